@@ -0,0 +1,12 @@
+package activity
+
+import (
+	"github.com/cschleiden/go-workflows/workflowerrors"
+)
+
+// NewError returns an error with the given type. Workflows can inspect the type of errors returned by
+// activities with workflow.ErrorType, and stop retrying errors of certain types with
+// workflow.RetryOptions.NonRetryableErrorTypes.
+func NewError(errorType, message string) error {
+	return workflowerrors.NewApplicationError(errorType, message)
+}
@@ -0,0 +1,57 @@
+package activity
+
+import (
+	"context"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/activity"
+)
+
+// ActivityExecutionInfo describes the current activity execution, as scheduled by its workflow.
+type ActivityExecutionInfo struct {
+	// ActivityID identifies this execution.
+	ActivityID string
+
+	// InstanceID and ExecutionID identify the workflow instance that scheduled the activity.
+	InstanceID  string
+	ExecutionID string
+
+	// Name is the name the activity was registered with.
+	Name string
+
+	// Attempt is the retry attempt of this execution, starting at 0.
+	Attempt int
+
+	// MaxAttempts is the maximum number of attempts configured in the retry options of the activity.
+	MaxAttempts int
+
+	// RetryDeadline is the time after which the activity is not retried anymore, zero if retries are not limited
+	// by time.
+	RetryDeadline time.Time
+
+	// CancellationRequested is true if the workflow instance had been canceled when the activity was scheduled,
+	// e.g., for cleanup activities.
+	CancellationRequested bool
+}
+
+// ExecutionInfo returns information about the current activity execution.
+func ExecutionInfo(ctx context.Context) ActivityExecutionInfo {
+	as := activity.GetActivityState(ctx)
+	a := as.Attributes
+
+	info := ActivityExecutionInfo{
+		ActivityID:            as.ActivityID,
+		InstanceID:            as.Instance.InstanceID,
+		ExecutionID:           as.Instance.ExecutionID,
+		Name:                  a.Name,
+		Attempt:               a.Attempt,
+		MaxAttempts:           a.MaxAttempts,
+		CancellationRequested: a.CancellationRequested,
+	}
+
+	if a.RetryDeadline != nil {
+		info.RetryDeadline = *a.RetryDeadline
+	}
+
+	return info
+}
@@ -0,0 +1,58 @@
+package activity
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/internal/activity"
+	"github.com/cschleiden/go-workflows/internal/converter"
+)
+
+// RecordHeartbeat reports that the activity is still making progress. Activities with a heartbeat timeout, see
+// workflow.ActivityOptions.HeartbeatTimeout, have to call it at least once per timeout, otherwise the attempt
+// fails and is retried.
+//
+// details are optional and describe the progress of the activity, e.g., the last processed item. If the backend
+// supports it, they are stored with the activity and are available with HeartbeatDetails when the activity is
+// executed again after a worker crashed, or when the activity is retried after it failed.
+func RecordHeartbeat(ctx context.Context, details interface{}) {
+	h := activity.GetHeartbeat(ctx)
+	if h == nil {
+		return
+	}
+
+	if details == nil {
+		h.Record(nil)
+		return
+	}
+
+	p, err := converter.DefaultConverter.To(details)
+	if err != nil {
+		Logger(ctx).Warn("Could not convert heartbeat details", "error", err)
+		h.Record(nil)
+		return
+	}
+
+	h.Record(p)
+}
+
+// HeartbeatDetails decodes the details of the last heartbeat recorded for this activity into v, either by this
+// execution or by a previous one. It returns false if no details have been recorded.
+func HeartbeatDetails(ctx context.Context, v interface{}) (bool, error) {
+	var details []byte
+	if h := activity.GetHeartbeat(ctx); h != nil {
+		details = h.Details()
+	} else if as := activity.GetActivityState(ctx); as.Attributes != nil {
+		details = as.Attributes.HeartbeatDetails
+	}
+
+	if len(details) == 0 {
+		return false, nil
+	}
+
+	if err := converter.DefaultConverter.From(details, v); err != nil {
+		return false, fmt.Errorf("converting heartbeat details: %w", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,29 @@
+package activity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/internal/activity"
+	"github.com/cschleiden/go-workflows/internal/converter"
+)
+
+var ErrNotStreaming = errors.New("activity was not started as a streaming activity")
+
+// Stream sends an intermediate result to the workflow that started this activity with
+// workflow.ExecuteStreamingActivity. Chunks are delivered in the order they are sent, before the
+// activity result.
+func Stream(ctx context.Context, chunk interface{}) error {
+	s := activity.GetStreamer(ctx)
+	if s == nil {
+		return ErrNotStreaming
+	}
+
+	p, err := converter.DefaultConverter.To(chunk)
+	if err != nil {
+		return fmt.Errorf("converting stream chunk: %w", err)
+	}
+
+	return s(ctx, p)
+}
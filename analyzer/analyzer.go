@@ -124,6 +124,14 @@ func run(pass *analysis.Pass) (interface{}, error) {
 				case "Sleep":
 					pass.Reportf(n.Pos(), "`time.Sleep` is not allowed in workflows, use `workflow.Sleep` instead")
 				}
+
+			case "math/rand":
+				switch id.Name {
+				case "New", "NewSource", "NewZipf":
+					// Generators with an explicit seed are deterministic
+				default:
+					pass.Reportf(n.Pos(), "`rand.%s` is not allowed in workflows, use `workflow.Random` instead", id.Name)
+				}
 			}
 		}
 
@@ -6,6 +6,7 @@ import (
 	"context"
 	workflow "context"
 	"fmt"
+	"math/rand"
 	"time"
 
 	"sync"
@@ -100,6 +101,10 @@ func wfChanNestedFunc(ctx workflow.Context) error {
 func wfFunctionUsage(ctx workflow.Context) error {
 	time.Sleep(10 * time.Second) // want "`time.Sleep` is not allowed in workflows, use `workflow.Sleep` instead"
 	fmt.Println(time.Now())      // want "`time.Now` is not allowed in workflows, use `workflow.Now` instead"
+	fmt.Println(rand.Intn(10))   // want "`rand.Intn` is not allowed in workflows, use `workflow.Random` instead"
+
+	r := rand.New(rand.NewSource(42))
+	fmt.Println(r.Intn(10))
 
 	return nil
 }
@@ -0,0 +1,254 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+var ErrNotFound = errors.New("archived workflow instance not found")
+var ErrInstanceNotFinished = errors.New("workflow instance is not finished")
+
+type Status string
+
+const (
+	StatusCompleted  Status = "completed"
+	StatusFailed     Status = "failed"
+	StatusCanceled   Status = "canceled"
+	StatusTerminated Status = "terminated"
+)
+
+// Record is the minimal metadata kept in the index for an archived workflow instance.
+type Record struct {
+	InstanceID  string `json:"instance_id"`
+	ExecutionID string `json:"execution_id"`
+
+	// Name of the workflow
+	Name string `json:"name"`
+
+	ClosedAt time.Time `json:"closed_at"`
+	Status   Status    `json:"status"`
+
+	SearchAttributes map[string]string `json:"search_attributes,omitempty"`
+
+	// HistoryKey is the key of the archived history in the store
+	HistoryKey string `json:"history_key"`
+}
+
+// Store holds archived histories, for example in object storage.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) error
+
+	// Get returns ErrNotFound if there is no data for the given key.
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// Index is the visibility store for archived instances. Records are kept independently of the backend,
+// so archived instances remain discoverable after their history has been purged from it.
+type Index interface {
+	Add(ctx context.Context, record *Record) error
+
+	// Get returns ErrNotFound if no record exists for the given instance.
+	Get(ctx context.Context, instanceID string) (*Record, error)
+
+	// List returns records matching the given options, ordered by instance ID.
+	List(ctx context.Context, options ListOptions) ([]*Record, error)
+}
+
+type ListOptions struct {
+	// Name only returns instances of the workflow with the given name, if set
+	Name string
+
+	// Status only returns instances with the given status, if set
+	Status Status
+
+	// ClosedAfter and ClosedBefore limit the close time of returned instances, if set
+	ClosedAfter  time.Time
+	ClosedBefore time.Time
+
+	// SearchAttributes only returns instances where all of the given attributes match
+	SearchAttributes map[string]string
+
+	// AfterInstanceID and Count page through the results
+	AfterInstanceID string
+	Count           int
+}
+
+// Matches returns true if the given record matches the filters of the options.
+func (o *ListOptions) Matches(r *Record) bool {
+	if o.Name != "" && r.Name != o.Name {
+		return false
+	}
+
+	if o.Status != "" && r.Status != o.Status {
+		return false
+	}
+
+	if !o.ClosedAfter.IsZero() && !r.ClosedAt.After(o.ClosedAfter) {
+		return false
+	}
+
+	if !o.ClosedBefore.IsZero() && !r.ClosedAt.Before(o.ClosedBefore) {
+		return false
+	}
+
+	for k, v := range o.SearchAttributes {
+		if r.SearchAttributes[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+type Archiver struct {
+	backend backend.Backend
+	store   Store
+	index   Index
+}
+
+func NewArchiver(backend backend.Backend, store Store, index Index) *Archiver {
+	return &Archiver{
+		backend: backend,
+		store:   store,
+		index:   index,
+	}
+}
+
+// Archive copies the history of the given finished instance to the store and indexes it together with the
+// given search attributes. Once archived, the history can be purged from the backend. Executions the instance
+// continued from are archived when passed as instance, if the backend keeps them, see
+// backend.ExecutionHistoryReader. Instances on hold are not archived, Archive returns backend.ErrInstanceHeld for
+// them.
+func (a *Archiver) Archive(ctx context.Context, instance *workflow.Instance, searchAttributes map[string]string) (*Record, error) {
+	state, err := a.backend.GetWorkflowInstanceState(ctx, instance)
+	if err != nil {
+		return nil, fmt.Errorf("getting workflow instance state: %w", err)
+	}
+
+	if h, ok := a.backend.(backend.InstanceHolder); ok {
+		hold, err := h.GetWorkflowInstanceHold(ctx, instance)
+		if err != nil {
+			return nil, fmt.Errorf("getting workflow instance hold: %w", err)
+		}
+
+		if hold != nil {
+			return nil, backend.ErrInstanceHeld
+		}
+	}
+
+	h, err := backend.GetWorkflowExecutionHistory(ctx, a.backend, instance)
+	if err != nil {
+		return nil, fmt.Errorf("getting workflow instance history: %w", err)
+	}
+
+	// Executions the instance continued from are finished, even while the instance is running
+	if state != core.WorkflowInstanceStateFinished && !continuedAsNew(h) {
+		return nil, ErrInstanceNotFinished
+	}
+
+	record := &Record{
+		InstanceID:       instance.InstanceID,
+		ExecutionID:      instance.ExecutionID,
+		SearchAttributes: searchAttributes,
+		HistoryKey:       fmt.Sprintf("%s/%s", instance.InstanceID, instance.ExecutionID),
+	}
+
+	for i := range h {
+		event := &h[i]
+		switch event.Type {
+		case history.EventType_WorkflowExecutionStarted:
+			a, err := history.AttributesAs[*history.ExecutionStartedAttributes](event)
+			if err != nil {
+				return nil, err
+			}
+
+			record.Name = a.Name
+
+		case history.EventType_WorkflowExecutionFinished:
+			a, err := history.AttributesAs[*history.ExecutionCompletedAttributes](event)
+			if err != nil {
+				return nil, err
+			}
+
+			record.ClosedAt = event.Timestamp
+			record.Status = StatusCompleted
+			if a.Error != "" {
+				record.Status = StatusFailed
+			}
+
+		case history.EventType_WorkflowExecutionCanceled:
+			record.ClosedAt = event.Timestamp
+			record.Status = StatusCanceled
+
+		case history.EventType_WorkflowExecutionTerminated:
+			record.ClosedAt = event.Timestamp
+			record.Status = StatusTerminated
+		}
+	}
+
+	data, err := json.Marshal(h)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling history: %w", err)
+	}
+
+	if err := a.store.Put(ctx, record.HistoryKey, data); err != nil {
+		return nil, fmt.Errorf("storing history: %w", err)
+	}
+
+	// Index the instance only after the history has been stored, so every record can be resolved
+	if err := a.index.Add(ctx, record); err != nil {
+		return nil, fmt.Errorf("indexing archived instance: %w", err)
+	}
+
+	return record, nil
+}
+
+// continuedAsNew returns true if the given history is of an execution that continued as new.
+func continuedAsNew(h []history.Event) bool {
+	for i := range h {
+		if h[i].Type != history.EventType_WorkflowExecutionFinished {
+			continue
+		}
+
+		a, err := history.AttributesAs[*history.ExecutionCompletedAttributes](&h[i])
+		if err == nil && a.ContinuedAsNew != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ListArchived returns the records of archived instances matching the given options. Histories are not
+// loaded, use GetArchivedHistory to retrieve them.
+func (a *Archiver) ListArchived(ctx context.Context, options ListOptions) ([]*Record, error) {
+	return a.index.List(ctx, options)
+}
+
+// GetArchivedHistory loads the history of an archived instance from the store.
+func (a *Archiver) GetArchivedHistory(ctx context.Context, instanceID string) ([]history.Event, error) {
+	record, err := a.index.Get(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := a.store.Get(ctx, record.HistoryKey)
+	if err != nil {
+		return nil, fmt.Errorf("loading history: %w", err)
+	}
+
+	var h []history.Event
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("unmarshaling history: %w", err)
+	}
+
+	return h, nil
+}
@@ -0,0 +1,83 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/backend/sqlite"
+	"github.com/cschleiden/go-workflows/client"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/worker"
+	"github.com/cschleiden/go-workflows/workflow"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Archiver(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := sqlite.NewInMemoryBackend(backend.WithStickyTimeout(0))
+	c := client.New(b)
+	w := worker.New(b, nil)
+
+	wf1 := func(ctx workflow.Context) (int, error) {
+		return 42, nil
+	}
+	wf2 := func(ctx workflow.Context) error {
+		return errors.New("failed")
+	}
+	require.NoError(t, w.RegisterWorkflow(wf1))
+	require.NoError(t, w.RegisterWorkflow(wf2))
+	require.NoError(t, w.Start(ctx))
+
+	run := func(wf interface{}) *workflow.Instance {
+		instance, err := c.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+			InstanceID: uuid.NewString(),
+		}, wf)
+		require.NoError(t, err)
+		require.NoError(t, c.WaitForWorkflowInstance(ctx, instance, time.Second*5))
+
+		return instance
+	}
+
+	i1 := run(wf1)
+	i2 := run(wf2)
+
+	a := NewArchiver(b, NewMemoryStore(), NewMemoryIndex())
+
+	r1, err := a.Archive(ctx, i1, map[string]string{"customer": "c1"})
+	require.NoError(t, err)
+	require.Equal(t, StatusCompleted, r1.Status)
+	require.False(t, r1.ClosedAt.IsZero())
+	require.NotEmpty(t, r1.Name)
+
+	r2, err := a.Archive(ctx, i2, map[string]string{"customer": "c2"})
+	require.NoError(t, err)
+	require.Equal(t, StatusFailed, r2.Status)
+
+	records, err := a.ListArchived(ctx, ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	records, err = a.ListArchived(ctx, ListOptions{SearchAttributes: map[string]string{"customer": "c2"}})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, i2.InstanceID, records[0].InstanceID)
+
+	records, err = a.ListArchived(ctx, ListOptions{Name: r1.Name, Status: StatusCompleted})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, i1.InstanceID, records[0].InstanceID)
+
+	h, err := a.GetArchivedHistory(ctx, i1.InstanceID)
+	require.NoError(t, err)
+	require.NotEmpty(t, h)
+	require.Equal(t, history.EventType_WorkflowExecutionFinished, h[len(h)-1].Type)
+
+	_, err = a.GetArchivedHistory(ctx, uuid.NewString())
+	require.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,95 @@
+package archive
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+type memoryStore struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+// NewMemoryStore returns a store keeping archived histories in memory, useful for testing.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		data: map[string][]byte{},
+	}
+}
+
+func (s *memoryStore) Put(ctx context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[key] = data
+
+	return nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.data[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return data, nil
+}
+
+type memoryIndex struct {
+	mu      sync.RWMutex
+	records map[string]*Record
+}
+
+// NewMemoryIndex returns an index keeping records of archived instances in memory, useful for testing.
+func NewMemoryIndex() Index {
+	return &memoryIndex{
+		records: map[string]*Record{},
+	}
+}
+
+func (i *memoryIndex) Add(ctx context.Context, record *Record) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.records[record.InstanceID] = record
+
+	return nil
+}
+
+func (i *memoryIndex) Get(ctx context.Context, instanceID string) (*Record, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	r, ok := i.records[instanceID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return r, nil
+}
+
+func (i *memoryIndex) List(ctx context.Context, options ListOptions) ([]*Record, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	records := make([]*Record, 0)
+	for _, r := range i.records {
+		if r.InstanceID > options.AfterInstanceID && options.Matches(r) {
+			records = append(records, r)
+		}
+	}
+
+	sort.Slice(records, func(a, b int) bool {
+		return records[a].InstanceID < records[b].InstanceID
+	})
+
+	if options.Count > 0 && len(records) > options.Count {
+		records = records[:options.Count]
+	}
+
+	return records, nil
+}
@@ -0,0 +1,212 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Options configures a store writing to S3 compatible object storage.
+type S3Options struct {
+	// Endpoint is the URL of the storage service, e.g., "https://s3.eu-west-1.amazonaws.com", or
+	// "https://storage.googleapis.com" for Google Cloud Storage.
+	Endpoint string
+
+	// Region is the region of the bucket, e.g., "eu-west-1". Google Cloud Storage accepts "auto".
+	Region string
+
+	// Bucket is the name of the bucket objects are stored in, it's added to the path of the endpoint. If empty,
+	// keys are relative to the endpoint, e.g., for virtual-hosted-style endpoints like
+	// "https://bucket.s3.eu-west-1.amazonaws.com".
+	Bucket string
+
+	// AccessKeyID and SecretAccessKey are the credentials requests are signed with. For Google Cloud Storage, these
+	// are HMAC keys of a service account.
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// SessionToken is set for temporary credentials.
+	SessionToken string
+
+	// Client is used to send requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+type s3Store struct {
+	options S3Options
+	now     func() time.Time
+}
+
+// NewS3Store returns a store keeping objects in a bucket of S3, or any storage service implementing the S3 API and
+// AWS Signature Version 4, like Google Cloud Storage in interoperability mode or MinIO.
+func NewS3Store(options S3Options) Store {
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+
+	return &s3Store{
+		options: options,
+		now:     time.Now,
+	}
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	return nil
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+
+	default:
+		return nil, responseError(resp)
+	}
+}
+
+func (s *s3Store) do(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	u, err := url.Parse(s.options.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing endpoint: %w", err)
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+	if s.options.Bucket != "" {
+		path += "/" + s.options.Bucket
+	}
+	path += "/" + key
+
+	u.Path = path
+	u.RawPath = escapePath(path)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if body == nil {
+		// Don't send an empty body with GET requests
+		req.Body = nil
+		req.ContentLength = 0
+	}
+
+	s.sign(req, body)
+
+	resp, err := s.options.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+
+	return resp, nil
+}
+
+// sign adds the AWS Signature Version 4 authorization header to the request. All headers set on the request are
+// signed.
+func (s *s3Store) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("x-amz-content-sha256", hex.EncodeToString(payloadHash[:]))
+	req.Header.Set("x-amz-date", amzDate)
+	if s.options.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", s.options.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		req.Header.Get("x-amz-content-sha256"),
+	}, "\n")
+
+	scope := date + "/" + s.options.Region + "/s3/aws4_request"
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalRequestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.options.SecretAccessKey), date)
+	key = hmacSHA256(key, s.options.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		s.options.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// escapePath encodes every byte of the path except unreserved characters and slashes, as required for signing.
+func escapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected response %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
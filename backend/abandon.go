@@ -0,0 +1,26 @@
+package backend
+
+import (
+	"context"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/task"
+)
+
+// WorkflowTaskAbandoner is implemented by backends that can release a workflow task without completing
+// it. Workers use it to retry tasks that failed because of a transient error. For other backends, the
+// task becomes available again when its lock expires.
+type WorkflowTaskAbandoner interface {
+	// AbandonWorkflowTask releases the lock on the given task. The task can be picked up again after
+	// the given delay.
+	AbandonWorkflowTask(ctx context.Context, t *task.Workflow, delay time.Duration) error
+}
+
+// WorkflowTaskParker is implemented by backends that can release a workflow task while keeping its instance
+// sticky to the current worker. Workers use it to park tasks that exceeded their replay budget, so that the
+// worker holding the partially replayed executor resumes the replay.
+type WorkflowTaskParker interface {
+	// ParkWorkflowTask releases the lock on the given task like AbandonWorkflowTask. Other workers can only
+	// pick up the task once the sticky timeout has passed after the delay.
+	ParkWorkflowTask(ctx context.Context, t *task.Workflow, delay time.Duration) error
+}
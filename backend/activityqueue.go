@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"github.com/cschleiden/go-workflows/internal/activityqueue"
+	"github.com/cschleiden/go-workflows/internal/history"
+)
+
+// DefaultActivityQueue is the queue activities are dispatched to when their options don't specify one.
+const DefaultActivityQueue = ""
+
+// MaxActivityLabels is the maximum number of labels of an activity or a worker. Workers serve a queue for every
+// combination of their labels, 2^n queues for n labels.
+const MaxActivityLabels = activityqueue.MaxLabels
+
+// ValidateActivityQueue returns an error if activities can't be dispatched to the given queue with the given
+// labels. Queues can't contain "#", labels can't contain "#" or ",", and there can be at most MaxActivityLabels
+// distinct labels.
+func ValidateActivityQueue(queue string, labels ...string) error {
+	return activityqueue.Validate(queue, labels...)
+}
+
+// ActivityQueue returns the queue the activity scheduled by the given ActivityScheduled event is dispatched to.
+func ActivityQueue(event *history.Event) string {
+	a, err := history.AttributesAs[*history.ActivityScheduledAttributes](event)
+	if err != nil {
+		return DefaultActivityQueue
+	}
+
+	return LabeledActivityQueue(a.Queue, a.Labels...)
+}
+
+// LabeledActivityQueue returns the queue for activities on the given queue that require the given labels. Labels
+// are sorted and deduplicated, so the order they are given in doesn't matter. Without labels, this is the queue
+// itself.
+func LabeledActivityQueue(queue string, labels ...string) string {
+	return activityqueue.Labeled(queue, labels...)
+}
+
+// labeledActivityQueues returns the queues for activities on the given queue that require any subset of the
+// given labels, including the queue itself for activities without labels.
+func labeledActivityQueues(queue string, labels []string) []string {
+	labels = activityqueue.Normalize(labels)
+
+	queues := make([]string, 0, 1<<len(labels))
+	for subset := 0; subset < 1<<len(labels); subset++ {
+		selected := make([]string, 0, len(labels))
+		for i, label := range labels {
+			if subset&(1<<i) != 0 {
+				selected = append(selected, label)
+			}
+		}
+
+		queues = append(queues, LabeledActivityQueue(queue, selected...))
+	}
+
+	return queues
+}
@@ -0,0 +1,65 @@
+package backend
+
+import (
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+)
+
+// DelayActivityTimeouts returns the given timer events with the schedule-to-start timeouts of delayed activities
+// moved by the time the activities are delayed, so that the timeouts are measured from the time the activities
+// become visible to workers. Backends that set VisibleAt for activity events, e.g., to limit the rate of activities
+// of an instance, call it with those events before scheduling the timer events of a workflow task.
+func DelayActivityTimeouts(activityEvents, timerEvents []history.Event) []history.Event {
+	delays := make(map[int64]time.Duration)
+	for _, event := range activityEvents {
+		if event.VisibleAt != nil && event.VisibleAt.After(event.Timestamp) {
+			delays[event.ScheduleEventID] = event.VisibleAt.Sub(event.Timestamp)
+		}
+	}
+
+	if len(delays) == 0 {
+		return timerEvents
+	}
+
+	delayed := make([]history.Event, len(timerEvents))
+	for i, event := range timerEvents {
+		// Schedule event IDs are unique within an instance, so failures sharing the ID of an activity are its timeouts
+		if d, ok := delays[event.ScheduleEventID]; ok && event.Type == history.EventType_ActivityFailed && event.VisibleAt != nil {
+			visibleAt := event.VisibleAt.Add(d)
+			event.VisibleAt = &visibleAt
+		}
+
+		delayed[i] = event
+	}
+
+	return delayed
+}
+
+// RemovedFutureEvents returns the schedule event ids of events scheduled for the future that the given executed
+// events make obsolete: canceled and rescheduled timers, and the timeouts of activities that started or were
+// resolved. Backends remove these events before scheduling the timer events of the same workflow task, which
+// might reuse the schedule event ids, e.g., for rescheduled timers or start-to-close timeouts.
+func RemovedFutureEvents(executedEvents []history.Event) []int64 {
+	var removed []int64
+	for i := range executedEvents {
+		event := &executedEvents[i]
+
+		switch event.Type {
+		case history.EventType_TimerCanceled, history.EventType_TimerRescheduled,
+			history.EventType_ActivityStarted, history.EventType_ActivityCompleted:
+			removed = append(removed, event.ScheduleEventID)
+
+		case history.EventType_ActivityFailed:
+			// A schedule-to-start timeout that fires after the activity started is ignored, it must not remove the
+			// start-to-close timeout of the running activity
+			if a, err := history.AttributesAs[*history.ActivityFailedAttributes](event); err == nil && a.Timeout == history.ActivityTimeout_ScheduleToStart {
+				continue
+			}
+
+			removed = append(removed, event.ScheduleEventID)
+		}
+	}
+
+	return removed
+}
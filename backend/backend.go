@@ -23,10 +23,15 @@ type Backend interface {
 	// CreateWorkflowInstance creates a new workflow instance
 	CreateWorkflowInstance(ctx context.Context, instance *workflow.Instance, event history.Event) error
 
+	// CreateWorkflowInstances creates multiple workflow instances atomically. Either all instances are
+	// created, or none of them are.
+	CreateWorkflowInstances(ctx context.Context, instances []history.WorkflowEvent) error
+
 	// CancelWorkflowInstance cancels a running workflow instance
 	CancelWorkflowInstance(ctx context.Context, instance *workflow.Instance, cancelEvent *history.Event) error
 
-	// GetWorkflowInstanceState returns the state of the given workflow instance
+	// GetWorkflowInstanceState returns the state of the given workflow instance. The instance is looked up by its
+	// instance ID, so for instances that continued as new, this is the state of the latest execution.
 	GetWorkflowInstanceState(ctx context.Context, instance *workflow.Instance) (core.WorkflowInstanceState, error)
 
 	// GetWorkflowInstanceHistory returns the workflow history for the given instance. When lastSequenceID
@@ -0,0 +1,111 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+// BackupVersion is the version of the backup format written by WriteBackup.
+const BackupVersion = 1
+
+// Backup is a portable copy of the workflow instances of a backend, with their histories and pending work.
+type Backup struct {
+	Version   int               `json:"version"`
+	CreatedAt time.Time         `json:"created_at"`
+	Instances []*BackupInstance `json:"instances"`
+}
+
+// BackupInstance is a workflow instance in a Backup.
+type BackupInstance struct {
+	Instance    *workflow.Instance `json:"instance"`
+	Metadata    *workflow.Metadata `json:"metadata,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	CompletedAt *time.Time         `json:"completed_at,omitempty"`
+	RemovedAt   *time.Time         `json:"removed_at,omitempty"`
+	Hold        *Hold              `json:"hold,omitempty"`
+
+	History []history.Event `json:"history"`
+
+	// PendingEvents are events that have not been executed by a workflow task yet, including future timer
+	// events.
+	PendingEvents []history.Event `json:"pending_events"`
+
+	// Activities are the ActivityScheduled events of activities that have not completed yet.
+	Activities []history.Event `json:"activities"`
+
+	// ActivityHeartbeatDetails are the details of the last heartbeats recorded by the activities, by activity ID,
+	// see ActivityHeartbeatRecorder.
+	ActivityHeartbeatDetails map[string]payload.Payload `json:"activity_heartbeat_details,omitempty"`
+
+	// NextActivityAt is the earliest time the next activity of the instance becomes visible to workers, if the
+	// rate of its activities is limited, see WithInstanceActivityRate.
+	NextActivityAt *time.Time `json:"next_activity_at,omitempty"`
+
+	// Executions are the finished executions the instance continued from, in the order they completed.
+	Executions []*BackupExecution `json:"executions,omitempty"`
+}
+
+// BackupExecution is a finished execution of a workflow instance in a Backup, see ExecutionHistoryReader.
+type BackupExecution struct {
+	Instance    *workflow.Instance `json:"instance"`
+	Metadata    *workflow.Metadata `json:"metadata,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	CompletedAt time.Time          `json:"completed_at"`
+
+	History []history.Event `json:"history"`
+}
+
+// BackupRestorer is implemented by backends that can back up and restore all of their workflow instances.
+type BackupRestorer interface {
+	// BackupWorkflowInstances returns a copy of all workflow instances, including removed ones.
+	BackupWorkflowInstances(ctx context.Context) (*Backup, error)
+
+	// RestoreWorkflowInstances adds the instances of the given backup. Locks held by workers when the backup was
+	// taken are not restored. It returns ErrInstanceAlreadyExists if an instance already exists, in which case
+	// no instance is restored.
+	RestoreWorkflowInstances(ctx context.Context, backup *Backup) error
+}
+
+var ErrBackupNotSupported = errors.New("backend does not support backups")
+
+// WriteBackup writes a backup of all workflow instances of the given backend as JSON to w.
+func WriteBackup(ctx context.Context, b Backend, w io.Writer) error {
+	br, ok := b.(BackupRestorer)
+	if !ok {
+		return ErrBackupNotSupported
+	}
+
+	backup, err := br.BackupWorkflowInstances(ctx)
+	if err != nil {
+		return fmt.Errorf("backing up workflow instances: %w", err)
+	}
+
+	return json.NewEncoder(w).Encode(backup)
+}
+
+// RestoreBackup reads a backup written by WriteBackup from r, and restores its instances into the given backend.
+func RestoreBackup(ctx context.Context, b Backend, r io.Reader) error {
+	br, ok := b.(BackupRestorer)
+	if !ok {
+		return ErrBackupNotSupported
+	}
+
+	var backup Backup
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return fmt.Errorf("reading backup: %w", err)
+	}
+
+	if backup.Version != BackupVersion {
+		return fmt.Errorf("unsupported backup version %d", backup.Version)
+	}
+
+	return br.RestoreWorkflowInstances(ctx, &backup)
+}
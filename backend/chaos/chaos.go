@@ -0,0 +1,389 @@
+// Package chaos provides a backend decorator that injects faults, for testing the resilience of workers and
+// workflows, e.g., in CI. The faults stay within the guarantees backends give: tasks are delivered at least
+// once, locks can expire, and calls can fail or take long.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/task"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+// ErrInjected is returned by calls that fail because of an injected fault.
+var ErrInjected = errors.New("chaos: injected fault")
+
+// Faults configures the faults injected by the backend. Rates are probabilities between 0 and 1.
+type Faults struct {
+	// Latency is the maximum delay added to every backend call. Each call is delayed by a random duration up to
+	// Latency.
+	Latency time.Duration
+
+	// PollErrorRate is the rate at which polling for workflow and activity tasks fails with ErrInjected.
+	PollErrorRate float64
+
+	// DuplicateActivityRate is the rate at which activity tasks are delivered a second time, as if the lock of the
+	// first worker had expired. Completions of a duplicated task that the wrapped backend rejects are not surfaced
+	// to the worker. Depending on the backend, the results of both deliveries might be stored, e.g., the Redis
+	// backend accepts completing a task twice; workflows only use the first result of an activity.
+	DuplicateActivityRate float64
+
+	// DuplicateWorkflowTaskRate is the rate at which workflow tasks are delivered a second time, as if the lock of
+	// the first worker had expired before it could complete the task. The completion of the first delivery is
+	// dropped, as a backend would reject it, and the task is delivered again once the first worker has tried to
+	// complete it, so the task is executed twice but only committed once.
+	DuplicateWorkflowTaskRate float64
+
+	// LockExpiryRate is the rate at which the lock of a task expires when the worker extends it, so the task is
+	// delivered a second time while it's still being executed. Activity tasks are then handled like duplicated
+	// ones, see DuplicateActivityRate, and workflow tasks like DuplicateWorkflowTaskRate describes.
+	LockExpiryRate float64
+
+	// LostActivityResultRate is the rate at which activity results are dropped, as if the worker crashed right
+	// after executing the activity. The activity is executed again once its lock expires.
+	LostActivityResultRate float64
+
+	// SkippedHeartbeatRate is the rate at which extending the lock of an activity task is skipped, so the locks of
+	// long running activities can expire and the activities are executed again. Heartbeats of workflow tasks are
+	// not skipped.
+	SkippedHeartbeatRate float64
+
+	// Seed seeds the random number generator deciding which faults are injected. 0 uses the current time.
+	Seed int64
+}
+
+type chaosBackend struct {
+	backend.Backend
+
+	faults Faults
+
+	mu   sync.Mutex
+	rand *rand.Rand
+
+	// duplicates are activity tasks waiting to be delivered again
+	duplicates []*task.Activity
+
+	// activities are the delivered activity tasks by ID, deliveries counts their outstanding deliveries, and
+	// settled tracks duplicated tasks that have been completed
+	activities map[string]*task.Activity
+	deliveries map[string]int
+	settled    map[string]bool
+
+	// workflowTasks are copies of the delivered workflow tasks by ID, as they were delivered. expired tracks the
+	// tasks whose current delivery lost its lock, and workflowDuplicates are tasks waiting to be delivered again.
+	workflowTasks      map[string]*task.Workflow
+	expired            map[string]bool
+	workflowDuplicates []*task.Workflow
+}
+
+// NewBackend returns a backend injecting the given faults into calls to b. Optional interfaces implemented by b,
+// e.g., backend.InstanceRemover, are not exposed by the returned backend.
+func NewBackend(b backend.Backend, faults Faults) backend.Backend {
+	seed := faults.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &chaosBackend{
+		Backend:       b,
+		faults:        faults,
+		rand:          rand.New(rand.NewSource(seed)),
+		activities:    make(map[string]*task.Activity),
+		deliveries:    make(map[string]int),
+		settled:       make(map[string]bool),
+		workflowTasks: make(map[string]*task.Workflow),
+		expired:       make(map[string]bool),
+	}
+}
+
+func (b *chaosBackend) CreateWorkflowInstance(ctx context.Context, instance *workflow.Instance, event history.Event) error {
+	if err := b.delay(ctx); err != nil {
+		return err
+	}
+
+	return b.Backend.CreateWorkflowInstance(ctx, instance, event)
+}
+
+func (b *chaosBackend) CreateWorkflowInstances(ctx context.Context, instances []history.WorkflowEvent) error {
+	if err := b.delay(ctx); err != nil {
+		return err
+	}
+
+	return b.Backend.CreateWorkflowInstances(ctx, instances)
+}
+
+func (b *chaosBackend) CancelWorkflowInstance(ctx context.Context, instance *workflow.Instance, cancelEvent *history.Event) error {
+	if err := b.delay(ctx); err != nil {
+		return err
+	}
+
+	return b.Backend.CancelWorkflowInstance(ctx, instance, cancelEvent)
+}
+
+func (b *chaosBackend) GetWorkflowInstanceState(ctx context.Context, instance *workflow.Instance) (core.WorkflowInstanceState, error) {
+	if err := b.delay(ctx); err != nil {
+		return core.WorkflowInstanceStateActive, err
+	}
+
+	return b.Backend.GetWorkflowInstanceState(ctx, instance)
+}
+
+func (b *chaosBackend) GetWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, lastSequenceID *int64) ([]history.Event, error) {
+	if err := b.delay(ctx); err != nil {
+		return nil, err
+	}
+
+	return b.Backend.GetWorkflowInstanceHistory(ctx, instance, lastSequenceID)
+}
+
+func (b *chaosBackend) SignalWorkflow(ctx context.Context, instanceID string, event history.Event) error {
+	if err := b.delay(ctx); err != nil {
+		return err
+	}
+
+	return b.Backend.SignalWorkflow(ctx, instanceID, event)
+}
+
+func (b *chaosBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, error) {
+	if err := b.delay(ctx); err != nil {
+		return nil, err
+	}
+
+	if b.chance(b.faults.PollErrorRate) {
+		return nil, ErrInjected
+	}
+
+	b.mu.Lock()
+	if len(b.workflowDuplicates) > 0 {
+		t := b.workflowDuplicates[0]
+		b.workflowDuplicates = b.workflowDuplicates[1:]
+		b.mu.Unlock()
+
+		b.Logger().Debug("chaos: delivering duplicate workflow task", "task_id", t.ID)
+
+		return b.deliverWorkflowTask(t), nil
+	}
+	b.mu.Unlock()
+
+	t, err := b.Backend.GetWorkflowTask(ctx)
+	if err != nil || t == nil {
+		return t, err
+	}
+
+	t = b.deliverWorkflowTask(t)
+
+	if b.chance(b.faults.DuplicateWorkflowTaskRate) {
+		b.expireWorkflowTask(t.ID)
+	}
+
+	return t, nil
+}
+
+func (b *chaosBackend) ExtendWorkflowTask(ctx context.Context, taskID string, instance *core.WorkflowInstance) error {
+	if err := b.delay(ctx); err != nil {
+		return err
+	}
+
+	if b.chance(b.faults.LockExpiryRate) {
+		b.expireWorkflowTask(taskID)
+	}
+
+	return b.Backend.ExtendWorkflowTask(ctx, taskID, instance)
+}
+
+func (b *chaosBackend) CompleteWorkflowTask(
+	ctx context.Context, task *task.Workflow, instance *workflow.Instance, state core.WorkflowInstanceState,
+	executedEvents, activityEvents, timerEvents []history.Event, workflowEvents []history.WorkflowEvent,
+) error {
+	if err := b.delay(ctx); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	t := b.workflowTasks[task.ID]
+	delete(b.workflowTasks, task.ID)
+
+	if b.expired[task.ID] {
+		// Deliver the task again once this delivery is done, so that it isn't executed concurrently
+		delete(b.expired, task.ID)
+		b.workflowDuplicates = append(b.workflowDuplicates, t)
+		b.mu.Unlock()
+
+		b.Logger().Debug("chaos: dropping completion of workflow task with expired lock", "task_id", task.ID)
+
+		return nil
+	}
+	b.mu.Unlock()
+
+	return b.Backend.CompleteWorkflowTask(ctx, task, instance, state, executedEvents, activityEvents, timerEvents, workflowEvents)
+}
+
+func (b *chaosBackend) GetActivityTask(ctx context.Context) (*task.Activity, error) {
+	if err := b.delay(ctx); err != nil {
+		return nil, err
+	}
+
+	if b.chance(b.faults.PollErrorRate) {
+		return nil, ErrInjected
+	}
+
+	b.mu.Lock()
+	if len(b.duplicates) > 0 {
+		t := b.duplicates[0]
+		b.duplicates = b.duplicates[1:]
+		b.mu.Unlock()
+
+		b.Logger().Debug("chaos: delivering duplicate activity task", "activity_id", t.ID)
+
+		return t, nil
+	}
+	b.mu.Unlock()
+
+	t, err := b.Backend.GetActivityTask(ctx)
+	if err != nil || t == nil {
+		return t, err
+	}
+
+	duplicate := b.chance(b.faults.DuplicateActivityRate)
+
+	b.mu.Lock()
+	b.activities[t.ID] = t
+	b.deliveries[t.ID]++
+	if duplicate {
+		b.deliveries[t.ID]++
+		b.duplicates = append(b.duplicates, t)
+	}
+	b.mu.Unlock()
+
+	return t, nil
+}
+
+func (b *chaosBackend) CompleteActivityTask(ctx context.Context, instance *workflow.Instance, activityID string, event history.Event) error {
+	if err := b.delay(ctx); err != nil {
+		return err
+	}
+
+	if b.chance(b.faults.LostActivityResultRate) {
+		b.Logger().Debug("chaos: dropping activity result", "activity_id", activityID)
+		b.settle(activityID, false)
+
+		return nil
+	}
+
+	err := b.Backend.CompleteActivityTask(ctx, instance, activityID, event)
+
+	if duplicated := b.settle(activityID, err == nil); duplicated && err != nil {
+		// The backend rejects completions of tasks that have already been completed
+		b.Logger().Debug("chaos: dropping completion of duplicate activity task", "activity_id", activityID, "error", err)
+
+		return nil
+	}
+
+	return err
+}
+
+func (b *chaosBackend) ExtendActivityTask(ctx context.Context, activityID string) error {
+	if err := b.delay(ctx); err != nil {
+		return err
+	}
+
+	if b.chance(b.faults.SkippedHeartbeatRate) {
+		b.Logger().Debug("chaos: skipping activity heartbeat", "activity_id", activityID)
+
+		return nil
+	}
+
+	if b.chance(b.faults.LockExpiryRate) {
+		b.mu.Lock()
+		if t, ok := b.activities[activityID]; ok {
+			b.Logger().Debug("chaos: expiring lock of activity task", "activity_id", activityID)
+
+			b.deliveries[activityID]++
+			b.duplicates = append(b.duplicates, t)
+		}
+		b.mu.Unlock()
+	}
+
+	return b.Backend.ExtendActivityTask(ctx, activityID)
+}
+
+// deliverWorkflowTask records the delivery of the given workflow task, and returns a copy of it to deliver. The
+// original is kept to deliver the task again, since workers might modify the tasks they execute.
+func (b *chaosBackend) deliverWorkflowTask(t *task.Workflow) *task.Workflow {
+	c := *t
+	c.NewEvents = append([]history.Event(nil), t.NewEvents...)
+
+	b.mu.Lock()
+	b.workflowTasks[t.ID] = t
+	b.mu.Unlock()
+
+	return &c
+}
+
+// expireWorkflowTask marks the lock of the current delivery of the given workflow task as expired.
+func (b *chaosBackend) expireWorkflowTask(taskID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.workflowTasks[taskID]; ok {
+		b.expired[taskID] = true
+	}
+}
+
+// settle records that a delivery of the given activity task has finished. It returns true if the task has been
+// delivered more than once, and another delivery has been or might still be completed.
+func (b *chaosBackend) settle(activityID string, completed bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	duplicated := b.deliveries[activityID] > 1 || b.settled[activityID]
+
+	b.deliveries[activityID]--
+	if b.deliveries[activityID] <= 0 {
+		delete(b.activities, activityID)
+		delete(b.deliveries, activityID)
+		delete(b.settled, activityID)
+	} else if completed {
+		b.settled[activityID] = true
+	}
+
+	return duplicated
+}
+
+func (b *chaosBackend) chance(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.rand.Float64() < rate
+}
+
+func (b *chaosBackend) delay(ctx context.Context) error {
+	if b.faults.Latency <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	d := time.Duration(b.rand.Int63n(int64(b.faults.Latency)))
+	b.mu.Unlock()
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
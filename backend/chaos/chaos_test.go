@@ -0,0 +1,236 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/backend/sqlite"
+	"github.com/cschleiden/go-workflows/client"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/logger"
+	"github.com/cschleiden/go-workflows/internal/task"
+	"github.com/cschleiden/go-workflows/worker"
+	"github.com/cschleiden/go-workflows/workflow"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ChaosBackend_PollErrors(t *testing.T) {
+	b := &backend.MockBackend{}
+
+	cb := NewBackend(b, Faults{PollErrorRate: 1, Seed: 1})
+
+	_, err := cb.GetWorkflowTask(context.Background())
+	require.ErrorIs(t, err, ErrInjected)
+
+	_, err = cb.GetActivityTask(context.Background())
+	require.ErrorIs(t, err, ErrInjected)
+
+	b.AssertExpectations(t)
+}
+
+func Test_ChaosBackend_DuplicateActivity(t *testing.T) {
+	ctx := context.Background()
+	instance := core.NewWorkflowInstance("instance", "execution")
+	at := &task.Activity{ID: "activity", WorkflowInstance: instance}
+
+	b := &backend.MockBackend{}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("GetActivityTask", mock.Anything).Return(at, nil).Once()
+	b.On("CompleteActivityTask", mock.Anything, instance, "activity", mock.Anything).Return(nil).Once()
+	b.On("CompleteActivityTask", mock.Anything, instance, "activity", mock.Anything).Return(errors.New("could not find activity")).Once()
+
+	cb := NewBackend(b, Faults{DuplicateActivityRate: 1, Seed: 1})
+
+	t1, err := cb.GetActivityTask(ctx)
+	require.NoError(t, err)
+	require.Equal(t, at, t1)
+
+	// The duplicate is delivered without polling the wrapped backend
+	t2, err := cb.GetActivityTask(ctx)
+	require.NoError(t, err)
+	require.Equal(t, at, t2)
+
+	event := history.NewPendingEvent(time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{})
+
+	require.NoError(t, cb.CompleteActivityTask(ctx, instance, "activity", event))
+
+	// The backend rejects the second completion, which is not surfaced to the worker
+	require.NoError(t, cb.CompleteActivityTask(ctx, instance, "activity", event))
+
+	b.AssertExpectations(t)
+}
+
+func Test_ChaosBackend_ActivityLockExpiry(t *testing.T) {
+	ctx := context.Background()
+	instance := core.NewWorkflowInstance("instance", "execution")
+	at := &task.Activity{ID: "activity", WorkflowInstance: instance}
+
+	b := &backend.MockBackend{}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("GetActivityTask", mock.Anything).Return(at, nil).Once()
+	b.On("ExtendActivityTask", mock.Anything, "activity").Return(nil).Once()
+	b.On("CompleteActivityTask", mock.Anything, instance, "activity", mock.Anything).Return(nil).Twice()
+
+	cb := NewBackend(b, Faults{LockExpiryRate: 1, Seed: 1})
+
+	t1, err := cb.GetActivityTask(ctx)
+	require.NoError(t, err)
+	require.Equal(t, at, t1)
+
+	// The task is delivered again while the first delivery is still running
+	require.NoError(t, cb.ExtendActivityTask(ctx, "activity"))
+
+	t2, err := cb.GetActivityTask(ctx)
+	require.NoError(t, err)
+	require.Equal(t, at, t2)
+
+	// Backends like Redis accept both completions
+	event := history.NewPendingEvent(time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{})
+	require.NoError(t, cb.CompleteActivityTask(ctx, instance, "activity", event))
+	require.NoError(t, cb.CompleteActivityTask(ctx, instance, "activity", event))
+
+	b.AssertExpectations(t)
+}
+
+func Test_ChaosBackend_DuplicateWorkflowTask(t *testing.T) {
+	ctx := context.Background()
+	instance := core.NewWorkflowInstance("instance", "execution")
+	event := history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{})
+	wt := &task.Workflow{ID: "task", WorkflowInstance: instance, NewEvents: []history.Event{event}}
+
+	b := &backend.MockBackend{}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("GetWorkflowTask", mock.Anything).Return(wt, nil).Once()
+	b.On("CompleteWorkflowTask", mock.Anything, mock.Anything, instance, core.WorkflowInstanceStateActive,
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+
+	cb := NewBackend(b, Faults{DuplicateWorkflowTaskRate: 1, Seed: 1})
+
+	t1, err := cb.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.Equal(t, wt, t1)
+
+	// Changes workers make to the task are not delivered again
+	t1.NewEvents = t1.NewEvents[:0]
+
+	// The completion of the first delivery is dropped
+	require.NoError(t, cb.CompleteWorkflowTask(ctx, t1, instance, core.WorkflowInstanceStateActive, nil, nil, nil, nil))
+	b.AssertNotCalled(t, "CompleteWorkflowTask", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	// The duplicate is delivered without polling the wrapped backend, and its completion is stored
+	t2, err := cb.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.Equal(t, wt, t2)
+
+	require.NoError(t, cb.CompleteWorkflowTask(ctx, t2, instance, core.WorkflowInstanceStateActive, nil, nil, nil, nil))
+
+	b.AssertExpectations(t)
+}
+
+func Test_ChaosBackend_WorkflowTaskLockExpiry(t *testing.T) {
+	ctx := context.Background()
+	instance := core.NewWorkflowInstance("instance", "execution")
+	wt := &task.Workflow{ID: "task", WorkflowInstance: instance}
+
+	b := &backend.MockBackend{}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("GetWorkflowTask", mock.Anything).Return(wt, nil).Once()
+	b.On("ExtendWorkflowTask", mock.Anything, "task", instance).Return(nil).Once()
+
+	cb := NewBackend(b, Faults{LockExpiryRate: 1, Seed: 1})
+
+	t1, err := cb.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, cb.ExtendWorkflowTask(ctx, "task", instance))
+	require.NoError(t, cb.CompleteWorkflowTask(ctx, t1, instance, core.WorkflowInstanceStateActive, nil, nil, nil, nil))
+
+	t2, err := cb.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.Equal(t, wt, t2)
+
+	b.AssertExpectations(t)
+}
+
+func Test_ChaosBackend_LostActivityResult(t *testing.T) {
+	ctx := context.Background()
+	instance := core.NewWorkflowInstance("instance", "execution")
+
+	b := &backend.MockBackend{}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+
+	cb := NewBackend(b, Faults{LostActivityResultRate: 1, SkippedHeartbeatRate: 1, Seed: 1})
+
+	require.NoError(t, cb.ExtendActivityTask(ctx, "activity"))
+
+	event := history.NewPendingEvent(time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{})
+	require.NoError(t, cb.CompleteActivityTask(ctx, instance, "activity", event))
+
+	b.AssertNotCalled(t, "ExtendActivityTask", mock.Anything, mock.Anything)
+	b.AssertNotCalled(t, "CompleteActivityTask", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func Test_ChaosBackend_LatencyRespectsContext(t *testing.T) {
+	b := &backend.MockBackend{}
+
+	cb := NewBackend(b, Faults{Latency: time.Hour, Seed: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := cb.GetWorkflowTask(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func Test_ChaosBackend_DuplicateWorkflowTasks_EndToEnd(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewBackend(sqlite.NewInMemoryBackend(backend.WithStickyTimeout(0)), Faults{
+		DuplicateWorkflowTaskRate: 0.5,
+		DuplicateActivityRate:     0.5,
+		Seed:                      1,
+	})
+
+	a := func(ctx context.Context, i int) (int, error) {
+		return i, nil
+	}
+
+	wf := func(ctx workflow.Context) (int, error) {
+		sum := 0
+		for i := 1; i <= 5; i++ {
+			r, err := workflow.ExecuteActivity[int](ctx, workflow.DefaultActivityOptions, a, i).Get(ctx)
+			if err != nil {
+				return 0, err
+			}
+
+			sum += r
+		}
+
+		return sum, nil
+	}
+
+	w := worker.New(b, nil)
+	require.NoError(t, w.RegisterWorkflow(wf))
+	require.NoError(t, w.RegisterActivity(a))
+	require.NoError(t, w.Start(ctx))
+
+	c := client.New(b)
+
+	instance, err := c.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{InstanceID: "instance"}, wf)
+	require.NoError(t, err)
+
+	// Workers execute duplicated tasks again, but every activity result is only used once
+	r, err := client.GetWorkflowResult[int](ctx, c, instance, 10*time.Second)
+	require.NoError(t, err)
+	require.Equal(t, 15, r)
+
+	cancel()
+	require.NoError(t, w.WaitForCompletion())
+}
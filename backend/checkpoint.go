@@ -0,0 +1,21 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/task"
+)
+
+// WorkflowTaskCheckpointer is implemented by backends that can commit the progress of a workflow task without
+// completing it. Workers use it to commit large tasks in chunks, so that a crash only loses the progress of the
+// current chunk.
+type WorkflowTaskCheckpointer interface {
+	// CheckpointWorkflowTask commits the given events like CompleteWorkflowTask for an active instance, but keeps
+	// the instance locked and extends the lock of the task. Pending events of the task that have not been executed
+	// yet stay pending. The task is completed with CompleteWorkflowTask once all its events are executed.
+	CheckpointWorkflowTask(
+		ctx context.Context, task *task.Workflow, instance *core.WorkflowInstance,
+		executedEvents, activityEvents, timerEvents []history.Event, workflowEvents []history.WorkflowEvent) error
+}
@@ -0,0 +1,48 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	core "github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+)
+
+// CommittedEvent is a history event of a workflow execution, with the time it was committed to the history.
+type CommittedEvent struct {
+	Instance    *core.WorkflowInstance
+	CommittedAt time.Time
+	Event       *history.Event
+}
+
+// EventCursor is a position in the list of committed history events, ordered by commit time, instance ID,
+// execution ID, and sequence ID.
+type EventCursor struct {
+	CommittedAt time.Time `json:"committed_at"`
+	InstanceID  string    `json:"instance_id"`
+	ExecutionID string    `json:"execution_id"`
+	SequenceID  int64     `json:"sequence_id"`
+}
+
+// Cursor returns the position right after the event.
+func (e *CommittedEvent) Cursor() *EventCursor {
+	return &EventCursor{
+		CommittedAt: e.CommittedAt,
+		InstanceID:  e.Instance.InstanceID,
+		ExecutionID: e.Instance.ExecutionID,
+		SequenceID:  e.Event.SequenceID,
+	}
+}
+
+// CommittedEventLister is implemented by backends that can list the history events of all workflow instances in
+// the order they were committed, e.g., to process them incrementally while instances are still running.
+type CommittedEventLister interface {
+	// GetCommittedEvents returns up to count history events committed after the given cursor, or from the start if
+	// it's nil, and before committedBefore, ordered by commit time, instance ID, execution ID, and sequence ID.
+	// Events of running instances and of executions an instance continued from are returned as well. Events of
+	// removed instances are skipped.
+	GetCommittedEvents(ctx context.Context, after *EventCursor, committedBefore time.Time, count int) ([]*CommittedEvent, error)
+}
+
+var ErrCommittedEventsNotSupported = errors.New("backend does not support listing committed history events")
@@ -0,0 +1,19 @@
+package backend
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+// HistoryCompactor is implemented by backends that can compact the history of long-lived instances that can't
+// easily continue as new.
+type HistoryCompactor interface {
+	// CompactWorkflowInstanceHistory replaces every resolved activity and timer up to the event with the given
+	// sequence id with a single summary event holding its result, see history.Compact. It returns the number of
+	// compacted requests, and ErrInstanceNotFound if the instance doesn't exist.
+	CompactWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, checkpoint int64) (int, error)
+}
+
+var ErrCompactionNotSupported = errors.New("backend does not support compacting workflow instance history")
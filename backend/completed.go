@@ -0,0 +1,45 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	core "github.com/cschleiden/go-workflows/internal/core"
+)
+
+// CompletedInstance is a finished workflow instance.
+type CompletedInstance struct {
+	Instance    *core.WorkflowInstance
+	CompletedAt time.Time
+}
+
+// CompletionCursor is a position in the list of finished workflow instances, ordered by completion time, instance
+// ID, and execution ID.
+type CompletionCursor struct {
+	CompletedAt time.Time `json:"completed_at"`
+	InstanceID  string    `json:"instance_id"`
+	ExecutionID string    `json:"execution_id,omitempty"`
+}
+
+// Cursor returns the position right after the instance.
+func (i *CompletedInstance) Cursor() *CompletionCursor {
+	return &CompletionCursor{
+		CompletedAt: i.CompletedAt,
+		InstanceID:  i.Instance.InstanceID,
+		ExecutionID: i.Instance.ExecutionID,
+	}
+}
+
+// CompletedInstanceLister is implemented by backends that can list finished workflow instances in the order they
+// completed, e.g., to process their histories incrementally.
+type CompletedInstanceLister interface {
+	// GetCompletedWorkflowInstances returns up to count instances that completed after the given cursor, or from
+	// the start if it's nil, and before completedBefore, ordered by completion time, instance ID, and execution
+	// ID. Executions an instance continued from are returned as well, with the time they completed, see
+	// ExecutionHistoryReader. Removed instances are skipped.
+	GetCompletedWorkflowInstances(
+		ctx context.Context, after *CompletionCursor, completedBefore time.Time, count int) ([]*CompletedInstance, error)
+}
+
+var ErrCompletedInstancesNotSupported = errors.New("backend does not support listing completed workflow instances")
@@ -0,0 +1,28 @@
+package backend
+
+import "errors"
+
+// PermanentError marks an error returned by a backend that won't go away if the operation is retried,
+// for example a constraint violation. Errors not marked as permanent are considered transient by the
+// client, unless they are one of the well-known errors like ErrInstanceNotFound.
+type PermanentError struct {
+	Err error
+}
+
+func NewPermanentError(err error) error {
+	return &PermanentError{Err: err}
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanentError returns true if the given error or any error it wraps is a PermanentError.
+func IsPermanentError(err error) bool {
+	var pe *PermanentError
+	return errors.As(err, &pe)
+}
@@ -0,0 +1,28 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+// ExecutionHistoryReader is implemented by backends that keep the histories of the executions an instance
+// continued from, see workflow.ContinueAsNew. Every finished execution is also listed by
+// CompletedInstanceLister, with its own completion time.
+type ExecutionHistoryReader interface {
+	// GetWorkflowExecutionHistory returns the history of the given execution, which can be the current execution
+	// of its instance or one it continued from. Returns ErrInstanceNotFound if the execution doesn't exist, or
+	// its instance has been removed.
+	GetWorkflowExecutionHistory(ctx context.Context, instance *workflow.Instance) ([]history.Event, error)
+}
+
+// GetWorkflowExecutionHistory returns the history of the given execution. If the backend doesn't implement
+// ExecutionHistoryReader, the history of the current execution of the instance is returned.
+func GetWorkflowExecutionHistory(ctx context.Context, b Backend, instance *workflow.Instance) ([]history.Event, error) {
+	if r, ok := b.(ExecutionHistoryReader); ok {
+		return r.GetWorkflowExecutionHistory(ctx, instance)
+	}
+
+	return b.GetWorkflowInstanceHistory(ctx, instance, nil)
+}
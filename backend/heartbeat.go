@@ -0,0 +1,29 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+// ActivityHeartbeatRecorder is implemented by backends that can store the details of activity heartbeats, see
+// activity.RecordHeartbeat. Stored details are returned with the activity task if it's delivered again, e.g.,
+// after the worker executing it crashed, so the activity can resume from its last checkpoint.
+type ActivityHeartbeatRecorder interface {
+	// RecordActivityHeartbeat extends the lock of the given activity task like ExtendActivityTask, and stores the
+	// given heartbeat details with the task.
+	RecordActivityHeartbeat(ctx context.Context, activityID string, details payload.Payload) error
+}
+
+// ActivityHeartbeatTimeoutRescheduler is implemented by backends that can move the heartbeat timeout of an activity,
+// see workflow.ActivityOptions.HeartbeatTimeout. The executor schedules the timeout as a future event when the
+// activity is started, and the worker executing the activity moves it with every heartbeat. If the worker goes
+// away, the timeout fires and the workflow retries the activity.
+type ActivityHeartbeatTimeoutRescheduler interface {
+	// RescheduleActivityHeartbeatTimeout replaces the heartbeat timeout event with the ID of the given event, see
+	// history.NewActivityHeartbeatTimeoutEvent, if it's still scheduled for the future. Otherwise, e.g., if the
+	// executor has not scheduled it yet, or if it already fired, the call does nothing.
+	RescheduleActivityHeartbeatTimeout(ctx context.Context, instance *core.WorkflowInstance, event *history.Event) error
+}
@@ -0,0 +1,105 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+// HistoryFilter restricts the events returned by GetFilteredWorkflowInstanceHistory. The zero value
+// returns the full history.
+type HistoryFilter struct {
+	// EventTypes, if set, limits the result to events of these types.
+	EventTypes []history.EventType
+
+	// FromSequenceID and ToSequenceID limit the result to events with a sequence id in the given inclusive
+	// range. 0 means the range is open on that side.
+	FromSequenceID int64
+	ToSequenceID   int64
+
+	// ExcludePayloads strips inputs, results, and other payloads from the returned events.
+	ExcludePayloads bool
+}
+
+// Matches returns true if the event satisfies the event type and sequence id restrictions of the filter.
+func (f *HistoryFilter) Matches(e history.Event) bool {
+	if f.FromSequenceID > 0 && e.SequenceID < f.FromSequenceID {
+		return false
+	}
+
+	if f.ToSequenceID > 0 && e.SequenceID > f.ToSequenceID {
+		return false
+	}
+
+	if len(f.EventTypes) == 0 {
+		return true
+	}
+
+	for _, t := range f.EventTypes {
+		if e.Type == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Apply returns the events matching the filter, with their payloads stripped if requested.
+func (f *HistoryFilter) Apply(events []history.Event) []history.Event {
+	r := make([]history.Event, 0, len(events))
+	for _, e := range events {
+		if !f.Matches(e) {
+			continue
+		}
+
+		r = append(r, f.StripPayloads(e))
+	}
+
+	return r
+}
+
+// StripPayloads removes all payloads from the event when ExcludePayloads is set.
+func (f *HistoryFilter) StripPayloads(e history.Event) history.Event {
+	if !f.ExcludePayloads {
+		return e
+	}
+
+	return history.RedactPayloads(e, func(payload.Payload) payload.Payload {
+		return nil
+	})
+}
+
+// HistoryFilterProvider is implemented by backends that can apply a HistoryFilter while reading the
+// history, avoiding loading events that are not needed. Payloads are returned without applying the Redactor, use
+// GetFilteredWorkflowInstanceHistory to read redacted histories.
+type HistoryFilterProvider interface {
+	GetFilteredWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, filter HistoryFilter) ([]history.Event, error)
+}
+
+// GetFilteredWorkflowInstanceHistory returns the history of the given instance restricted by filter, with the
+// Redactor of the backend applied to its payloads. If the backend doesn't implement HistoryFilterProvider, the full
+// history is read and filtered in memory.
+func GetFilteredWorkflowInstanceHistory(ctx context.Context, b Backend, instance *workflow.Instance, filter HistoryFilter) ([]history.Event, error) {
+	var h []history.Event
+	var err error
+
+	if p, ok := b.(HistoryFilterProvider); ok {
+		h, err = p.GetFilteredWorkflowInstanceHistory(ctx, instance, filter)
+	} else {
+		var lastSequenceID *int64
+		if filter.FromSequenceID > 0 {
+			s := filter.FromSequenceID - 1
+			lastSequenceID = &s
+		}
+
+		h, err = b.GetWorkflowInstanceHistory(ctx, instance, lastSequenceID)
+		h = filter.Apply(h)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return RedactorOf(b).RedactEvents(h), nil
+}
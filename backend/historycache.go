@@ -0,0 +1,29 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+)
+
+// HistoryCache caches the histories of actively executing workflow instances in front of the database of a
+// backend, see WithHistoryCache. This reduces the load on the database when a workflow task is not executed by
+// the worker holding the instance in its cache, and the full history has to be read.
+//
+// Reading from the database and caching the result is not atomic, so Get returns a token that identifies the
+// version of the cached history. Put only caches a history if the history hasn't been changed since.
+type HistoryCache interface {
+	// Get returns the cached events of the given instance with a sequence id greater than afterSequenceID, and
+	// true if the history of the instance is cached. If it's not, pass the returned token to Put.
+	Get(ctx context.Context, instanceID string, afterSequenceID int64) (events []history.Event, token int64, ok bool, err error)
+
+	// Put caches the full history of the given instance, unless it was changed after Get returned the token.
+	Put(ctx context.Context, instanceID string, events []history.Event, token int64) error
+
+	// Append adds events appended to the history of the given instance. If the cached history doesn't end right
+	// before the appended events, it's removed from the cache.
+	Append(ctx context.Context, instanceID string, events []history.Event) error
+
+	// Invalidate removes the history of the given instance from the cache.
+	Invalidate(ctx context.Context, instanceID string) error
+}
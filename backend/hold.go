@@ -0,0 +1,39 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+// Hold is a legal or compliance hold on a workflow instance. Held instances are exempt from retention: they can't
+// be removed or archived, and removed instances placed on hold are not purged, until the hold is released.
+type Hold struct {
+	Reason   string    `json:"reason"`
+	PlacedAt time.Time `json:"placed_at"`
+}
+
+// InstanceHolder is implemented by backends that can place holds on workflow instances.
+type InstanceHolder interface {
+	// PlaceWorkflowInstanceHold places a hold with the given reason on the given instance, replacing an existing
+	// hold. Removed instances that haven't been purged yet can be held as well. Returns ErrInstanceNotFound if the
+	// instance doesn't exist.
+	PlaceWorkflowInstanceHold(ctx context.Context, instance *workflow.Instance, reason string) error
+
+	// ReleaseWorkflowInstanceHold releases the hold on the given instance. Returns ErrInstanceNotHeld if the
+	// instance isn't held.
+	ReleaseWorkflowInstanceHold(ctx context.Context, instance *workflow.Instance) error
+
+	// GetWorkflowInstanceHold returns the hold on the given instance, or nil if it isn't held. Returns
+	// ErrInstanceNotFound if the instance doesn't exist.
+	GetWorkflowInstanceHold(ctx context.Context, instance *workflow.Instance) (*Hold, error)
+}
+
+var ErrHoldNotSupported = errors.New("backend does not support holds on workflow instances")
+
+// ErrInstanceHeld is returned when removing or archiving a workflow instance that is on hold.
+var ErrInstanceHeld = errors.New("workflow instance is on hold")
+
+var ErrInstanceNotHeld = errors.New("workflow instance is not on hold")
@@ -0,0 +1,117 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Leaser is implemented by backends that can store leases shared by all workers using the same storage. Leases
+// are used to elect a single leader for background jobs, see RunAsLeader.
+type Leaser interface {
+	// AcquireLease acquires the named lease for owner until ttl has passed, or extends it if owner already holds
+	// it. It returns false if another owner holds a lease that has not expired yet.
+	AcquireLease(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+
+	// ReleaseLease releases the named lease if it's held by owner.
+	ReleaseLease(ctx context.Context, name, owner string) error
+}
+
+var ErrLeasesNotSupported = errors.New("backend does not support leases")
+
+// RunAsLeader campaigns for the named lease until the given context is canceled, and runs f while holding it.
+// Across all workers sharing the backend's storage, at most one f runs for a given name at a time, e.g., for
+// retention or other maintenance jobs.
+//
+// The lease is renewed every third of ttl. The context passed to f is canceled when the lease could not be
+// renewed, and f is expected to return promptly then. If f returns while the lease is still held, the lease is
+// released and campaigning continues.
+func RunAsLeader(ctx context.Context, b Backend, name string, ttl time.Duration, f func(ctx context.Context)) error {
+	l, ok := b.(Leaser)
+	if !ok {
+		return ErrLeasesNotSupported
+	}
+
+	owner := uuid.NewString()
+
+	t := time.NewTicker(ttl / 3)
+	defer t.Stop()
+
+	var cancel context.CancelFunc
+	var done chan struct{}
+
+	stepDown := func() {
+		if cancel != nil {
+			cancel()
+			<-done
+
+			cancel = nil
+			done = nil
+		}
+	}
+
+	// Release the lease on the way out, so that another worker can take over without waiting for it to expire.
+	defer func() {
+		stepDown()
+
+		releaseCtx, releaseCancel := context.WithTimeout(context.Background(), ttl)
+		defer releaseCancel()
+
+		if err := l.ReleaseLease(releaseCtx, name, owner); err != nil {
+			b.Logger().Error("releasing lease", "name", name, "error", err)
+		}
+	}()
+
+	for {
+		acquired, err := l.AcquireLease(ctx, name, owner, ttl)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			b.Logger().Error("acquiring lease", "name", name, "error", err)
+		}
+
+		if acquired && cancel == nil {
+			b.Logger().Debug("acquired lease", "name", name)
+
+			leaderCtx, leaderCancel := context.WithCancel(ctx)
+			cancel = leaderCancel
+			done = make(chan struct{})
+
+			go func(done chan struct{}) {
+				defer close(done)
+
+				f(leaderCtx)
+			}(done)
+		} else if !acquired && cancel != nil {
+			// We cannot be sure we still hold the lease, stop before another worker takes over
+			b.Logger().Debug("lost lease", "name", name)
+
+			stepDown()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-done:
+			// f returned on its own, give up the lease
+			stepDown()
+
+			if err := l.ReleaseLease(ctx, name, owner); err != nil && ctx.Err() == nil {
+				b.Logger().Error("releasing lease", "name", name, "error", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-t.C:
+			}
+
+		case <-t.C:
+		}
+	}
+}
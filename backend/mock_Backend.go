@@ -80,6 +80,20 @@ func (_m *MockBackend) CreateWorkflowInstance(ctx context.Context, instance *cor
 	return r0
 }
 
+// CreateWorkflowInstances provides a mock function with given fields: ctx, instances
+func (_m *MockBackend) CreateWorkflowInstances(ctx context.Context, instances []history.WorkflowEvent) error {
+	ret := _m.Called(ctx, instances)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []history.WorkflowEvent) error); ok {
+		r0 = rf(ctx, instances)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // ExtendActivityTask provides a mock function with given fields: ctx, activityID
 func (_m *MockBackend) ExtendActivityTask(ctx context.Context, activityID string) error {
 	ret := _m.Called(ctx, activityID)
@@ -0,0 +1,88 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"github.com/cschleiden/go-workflows/log"
+)
+
+// databaseClockRefreshInterval is how often the offset between the local and the database clock is measured.
+const databaseClockRefreshInterval = time.Minute
+
+// databaseClock returns the time of the database server. It measures the offset to the local clock
+// periodically instead of querying the database for every call. Measurements after the first one are taken
+// in the background, so Now never waits for the database.
+type databaseClock struct {
+	db     *sql.DB
+	local  func() time.Time
+	logger log.Logger
+
+	// offset is the last measured offset of the database clock in nanoseconds
+	offset int64
+
+	// refreshedAt is the local time of the last measurement in unix nanoseconds, zero if there wasn't one
+	refreshedAt int64
+
+	// refreshing is 1 while a measurement is in progress
+	refreshing int32
+}
+
+func newDatabaseClock(db *sql.DB, local func() time.Time, logger log.Logger) *databaseClock {
+	return &databaseClock{
+		db:     db,
+		local:  local,
+		logger: logger,
+	}
+}
+
+func (c *databaseClock) Now() time.Time {
+	now := c.local()
+
+	refreshedAt := atomic.LoadInt64(&c.refreshedAt)
+	if refreshedAt == 0 {
+		// Without any measurement, the local clock could be arbitrarily off. Measure once before returning.
+		c.tryRefresh()
+		now = c.local()
+	} else if now.Sub(time.Unix(0, refreshedAt)) > databaseClockRefreshInterval {
+		go c.tryRefresh()
+	}
+
+	return now.Add(time.Duration(atomic.LoadInt64(&c.offset)))
+}
+
+// tryRefresh measures the offset unless another measurement is already in progress.
+func (c *databaseClock) tryRefresh() {
+	if !atomic.CompareAndSwapInt32(&c.refreshing, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&c.refreshing, 0)
+
+	if err := c.refresh(); err != nil {
+		// Keep using the last known offset
+		c.logger.Error("measuring database clock offset", "error", err)
+	}
+}
+
+func (c *databaseClock) refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	before := c.local()
+
+	var dbNow time.Time
+	if err := c.db.QueryRowContext(ctx, "SELECT UTC_TIMESTAMP(6)").Scan(&dbNow); err != nil {
+		atomic.StoreInt64(&c.refreshedAt, before.UnixNano())
+		return err
+	}
+
+	after := c.local()
+
+	// Assume the database read its clock halfway through the round trip
+	atomic.StoreInt64(&c.offset, int64(dbNow.Sub(before.Add(after.Sub(before)/2))))
+	atomic.StoreInt64(&c.refreshedAt, after.UnixNano())
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+)
+
+var _ backend.CommittedEventLister = (*mysqlBackend)(nil)
+
+func (mb *mysqlBackend) GetCommittedEvents(
+	ctx context.Context, after *backend.EventCursor, committedBefore time.Time, count int,
+) ([]*backend.CommittedEvent, error) {
+	// Events of the executions the instances continued from are listed together with the current ones
+	query := `SELECT * FROM (
+			SELECT h.committed_at, h.instance_id, i.execution_id, i.parent_instance_id, i.parent_schedule_event_id,
+				h.event_id, h.sequence_id, h.event_type, h.timestamp, h.schedule_event_id, h.attributes, h.visible_at, h.caused_by
+			FROM history h
+			INNER JOIN instances i ON i.instance_id = h.instance_id
+			WHERE i.removed_at IS NULL
+			UNION ALL
+			SELECT eh.committed_at, eh.instance_id, eh.execution_id, e.parent_instance_id, e.parent_schedule_event_id,
+				eh.event_id, eh.sequence_id, eh.event_type, eh.timestamp, eh.schedule_event_id, eh.attributes, eh.visible_at, eh.caused_by
+			FROM execution_history eh
+			INNER JOIN executions e ON e.instance_id = eh.instance_id AND e.execution_id = eh.execution_id
+			INNER JOIN instances i ON i.instance_id = eh.instance_id
+			WHERE i.removed_at IS NULL
+		) c
+		WHERE committed_at < ?`
+	args := []interface{}{committedBefore}
+
+	if after != nil {
+		query += ` AND (committed_at > ? OR (committed_at = ? AND (instance_id > ? OR (instance_id = ? AND (execution_id > ? OR (execution_id = ? AND sequence_id > ?))))))`
+		args = append(args,
+			after.CommittedAt, after.CommittedAt, after.InstanceID, after.InstanceID, after.ExecutionID, after.ExecutionID, after.SequenceID)
+	}
+
+	query += ` ORDER BY committed_at, instance_id, execution_id, sequence_id LIMIT ?`
+	args = append(args, count)
+
+	rows, err := mb.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting committed events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]*backend.CommittedEvent, 0)
+	for rows.Next() {
+		var committedAt time.Time
+		var instanceID, executionID string
+		var parentInstanceID *string
+		var parentEventID *int64
+		var attributes []byte
+		var causedBy sql.NullString
+
+		event := &history.Event{}
+
+		if err := rows.Scan(
+			&committedAt, &instanceID, &executionID, &parentInstanceID, &parentEventID,
+			&event.ID, &event.SequenceID, &event.Type, &event.Timestamp, &event.ScheduleEventID, &attributes, &event.VisibleAt, &causedBy,
+		); err != nil {
+			return nil, fmt.Errorf("scanning committed event: %w", err)
+		}
+
+		event.CausedBy = causedBy.String
+		event.SetSerializedAttributes(attributes)
+
+		instance := core.NewWorkflowInstance(instanceID, executionID)
+		if parentInstanceID != nil && parentEventID != nil {
+			instance = core.NewSubWorkflowInstance(instanceID, executionID, *parentInstanceID, *parentEventID)
+		}
+
+		events = append(events, &backend.CommittedEvent{
+			Instance:    instance,
+			CommittedAt: committedAt,
+			Event:       event,
+		})
+	}
+
+	return events, rows.Err()
+}
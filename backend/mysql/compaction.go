@@ -0,0 +1,95 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/historycache"
+	"github.com/cschleiden/go-workflows/internal/sqlinstr"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+var _ backend.HistoryCompactor = (*mysqlBackend)(nil)
+
+func (b *mysqlBackend) CompactWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, checkpoint int64) (int, error) {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id string
+	if err := tx.QueryRowContext(
+		ctx,
+		"SELECT instance_id FROM instances WHERE instance_id = ? AND removed_at IS NULL",
+		instance.InstanceID,
+	).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, backend.ErrInstanceNotFound
+		}
+
+		return 0, fmt.Errorf("getting workflow instance: %w", err)
+	}
+
+	rows, err := tx.QueryContext(
+		ctx,
+		"SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at, caused_by FROM `history` WHERE instance_id = ? AND sequence_id <= ? ORDER BY sequence_id",
+		instance.InstanceID,
+		checkpoint,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("getting history: %w", err)
+	}
+
+	h, err := scanHistoryEvents(rows)
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	summaries, removed, err := history.Compact(h, checkpoint)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(summaries) == 0 {
+		return 0, nil
+	}
+
+	for _, sequenceID := range removed {
+		if _, err := tx.ExecContext(
+			ctx, "DELETE FROM `history` WHERE instance_id = ? AND sequence_id = ?", instance.InstanceID, sequenceID,
+		); err != nil {
+			return 0, fmt.Errorf("removing compacted event: %w", err)
+		}
+	}
+
+	for _, summary := range summaries {
+		attributes, err := summary.SerializedAttributes()
+		if err != nil {
+			return 0, fmt.Errorf("serializing attributes: %w", err)
+		}
+
+		if _, err := tx.ExecContext(
+			ctx,
+			"UPDATE `history` SET event_type = ?, attributes = ? WHERE instance_id = ? AND sequence_id = ?",
+			summary.Type, attributes, instance.InstanceID, summary.SequenceID,
+		); err != nil {
+			return 0, fmt.Errorf("updating compacted event: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	historycache.Invalidate(ctx, b.options.HistoryCache, b.Logger(), instance.InstanceID)
+
+	return len(summaries), nil
+}
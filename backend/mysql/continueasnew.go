@@ -0,0 +1,145 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+// continueAsNew replaces the current execution of the instance with the given new execution. The history of the
+// current execution is moved to the execution history, and a completion record is kept for it in the executions
+// table. Its pending events are removed, except for signals, which are delivered to the new execution. Pending
+// cancellations are removed as well and returned, the caller delivers them to the new execution after its start
+// event. Activities of the current execution that have not been picked up by a worker are removed, results of
+// running ones are dropped when they complete.
+func continueAsNew(
+	ctx context.Context, tx *sql.Tx, instance, newInstance *workflow.Instance, metadata *workflow.Metadata, completedAt time.Time,
+) ([]history.Event, error) {
+	metadataJson, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling metadata: %w", err)
+	}
+
+	// Executions continued from an earlier one start when that one completed
+	var createdAt time.Time
+	if err := tx.QueryRowContext(
+		ctx,
+		`SELECT COALESCE((SELECT MAX(completed_at) FROM executions WHERE instance_id = ?), created_at)
+		FROM instances WHERE instance_id = ?`,
+		instance.InstanceID,
+		instance.InstanceID,
+	).Scan(&createdAt); err != nil {
+		return nil, fmt.Errorf("getting start of continued execution: %w", err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO executions (instance_id, execution_id, parent_instance_id, parent_schedule_event_id, metadata, created_at, completed_at)
+		SELECT instance_id, execution_id, parent_instance_id, parent_schedule_event_id, metadata, ?, ?
+		FROM instances WHERE instance_id = ? AND execution_id = ?`,
+		createdAt,
+		completedAt,
+		instance.InstanceID,
+		instance.ExecutionID,
+	); err != nil {
+		return nil, fmt.Errorf("recording completed execution: %w", err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO execution_history
+			(event_id, sequence_id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at, caused_by, committed_at)
+		SELECT event_id, sequence_id, instance_id, ?, event_type, timestamp, schedule_event_id, attributes, visible_at, caused_by, committed_at
+		FROM history WHERE instance_id = ? ORDER BY id`,
+		instance.ExecutionID,
+		instance.InstanceID,
+	); err != nil {
+		return nil, fmt.Errorf("keeping history of continued workflow instance: %w", err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"UPDATE `instances` SET execution_id = ?, metadata = ?, completed_at = NULL WHERE instance_id = ? AND execution_id = ?",
+		newInstance.ExecutionID,
+		string(metadataJson),
+		instance.InstanceID,
+		instance.ExecutionID,
+	); err != nil {
+		return nil, fmt.Errorf("continuing workflow instance as new: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM `history` WHERE instance_id = ?", instance.InstanceID); err != nil {
+		return nil, fmt.Errorf("removing history of continued workflow instance: %w", err)
+	}
+
+	canceled, err := getPendingCancellations(ctx, tx, instance.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"DELETE FROM `pending_events` WHERE instance_id = ? AND event_type != ?",
+		instance.InstanceID,
+		history.EventType_SignalReceived,
+	); err != nil {
+		return nil, fmt.Errorf("removing pending events of continued workflow instance: %w", err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"DELETE FROM `activities` WHERE instance_id = ? AND execution_id = ? AND locked_until IS NULL",
+		instance.InstanceID,
+		instance.ExecutionID,
+	); err != nil {
+		return nil, fmt.Errorf("removing activities of continued workflow instance: %w", err)
+	}
+
+	return canceled, nil
+}
+
+// getPendingCancellations returns the cancellation requests of the instance that have not been executed yet.
+func getPendingCancellations(ctx context.Context, tx *sql.Tx, instanceID string) ([]history.Event, error) {
+	rows, err := tx.QueryContext(
+		ctx,
+		"SELECT event_id, sequence_id, event_type, timestamp, schedule_event_id, attributes, visible_at, caused_by FROM `pending_events` WHERE instance_id = ? AND event_type = ? AND visible_at IS NULL ORDER BY id",
+		instanceID,
+		history.EventType_WorkflowExecutionCanceled,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting pending cancellations: %w", err)
+	}
+	defer rows.Close()
+
+	var events []history.Event
+	for rows.Next() {
+		var attributes []byte
+		var causedBy sql.NullString
+
+		event := history.Event{}
+		if err := rows.Scan(
+			&event.ID,
+			&event.SequenceID,
+			&event.Type,
+			&event.Timestamp,
+			&event.ScheduleEventID,
+			&attributes,
+			&event.VisibleAt,
+			&causedBy,
+		); err != nil {
+			return nil, fmt.Errorf("scanning event: %w", err)
+		}
+
+		event.CausedBy = causedBy.String
+		event.SetSerializedAttributes(attributes)
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -27,6 +27,7 @@ func (mb *mysqlBackend) GetWorkflowInstances(ctx context.Context, afterInstanceI
 			FROM instances i
 			INNER JOIN (SELECT instance_id, created_at FROM instances WHERE id = ?) ii
 				ON i.created_at < ii.created_at OR (i.created_at = ii.created_at AND i.instance_id < ii.instance_id)
+			WHERE i.removed_at IS NULL
 			ORDER BY i.created_at DESC, i.instance_id DESC
 			LIMIT ?`,
 			afterInstanceID,
@@ -37,6 +38,7 @@ func (mb *mysqlBackend) GetWorkflowInstances(ctx context.Context, afterInstanceI
 			ctx,
 			`SELECT i.instance_id, i.execution_id, i.created_at, i.completed_at
 			FROM instances i
+			WHERE i.removed_at IS NULL
 			ORDER BY i.created_at DESC, i.instance_id DESC
 			LIMIT ?`,
 			count,
@@ -80,7 +82,7 @@ func (mb *mysqlBackend) GetWorkflowInstance(ctx context.Context, instanceID stri
 	}
 	defer tx.Rollback()
 
-	res := tx.QueryRowContext(ctx, "SELECT instance_id, execution_id, created_at, completed_at FROM instances WHERE instance_id = ?", instanceID)
+	res := tx.QueryRowContext(ctx, "SELECT instance_id, execution_id, created_at, completed_at FROM instances WHERE instance_id = ? AND removed_at IS NULL", instanceID)
 
 	var id, executionID string
 	var createdAt time.Time
@@ -3,40 +3,130 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/cschleiden/go-workflows/internal/history"
 )
 
+// insertPendingEvents adds the given events to the pending events of the instance. Events are deduplicated by
+// their ID, so events that are delivered more than once, e.g., by retried requests, are only added to the
+// history once.
 func insertPendingEvents(ctx context.Context, tx *sql.Tx, instanceID string, newEvents []history.Event) error {
-	return insertEvents(ctx, tx, "pending_events", instanceID, newEvents)
+	newEvents, err := withoutExecutedEvents(ctx, tx, instanceID, newEvents)
+	if err != nil {
+		return err
+	}
+
+	return insertEvents(ctx, tx, "pending_events", instanceID, newEvents, nil, " ON DUPLICATE KEY UPDATE event_id = event_id")
+}
+
+// insertHistoryEvents adds the given events to the history of the instance, committedAt is the backend time of the
+// transaction adding them, see backend.CommittedEventLister.
+func insertHistoryEvents(ctx context.Context, tx *sql.Tx, instanceID string, historyEvents []history.Event, committedAt time.Time) error {
+	return insertEvents(ctx, tx, "history", instanceID, historyEvents, &committedAt, "")
 }
 
-func insertHistoryEvents(ctx context.Context, tx *sql.Tx, instanceID string, historyEvents []history.Event) error {
-	return insertEvents(ctx, tx, "history", instanceID, historyEvents)
+// getLastSequenceID returns the sequence ID of the last event in the history of the instance, or 0 if the history
+// is empty.
+func getLastSequenceID(ctx context.Context, tx *sql.Tx, instanceID string) (int64, error) {
+	var sequenceID int64
+	row := tx.QueryRowContext(ctx, "SELECT sequence_id FROM `history` WHERE instance_id = ? ORDER BY id DESC LIMIT 1", instanceID)
+	if err := row.Scan(&sequenceID); err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("getting most recent sequence id: %w", err)
+	}
+
+	return sequenceID, nil
+}
+
+// withoutExecutedEvents removes events that are already in the history of the given instance.
+func withoutExecutedEvents(ctx context.Context, tx *sql.Tx, instanceID string, events []history.Event) ([]history.Event, error) {
+	if len(events) == 0 {
+		return events, nil
+	}
+
+	args := make([]interface{}, 0, len(events)+1)
+	args = append(args, instanceID)
+	for _, e := range events {
+		args = append(args, e.ID)
+	}
+
+	rows, err := tx.QueryContext(
+		ctx,
+		"SELECT event_id FROM `history` WHERE instance_id = ? AND event_id IN (?"+strings.Repeat(", ?", len(events)-1)+")",
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("checking for executed events: %w", err)
+	}
+	defer rows.Close()
+
+	executed := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scanning event id: %w", err)
+		}
+
+		executed[id] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(executed) == 0 {
+		return events, nil
+	}
+
+	r := make([]history.Event, 0, len(events)-len(executed))
+	for _, e := range events {
+		if !executed[e.ID] {
+			r = append(r, e)
+		}
+	}
+
+	return r, nil
 }
 
-func insertEvents(ctx context.Context, tx *sql.Tx, tableName string, instanceID string, events []history.Event) error {
-	const batchSize = 20
-	for batchStart := 0; batchStart < len(events); batchStart += batchSize {
-		batchEnd := batchStart + batchSize
+// insertBatchSize is the maximum number of rows inserted with a single statement.
+const insertBatchSize = 100
+
+// insertEvents inserts the given events into the given table. If committedAt is set, it's stored in the
+// committed_at column of every row. onConflict is appended to every insert statement.
+func insertEvents(
+	ctx context.Context, tx *sql.Tx, tableName string, instanceID string, events []history.Event, committedAt *time.Time, onConflict string,
+) error {
+	columns := "event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at, caused_by"
+	placeholders := "(?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	if committedAt != nil {
+		columns += ", committed_at"
+		placeholders = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	}
+
+	for batchStart := 0; batchStart < len(events); batchStart += insertBatchSize {
+		batchEnd := batchStart + insertBatchSize
 		if batchEnd > len(events) {
 			batchEnd = len(events)
 		}
 		batchEvents := events[batchStart:batchEnd]
 
-		query := "INSERT INTO `" + tableName + "` (event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)" +
-			strings.Repeat(", (?, ?, ?, ?, ?, ?, ?, ?)", len(batchEvents)-1)
+		query := "INSERT INTO `" + tableName + "` (" + columns + ") VALUES " + placeholders +
+			strings.Repeat(", "+placeholders, len(batchEvents)-1) + onConflict
 
-		args := make([]interface{}, 0, len(batchEvents)*7)
+		args := make([]interface{}, 0, len(batchEvents)*10)
 
 		for _, newEvent := range batchEvents {
-			a, err := history.SerializeAttributes(newEvent.Attributes)
+			a, err := newEvent.SerializedAttributes()
 			if err != nil {
 				return err
 			}
 
-			args = append(args, newEvent.ID, newEvent.SequenceID, instanceID, newEvent.Type, newEvent.Timestamp, newEvent.ScheduleEventID, a, newEvent.VisibleAt)
+			args = append(args, newEvent.ID, newEvent.SequenceID, instanceID, newEvent.Type, newEvent.Timestamp, newEvent.ScheduleEventID, a, newEvent.VisibleAt, newEvent.CausedBy)
+			if committedAt != nil {
+				args = append(args, *committedAt)
+			}
 		}
 
 		_, err := tx.ExecContext(
@@ -52,12 +142,32 @@ func insertEvents(ctx context.Context, tx *sql.Tx, tableName string, instanceID
 	return nil
 }
 
-func removeFutureEvent(ctx context.Context, tx *sql.Tx, instanceID string, scheduleEventID int64) error {
+// removeFutureEventsOf removes the events scheduled for the future with the given schedule event ids.
+func removeFutureEventsOf(ctx context.Context, tx *sql.Tx, instanceID string, scheduleEventIDs []int64) error {
+	if len(scheduleEventIDs) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(scheduleEventIDs)+1)
+	args = append(args, instanceID)
+	for _, id := range scheduleEventIDs {
+		args = append(args, id)
+	}
+
+	_, err := tx.ExecContext(
+		ctx,
+		"DELETE FROM `pending_events` WHERE instance_id = ? AND schedule_event_id IN (?"+strings.Repeat(", ?", len(scheduleEventIDs)-1)+") AND visible_at IS NOT NULL",
+		args...,
+	)
+
+	return err
+}
+
+func removeFutureEvents(ctx context.Context, tx *sql.Tx, instanceID string) error {
 	_, err := tx.ExecContext(
 		ctx,
-		"DELETE FROM `pending_events` WHERE instance_id = ? AND schedule_event_id = ? AND visible_at IS NOT NULL",
+		"DELETE FROM `pending_events` WHERE instance_id = ? AND visible_at IS NOT NULL",
 		instanceID,
-		scheduleEventID,
 	)
 
 	return err
@@ -0,0 +1,71 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/sqlinstr"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+var _ backend.ExecutionHistoryReader = (*mysqlBackend)(nil)
+
+func (b *mysqlBackend) GetWorkflowExecutionHistory(ctx context.Context, instance *workflow.Instance) ([]history.Event, error) {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var executionID string
+	var removed bool
+	if err := tx.QueryRowContext(
+		ctx, "SELECT execution_id, removed_at IS NOT NULL FROM instances WHERE instance_id = ?", instance.InstanceID,
+	).Scan(&executionID, &removed); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, backend.ErrInstanceNotFound
+		}
+
+		return nil, fmt.Errorf("getting workflow instance: %w", err)
+	}
+
+	if removed {
+		return nil, backend.ErrInstanceNotFound
+	}
+
+	query := "SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at, caused_by FROM `history` WHERE instance_id = ? ORDER BY sequence_id"
+	args := []interface{}{instance.InstanceID}
+
+	if executionID != instance.ExecutionID {
+		var n int
+		if err := tx.QueryRowContext(
+			ctx,
+			"SELECT COUNT(*) FROM executions WHERE instance_id = ? AND execution_id = ?",
+			instance.InstanceID,
+			instance.ExecutionID,
+		).Scan(&n); err != nil {
+			return nil, fmt.Errorf("getting workflow execution: %w", err)
+		}
+
+		if n == 0 {
+			return nil, backend.ErrInstanceNotFound
+		}
+
+		query = "SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at, caused_by FROM `execution_history` WHERE instance_id = ? AND execution_id = ? ORDER BY sequence_id"
+		args = append(args, instance.ExecutionID)
+	}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting execution history: %w", err)
+	}
+	defer rows.Close()
+
+	return scanHistoryEvents(rows)
+}
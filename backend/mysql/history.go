@@ -0,0 +1,109 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/sqlinstr"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+var _ backend.HistoryFilterProvider = (*mysqlBackend)(nil)
+
+func (b *mysqlBackend) GetFilteredWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, filter backend.HistoryFilter) ([]history.Event, error) {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkNotRemoved(ctx, tx, instance.InstanceID); err != nil {
+		return nil, err
+	}
+
+	query, args := historyFilterQuery(instance.InstanceID, filter)
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting history: %w", err)
+	}
+	defer rows.Close()
+
+	h, err := scanHistoryEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range h {
+		h[i] = filter.StripPayloads(h[i])
+	}
+
+	return h, nil
+}
+
+func historyFilterQuery(instanceID string, filter backend.HistoryFilter) (string, []interface{}) {
+	var q strings.Builder
+	q.WriteString("SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at, caused_by FROM `history` WHERE instance_id = ?")
+	args := []interface{}{instanceID}
+
+	if filter.FromSequenceID > 0 {
+		q.WriteString(" AND sequence_id >= ?")
+		args = append(args, filter.FromSequenceID)
+	}
+
+	if filter.ToSequenceID > 0 {
+		q.WriteString(" AND sequence_id <= ?")
+		args = append(args, filter.ToSequenceID)
+	}
+
+	if len(filter.EventTypes) > 0 {
+		q.WriteString(" AND event_type IN (?" + strings.Repeat(", ?", len(filter.EventTypes)-1) + ")")
+		for _, t := range filter.EventTypes {
+			args = append(args, t)
+		}
+	}
+
+	q.WriteString(" ORDER BY sequence_id")
+
+	return q.String(), args
+}
+
+func scanHistoryEvents(rows *sql.Rows) ([]history.Event, error) {
+	h := make([]history.Event, 0)
+
+	for rows.Next() {
+		var instanceID string
+		var attributes []byte
+		var causedBy sql.NullString
+
+		historyEvent := history.Event{}
+
+		if err := rows.Scan(
+			&historyEvent.ID,
+			&historyEvent.SequenceID,
+			&instanceID,
+			&historyEvent.Type,
+			&historyEvent.Timestamp,
+			&historyEvent.ScheduleEventID,
+			&attributes,
+			&historyEvent.VisibleAt,
+			&causedBy,
+		); err != nil {
+			return nil, fmt.Errorf("scanning event: %w", err)
+		}
+
+		historyEvent.CausedBy = causedBy.String
+
+		historyEvent.SetSerializedAttributes(attributes)
+
+		h = append(h, historyEvent)
+	}
+
+	return h, nil
+}
@@ -0,0 +1,85 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/sqlinstr"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+var _ backend.InstanceHolder = (*mysqlBackend)(nil)
+
+func (b *mysqlBackend) PlaceWorkflowInstanceHold(ctx context.Context, instance *workflow.Instance, reason string) error {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
+	res, err := b.db.ExecContext(
+		ctx,
+		"UPDATE instances SET hold_reason = ?, held_at = ? WHERE instance_id = ?",
+		reason,
+		b.options.Now(),
+		instance.InstanceID,
+	)
+	if err != nil {
+		return fmt.Errorf("placing hold on workflow instance: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return backend.ErrInstanceNotFound
+	}
+
+	return nil
+}
+
+func (b *mysqlBackend) ReleaseWorkflowInstanceHold(ctx context.Context, instance *workflow.Instance) error {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
+	res, err := b.db.ExecContext(
+		ctx,
+		"UPDATE instances SET hold_reason = NULL, held_at = NULL WHERE instance_id = ? AND held_at IS NOT NULL",
+		instance.InstanceID,
+	)
+	if err != nil {
+		return fmt.Errorf("releasing hold on workflow instance: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return backend.ErrInstanceNotHeld
+	}
+
+	return nil
+}
+
+func (b *mysqlBackend) GetWorkflowInstanceHold(ctx context.Context, instance *workflow.Instance) (*backend.Hold, error) {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
+	var reason sql.NullString
+	var heldAt sql.NullTime
+	if err := b.db.QueryRowContext(
+		ctx,
+		"SELECT hold_reason, held_at FROM instances WHERE instance_id = ?",
+		instance.InstanceID,
+	).Scan(&reason, &heldAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, backend.ErrInstanceNotFound
+		}
+
+		return nil, fmt.Errorf("getting hold of workflow instance: %w", err)
+	}
+
+	if !heldAt.Valid {
+		return nil, nil
+	}
+
+	return &backend.Hold{
+		Reason:   reason.String,
+		PlacedAt: heldAt.Time,
+	}, nil
+}
@@ -0,0 +1,67 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+)
+
+var _ backend.Leaser = (*mysqlBackend)(nil)
+
+func (b *mysqlBackend) AcquireLease(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		return false, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := b.options.Now()
+
+	res, err := tx.ExecContext(
+		ctx,
+		"INSERT IGNORE INTO `leases` (`name`, `owner`, `expires_at`) VALUES (?, ?, ?)",
+		name, owner, now.Add(ttl),
+	)
+	if err != nil {
+		return false, fmt.Errorf("inserting lease: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return false, err
+	} else if n == 0 {
+		// Lease exists, extend it if we hold it, or take it over if it has expired
+		res, err = tx.ExecContext(
+			ctx,
+			"UPDATE `leases` SET `owner` = ?, `expires_at` = ? WHERE `name` = ? AND (`owner` = ? OR `expires_at` < ?)",
+			owner, now.Add(ttl), name, owner, b.options.LockExpiryCutoff(now),
+		)
+		if err != nil {
+			return false, fmt.Errorf("updating lease: %w", err)
+		}
+
+		if n, err := res.RowsAffected(); err != nil {
+			return false, err
+		} else if n == 0 {
+			return false, nil
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("acquiring lease: %w", err)
+	}
+
+	return true, nil
+}
+
+func (b *mysqlBackend) ReleaseLease(ctx context.Context, name, owner string) error {
+	if _, err := b.db.ExecContext(ctx, "DELETE FROM `leases` WHERE `name` = ? AND `owner` = ?", name, owner); err != nil {
+		return fmt.Errorf("releasing lease: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// column is a column added to a table after the table was first released.
+type column struct {
+	table      string
+	name       string
+	definition string
+
+	// backfill, if set, is executed after the column has been added, e.g., to set it for existing rows
+	backfill string
+}
+
+// addedColumns are added to tables of existing databases, which are not changed by the CREATE TABLE IF NOT EXISTS
+// statements of the schema. Columns added to the schema have to be added here, too.
+var addedColumns = []column{
+	{"pending_events", "caused_by", "NVARCHAR(64) NULL", ""},
+	{"history", "caused_by", "NVARCHAR(64) NULL", ""},
+	{"instances", "next_activity_at", "DATETIME(3) NULL", ""},
+	{"instances", "removed_at", "DATETIME NULL", ""},
+	{"activities", "queue", "NVARCHAR(128) NOT NULL DEFAULT ''", ""},
+	{"instances", "hold_reason", "TEXT NULL", ""},
+	{"instances", "held_at", "DATETIME NULL", ""},
+	{"activities", "heartbeat_details", "BLOB NULL", ""},
+	// Events committed before the column was added are ordered by their timestamp
+	{"history", "committed_at", "DATETIME(3) NULL", "UPDATE `history` SET committed_at = timestamp WHERE committed_at IS NULL"},
+}
+
+// modifiedColumns are changed to their current definition in existing databases. typ is the expected COLUMN_TYPE,
+// columns already having it are not changed.
+var modifiedColumns = []struct {
+	column
+	typ string
+}{
+	// Activities delayed by the per-instance rate limit become visible with millisecond precision
+	{column{"activities", "visible_at", "DATETIME(3) NULL", ""}, "datetime(3)"},
+}
+
+// index is an index added to a table after the table was first released.
+type index struct {
+	table      string
+	name       string
+	definition string
+
+	// prepare, if set, is executed before the index is created, e.g., to remove rows violating a unique index
+	prepare string
+}
+
+// addedIndexes are created once the columns they index have been added.
+var addedIndexes = []index{
+	{
+		"pending_events", "idx_pending_events_instance_id_event_id",
+		"UNIQUE INDEX `idx_pending_events_instance_id_event_id` (`instance_id`, `event_id`)",
+		// Keep the first of any events delivered more than once
+		"DELETE pe FROM `pending_events` pe INNER JOIN `pending_events` dup ON pe.instance_id = dup.instance_id AND pe.event_id = dup.event_id AND pe.id > dup.id",
+	},
+	{"instances", "idx_instances_removed_at", "INDEX `idx_instances_removed_at` (`removed_at`)", ""},
+	{"activities", "idx_activities_queue", "INDEX `idx_activities_queue` (`queue`)", ""},
+	{"instances", "idx_instances_completed_at_sticky_until", "INDEX `idx_instances_completed_at_sticky_until` (`completed_at`, `sticky_until`)", ""},
+	{"history", "idx_history_committed_at", "INDEX `idx_history_committed_at` (`committed_at`, `instance_id`)", ""},
+}
+
+// migrate adds columns and indexes missing from databases created with an earlier version of the schema. It's
+// safe to run it for up-to-date databases.
+func migrate(db *sql.DB) error {
+	for _, c := range addedColumns {
+		var n int
+		if err := db.QueryRow(
+			"SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?",
+			c.table, c.name,
+		).Scan(&n); err != nil {
+			return fmt.Errorf("checking column %s.%s: %w", c.table, c.name, err)
+		}
+
+		if n > 0 {
+			continue
+		}
+
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `%s` %s", c.table, c.name, c.definition)); err != nil {
+			return fmt.Errorf("adding column %s.%s: %w", c.table, c.name, err)
+		}
+
+		if c.backfill != "" {
+			if _, err := db.Exec(c.backfill); err != nil {
+				return fmt.Errorf("backfilling column %s.%s: %w", c.table, c.name, err)
+			}
+		}
+	}
+
+	for _, c := range modifiedColumns {
+		var typ string
+		if err := db.QueryRow(
+			"SELECT COLUMN_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?",
+			c.table, c.name,
+		).Scan(&typ); err != nil {
+			return fmt.Errorf("checking column %s.%s: %w", c.table, c.name, err)
+		}
+
+		if typ == c.typ {
+			continue
+		}
+
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE `%s` MODIFY COLUMN `%s` %s", c.table, c.name, c.definition)); err != nil {
+			return fmt.Errorf("modifying column %s.%s: %w", c.table, c.name, err)
+		}
+	}
+
+	for _, i := range addedIndexes {
+		var n int
+		if err := db.QueryRow(
+			"SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?",
+			i.table, i.name,
+		).Scan(&n); err != nil {
+			return fmt.Errorf("checking index %s: %w", i.name, err)
+		}
+
+		if n > 0 {
+			continue
+		}
+
+		if i.prepare != "" {
+			if _, err := db.Exec(i.prepare); err != nil {
+				return fmt.Errorf("preparing index %s: %w", i.name, err)
+			}
+		}
+
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE `%s` ADD %s", i.table, i.definition)); err != nil {
+			return fmt.Errorf("creating index %s: %w", i.name, err)
+		}
+	}
+
+	return nil
+}
@@ -11,14 +11,17 @@ import (
 	"time"
 
 	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/activityrate"
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/historycache"
 	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/internal/sqlinstr"
 	"github.com/cschleiden/go-workflows/internal/task"
 	"github.com/cschleiden/go-workflows/log"
 	"github.com/cschleiden/go-workflows/metrics"
 	"github.com/cschleiden/go-workflows/workflow"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -39,19 +42,38 @@ func NewMysqlBackend(host string, port int, user, password, database string, opt
 		panic(fmt.Errorf("initializing database: %w", err))
 	}
 
+	if err := migrate(db); err != nil {
+		panic(fmt.Errorf("migrating database: %w", err))
+	}
+
 	if err := db.Close(); err != nil {
 		panic(err)
 	}
 
-	db, err = sql.Open("mysql", dsn)
+	options := backend.ApplyOptions(opts...)
+
+	db, err = sqlinstr.Open(&mysql.MySQLDriver{}, dsn, sqlinstr.Options{
+		Logger:             options.Logger,
+		Metrics:            options.Metrics.WithTags(metrics.Tags{metrickeys.Backend: "mysql"}),
+		SlowQueryThreshold: options.SlowQueryThreshold,
+	})
 	if err != nil {
 		panic(err)
 	}
 
+	if options.UseDatabaseTime {
+		local := options.Clock
+		if local == nil {
+			local = time.Now
+		}
+
+		options.Clock = newDatabaseClock(db, local, options.Logger).Now
+	}
+
 	return &mysqlBackend{
 		db:         db,
 		workerName: fmt.Sprintf("worker-%v", uuid.NewString()),
-		options:    backend.ApplyOptions(opts...),
+		options:    options,
 	}
 }
 
@@ -63,6 +85,11 @@ type mysqlBackend struct {
 
 // CreateWorkflowInstance creates a new workflow instance
 func (b *mysqlBackend) CreateWorkflowInstance(ctx context.Context, instance *workflow.Instance, event history.Event) error {
+	return b.CreateWorkflowInstances(ctx, []history.WorkflowEvent{{WorkflowInstance: instance, HistoryEvent: event}})
+}
+
+// CreateWorkflowInstances creates the given workflow instances in a single transaction
+func (b *mysqlBackend) CreateWorkflowInstances(ctx context.Context, instances []history.WorkflowEvent) error {
 	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 	})
@@ -71,14 +98,24 @@ func (b *mysqlBackend) CreateWorkflowInstance(ctx context.Context, instance *wor
 	}
 	defer tx.Rollback()
 
-	// Create workflow instance
-	if err := createInstance(ctx, tx, instance, event.Attributes.(*history.ExecutionStartedAttributes).Metadata, false); err != nil {
-		return err
-	}
+	for _, i := range instances {
+		instance, event := i.WorkflowInstance, i.HistoryEvent
+		ctx := sqlinstr.WithInstanceID(ctx, instance.InstanceID)
 
-	// Initial history is empty, store only new events
-	if err := insertPendingEvents(ctx, tx, instance.InstanceID, []history.Event{event}); err != nil {
-		return fmt.Errorf("inserting new event: %w", err)
+		a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&event)
+		if err != nil {
+			return err
+		}
+
+		// Create workflow instance
+		if err := createInstance(ctx, tx, instance, a.Metadata, b.options.Now()); err != nil {
+			return err
+		}
+
+		// Initial history is empty, store only new events
+		if err := insertPendingEvents(ctx, tx, instance.InstanceID, []history.Event{event}); err != nil {
+			return fmt.Errorf("inserting new event: %w", err)
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -92,6 +129,18 @@ func (b *mysqlBackend) Logger() log.Logger {
 	return b.options.Logger
 }
 
+var _ backend.RedactorProvider = (*mysqlBackend)(nil)
+
+func (b *mysqlBackend) Redactor() backend.Redactor {
+	return b.options.Redactor
+}
+
+var _ backend.Clock = (*mysqlBackend)(nil)
+
+func (b *mysqlBackend) Now() time.Time {
+	return b.options.Now()
+}
+
 func (b *mysqlBackend) Tracer() trace.Tracer {
 	return b.options.TracerProvider.Tracer(backend.TracerName)
 }
@@ -101,6 +150,8 @@ func (b *mysqlBackend) Metrics() metrics.Client {
 }
 
 func (b *mysqlBackend) CancelWorkflowInstance(ctx context.Context, instance *workflow.Instance, event *history.Event) error {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
 	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 	})
@@ -130,71 +181,69 @@ func (b *mysqlBackend) CancelWorkflowInstance(ctx context.Context, instance *wor
 }
 
 func (b *mysqlBackend) GetWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, lastSequenceID *int64) ([]history.Event, error) {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
+	latest := func() (int64, error) {
+		tx, err := b.db.BeginTx(ctx, nil)
+		if err != nil {
+			return 0, err
+		}
+		defer tx.Rollback()
+
+		if err := checkNotRemoved(ctx, tx, instance.InstanceID); err != nil {
+			return 0, err
+		}
+
+		return getLastSequenceID(ctx, tx, instance.InstanceID)
+	}
+
+	return historycache.Get(ctx, b.options.HistoryCache, b.Logger(), b.Metrics(), instance.InstanceID, lastSequenceID, latest, func() ([]history.Event, error) {
+		return b.getHistory(ctx, instance.InstanceID, lastSequenceID)
+	})
+}
+
+func (b *mysqlBackend) getHistory(ctx context.Context, instanceID string, lastSequenceID *int64) ([]history.Event, error) {
 	tx, err := b.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
+	if err := checkNotRemoved(ctx, tx, instanceID); err != nil {
+		return nil, err
+	}
+
 	var historyEvents *sql.Rows
 	if lastSequenceID != nil {
 		historyEvents, err = tx.QueryContext(
 			ctx,
-			"SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at FROM `history` WHERE instance_id = ? AND sequence_id > ? ORDER BY sequence_id",
-			instance.InstanceID,
+			"SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at, caused_by FROM `history` WHERE instance_id = ? AND sequence_id > ? ORDER BY sequence_id",
+			instanceID,
 			*lastSequenceID,
 		)
 	} else {
 		historyEvents, err = tx.QueryContext(
 			ctx,
-			"SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at FROM `history` WHERE instance_id = ? ORDER BY sequence_id",
-			instance.InstanceID,
+			"SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at, caused_by FROM `history` WHERE instance_id = ? ORDER BY sequence_id",
+			instanceID,
 		)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("getting history: %w", err)
 	}
 
-	h := make([]history.Event, 0)
-
-	for historyEvents.Next() {
-		var instanceID string
-		var attributes []byte
-
-		historyEvent := history.Event{}
-
-		if err := historyEvents.Scan(
-			&historyEvent.ID,
-			&historyEvent.SequenceID,
-			&instanceID,
-			&historyEvent.Type,
-			&historyEvent.Timestamp,
-			&historyEvent.ScheduleEventID,
-			&attributes,
-			&historyEvent.VisibleAt,
-		); err != nil {
-			return nil, fmt.Errorf("scanning event: %w", err)
-		}
-
-		a, err := history.DeserializeAttributes(historyEvent.Type, attributes)
-		if err != nil {
-			return nil, fmt.Errorf("deserializing attributes: %w", err)
-		}
-
-		historyEvent.Attributes = a
-
-		h = append(h, historyEvent)
-	}
+	defer historyEvents.Close()
 
-	return h, nil
+	return scanHistoryEvents(historyEvents)
 }
 
 func (b *mysqlBackend) GetWorkflowInstanceState(ctx context.Context, instance *workflow.Instance) (core.WorkflowInstanceState, error) {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
 	row := b.db.QueryRowContext(
 		ctx,
-		"SELECT completed_at FROM instances WHERE instance_id = ? AND execution_id = ?",
+		"SELECT completed_at FROM instances WHERE instance_id = ? AND removed_at IS NULL",
 		instance.InstanceID,
-		instance.ExecutionID,
 	)
 
 	var completedAt sql.NullTime
@@ -211,7 +260,7 @@ func (b *mysqlBackend) GetWorkflowInstanceState(ctx context.Context, instance *w
 	return core.WorkflowInstanceStateActive, nil
 }
 
-func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, metadata *workflow.Metadata, ignoreDuplicate bool) error {
+func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, metadata *workflow.Metadata, createdAt time.Time) error {
 	var parentInstanceID *string
 	var parentEventID *int64
 	if wfi.SubWorkflow() {
@@ -229,26 +278,25 @@ func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, met
 
 	res, err := tx.ExecContext(
 		ctx,
-		"INSERT IGNORE INTO `instances` (instance_id, execution_id, parent_instance_id, parent_schedule_event_id, metadata) VALUES (?, ?, ?, ?, ?)",
+		"INSERT IGNORE INTO `instances` (instance_id, execution_id, parent_instance_id, parent_schedule_event_id, metadata, created_at) VALUES (?, ?, ?, ?, ?, ?)",
 		wfi.InstanceID,
 		wfi.ExecutionID,
 		parentInstanceID,
 		parentEventID,
 		string(metadataJson),
+		createdAt,
 	)
 	if err != nil {
 		return fmt.Errorf("inserting workflow instance: %w", err)
 	}
 
-	if !ignoreDuplicate {
-		rows, err := res.RowsAffected()
-		if err != nil {
-			return err
-		}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
 
-		if rows != 1 {
-			return backend.ErrInstanceAlreadyExists
-		}
+	if rows != 1 {
+		return backend.ErrInstanceAlreadyExists
 	}
 
 	return nil
@@ -256,6 +304,8 @@ func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, met
 
 // SignalWorkflow signals a running workflow instance
 func (b *mysqlBackend) SignalWorkflow(ctx context.Context, instanceID string, event history.Event) error {
+	ctx = sqlinstr.WithInstanceID(ctx, instanceID)
+
 	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 	})
@@ -287,8 +337,11 @@ func (b *mysqlBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, err
 	}
 	defer tx.Rollback()
 
-	// Lock next workflow task by finding an unlocked instance with new events to process.
-	now := time.Now()
+	// Lock next workflow task by finding an unlocked instance with new events to process. Instances are ordered
+	// by when they were last unlocked, so that one instance with a constant stream of new events does not starve
+	// the others.
+	now := b.options.Now()
+	cutoff := b.options.LockExpiryCutoff(now)
 	row := tx.QueryRowContext(
 		ctx,
 		`SELECT i.id, i.instance_id, i.execution_id, i.parent_instance_id, i.parent_schedule_event_id, i.metadata, i.sticky_until
@@ -299,10 +352,11 @@ func (b *mysqlBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, err
 				AND (pe.visible_at IS NULL OR pe.visible_at <= ?)
 				AND (i.locked_until IS NULL OR i.locked_until < ?)
 				AND (i.sticky_until IS NULL OR i.sticky_until < ? OR i.worker = ?)
+			ORDER BY i.sticky_until
 			LIMIT 1
 			FOR UPDATE OF i SKIP LOCKED`,
 		now,          // event.visible_at
-		now,          // locked_until
+		cutoff,       // locked_until
 		now,          // sticky_until
 		b.workerName, // worker
 	)
@@ -318,9 +372,13 @@ func (b *mysqlBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, err
 			return nil, nil
 		}
 
+		b.recordLockContention("workflow_task", err)
+
 		return nil, fmt.Errorf("scanning workflow instance: %w", err)
 	}
 
+	ctx = sqlinstr.WithInstanceID(ctx, instanceID)
+
 	res, err := tx.ExecContext(
 		ctx,
 		`UPDATE instances i
@@ -331,6 +389,8 @@ func (b *mysqlBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, err
 		id,
 	)
 	if err != nil {
+		b.recordLockContention("workflow_task", err)
+
 		return nil, fmt.Errorf("locking workflow instance: %w", err)
 	}
 
@@ -338,6 +398,8 @@ func (b *mysqlBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, err
 		return nil, fmt.Errorf("locking workflow instance: %w", err)
 	} else if affectedRows == 0 {
 		// No instance locked?
+		b.recordLockContention("workflow_task", nil)
+
 		return nil, nil
 	}
 
@@ -366,7 +428,7 @@ func (b *mysqlBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, err
 	// Get new events
 	events, err := tx.QueryContext(
 		ctx,
-		"SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at FROM `pending_events` WHERE instance_id = ? AND (`visible_at` IS NULL OR `visible_at` <= ?) ORDER BY id",
+		"SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at, caused_by FROM `pending_events` WHERE instance_id = ? AND (`visible_at` IS NULL OR `visible_at` <= ?) ORDER BY id",
 		instanceID,
 		now,
 	)
@@ -377,6 +439,7 @@ func (b *mysqlBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, err
 	for events.Next() {
 		var instanceID string
 		var attributes []byte
+		var causedBy sql.NullString
 
 		historyEvent := history.Event{}
 
@@ -389,16 +452,14 @@ func (b *mysqlBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, err
 			&historyEvent.ScheduleEventID,
 			&attributes,
 			&historyEvent.VisibleAt,
+			&causedBy,
 		); err != nil {
 			return nil, fmt.Errorf("scanning event: %w", err)
 		}
 
-		a, err := history.DeserializeAttributes(historyEvent.Type, attributes)
-		if err != nil {
-			return nil, fmt.Errorf("deserializing attributes: %w", err)
-		}
+		historyEvent.CausedBy = causedBy.String
 
-		historyEvent.Attributes = a
+		historyEvent.SetSerializedAttributes(attributes)
 
 		t.NewEvents = append(t.NewEvents, historyEvent)
 	}
@@ -409,13 +470,8 @@ func (b *mysqlBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, err
 	}
 
 	// Get most recent sequence id
-	row = tx.QueryRowContext(ctx, "SELECT sequence_id FROM `history` WHERE instance_id = ? ORDER BY id DESC LIMIT 1", instanceID)
-	if err := row.Scan(
-		&t.LastSequenceID,
-	); err != nil {
-		if err != sql.ErrNoRows {
-			return nil, fmt.Errorf("getting most recent sequence id: %w", err)
-		}
+	if t.LastSequenceID, err = getLastSequenceID(ctx, tx, instanceID); err != nil {
+		return nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -438,6 +494,38 @@ func (b *mysqlBackend) CompleteWorkflowTask(
 	executedEvents, activityEvents, timerEvents []history.Event,
 	workflowEvents []history.WorkflowEvent,
 ) error {
+	return b.completeWorkflowTask(ctx, task, instance, state, false, executedEvents, activityEvents, timerEvents, workflowEvents)
+}
+
+var _ backend.WorkflowTaskCheckpointer = (*mysqlBackend)(nil)
+
+// CheckpointWorkflowTask commits the progress of a workflow task like CompleteWorkflowTask, but keeps the instance
+// locked
+func (b *mysqlBackend) CheckpointWorkflowTask(
+	ctx context.Context,
+	task *task.Workflow,
+	instance *workflow.Instance,
+	executedEvents, activityEvents, timerEvents []history.Event,
+	workflowEvents []history.WorkflowEvent,
+) error {
+	return b.completeWorkflowTask(
+		ctx, task, instance, core.WorkflowInstanceStateActive, true, executedEvents, activityEvents, timerEvents, workflowEvents)
+}
+
+func (b *mysqlBackend) completeWorkflowTask(
+	ctx context.Context,
+	task *task.Workflow,
+	instance *workflow.Instance,
+	state core.WorkflowInstanceState,
+	keepLocked bool,
+	executedEvents, activityEvents, timerEvents []history.Event,
+	workflowEvents []history.WorkflowEvent,
+) error {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
+	// Timers fire after their delay according to the clock of the backend, not the one of the worker
+	timerEvents = backend.RebaseFutureEvents(b.options.Now(), timerEvents)
+
 	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 	})
@@ -446,22 +534,34 @@ func (b *mysqlBackend) CompleteWorkflowTask(
 	}
 	defer tx.Rollback()
 
-	// Unlock instance, but keep it sticky to the current worker
+	// Unlock instance, but keep it sticky to the current worker. Checkpoints extend the lock instead.
 	var completedAt *time.Time
 	if state == core.WorkflowInstanceStateFinished {
-		t := time.Now()
+		t := b.options.Now()
 		completedAt = &t
 	}
 
-	res, err := tx.ExecContext(
-		ctx,
-		`UPDATE instances SET locked_until = NULL, sticky_until = ?, completed_at = ? WHERE instance_id = ? AND execution_id = ? AND worker = ?`,
-		time.Now().Add(b.options.StickyTimeout),
-		completedAt,
-		instance.InstanceID,
-		instance.ExecutionID,
-		b.workerName,
-	)
+	var res sql.Result
+	if keepLocked {
+		res, err = tx.ExecContext(
+			ctx,
+			`UPDATE instances SET locked_until = ? WHERE instance_id = ? AND execution_id = ? AND worker = ?`,
+			b.options.Now().Add(b.options.WorkflowLockTimeout),
+			instance.InstanceID,
+			instance.ExecutionID,
+			b.workerName,
+		)
+	} else {
+		res, err = tx.ExecContext(
+			ctx,
+			`UPDATE instances SET locked_until = NULL, sticky_until = ?, completed_at = ? WHERE instance_id = ? AND execution_id = ? AND worker = ?`,
+			b.options.Now().Add(b.options.StickyTimeout),
+			completedAt,
+			instance.InstanceID,
+			instance.ExecutionID,
+			b.workerName,
+		)
+	}
 	if err != nil {
 		return fmt.Errorf("unlocking instance: %w", err)
 	}
@@ -491,15 +591,35 @@ func (b *mysqlBackend) CompleteWorkflowTask(
 	}
 
 	// Insert new events generated during this workflow execution to the history
-	if err := insertHistoryEvents(ctx, tx, instance.InstanceID, executedEvents); err != nil {
+	if err := insertHistoryEvents(ctx, tx, instance.InstanceID, executedEvents, b.options.Now()); err != nil {
 		return fmt.Errorf("inserting new history events: %w", err)
 	}
 
 	// Schedule activities
-	for _, e := range activityEvents {
-		if err := scheduleActivity(ctx, tx, instance, e); err != nil {
-			return fmt.Errorf("scheduling activity: %w", err)
+	visibleAt, err := b.throttleActivities(ctx, tx, instance.InstanceID, len(activityEvents))
+	if err != nil {
+		return err
+	}
+
+	if visibleAt != nil {
+		throttled := make([]history.Event, len(activityEvents))
+		for i, e := range activityEvents {
+			e.VisibleAt = &visibleAt[i]
+			throttled[i] = e
 		}
+
+		activityEvents = throttled
+		timerEvents = backend.DelayActivityTimeouts(activityEvents, timerEvents)
+	}
+
+	if err := scheduleActivities(ctx, tx, instance, activityEvents); err != nil {
+		return fmt.Errorf("scheduling activities: %w", err)
+	}
+
+	// Remove canceled and rescheduled timers and obsolete activity timeouts before scheduling new ones,
+	// rescheduled timers are replaced by a new timer event with the same schedule event id.
+	if err := removeFutureEventsOf(ctx, tx, instance.InstanceID, backend.RemovedFutureEvents(executedEvents)); err != nil {
+		return fmt.Errorf("removing future events: %w", err)
 	}
 
 	// Timer events
@@ -507,12 +627,11 @@ func (b *mysqlBackend) CompleteWorkflowTask(
 		return fmt.Errorf("scheduling timers: %w", err)
 	}
 
-	for _, event := range executedEvents {
-		switch event.Type {
-		case history.EventType_TimerCanceled:
-			if err := removeFutureEvent(ctx, tx, instance.InstanceID, event.ScheduleEventID); err != nil {
-				return fmt.Errorf("removing future event: %w", err)
-			}
+	// The instance won't be executed again, remove any events scheduled for the future, e.g., timers that have not
+	// fired yet.
+	if state == core.WorkflowInstanceStateFinished {
+		if err := removeFutureEvents(ctx, tx, instance.InstanceID); err != nil {
+			return fmt.Errorf("removing future events: %w", err)
 		}
 	}
 
@@ -520,14 +639,51 @@ func (b *mysqlBackend) CompleteWorkflowTask(
 	groupedEvents := history.EventsByWorkflowInstanceID(workflowEvents)
 
 	for targetInstanceID, events := range groupedEvents {
-		for _, m := range events {
+		for i, m := range events {
 			if m.HistoryEvent.Type == history.EventType_WorkflowExecutionStarted {
-				a := m.HistoryEvent.Attributes.(*history.ExecutionStartedAttributes)
-				// Create new instance
-				if err := createInstance(ctx, tx, m.WorkflowInstance, a.Metadata, true); err != nil {
+				a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&m.HistoryEvent)
+				if err != nil {
 					return err
 				}
 
+				if targetInstanceID == instance.InstanceID {
+					// The instance continued as new, replace the current execution
+					canceled, err := continueAsNew(ctx, tx, instance, m.WorkflowInstance, a.Metadata, b.options.Now())
+					if err != nil {
+						return err
+					}
+
+					// Cancellations requested for the previous execution also apply to the new one
+					cancellations := make([]history.WorkflowEvent, 0, len(canceled)+len(events)-i-1)
+					for _, event := range canceled {
+						cancellations = append(cancellations, history.WorkflowEvent{WorkflowInstance: m.WorkflowInstance, HistoryEvent: event})
+					}
+					events = append(events[:i+1:i+1], append(cancellations, events[i+1:]...)...)
+
+					break
+				}
+
+				// Create new instance. If the derived instance ID of a sub-workflow is already taken, fail the
+				// sub-workflow in the parent instead of starting the existing instance again. Explicit instance IDs
+				// deliver the start event to the existing instance.
+				if err := createInstance(ctx, tx, m.WorkflowInstance, a.Metadata, b.options.Now()); err != nil {
+					if !errors.Is(err, backend.ErrInstanceAlreadyExists) {
+						return err
+					}
+
+					if !a.DerivedInstanceID {
+						break
+					}
+
+					if err := insertPendingEvents(ctx, tx, instance.InstanceID, []history.Event{
+						backend.SubWorkflowAlreadyExistsEvent(b.options.Now(), m.WorkflowInstance),
+					}); err != nil {
+						return fmt.Errorf("failing sub-workflow: %w", err)
+					}
+
+					events = append(events[:i:i], events[i+1:]...)
+				}
+
 				break
 			}
 		}
@@ -546,17 +702,21 @@ func (b *mysqlBackend) CompleteWorkflowTask(
 		return fmt.Errorf("committing complete workflow transaction: %w", err)
 	}
 
+	historycache.Append(ctx, b.options.HistoryCache, b.Logger(), instance.InstanceID, executedEvents, state == core.WorkflowInstanceStateFinished)
+
 	return nil
 }
 
 func (b *mysqlBackend) ExtendWorkflowTask(ctx context.Context, taskID string, instance *core.WorkflowInstance) error {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
 	tx, err := b.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	until := time.Now().Add(b.options.WorkflowLockTimeout)
+	until := b.options.Now().Add(b.options.WorkflowLockTimeout)
 	res, err := tx.ExecContext(
 		ctx,
 		`UPDATE instances SET locked_until = ? WHERE instance_id = ? AND execution_id = ? AND worker = ?`,
@@ -578,6 +738,59 @@ func (b *mysqlBackend) ExtendWorkflowTask(ctx context.Context, taskID string, in
 	return tx.Commit()
 }
 
+// AbandonWorkflowTask releases the lock on the given task, other workers can pick it up again after the delay
+func (b *mysqlBackend) AbandonWorkflowTask(ctx context.Context, t *task.Workflow, delay time.Duration) error {
+	ctx = sqlinstr.WithInstanceID(ctx, t.WorkflowInstance.InstanceID)
+
+	// Keep the lock until the delay has passed, after that any worker can pick up the task again
+	res, err := b.db.ExecContext(
+		ctx,
+		`UPDATE instances SET locked_until = ? WHERE instance_id = ? AND execution_id = ? AND worker = ?`,
+		b.options.Now().Add(delay),
+		t.WorkflowInstance.InstanceID,
+		t.WorkflowInstance.ExecutionID,
+		b.workerName,
+	)
+	if err != nil {
+		return fmt.Errorf("abandoning workflow task: %w", err)
+	}
+
+	if rowsAffected, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("determining if workflow task was abandoned: %w", err)
+	} else if rowsAffected == 0 {
+		return errors.New("could not abandon workflow task")
+	}
+
+	return nil
+}
+
+func (b *mysqlBackend) ParkWorkflowTask(ctx context.Context, t *task.Workflow, delay time.Duration) error {
+	ctx = sqlinstr.WithInstanceID(ctx, t.WorkflowInstance.InstanceID)
+
+	// Keep the lock until the delay has passed, and the instance sticky to this worker for a while after that
+	lockedUntil := b.options.Now().Add(delay)
+	res, err := b.db.ExecContext(
+		ctx,
+		`UPDATE instances SET locked_until = ?, sticky_until = ? WHERE instance_id = ? AND execution_id = ? AND worker = ?`,
+		lockedUntil,
+		lockedUntil.Add(b.options.StickyTimeout),
+		t.WorkflowInstance.InstanceID,
+		t.WorkflowInstance.ExecutionID,
+		b.workerName,
+	)
+	if err != nil {
+		return fmt.Errorf("parking workflow task: %w", err)
+	}
+
+	if rowsAffected, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("determining if workflow task was parked: %w", err)
+	} else if rowsAffected == 0 {
+		return errors.New("could not park workflow task")
+	}
+
+	return nil
+}
+
 // GetActivityTask returns a pending activity task or nil if there are no pending activities
 func (b *mysqlBackend) GetActivityTask(ctx context.Context) (*task.Activity, error) {
 	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
@@ -589,32 +802,47 @@ func (b *mysqlBackend) GetActivityTask(ctx context.Context) (*task.Activity, err
 	defer tx.Rollback()
 
 	// Lock next activity
-	now := time.Now()
+	now := b.options.Now()
+	cutoff := b.options.LockExpiryCutoff(now)
+	queues := b.options.ServedActivityQueues()
+	args := []interface{}{
+		cutoff, // locked_until
+		now,    // visible_at
+	}
+	for _, queue := range queues {
+		args = append(args, queue)
+	}
+
 	res := tx.QueryRowContext(
 		ctx,
 		`SELECT activities.id, activity_id, activities.instance_id, activities.execution_id,
-			instances.metadata, event_type, timestamp, schedule_event_id, attributes, visible_at
+			instances.metadata, event_type, timestamp, schedule_event_id, attributes, visible_at, heartbeat_details
 			FROM activities
 				INNER JOIN instances ON activities.instance_id = instances.instance_id
-			WHERE activities.locked_until IS NULL OR activities.locked_until < ?
+			WHERE
+				(activities.locked_until IS NULL OR activities.locked_until < ?)
+				AND (activities.visible_at IS NULL OR activities.visible_at <= ?)
+				AND activities.queue IN (?`+strings.Repeat(", ?", len(queues)-1)+`)
 			LIMIT 1
 			FOR UPDATE SKIP LOCKED`,
-		now,
+		args...,
 	)
 
 	var id int64
 	var instanceID, executionID string
-	var attributes []byte
+	var attributes, heartbeatDetails []byte
 	var metadataJson sql.NullString
 	event := history.Event{}
 
 	if err := res.Scan(
 		&id, &event.ID, &instanceID, &executionID, &metadataJson, &event.Type,
-		&event.Timestamp, &event.ScheduleEventID, &attributes, &event.VisibleAt); err != nil {
+		&event.Timestamp, &event.ScheduleEventID, &attributes, &event.VisibleAt, &heartbeatDetails); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 
+		b.recordLockContention("activity_task", err)
+
 		return nil, fmt.Errorf("finding activity task to lock: %w", err)
 	}
 
@@ -623,12 +851,7 @@ func (b *mysqlBackend) GetActivityTask(ctx context.Context) (*task.Activity, err
 		return nil, fmt.Errorf("unmarshaling metadata: %w", err)
 	}
 
-	a, err := history.DeserializeAttributes(event.Type, attributes)
-	if err != nil {
-		return nil, fmt.Errorf("deserializing attributes: %w", err)
-	}
-
-	event.Attributes = a
+	event.SetSerializedAttributes(attributes)
 
 	if _, err := tx.ExecContext(
 		ctx,
@@ -637,6 +860,8 @@ func (b *mysqlBackend) GetActivityTask(ctx context.Context) (*task.Activity, err
 		b.workerName,
 		id,
 	); err != nil {
+		b.recordLockContention("activity_task", err)
+
 		return nil, fmt.Errorf("locking activity: %w", err)
 	}
 
@@ -645,6 +870,7 @@ func (b *mysqlBackend) GetActivityTask(ctx context.Context) (*task.Activity, err
 		WorkflowInstance: core.NewWorkflowInstance(instanceID, executionID),
 		Metadata:         metadata,
 		Event:            event,
+		HeartbeatDetails: heartbeatDetails,
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -656,6 +882,8 @@ func (b *mysqlBackend) GetActivityTask(ctx context.Context) (*task.Activity, err
 
 // CompleteActivityTask completes a activity task retrieved using GetActivityTask
 func (b *mysqlBackend) CompleteActivityTask(ctx context.Context, instance *workflow.Instance, id string, event history.Event) error {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
 	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 	})
@@ -685,6 +913,21 @@ func (b *mysqlBackend) CompleteActivityTask(ctx context.Context, instance *workf
 		}
 	}
 
+	// Drop the result if the instance continued as new since the activity was scheduled
+	var current int
+	if err := tx.QueryRowContext(
+		ctx,
+		"SELECT COUNT(*) FROM instances WHERE instance_id = ? AND execution_id = ?",
+		instance.InstanceID,
+		instance.ExecutionID,
+	).Scan(&current); err != nil {
+		return fmt.Errorf("checking workflow instance execution: %w", err)
+	}
+
+	if current == 0 {
+		return tx.Commit()
+	}
+
 	// Insert new event generated during this workflow execution
 	if err := insertPendingEvents(ctx, tx, instance.InstanceID, []history.Event{event}); err != nil {
 		return fmt.Errorf("inserting new events for completed activity: %w", err)
@@ -704,7 +947,7 @@ func (b *mysqlBackend) ExtendActivityTask(ctx context.Context, activityID string
 	}
 	defer tx.Rollback()
 
-	until := time.Now().Add(b.options.ActivityLockTimeout)
+	until := b.options.Now().Add(b.options.ActivityLockTimeout)
 	res, err := tx.ExecContext(
 		ctx,
 		`UPDATE activities SET locked_until = ? WHERE activity_id = ? AND worker = ?`,
@@ -725,25 +968,57 @@ func (b *mysqlBackend) ExtendActivityTask(ctx context.Context, activityID string
 	return tx.Commit()
 }
 
-func scheduleActivity(ctx context.Context, tx *sql.Tx, instance *core.WorkflowInstance, event history.Event) error {
-	a, err := history.SerializeAttributes(event.Attributes)
-	if err != nil {
-		return err
+// throttleActivities returns the times at which the given number of activities of the instance become visible to
+// workers, spread according to InstanceActivityRate. It returns nil if the rate is not limited.
+func (b *mysqlBackend) throttleActivities(ctx context.Context, tx *sql.Tx, instanceID string, n int) ([]time.Time, error) {
+	if b.options.InstanceActivityRate <= 0 || n == 0 {
+		return nil, nil
 	}
 
-	_, err = tx.ExecContext(
-		ctx,
-		`INSERT INTO activities
-			(activity_id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-		event.ID,
-		instance.InstanceID,
-		instance.ExecutionID,
-		event.Type,
-		event.Timestamp,
-		event.ScheduleEventID,
-		a,
-		event.VisibleAt,
-	)
+	var next sql.NullTime
+	if err := tx.QueryRowContext(ctx, "SELECT next_activity_at FROM instances WHERE instance_id = ? FOR UPDATE", instanceID).Scan(&next); err != nil {
+		return nil, fmt.Errorf("getting next activity time: %w", err)
+	}
+
+	visibleAt, nextActivityAt := activityrate.Schedule(b.options.Now(), next.Time, n, b.options.InstanceActivityRate)
+
+	if _, err := tx.ExecContext(ctx, "UPDATE instances SET next_activity_at = ? WHERE instance_id = ?", nextActivityAt, instanceID); err != nil {
+		return nil, fmt.Errorf("updating next activity time: %w", err)
+	}
+
+	return visibleAt, nil
+}
+
+// scheduleActivities inserts the given activity events, using a single statement per batch.
+func scheduleActivities(ctx context.Context, tx *sql.Tx, instance *core.WorkflowInstance, events []history.Event) error {
+	for batchStart := 0; batchStart < len(events); batchStart += insertBatchSize {
+		batchEnd := batchStart + insertBatchSize
+		if batchEnd > len(events) {
+			batchEnd = len(events)
+		}
+		batchEvents := events[batchStart:batchEnd]
+
+		query := `INSERT INTO activities
+			(activity_id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at, queue) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)` +
+			strings.Repeat(", (?, ?, ?, ?, ?, ?, ?, ?, ?)", len(batchEvents)-1)
+
+		args := make([]interface{}, 0, len(batchEvents)*9)
+
+		for i := range batchEvents {
+			event := &batchEvents[i]
 
-	return err
+			a, err := event.SerializedAttributes()
+			if err != nil {
+				return err
+			}
+
+			args = append(args, event.ID, instance.InstanceID, instance.ExecutionID, event.Type, event.Timestamp, event.ScheduleEventID, a, event.VisibleAt, backend.ActivityQueue(event))
+		}
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
@@ -6,11 +6,14 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/cschleiden/go-workflows/backend"
 	"github.com/cschleiden/go-workflows/backend/test"
+	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
 )
 
 const testUser = "root"
@@ -97,6 +100,140 @@ func TestMySqlBackendE2E(t *testing.T) {
 	})
 }
 
+// initialSchema is the schema of the first release, before any columns were added.
+const initialSchema = `CREATE TABLE IF NOT EXISTS instances (
+  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  instance_id NVARCHAR(128) NOT NULL,
+  execution_id NVARCHAR(128) NOT NULL,
+  parent_instance_id NVARCHAR(128) NULL,
+  parent_schedule_event_id BIGINT NULL,
+  metadata BLOB NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  completed_at DATETIME NULL,
+  locked_until DATETIME NULL,
+  sticky_until DATETIME NULL,
+  worker NVARCHAR(64) NULL,
+
+  UNIQUE INDEX idx_instances_instance_id (instance_id),
+  INDEX idx_instances_locked_until_completed_at (completed_at, locked_until, sticky_until, worker),
+  INDEX idx_instances_parent_instance_id (parent_instance_id)
+);
+
+
+CREATE TABLE IF NOT EXISTS pending_events (
+  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  event_id NVARCHAR(128) NOT NULL,
+  sequence_id BIGINT NOT NULL, -- Not used, but keep for now for query compat
+  instance_id NVARCHAR(128) NOT NULL,
+  event_type INT NOT NULL,
+  timestamp DATETIME NOT NULL,
+  schedule_event_id BIGINT NOT NULL,
+  attributes BLOB NOT NULL,
+  visible_at DATETIME NULL,
+
+  INDEX idx_pending_events_instance_id (instance_id),
+  INDEX idx_pending_events_instance_id_visible_at_schedule_event_id (instance_id, visible_at, schedule_event_id)
+);
+
+
+CREATE TABLE IF NOT EXISTS history (
+  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  event_id NVARCHAR(64) NOT NULL,
+  sequence_id BIGINT NOT NULL,
+  instance_id NVARCHAR(128) NOT NULL,
+  event_type INT NOT NULL,
+  timestamp DATETIME NOT NULL,
+  schedule_event_id BIGINT NOT NULL,
+  attributes BLOB NOT NULL,
+  visible_at DATETIME NULL, -- Is this required?
+
+  INDEX idx_history_instance_id (instance_id),
+  INDEX idx_history_instance_id_sequence_id (instance_id, sequence_id)
+);
+
+
+CREATE TABLE IF NOT EXISTS activities (
+  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  activity_id NVARCHAR(64) NOT NULL,
+  instance_id NVARCHAR(128) NOT NULL,
+  execution_id NVARCHAR(128) NOT NULL,
+  event_type INT NOT NULL,
+  timestamp DATETIME NOT NULL,
+  schedule_event_id BIGINT NOT NULL,
+  attributes BLOB NOT NULL,
+  visible_at DATETIME NULL,
+  locked_until DATETIME NULL,
+  worker NVARCHAR(64) NULL,
+
+  UNIQUE INDEX idx_activities_instance_id (instance_id, activity_id, execution_id, worker),
+  INDEX idx_activities_locked_until (locked_until)
+);`
+
+func Test_MigratesExistingDatabase(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@/?parseTime=true&interpolateParams=true", testUser, testPassword))
+	require.NoError(t, err)
+
+	dbName := "test_" + strings.Replace(uuid.NewString(), "-", "", -1)
+	_, err = db.Exec("CREATE DATABASE " + dbName)
+	require.NoError(t, err)
+
+	defer func() {
+		_, err := db.Exec("DROP DATABASE IF EXISTS " + dbName)
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+	}()
+
+	// Create the tables as they were in the first release
+	sdb, err := sql.Open("mysql", fmt.Sprintf("%s:%s@/%s?multiStatements=true", testUser, testPassword, dbName))
+	require.NoError(t, err)
+	_, err = sdb.Exec(initialSchema)
+	require.NoError(t, err)
+
+	// Events delivered more than once don't prevent creating the unique index
+	for i := 0; i < 2; i++ {
+		_, err = sdb.Exec(
+			"INSERT INTO pending_events (event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes) VALUES ('e', 0, 'i', 1, NOW(), 0, '{}')",
+		)
+		require.NoError(t, err)
+	}
+	require.NoError(t, sdb.Close())
+
+	b := NewMysqlBackend("localhost", 3306, testUser, testPassword, dbName, backend.WithStickyTimeout(0))
+	ctx := context.Background()
+
+	var n int
+	require.NoError(t, b.db.QueryRow("SELECT COUNT(*) FROM pending_events WHERE instance_id = 'i'").Scan(&n))
+	require.Equal(t, 1, n)
+
+	var typ string
+	require.NoError(t, b.db.QueryRow(
+		"SELECT COLUMN_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'activities' AND COLUMN_NAME = 'visible_at'",
+	).Scan(&typ))
+	require.Equal(t, "datetime(3)", typ)
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.CreateWorkflowInstance(
+		ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	))
+
+	tk, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.NoError(t, b.CompleteWorkflowTask(
+		ctx, tk, wfi, core.WorkflowInstanceStateActive, tk.NewEvents, []history.Event{}, []history.Event{}, []history.WorkflowEvent{}))
+	require.NoError(t, b.db.Close())
+
+	// Migrating an up-to-date database doesn't change it
+	b = NewMysqlBackend("localhost", 3306, testUser, testPassword, dbName, backend.WithStickyTimeout(0))
+	h, err := b.GetWorkflowInstanceHistory(ctx, wfi, nil)
+	require.NoError(t, err)
+	require.Len(t, h, 1)
+	require.NoError(t, b.db.Close())
+}
+
 var _ test.TestBackend = (*mysqlBackend)(nil)
 
 func (mb *mysqlBackend) GetFutureEvents(ctx context.Context) ([]history.Event, error) {
@@ -136,12 +273,7 @@ func (mb *mysqlBackend) GetFutureEvents(ctx context.Context) ([]history.Event, e
 			return nil, fmt.Errorf("scanning event: %w", err)
 		}
 
-		a, err := history.DeserializeAttributes(fe.Type, attributes)
-		if err != nil {
-			return nil, fmt.Errorf("deserializing attributes: %w", err)
-		}
-
-		fe.Attributes = a
+		fe.SetSerializedAttributes(attributes)
 
 		f = append(f, fe)
 	}
@@ -0,0 +1,153 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/historycache"
+	"github.com/cschleiden/go-workflows/internal/sqlinstr"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+var _ backend.InstanceRemover = (*mysqlBackend)(nil)
+
+func (b *mysqlBackend) RemoveWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var completedAt, removedAt, heldAt sql.NullTime
+	if err := tx.QueryRowContext(
+		ctx,
+		"SELECT completed_at, removed_at, held_at FROM instances WHERE instance_id = ?",
+		instance.InstanceID,
+	).Scan(&completedAt, &removedAt, &heldAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return backend.ErrInstanceNotFound
+		}
+
+		return fmt.Errorf("getting workflow instance: %w", err)
+	}
+
+	if removedAt.Valid {
+		return backend.ErrInstanceNotFound
+	}
+
+	if !completedAt.Valid {
+		return backend.ErrInstanceNotFinished
+	}
+
+	if heldAt.Valid {
+		return backend.ErrInstanceHeld
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE instances SET removed_at = ? WHERE instance_id = ?", b.options.Now(), instance.InstanceID); err != nil {
+		return fmt.Errorf("removing workflow instance: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// Cached histories would still be returned for the removed instance
+	historycache.Invalidate(ctx, b.options.HistoryCache, b.Logger(), instance.InstanceID)
+
+	return nil
+}
+
+func (b *mysqlBackend) UndeleteWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
+	res, err := b.db.ExecContext(
+		ctx,
+		"UPDATE instances SET removed_at = NULL WHERE instance_id = ? AND removed_at IS NOT NULL",
+		instance.InstanceID,
+	)
+	if err != nil {
+		return fmt.Errorf("restoring workflow instance: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return backend.ErrInstanceNotFound
+	}
+
+	return nil
+}
+
+func (b *mysqlBackend) PurgeRemovedWorkflowInstances(ctx context.Context, removedBefore time.Time) (int, error) {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT instance_id FROM instances WHERE removed_at IS NOT NULL AND removed_at < ? AND held_at IS NULL", removedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("listing removed workflow instances: %w", err)
+	}
+
+	var instanceIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		instanceIDs = append(instanceIDs, id)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range instanceIDs {
+		for _, table := range []string{"history", "executions", "execution_history", "pending_events", "activities", "prefetch_hints"} {
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM `%s` WHERE instance_id = ?", table), id); err != nil {
+				return 0, fmt.Errorf("purging %s of workflow instance %s: %w", table, id, err)
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM instances WHERE instance_id = ?", id); err != nil {
+			return 0, fmt.Errorf("purging workflow instance %s: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	historycache.Invalidate(ctx, b.options.HistoryCache, b.Logger(), instanceIDs...)
+
+	return len(instanceIDs), nil
+}
+
+// checkNotRemoved returns ErrInstanceNotFound if the given instance has been removed. Instances that don't exist
+// are not reported, reading their history returns no events.
+func checkNotRemoved(ctx context.Context, tx *sql.Tx, instanceID string) error {
+	var removed bool
+	if err := tx.QueryRowContext(ctx, "SELECT removed_at IS NOT NULL FROM instances WHERE instance_id = ?", instanceID).Scan(&removed); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+
+		return fmt.Errorf("getting workflow instance: %w", err)
+	}
+
+	if removed {
+		return backend.ErrInstanceNotFound
+	}
+
+	return nil
+}
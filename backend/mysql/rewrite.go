@@ -0,0 +1,157 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/historycache"
+)
+
+var _ backend.PayloadRewriter = (*mysqlBackend)(nil)
+
+const rewriteBatchSize = 100
+
+// RewritePayloads implements backend.PayloadRewriter. It updates history, including the histories of executions
+// instances continued from, pending events, and scheduled activities including their heartbeat details in batches,
+// each in its own transaction.
+func (b *mysqlBackend) RewritePayloads(ctx context.Context, rewrite backend.PayloadRewriteFunc) (int, error) {
+	updated := 0
+
+	for _, table := range []string{"history", "execution_history", "pending_events", "activities"} {
+		var lastID int64
+
+		for {
+			n, next, instanceIDs, err := b.rewriteBatch(ctx, table, lastID, rewrite)
+			if err != nil {
+				return updated, fmt.Errorf("rewriting %s: %w", table, err)
+			}
+
+			updated += n
+
+			if table == "history" {
+				historycache.Invalidate(ctx, b.options.HistoryCache, b.Logger(), instanceIDs...)
+			}
+
+			if next == lastID {
+				break
+			}
+
+			lastID = next
+		}
+	}
+
+	return updated, nil
+}
+
+func (b *mysqlBackend) rewriteBatch(
+	ctx context.Context, table string, afterID int64, rewrite backend.PayloadRewriteFunc,
+) (int, int64, []string, error) {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, afterID, nil, err
+	}
+	defer tx.Rollback()
+
+	// Activities also store the details of their last heartbeat
+	columns := "id, instance_id, event_type, attributes"
+	if table == "activities" {
+		columns += ", heartbeat_details"
+	}
+
+	rows, err := tx.QueryContext(
+		ctx,
+		"SELECT "+columns+" FROM `"+table+"` WHERE id > ? ORDER BY id LIMIT ?",
+		afterID,
+		rewriteBatchSize,
+	)
+	if err != nil {
+		return 0, afterID, nil, err
+	}
+
+	type update struct {
+		id               int64
+		attributes       []byte
+		heartbeatDetails []byte
+	}
+
+	updates := make([]update, 0)
+	instanceIDs := make([]string, 0)
+	lastID := afterID
+
+	for rows.Next() {
+		var id int64
+		var instanceID string
+		var eventType history.EventType
+		var attributes, heartbeatDetails []byte
+
+		dest := []interface{}{&id, &instanceID, &eventType, &attributes}
+		if table == "activities" {
+			dest = append(dest, &heartbeatDetails)
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return 0, afterID, nil, fmt.Errorf("scanning event: %w", err)
+		}
+
+		lastID = id
+
+		event := history.Event{Type: eventType}
+		event.SetSerializedAttributes(attributes)
+
+		e, changed, err := history.RewritePayloads(event, rewrite)
+		if err != nil {
+			rows.Close()
+			return 0, afterID, nil, err
+		}
+
+		if len(heartbeatDetails) > 0 {
+			details, detailsChanged, err := rewrite(heartbeatDetails)
+			if err != nil {
+				rows.Close()
+				return 0, afterID, nil, err
+			}
+
+			if detailsChanged {
+				heartbeatDetails = details
+				changed = true
+			}
+		}
+
+		if !changed {
+			continue
+		}
+
+		attributes, err = e.SerializedAttributes()
+		if err != nil {
+			rows.Close()
+			return 0, afterID, nil, fmt.Errorf("serializing attributes: %w", err)
+		}
+
+		updates = append(updates, update{id, attributes, heartbeatDetails})
+		instanceIDs = append(instanceIDs, instanceID)
+	}
+
+	if err := rows.Close(); err != nil {
+		return 0, afterID, nil, err
+	}
+
+	for _, u := range updates {
+		query, args := "UPDATE `"+table+"` SET attributes = ? WHERE id = ?", []interface{}{u.attributes, u.id}
+		if table == "activities" {
+			query, args = "UPDATE `"+table+"` SET attributes = ?, heartbeat_details = ? WHERE id = ?", []interface{}{u.attributes, u.heartbeatDetails, u.id}
+		}
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return 0, afterID, nil, fmt.Errorf("updating event: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, afterID, nil, err
+	}
+
+	return len(updates), lastID, instanceIDs, nil
+}
@@ -10,10 +10,16 @@ CREATE TABLE IF NOT EXISTS `instances` (
   `locked_until` DATETIME NULL,
   `sticky_until` DATETIME NULL,
   `worker` NVARCHAR(64) NULL,
+  `next_activity_at` DATETIME(3) NULL,
+  `removed_at` DATETIME NULL,
+  `hold_reason` TEXT NULL,
+  `held_at` DATETIME NULL,
 
   UNIQUE INDEX `idx_instances_instance_id` (`instance_id`),
   INDEX `idx_instances_locked_until_completed_at` (`completed_at`, `locked_until`, `sticky_until`, `worker`),
-  INDEX `idx_instances_parent_instance_id` (`parent_instance_id`)
+  INDEX `idx_instances_completed_at_sticky_until` (`completed_at`, `sticky_until`),
+  INDEX `idx_instances_parent_instance_id` (`parent_instance_id`),
+  INDEX `idx_instances_removed_at` (`removed_at`)
 );
 
 
@@ -27,7 +33,9 @@ CREATE TABLE IF NOT EXISTS `pending_events` (
   `schedule_event_id` BIGINT NOT NULL,
   `attributes` BLOB NOT NULL,
   `visible_at` DATETIME NULL,
+  `caused_by` NVARCHAR(64) NULL,
 
+  UNIQUE INDEX `idx_pending_events_instance_id_event_id` (`instance_id`, `event_id`),
   INDEX `idx_pending_events_instance_id` (`instance_id`),
   INDEX `idx_pending_events_instance_id_visible_at_schedule_event_id` (`instance_id`, `visible_at`, `schedule_event_id`)
 );
@@ -43,9 +51,44 @@ CREATE TABLE IF NOT EXISTS `history` (
   `schedule_event_id` BIGINT NOT NULL,
   `attributes` BLOB NOT NULL,
   `visible_at` DATETIME NULL, -- Is this required?
+  `caused_by` NVARCHAR(64) NULL,
+  `committed_at` DATETIME(3) NULL,
 
   INDEX `idx_history_instance_id` (`instance_id`),
-  INDEX `idx_history_instance_id_sequence_id` (`instance_id`, `sequence_id`)
+  INDEX `idx_history_instance_id_sequence_id` (`instance_id`, `sequence_id`),
+  INDEX `idx_history_committed_at` (`committed_at`, `instance_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `executions` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  `instance_id` NVARCHAR(128) NOT NULL,
+  `execution_id` NVARCHAR(128) NOT NULL,
+  `parent_instance_id` NVARCHAR(128) NULL,
+  `parent_schedule_event_id` BIGINT NULL,
+  `metadata` BLOB NULL,
+  `created_at` DATETIME NOT NULL,
+  `completed_at` DATETIME NOT NULL,
+
+  UNIQUE INDEX `idx_executions_instance_id_execution_id` (`instance_id`, `execution_id`),
+  INDEX `idx_executions_completed_at` (`completed_at`, `instance_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `execution_history` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  `event_id` NVARCHAR(64) NOT NULL,
+  `sequence_id` BIGINT NOT NULL,
+  `instance_id` NVARCHAR(128) NOT NULL,
+  `execution_id` NVARCHAR(128) NOT NULL,
+  `event_type` INT NOT NULL,
+  `timestamp` DATETIME NOT NULL,
+  `schedule_event_id` BIGINT NOT NULL,
+  `attributes` BLOB NOT NULL,
+  `visible_at` DATETIME NULL,
+  `caused_by` NVARCHAR(64) NULL,
+  `committed_at` DATETIME(3) NOT NULL,
+
+  INDEX `idx_execution_history_instance_id_execution_id` (`instance_id`, `execution_id`, `sequence_id`),
+  INDEX `idx_execution_history_committed_at` (`committed_at`, `instance_id`)
 );
 
 
@@ -58,10 +101,25 @@ CREATE TABLE IF NOT EXISTS `activities` (
   `timestamp` DATETIME NOT NULL,
   `schedule_event_id` BIGINT NOT NULL,
   `attributes` BLOB NOT NULL,
-  `visible_at` DATETIME NULL,
+  `visible_at` DATETIME(3) NULL,
   `locked_until` DATETIME NULL,
   `worker` NVARCHAR(64) NULL,
+  `queue` NVARCHAR(128) NOT NULL DEFAULT '',
+  `heartbeat_details` BLOB NULL,
 
   UNIQUE INDEX `idx_activities_instance_id` (`instance_id`, `activity_id`, `execution_id`, `worker`),
-  INDEX `idx_activities_locked_until` (`locked_until`)
-);
\ No newline at end of file
+  INDEX `idx_activities_locked_until` (`locked_until`),
+  INDEX `idx_activities_queue` (`queue`)
+);
+CREATE TABLE IF NOT EXISTS `leases` (
+  `name` NVARCHAR(128) NOT NULL PRIMARY KEY,
+  `owner` NVARCHAR(64) NOT NULL,
+  `expires_at` DATETIME NOT NULL
+);
+CREATE TABLE IF NOT EXISTS `prefetch_hints` (
+  `instance_id` NVARCHAR(128) NOT NULL,
+  `execution_id` NVARCHAR(128) NOT NULL,
+  `until` DATETIME(3) NOT NULL,
+
+  PRIMARY KEY (`instance_id`, `execution_id`)
+);
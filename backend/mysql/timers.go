@@ -0,0 +1,36 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/history"
+)
+
+var _ backend.TimerLister = (*mysqlBackend)(nil)
+
+func (b *mysqlBackend) PendingTimers(ctx context.Context, count int) ([]*backend.PendingTimer, error) {
+	rows, err := b.db.QueryContext(
+		ctx,
+		"SELECT instance_id, schedule_event_id, timestamp, visible_at FROM `pending_events` WHERE event_type = ? AND visible_at IS NOT NULL ORDER BY visible_at LIMIT ?",
+		history.EventType_TimerFired,
+		count,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing pending timers: %w", err)
+	}
+	defer rows.Close()
+
+	timers := make([]*backend.PendingTimer, 0)
+	for rows.Next() {
+		t := &backend.PendingTimer{}
+		if err := rows.Scan(&t.InstanceID, &t.ScheduleEventID, &t.ScheduledAt, &t.FireAt); err != nil {
+			return nil, fmt.Errorf("scanning timer: %w", err)
+		}
+
+		timers = append(timers, t)
+	}
+
+	return timers, rows.Err()
+}
@@ -0,0 +1,95 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+)
+
+var _ backend.InstanceTreeReader = (*mysqlBackend)(nil)
+
+func (mb *mysqlBackend) GetWorkflowInstanceNode(ctx context.Context, instanceID string) (*backend.WorkflowInstanceNode, error) {
+	row := mb.db.QueryRowContext(
+		ctx,
+		`SELECT instance_id, execution_id, parent_instance_id, parent_schedule_event_id, created_at, completed_at
+		FROM instances
+		WHERE instance_id = ? AND removed_at IS NULL`,
+		instanceID,
+	)
+
+	node, err := scanWorkflowInstanceNode(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, backend.ErrInstanceNotFound
+		}
+
+		return nil, fmt.Errorf("getting workflow instance: %w", err)
+	}
+
+	return node, nil
+}
+
+func (mb *mysqlBackend) GetSubWorkflowInstanceNodes(ctx context.Context, instanceID string) ([]*backend.WorkflowInstanceNode, error) {
+	rows, err := mb.db.QueryContext(
+		ctx,
+		`SELECT instance_id, execution_id, parent_instance_id, parent_schedule_event_id, created_at, completed_at
+		FROM instances
+		WHERE parent_instance_id = ? AND removed_at IS NULL
+		ORDER BY created_at, instance_id`,
+		instanceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting sub-workflow instances: %w", err)
+	}
+	defer rows.Close()
+
+	nodes := make([]*backend.WorkflowInstanceNode, 0)
+	for rows.Next() {
+		node, err := scanWorkflowInstanceNode(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning sub-workflow instance: %w", err)
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	return nodes, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWorkflowInstanceNode(row rowScanner) (*backend.WorkflowInstanceNode, error) {
+	var id, executionID string
+	var parentInstanceID *string
+	var parentEventID *int64
+	var createdAt time.Time
+	var completedAt *time.Time
+
+	if err := row.Scan(&id, &executionID, &parentInstanceID, &parentEventID, &createdAt, &completedAt); err != nil {
+		return nil, err
+	}
+
+	instance := core.NewWorkflowInstance(id, executionID)
+	if parentInstanceID != nil && parentEventID != nil {
+		instance = core.NewSubWorkflowInstance(id, executionID, *parentInstanceID, *parentEventID)
+	}
+
+	state := core.WorkflowInstanceStateActive
+	if completedAt != nil {
+		state = core.WorkflowInstanceStateFinished
+	}
+
+	return &backend.WorkflowInstanceNode{
+		Instance:    instance,
+		State:       state,
+		CreatedAt:   createdAt,
+		CompletedAt: completedAt,
+	}, nil
+}
@@ -22,6 +22,95 @@ type Options struct {
 	WorkflowLockTimeout time.Duration
 
 	ActivityLockTimeout time.Duration
+
+	// InstanceActivityRate is the maximum number of activities per second a single workflow instance can
+	// schedule. Activities exceeding the rate are delayed, protecting downstream systems from instances that fan
+	// out to a large number of activities at once. 0 is no limit.
+	InstanceActivityRate int
+
+	// SlowQueryThreshold is the duration above which queries of SQL backends are logged as slow. 0 disables the
+	// slow query log.
+	SlowQueryThreshold time.Duration
+
+	// Clock returns the current time used to evaluate when events become visible and when locks expire. Defaults
+	// to time.Now.
+	Clock func() time.Time
+
+	// ClockSkewTolerance is the maximum expected clock skew between workers. Locks expire only once they are due
+	// by more than this tolerance, so workers with clocks running ahead don't take over tasks that are still locked
+	// by another worker. Timers are not delayed by it, backends schedule them on their own clock, see
+	// RebaseFutureEvents.
+	ClockSkewTolerance time.Duration
+
+	// UseDatabaseTime makes backends that support it use the time of the database server instead of the local
+	// clock, so all workers agree on the current time.
+	UseDatabaseTime bool
+
+	// HistoryCache, if set, is used by SQL backends to cache the histories of actively executing workflow
+	// instances.
+	HistoryCache HistoryCache
+
+	// ActivityQueues are the queues GetActivityTask returns activities from. Defaults to only the
+	// DefaultActivityQueue.
+	ActivityQueues []string
+
+	// ActivityLabels are the labels of this worker. Activities requiring labels are only returned by
+	// GetActivityTask if all of them are in ActivityLabels.
+	ActivityLabels []string
+
+	// Redactor, if set, is applied to payloads in histories and query results returned to callers, and to payloads
+	// passed as fields to the Logger. See Redactor.
+	Redactor Redactor
+}
+
+// Now returns the current time according to Clock.
+func (o *Options) Now() time.Time {
+	if o.Clock == nil {
+		return time.Now()
+	}
+
+	return o.Clock()
+}
+
+// Clock is implemented by backends to expose the current time according to Options.Clock, which they use for the
+// timestamps they record, e.g., when instances complete or are removed.
+type Clock interface {
+	Now() time.Time
+}
+
+// Now returns the current time according to the clock of the given backend, or the local time if it doesn't
+// implement Clock.
+func Now(b Backend) time.Time {
+	if c, ok := b.(Clock); ok {
+		return c.Now()
+	}
+
+	return time.Now()
+}
+
+// LockExpiryCutoff returns the time up to which locks are expired, taking ClockSkewTolerance into account.
+func (o *Options) LockExpiryCutoff(now time.Time) time.Time {
+	return now.Add(-o.ClockSkewTolerance)
+}
+
+// ServedActivityQueues returns the queues activity tasks are returned from, see ActivityQueues. With
+// ActivityLabels, this includes the queues for activities requiring any combination of the labels.
+func (o *Options) ServedActivityQueues() []string {
+	queues := o.ActivityQueues
+	if len(queues) == 0 {
+		queues = []string{DefaultActivityQueue}
+	}
+
+	if len(o.ActivityLabels) == 0 {
+		return queues
+	}
+
+	served := make([]string, 0, len(queues)<<len(o.ActivityLabels))
+	for _, queue := range queues {
+		served = append(served, labeledActivityQueues(queue, o.ActivityLabels)...)
+	}
+
+	return served
 }
 
 var DefaultOptions Options = Options{
@@ -29,6 +118,8 @@ var DefaultOptions Options = Options{
 	WorkflowLockTimeout: time.Minute,
 	ActivityLockTimeout: time.Minute * 2,
 
+	Clock: time.Now,
+
 	Logger:         logger.NewDefaultLogger(),
 	Metrics:        mi.NewNoopMetricsClient(),
 	TracerProvider: trace.NewNoopTracerProvider(),
@@ -42,6 +133,82 @@ func WithStickyTimeout(timeout time.Duration) BackendOption {
 	}
 }
 
+func WithInstanceActivityRate(perSecond int) BackendOption {
+	return func(o *Options) {
+		o.InstanceActivityRate = perSecond
+	}
+}
+
+func WithSlowQueryThreshold(threshold time.Duration) BackendOption {
+	return func(o *Options) {
+		o.SlowQueryThreshold = threshold
+	}
+}
+
+func WithClock(clock func() time.Time) BackendOption {
+	return func(o *Options) {
+		o.Clock = clock
+	}
+}
+
+func WithClockSkewTolerance(tolerance time.Duration) BackendOption {
+	return func(o *Options) {
+		o.ClockSkewTolerance = tolerance
+	}
+}
+
+// WithDatabaseTime makes the backend use the time of the database server, see Options.UseDatabaseTime.
+func WithDatabaseTime() BackendOption {
+	return func(o *Options) {
+		o.UseDatabaseTime = true
+	}
+}
+
+// WithHistoryCache configures a read-through cache for the histories of actively executing workflow instances,
+// e.g., redis.NewHistoryCache. It's used by the SQL backends.
+func WithHistoryCache(cache HistoryCache) BackendOption {
+	return func(o *Options) {
+		o.HistoryCache = cache
+	}
+}
+
+// WithActivityQueues sets the queues this backend returns activity tasks from. Activities that specify a
+// queue in their options are only executed by workers whose backend serves that queue. Include
+// DefaultActivityQueue to also execute activities without a queue. It panics if a queue is invalid, see
+// ValidateActivityQueue.
+func WithActivityQueues(queues ...string) BackendOption {
+	for _, queue := range queues {
+		if err := ValidateActivityQueue(queue); err != nil {
+			panic(err)
+		}
+	}
+
+	return func(o *Options) {
+		o.ActivityQueues = queues
+	}
+}
+
+// WithActivityLabels sets the labels this worker advertises, e.g., "gpu" or "region=eu". Activities that require
+// labels in their options are only executed by workers with all of them. Every combination of labels is served
+// as a separate queue, so there can be at most MaxActivityLabels labels. It panics if the labels are invalid,
+// see ValidateActivityQueue.
+func WithActivityLabels(labels ...string) BackendOption {
+	if err := ValidateActivityQueue(DefaultActivityQueue, labels...); err != nil {
+		panic(err)
+	}
+
+	return func(o *Options) {
+		o.ActivityLabels = labels
+	}
+}
+
+// WithRedactor sets the redactor which is applied to payloads before they are returned to callers or logged.
+func WithRedactor(r Redactor) BackendOption {
+	return func(o *Options) {
+		o.Redactor = r
+	}
+}
+
 func WithLogger(logger log.Logger) BackendOption {
 	return func(o *Options) {
 		o.Logger = logger
@@ -71,5 +238,9 @@ func ApplyOptions(opts ...BackendOption) Options {
 		options.Logger = logger.NewDefaultLogger()
 	}
 
+	if options.Redactor != nil {
+		options.Logger = logger.NewRedactingLogger(options.Logger, options.Redactor)
+	}
+
 	return options
 }
@@ -0,0 +1,56 @@
+package backend
+
+import (
+	"context"
+	"time"
+)
+
+// OrphanedEvent is a pending event or a queued activity of a workflow instance that has finished or doesn't exist
+// anymore, e.g., a timer of a canceled instance, or the result of an activity that completed after its instance
+// finished. Such events are never processed.
+type OrphanedEvent struct {
+	InstanceID string
+	EventID    string
+
+	// EventType is the type of the event, e.g., "TimerFired" or "ActivityCompleted". Queued activities are
+	// reported as "ActivityScheduled".
+	EventType       string
+	ScheduleEventID int64
+
+	// Timestamp is the time the event was created.
+	Timestamp time.Time
+
+	// InstanceCompletedAt is the time the instance finished, zero if the instance doesn't exist.
+	InstanceCompletedAt time.Time
+}
+
+// OrphanedEventsReport lists the orphaned events of a backend.
+type OrphanedEventsReport struct {
+	// Counts is the number of orphaned events by event type.
+	Counts map[string]int
+
+	// Events are the oldest orphaned events, up to the requested number.
+	Events []*OrphanedEvent
+}
+
+// Total returns the number of orphaned events.
+func (r *OrphanedEventsReport) Total() int {
+	total := 0
+	for _, n := range r.Counts {
+		total += n
+	}
+
+	return total
+}
+
+// OrphanedEventCleaner is implemented by backends that can report and delete orphaned events. Orphaned events only
+// take up storage, but a growing number of them can point to bugs in how instances are completed.
+type OrphanedEventCleaner interface {
+	// OrphanedEvents returns a report of all orphaned events, listing up to count of them.
+	OrphanedEvents(ctx context.Context, count int) (*OrphanedEventsReport, error)
+
+	// DeleteOrphanedEvents deletes the orphaned events of instances that finished before the given time, and of
+	// instances that don't exist, and returns the number of deleted events. Pending events of running instances
+	// are never deleted, neither are activities currently locked by a worker.
+	DeleteOrphanedEvents(ctx context.Context, completedBefore time.Time) (int, error)
+}
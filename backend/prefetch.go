@@ -0,0 +1,22 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+// PrefetchHinter is implemented by backends that can store hints about workflow instances that are about to
+// receive many tasks, e.g., before a daily batch fan-in. Workers with a PrefetchHintInterval periodically read
+// the hints and prefetch the hinted instances, so that their tasks don't all have to replay the history at once.
+type PrefetchHinter interface {
+	// AddPrefetchHints hints that the given instances should be prefetched until the given time.
+	AddPrefetchHints(ctx context.Context, instances []*workflow.Instance, until time.Time) error
+
+	// PrefetchHints returns the instances that should currently be prefetched.
+	PrefetchHints(ctx context.Context) ([]*workflow.Instance, error)
+}
+
+var ErrPrefetchHintsNotSupported = errors.New("backend does not support prefetch hints")
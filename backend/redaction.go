@@ -0,0 +1,53 @@
+package backend
+
+import (
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+// Redactor is applied to every payload (workflow and activity inputs and results, signal arguments, ...) before
+// it leaves the backend for callers other than workers, e.g., histories returned by
+// GetFilteredWorkflowInstanceHistory and results of client.QueryWorkflow. It can be used to prevent sensitive data
+// from being displayed or logged. Workers replay the unredacted history.
+type Redactor func(data []byte) []byte
+
+// RedactEvent returns a copy of the event with the redactor applied to all its payloads. A nil redactor returns
+// the event unchanged.
+func (r Redactor) RedactEvent(e history.Event) history.Event {
+	if r == nil {
+		return e
+	}
+
+	return history.RedactPayloads(e, func(p payload.Payload) payload.Payload {
+		return r(p)
+	})
+}
+
+// RedactEvents applies RedactEvent to all given events. The given slice is not modified.
+func (r Redactor) RedactEvents(events []history.Event) []history.Event {
+	if r == nil {
+		return events
+	}
+
+	redacted := make([]history.Event, len(events))
+	for i, e := range events {
+		redacted[i] = r.RedactEvent(e)
+	}
+
+	return redacted
+}
+
+// RedactorProvider is implemented by backends to expose the Redactor configured with WithRedactor.
+type RedactorProvider interface {
+	Redactor() Redactor
+}
+
+// RedactorOf returns the redactor configured for the given backend, or nil if it doesn't implement
+// RedactorProvider or has no redactor.
+func RedactorOf(b Backend) Redactor {
+	if p, ok := b.(RedactorProvider); ok {
+		return p.Redactor()
+	}
+
+	return nil
+}
@@ -3,22 +3,89 @@ package redis
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/cschleiden/go-workflows/backend"
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/metrickeys"
 	"github.com/cschleiden/go-workflows/internal/task"
+	"github.com/cschleiden/go-workflows/metrics"
 )
 
-func (rb *redisBackend) GetActivityTask(ctx context.Context) (*task.Activity, error) {
-	activityTask, err := rb.activityQueue.Dequeue(ctx, rb.rdb, rb.options.ActivityLockTimeout, rb.options.BlockTimeout)
+func (rb *redisBackend) initActivityQueue(q *taskQueue[activityData]) {
+	// Tasks are only recovered when their lock expired, count these as lock contention
+	q.onRecover = func() {
+		rb.Metrics().Counter(metrickeys.BackendLockContention, metrics.Tags{metrickeys.Operation: "activity_task"}, 1)
+	}
+	q.visibleBefore = rb.visibleBefore
+}
+
+// getActivityQueue returns the task queue for the given activity queue, creating it if necessary.
+func (rb *redisBackend) getActivityQueue(queue string) (*taskQueue[activityData], error) {
+	if queue == backend.DefaultActivityQueue {
+		return rb.activityQueue, nil
+	}
+
+	rb.activityQueuesMu.Lock()
+	defer rb.activityQueuesMu.Unlock()
+
+	if q, ok := rb.activityQueues[queue]; ok {
+		return q, nil
+	}
+
+	q, err := newTaskQueue[activityData](rb.rdb, "activities:"+queue)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("creating activity task queue %q: %w", queue, err)
 	}
 
-	if activityTask == nil {
-		return nil, nil
+	rb.initActivityQueue(q)
+	rb.activityQueues[queue] = q
+
+	return q, nil
+}
+
+// activityTaskQueue returns the task queue the activity task with the given ID was returned from.
+func (rb *redisBackend) activityTaskQueue(activityID string) *taskQueue[activityData] {
+	if q, ok := rb.activityTaskQueues.Load(activityID); ok {
+		return q.(*taskQueue[activityData])
 	}
 
+	return rb.activityQueue
+}
+
+func (rb *redisBackend) GetActivityTask(ctx context.Context) (*task.Activity, error) {
+	queues := rb.options.ServedActivityQueues()
+
+	// Split the block timeout between the queues, so that idle queues don't delay the others
+	timeout := rb.options.BlockTimeout / time.Duration(len(queues))
+
+	for _, queue := range queues {
+		q, err := rb.getActivityQueue(queue)
+		if err != nil {
+			return nil, err
+		}
+
+		activityTask, err := q.Dequeue(ctx, rb.rdb, rb.options.ActivityLockTimeout, timeout)
+		if err != nil {
+			return nil, err
+		}
+
+		if activityTask == nil {
+			continue
+		}
+
+		if q != rb.activityQueue {
+			rb.activityTaskQueues.Store(activityTask.TaskID, q)
+		}
+
+		return rb.activityTask(ctx, activityTask)
+	}
+
+	return nil, nil
+}
+
+func (rb *redisBackend) activityTask(ctx context.Context, activityTask *TaskItem[activityData]) (*task.Activity, error) {
 	instanceState, err := readInstance(ctx, rb.rdb, activityTask.Data.Instance.InstanceID)
 	if err != nil {
 		return nil, fmt.Errorf("reading workflow instance for activity task: %w", err)
@@ -35,7 +102,7 @@ func (rb *redisBackend) GetActivityTask(ctx context.Context) (*task.Activity, er
 func (rb *redisBackend) ExtendActivityTask(ctx context.Context, activityID string) error {
 	p := rb.rdb.Pipeline()
 
-	if err := rb.activityQueue.Extend(ctx, p, activityID); err != nil {
+	if err := rb.activityTaskQueue(activityID).Extend(ctx, p, activityID); err != nil {
 		return err
 	}
 
@@ -44,17 +111,30 @@ func (rb *redisBackend) ExtendActivityTask(ctx context.Context, activityID strin
 }
 
 func (rb *redisBackend) CompleteActivityTask(ctx context.Context, instance *core.WorkflowInstance, activityID string, event history.Event) error {
+	instanceState, err := readInstance(ctx, rb.rdb, instance.InstanceID)
+	if err != nil && err != backend.ErrInstanceNotFound {
+		return err
+	}
+
 	p := rb.rdb.TxPipeline()
 
-	if err := rb.addWorkflowInstanceEventP(ctx, p, instance, &event); err != nil {
-		return err
+	// Drop the result if the instance has been purged, or continued as new since the activity was scheduled
+	if instanceState != nil && instanceState.Instance.ExecutionID == instance.ExecutionID {
+		if err := rb.addWorkflowInstanceEventP(ctx, p, instance, &event); err != nil {
+			return err
+		}
 	}
 
 	// Unlock activity
-	if _, err := rb.activityQueue.Complete(ctx, p, activityID); err != nil {
+	if _, err := rb.activityTaskQueue(activityID).Complete(ctx, p, activityID); err != nil {
 		return err
 	}
 
-	_, err := p.Exec(ctx)
-	return err
+	if _, err := p.Exec(ctx); err != nil {
+		return err
+	}
+
+	rb.activityTaskQueues.Delete(activityID)
+
+	return nil
 }
@@ -0,0 +1,78 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Reserve a slot for a workflow instance. Returns 1 if the instance holds a slot, 0 if all slots are taken.
+//
+// KEYS[1] - set of instances holding a slot
+// ARGV[1] - instance id
+// ARGV[2] - maximum number of slots
+var workflowConcurrencyAcquireCmd = redis.NewScript(`
+	if redis.call("SISMEMBER", KEYS[1], ARGV[1]) == 1 then
+		return 1
+	end
+
+	if redis.call("SCARD", KEYS[1]) >= tonumber(ARGV[2]) then
+		return 0
+	end
+
+	redis.call("SADD", KEYS[1], ARGV[1])
+	return 1
+`)
+
+type workflowConcurrencyLimiter struct {
+	rdb    redis.UniversalClient
+	limits map[string]int
+}
+
+// NewWorkflowConcurrencyLimiter returns a limiter which enforces the given limits (concurrently running instances,
+// keyed by workflow name) across all workers sharing the same Redis instance. Workflows without a limit are not
+// restricted.
+//
+// Pass the returned limiter as `WorkflowConcurrencyLimiter` in the worker options.
+func NewWorkflowConcurrencyLimiter(client redis.UniversalClient, limits map[string]int) (*workflowConcurrencyLimiter, error) {
+	if err := workflowConcurrencyAcquireCmd.Load(context.Background(), client).Err(); err != nil {
+		return nil, fmt.Errorf("loading redis script: %w", err)
+	}
+
+	return &workflowConcurrencyLimiter{
+		rdb:    client,
+		limits: limits,
+	}, nil
+}
+
+func (l *workflowConcurrencyLimiter) Acquire(ctx context.Context, workflowName, instanceID string) (bool, error) {
+	limit, ok := l.limits[workflowName]
+	if !ok || limit <= 0 {
+		return true, nil
+	}
+
+	r, err := workflowConcurrencyAcquireCmd.Run(ctx, l.rdb, []string{workflowConcurrencyKey(workflowName)}, instanceID, limit).Int64()
+	if err != nil {
+		return false, fmt.Errorf("acquiring workflow concurrency slot: %w", err)
+	}
+
+	return r == 1, nil
+}
+
+func (l *workflowConcurrencyLimiter) Release(ctx context.Context, workflowName, instanceID string) error {
+	if err := l.rdb.SRem(ctx, workflowConcurrencyKey(workflowName), instanceID).Err(); err != nil {
+		return fmt.Errorf("releasing workflow concurrency slot: %w", err)
+	}
+
+	return nil
+}
+
+func (l *workflowConcurrencyLimiter) Holders(ctx context.Context, workflowName string) ([]string, error) {
+	holders, err := l.rdb.SMembers(ctx, workflowConcurrencyKey(workflowName)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("reading workflow concurrency slots: %w", err)
+	}
+
+	return holders, nil
+}
@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WorkflowConcurrencyLimiter(t *testing.T) {
+	// These cases rely on redis being running on localhost:6379. Skip this test if `-short` is set.
+	if testing.Short() {
+		t.Skip()
+	}
+
+	client := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs:    []string{"localhost:6379"},
+		Username: "",
+		Password: "RedisPassw0rd",
+		DB:       1,
+	})
+
+	ctx := context.Background()
+	require.NoError(t, client.FlushDB(ctx).Err())
+
+	l, err := NewWorkflowConcurrencyLimiter(client, map[string]int{"limited": 1})
+	require.NoError(t, err)
+
+	ok, err := l.Acquire(ctx, "unlimited", "i1")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = l.Acquire(ctx, "limited", "i1")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// Acquiring again for the same instance succeeds
+	ok, err = l.Acquire(ctx, "limited", "i1")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = l.Acquire(ctx, "limited", "i2")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	holders, err := l.Holders(ctx, "limited")
+	require.NoError(t, err)
+	require.Equal(t, []string{"i1"}, holders)
+
+	require.NoError(t, l.Release(ctx, "limited", "i1"))
+
+	ok, err = l.Acquire(ctx, "limited", "i2")
+	require.NoError(t, err)
+	require.True(t, ok)
+}
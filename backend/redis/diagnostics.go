@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/cschleiden/go-workflows/backend"
 	"github.com/cschleiden/go-workflows/diag"
 	"github.com/go-redis/redis/v8"
 )
@@ -58,6 +59,10 @@ func (rb *redisBackend) GetWorkflowInstances(ctx context.Context, afterInstanceI
 			return nil, fmt.Errorf("unmarshaling instance state: %w", err)
 		}
 
+		if state.RemovedAt != nil {
+			continue
+		}
+
 		instanceRefs = append(instanceRefs, &diag.WorkflowInstanceRef{
 			Instance:    state.Instance,
 			CreatedAt:   state.CreatedAt,
@@ -75,6 +80,10 @@ func (rb *redisBackend) GetWorkflowInstance(ctx context.Context, instanceID stri
 		return nil, err
 	}
 
+	if instance.RemovedAt != nil {
+		return nil, backend.ErrInstanceNotFound
+	}
+
 	return &diag.WorkflowInstanceRef{
 		Instance:    instance.Instance,
 		CreatedAt:   instance.CreatedAt,
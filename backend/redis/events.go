@@ -10,19 +10,36 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
-func addEventToStreamP(ctx context.Context, p redis.Pipeliner, streamKey string, event *history.Event) error {
+// addPendingEventCmd adds the given event to the pending events of an instance, unless an event with the same ID
+// has been added before. This deduplicates events that are delivered more than once, e.g., by retried requests.
+// The set of event IDs expires once no event has been added for the deduplication window.
+// KEYS[1] - pending events stream key
+// KEYS[2] - instance event IDs set key
+// ARGV[1] - event ID
+// ARGV[2] - event data as serialized string
+// ARGV[3] - deduplication window in milliseconds
+var addPendingEventCmd = redis.NewScript(`
+	local added = redis.call("SADD", KEYS[2], ARGV[1])
+	redis.call("PEXPIRE", KEYS[2], ARGV[3])
+
+	if added == 0 then
+		return 0
+	end
+
+	redis.call("XADD", KEYS[1], "*", "event", ARGV[2])
+	return 1
+`)
+
+func (rb *redisBackend) addPendingEventP(ctx context.Context, p redis.Pipeliner, instanceID string, event *history.Event) error {
 	eventData, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
 
-	return p.XAdd(ctx, &redis.XAddArgs{
-		Stream: streamKey,
-		ID:     "*",
-		Values: map[string]interface{}{
-			"event": string(eventData),
-		},
-	}).Err()
+	addPendingEventCmd.Run(ctx, p, []string{pendingEventsKey(instanceID), eventIDsKey(instanceID)},
+		event.ID, string(eventData), rb.options.EventDeduplicationWindow.Milliseconds())
+
+	return nil
 }
 
 // addEventsToStream adds the given events to the given event stream. If successful, the message id of the last event added
@@ -58,11 +75,13 @@ func addEventsToHistoryStreamP(ctx context.Context, p redis.Pipeliner, streamKey
 
 // KEYS[1] - future event zset key
 // KEYS[2] - future event key
+// KEYS[3] - instance future events set key
 // ARGV[1] - timestamp
 // ARGV[2] - Instance ID
 // ARGV[3] - event payload
 var addFutureEventCmd = redis.NewScript(`
 	redis.call("ZADD", KEYS[1], ARGV[1], KEYS[2])
+	redis.call("SADD", KEYS[3], KEYS[2])
 	return redis.call("HSET", KEYS[2], "instance", ARGV[2], "event", ARGV[3])
 `)
 
@@ -74,7 +93,7 @@ func addFutureEventP(ctx context.Context, p redis.Pipeliner, instance *core.Work
 
 	addFutureEventCmd.Run(
 		ctx, p,
-		[]string{futureEventsKey(), futureEventKey(instance.InstanceID, event.ScheduleEventID)},
+		[]string{futureEventsKey(), futureEventKeyOf(instance, event), instanceFutureEventsKey(instance.InstanceID)},
 		strconv.FormatInt(event.VisibleAt.UnixMilli(), 10),
 		instance.InstanceID,
 		string(eventData),
@@ -83,15 +102,61 @@ func addFutureEventP(ctx context.Context, p redis.Pipeliner, instance *core.Work
 	return nil
 }
 
+// futureEventKeyOf returns the key the given future event is stored under
+func futureEventKeyOf(instance *core.WorkflowInstance, event *history.Event) string {
+	if event.Type == history.EventType_ActivityFailed {
+		if a, err := history.AttributesAs[*history.ActivityFailedAttributes](event); err == nil && a.Timeout == history.ActivityTimeout_Heartbeat {
+			return heartbeatTimeoutKey(instance.InstanceID, event.ScheduleEventID)
+		}
+	}
+
+	return futureEventKey(instance.InstanceID, event.ScheduleEventID)
+}
+
 // KEYS[1] - future event zset key
 // KEYS[2] - future event key
+// ARGV[1] - current timestamp
+// ARGV[2] - new timestamp
+// ARGV[3] - event payload
+var rescheduleFutureEventCmd = redis.NewScript(`
+	local score = redis.call("ZSCORE", KEYS[1], KEYS[2])
+	if not score or tonumber(score) <= tonumber(ARGV[1]) then
+		return 0
+	end
+
+	redis.call("ZADD", KEYS[1], ARGV[2], KEYS[2])
+	return redis.call("HSET", KEYS[2], "event", ARGV[3])
+`)
+
+// KEYS[1] - future event zset key
+// KEYS[2] - future event key
+// KEYS[3] - instance future events set key
 var removeFutureEventCmd = redis.NewScript(`
 	redis.call("ZREM", KEYS[1], KEYS[2])
+	redis.call("SREM", KEYS[3], KEYS[2])
+	return redis.call("DEL", KEYS[2])
+`)
+
+// removeFutureEvent removes the scheduled future events with the given schedule event id
+func removeFutureEventP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, scheduleEventID int64) {
+	for _, key := range []string{futureEventKey(instance.InstanceID, scheduleEventID), heartbeatTimeoutKey(instance.InstanceID, scheduleEventID)} {
+		removeFutureEventCmd.Run(ctx, p, []string{futureEventsKey(), key, instanceFutureEventsKey(instance.InstanceID)})
+	}
+}
+
+// KEYS[1] - future event zset key
+// KEYS[2] - instance future events set key
+var removeFutureEventsCmd = redis.NewScript(`
+	local events = redis.call("SMEMBERS", KEYS[2])
+	for i = 1, #events do
+		redis.call("ZREM", KEYS[1], events[i])
+		redis.call("DEL", events[i])
+	end
+
 	return redis.call("DEL", KEYS[2])
 `)
 
-// removeFutureEvent removes a scheduled future event for the given event. Events are associated via their ScheduleEventID
-func removeFutureEventP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, event *history.Event) {
-	key := futureEventKey(instance.InstanceID, event.ScheduleEventID)
-	removeFutureEventCmd.Run(ctx, p, []string{futureEventsKey(), key})
+// removeFutureEventsP removes all scheduled future events for the given instance
+func removeFutureEventsP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance) {
+	removeFutureEventsCmd.Run(ctx, p, []string{futureEventsKey(), instanceFutureEventsKey(instance.InstanceID)})
 }
@@ -0,0 +1,108 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/workflow"
+	"github.com/go-redis/redis/v8"
+)
+
+var _ backend.ExecutionHistoryReader = (*redisBackend)(nil)
+
+// executionState is the completion record of an execution the instance continued from.
+type executionState struct {
+	Instance *core.WorkflowInstance `json:"instance,omitempty"`
+	Metadata *core.WorkflowMetadata `json:"metadata,omitempty"`
+
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+	CompletedAt time.Time `json:"completed_at,omitempty"`
+}
+
+// addExecutionP moves the history of the current execution of the instance to the execution history, and records
+// its completion.
+func addExecutionP(ctx context.Context, p redis.Pipeliner, state *instanceState, completedAt time.Time) error {
+	instanceID, executionID := state.Instance.InstanceID, state.Instance.ExecutionID
+
+	// Executions continued from an earlier one start when that one completed
+	createdAt := state.CreatedAt
+	if state.ContinuedAt != nil {
+		createdAt = *state.ContinuedAt
+	}
+
+	b, err := json.Marshal(&executionState{
+		Instance:    state.Instance,
+		Metadata:    state.Metadata,
+		CreatedAt:   createdAt,
+		CompletedAt: completedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling execution state: %w", err)
+	}
+
+	p.HSet(ctx, executionsKey(instanceID), executionID, string(b))
+	p.Rename(ctx, historyKey(instanceID), executionHistoryKey(instanceID, executionID))
+
+	state.ContinuedAt = &completedAt
+
+	return nil
+}
+
+func (rb *redisBackend) GetWorkflowExecutionHistory(ctx context.Context, instance *workflow.Instance) ([]history.Event, error) {
+	// Read all keys in a transaction, so that the instance doesn't continue as new between reading its state and
+	// its history
+	var instanceCmd *redis.StringCmd
+	var executionCmd *redis.BoolCmd
+	var historyCmd, executionHistoryCmd *redis.XMessageSliceCmd
+	_, err := rb.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		instanceCmd = readInstanceP(ctx, p, instance.InstanceID)
+		executionCmd = p.HExists(ctx, executionsKey(instance.InstanceID), instance.ExecutionID)
+		historyCmd = p.XRange(ctx, historyKey(instance.InstanceID), "-", "+")
+		executionHistoryCmd = p.XRange(ctx, executionHistoryKey(instance.InstanceID, instance.ExecutionID), "-", "+")
+
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	state, err := readInstancePipelineCmd(instanceCmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.RemovedAt != nil {
+		return nil, backend.ErrInstanceNotFound
+	}
+
+	msgsCmd := historyCmd
+	if state.Instance.ExecutionID != instance.ExecutionID {
+		if !executionCmd.Val() {
+			return nil, backend.ErrInstanceNotFound
+		}
+
+		msgsCmd = executionHistoryCmd
+	}
+
+	msgs, err := msgsCmd.Result()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]history.Event, 0, len(msgs))
+	for _, msg := range msgs {
+		var event history.Event
+		if err := json.Unmarshal([]byte(msg.Values["event"].(string)), &event); err != nil {
+			return nil, fmt.Errorf("unmarshaling event: %w", err)
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
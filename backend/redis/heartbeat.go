@@ -0,0 +1,36 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+)
+
+var _ backend.ActivityHeartbeatTimeoutRescheduler = (*redisBackend)(nil)
+
+func (rb *redisBackend) RescheduleActivityHeartbeatTimeout(ctx context.Context, instance *core.WorkflowInstance, event *history.Event) error {
+	now := rb.options.Now()
+	event = &backend.RebaseFutureEvents(now, []history.Event{*event})[0]
+
+	eventData, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if err := rescheduleFutureEventCmd.Run(
+		ctx, rb.rdb,
+		[]string{futureEventsKey(), futureEventKeyOf(instance, event)},
+		strconv.FormatInt(now.UnixMilli(), 10),
+		strconv.FormatInt(event.VisibleAt.UnixMilli(), 10),
+		string(eventData),
+	).Err(); err != nil {
+		return fmt.Errorf("rescheduling activity heartbeat timeout: %w", err)
+	}
+
+	return nil
+}
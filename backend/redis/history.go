@@ -0,0 +1,55 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+var _ backend.HistoryFilterProvider = (*redisBackend)(nil)
+
+func (rb *redisBackend) GetFilteredWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, filter backend.HistoryFilter) ([]history.Event, error) {
+	// Stream IDs are derived from sequence ids, so the range can be applied by redis. Event types are
+	// filtered after reading.
+	start, end := "-", "+"
+
+	if filter.FromSequenceID > 0 {
+		start = historyID(filter.FromSequenceID)
+	}
+
+	if filter.ToSequenceID > 0 {
+		end = historyID(filter.ToSequenceID)
+	}
+
+	p := rb.rdb.Pipeline()
+	instanceCmd := readInstanceP(ctx, p, instance.InstanceID)
+	msgsCmd := p.XRange(ctx, historyKey(instance.InstanceID), start, end)
+
+	// Errors are checked when checking the cmds
+	_, _ = p.Exec(ctx)
+
+	if err := checkNotRemoved(instanceCmd); err != nil {
+		return nil, err
+	}
+
+	msgs, err := msgsCmd.Result()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]history.Event, 0, len(msgs))
+	for _, msg := range msgs {
+		var event history.Event
+		if err := json.Unmarshal([]byte(msg.Values["event"].(string)), &event); err != nil {
+			return nil, fmt.Errorf("unmarshaling event: %w", err)
+		}
+
+		events = append(events, event)
+	}
+
+	return filter.Apply(events), nil
+}
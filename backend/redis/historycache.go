@@ -0,0 +1,191 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/go-redis/redis/v8"
+)
+
+// Cache the full history of an instance, unless it changed since the generation given as token. Returns 1 if the
+// history was cached.
+//
+// KEYS[1] - history cache list
+// KEYS[2] - history cache generation
+// ARGV[1] - token, the generation when the history was read
+// ARGV[2] - ttl in milliseconds
+// ARGV[3..n] - events
+var putHistoryCmd = redis.NewScript(`
+	local generation = tonumber(redis.call("GET", KEYS[2]) or "0")
+	if generation ~= tonumber(ARGV[1]) or redis.call("EXISTS", KEYS[1]) == 1 then
+		return 0
+	end
+
+	for i = 3, #ARGV, 1000 do
+		redis.call("RPUSH", KEYS[1], unpack(ARGV, i, math.min(i + 999, #ARGV)))
+	end
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+
+	return 1
+`)
+
+// Append events to the cached history of an instance if they directly follow the cached events, otherwise
+// remove the cached history. Returns 1 if the events were appended.
+//
+// KEYS[1] - history cache list
+// KEYS[2] - history cache generation
+// ARGV[1] - sequence id of the first appended event
+// ARGV[2] - ttl in milliseconds
+// ARGV[3..n] - events
+var appendHistoryCmd = redis.NewScript(`
+	redis.call("INCR", KEYS[2])
+	redis.call("PEXPIRE", KEYS[2], ARGV[2])
+
+	local cached = redis.call("LLEN", KEYS[1])
+	if cached == 0 or cached ~= tonumber(ARGV[1]) - 1 then
+		redis.call("DEL", KEYS[1])
+		return 0
+	end
+
+	for i = 3, #ARGV, 1000 do
+		redis.call("RPUSH", KEYS[1], unpack(ARGV, i, math.min(i + 999, #ARGV)))
+	end
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+
+	return 1
+`)
+
+type historyCache struct {
+	rdb redis.UniversalClient
+	ttl time.Duration
+}
+
+var _ backend.HistoryCache = (*historyCache)(nil)
+
+// NewHistoryCache returns a backend.HistoryCache that keeps histories of workflow instances in Redis. Histories
+// expire when they haven't been changed for the given ttl, so only actively executing instances are cached.
+//
+// Pass the returned cache to a SQL backend using backend.WithHistoryCache.
+func NewHistoryCache(client redis.UniversalClient, ttl time.Duration) (*historyCache, error) {
+	ctx := context.Background()
+	for name, cmd := range map[string]*redis.Script{"putHistoryCmd": putHistoryCmd, "appendHistoryCmd": appendHistoryCmd} {
+		if err := cmd.Load(ctx, client).Err(); err != nil {
+			return nil, fmt.Errorf("loading redis script: %v %w", name, err)
+		}
+	}
+
+	return &historyCache{
+		rdb: client,
+		ttl: ttl,
+	}, nil
+}
+
+func (c *historyCache) Get(ctx context.Context, instanceID string, afterSequenceID int64) ([]history.Event, int64, bool, error) {
+	var generation *redis.StringCmd
+	var exists *redis.IntCmd
+	var cached *redis.StringSliceCmd
+
+	// Sequence ids start at 1, so the event after afterSequenceID is at index afterSequenceID
+	_, err := c.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		generation = p.Get(ctx, historyCacheGenerationKey(instanceID))
+		exists = p.Exists(ctx, historyCacheKey(instanceID))
+		cached = p.LRange(ctx, historyCacheKey(instanceID), afterSequenceID, -1)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, 0, false, fmt.Errorf("reading cached history: %w", err)
+	}
+
+	token, err := generation.Int64()
+	if err != nil && err != redis.Nil {
+		return nil, 0, false, fmt.Errorf("reading cached history generation: %w", err)
+	}
+
+	if exists.Val() == 0 {
+		return nil, token, false, nil
+	}
+
+	events := make([]history.Event, 0, len(cached.Val()))
+	for _, data := range cached.Val() {
+		var event history.Event
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, 0, false, fmt.Errorf("unmarshaling cached event: %w", err)
+		}
+
+		events = append(events, event)
+	}
+
+	return events, token, true, nil
+}
+
+func (c *historyCache) Put(ctx context.Context, instanceID string, events []history.Event, token int64) error {
+	// Only cache contiguous histories, so that events can be looked up by their sequence id
+	for i, event := range events {
+		if event.SequenceID != int64(i+1) {
+			return nil
+		}
+	}
+
+	args, err := c.args(token, events)
+	if err != nil {
+		return err
+	}
+
+	if err := putHistoryCmd.Run(ctx, c.rdb, []string{historyCacheKey(instanceID), historyCacheGenerationKey(instanceID)}, args...).Err(); err != nil {
+		return fmt.Errorf("caching history: %w", err)
+	}
+
+	return nil
+}
+
+func (c *historyCache) Append(ctx context.Context, instanceID string, events []history.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	args, err := c.args(events[0].SequenceID, events)
+	if err != nil {
+		return err
+	}
+
+	if err := appendHistoryCmd.Run(ctx, c.rdb, []string{historyCacheKey(instanceID), historyCacheGenerationKey(instanceID)}, args...).Err(); err != nil {
+		return fmt.Errorf("appending to cached history: %w", err)
+	}
+
+	return nil
+}
+
+func (c *historyCache) Invalidate(ctx context.Context, instanceID string) error {
+	_, err := c.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Incr(ctx, historyCacheGenerationKey(instanceID))
+		p.PExpire(ctx, historyCacheGenerationKey(instanceID), c.ttl)
+		p.Del(ctx, historyCacheKey(instanceID))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("invalidating cached history: %w", err)
+	}
+
+	return nil
+}
+
+// args returns the script arguments for the given value, the ttl, and the serialized events.
+func (c *historyCache) args(value int64, events []history.Event) ([]interface{}, error) {
+	args := make([]interface{}, 0, len(events)+2)
+	args = append(args, value, c.ttl.Milliseconds())
+
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling event: %w", err)
+		}
+
+		args = append(args, string(data))
+	}
+
+	return args, nil
+}
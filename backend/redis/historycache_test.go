@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_HistoryCache(t *testing.T) {
+	// These cases rely on redis being running on localhost:6379. Skip this test if `-short` is set.
+	if testing.Short() {
+		t.Skip()
+	}
+
+	client := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs:    []string{"localhost:6379"},
+		Username: "",
+		Password: "RedisPassw0rd",
+		DB:       1,
+	})
+
+	ctx := context.Background()
+	require.NoError(t, client.FlushDB(ctx).Err())
+
+	c, err := NewHistoryCache(client, time.Minute)
+	require.NoError(t, err)
+
+	newEvents := func(from, to int64) []history.Event {
+		r := make([]history.Event, 0)
+		for i := from; i <= to; i++ {
+			r = append(r, history.NewHistoryEvent(i, time.Now(), history.EventType_TimerFired, &history.TimerFiredAttributes{}))
+		}
+
+		return r
+	}
+
+	_, token, ok, err := c.Get(ctx, "i1", 0)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, c.Put(ctx, "i1", newEvents(1, 3), token))
+
+	h, _, ok, err := c.Get(ctx, "i1", 1)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Len(t, h, 2)
+	require.Equal(t, int64(2), h[0].SequenceID)
+
+	// Appending extends the cached history
+	require.NoError(t, c.Append(ctx, "i1", newEvents(4, 5)))
+
+	h, _, ok, err = c.Get(ctx, "i1", 0)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Len(t, h, 5)
+
+	// A history read before an append is not cached
+	_, token, _, err = c.Get(ctx, "i2", 0)
+	require.NoError(t, err)
+	require.NoError(t, c.Append(ctx, "i2", newEvents(3, 3)))
+	require.NoError(t, c.Put(ctx, "i2", newEvents(1, 2), token))
+
+	_, _, ok, err = c.Get(ctx, "i2", 0)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// Appending events that don't follow the cached ones invalidates the history
+	require.NoError(t, c.Append(ctx, "i1", newEvents(7, 7)))
+
+	_, _, ok, err = c.Get(ctx, "i1", 0)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, c.Invalidate(ctx, "i1"))
+}
@@ -0,0 +1,49 @@
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/workflow"
+	"github.com/go-redis/redis/v8"
+)
+
+var _ backend.InstanceHolder = (*redisBackend)(nil)
+
+func (rb *redisBackend) PlaceWorkflowInstanceHold(ctx context.Context, instance *workflow.Instance, reason string) error {
+	return rb.updateInstance(ctx, instance.InstanceID, func(p redis.Pipeliner, state *instanceState) error {
+		state.Hold = &backend.Hold{
+			Reason:   reason,
+			PlacedAt: rb.options.Now(),
+		}
+
+		return nil
+	})
+}
+
+func (rb *redisBackend) ReleaseWorkflowInstanceHold(ctx context.Context, instance *workflow.Instance) error {
+	err := rb.updateInstance(ctx, instance.InstanceID, func(p redis.Pipeliner, state *instanceState) error {
+		if state.Hold == nil {
+			return backend.ErrInstanceNotHeld
+		}
+
+		state.Hold = nil
+
+		return nil
+	})
+	if errors.Is(err, backend.ErrInstanceNotFound) {
+		return backend.ErrInstanceNotHeld
+	}
+
+	return err
+}
+
+func (rb *redisBackend) GetWorkflowInstanceHold(ctx context.Context, instance *workflow.Instance) (*backend.Hold, error) {
+	state, err := readInstance(ctx, rb.rdb, instance.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return state.Hold, nil
+}
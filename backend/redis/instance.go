@@ -3,6 +3,7 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -14,45 +15,79 @@ import (
 )
 
 func (rb *redisBackend) CreateWorkflowInstance(ctx context.Context, instance *workflow.Instance, event history.Event) error {
-	state, err := readInstance(ctx, rb.rdb, instance.InstanceID)
-	if err != nil && err != backend.ErrInstanceNotFound {
-		return err
-	}
+	return rb.CreateWorkflowInstances(ctx, []history.WorkflowEvent{{WorkflowInstance: instance, HistoryEvent: event}})
+}
 
-	if state != nil {
-		return backend.ErrInstanceAlreadyExists
-	}
+func (rb *redisBackend) CreateWorkflowInstances(ctx context.Context, instances []history.WorkflowEvent) error {
+	keys := make([]string, 0, len(instances))
+	seen := make(map[string]bool, len(instances))
 
-	p := rb.rdb.TxPipeline()
+	for _, i := range instances {
+		instanceID := i.WorkflowInstance.InstanceID
+		if seen[instanceID] {
+			return backend.ErrInstanceAlreadyExists
+		}
+		seen[instanceID] = true
 
-	if err := createInstanceP(ctx, p, instance, event.Attributes.(*history.ExecutionStartedAttributes).Metadata, false); err != nil {
-		return err
+		keys = append(keys, instanceKey(instanceID))
 	}
 
-	// Create event stream
-	eventData, err := json.Marshal(event)
-	if err != nil {
-		return err
-	}
+	// Watch the instance keys, so that the transaction fails if any instance is created between checking for
+	// existing instances and creating them
+	err := rb.rdb.Watch(ctx, func(tx *redis.Tx) error {
+		existing, err := tx.Exists(ctx, keys...).Result()
+		if err != nil {
+			return fmt.Errorf("checking for existing instances: %w", err)
+		}
 
-	p.XAdd(ctx, &redis.XAddArgs{
-		Stream: pendingEventsKey(instance.InstanceID),
-		ID:     "*",
-		Values: map[string]interface{}{
-			"event": string(eventData),
-		},
-	})
+		if existing > 0 {
+			return backend.ErrInstanceAlreadyExists
+		}
 
-	// Queue workflow instance task
-	if err := rb.workflowQueue.Enqueue(ctx, p, instance.InstanceID, nil); err != nil {
-		return fmt.Errorf("queueing workflow task: %w", err)
-	}
+		// All instances are created in a single MULTI/EXEC transaction
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			for _, i := range instances {
+				instance, event := i.WorkflowInstance, i.HistoryEvent
+
+				a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&event)
+				if err != nil {
+					return err
+				}
+
+				if err := createInstanceP(ctx, p, instance, a.Metadata, rb.options.Now()); err != nil {
+					return err
+				}
+
+				// Create event stream
+				if err := rb.addPendingEventP(ctx, p, instance.InstanceID, &event); err != nil {
+					return err
+				}
+
+				// Queue workflow instance task
+				if err := rb.workflowQueue.Enqueue(ctx, p, instance.InstanceID, nil); err != nil {
+					return fmt.Errorf("queueing workflow task: %w", err)
+				}
+			}
+
+			return nil
+		})
+
+		return err
+	}, keys...)
+	if err != nil {
+		if errors.Is(err, redis.TxFailedErr) {
+			// One of the instances has been created concurrently
+			return backend.ErrInstanceAlreadyExists
+		}
+
+		if errors.Is(err, backend.ErrInstanceAlreadyExists) {
+			return err
+		}
 
-	if _, err := p.Exec(ctx); err != nil {
 		return fmt.Errorf("creating workflow instance: %w", err)
 	}
 
-	rb.options.Logger.Debug("Created new workflow instance")
+	rb.options.Logger.Debug("Created new workflow instances", "count", len(instances))
 
 	return nil
 }
@@ -64,7 +99,18 @@ func (rb *redisBackend) GetWorkflowInstanceHistory(ctx context.Context, instance
 		start = "(" + historyID(*lastSequenceID)
 	}
 
-	msgs, err := rb.rdb.XRange(ctx, historyKey(instance.InstanceID), start, "+").Result()
+	p := rb.rdb.Pipeline()
+	instanceCmd := readInstanceP(ctx, p, instance.InstanceID)
+	msgsCmd := p.XRange(ctx, historyKey(instance.InstanceID), start, "+")
+
+	// Errors are checked when checking the cmds
+	_, _ = p.Exec(ctx)
+
+	if err := checkNotRemoved(instanceCmd); err != nil {
+		return nil, err
+	}
+
+	msgs, err := msgsCmd.Result()
 	if err != nil {
 		return nil, err
 	}
@@ -88,6 +134,10 @@ func (rb *redisBackend) GetWorkflowInstanceState(ctx context.Context, instance *
 		return core.WorkflowInstanceStateActive, err
 	}
 
+	if instanceState.RemovedAt != nil {
+		return core.WorkflowInstanceStateActive, backend.ErrInstanceNotFound
+	}
+
 	return instanceState.State, nil
 }
 
@@ -118,14 +168,27 @@ type instanceState struct {
 	CreatedAt   time.Time  `json:"created_at,omitempty"`
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
 
+	// ContinuedAt is the time the current execution was started, if the instance continued as new.
+	ContinuedAt *time.Time `json:"continued_at,omitempty"`
+
+	// RemovedAt is the time the instance was removed, nil if it hasn't been removed.
+	RemovedAt *time.Time `json:"removed_at,omitempty"`
+
+	// Hold is the legal or compliance hold on the instance, nil if it isn't held.
+	Hold *backend.Hold `json:"hold,omitempty"`
+
 	LastSequenceID int64 `json:"last_sequence_id,omitempty"`
+
+	// NextActivityAt is the earliest time the next activity of the instance becomes visible, if activities are
+	// throttled.
+	NextActivityAt *time.Time `json:"next_activity_at,omitempty"`
 }
 
-func createInstanceP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, metadata *core.WorkflowMetadata, ignoreDuplicate bool) error {
+func createInstanceP(
+	ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, metadata *core.WorkflowMetadata, createdAt time.Time,
+) error {
 	key := instanceKey(instance.InstanceID)
 
-	createdAt := time.Now()
-
 	b, err := json.Marshal(&instanceState{
 		Instance:  instance,
 		State:     core.WorkflowInstanceStateActive,
@@ -12,14 +12,34 @@ func instancesByCreation() string {
 	return "instances-by-creation"
 }
 
+// removedInstancesKey is the set of removed instances, scored by the time they were removed.
+func removedInstancesKey() string {
+	return "removed-instances"
+}
+
 func pendingEventsKey(instanceID string) string {
 	return fmt.Sprintf("pending-events:%v", instanceID)
 }
 
+// eventIDsKey is the set of IDs of all events added to the instance, used to deduplicate events.
+func eventIDsKey(instanceID string) string {
+	return fmt.Sprintf("event-ids:%v", instanceID)
+}
+
 func historyKey(instanceID string) string {
 	return fmt.Sprintf("history:%v", instanceID)
 }
 
+// executionsKey is the hash of the executions the instance continued from, keyed by execution ID.
+func executionsKey(instanceID string) string {
+	return fmt.Sprintf("executions:%v", instanceID)
+}
+
+// executionHistoryKey is the history of an execution the instance continued from.
+func executionHistoryKey(instanceID, executionID string) string {
+	return fmt.Sprintf("execution-history:%v:%v", instanceID, executionID)
+}
+
 func historyID(sequenceID int64) string {
 	return fmt.Sprintf("%v-0", sequenceID)
 }
@@ -28,6 +48,43 @@ func futureEventsKey() string {
 	return "future-events"
 }
 
+func instanceFutureEventsKey(instanceID string) string {
+	return fmt.Sprintf("instance-future-events:%v", instanceID)
+}
+
 func futureEventKey(instanceID string, scheduleEventID int64) string {
 	return fmt.Sprintf("future-event:%v:%v", instanceID, scheduleEventID)
 }
+
+// heartbeatTimeoutKey is the key of the heartbeat timeout of an activity, which is scheduled alongside its
+// start-to-close timeout for the same schedule event id.
+func heartbeatTimeoutKey(instanceID string, scheduleEventID int64) string {
+	return fmt.Sprintf("future-event:%v:%v:heartbeat", instanceID, scheduleEventID)
+}
+
+func activityRateLimitKey(activityName string) string {
+	return fmt.Sprintf("rate-limit:activity:%v", activityName)
+}
+
+func workflowConcurrencyKey(workflowName string) string {
+	return fmt.Sprintf("concurrency:workflow:%v", workflowName)
+}
+
+func leaseKey(name string) string {
+	return fmt.Sprintf("lease:%v", name)
+}
+
+// historyCacheKey is the list of cached history events of an instance, see NewHistoryCache.
+func historyCacheKey(instanceID string) string {
+	return fmt.Sprintf("history-cache:%v", instanceID)
+}
+
+// historyCacheGenerationKey is incremented whenever the history of an instance changes, to prevent caching
+// histories read before the change.
+func historyCacheGenerationKey(instanceID string) string {
+	return fmt.Sprintf("history-cache-generation:%v", instanceID)
+}
+
+func prefetchHintsKey() string {
+	return "prefetch-hints"
+}
@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/go-redis/redis/v8"
+)
+
+var _ backend.Leaser = (*redisBackend)(nil)
+
+// Acquire or extend a lease. Returns 1 if the lease is held by the given owner.
+//
+// KEYS[1] - lease key
+// ARGV[1] - owner
+// ARGV[2] - ttl in milliseconds
+var acquireLeaseCmd = redis.NewScript(`
+	local owner = redis.call("GET", KEYS[1])
+	if owner ~= false and owner ~= ARGV[1] then
+		return 0
+	end
+
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+`)
+
+// Release a lease if it's held by the given owner.
+//
+// KEYS[1] - lease key
+// ARGV[1] - owner
+var releaseLeaseCmd = redis.NewScript(`
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		redis.call("DEL", KEYS[1])
+	end
+
+	return 0
+`)
+
+func (rb *redisBackend) AcquireLease(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	r, err := acquireLeaseCmd.Run(ctx, rb.rdb, []string{leaseKey(name)}, owner, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, fmt.Errorf("acquiring lease: %w", err)
+	}
+
+	return r == 1, nil
+}
+
+func (rb *redisBackend) ReleaseLease(ctx context.Context, name, owner string) error {
+	if err := releaseLeaseCmd.Run(ctx, rb.rdb, []string{leaseKey(name)}, owner).Err(); err != nil {
+		return fmt.Errorf("releasing lease: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/workflow"
+	"github.com/go-redis/redis/v8"
+)
+
+var _ backend.PrefetchHinter = (*redisBackend)(nil)
+
+func (rb *redisBackend) AddPrefetchHints(ctx context.Context, instances []*workflow.Instance, until time.Time) error {
+	if len(instances) == 0 {
+		return nil
+	}
+
+	members := make([]*redis.Z, 0, len(instances))
+	for _, instance := range instances {
+		member, err := json.Marshal(instance)
+		if err != nil {
+			return fmt.Errorf("marshaling instance: %w", err)
+		}
+
+		members = append(members, &redis.Z{Score: float64(until.UnixMilli()), Member: string(member)})
+	}
+
+	if err := rb.rdb.ZAdd(ctx, prefetchHintsKey(), members...).Err(); err != nil {
+		return fmt.Errorf("adding prefetch hints: %w", err)
+	}
+
+	return nil
+}
+
+func (rb *redisBackend) PrefetchHints(ctx context.Context) ([]*workflow.Instance, error) {
+	now := strconv.FormatInt(rb.options.Now().UnixMilli(), 10)
+
+	if err := rb.rdb.ZRemRangeByScore(ctx, prefetchHintsKey(), "-inf", now).Err(); err != nil {
+		return nil, fmt.Errorf("removing expired prefetch hints: %w", err)
+	}
+
+	members, err := rb.rdb.ZRangeByScore(ctx, prefetchHintsKey(), &redis.ZRangeBy{Min: "(" + now, Max: "+inf"}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("getting prefetch hints: %w", err)
+	}
+
+	instances := make([]*workflow.Instance, 0, len(members))
+	for _, member := range members {
+		var instance workflow.Instance
+		if err := json.Unmarshal([]byte(member), &instance); err != nil {
+			return nil, fmt.Errorf("unmarshaling instance: %w", err)
+		}
+
+		instances = append(instances, &instance)
+	}
+
+	return instances, nil
+}
@@ -14,8 +14,15 @@ type taskQueue[T any] struct {
 	tasktype   string
 	setKey     string
 	streamKey  string
+	delayedKey string
 	groupName  string
 	workerName string
+
+	// onRecover, if set, is called whenever an abandoned task is taken over from another worker
+	onRecover func()
+
+	// visibleBefore, if set, returns the time up to which delayed tasks are made available. Defaults to now.
+	visibleBefore func() time.Time
 }
 
 type TaskItem[T any] struct {
@@ -39,6 +46,7 @@ func newTaskQueue[T any](rdb redis.UniversalClient, tasktype string) (*taskQueue
 		tasktype:   tasktype,
 		setKey:     "task-set:" + tasktype,
 		streamKey:  "task-stream:" + tasktype,
+		delayedKey: "task-delayed:" + tasktype,
 		groupName:  "task-workers",
 		workerName: uuid.NewString(),
 	}
@@ -55,8 +63,9 @@ func newTaskQueue[T any](rdb redis.UniversalClient, tasktype string) (*taskQueue
 
 	// Pre-load script
 	cmds := map[string]*redis.StringCmd{
-		"enqueueCmd":  enqueueCmd.Load(context.Background(), rdb),
-		"completeCmd": completeCmd.Load(context.Background(), rdb),
+		"enqueueCmd":        enqueueCmd.Load(context.Background(), rdb),
+		"completeCmd":       completeCmd.Load(context.Background(), rdb),
+		"promoteDelayedCmd": promoteDelayedCmd.Load(context.Background(), rdb),
 	}
 
 	for name, cmd := range cmds {
@@ -102,7 +111,60 @@ func (q *taskQueue[T]) Enqueue(ctx context.Context, p redis.Pipeliner, id string
 	return nil
 }
 
+type delayedTask struct {
+	ID   string `json:"id"`
+	Data string `json:"data"`
+}
+
+// EnqueueAt adds a task that can only be dequeued once visibleAt has passed.
+func (q *taskQueue[T]) EnqueueAt(ctx context.Context, p redis.Pipeliner, id string, data *T, visibleAt time.Time) error {
+	ds, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	member, err := json.Marshal(&delayedTask{ID: id, Data: string(ds)})
+	if err != nil {
+		return err
+	}
+
+	return p.ZAdd(ctx, q.delayedKey, &redis.Z{
+		Score:  float64(visibleAt.UnixMilli()),
+		Member: string(member),
+	}).Err()
+}
+
+// Moves delayed tasks that have become visible to the stream, preventing duplicates like enqueueCmd
+// KEYS[1] = delayed set
+// KEYS[2] = set
+// KEYS[3] = stream
+// ARGV[1] = current timestamp in milliseconds
+var promoteDelayedCmd = redis.NewScript(
+	`local due = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, 100)
+	for _, member in ipairs(due) do
+		local task = cjson.decode(member)
+		local added = redis.call("SADD", KEYS[2], task.id)
+		if added == 1 then
+			redis.call("XADD", KEYS[3], "*", "id", task.id, "data", task.data)
+		end
+
+		redis.call("ZREM", KEYS[1], member)
+	end
+
+	return #due
+`)
+
 func (q *taskQueue[T]) Dequeue(ctx context.Context, rdb redis.UniversalClient, lockTimeout, timeout time.Duration) (*TaskItem[T], error) {
+	// Make delayed tasks that have become visible available
+	visibleBefore := time.Now()
+	if q.visibleBefore != nil {
+		visibleBefore = q.visibleBefore()
+	}
+
+	if err := promoteDelayedCmd.Run(ctx, rdb, []string{q.delayedKey, q.setKey, q.streamKey}, visibleBefore.UnixMilli()).Err(); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("promoting delayed tasks: %w", err)
+	}
+
 	// Try to recover abandoned messages
 	task, err := q.recover(ctx, rdb, lockTimeout)
 	if err != nil {
@@ -209,6 +271,10 @@ func (q *taskQueue[T]) recover(ctx context.Context, rdb redis.UniversalClient, i
 		return nil, nil
 	}
 
+	if q.onRecover != nil {
+		q.onRecover()
+	}
+
 	return msgToTaskItem[T](&msgs[0])
 }
 
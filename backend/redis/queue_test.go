@@ -237,6 +237,30 @@ func Test_TaskQueue(t *testing.T) {
 				require.Nil(t, recoveredTask)
 			},
 		},
+		{
+			name: "Delayed task",
+			f: func(t *testing.T) {
+				q, _ := newTaskQueue[any](client, "test")
+
+				ctx := context.Background()
+
+				_, err := client.Pipelined(ctx, func(p redis.Pipeliner) error {
+					return q.EnqueueAt(ctx, p, "t1", nil, time.Now().Add(time.Millisecond*50))
+				})
+				require.NoError(t, err)
+
+				task, err := q.Dequeue(ctx, client, lockTimeout, blockTimeout)
+				require.NoError(t, err)
+				require.Nil(t, task)
+
+				time.Sleep(time.Millisecond * 50)
+
+				task, err = q.Dequeue(ctx, client, lockTimeout, blockTimeout)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, "t1", task.ID)
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
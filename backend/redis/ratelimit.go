@@ -0,0 +1,89 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Count an execution against the current rate limit window. Returns 0 if the execution is allowed, otherwise
+// the number of milliseconds until the current window expires.
+//
+// KEYS[1] - rate limit window key
+// ARGV[1] - maximum number of executions per window
+// ARGV[2] - window length in milliseconds
+var activityRateLimitCmd = redis.NewScript(`
+	local count = redis.call("INCR", KEYS[1])
+	if count == 1 or redis.call("PTTL", KEYS[1]) < 0 then
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	end
+
+	if count > tonumber(ARGV[1]) then
+		return redis.call("PTTL", KEYS[1])
+	end
+
+	return 0
+`)
+
+type activityRateLimiter struct {
+	rdb    redis.UniversalClient
+	window time.Duration
+
+	mu     sync.RWMutex
+	limits map[string]int
+}
+
+// NewActivityRateLimiter returns a rate limiter which enforces the given limits (executions per second, keyed by
+// activity name) across all workers sharing the same Redis instance. Activities without a limit are not throttled.
+//
+// Pass the returned limiter as `ActivityRateLimiter` in the worker options.
+func NewActivityRateLimiter(client redis.UniversalClient, limits map[string]int) (*activityRateLimiter, error) {
+	if err := activityRateLimitCmd.Load(context.Background(), client).Err(); err != nil {
+		return nil, fmt.Errorf("loading redis script: %w", err)
+	}
+
+	return &activityRateLimiter{
+		rdb:    client,
+		limits: limits,
+		window: time.Second,
+	}, nil
+}
+
+// SetLimits replaces the limits of this limiter. It's safe to call while activities are being executed.
+func (rl *activityRateLimiter) SetLimits(limits map[string]int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.limits = limits
+}
+
+func (rl *activityRateLimiter) Wait(ctx context.Context, activityName string) error {
+	rl.mu.RLock()
+	limit, ok := rl.limits[activityName]
+	rl.mu.RUnlock()
+
+	if !ok || limit <= 0 {
+		return nil
+	}
+
+	for {
+		wait, err := activityRateLimitCmd.Run(
+			ctx, rl.rdb, []string{activityRateLimitKey(activityName)}, limit, rl.window.Milliseconds()).Int64()
+		if err != nil {
+			return fmt.Errorf("checking activity rate limit: %w", err)
+		}
+
+		if wait <= 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(wait) * time.Millisecond):
+		}
+	}
+}
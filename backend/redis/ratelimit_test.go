@@ -0,0 +1,50 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ActivityRateLimiter(t *testing.T) {
+	// These cases rely on redis being running on localhost:6379. Skip this test if `-short` is set.
+	if testing.Short() {
+		t.Skip()
+	}
+
+	client := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs:    []string{"localhost:6379"},
+		Username: "",
+		Password: "RedisPassw0rd",
+		DB:       1,
+	})
+
+	ctx := context.Background()
+	require.NoError(t, client.FlushDB(ctx).Err())
+
+	rl, err := NewActivityRateLimiter(client, map[string]int{"limited": 2})
+	require.NoError(t, err)
+
+	// Unlimited activities never wait
+	for i := 0; i < 10; i++ {
+		require.NoError(t, rl.Wait(ctx, "unlimited"))
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, rl.Wait(ctx, "limited"))
+	}
+
+	// The third execution has to wait for the next window
+	require.Greater(t, time.Since(start), time.Millisecond*500)
+
+	// Waiting respects context cancellation
+	cctx, cancel := context.WithTimeout(ctx, time.Millisecond*10)
+	defer cancel()
+
+	require.NoError(t, rl.Wait(cctx, "limited"))
+	require.ErrorIs(t, rl.Wait(cctx, "limited"), context.DeadlineExceeded)
+}
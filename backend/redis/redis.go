@@ -3,11 +3,13 @@ package redis
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/cschleiden/go-workflows/backend"
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/logger"
 	"github.com/cschleiden/go-workflows/internal/metrickeys"
 	"github.com/cschleiden/go-workflows/log"
 	"github.com/cschleiden/go-workflows/metrics"
@@ -19,6 +21,10 @@ type RedisOptions struct {
 	backend.Options
 
 	BlockTimeout time.Duration
+
+	// EventDeduplicationWindow is how long the IDs of the events of an instance are kept to deduplicate events
+	// delivered more than once, after the last event was added to the instance.
+	EventDeduplicationWindow time.Duration
 }
 
 type RedisBackendOption func(*RedisOptions)
@@ -29,6 +35,15 @@ func WithBlockTimeout(timeout time.Duration) RedisBackendOption {
 	}
 }
 
+// WithEventDeduplicationWindow sets how long the IDs of the events of an instance are kept after the last event
+// was added to it. Events delivered again after that, e.g., by a request retried much later, are not deduplicated.
+// Defaults to 24 hours.
+func WithEventDeduplicationWindow(window time.Duration) RedisBackendOption {
+	return func(o *RedisOptions) {
+		o.EventDeduplicationWindow = window
+	}
+}
+
 func WithBackendOptions(opts ...backend.BackendOption) RedisBackendOption {
 	return func(o *RedisOptions) {
 		for _, opt := range opts {
@@ -52,32 +67,49 @@ func NewRedisBackend(client redis.UniversalClient, opts ...RedisBackendOption) (
 
 	// Default options
 	options := &RedisOptions{
-		Options:      backend.ApplyOptions(),
-		BlockTimeout: time.Second * 2,
+		Options:                  backend.ApplyOptions(),
+		BlockTimeout:             time.Second * 2,
+		EventDeduplicationWindow: time.Hour * 24,
 	}
 
 	for _, opt := range opts {
 		opt(options)
 	}
 
+	if options.Redactor != nil {
+		options.Logger = logger.NewRedactingLogger(options.Logger, options.Redactor)
+	}
+
 	rb := &redisBackend{
 		rdb:     client,
 		options: options,
 
-		workflowQueue: workflowQueue,
-		activityQueue: activityQueue,
+		workflowQueue:  workflowQueue,
+		activityQueue:  activityQueue,
+		activityQueues: map[string]*taskQueue[activityData]{},
+	}
+
+	// Tasks are only recovered when their lock expired, count these as lock contention
+	workflowQueue.onRecover = func() {
+		rb.Metrics().Counter(metrickeys.BackendLockContention, metrics.Tags{metrickeys.Operation: "workflow_task"}, 1)
 	}
+	rb.initActivityQueue(activityQueue)
+
+	workflowQueue.visibleBefore = rb.visibleBefore
 
 	// Preload scripts here. Usually redis-go attempts to execute them first, and the if redis doesn't know
 	// them, loads them. This doesn't work when using (transactional) pipelines, so eagerly load them on startup.
 	ctx := context.Background()
 	cmds := map[string]*redis.StringCmd{
-		"addEventsToStreamCmd":   addEventsToStreamCmd.Load(ctx, rb.rdb),
-		"addFutureEventCmd":      addFutureEventCmd.Load(ctx, rb.rdb),
-		"futureEventsCmd":        futureEventsCmd.Load(ctx, rb.rdb),
-		"removeFutureEventCmd":   removeFutureEventCmd.Load(ctx, rb.rdb),
-		"removePendingEventsCmd": removePendingEventsCmd.Load(ctx, rb.rdb),
-		"requeueInstanceCmd":     requeueInstanceCmd.Load(ctx, rb.rdb),
+		"addEventsToStreamCmd":     addEventsToStreamCmd.Load(ctx, rb.rdb),
+		"addFutureEventCmd":        addFutureEventCmd.Load(ctx, rb.rdb),
+		"addPendingEventCmd":       addPendingEventCmd.Load(ctx, rb.rdb),
+		"futureEventsCmd":          futureEventsCmd.Load(ctx, rb.rdb),
+		"removeFutureEventCmd":     removeFutureEventCmd.Load(ctx, rb.rdb),
+		"removeFutureEventsCmd":    removeFutureEventsCmd.Load(ctx, rb.rdb),
+		"removePendingEventsCmd":   removePendingEventsCmd.Load(ctx, rb.rdb),
+		"requeueInstanceCmd":       requeueInstanceCmd.Load(ctx, rb.rdb),
+		"rescheduleFutureEventCmd": rescheduleFutureEventCmd.Load(ctx, rb.rdb),
 	}
 	for name, cmd := range cmds {
 		// fmt.Println(name, cmd.Val())
@@ -95,7 +127,23 @@ type redisBackend struct {
 	options *RedisOptions
 
 	workflowQueue *taskQueue[any]
+
+	// activityQueue is the queue of activities without a queue in their options
 	activityQueue *taskQueue[activityData]
+
+	// activityQueues holds the task queues of other activity queues, created when first used
+	activityQueues   map[string]*taskQueue[activityData]
+	activityQueuesMu sync.Mutex
+
+	// activityTaskQueues maps the IDs of activity tasks returned by GetActivityTask to their task queue, if
+	// that's not activityQueue
+	activityTaskQueues sync.Map
+}
+
+// visibleBefore returns the time up to which delayed tasks and future events are visible. Lock expiry is
+// evaluated by redis itself, so it's not affected by clock skew between workers.
+func (rb *redisBackend) visibleBefore() time.Time {
+	return rb.options.Now()
 }
 
 type activityData struct {
@@ -108,6 +156,18 @@ func (rb *redisBackend) Logger() log.Logger {
 	return rb.options.Logger
 }
 
+var _ backend.RedactorProvider = (*redisBackend)(nil)
+
+func (rb *redisBackend) Redactor() backend.Redactor {
+	return rb.options.Redactor
+}
+
+var _ backend.Clock = (*redisBackend)(nil)
+
+func (rb *redisBackend) Now() time.Time {
+	return rb.options.Now()
+}
+
 func (rb *redisBackend) Metrics() metrics.Client {
 	return rb.options.Metrics.WithTags(metrics.Tags{metrickeys.Backend: "redis"})
 }
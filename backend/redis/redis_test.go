@@ -10,9 +10,12 @@ import (
 
 	"github.com/cschleiden/go-workflows/backend"
 	"github.com/cschleiden/go-workflows/backend/test"
+	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/log"
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -58,6 +61,84 @@ func Test_EndToEndRedisBackend(t *testing.T) {
 	test.EndToEndBackendTest(t, setup, nil)
 }
 
+func Test_EventIDsExpire(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	ctx := context.Background()
+	client := getClient()
+	require.NoError(t, client.FlushDB(ctx).Err())
+
+	b, err := NewRedisBackend(client, WithBlockTimeout(time.Millisecond*10), WithEventDeduplicationWindow(time.Minute))
+	require.NoError(t, err)
+
+	instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	err = b.CreateWorkflowInstance(ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+	require.NoError(t, err)
+
+	ttl, err := client.PTTL(ctx, eventIDsKey(instance.InstanceID)).Result()
+	require.NoError(t, err)
+	require.Greater(t, ttl, time.Duration(0))
+	require.LessOrEqual(t, ttl, time.Minute)
+}
+
+func Test_ContinueAsNew_KeepsPendingSignalsAndCancellations(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	ctx := context.Background()
+	client := getClient()
+	b := getCreateBackend(client, true)().(*redisBackend)
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.CreateWorkflowInstance(
+		ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	))
+
+	tk, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+
+	// The signal and cancellation arrive while the task continuing the instance is running
+	signalEvent := history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{Name: "signal"})
+	require.NoError(t, b.SignalWorkflow(ctx, wfi.InstanceID, signalEvent))
+
+	cancelEvent := history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionCanceled, &history.ExecutionCanceledAttributes{})
+	require.NoError(t, b.CancelWorkflowInstance(ctx, wfi, &cancelEvent))
+
+	newInstance := core.NewWorkflowInstance(wfi.InstanceID, uuid.NewString())
+	executedEvents := append(tk.NewEvents, history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{
+		ContinuedAsNew: newInstance.ExecutionID,
+	}))
+	for i := range executedEvents {
+		executedEvents[i].SequenceID = int64(i + 1)
+	}
+
+	require.NoError(t, b.CompleteWorkflowTask(
+		ctx, tk, wfi, core.WorkflowInstanceStateFinished, executedEvents, []history.Event{}, []history.Event{},
+		[]history.WorkflowEvent{{
+			WorkflowInstance: newInstance,
+			HistoryEvent:     history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+		}},
+	))
+
+	// The new execution receives the signal, and is canceled after it started
+	tk, err = b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, tk)
+	require.Equal(t, newInstance.ExecutionID, tk.WorkflowInstance.ExecutionID)
+	require.Len(t, tk.NewEvents, 3)
+	require.Equal(t, history.EventType_SignalReceived, tk.NewEvents[0].Type)
+	require.Equal(t, history.EventType_WorkflowExecutionStarted, tk.NewEvents[1].Type)
+	require.Equal(t, history.EventType_WorkflowExecutionCanceled, tk.NewEvents[2].Type)
+
+	// The history of the previous execution is kept
+	h, err := b.GetWorkflowExecutionHistory(ctx, wfi)
+	require.NoError(t, err)
+	require.Len(t, h, len(executedEvents))
+}
+
 func getClient() redis.UniversalClient {
 	client := redis.NewUniversalClient(&redis.UniversalOptions{
 		Addrs:    []string{address},
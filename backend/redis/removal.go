@@ -0,0 +1,225 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/workflow"
+	"github.com/go-redis/redis/v8"
+)
+
+var _ backend.InstanceRemover = (*redisBackend)(nil)
+
+func (rb *redisBackend) RemoveWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	return rb.updateInstance(ctx, instance.InstanceID, func(p redis.Pipeliner, state *instanceState) error {
+		if state.RemovedAt != nil {
+			return backend.ErrInstanceNotFound
+		}
+
+		if state.CompletedAt == nil {
+			return backend.ErrInstanceNotFinished
+		}
+
+		if state.Hold != nil {
+			return backend.ErrInstanceHeld
+		}
+
+		now := rb.options.Now()
+		state.RemovedAt = &now
+
+		p.ZAdd(ctx, removedInstancesKey(), &redis.Z{Score: float64(now.UnixMilli()), Member: instance.InstanceID})
+
+		return nil
+	})
+}
+
+func (rb *redisBackend) UndeleteWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	return rb.updateInstance(ctx, instance.InstanceID, func(p redis.Pipeliner, state *instanceState) error {
+		if state.RemovedAt == nil {
+			return backend.ErrInstanceNotFound
+		}
+
+		state.RemovedAt = nil
+
+		p.ZRem(ctx, removedInstancesKey(), instance.InstanceID)
+
+		return nil
+	})
+}
+
+func (rb *redisBackend) PurgeRemovedWorkflowInstances(ctx context.Context, removedBefore time.Time) (int, error) {
+	instanceIDs, err := rb.rdb.ZRangeByScore(ctx, removedInstancesKey(), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: "(" + strconv.FormatInt(removedBefore.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("listing removed workflow instances: %w", err)
+	}
+
+	purged := make(map[string]bool, len(instanceIDs))
+	for _, instanceID := range instanceIDs {
+		ok, err := rb.purgeInstance(ctx, instanceID)
+		if err != nil {
+			return len(purged), fmt.Errorf("purging workflow instance %s: %w", instanceID, err)
+		}
+
+		if ok {
+			purged[instanceID] = true
+		}
+	}
+
+	if len(purged) > 0 {
+		if err := rb.removePrefetchHints(ctx, purged); err != nil {
+			return len(purged), err
+		}
+	}
+
+	return len(purged), nil
+}
+
+// purgeInstance deletes all data of the given removed instance, unless it's on hold. It returns whether the
+// instance was purged.
+func (rb *redisBackend) purgeInstance(ctx context.Context, instanceID string) (bool, error) {
+	purged := false
+
+	err := rb.rdb.Watch(ctx, func(tx *redis.Tx) error {
+		state, err := readInstancePipelineCmd(tx.Get(ctx, instanceKey(instanceID)))
+		if err != nil && !errors.Is(err, backend.ErrInstanceNotFound) {
+			return err
+		}
+
+		if state != nil && (state.RemovedAt == nil || state.Hold != nil) {
+			// Restored or placed on hold since it was listed, held instances stay in the set of removed instances
+			// and are purged once the hold is released
+			return nil
+		}
+
+		executionIDs, err := tx.HKeys(ctx, executionsKey(instanceID)).Result()
+		if err != nil {
+			return fmt.Errorf("listing executions: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			keys := []string{
+				instanceKey(instanceID),
+				pendingEventsKey(instanceID),
+				eventIDsKey(instanceID),
+				historyKey(instanceID),
+				executionsKey(instanceID),
+			}
+			for _, executionID := range executionIDs {
+				keys = append(keys, executionHistoryKey(instanceID, executionID))
+			}
+
+			p.Del(ctx, keys...)
+			p.ZRem(ctx, instancesByCreation(), instanceID)
+			p.ZRem(ctx, removedInstancesKey(), instanceID)
+
+			if state != nil {
+				removeFutureEventsP(ctx, p, state.Instance)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		purged = state != nil
+
+		return nil
+	}, instanceKey(instanceID))
+
+	return purged, err
+}
+
+// removePrefetchHints removes the prefetch hints of the given instances.
+func (rb *redisBackend) removePrefetchHints(ctx context.Context, instanceIDs map[string]bool) error {
+	members, err := rb.rdb.ZRange(ctx, prefetchHintsKey(), 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("getting prefetch hints: %w", err)
+	}
+
+	var removed []interface{}
+	for _, member := range members {
+		var instance workflow.Instance
+		if err := json.Unmarshal([]byte(member), &instance); err != nil {
+			return fmt.Errorf("unmarshaling instance: %w", err)
+		}
+
+		if instanceIDs[instance.InstanceID] {
+			removed = append(removed, member)
+		}
+	}
+
+	if len(removed) == 0 {
+		return nil
+	}
+
+	if err := rb.rdb.ZRem(ctx, prefetchHintsKey(), removed...).Err(); err != nil {
+		return fmt.Errorf("removing prefetch hints: %w", err)
+	}
+
+	return nil
+}
+
+// updateInstanceMaxAttempts is how often updating an instance is attempted when it's changed concurrently.
+const updateInstanceMaxAttempts = 3
+
+// updateInstance calls fn with the state of the given instance, and stores the state after fn returns. Commands
+// fn adds to the pipeline are executed in the same transaction. The transaction fails, and is retried, if the
+// instance is changed concurrently.
+func (rb *redisBackend) updateInstance(
+	ctx context.Context, instanceID string, fn func(p redis.Pipeliner, state *instanceState) error,
+) error {
+	key := instanceKey(instanceID)
+
+	var err error
+	for attempt := 1; attempt <= updateInstanceMaxAttempts; attempt++ {
+		err = rb.rdb.Watch(ctx, func(tx *redis.Tx) error {
+			state, err := readInstancePipelineCmd(tx.Get(ctx, key))
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				if err := fn(p, state); err != nil {
+					return err
+				}
+
+				return updateInstanceP(ctx, p, instanceID, state)
+			})
+
+			return err
+		}, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+
+	return err
+}
+
+// checkNotRemoved returns ErrInstanceNotFound if the instance read by cmd has been removed. Instances that don't
+// exist are not reported, reading their history returns no events.
+func checkNotRemoved(cmd *redis.StringCmd) error {
+	state, err := readInstancePipelineCmd(cmd)
+	if err != nil {
+		if errors.Is(err, backend.ErrInstanceNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	if state.RemovedAt != nil {
+		return backend.ErrInstanceNotFound
+	}
+
+	return nil
+}
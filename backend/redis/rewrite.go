@@ -0,0 +1,221 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/historycache"
+	"github.com/go-redis/redis/v8"
+)
+
+var _ backend.PayloadRewriter = (*redisBackend)(nil)
+
+const (
+	rewriteBatchSize   = 100
+	rewriteMaxAttempts = 3
+)
+
+// RewritePayloads implements backend.PayloadRewriter. It updates the history, the histories of executions the
+// instance continued from, the pending events, and the future events of every instance, one instance at a time.
+// Stream entries can't be updated in place, so streams with changed events are replaced with a copy that keeps
+// the IDs of their entries. Activity tasks that are already queued are not rewritten, keep the keys they are
+// encrypted with until they have been executed.
+func (rb *redisBackend) RewritePayloads(ctx context.Context, rewrite backend.PayloadRewriteFunc) (int, error) {
+	updated := 0
+
+	for offset := int64(0); ; offset += rewriteBatchSize {
+		instanceIDs, err := rb.rdb.ZRange(ctx, instancesByCreation(), offset, offset+rewriteBatchSize-1).Result()
+		if err != nil {
+			return updated, fmt.Errorf("listing instances: %w", err)
+		}
+
+		for _, instanceID := range instanceIDs {
+			n, err := rb.rewriteInstance(ctx, instanceID, rewrite)
+			updated += n
+			if err != nil {
+				return updated, fmt.Errorf("rewriting instance %s: %w", instanceID, err)
+			}
+		}
+
+		if len(instanceIDs) < rewriteBatchSize {
+			break
+		}
+	}
+
+	return updated, nil
+}
+
+func (rb *redisBackend) rewriteInstance(ctx context.Context, instanceID string, rewrite backend.PayloadRewriteFunc) (int, error) {
+	executionIDs, err := rb.rdb.HKeys(ctx, executionsKey(instanceID)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("listing executions: %w", err)
+	}
+
+	streams := []string{historyKey(instanceID), pendingEventsKey(instanceID)}
+	for _, executionID := range executionIDs {
+		streams = append(streams, executionHistoryKey(instanceID, executionID))
+	}
+
+	updated := 0
+
+	for _, key := range streams {
+		n, err := rb.rewriteStream(ctx, key, rewrite)
+		updated += n
+		if err != nil {
+			return updated, err
+		}
+
+		if n > 0 && key == historyKey(instanceID) {
+			historycache.Invalidate(ctx, rb.options.HistoryCache, rb.Logger(), instanceID)
+		}
+	}
+
+	futureEvents, err := rb.rdb.SMembers(ctx, instanceFutureEventsKey(instanceID)).Result()
+	if err != nil {
+		return updated, fmt.Errorf("listing future events: %w", err)
+	}
+
+	for _, key := range futureEvents {
+		changed, err := rb.rewriteFutureEvent(ctx, key, rewrite)
+		if err != nil {
+			return updated, err
+		}
+
+		if changed {
+			updated++
+		}
+	}
+
+	return updated, nil
+}
+
+// rewriteStream replaces the given event stream with a copy with rewritten payloads, if any changed. The copy
+// keeps the IDs of the entries and the last generated ID of the stream, so that readers holding entry IDs, e.g.,
+// workflow tasks removing the pending events they executed, and new entries are not affected.
+func (rb *redisBackend) rewriteStream(ctx context.Context, key string, rewrite backend.PayloadRewriteFunc) (int, error) {
+	var updated int
+	var err error
+
+	for attempt := 1; attempt <= rewriteMaxAttempts; attempt++ {
+		updated = 0
+
+		err = rb.rdb.Watch(ctx, func(tx *redis.Tx) error {
+			msgs, err := tx.XRange(ctx, key, "-", "+").Result()
+			if err != nil {
+				return fmt.Errorf("reading stream %s: %w", key, err)
+			}
+
+			for i, msg := range msgs {
+				eventData, changed, err := rewriteEventData(msg.Values["event"], rewrite)
+				if err != nil {
+					return err
+				}
+
+				if changed {
+					msgs[i].Values["event"] = eventData
+					updated++
+				}
+			}
+
+			if updated == 0 {
+				return nil
+			}
+
+			info, err := tx.XInfoStream(ctx, key).Result()
+			if err != nil {
+				return fmt.Errorf("reading stream %s: %w", key, err)
+			}
+
+			_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				p.Del(ctx, key)
+
+				for _, msg := range msgs {
+					p.XAdd(ctx, &redis.XAddArgs{Stream: key, ID: msg.ID, Values: msg.Values})
+				}
+
+				p.Do(ctx, "XSETID", key, info.LastGeneratedID)
+
+				return nil
+			})
+
+			return err
+		}, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return updated, nil
+}
+
+// rewriteFutureEvent rewrites the payloads of the future event stored under the given key, unless it has been
+// delivered in the meantime.
+func (rb *redisBackend) rewriteFutureEvent(ctx context.Context, key string, rewrite backend.PayloadRewriteFunc) (bool, error) {
+	var changed bool
+	var err error
+
+	for attempt := 1; attempt <= rewriteMaxAttempts; attempt++ {
+		changed = false
+
+		err = rb.rdb.Watch(ctx, func(tx *redis.Tx) error {
+			data, err := tx.HGet(ctx, key, "event").Result()
+			if err != nil {
+				if errors.Is(err, redis.Nil) {
+					return nil
+				}
+
+				return fmt.Errorf("reading future event: %w", err)
+			}
+
+			eventData, c, err := rewriteEventData(data, rewrite)
+			if err != nil || !c {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				p.HSet(ctx, key, "event", eventData)
+				return nil
+			})
+
+			changed = err == nil
+
+			return err
+		}, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+
+	return changed, err
+}
+
+// rewriteEventData rewrites the payloads of the given serialized event, and returns the serialized event and true
+// if any payload changed.
+func rewriteEventData(data interface{}, rewrite backend.PayloadRewriteFunc) (string, bool, error) {
+	s, _ := data.(string)
+
+	var event history.Event
+	if err := json.Unmarshal([]byte(s), &event); err != nil {
+		return "", false, fmt.Errorf("unmarshaling event: %w", err)
+	}
+
+	e, changed, err := history.RewritePayloads(event, rewrite)
+	if err != nil || !changed {
+		return s, false, err
+	}
+
+	eventData, err := json.Marshal(e)
+	if err != nil {
+		return "", false, fmt.Errorf("marshaling event: %w", err)
+	}
+
+	return string(eventData), true, nil
+}
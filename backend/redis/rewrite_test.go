@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RewriteEventData(t *testing.T) {
+	event := history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+		Inputs: []payload.Payload{payload.Payload(`"a"`), payload.Payload(`"b"`)},
+	})
+	data, err := json.Marshal(event)
+	require.NoError(t, err)
+
+	rewrite := func(p payload.Payload) (payload.Payload, bool, error) {
+		if string(p) == `"a"` {
+			return payload.Payload(`"x"`), true, nil
+		}
+
+		return p, false, nil
+	}
+
+	rewritten, changed, err := rewriteEventData(string(data), rewrite)
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	var e history.Event
+	require.NoError(t, json.Unmarshal([]byte(rewritten), &e))
+	require.Equal(t, event.ID, e.ID)
+	a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&e)
+	require.NoError(t, err)
+	require.Equal(t, []payload.Payload{payload.Payload(`"x"`), payload.Payload(`"b"`)}, a.Inputs)
+
+	// Unchanged events are kept as they are
+	unchanged, changed, err := rewriteEventData(rewritten, rewrite)
+	require.NoError(t, err)
+	require.False(t, changed)
+	require.Equal(t, rewritten, unchanged)
+}
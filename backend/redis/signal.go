@@ -18,10 +18,14 @@ func (rb *redisBackend) SignalWorkflow(ctx context.Context, instanceID string, e
 	}
 
 	ctx = tracing.UnmarshalSpan(ctx, instanceState.Metadata)
-	a := event.Attributes.(*history.SignalReceivedAttributes)
+	a, err := history.AttributesAs[*history.SignalReceivedAttributes](&event)
+	if err != nil {
+		return err
+	}
+
 	_, span := rb.Tracer().Start(ctx, fmt.Sprintf("SignalWorkflow: %s", a.Name), trace.WithAttributes(
 		attribute.String(tracing.WorkflowInstanceID, instanceID),
-		attribute.String("signal.name", event.Attributes.(*history.SignalReceivedAttributes).Name),
+		attribute.String("signal.name", a.Name),
 	))
 	defer span.End()
 
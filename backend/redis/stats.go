@@ -0,0 +1,59 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/go-redis/redis/v8"
+)
+
+var _ backend.StorageStatsProvider = (*redisBackend)(nil)
+
+func (rb *redisBackend) StorageStats(ctx context.Context) (*backend.StorageStats, error) {
+	p := rb.rdb.Pipeline()
+
+	instances := p.ZCard(ctx, instancesByCreation())
+	futureEvents := p.ZCard(ctx, futureEventsKey())
+	workflowTasks := p.XLen(ctx, rb.workflowQueue.Keys().StreamKey)
+	activityTasks := p.XLen(ctx, rb.activityQueue.Keys().StreamKey)
+	oldestWorkflowTask := p.XRangeN(ctx, rb.workflowQueue.Keys().StreamKey, "-", "+", 1)
+	oldestActivityTask := p.XRangeN(ctx, rb.activityQueue.Keys().StreamKey, "-", "+", 1)
+
+	if _, err := p.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("collecting storage stats: %w", err)
+	}
+
+	now := time.Now()
+
+	return &backend.StorageStats{
+		Sizes: map[string]int64{
+			"instances":      instances.Val(),
+			"future_events":  futureEvents.Val(),
+			"workflow_tasks": workflowTasks.Val(),
+			"activity_tasks": activityTasks.Val(),
+		},
+		OldestWorkflowTaskAge: messageAge(now, oldestWorkflowTask.Val()),
+		OldestActivityTaskAge: messageAge(now, oldestActivityTask.Val()),
+		// Future events are only used for timers
+		PendingTimers: futureEvents.Val(),
+	}, nil
+}
+
+// messageAge returns the age of the first of the given stream messages, derived from the millisecond
+// timestamp in its ID.
+func messageAge(now time.Time, msgs []redis.XMessage) time.Duration {
+	if len(msgs) == 0 {
+		return 0
+	}
+
+	ms, err := strconv.ParseInt(strings.SplitN(msgs[0].ID, "-", 2)[0], 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return now.Sub(time.UnixMilli(ms))
+}
@@ -0,0 +1,95 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/go-redis/redis/v8"
+)
+
+var _ backend.TimerLister = (*redisBackend)(nil)
+
+func (rb *redisBackend) PendingTimers(ctx context.Context, count int) ([]*backend.PendingTimer, error) {
+	if count <= 0 {
+		return []*backend.PendingTimer{}, nil
+	}
+
+	// Future events are ordered by the time they become visible. They also include activity timeouts, and
+	// events delivered in the meantime, so page through them until enough timers have been found.
+	timers := make([]*backend.PendingTimer, 0, count)
+	for offset := int64(0); len(timers) < count; offset += int64(count) {
+		events, err := rb.rdb.ZRangeWithScores(ctx, futureEventsKey(), offset, offset+int64(count)-1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("listing future events: %w", err)
+		}
+
+		page, err := rb.pendingTimers(ctx, events)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range page {
+			if len(timers) == count {
+				break
+			}
+
+			timers = append(timers, t)
+		}
+
+		if len(events) < count {
+			break
+		}
+	}
+
+	return timers, nil
+}
+
+// pendingTimers returns the timers among the given future events.
+func (rb *redisBackend) pendingTimers(ctx context.Context, events []redis.Z) ([]*backend.PendingTimer, error) {
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	p := rb.rdb.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(events))
+	for i, e := range events {
+		cmds[i] = p.HMGet(ctx, e.Member.(string), "instance", "event")
+	}
+
+	if _, err := p.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("reading future events: %w", err)
+	}
+
+	timers := make([]*backend.PendingTimer, 0, len(events))
+	for i, e := range events {
+		values := cmds[i].Val()
+		instanceID, _ := values[0].(string)
+		eventData, _ := values[1].(string)
+		if instanceID == "" || eventData == "" {
+			// Event has been delivered in the meantime
+			continue
+		}
+
+		var event history.Event
+		if err := json.Unmarshal([]byte(eventData), &event); err != nil {
+			return nil, fmt.Errorf("unmarshaling future event: %w", err)
+		}
+
+		if event.Type != history.EventType_TimerFired {
+			continue
+		}
+
+		timers = append(timers, &backend.PendingTimer{
+			InstanceID:      instanceID,
+			ScheduleEventID: event.ScheduleEventID,
+			ScheduledAt:     event.Timestamp,
+			FireAt:          time.UnixMilli(int64(e.Score)),
+		})
+	}
+
+	return timers, nil
+}
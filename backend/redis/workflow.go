@@ -3,10 +3,13 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
 
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/activityrate"
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/task"
@@ -48,6 +51,7 @@ var futureEventsCmd = redis.NewScript(`
 		-- Delete event hash data
 		redis.call("DEL", events[i])
 		redis.call("ZREM", KEYS[1], events[i])
+		redis.call("SREM", "instance-future-events:" .. instanceID, events[i])
 	end
 
 	return #events
@@ -55,7 +59,7 @@ var futureEventsCmd = redis.NewScript(`
 
 func (rb *redisBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, error) {
 	// Check for future events
-	now := time.Now().UnixMilli()
+	now := rb.visibleBefore().UnixMilli()
 	nowStr := strconv.FormatInt(now, 10)
 
 	queueKeys := rb.workflowQueue.Keys()
@@ -68,7 +72,9 @@ func (rb *redisBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, er
 		return nil, fmt.Errorf("checking future events: %w", err)
 	}
 
-	// Try to get a workflow task, this locks the instance when it dequeues one
+	// Try to get a workflow task, this locks the instance when it dequeues one. Instances with new events are
+	// re-queued at the end of the task stream when their task is completed, so tasks are dispatched round-robin
+	// across instances.
 	instanceTask, err := rb.workflowQueue.Dequeue(ctx, rb.rdb, rb.options.WorkflowLockTimeout, rb.options.BlockTimeout)
 	if err != nil {
 		return nil, err
@@ -90,6 +96,7 @@ func (rb *redisBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, er
 	}
 
 	newEvents := make([]history.Event, 0, len(msgs))
+	messageIDs := make(map[string]string, len(msgs))
 	for _, msg := range msgs {
 		var event history.Event
 
@@ -98,6 +105,7 @@ func (rb *redisBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, er
 		}
 
 		newEvents = append(newEvents, event)
+		messageIDs[event.ID] = msg.ID
 	}
 
 	return &task.Workflow{
@@ -107,10 +115,21 @@ func (rb *redisBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, er
 		Metadata:              instanceState.Metadata,
 		LastSequenceID:        instanceState.LastSequenceID,
 		NewEvents:             newEvents,
-		CustomData:            msgs[len(msgs)-1].ID, // Id of last pending message in stream at this point
+		CustomData: &pendingEventsData{
+			LastMessageID: msgs[len(msgs)-1].ID, // Id of last pending message in stream at this point
+			MessageIDs:    messageIDs,
+		},
 	}, nil
 }
 
+// pendingEventsData tracks the stream messages of the pending events in a workflow task
+type pendingEventsData struct {
+	LastMessageID string
+
+	// MessageIDs maps event IDs to the IDs of their messages in the pending events stream
+	MessageIDs map[string]string
+}
+
 func (rb *redisBackend) ExtendWorkflowTask(ctx context.Context, taskID string, instance *core.WorkflowInstance) error {
 	_, err := rb.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
 		return rb.workflowQueue.Extend(ctx, p, taskID)
@@ -119,6 +138,31 @@ func (rb *redisBackend) ExtendWorkflowTask(ctx context.Context, taskID string, i
 	return err
 }
 
+// AbandonWorkflowTask releases the given task. The instance is queued again once the delay has passed, or earlier
+// if new events are added for it in the meantime.
+func (rb *redisBackend) AbandonWorkflowTask(ctx context.Context, t *task.Workflow, delay time.Duration) error {
+	p := rb.rdb.TxPipeline()
+
+	completeCmd, err := rb.workflowQueue.Complete(ctx, p, t.ID)
+	if err != nil {
+		return fmt.Errorf("abandoning workflow task: %w", err)
+	}
+
+	if err := rb.workflowQueue.EnqueueAt(ctx, p, t.WorkflowInstance.InstanceID, nil, rb.options.Now().Add(delay)); err != nil {
+		return fmt.Errorf("queueing workflow task: %w", err)
+	}
+
+	if _, err := p.Exec(ctx); err != nil {
+		if err := completeCmd.Err(); err == redis.Nil {
+			return errors.New("could not abandon workflow task")
+		}
+
+		return fmt.Errorf("abandoning workflow task: %w", err)
+	}
+
+	return nil
+}
+
 // Remove all pending events before (and including) a given message id
 // KEYS[1] - pending events stream key
 // ARGV[1] - message id
@@ -145,6 +189,70 @@ var requeueInstanceCmd = redis.NewScript(`
 	return true
 `)
 
+// continuePendingEventsP removes the pending events of an instance continuing as new, except for signals, which
+// are delivered to the new execution. Pending cancellations are removed as well, and added again after the start
+// event of the new execution, which has to be added to the pipeline before.
+func continuePendingEventsP(
+	ctx context.Context, tx *redis.Tx, p redis.Pipeliner, instanceID string, data *pendingEventsData, executedEvents []history.Event,
+) error {
+	removed := make([]string, 0, len(executedEvents))
+	executed := make(map[string]bool, len(executedEvents))
+	for _, event := range executedEvents {
+		if msgID, ok := data.MessageIDs[event.ID]; ok {
+			removed = append(removed, msgID)
+			executed[msgID] = true
+		}
+	}
+
+	msgs, err := tx.XRange(ctx, pendingEventsKey(instanceID), "-", "+").Result()
+	if err != nil {
+		return fmt.Errorf("reading pending events: %w", err)
+	}
+
+	var canceled []string
+	for _, msg := range msgs {
+		if executed[msg.ID] {
+			continue
+		}
+
+		eventData := msg.Values["event"].(string)
+
+		var event history.Event
+		if err := json.Unmarshal([]byte(eventData), &event); err != nil {
+			return fmt.Errorf("unmarshaling event: %w", err)
+		}
+
+		switch event.Type {
+		case history.EventType_SignalReceived:
+			continue
+
+		case history.EventType_WorkflowExecutionCanceled:
+			canceled = append(canceled, eventData)
+		}
+
+		removed = append(removed, msg.ID)
+	}
+
+	if len(removed) > 0 {
+		p.XDel(ctx, pendingEventsKey(instanceID), removed...)
+	}
+
+	// The IDs of the cancellations are already in the set of event IDs, add them to the stream directly
+	for _, eventData := range canceled {
+		p.XAdd(ctx, &redis.XAddArgs{
+			Stream: pendingEventsKey(instanceID),
+			ID:     "*",
+			Values: map[string]interface{}{"event": eventData},
+		})
+	}
+
+	return nil
+}
+
+// completeWorkflowTaskMaxAttempts is how often completing a workflow task is attempted when sub-workflow instances
+// are created concurrently by someone else, or events are added to an instance continuing as new
+const completeWorkflowTaskMaxAttempts = 3
+
 func (rb *redisBackend) CompleteWorkflowTask(
 	ctx context.Context,
 	task *task.Workflow,
@@ -152,27 +260,118 @@ func (rb *redisBackend) CompleteWorkflowTask(
 	state core.WorkflowInstanceState,
 	executedEvents, activityEvents, timerEvents []history.Event,
 	workflowEvents []history.WorkflowEvent,
+) error {
+	return rb.commitWorkflowTask(ctx, task, instance, state, false, executedEvents, activityEvents, timerEvents, workflowEvents)
+}
+
+var _ backend.WorkflowTaskCheckpointer = (*redisBackend)(nil)
+
+// CheckpointWorkflowTask commits the progress of a workflow task like CompleteWorkflowTask, but keeps the task in
+// the queue and extends its lease
+func (rb *redisBackend) CheckpointWorkflowTask(
+	ctx context.Context,
+	task *task.Workflow,
+	instance *core.WorkflowInstance,
+	executedEvents, activityEvents, timerEvents []history.Event,
+	workflowEvents []history.WorkflowEvent,
+) error {
+	return rb.commitWorkflowTask(
+		ctx, task, instance, core.WorkflowInstanceStateActive, true, executedEvents, activityEvents, timerEvents, workflowEvents)
+}
+
+func (rb *redisBackend) commitWorkflowTask(
+	ctx context.Context,
+	task *task.Workflow,
+	instance *core.WorkflowInstance,
+	state core.WorkflowInstanceState,
+	keepLocked bool,
+	executedEvents, activityEvents, timerEvents []history.Event,
+	workflowEvents []history.WorkflowEvent,
+) error {
+	// Watch the instance keys of sub-workflows to be started, so that the transaction fails if one of them is created
+	// between checking whether the instance ID is taken and creating the instance. When the instance continues as
+	// new, its pending events are watched as well, so that none is added after they were sorted out for the new
+	// execution.
+	var watchedKeys []string
+	for _, m := range workflowEvents {
+		if m.HistoryEvent.Type != history.EventType_WorkflowExecutionStarted {
+			continue
+		}
+
+		if m.WorkflowInstance.InstanceID == instance.InstanceID {
+			watchedKeys = append(watchedKeys, pendingEventsKey(instance.InstanceID))
+		} else {
+			watchedKeys = append(watchedKeys, instanceKey(m.WorkflowInstance.InstanceID))
+		}
+	}
+
+	var err error
+	for attempt := 1; attempt <= completeWorkflowTaskMaxAttempts; attempt++ {
+		err = rb.rdb.Watch(ctx, func(tx *redis.Tx) error {
+			return rb.completeWorkflowTask(ctx, tx, task, instance, state, keepLocked, executedEvents, activityEvents, timerEvents, workflowEvents)
+		}, watchedKeys...)
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+
+	return err
+}
+
+func (rb *redisBackend) completeWorkflowTask(
+	ctx context.Context,
+	tx *redis.Tx,
+	task *task.Workflow,
+	instance *core.WorkflowInstance,
+	state core.WorkflowInstanceState,
+	keepLocked bool,
+	executedEvents, activityEvents, timerEvents []history.Event,
+	workflowEvents []history.WorkflowEvent,
 ) error {
 	instanceState, err := readInstance(ctx, rb.rdb, instance.InstanceID)
 	if err != nil {
 		return err
 	}
 
+	// Timers fire after their delay according to the clock of the backend, not the one of the worker
+	timerEvents = backend.RebaseFutureEvents(rb.options.Now(), timerEvents)
+
 	// Check-point the workflow. We guarantee that no other worker is working on this workflow instance at this point via the
-	// task queue, so we don't need to WATCH the keys, we just need to make sure all commands are executed atomically to prevent
-	// a worker crashing in the middle of this execution.
-	p := rb.rdb.TxPipeline()
+	// task queue, we just need to make sure all commands are executed atomically to prevent a worker crashing in the middle
+	// of this execution. Only the instance keys of new sub-workflows are watched.
+	p := tx.TxPipeline()
 
 	// Add executed events to the history
 	if err := addEventsToHistoryStreamP(ctx, p, historyKey(instance.InstanceID), executedEvents); err != nil {
 		return fmt.Errorf("serializing : %w", err)
 	}
 
-	for _, event := range executedEvents {
-		switch event.Type {
-		case history.EventType_TimerCanceled:
-			removeFutureEventP(ctx, p, instance, &event)
+	// Rescheduled timers and start-to-close timeouts are scheduled again below
+	for _, scheduleEventID := range backend.RemovedFutureEvents(executedEvents) {
+		removeFutureEventP(ctx, p, instance, scheduleEventID)
+	}
+
+	// Spread activities over time if the rate of the instance is limited
+	if rb.options.InstanceActivityRate > 0 && len(activityEvents) > 0 {
+		var next time.Time
+		if instanceState.NextActivityAt != nil {
+			next = *instanceState.NextActivityAt
 		}
+
+		visibleAt, next := activityrate.Schedule(rb.options.Now(), next, len(activityEvents), rb.options.InstanceActivityRate)
+		instanceState.NextActivityAt = &next
+
+		throttled := make([]history.Event, len(activityEvents))
+		for i, event := range activityEvents {
+			if visibleAt[i].After(rb.options.Now()) {
+				event.VisibleAt = &visibleAt[i]
+			}
+
+			throttled[i] = event
+		}
+
+		activityEvents = throttled
+		timerEvents = backend.DelayActivityTimeouts(activityEvents, timerEvents)
 	}
 
 	// Schedule timers
@@ -182,7 +381,14 @@ func (rb *redisBackend) CompleteWorkflowTask(
 		}
 	}
 
+	// The instance won't be executed again, remove any timers that have not fired yet
+	if state == core.WorkflowInstanceStateFinished {
+		removeFutureEventsP(ctx, p, instance)
+	}
+
 	// Send new workflow events to the respective streams
+	var continuedAs *core.WorkflowInstance
+	var continuedMetadata *core.WorkflowMetadata
 	groupedEvents := history.EventsByWorkflowInstanceID(workflowEvents)
 	for targetInstanceID, events := range groupedEvents {
 		// Insert pending events for target instance
@@ -191,14 +397,45 @@ func (rb *redisBackend) CompleteWorkflowTask(
 
 			if m.HistoryEvent.Type == history.EventType_WorkflowExecutionStarted {
 				// Create new instance
-				a := m.HistoryEvent.Attributes.(*history.ExecutionStartedAttributes)
-				if err := createInstanceP(ctx, p, m.WorkflowInstance, a.Metadata, true); err != nil {
+				a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&m.HistoryEvent)
+				if err != nil {
 					return err
 				}
+
+				if targetInstanceID == instance.InstanceID {
+					// The instance continued as new, replace the current execution. Signals that have not been
+					// received yet are delivered to the new execution.
+					continuedAs, continuedMetadata = m.WorkflowInstance, a.Metadata
+				} else {
+					exists, err := tx.Exists(ctx, instanceKey(m.WorkflowInstance.InstanceID)).Result()
+					if err != nil {
+						return fmt.Errorf("checking sub-workflow instance: %w", err)
+					}
+
+					switch {
+					case exists > 0 && a.DerivedInstanceID:
+						// The derived instance ID is already taken, fail the sub-workflow in the parent instead of
+						// starting the existing instance again
+						failedEvent := backend.SubWorkflowAlreadyExistsEvent(rb.options.Now(), m.WorkflowInstance)
+						if err := rb.addPendingEventP(ctx, p, instance.InstanceID, &failedEvent); err != nil {
+							return err
+						}
+
+						continue
+
+					case exists > 0:
+						// Explicit instance IDs deliver the start event to the existing instance
+
+					default:
+						if err := createInstanceP(ctx, p, m.WorkflowInstance, a.Metadata, rb.options.Now()); err != nil {
+							return err
+						}
+					}
+				}
 			}
 
 			// Add pending event to stream
-			if err := addEventToStreamP(ctx, p, pendingEventsKey(targetInstanceID), &m.HistoryEvent); err != nil {
+			if err := rb.addPendingEventP(ctx, p, targetInstanceID, &m.HistoryEvent); err != nil {
 				return err
 			}
 		}
@@ -214,7 +451,7 @@ func (rb *redisBackend) CompleteWorkflowTask(
 	instanceState.State = state
 
 	if state == core.WorkflowInstanceStateFinished {
-		t := time.Now()
+		t := rb.options.Now()
 		instanceState.CompletedAt = &t
 	}
 
@@ -222,45 +459,103 @@ func (rb *redisBackend) CompleteWorkflowTask(
 		instanceState.LastSequenceID = executedEvents[len(executedEvents)-1].SequenceID
 	}
 
+	if continuedAs != nil {
+		// Keep the history and a completion record of the current execution
+		if err := addExecutionP(ctx, p, instanceState, rb.options.Now()); err != nil {
+			return err
+		}
+
+		instanceState.Instance = continuedAs
+		instanceState.Metadata = continuedMetadata
+		instanceState.State = core.WorkflowInstanceStateActive
+		instanceState.CompletedAt = nil
+		instanceState.LastSequenceID = 0
+	}
+
 	if err := updateInstanceP(ctx, p, instance.InstanceID, instanceState); err != nil {
 		return fmt.Errorf("updating workflow instance: %w", err)
 	}
 
 	// Store activity data
 	for _, activityEvent := range activityEvents {
-		if err := rb.activityQueue.Enqueue(ctx, p, activityEvent.ID, &activityData{
+		data := &activityData{
 			Instance: instance,
 			ID:       activityEvent.ID,
 			Event:    activityEvent,
-		}); err != nil {
+		}
+
+		queue, err := rb.getActivityQueue(backend.ActivityQueue(&activityEvent))
+		if err != nil {
+			return err
+		}
+
+		if activityEvent.VisibleAt != nil {
+			err = queue.EnqueueAt(ctx, p, activityEvent.ID, data, *activityEvent.VisibleAt)
+		} else {
+			err = queue.Enqueue(ctx, p, activityEvent.ID, data)
+		}
+
+		if err != nil {
 			return fmt.Errorf("queueing activity task: %w", err)
 		}
 	}
 
 	// Remove executed pending events
-	if task.CustomData != nil {
-		lastPendingEventMessageID := task.CustomData.(string)
-		removePendingEventsCmd.Run(ctx, p, []string{pendingEventsKey(instance.InstanceID)}, lastPendingEventMessageID)
-	}
+	if data, ok := task.CustomData.(*pendingEventsData); ok {
+		if continuedAs != nil {
+			if err := continuePendingEventsP(ctx, tx, p, instance.InstanceID, data, executedEvents); err != nil {
+				return err
+			}
+		} else if state == core.WorkflowInstanceStateFinished {
+			// The instance won't execute any more events, remove all events that were pending for this task
+			removePendingEventsCmd.Run(ctx, p, []string{pendingEventsKey(instance.InstanceID)}, data.LastMessageID)
+		} else {
+			// The worker might only have executed some of the pending events, remove just those
+			executedMessageIDs := make([]string, 0, len(executedEvents))
+			for _, event := range executedEvents {
+				if msgID, ok := data.MessageIDs[event.ID]; ok {
+					executedMessageIDs = append(executedMessageIDs, msgID)
+				}
+			}
 
-	// Complete workflow task and unlock instance.
-	completeCmd, err := rb.workflowQueue.Complete(ctx, p, task.ID)
-	if err != nil {
-		return fmt.Errorf("completing workflow task: %w", err)
+			if len(executedMessageIDs) > 0 {
+				p.XDel(ctx, pendingEventsKey(instance.InstanceID), executedMessageIDs...)
+			}
+		}
 	}
 
-	// If there are pending events, queue the instance again
-	keyInfo := rb.workflowQueue.Keys()
-	requeueInstanceCmd.Run(ctx, p,
-		[]string{pendingEventsKey(instance.InstanceID), keyInfo.StreamKey, keyInfo.SetKey},
-		instance.InstanceID,
-	)
+	var completeCmd *redis.Cmd
+	if keepLocked {
+		// Checkpoints keep the task and extend its lease, remaining events are executed as part of the same task
+		if err := rb.workflowQueue.Extend(ctx, p, task.ID); err != nil {
+			return fmt.Errorf("extending workflow task: %w", err)
+		}
+	} else {
+		// Complete workflow task and unlock instance.
+		completeCmd, err = rb.workflowQueue.Complete(ctx, p, task.ID)
+		if err != nil {
+			return fmt.Errorf("completing workflow task: %w", err)
+		}
+
+		// If there are pending events, queue the instance again
+		keyInfo := rb.workflowQueue.Keys()
+		requeueInstanceCmd.Run(ctx, p,
+			[]string{pendingEventsKey(instance.InstanceID), keyInfo.StreamKey, keyInfo.SetKey},
+			instance.InstanceID,
+		)
+	}
 
 	// Commit transaction
 	executedCmds, err := p.Exec(ctx)
 	if err != nil {
-		if err := completeCmd.Err(); err != nil && err == redis.Nil {
-			return fmt.Errorf("could not complete workflow task: %w", err)
+		if errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+
+		if completeCmd != nil {
+			if err := completeCmd.Err(); err != nil && err == redis.Nil {
+				return fmt.Errorf("could not complete workflow task: %w", err)
+			}
 		}
 
 		for _, cmd := range executedCmds {
@@ -286,7 +581,7 @@ func (rb *redisBackend) CompleteWorkflowTask(
 
 func (rb *redisBackend) addWorkflowInstanceEventP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, event *history.Event) error {
 	// Add event to pending events for instance
-	if err := addEventToStreamP(ctx, p, pendingEventsKey(instance.InstanceID), event); err != nil {
+	if err := rb.addPendingEventP(ctx, p, instance.InstanceID, event); err != nil {
 		return err
 	}
 
@@ -0,0 +1,67 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/metrics"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+// InstanceRemover is implemented by backends that can remove finished workflow instances, all built-in backends
+// do. Removal is soft: removed instances are hidden, and reading their state or history returns
+// ErrInstanceNotFound, but their data is kept until it's purged, so they can be restored in the meantime, e.g.,
+// after accidentally removing instances whose history is needed for audits.
+type InstanceRemover interface {
+	// RemoveWorkflowInstance marks the given finished instance as removed. It returns ErrInstanceNotFound if the
+	// instance doesn't exist or has already been removed, ErrInstanceNotFinished if it's still running, and
+	// ErrInstanceHeld if it's on hold.
+	RemoveWorkflowInstance(ctx context.Context, instance *workflow.Instance) error
+
+	// UndeleteWorkflowInstance restores the given removed instance. It returns ErrInstanceNotFound if the instance
+	// hasn't been removed, or has already been purged.
+	UndeleteWorkflowInstance(ctx context.Context, instance *workflow.Instance) error
+
+	// PurgeRemovedWorkflowInstances permanently deletes all data of instances removed before the given time, and
+	// returns the number of purged instances. Instances on hold are not purged, see InstanceHolder.
+	PurgeRemovedWorkflowInstances(ctx context.Context, removedBefore time.Time) (int, error)
+}
+
+var ErrRemovalNotSupported = errors.New("backend does not support removing workflow instances")
+
+var ErrInstanceNotFinished = errors.New("workflow instance is not finished")
+
+// PurgeRemovedInstances periodically purges instances of the given backend that were removed more than gracePeriod
+// ago, until the given context is canceled. Until then, removed instances can be restored with
+// UndeleteWorkflowInstance. Run it on a single worker, see RunAsLeader.
+func PurgeRemovedInstances(ctx context.Context, b Backend, gracePeriod, interval time.Duration) error {
+	r, ok := b.(InstanceRemover)
+	if !ok {
+		return ErrRemovalNotSupported
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		n, err := r.PurgeRemovedWorkflowInstances(ctx, Now(b).Add(-gracePeriod))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			b.Logger().Error("purging removed workflow instances", "error", err)
+		} else if n > 0 {
+			b.Logger().Debug("Purged removed workflow instances", "count", n)
+			b.Metrics().Counter(metrickeys.BackendInstancesPurged, metrics.Tags{}, int64(n))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
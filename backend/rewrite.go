@@ -0,0 +1,18 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+// PayloadRewriteFunc returns the new payload for p and true if it has to be updated.
+type PayloadRewriteFunc func(p payload.Payload) (payload.Payload, bool, error)
+
+// PayloadRewriter is implemented by backends that support updating stored payloads in place, for example
+// to re-encrypt them with a new key.
+type PayloadRewriter interface {
+	// RewritePayloads passes every payload of stored events through rewrite, and updates events with
+	// changed payloads. It returns the number of updated events.
+	RewritePayloads(ctx context.Context, rewrite PayloadRewriteFunc) (int, error)
+}
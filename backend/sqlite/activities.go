@@ -3,29 +3,66 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/activityrate"
 	"github.com/cschleiden/go-workflows/internal/history"
 )
 
-func scheduleActivity(ctx context.Context, tx *sql.Tx, instanceID, executionID string, event history.Event) error {
-	attributes, err := history.SerializeAttributes(event.Attributes)
-	if err != nil {
-		return err
+// scheduleActivities inserts the given activity events, using a single statement per batch.
+func scheduleActivities(ctx context.Context, tx *sql.Tx, instanceID, executionID string, events []history.Event) error {
+	for batchStart := 0; batchStart < len(events); batchStart += insertBatchSize {
+		batchEnd := batchStart + insertBatchSize
+		if batchEnd > len(events) {
+			batchEnd = len(events)
+		}
+		batchEvents := events[batchStart:batchEnd]
+
+		query := `INSERT INTO activities
+			(id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at, queue) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)` +
+			strings.Repeat(", (?, ?, ?, ?, ?, ?, ?, ?, ?)", len(batchEvents)-1)
+
+		args := make([]interface{}, 0, len(batchEvents)*9)
+
+		for i := range batchEvents {
+			event := &batchEvents[i]
+
+			attributes, err := event.SerializedAttributes()
+			if err != nil {
+				return err
+			}
+
+			args = append(args, event.ID, instanceID, executionID, event.Type, event.Timestamp, event.ScheduleEventID, attributes, event.VisibleAt, backend.ActivityQueue(event))
+		}
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// throttleActivities returns the times at which the given number of activities of the instance become visible to
+// workers, spread according to InstanceActivityRate. It returns nil if the rate is not limited.
+func (sb *sqliteBackend) throttleActivities(ctx context.Context, tx *sql.Tx, instanceID string, n int) ([]time.Time, error) {
+	if sb.options.InstanceActivityRate <= 0 || n == 0 {
+		return nil, nil
+	}
+
+	var next sql.NullTime
+	if err := tx.QueryRowContext(ctx, "SELECT next_activity_at FROM instances WHERE id = ?", instanceID).Scan(&next); err != nil {
+		return nil, fmt.Errorf("getting next activity time: %w", err)
+	}
+
+	visibleAt, nextActivityAt := activityrate.Schedule(sb.options.Now(), next.Time, n, sb.options.InstanceActivityRate)
+
+	if _, err := tx.ExecContext(ctx, "UPDATE instances SET next_activity_at = ? WHERE id = ?", nextActivityAt, instanceID); err != nil {
+		return nil, fmt.Errorf("updating next activity time: %w", err)
 	}
 
-	_, err = tx.ExecContext(
-		ctx,
-		`INSERT INTO activities
-			(id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-		event.ID,
-		instanceID,
-		executionID,
-		event.Type,
-		event.Timestamp,
-		event.ScheduleEventID,
-		attributes,
-		event.VisibleAt,
-	)
-
-	return err
+	return visibleAt, nil
 }
@@ -0,0 +1,222 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+var _ backend.BackupRestorer = (*sqliteBackend)(nil)
+
+func (sb *sqliteBackend) BackupWorkflowInstances(ctx context.Context) (*backend.Backup, error) {
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT id, execution_id, parent_instance_id, parent_schedule_event_id, metadata, created_at, completed_at, removed_at,
+			hold_reason, held_at, next_activity_at
+		FROM instances
+		ORDER BY created_at, id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting workflow instances: %w", err)
+	}
+
+	instances := make([]*backend.BackupInstance, 0)
+	for rows.Next() {
+		var id, executionID string
+		var parentInstanceID *string
+		var parentEventID *int64
+		var metadataJson sql.NullString
+		var createdAt time.Time
+		var completedAt, removedAt, heldAt, nextActivityAt *time.Time
+		var holdReason sql.NullString
+
+		if err := rows.Scan(&id, &executionID, &parentInstanceID, &parentEventID, &metadataJson, &createdAt, &completedAt, &removedAt, &holdReason, &heldAt, &nextActivityAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning workflow instance: %w", err)
+		}
+
+		var metadata *workflow.Metadata
+		if metadataJson.Valid && metadataJson.String != "" {
+			if err := json.Unmarshal([]byte(metadataJson.String), &metadata); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("unmarshaling metadata: %w", err)
+			}
+		}
+
+		instance := core.NewWorkflowInstance(id, executionID)
+		if parentInstanceID != nil && parentEventID != nil {
+			instance = core.NewSubWorkflowInstance(id, executionID, *parentInstanceID, *parentEventID)
+		}
+
+		var hold *backend.Hold
+		if heldAt != nil {
+			hold = &backend.Hold{Reason: holdReason.String, PlacedAt: *heldAt}
+		}
+
+		instances = append(instances, &backend.BackupInstance{
+			Instance:       instance,
+			Metadata:       metadata,
+			CreatedAt:      createdAt,
+			CompletedAt:    completedAt,
+			RemovedAt:      removedAt,
+			Hold:           hold,
+			NextActivityAt: nextActivityAt,
+		})
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, i := range instances {
+		id := i.Instance.InstanceID
+
+		if i.History, err = getHistory(ctx, tx, id, nil); err != nil {
+			return nil, err
+		}
+
+		if i.PendingEvents, err = queryEvents(ctx, tx, "SELECT * FROM `pending_events` WHERE instance_id = ? ORDER BY rowid", id); err != nil {
+			return nil, fmt.Errorf("getting pending events: %w", err)
+		}
+
+		if i.Activities, err = queryEvents(ctx, tx, "SELECT id, 0, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at, NULL FROM `activities` WHERE instance_id = ? ORDER BY rowid", id); err != nil {
+			return nil, fmt.Errorf("getting activities: %w", err)
+		}
+
+		if i.ActivityHeartbeatDetails, err = getHeartbeatDetails(ctx, tx, id); err != nil {
+			return nil, err
+		}
+
+		if i.Executions, err = getExecutions(ctx, tx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	return &backend.Backup{
+		Version:   backend.BackupVersion,
+		CreatedAt: sb.options.Now(),
+		Instances: instances,
+	}, nil
+}
+
+func (sb *sqliteBackend) RestoreWorkflowInstances(ctx context.Context, backup *backend.Backup) error {
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, i := range backup.Instances {
+		id := i.Instance.InstanceID
+
+		if err := createInstance(ctx, tx, i.Instance, i.Metadata, i.CreatedAt); err != nil {
+			return fmt.Errorf("restoring workflow instance %s: %w", id, err)
+		}
+
+		var holdReason, heldAt interface{}
+		if i.Hold != nil {
+			holdReason, heldAt = i.Hold.Reason, i.Hold.PlacedAt
+		}
+
+		if _, err := tx.ExecContext(
+			ctx,
+			"UPDATE instances SET created_at = ?, completed_at = ?, removed_at = ?, hold_reason = ?, held_at = ?, next_activity_at = ? WHERE id = ?",
+			i.CreatedAt, i.CompletedAt, i.RemovedAt, holdReason, heldAt, i.NextActivityAt, id,
+		); err != nil {
+			return fmt.Errorf("restoring workflow instance %s: %w", id, err)
+		}
+
+		if err := insertHistoryEvents(ctx, tx, id, i.History, sb.options.Now()); err != nil {
+			return fmt.Errorf("restoring history of %s: %w", id, err)
+		}
+
+		if err := insertEvents(ctx, tx, "pending_events", id, i.PendingEvents, nil, ""); err != nil {
+			return fmt.Errorf("restoring pending events of %s: %w", id, err)
+		}
+
+		if err := scheduleActivities(ctx, tx, id, i.Instance.ExecutionID, i.Activities); err != nil {
+			return fmt.Errorf("restoring activities of %s: %w", id, err)
+		}
+
+		for activityID, details := range i.ActivityHeartbeatDetails {
+			if _, err := tx.ExecContext(
+				ctx,
+				"UPDATE activities SET heartbeat_details = ? WHERE instance_id = ? AND id = ?",
+				[]byte(details), id, activityID,
+			); err != nil {
+				return fmt.Errorf("restoring heartbeat details of %s: %w", id, err)
+			}
+		}
+
+		if err := insertExecutions(ctx, tx, id, i.Executions, sb.options.Now()); err != nil {
+			return fmt.Errorf("restoring executions of %s: %w", id, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// getHeartbeatDetails returns the details of the last heartbeats of the activities of the given instance, by
+// activity ID.
+func getHeartbeatDetails(ctx context.Context, tx *sql.Tx, instanceID string) (map[string]payload.Payload, error) {
+	rows, err := tx.QueryContext(
+		ctx, "SELECT id, heartbeat_details FROM `activities` WHERE instance_id = ? AND heartbeat_details IS NOT NULL", instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("getting activity heartbeat details: %w", err)
+	}
+	defer rows.Close()
+
+	var details map[string]payload.Payload
+	for rows.Next() {
+		var id string
+		var d []byte
+		if err := rows.Scan(&id, &d); err != nil {
+			return nil, fmt.Errorf("scanning activity heartbeat details: %w", err)
+		}
+
+		if details == nil {
+			details = make(map[string]payload.Payload)
+		}
+
+		details[id] = d
+	}
+
+	return details, rows.Err()
+}
+
+// queryEvents returns the events selected by the given query, which has to return the columns of the history
+// table.
+func queryEvents(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]history.Event, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]history.Event, 0)
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+)
+
+var _ backend.CompletedInstanceLister = (*sqliteBackend)(nil)
+
+func (sb *sqliteBackend) GetCompletedWorkflowInstances(
+	ctx context.Context, after *backend.CompletionCursor, completedBefore time.Time, count int,
+) ([]*backend.CompletedInstance, error) {
+	// Executions the instances continued from are listed together with the current ones
+	query := `SELECT * FROM (
+			SELECT id, execution_id, parent_instance_id, parent_schedule_event_id, completed_at
+			FROM instances
+			WHERE completed_at IS NOT NULL AND removed_at IS NULL
+			UNION ALL
+			SELECT e.instance_id, e.execution_id, e.parent_instance_id, e.parent_schedule_event_id, e.completed_at
+			FROM executions e
+			INNER JOIN instances i ON i.id = e.instance_id
+			WHERE i.removed_at IS NULL
+		)
+		WHERE completed_at < ?`
+	args := []interface{}{completedBefore}
+
+	if after != nil {
+		query += ` AND (completed_at > ? OR (completed_at = ? AND (id > ? OR (id = ? AND execution_id > ?))))`
+		args = append(args, after.CompletedAt, after.CompletedAt, after.InstanceID, after.InstanceID, after.ExecutionID)
+	}
+
+	query += ` ORDER BY completed_at, id, execution_id LIMIT ?`
+	args = append(args, count)
+
+	rows, err := sb.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting completed workflow instances: %w", err)
+	}
+	defer rows.Close()
+
+	instances := make([]*backend.CompletedInstance, 0)
+	for rows.Next() {
+		var id, executionID string
+		var parentInstanceID *string
+		var parentEventID *int64
+		var completedAt time.Time
+
+		if err := rows.Scan(&id, &executionID, &parentInstanceID, &parentEventID, &completedAt); err != nil {
+			return nil, fmt.Errorf("scanning completed workflow instance: %w", err)
+		}
+
+		instance := core.NewWorkflowInstance(id, executionID)
+		if parentInstanceID != nil && parentEventID != nil {
+			instance = core.NewSubWorkflowInstance(id, executionID, *parentInstanceID, *parentEventID)
+		}
+
+		instances = append(instances, &backend.CompletedInstance{
+			Instance:    instance,
+			CompletedAt: completedAt,
+		})
+	}
+
+	return instances, rows.Err()
+}
@@ -27,6 +27,7 @@ func (sb *sqliteBackend) GetWorkflowInstances(ctx context.Context, afterInstance
 			FROM instances i
 			INNER JOIN (SELECT id, created_at FROM instances WHERE id = ?) ii
 				ON i.created_at < ii.created_at OR (i.created_at = ii.created_at AND i.id < ii.id)
+			WHERE i.removed_at IS NULL
 			ORDER BY i.created_at DESC, i.id DESC
 			LIMIT ?`,
 			afterInstanceID,
@@ -37,6 +38,7 @@ func (sb *sqliteBackend) GetWorkflowInstances(ctx context.Context, afterInstance
 			ctx,
 			`SELECT i.id, i.execution_id, i.created_at, i.completed_at
 			FROM instances i
+			WHERE i.removed_at IS NULL
 			ORDER BY i.created_at DESC, i.id DESC
 			LIMIT ?`,
 			count,
@@ -80,7 +82,7 @@ func (sb *sqliteBackend) GetWorkflowInstance(ctx context.Context, instanceID str
 	}
 	defer tx.Rollback()
 
-	res := tx.QueryRowContext(ctx, "SELECT id, execution_id, created_at, completed_at FROM instances WHERE id = ?", instanceID)
+	res := tx.QueryRowContext(ctx, "SELECT id, execution_id, created_at, completed_at FROM instances WHERE id = ? AND removed_at IS NULL", instanceID)
 
 	var id, executionID string
 	var createdAt time.Time
@@ -0,0 +1,199 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/sqlinstr"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+var _ backend.ExecutionHistoryReader = (*sqliteBackend)(nil)
+
+func (sb *sqliteBackend) GetWorkflowExecutionHistory(ctx context.Context, instance *workflow.Instance) ([]history.Event, error) {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var executionID string
+	var removed bool
+	if err := tx.QueryRowContext(
+		ctx, "SELECT execution_id, removed_at IS NOT NULL FROM instances WHERE id = ?", instance.InstanceID,
+	).Scan(&executionID, &removed); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, backend.ErrInstanceNotFound
+		}
+
+		return nil, fmt.Errorf("getting workflow instance: %w", err)
+	}
+
+	if removed {
+		return nil, backend.ErrInstanceNotFound
+	}
+
+	if executionID == instance.ExecutionID {
+		h, err := getHistory(ctx, tx, instance.InstanceID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("getting workflow history: %w", err)
+		}
+
+		return h, nil
+	}
+
+	var n int
+	if err := tx.QueryRowContext(
+		ctx,
+		"SELECT COUNT(*) FROM executions WHERE instance_id = ? AND execution_id = ?",
+		instance.InstanceID,
+		instance.ExecutionID,
+	).Scan(&n); err != nil {
+		return nil, fmt.Errorf("getting workflow execution: %w", err)
+	}
+
+	if n == 0 {
+		return nil, backend.ErrInstanceNotFound
+	}
+
+	return getExecutionHistory(ctx, tx, instance.InstanceID, instance.ExecutionID)
+}
+
+// getExecutionHistory returns the history of an execution the instance continued from.
+func getExecutionHistory(ctx context.Context, tx *sql.Tx, instanceID, executionID string) ([]history.Event, error) {
+	events, err := queryEvents(
+		ctx,
+		tx,
+		`SELECT id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at, caused_by
+		FROM execution_history WHERE instance_id = ? AND execution_id = ? ORDER BY sequence_id`,
+		instanceID,
+		executionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting execution history: %w", err)
+	}
+
+	return events, nil
+}
+
+// getExecutions returns the executions the instance continued from, with their histories, in the order they
+// completed.
+func getExecutions(ctx context.Context, tx *sql.Tx, instanceID string) ([]*backend.BackupExecution, error) {
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT execution_id, parent_instance_id, parent_schedule_event_id, metadata, created_at, completed_at
+		FROM executions WHERE instance_id = ? ORDER BY completed_at`,
+		instanceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting executions: %w", err)
+	}
+
+	var executions []*backend.BackupExecution
+	for rows.Next() {
+		var executionID string
+		var parentInstanceID *string
+		var parentEventID *int64
+		var metadataJson sql.NullString
+		var createdAt, completedAt time.Time
+
+		if err := rows.Scan(&executionID, &parentInstanceID, &parentEventID, &metadataJson, &createdAt, &completedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning execution: %w", err)
+		}
+
+		var metadata *workflow.Metadata
+		if metadataJson.Valid && metadataJson.String != "" {
+			if err := json.Unmarshal([]byte(metadataJson.String), &metadata); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("unmarshaling metadata: %w", err)
+			}
+		}
+
+		instance := core.NewWorkflowInstance(instanceID, executionID)
+		if parentInstanceID != nil && parentEventID != nil {
+			instance = core.NewSubWorkflowInstance(instanceID, executionID, *parentInstanceID, *parentEventID)
+		}
+
+		executions = append(executions, &backend.BackupExecution{
+			Instance:    instance,
+			Metadata:    metadata,
+			CreatedAt:   createdAt,
+			CompletedAt: completedAt,
+		})
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, e := range executions {
+		if e.History, err = getExecutionHistory(ctx, tx, instanceID, e.Instance.ExecutionID); err != nil {
+			return nil, err
+		}
+	}
+
+	return executions, nil
+}
+
+// insertExecutions restores the given executions the instance continued from, their events are committed at the
+// given time.
+func insertExecutions(
+	ctx context.Context, tx *sql.Tx, instanceID string, executions []*backend.BackupExecution, committedAt time.Time,
+) error {
+	for _, e := range executions {
+		var parentInstanceID *string
+		var parentEventID *int64
+		if e.Instance.SubWorkflow() {
+			i := e.Instance.ParentInstanceID
+			parentInstanceID = &i
+
+			n := e.Instance.ParentEventID
+			parentEventID = &n
+		}
+
+		metadataJson, err := json.Marshal(e.Metadata)
+		if err != nil {
+			return fmt.Errorf("marshaling metadata: %w", err)
+		}
+
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO executions (instance_id, execution_id, parent_instance_id, parent_schedule_event_id, metadata, created_at, completed_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			instanceID, e.Instance.ExecutionID, parentInstanceID, parentEventID, string(metadataJson), e.CreatedAt, e.CompletedAt,
+		); err != nil {
+			return fmt.Errorf("inserting execution: %w", err)
+		}
+
+		for _, event := range e.History {
+			a, err := event.SerializedAttributes()
+			if err != nil {
+				return err
+			}
+
+			if _, err := tx.ExecContext(
+				ctx,
+				`INSERT INTO execution_history
+					(id, sequence_id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at, caused_by, committed_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				event.ID, event.SequenceID, instanceID, e.Instance.ExecutionID, event.Type, event.Timestamp,
+				event.ScheduleEventID, a, event.VisibleAt, event.CausedBy, committedAt,
+			); err != nil {
+				return fmt.Errorf("inserting execution history: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
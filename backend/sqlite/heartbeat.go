@@ -0,0 +1,63 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+var _ backend.ActivityHeartbeatRecorder = (*sqliteBackend)(nil)
+
+func (sb *sqliteBackend) RecordActivityHeartbeat(ctx context.Context, activityID string, details payload.Payload) error {
+	until := sb.options.Now().Add(sb.options.ActivityLockTimeout)
+	res, err := sb.db.ExecContext(
+		ctx,
+		`UPDATE activities SET locked_until = ?, heartbeat_details = ? WHERE id = ? AND worker = ?`,
+		until,
+		[]byte(details),
+		activityID,
+		sb.workerName,
+	)
+	if err != nil {
+		return fmt.Errorf("recording activity heartbeat: %w", err)
+	}
+
+	if rowsAffected, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("determining if activity heartbeat was recorded: %w", err)
+	} else if rowsAffected == 0 {
+		return errors.New("could not record activity heartbeat")
+	}
+
+	return nil
+}
+
+var _ backend.ActivityHeartbeatTimeoutRescheduler = (*sqliteBackend)(nil)
+
+func (sb *sqliteBackend) RescheduleActivityHeartbeatTimeout(ctx context.Context, instance *core.WorkflowInstance, event *history.Event) error {
+	now := sb.options.Now()
+	event = &backend.RebaseFutureEvents(now, []history.Event{*event})[0]
+
+	a, err := event.SerializedAttributes()
+	if err != nil {
+		return err
+	}
+
+	if _, err := sb.db.ExecContext(
+		ctx,
+		"UPDATE `pending_events` SET visible_at = ?, attributes = ? WHERE instance_id = ? AND id = ? AND visible_at > ?",
+		event.VisibleAt,
+		a,
+		instance.InstanceID,
+		event.ID,
+		now,
+	); err != nil {
+		return fmt.Errorf("rescheduling activity heartbeat timeout: %w", err)
+	}
+
+	return nil
+}
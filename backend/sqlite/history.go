@@ -0,0 +1,76 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/sqlinstr"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+var _ backend.HistoryFilterProvider = (*sqliteBackend)(nil)
+
+func (sb *sqliteBackend) GetFilteredWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, filter backend.HistoryFilter) ([]history.Event, error) {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkNotRemoved(ctx, tx, instance.InstanceID); err != nil {
+		return nil, err
+	}
+
+	query, args := historyFilterQuery(instance.InstanceID, filter)
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting history: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]history.Event, 0)
+
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("reading event: %w", err)
+		}
+
+		events = append(events, filter.StripPayloads(event))
+	}
+
+	return events, rows.Err()
+}
+
+func historyFilterQuery(instanceID string, filter backend.HistoryFilter) (string, []interface{}) {
+	var q strings.Builder
+	q.WriteString("SELECT id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at, caused_by FROM `history` WHERE instance_id = ?")
+	args := []interface{}{instanceID}
+
+	if filter.FromSequenceID > 0 {
+		q.WriteString(" AND sequence_id >= ?")
+		args = append(args, filter.FromSequenceID)
+	}
+
+	if filter.ToSequenceID > 0 {
+		q.WriteString(" AND sequence_id <= ?")
+		args = append(args, filter.ToSequenceID)
+	}
+
+	if len(filter.EventTypes) > 0 {
+		q.WriteString(" AND event_type IN (?" + strings.Repeat(", ?", len(filter.EventTypes)-1) + ")")
+		for _, t := range filter.EventTypes {
+			args = append(args, t)
+		}
+	}
+
+	q.WriteString(" ORDER BY sequence_id")
+
+	return q.String(), args
+}
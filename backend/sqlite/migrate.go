@@ -0,0 +1,70 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// column is a column added to a table after the table was first released.
+type column struct {
+	table      string
+	name       string
+	definition string
+
+	// backfill, if set, is executed after the column has been added, e.g., to set it for existing rows
+	backfill string
+}
+
+// addedColumns are added to tables of existing databases, which are not changed by the CREATE TABLE IF NOT EXISTS
+// statements of the schema. Columns added to the schema have to be added here, too.
+var addedColumns = []column{
+	{"pending_events", "caused_by", "TEXT NULL", ""},
+	{"history", "caused_by", "TEXT NULL", ""},
+	{"instances", "next_activity_at", "DATETIME NULL", ""},
+	{"instances", "removed_at", "DATETIME NULL", ""},
+	{"activities", "queue", "TEXT NOT NULL DEFAULT ''", ""},
+	{"instances", "hold_reason", "TEXT NULL", ""},
+	{"instances", "held_at", "DATETIME NULL", ""},
+	{"activities", "heartbeat_details", "BLOB NULL", ""},
+	// Events committed before the column was added are ordered by their timestamp
+	{"history", "committed_at", "DATETIME NULL", "UPDATE `history` SET committed_at = timestamp WHERE committed_at IS NULL"},
+}
+
+// addedIndexes are created once the columns they index have been added.
+var addedIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS `idx_instances_removed_at` ON `instances` (`removed_at`)",
+	"CREATE INDEX IF NOT EXISTS `idx_history_committed_at` ON `history` (`committed_at`, `instance_id`)",
+}
+
+// migrate adds columns and indexes missing from databases created with an earlier version of the schema. It's
+// safe to run it for up-to-date databases.
+func migrate(db *sql.DB) error {
+	for _, c := range addedColumns {
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", c.table, c.name).Scan(&n); err != nil {
+			return fmt.Errorf("checking column %s.%s: %w", c.table, c.name, err)
+		}
+
+		if n > 0 {
+			continue
+		}
+
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `%s` %s", c.table, c.name, c.definition)); err != nil {
+			return fmt.Errorf("adding column %s.%s: %w", c.table, c.name, err)
+		}
+
+		if c.backfill != "" {
+			if _, err := db.Exec(c.backfill); err != nil {
+				return fmt.Errorf("backfilling column %s.%s: %w", c.table, c.name, err)
+			}
+		}
+	}
+
+	for _, index := range addedIndexes {
+		if _, err := db.Exec(index); err != nil {
+			return fmt.Errorf("creating index: %w", err)
+		}
+	}
+
+	return nil
+}
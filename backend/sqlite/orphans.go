@@ -0,0 +1,141 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/history"
+)
+
+var _ backend.OrphanedEventCleaner = (*sqliteBackend)(nil)
+
+func (sb *sqliteBackend) OrphanedEvents(ctx context.Context, count int) (*backend.OrphanedEventsReport, error) {
+	report := &backend.OrphanedEventsReport{
+		Counts: map[string]int{},
+		Events: make([]*backend.OrphanedEvent, 0),
+	}
+
+	rows, err := sb.db.QueryContext(
+		ctx,
+		`SELECT event_type, COUNT(*) FROM (
+				SELECT pe.event_type FROM pending_events pe
+					LEFT JOIN instances i ON i.id = pe.instance_id
+					WHERE i.id IS NULL OR i.completed_at IS NOT NULL
+				UNION ALL
+				SELECT a.event_type FROM activities a
+					LEFT JOIN instances i ON i.id = a.instance_id
+					WHERE i.id IS NULL OR i.completed_at IS NOT NULL
+			) o GROUP BY event_type`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("counting orphaned events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var eventType history.EventType
+		var n int
+		if err := rows.Scan(&eventType, &n); err != nil {
+			return nil, fmt.Errorf("scanning orphaned event count: %w", err)
+		}
+
+		report.Counts[eventType.String()] = n
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = sb.db.QueryContext(
+		ctx,
+		`SELECT instance_id, event_id, event_type, schedule_event_id, timestamp, completed_at FROM (
+				SELECT pe.instance_id, pe.id AS event_id, pe.event_type, pe.schedule_event_id, pe.timestamp, i.completed_at
+					FROM pending_events pe
+					LEFT JOIN instances i ON i.id = pe.instance_id
+					WHERE i.id IS NULL OR i.completed_at IS NOT NULL
+				UNION ALL
+				SELECT a.instance_id, a.id AS event_id, a.event_type, a.schedule_event_id, a.timestamp, i.completed_at
+					FROM activities a
+					LEFT JOIN instances i ON i.id = a.instance_id
+					WHERE i.id IS NULL OR i.completed_at IS NOT NULL
+			) o ORDER BY timestamp LIMIT ?`,
+		count,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing orphaned events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := &backend.OrphanedEvent{}
+
+		var eventType history.EventType
+		var completedAt sql.NullTime
+		if err := rows.Scan(&e.InstanceID, &e.EventID, &eventType, &e.ScheduleEventID, &e.Timestamp, &completedAt); err != nil {
+			return nil, fmt.Errorf("scanning orphaned event: %w", err)
+		}
+
+		e.EventType = eventType.String()
+		if completedAt.Valid {
+			e.InstanceCompletedAt = completedAt.Time
+		}
+
+		report.Events = append(report.Events, e)
+	}
+
+	return report, rows.Err()
+}
+
+func (sb *sqliteBackend) DeleteOrphanedEvents(ctx context.Context, completedBefore time.Time) (int, error) {
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM pending_events WHERE
+			instance_id IN (SELECT id FROM instances WHERE completed_at IS NOT NULL AND completed_at < ?)
+			OR instance_id NOT IN (SELECT id FROM instances)`,
+		completedBefore,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("deleting orphaned events: %w", err)
+	}
+
+	events, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	// Activities locked by a worker are left alone, their worker removes them when it completes them
+	res, err = tx.ExecContext(
+		ctx,
+		`DELETE FROM activities WHERE
+			(locked_until IS NULL OR locked_until < ?)
+			AND (
+				instance_id IN (SELECT id FROM instances WHERE completed_at IS NOT NULL AND completed_at < ?)
+				OR instance_id NOT IN (SELECT id FROM instances)
+			)`,
+		sb.options.LockExpiryCutoff(sb.options.Now()),
+		completedBefore,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("deleting orphaned activities: %w", err)
+	}
+
+	activities, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return int(events + activities), nil
+}
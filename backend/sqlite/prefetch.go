@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+var _ backend.PrefetchHinter = (*sqliteBackend)(nil)
+
+func (sb *sqliteBackend) AddPrefetchHints(ctx context.Context, instances []*workflow.Instance, until time.Time) error {
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, instance := range instances {
+		if _, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO `prefetch_hints` (`instance_id`, `execution_id`, `until`) VALUES (?, ?, ?) ON CONFLICT DO UPDATE SET `until` = excluded.`until`",
+			instance.InstanceID, instance.ExecutionID, until,
+		); err != nil {
+			return fmt.Errorf("adding prefetch hint: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (sb *sqliteBackend) PrefetchHints(ctx context.Context) ([]*workflow.Instance, error) {
+	now := sb.options.Now()
+
+	if _, err := sb.db.ExecContext(ctx, "DELETE FROM `prefetch_hints` WHERE `until` <= ?", now); err != nil {
+		return nil, fmt.Errorf("removing expired prefetch hints: %w", err)
+	}
+
+	rows, err := sb.db.QueryContext(ctx, "SELECT `instance_id`, `execution_id` FROM `prefetch_hints` WHERE `until` > ?", now)
+	if err != nil {
+		return nil, fmt.Errorf("getting prefetch hints: %w", err)
+	}
+	defer rows.Close()
+
+	var instances []*workflow.Instance
+	for rows.Next() {
+		var instanceID, executionID string
+		if err := rows.Scan(&instanceID, &executionID); err != nil {
+			return nil, fmt.Errorf("scanning prefetch hint: %w", err)
+		}
+
+		instances = append(instances, core.NewWorkflowInstance(instanceID, executionID))
+	}
+
+	return instances, rows.Err()
+}
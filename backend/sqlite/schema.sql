@@ -8,11 +8,16 @@ CREATE TABLE IF NOT EXISTS `instances` (
   `completed_at` DATETIME NULL,
   `locked_until` DATETIME NULL,
   `sticky_until` DATETIME NULL,
-  `worker` TEXT NULL
+  `worker` TEXT NULL,
+  `next_activity_at` DATETIME NULL,
+  `removed_at` DATETIME NULL,
+  `hold_reason` TEXT NULL,
+  `held_at` DATETIME NULL
 );
 
 CREATE INDEX IF NOT EXISTS `idx_instances_locked_until_completed_at` ON `instances` (`locked_until`, `sticky_until`, `completed_at`, `worker`);
 CREATE INDEX IF NOT EXISTS `idx_instances_parent_instance_id` ON `instances` (`parent_instance_id`);
+CREATE INDEX IF NOT EXISTS `idx_instances_completed_at_sticky_until` ON `instances` (`completed_at`, `sticky_until`);
 
 CREATE TABLE IF NOT EXISTS `pending_events` (
   `id` TEXT,
@@ -23,6 +28,7 @@ CREATE TABLE IF NOT EXISTS `pending_events` (
   `schedule_event_id` INT NOT NULL,
   `attributes` BLOB NOT NULL,
   `visible_at` DATETIME NULL,
+  `caused_by` TEXT NULL,
   PRIMARY KEY(`id`, `instance_id`)
 );
 
@@ -37,11 +43,43 @@ CREATE TABLE IF NOT EXISTS `history` (
   `schedule_event_id` INT NOT NULL,
   `attributes` BLOB NOT NULL,
   `visible_at` DATETIME NULL,
+  `caused_by` TEXT NULL,
+  `committed_at` DATETIME NULL,
   PRIMARY KEY(`id`, `instance_id`)
 );
 
 CREATE INDEX IF NOT EXISTS `idx_history_instance_sequence_id` ON `history` (`instance_id`, `sequence_id`);
 
+CREATE TABLE IF NOT EXISTS `executions` (
+  `instance_id` TEXT NOT NULL,
+  `execution_id` TEXT NOT NULL,
+  `parent_instance_id` TEXT NULL,
+  `parent_schedule_event_id` INTEGER NULL,
+  `metadata` TEXT NULL,
+  `created_at` DATETIME NOT NULL,
+  `completed_at` DATETIME NOT NULL,
+  PRIMARY KEY(`instance_id`, `execution_id`)
+);
+
+CREATE INDEX IF NOT EXISTS `idx_executions_completed_at` ON `executions` (`completed_at`, `instance_id`);
+
+CREATE TABLE IF NOT EXISTS `execution_history` (
+  `id` TEXT,
+  `sequence_id` INTEGER NOT NULL,
+  `instance_id` TEXT NOT NULL,
+  `execution_id` TEXT NOT NULL,
+  `event_type` INTEGER NOT NULL,
+  `timestamp` DATETIME NOT NULL,
+  `schedule_event_id` INT NOT NULL,
+  `attributes` BLOB NOT NULL,
+  `visible_at` DATETIME NULL,
+  `caused_by` TEXT NULL,
+  `committed_at` DATETIME NOT NULL,
+  PRIMARY KEY(`instance_id`, `execution_id`, `sequence_id`)
+);
+
+CREATE INDEX IF NOT EXISTS `idx_execution_history_committed_at` ON `execution_history` (`committed_at`, `instance_id`);
+
 CREATE TABLE IF NOT EXISTS `activities` (
   `id` TEXT PRIMARY KEY,
   `instance_id` TEXT NOT NULL,
@@ -52,5 +90,18 @@ CREATE TABLE IF NOT EXISTS `activities` (
   `attributes` BLOB NOT NULL,
   `visible_at` DATETIME NULL,
   `locked_until` DATETIME NULL,
-  `worker` TEXT NULL
-);
\ No newline at end of file
+  `worker` TEXT NULL,
+  `queue` TEXT NOT NULL DEFAULT '',
+  `heartbeat_details` BLOB NULL
+);
+CREATE TABLE IF NOT EXISTS `leases` (
+  `name` TEXT PRIMARY KEY,
+  `owner` TEXT NOT NULL,
+  `expires_at` DATETIME NOT NULL
+);
+CREATE TABLE IF NOT EXISTS `prefetch_hints` (
+  `instance_id` TEXT NOT NULL,
+  `execution_id` TEXT NOT NULL,
+  `until` DATETIME NOT NULL,
+  PRIMARY KEY (`instance_id`, `execution_id`)
+);
@@ -13,15 +13,16 @@ import (
 	"github.com/cschleiden/go-workflows/backend"
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/historycache"
 	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/internal/sqlinstr"
 	"github.com/cschleiden/go-workflows/internal/task"
 	"github.com/cschleiden/go-workflows/log"
 	"github.com/cschleiden/go-workflows/metrics"
 	"github.com/cschleiden/go-workflows/workflow"
 	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
 	"go.opentelemetry.io/otel/trace"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 //go:embed schema.sql
@@ -40,7 +41,13 @@ func NewSqliteBackend(path string, opts ...backend.BackendOption) *sqliteBackend
 }
 
 func newSqliteBackend(dsn string, opts ...backend.BackendOption) *sqliteBackend {
-	db, err := sql.Open("sqlite3", dsn)
+	options := backend.ApplyOptions(opts...)
+
+	db, err := sqlinstr.Open(&sqlite3.SQLiteDriver{}, dsn, sqlinstr.Options{
+		Logger:             options.Logger,
+		Metrics:            options.Metrics.WithTags(metrics.Tags{metrickeys.Backend: "sqlite"}),
+		SlowQueryThreshold: options.SlowQueryThreshold,
+	})
 	if err != nil {
 		panic(err)
 	}
@@ -50,10 +57,14 @@ func newSqliteBackend(dsn string, opts ...backend.BackendOption) *sqliteBackend
 		panic(err)
 	}
 
+	if err := migrate(db); err != nil {
+		panic(fmt.Errorf("migrating database: %w", err))
+	}
+
 	return &sqliteBackend{
 		db:         db,
 		workerName: fmt.Sprintf("worker-%v", uuid.NewString()),
-		options:    backend.ApplyOptions(opts...),
+		options:    options,
 	}
 }
 
@@ -67,6 +78,18 @@ func (sb *sqliteBackend) Logger() log.Logger {
 	return sb.options.Logger
 }
 
+var _ backend.RedactorProvider = (*sqliteBackend)(nil)
+
+func (sb *sqliteBackend) Redactor() backend.Redactor {
+	return sb.options.Redactor
+}
+
+var _ backend.Clock = (*sqliteBackend)(nil)
+
+func (sb *sqliteBackend) Now() time.Time {
+	return sb.options.Now()
+}
+
 func (sb *sqliteBackend) Metrics() metrics.Client {
 	return sb.options.Metrics.WithTags(metrics.Tags{metrickeys.Backend: "sqlite"})
 }
@@ -76,19 +99,33 @@ func (sb *sqliteBackend) Tracer() trace.Tracer {
 }
 
 func (sb *sqliteBackend) CreateWorkflowInstance(ctx context.Context, instance *workflow.Instance, event history.Event) error {
+	return sb.CreateWorkflowInstances(ctx, []history.WorkflowEvent{{WorkflowInstance: instance, HistoryEvent: event}})
+}
+
+func (sb *sqliteBackend) CreateWorkflowInstances(ctx context.Context, instances []history.WorkflowEvent) error {
 	tx, err := sb.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("starting transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Create workflow instance
-	if err := createInstance(ctx, tx, instance, event.Attributes.(*history.ExecutionStartedAttributes).Metadata, false); err != nil {
-		return err
-	}
+	for _, i := range instances {
+		instance, event := i.WorkflowInstance, i.HistoryEvent
+		ctx := sqlinstr.WithInstanceID(ctx, instance.InstanceID)
 
-	if err := insertPendingEvents(ctx, tx, instance.InstanceID, []history.Event{event}); err != nil {
-		return fmt.Errorf("inserting new event: %w", err)
+		a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&event)
+		if err != nil {
+			return err
+		}
+
+		// Create workflow instance
+		if err := createInstance(ctx, tx, instance, a.Metadata, sb.options.Now()); err != nil {
+			return err
+		}
+
+		if err := insertPendingEvents(ctx, tx, instance.InstanceID, []history.Event{event}); err != nil {
+			return fmt.Errorf("inserting new event: %w", err)
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -98,7 +135,7 @@ func (sb *sqliteBackend) CreateWorkflowInstance(ctx context.Context, instance *w
 	return nil
 }
 
-func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, metadata *workflow.Metadata, ignoreDuplicate bool) error {
+func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, metadata *workflow.Metadata, createdAt time.Time) error {
 	var parentInstanceID *string
 	var parentEventID *int64
 	if wfi.SubWorkflow() {
@@ -116,32 +153,33 @@ func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, met
 
 	res, err := tx.ExecContext(
 		ctx,
-		"INSERT OR IGNORE INTO `instances` (id, execution_id, parent_instance_id, parent_schedule_event_id, metadata) VALUES (?, ?, ?, ?, ?)",
+		"INSERT OR IGNORE INTO `instances` (id, execution_id, parent_instance_id, parent_schedule_event_id, metadata, created_at) VALUES (?, ?, ?, ?, ?, ?)",
 		wfi.InstanceID,
 		wfi.ExecutionID,
 		parentInstanceID,
 		parentEventID,
 		string(metadataJson),
+		createdAt,
 	)
 	if err != nil {
 		return fmt.Errorf("inserting workflow instance: %w", err)
 	}
 
-	if !ignoreDuplicate {
-		rows, err := res.RowsAffected()
-		if err != nil {
-			return err
-		}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
 
-		if rows != 1 {
-			return backend.ErrInstanceAlreadyExists
-		}
+	if rows != 1 {
+		return backend.ErrInstanceAlreadyExists
 	}
 
 	return nil
 }
 
 func (sb *sqliteBackend) CancelWorkflowInstance(ctx context.Context, instance *workflow.Instance, event *history.Event) error {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
 	tx, err := sb.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -168,26 +206,49 @@ func (sb *sqliteBackend) CancelWorkflowInstance(ctx context.Context, instance *w
 }
 
 func (sb *sqliteBackend) GetWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, lastSequenceID *int64) ([]history.Event, error) {
-	tx, err := sb.db.BeginTx(ctx, nil)
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback()
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
 
-	h, err := getHistory(ctx, tx, instance.InstanceID, lastSequenceID)
-	if err != nil {
-		return nil, fmt.Errorf("getting workflow history: %w", err)
+	latest := func() (int64, error) {
+		tx, err := sb.db.BeginTx(ctx, nil)
+		if err != nil {
+			return 0, err
+		}
+		defer tx.Rollback()
+
+		if err := checkNotRemoved(ctx, tx, instance.InstanceID); err != nil {
+			return 0, err
+		}
+
+		return getLastSequenceID(ctx, tx, instance.InstanceID)
 	}
 
-	return h, nil
+	return historycache.Get(ctx, sb.options.HistoryCache, sb.Logger(), sb.Metrics(), instance.InstanceID, lastSequenceID, latest, func() ([]history.Event, error) {
+		tx, err := sb.db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		if err := checkNotRemoved(ctx, tx, instance.InstanceID); err != nil {
+			return nil, err
+		}
+
+		h, err := getHistory(ctx, tx, instance.InstanceID, lastSequenceID)
+		if err != nil {
+			return nil, fmt.Errorf("getting workflow history: %w", err)
+		}
+
+		return h, nil
+	})
 }
 
 func (s *sqliteBackend) GetWorkflowInstanceState(ctx context.Context, instance *workflow.Instance) (core.WorkflowInstanceState, error) {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
 	row := s.db.QueryRowContext(
 		ctx,
-		"SELECT completed_at FROM instances WHERE id = ? AND execution_id = ?",
+		"SELECT completed_at FROM instances WHERE id = ? AND removed_at IS NULL",
 		instance.InstanceID,
-		instance.ExecutionID,
 	)
 
 	var completedAt sql.NullTime
@@ -205,6 +266,8 @@ func (s *sqliteBackend) GetWorkflowInstanceState(ctx context.Context, instance *
 }
 
 func (sb *sqliteBackend) SignalWorkflow(ctx context.Context, instanceID string, event history.Event) error {
+	ctx = sqlinstr.WithInstanceID(ctx, instanceID)
+
 	tx, err := sb.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -232,8 +295,11 @@ func (sb *sqliteBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, e
 	defer tx.Rollback()
 
 	// Lock next workflow task by finding an unlocked instance with new events to process
-	// (work around missing LIMIT support in sqlite driver for UPDATE statements by using sub-query)
-	now := time.Now()
+	// (work around missing LIMIT support in sqlite driver for UPDATE statements by using sub-query).
+	// Instances are ordered by when they were last unlocked, so that one instance with a constant stream
+	// of new events does not starve the others.
+	now := sb.options.Now()
+	cutoff := sb.options.LockExpiryCutoff(now)
 	row := tx.QueryRowContext(
 		ctx,
 		`UPDATE instances
@@ -249,11 +315,12 @@ func (sb *sqliteBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, e
 								FROM pending_events
 								WHERE instance_id = i.id AND execution_id = i.execution_id AND (visible_at IS NULL OR visible_at <= ?)
 						)
+					ORDER BY sticky_until
 					LIMIT 1
 			) RETURNING id, execution_id, parent_instance_id, parent_schedule_event_id, metadata, sticky_until`,
 		now.Add(sb.options.WorkflowLockTimeout), // new locked_until
 		sb.workerName,
-		now,           // locked_until
+		cutoff,        // locked_until
 		now,           // sticky_until
 		sb.workerName, // worker
 		now,           // event.visible_at
@@ -269,6 +336,8 @@ func (sb *sqliteBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, e
 			return nil, nil
 		}
 
+		sb.recordLockContention("workflow_task", err)
+
 		return nil, fmt.Errorf("locking workflow task: %w", err)
 	}
 
@@ -294,8 +363,10 @@ func (sb *sqliteBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, e
 		NewEvents:             []history.Event{},
 	}
 
+	ctx = sqlinstr.WithInstanceID(ctx, instanceID)
+
 	// Get new events
-	pendingEvents, err := getPendingEvents(ctx, tx, instanceID)
+	pendingEvents, err := getPendingEvents(ctx, tx, instanceID, now)
 	if err != nil {
 		return nil, fmt.Errorf("getting pending events: %w", err)
 	}
@@ -309,11 +380,8 @@ func (sb *sqliteBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, e
 
 	// Get only most recent sequence ID
 	// TODO: Denormalize to instances table
-	row = tx.QueryRowContext(ctx, "SELECT sequence_id FROM `history` WHERE instance_id = ? ORDER BY rowid DESC LIMIT 1", instanceID)
-	if err := row.Scan(&t.LastSequenceID); err != nil {
-		if err != sql.ErrNoRows {
-			return nil, fmt.Errorf("getting most recent sequence id: %w", err)
-		}
+	if t.LastSequenceID, err = getLastSequenceID(ctx, tx, instanceID); err != nil {
+		return nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -333,6 +401,38 @@ func (sb *sqliteBackend) CompleteWorkflowTask(
 	executedEvents, activityEvents, timerEvents []history.Event,
 	workflowEvents []history.WorkflowEvent,
 ) error {
+	return sb.completeWorkflowTask(ctx, task, instance, state, false, executedEvents, activityEvents, timerEvents, workflowEvents)
+}
+
+var _ backend.WorkflowTaskCheckpointer = (*sqliteBackend)(nil)
+
+// CheckpointWorkflowTask commits the progress of a workflow task like CompleteWorkflowTask, but keeps the instance
+// locked
+func (sb *sqliteBackend) CheckpointWorkflowTask(
+	ctx context.Context,
+	task *task.Workflow,
+	instance *workflow.Instance,
+	executedEvents, activityEvents, timerEvents []history.Event,
+	workflowEvents []history.WorkflowEvent,
+) error {
+	return sb.completeWorkflowTask(
+		ctx, task, instance, core.WorkflowInstanceStateActive, true, executedEvents, activityEvents, timerEvents, workflowEvents)
+}
+
+func (sb *sqliteBackend) completeWorkflowTask(
+	ctx context.Context,
+	task *task.Workflow,
+	instance *workflow.Instance,
+	state core.WorkflowInstanceState,
+	keepLocked bool,
+	executedEvents, activityEvents, timerEvents []history.Event,
+	workflowEvents []history.WorkflowEvent,
+) error {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
+	// Timers fire after their delay according to the clock of the backend, not the one of the worker
+	timerEvents = backend.RebaseFutureEvents(sb.options.Now(), timerEvents)
+
 	tx, err := sb.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -341,20 +441,33 @@ func (sb *sqliteBackend) CompleteWorkflowTask(
 
 	var completedAt *time.Time
 	if state == core.WorkflowInstanceStateFinished {
-		t := time.Now()
+		t := sb.options.Now()
 		completedAt = &t
 	}
 
-	// Unlock instance, but keep it sticky to the current worker
-	if res, err := tx.ExecContext(
-		ctx,
-		`UPDATE instances SET locked_until = NULL, sticky_until = ?, completed_at = ? WHERE id = ? AND execution_id = ? AND worker = ?`,
-		time.Now().Add(sb.options.StickyTimeout),
-		completedAt,
-		instance.InstanceID,
-		instance.ExecutionID,
-		sb.workerName,
-	); err != nil {
+	// Unlock instance, but keep it sticky to the current worker. Checkpoints extend the lock instead.
+	var res sql.Result
+	if keepLocked {
+		res, err = tx.ExecContext(
+			ctx,
+			`UPDATE instances SET locked_until = ? WHERE id = ? AND execution_id = ? AND worker = ?`,
+			sb.options.Now().Add(sb.options.WorkflowLockTimeout),
+			instance.InstanceID,
+			instance.ExecutionID,
+			sb.workerName,
+		)
+	} else {
+		res, err = tx.ExecContext(
+			ctx,
+			`UPDATE instances SET locked_until = NULL, sticky_until = ?, completed_at = ? WHERE id = ? AND execution_id = ? AND worker = ?`,
+			sb.options.Now().Add(sb.options.StickyTimeout),
+			completedAt,
+			instance.InstanceID,
+			instance.ExecutionID,
+			sb.workerName,
+		)
+	}
+	if err != nil {
 		return fmt.Errorf("unlocking workflow instance: %w", err)
 	} else if n, err := res.RowsAffected(); err != nil {
 		return fmt.Errorf("checking for unlocked workflow instances: %w", err)
@@ -380,15 +493,35 @@ func (sb *sqliteBackend) CompleteWorkflowTask(
 	}
 
 	// Add events from last execution to history
-	if err := insertHistoryEvents(ctx, tx, instance.InstanceID, executedEvents); err != nil {
+	if err := insertHistoryEvents(ctx, tx, instance.InstanceID, executedEvents, sb.options.Now()); err != nil {
 		return fmt.Errorf("inserting new history events: %w", err)
 	}
 
 	// Schedule activities
-	for _, event := range activityEvents {
-		if err := scheduleActivity(ctx, tx, instance.InstanceID, instance.ExecutionID, event); err != nil {
-			return fmt.Errorf("scheduling activity: %w", err)
+	visibleAt, err := sb.throttleActivities(ctx, tx, instance.InstanceID, len(activityEvents))
+	if err != nil {
+		return err
+	}
+
+	if visibleAt != nil {
+		throttled := make([]history.Event, len(activityEvents))
+		for i, event := range activityEvents {
+			event.VisibleAt = &visibleAt[i]
+			throttled[i] = event
 		}
+
+		activityEvents = throttled
+		timerEvents = backend.DelayActivityTimeouts(activityEvents, timerEvents)
+	}
+
+	if err := scheduleActivities(ctx, tx, instance.InstanceID, instance.ExecutionID, activityEvents); err != nil {
+		return fmt.Errorf("scheduling activities: %w", err)
+	}
+
+	// Remove canceled and rescheduled timers and obsolete activity timeouts before scheduling new ones,
+	// rescheduled timers are replaced by a new timer event with the same schedule event id.
+	if err := removeFutureEventsOf(ctx, tx, instance.InstanceID, backend.RemovedFutureEvents(executedEvents)); err != nil {
+		return fmt.Errorf("removing future events: %w", err)
 	}
 
 	// Timer events
@@ -396,12 +529,11 @@ func (sb *sqliteBackend) CompleteWorkflowTask(
 		return fmt.Errorf("scheduling timers: %w", err)
 	}
 
-	for _, event := range executedEvents {
-		switch event.Type {
-		case history.EventType_TimerCanceled:
-			if err := removeFutureEvent(ctx, tx, instance.InstanceID, event.ScheduleEventID); err != nil {
-				return fmt.Errorf("removing future event: %w", err)
-			}
+	// The instance won't be executed again, remove any events scheduled for the future, e.g., timers that have not
+	// fired yet.
+	if state == core.WorkflowInstanceStateFinished {
+		if err := removeFutureEvents(ctx, tx, instance.InstanceID); err != nil {
+			return fmt.Errorf("removing future events: %w", err)
 		}
 	}
 
@@ -409,14 +541,51 @@ func (sb *sqliteBackend) CompleteWorkflowTask(
 	groupedEvents := history.EventsByWorkflowInstanceID(workflowEvents)
 
 	for targetInstanceID, events := range groupedEvents {
-		for _, m := range events {
+		for i, m := range events {
 			if m.HistoryEvent.Type == history.EventType_WorkflowExecutionStarted {
-				a := m.HistoryEvent.Attributes.(*history.ExecutionStartedAttributes)
-				// Create new instance
-				if err := createInstance(ctx, tx, m.WorkflowInstance, a.Metadata, true); err != nil {
+				a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&m.HistoryEvent)
+				if err != nil {
 					return err
 				}
 
+				if targetInstanceID == instance.InstanceID {
+					// The instance continued as new, replace the current execution
+					canceled, err := continueAsNew(ctx, tx, instance, m.WorkflowInstance, a.Metadata, sb.options.Now())
+					if err != nil {
+						return err
+					}
+
+					// Cancellations requested for the previous execution also apply to the new one
+					cancellations := make([]history.WorkflowEvent, 0, len(canceled)+len(events)-i-1)
+					for _, event := range canceled {
+						cancellations = append(cancellations, history.WorkflowEvent{WorkflowInstance: m.WorkflowInstance, HistoryEvent: event})
+					}
+					events = append(events[:i+1:i+1], append(cancellations, events[i+1:]...)...)
+
+					break
+				}
+
+				// Create new instance. If the derived instance ID of a sub-workflow is already taken, fail the
+				// sub-workflow in the parent instead of starting the existing instance again. Explicit instance IDs
+				// deliver the start event to the existing instance.
+				if err := createInstance(ctx, tx, m.WorkflowInstance, a.Metadata, sb.options.Now()); err != nil {
+					if !errors.Is(err, backend.ErrInstanceAlreadyExists) {
+						return err
+					}
+
+					if !a.DerivedInstanceID {
+						break
+					}
+
+					if err := insertPendingEvents(ctx, tx, instance.InstanceID, []history.Event{
+						backend.SubWorkflowAlreadyExistsEvent(sb.options.Now(), m.WorkflowInstance),
+					}); err != nil {
+						return fmt.Errorf("failing sub-workflow: %w", err)
+					}
+
+					events = append(events[:i:i], events[i+1:]...)
+				}
+
 				break
 			}
 		}
@@ -431,17 +600,25 @@ func (sb *sqliteBackend) CompleteWorkflowTask(
 		}
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	historycache.Append(ctx, sb.options.HistoryCache, sb.Logger(), instance.InstanceID, executedEvents, state == core.WorkflowInstanceStateFinished)
+
+	return nil
 }
 
 func (sb *sqliteBackend) ExtendWorkflowTask(ctx context.Context, taskID string, instance *workflow.Instance) error {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
 	tx, err := sb.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	until := time.Now().Add(sb.options.WorkflowLockTimeout)
+	until := sb.options.Now().Add(sb.options.WorkflowLockTimeout)
 	res, err := tx.ExecContext(
 		ctx,
 		`UPDATE instances SET locked_until = ? WHERE id = ? AND execution_id = ? AND worker = ?`,
@@ -463,6 +640,58 @@ func (sb *sqliteBackend) ExtendWorkflowTask(ctx context.Context, taskID string,
 	return tx.Commit()
 }
 
+func (sb *sqliteBackend) AbandonWorkflowTask(ctx context.Context, t *task.Workflow, delay time.Duration) error {
+	ctx = sqlinstr.WithInstanceID(ctx, t.WorkflowInstance.InstanceID)
+
+	// Keep the lock until the delay has passed, after that any worker can pick up the task again
+	res, err := sb.db.ExecContext(
+		ctx,
+		`UPDATE instances SET locked_until = ? WHERE id = ? AND execution_id = ? AND worker = ?`,
+		sb.options.Now().Add(delay),
+		t.WorkflowInstance.InstanceID,
+		t.WorkflowInstance.ExecutionID,
+		sb.workerName,
+	)
+	if err != nil {
+		return fmt.Errorf("abandoning workflow task: %w", err)
+	}
+
+	if rowsAffected, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("determining if workflow task was abandoned: %w", err)
+	} else if rowsAffected == 0 {
+		return errors.New("could not abandon workflow task")
+	}
+
+	return nil
+}
+
+func (sb *sqliteBackend) ParkWorkflowTask(ctx context.Context, t *task.Workflow, delay time.Duration) error {
+	ctx = sqlinstr.WithInstanceID(ctx, t.WorkflowInstance.InstanceID)
+
+	// Keep the lock until the delay has passed, and the instance sticky to this worker for a while after that
+	lockedUntil := sb.options.Now().Add(delay)
+	res, err := sb.db.ExecContext(
+		ctx,
+		`UPDATE instances SET locked_until = ?, sticky_until = ? WHERE id = ? AND execution_id = ? AND worker = ?`,
+		lockedUntil,
+		lockedUntil.Add(sb.options.StickyTimeout),
+		t.WorkflowInstance.InstanceID,
+		t.WorkflowInstance.ExecutionID,
+		sb.workerName,
+	)
+	if err != nil {
+		return fmt.Errorf("parking workflow task: %w", err)
+	}
+
+	if rowsAffected, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("determining if workflow task was parked: %w", err)
+	} else if rowsAffected == 0 {
+		return errors.New("could not park workflow task")
+	}
+
+	return nil
+}
+
 func (sb *sqliteBackend) GetActivityTask(ctx context.Context) (*task.Activity, error) {
 	tx, err := sb.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -472,41 +701,51 @@ func (sb *sqliteBackend) GetActivityTask(ctx context.Context) (*task.Activity, e
 
 	// Lock next activity
 	// (work around missing LIMIT support in sqlite driver for UPDATE statements by using sub-query)
-	now := time.Now()
+	now := sb.options.Now()
+	cutoff := sb.options.LockExpiryCutoff(now)
+	queues := sb.options.ServedActivityQueues()
+	args := []interface{}{
+		now.Add(sb.options.ActivityLockTimeout),
+		sb.workerName,
+		cutoff, // locked_until
+		now,    // visible_at
+	}
+	for _, queue := range queues {
+		args = append(args, queue)
+	}
+
 	row := tx.QueryRowContext(
 		ctx,
 		`UPDATE activities
 			SET locked_until = ?, worker = ?
 			WHERE rowid = (
-				SELECT rowid FROM activities WHERE locked_until IS NULL OR locked_until < ? LIMIT 1
-			) RETURNING id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at`,
-		now.Add(sb.options.ActivityLockTimeout),
-		sb.workerName,
-		now,
+				SELECT rowid FROM activities
+					WHERE (locked_until IS NULL OR locked_until < ?) AND (visible_at IS NULL OR visible_at <= ?)
+						AND queue IN (?`+strings.Repeat(", ?", len(queues)-1)+`)
+					LIMIT 1
+			) RETURNING id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at, heartbeat_details`,
+		args...,
 	)
 	if err != nil {
 		return nil, err
 	}
 
 	var instanceID, executionID string
-	var attributes []byte
+	var attributes, heartbeatDetails []byte
 	event := history.Event{}
 
-	if err := row.Scan(&event.ID, &instanceID, &executionID, &event.Type, &event.Timestamp, &event.ScheduleEventID, &attributes, &event.VisibleAt); err != nil {
+	if err := row.Scan(&event.ID, &instanceID, &executionID, &event.Type, &event.Timestamp, &event.ScheduleEventID, &attributes, &event.VisibleAt, &heartbeatDetails); err != nil {
 		if err == sql.ErrNoRows {
 			// No rows locked, just return
 			return nil, nil
 		}
 
-		return nil, fmt.Errorf("scanning event: %w", err)
-	}
+		sb.recordLockContention("activity_task", err)
 
-	a, err := history.DeserializeAttributes(event.Type, attributes)
-	if err != nil {
-		return nil, fmt.Errorf("deserializing attributes: %w", err)
+		return nil, fmt.Errorf("scanning event: %w", err)
 	}
 
-	event.Attributes = a
+	event.SetSerializedAttributes(attributes)
 
 	var metadataJson sql.NullString
 	if err := tx.QueryRowContext(ctx, "SELECT metadata FROM instances WHERE id = ?", instanceID).Scan(&metadataJson); err != nil {
@@ -523,6 +762,7 @@ func (sb *sqliteBackend) GetActivityTask(ctx context.Context) (*task.Activity, e
 		WorkflowInstance: core.NewWorkflowInstance(instanceID, executionID),
 		Metadata:         metadata,
 		Event:            event,
+		HeartbeatDetails: heartbeatDetails,
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -533,6 +773,8 @@ func (sb *sqliteBackend) GetActivityTask(ctx context.Context) (*task.Activity, e
 }
 
 func (sb *sqliteBackend) CompleteActivityTask(ctx context.Context, instance *workflow.Instance, id string, event history.Event) error {
+	ctx = sqlinstr.WithInstanceID(ctx, instance.InstanceID)
+
 	tx, err := sb.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -554,6 +796,21 @@ func (sb *sqliteBackend) CompleteActivityTask(ctx context.Context, instance *wor
 		return errors.New("could not find activity to delete")
 	}
 
+	// Drop the result if the instance continued as new since the activity was scheduled
+	var current int
+	if err := tx.QueryRowContext(
+		ctx,
+		"SELECT COUNT(*) FROM instances WHERE id = ? AND execution_id = ?",
+		instance.InstanceID,
+		instance.ExecutionID,
+	).Scan(&current); err != nil {
+		return fmt.Errorf("checking workflow instance execution: %w", err)
+	}
+
+	if current == 0 {
+		return tx.Commit()
+	}
+
 	// Insert new event generated during this workflow execution
 	if err := insertPendingEvents(ctx, tx, instance.InstanceID, []history.Event{event}); err != nil {
 		return fmt.Errorf("inserting new events for completed activity: %w", err)
@@ -569,7 +826,7 @@ func (sb *sqliteBackend) ExtendActivityTask(ctx context.Context, activityID stri
 	}
 	defer tx.Rollback()
 
-	until := time.Now().Add(sb.options.ActivityLockTimeout)
+	until := sb.options.Now().Add(sb.options.ActivityLockTimeout)
 	res, err := tx.ExecContext(
 		ctx,
 		`UPDATE activities SET locked_until = ? WHERE id = ? AND worker = ?`,
@@ -1,13 +1,22 @@
 package sqlite
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
 	"fmt"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/cschleiden/go-workflows/backend"
 	"github.com/cschleiden/go-workflows/backend/test"
+	"github.com/cschleiden/go-workflows/client"
+	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/task"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_SqliteBackend(t *testing.T) {
@@ -24,6 +33,512 @@ func Test_EndToEndSqliteBackend(t *testing.T) {
 	}, nil)
 }
 
+func Benchmark_CompleteWorkflowTask(b *testing.B) {
+	for _, events := range []int{2, 20, 60} {
+		b.Run(fmt.Sprintf("events=%d", events), func(b *testing.B) {
+			test.CompleteWorkflowTaskBenchmark(b, func() test.TestBackend {
+				return NewInMemoryBackend(backend.WithStickyTimeout(0))
+			}, nil, events)
+		})
+	}
+}
+
+func Test_InstanceActivityRate(t *testing.T) {
+	b := NewInMemoryBackend(backend.WithStickyTimeout(0), backend.WithInstanceActivityRate(10))
+	ctx := context.Background()
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.CreateWorkflowInstance(
+		ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	))
+
+	tk, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+
+	activityEvents := []history.Event{
+		history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{}, history.ScheduleEventID(1)),
+		history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{}, history.ScheduleEventID(2)),
+	}
+	require.NoError(t, b.CompleteWorkflowTask(
+		ctx, tk, wfi, core.WorkflowInstanceStateActive, tk.NewEvents, activityEvents, []history.Event{}, []history.WorkflowEvent{}))
+
+	// Only the first activity is visible right away
+	at, err := b.GetActivityTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, at)
+	require.Equal(t, int64(1), at.Event.ScheduleEventID)
+
+	at, err = b.GetActivityTask(ctx)
+	require.NoError(t, err)
+	require.Nil(t, at)
+
+	time.Sleep(100 * time.Millisecond)
+
+	at, err = b.GetActivityTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, at)
+	require.Equal(t, int64(2), at.Event.ScheduleEventID)
+}
+
+func Test_InstanceActivityRate_DelaysScheduleToStartTimeouts(t *testing.T) {
+	b := NewInMemoryBackend(backend.WithStickyTimeout(0), backend.WithInstanceActivityRate(10))
+	ctx := context.Background()
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.CreateWorkflowInstance(
+		ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	))
+
+	tk, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+
+	now := time.Now()
+	activityEvents := []history.Event{
+		history.NewPendingEvent(now, history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{}, history.ScheduleEventID(1)),
+		history.NewPendingEvent(now, history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{}, history.ScheduleEventID(2)),
+	}
+	timerEvents := []history.Event{
+		history.NewPendingEvent(now, history.EventType_ActivityFailed, &history.ActivityFailedAttributes{
+			Timeout: history.ActivityTimeout_ScheduleToStart,
+		}, history.ScheduleEventID(2), history.VisibleAt(now.Add(time.Minute))),
+	}
+	require.NoError(t, b.CompleteWorkflowTask(
+		ctx, tk, wfi, core.WorkflowInstanceStateActive, tk.NewEvents, activityEvents, timerEvents, []history.WorkflowEvent{}))
+
+	// The second activity becomes visible later, its timeout is moved by the same delay
+	var visibleAt time.Time
+	require.NoError(t, b.db.QueryRowContext(ctx,
+		"SELECT visible_at FROM `pending_events` WHERE instance_id = ? AND event_type = ?",
+		wfi.InstanceID, history.EventType_ActivityFailed,
+	).Scan(&visibleAt))
+	require.WithinDuration(t, now.Add(time.Minute+100*time.Millisecond), visibleAt, 50*time.Millisecond)
+}
+
+func Test_ActivityStarted_RemovesScheduleToStartTimeout(t *testing.T) {
+	b := NewInMemoryBackend(backend.WithStickyTimeout(0))
+	ctx := context.Background()
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.CreateWorkflowInstance(
+		ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	))
+
+	tk, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+
+	now := time.Now()
+	activityEvents := []history.Event{
+		history.NewPendingEvent(now, history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{}, history.ScheduleEventID(1)),
+	}
+	timerEvents := []history.Event{
+		history.NewPendingEvent(now, history.EventType_ActivityFailed, &history.ActivityFailedAttributes{
+			Timeout: history.ActivityTimeout_ScheduleToStart,
+		}, history.ScheduleEventID(1), history.VisibleAt(now.Add(time.Minute))),
+	}
+	require.NoError(t, b.CompleteWorkflowTask(
+		ctx, tk, wfi, core.WorkflowInstanceStateActive, tk.NewEvents, activityEvents, timerEvents, []history.WorkflowEvent{}))
+
+	require.NoError(t, b.SignalWorkflow(ctx, wfi.InstanceID,
+		history.NewPendingEvent(time.Now(), history.EventType_ActivityStarted, &history.ActivityStartedAttributes{}, history.ScheduleEventID(1))))
+
+	tk, err = b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, tk)
+	require.NoError(t, b.CompleteWorkflowTask(
+		ctx, tk, wfi, core.WorkflowInstanceStateActive, tk.NewEvents, []history.Event{}, []history.Event{}, []history.WorkflowEvent{}))
+
+	// The activity started, its schedule-to-start timeout won't wake the instance
+	var n int
+	require.NoError(t, b.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM `pending_events` WHERE instance_id = ?", wfi.InstanceID,
+	).Scan(&n))
+	require.Zero(t, n)
+}
+
+// completeAsContinued completes the given task of the instance by continuing it as a new execution
+func completeAsContinued(t *testing.T, b *sqliteBackend, tk *task.Workflow, wfi *core.WorkflowInstance) *core.WorkflowInstance {
+	t.Helper()
+
+	newInstance := core.NewWorkflowInstance(wfi.InstanceID, uuid.NewString())
+	executedEvents := append(tk.NewEvents, history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{
+		ContinuedAsNew: newInstance.ExecutionID,
+	}))
+	for i := range executedEvents {
+		executedEvents[i].SequenceID = int64(i + 1)
+	}
+
+	require.NoError(t, b.CompleteWorkflowTask(
+		context.Background(), tk, wfi, core.WorkflowInstanceStateFinished, executedEvents, []history.Event{}, []history.Event{},
+		[]history.WorkflowEvent{{
+			WorkflowInstance: newInstance,
+			HistoryEvent:     history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+		}},
+	))
+
+	return newInstance
+}
+
+func Test_ContinueAsNew_KeepsPendingCancellation(t *testing.T) {
+	b := NewInMemoryBackend(backend.WithStickyTimeout(0))
+	ctx := context.Background()
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.CreateWorkflowInstance(
+		ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	))
+
+	tk, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+
+	// The cancellation arrives while the task continuing the instance is running
+	cancelEvent := history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionCanceled, &history.ExecutionCanceledAttributes{})
+	require.NoError(t, b.CancelWorkflowInstance(ctx, wfi, &cancelEvent))
+
+	newInstance := completeAsContinued(t, b, tk, wfi)
+
+	// The new execution is canceled after it started
+	tk, err = b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, tk)
+	require.Equal(t, newInstance.ExecutionID, tk.WorkflowInstance.ExecutionID)
+	require.Len(t, tk.NewEvents, 2)
+	require.Equal(t, history.EventType_WorkflowExecutionStarted, tk.NewEvents[0].Type)
+	require.Equal(t, history.EventType_WorkflowExecutionCanceled, tk.NewEvents[1].Type)
+}
+
+func Test_ContinueAsNew_InstanceOperations(t *testing.T) {
+	b := NewInMemoryBackend(backend.WithStickyTimeout(0))
+	ctx := context.Background()
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.CreateWorkflowInstance(
+		ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	))
+
+	tk, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+
+	newInstance := completeAsContinued(t, b, tk, wfi)
+
+	// The instance returned when it was created still refers to it after it continued as new
+	require.NoError(t, b.PlaceWorkflowInstanceHold(ctx, wfi, "investigating"))
+
+	hold, err := b.GetWorkflowInstanceHold(ctx, wfi)
+	require.NoError(t, err)
+	require.NotNil(t, hold)
+	require.Equal(t, "investigating", hold.Reason)
+
+	require.NoError(t, b.ReleaseWorkflowInstanceHold(ctx, wfi))
+
+	_, err = b.CompactWorkflowInstanceHistory(ctx, wfi, 0)
+	require.NoError(t, err)
+
+	tk, err = b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, tk)
+	require.Equal(t, newInstance.ExecutionID, tk.WorkflowInstance.ExecutionID)
+
+	executedEvents := append(tk.NewEvents, history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{}))
+	for i := range executedEvents {
+		executedEvents[i].SequenceID = int64(i + 1)
+	}
+	require.NoError(t, b.CompleteWorkflowTask(
+		ctx, tk, newInstance, core.WorkflowInstanceStateFinished, executedEvents, []history.Event{}, []history.Event{}, []history.WorkflowEvent{}))
+
+	require.NoError(t, b.RemoveWorkflowInstance(ctx, wfi))
+	require.NoError(t, b.UndeleteWorkflowInstance(ctx, wfi))
+}
+
+func Test_SubWorkflow_DuplicateInstanceID(t *testing.T) {
+	for _, derived := range []bool{true, false} {
+		t.Run(fmt.Sprintf("derived=%v", derived), func(t *testing.T) {
+			b := NewInMemoryBackend(backend.WithStickyTimeout(0))
+			ctx := context.Background()
+
+			existing := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+			require.NoError(t, b.CreateWorkflowInstance(
+				ctx, existing, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+			))
+
+			wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+			require.NoError(t, b.CreateWorkflowInstance(
+				ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+			))
+
+			// Complete the task of the existing instance, so that only the parent has pending events
+			tk, err := b.GetWorkflowTask(ctx)
+			require.NoError(t, err)
+			require.Equal(t, existing.InstanceID, tk.WorkflowInstance.InstanceID)
+			require.NoError(t, b.CompleteWorkflowTask(
+				ctx, tk, existing, core.WorkflowInstanceStateActive, tk.NewEvents, []history.Event{}, []history.Event{}, []history.WorkflowEvent{}))
+
+			tk, err = b.GetWorkflowTask(ctx)
+			require.NoError(t, err)
+			require.Equal(t, wfi.InstanceID, tk.WorkflowInstance.InstanceID)
+
+			sub := core.NewSubWorkflowInstance(existing.InstanceID, uuid.NewString(), wfi.InstanceID, 1)
+			require.NoError(t, b.CompleteWorkflowTask(
+				ctx, tk, wfi, core.WorkflowInstanceStateActive, tk.NewEvents, []history.Event{}, []history.Event{},
+				[]history.WorkflowEvent{{
+					WorkflowInstance: sub,
+					HistoryEvent: history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+						DerivedInstanceID: derived,
+					}),
+				}},
+			))
+
+			tk, err = b.GetWorkflowTask(ctx)
+			require.NoError(t, err)
+			require.NotNil(t, tk)
+
+			if derived {
+				// Sub-workflows with derived instance IDs fail in the parent
+				require.Equal(t, wfi.InstanceID, tk.WorkflowInstance.InstanceID)
+				require.Equal(t, history.EventType_SubWorkflowFailed, tk.NewEvents[0].Type)
+			} else {
+				// Explicit instance IDs deliver the start event to the existing instance
+				require.Equal(t, existing.InstanceID, tk.WorkflowInstance.InstanceID)
+				require.Equal(t, history.EventType_WorkflowExecutionStarted, tk.NewEvents[0].Type)
+			}
+		})
+	}
+}
+
+func Test_ActivityQueues(t *testing.T) {
+	b := NewInMemoryBackend(backend.WithStickyTimeout(0), backend.WithActivityQueues("vpn"))
+	ctx := context.Background()
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.CreateWorkflowInstance(
+		ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	))
+
+	tk, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+
+	activityEvents := []history.Event{
+		history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{}, history.ScheduleEventID(1)),
+		history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{Queue: "vpn"}, history.ScheduleEventID(2)),
+	}
+	require.NoError(t, b.CompleteWorkflowTask(
+		ctx, tk, wfi, core.WorkflowInstanceStateActive, tk.NewEvents, activityEvents, []history.Event{}, []history.WorkflowEvent{}))
+
+	// Only the activity on the served queue is returned
+	at, err := b.GetActivityTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, at)
+	require.Equal(t, int64(2), at.Event.ScheduleEventID)
+
+	at, err = b.GetActivityTask(ctx)
+	require.NoError(t, err)
+	require.Nil(t, at)
+}
+
+func Test_ActivityLabels(t *testing.T) {
+	b := NewInMemoryBackend(backend.WithStickyTimeout(0), backend.WithActivityLabels("region=eu", "gpu"))
+	ctx := context.Background()
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.CreateWorkflowInstance(
+		ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	))
+
+	tk, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+
+	activityEvents := []history.Event{
+		history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{Labels: []string{"gpu", "region=us"}}, history.ScheduleEventID(1)),
+		history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{Labels: []string{"gpu", "region=eu"}}, history.ScheduleEventID(2)),
+		history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{Queue: "vpn", Labels: []string{"gpu"}}, history.ScheduleEventID(3)),
+		history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{Labels: []string{"gpu"}}, history.ScheduleEventID(4)),
+		history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{}, history.ScheduleEventID(5)),
+	}
+	require.NoError(t, b.CompleteWorkflowTask(
+		ctx, tk, wfi, core.WorkflowInstanceStateActive, tk.NewEvents, activityEvents, []history.Event{}, []history.WorkflowEvent{}))
+
+	// Only activities requiring a subset of the advertised labels are returned
+	var scheduleEventIDs []int64
+	for {
+		at, err := b.GetActivityTask(ctx)
+		require.NoError(t, err)
+		if at == nil {
+			break
+		}
+
+		scheduleEventIDs = append(scheduleEventIDs, at.Event.ScheduleEventID)
+	}
+
+	require.ElementsMatch(t, []int64{2, 4, 5}, scheduleEventIDs)
+}
+
+func Test_ActivityHeartbeatDetails(t *testing.T) {
+	var offset time.Duration
+	clock := func() time.Time { return time.Now().Add(offset) }
+
+	b := NewInMemoryBackend(backend.WithStickyTimeout(0), backend.WithClock(clock))
+	ctx := context.Background()
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.CreateWorkflowInstance(
+		ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	))
+
+	tk, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+
+	activityEvents := []history.Event{
+		history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{}, history.ScheduleEventID(1)),
+	}
+	require.NoError(t, b.CompleteWorkflowTask(
+		ctx, tk, wfi, core.WorkflowInstanceStateActive, tk.NewEvents, activityEvents, []history.Event{}, []history.WorkflowEvent{}))
+
+	at, err := b.GetActivityTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, at)
+	require.Nil(t, at.HeartbeatDetails)
+
+	require.NoError(t, b.RecordActivityHeartbeat(ctx, at.ID, []byte("42")))
+
+	// The worker dies, the activity is delivered again with the details of its last heartbeat once the lock expires
+	offset = backend.DefaultOptions.ActivityLockTimeout + time.Second
+
+	at, err = b.GetActivityTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, at)
+	require.Equal(t, []byte("42"), []byte(at.HeartbeatDetails))
+}
+
+func Test_RescheduleActivityHeartbeatTimeout(t *testing.T) {
+	var offset time.Duration
+	clock := func() time.Time { return time.Now().Add(offset) }
+
+	b := NewInMemoryBackend(backend.WithStickyTimeout(0), backend.WithClock(clock))
+	ctx := context.Background()
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.CreateWorkflowInstance(
+		ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	))
+
+	tk, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+
+	now := time.Now()
+	timerEvents := []history.Event{
+		history.NewActivityHeartbeatTimeoutEvent(now, now.Add(time.Minute), "activity", 1, "a", time.Minute, nil),
+	}
+	require.NoError(t, b.CompleteWorkflowTask(
+		ctx, tk, wfi, core.WorkflowInstanceStateActive, tk.NewEvents, []history.Event{}, timerEvents, []history.WorkflowEvent{}))
+
+	// The worker records a heartbeat, the timeout is moved
+	event := history.NewActivityHeartbeatTimeoutEvent(now, now.Add(2*time.Minute), "activity", 1, "a", time.Minute, []byte("42"))
+	require.NoError(t, b.RescheduleActivityHeartbeatTimeout(ctx, wfi, &event))
+
+	offset = time.Minute + time.Second
+	tk, err = b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.Nil(t, tk)
+
+	offset = 2*time.Minute + time.Second
+	tk, err = b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, tk)
+	require.Len(t, tk.NewEvents, 1)
+	require.Equal(t, event.ID, tk.NewEvents[0].ID)
+
+	a, err := history.AttributesAs[*history.ActivityFailedAttributes](&tk.NewEvents[0])
+	require.NoError(t, err)
+	require.Equal(t, history.ActivityTimeout_Heartbeat, a.Timeout)
+	require.Equal(t, []byte("42"), []byte(a.HeartbeatDetails))
+
+	// Timeouts that fired are not moved anymore
+	event = history.NewActivityHeartbeatTimeoutEvent(clock(), clock().Add(time.Minute), "activity", 1, "a", time.Minute, nil)
+	require.NoError(t, b.RescheduleActivityHeartbeatTimeout(ctx, wfi, &event))
+
+	var visibleAt time.Time
+	require.NoError(t, b.db.QueryRowContext(ctx,
+		"SELECT visible_at FROM `pending_events` WHERE instance_id = ? AND id = ?", wfi.InstanceID, event.ID,
+	).Scan(&visibleAt))
+	require.WithinDuration(t, now.Add(2*time.Minute), visibleAt, time.Second)
+}
+
+func Test_ClockSkewTolerance(t *testing.T) {
+	var offset time.Duration
+	clock := func() time.Time { return time.Now().Add(offset) }
+
+	b := NewInMemoryBackend(backend.WithStickyTimeout(0), backend.WithClock(clock), backend.WithClockSkewTolerance(time.Minute))
+	ctx := context.Background()
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.CreateWorkflowInstance(
+		ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	))
+
+	tk, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, tk)
+
+	// The lock expires after the lock timeout plus the tolerance
+	offset = backend.DefaultOptions.WorkflowLockTimeout + 30*time.Second
+
+	tk2, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.Nil(t, tk2)
+
+	offset += time.Minute
+
+	tk, err = b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, tk)
+
+	// Timers are not delayed by the tolerance. They fire after their delay on the clock of the backend, even
+	// if the clock of the worker scheduling them is behind.
+	workerNow := clock().Add(-5 * time.Minute)
+	timerEvent := history.NewPendingEvent(workerNow, history.EventType_TimerFired, &history.TimerFiredAttributes{},
+		history.ScheduleEventID(1), history.VisibleAt(workerNow.Add(10*time.Second)))
+	require.NoError(t, b.CompleteWorkflowTask(
+		ctx, tk, wfi, core.WorkflowInstanceStateActive, tk.NewEvents, []history.Event{}, []history.Event{timerEvent}, []history.WorkflowEvent{}))
+
+	tk, err = b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.Nil(t, tk)
+
+	offset += 11 * time.Second
+
+	tk, err = b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, tk)
+	require.Equal(t, history.EventType_TimerFired, tk.NewEvents[0].Type)
+}
+
+func Test_StorageStats_DelayedEventAge(t *testing.T) {
+	b := NewInMemoryBackend(backend.WithStickyTimeout(0))
+	ctx := context.Background()
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.CreateWorkflowInstance(
+		ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	))
+
+	tk, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+
+	// A timer scheduled long ago, which only just fired
+	scheduledAt := time.Now().Add(-time.Hour)
+	timerEvent := history.NewPendingEvent(scheduledAt, history.EventType_TimerFired, &history.TimerFiredAttributes{},
+		history.ScheduleEventID(1), history.VisibleAt(scheduledAt.Add(10*time.Millisecond)))
+	require.NoError(t, b.CompleteWorkflowTask(
+		ctx, tk, wfi, core.WorkflowInstanceStateActive, tk.NewEvents, []history.Event{}, []history.Event{timerEvent}, []history.WorkflowEvent{}))
+
+	time.Sleep(50 * time.Millisecond)
+
+	// The event is waiting since it became visible, not since it was scheduled
+	stats, err := b.StorageStats(ctx)
+	require.NoError(t, err)
+	require.Greater(t, stats.OldestWorkflowTaskAge, time.Duration(0))
+	require.Less(t, stats.OldestWorkflowTaskAge, time.Minute)
+}
+
 var _ test.TestBackend = (*sqliteBackend)(nil)
 
 func (sb *sqliteBackend) GetFutureEvents(ctx context.Context) ([]history.Event, error) {
@@ -63,15 +578,272 @@ func (sb *sqliteBackend) GetFutureEvents(ctx context.Context) ([]history.Event,
 			return nil, fmt.Errorf("scanning event: %w", err)
 		}
 
-		a, err := history.DeserializeAttributes(fe.Type, attributes)
-		if err != nil {
-			return nil, fmt.Errorf("deserializing attributes: %w", err)
-		}
-
-		fe.Attributes = a
+		fe.SetSerializedAttributes(attributes)
 
 		f = append(f, fe)
 	}
 
 	return f, nil
 }
+
+func Test_PrefetchHints(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	b := NewInMemoryBackend(backend.WithStickyTimeout(0), backend.WithClock(clock))
+	ctx := context.Background()
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.AddPrefetchHints(ctx, []*core.WorkflowInstance{wfi}, now.Add(time.Minute)))
+
+	// Adding a hint again extends it
+	require.NoError(t, b.AddPrefetchHints(ctx, []*core.WorkflowInstance{wfi}, now.Add(2*time.Minute)))
+
+	instances, err := b.PrefetchHints(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []*core.WorkflowInstance{wfi}, instances)
+
+	now = now.Add(2 * time.Minute)
+
+	instances, err = b.PrefetchHints(ctx)
+	require.NoError(t, err)
+	require.Empty(t, instances)
+}
+
+func Test_CompactWorkflowInstanceHistory(t *testing.T) {
+	b := NewInMemoryBackend(backend.WithStickyTimeout(0))
+	ctx := context.Background()
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.CreateWorkflowInstance(
+		ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	))
+
+	tk, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+
+	executed := append(tk.NewEvents,
+		history.NewHistoryEvent(2, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{Name: "a"}, history.ScheduleEventID(1)),
+		history.NewHistoryEvent(3, time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{}, history.ScheduleEventID(1)),
+	)
+	executed[0].SequenceID = 1
+	require.NoError(t, b.CompleteWorkflowTask(
+		ctx, tk, wfi, core.WorkflowInstanceStateActive, executed, []history.Event{}, []history.Event{}, []history.WorkflowEvent{}))
+
+	n, err := b.CompactWorkflowInstanceHistory(ctx, wfi, 3)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	h, err := b.GetWorkflowInstanceHistory(ctx, wfi, nil)
+	require.NoError(t, err)
+	require.Len(t, h, 2)
+	require.Equal(t, history.EventType_Compacted, h[1].Type)
+	require.Equal(t, int64(3), h[1].SequenceID)
+
+	// Compacting again is a no-op
+	n, err = b.CompactWorkflowInstanceHistory(ctx, wfi, 3)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	_, err = b.CompactWorkflowInstanceHistory(ctx, core.NewWorkflowInstance(uuid.NewString(), uuid.NewString()), 3)
+	require.ErrorIs(t, err, backend.ErrInstanceNotFound)
+}
+
+func Test_GetWorkflowTree(t *testing.T) {
+	b := NewInMemoryBackend()
+	ctx := context.Background()
+
+	create := func(instance *core.WorkflowInstance) {
+		require.NoError(t, b.CreateWorkflowInstance(
+			ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+		))
+	}
+
+	root := core.NewWorkflowInstance("root", uuid.NewString())
+	create(root)
+	child := core.NewSubWorkflowInstance("child", uuid.NewString(), "root", 1)
+	create(child)
+	create(core.NewSubWorkflowInstance("sibling", uuid.NewString(), "root", 2))
+	create(core.NewSubWorkflowInstance("grandchild", uuid.NewString(), "child", 1))
+
+	c := client.New(b)
+
+	tree, err := c.GetWorkflowTree(ctx, "child")
+	require.NoError(t, err)
+
+	require.Equal(t, "root", tree.Root.Instance.InstanceID)
+	require.Equal(t, core.WorkflowInstanceStateActive, tree.Root.State)
+	require.Len(t, tree.Root.Children, 1)
+	require.Same(t, tree.Instance, tree.Root.Children[0])
+
+	require.Equal(t, "child", tree.Instance.Instance.InstanceID)
+	require.Equal(t, "root", tree.Instance.Instance.ParentInstanceID)
+	require.Len(t, tree.Instance.Children, 1)
+	require.Equal(t, "grandchild", tree.Instance.Children[0].Instance.InstanceID)
+	require.Empty(t, tree.Instance.Children[0].Children)
+
+	tree, err = c.GetWorkflowTree(ctx, "root")
+	require.NoError(t, err)
+	require.Same(t, tree.Root, tree.Instance)
+	require.Len(t, tree.Root.Children, 2)
+
+	_, err = c.GetWorkflowTree(ctx, "unknown")
+	require.ErrorIs(t, err, backend.ErrInstanceNotFound)
+}
+
+func Test_BackupAndRestore(t *testing.T) {
+	b := NewInMemoryBackend(backend.WithStickyTimeout(0), backend.WithInstanceActivityRate(1))
+	ctx := context.Background()
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.CreateWorkflowInstance(
+		ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	))
+
+	tk, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+
+	activityScheduled := history.NewHistoryEvent(2, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{Name: "a"}, history.ScheduleEventID(1))
+	executed := append(tk.NewEvents, activityScheduled)
+	executed[0].SequenceID = 1
+	require.NoError(t, b.CompleteWorkflowTask(
+		ctx, tk, wfi, core.WorkflowInstanceStateActive, executed, []history.Event{activityScheduled}, []history.Event{}, []history.WorkflowEvent{}))
+
+	// The activity records a heartbeat, then its worker goes away
+	at, err := b.GetActivityTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, at)
+	require.NoError(t, b.RecordActivityHeartbeat(ctx, at.ID, []byte("42")))
+
+	pending := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.CreateWorkflowInstance(
+		ctx, pending, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	))
+
+	var buf bytes.Buffer
+	require.NoError(t, backend.WriteBackup(ctx, b, &buf))
+
+	restored := NewInMemoryBackend(backend.WithStickyTimeout(0))
+	require.NoError(t, backend.RestoreBackup(ctx, restored, bytes.NewReader(buf.Bytes())))
+
+	// The activity throttling of the instance is restored
+	var nextActivityAt, restoredNextActivityAt time.Time
+	require.NoError(t, b.db.QueryRowContext(ctx, "SELECT next_activity_at FROM instances WHERE id = ?", wfi.InstanceID).Scan(&nextActivityAt))
+	require.NoError(t, restored.db.QueryRowContext(ctx, "SELECT next_activity_at FROM instances WHERE id = ?", wfi.InstanceID).Scan(&restoredNextActivityAt))
+	require.True(t, nextActivityAt.Equal(restoredNextActivityAt))
+
+	h, err := restored.GetWorkflowInstanceHistory(ctx, wfi, nil)
+	require.NoError(t, err)
+	require.Len(t, h, 2)
+	require.Equal(t, history.EventType_ActivityScheduled, h[1].Type)
+	a, err := h[1].Attributes()
+	require.NoError(t, err)
+	require.Equal(t, "a", a.(*history.ActivityScheduledAttributes).Name)
+
+	// The activity is delivered again with the details of its last heartbeat
+	at, err = restored.GetActivityTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, at)
+	require.Equal(t, wfi.InstanceID, at.WorkflowInstance.InstanceID)
+	require.Equal(t, activityScheduled.ID, at.ID)
+	require.Equal(t, []byte("42"), []byte(at.HeartbeatDetails))
+
+	tk, err = restored.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, tk)
+	require.Equal(t, pending.InstanceID, tk.WorkflowInstance.InstanceID)
+	require.Len(t, tk.NewEvents, 1)
+
+	// Restoring into a backend that already contains the instances fails without changes
+	err = backend.RestoreBackup(ctx, restored, bytes.NewReader(buf.Bytes()))
+	require.ErrorIs(t, err, backend.ErrInstanceAlreadyExists)
+}
+
+// initialSchema is the schema of the first release, before any columns were added.
+const initialSchema = `CREATE TABLE IF NOT EXISTS instances (
+  id TEXT PRIMARY KEY,
+  execution_id TEXT NO NULL,
+  parent_instance_id TEXT NULL,
+  parent_schedule_event_id INTEGER NULL,
+  metadata TEXT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  completed_at DATETIME NULL,
+  locked_until DATETIME NULL,
+  sticky_until DATETIME NULL,
+  worker TEXT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_instances_locked_until_completed_at ON instances (locked_until, sticky_until, completed_at, worker);
+CREATE INDEX IF NOT EXISTS idx_instances_parent_instance_id ON instances (parent_instance_id);
+
+CREATE TABLE IF NOT EXISTS pending_events (
+  id TEXT,
+  sequence_id INTEGER NOT NULL, -- not used but keep for now for query compat
+  instance_id TEXT NOT NULL,
+  event_type INTEGER NOT NULL,
+  timestamp DATETIME NOT NULL,
+  schedule_event_id INT NOT NULL,
+  attributes BLOB NOT NULL,
+  visible_at DATETIME NULL,
+  PRIMARY KEY(id, instance_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pending_events_instance_id_visible_at_schedule_event_id ON pending_events (instance_id, visible_at, schedule_event_id);
+
+CREATE TABLE IF NOT EXISTS history (
+  id TEXT,
+  sequence_id INTEGER NOT NULL,
+  instance_id TEXT NOT NULL,
+  event_type INTEGER NOT NULL,
+  timestamp DATETIME NOT NULL,
+  schedule_event_id INT NOT NULL,
+  attributes BLOB NOT NULL,
+  visible_at DATETIME NULL,
+  PRIMARY KEY(id, instance_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_history_instance_sequence_id ON history (instance_id, sequence_id);
+
+CREATE TABLE IF NOT EXISTS activities (
+  id TEXT PRIMARY KEY,
+  instance_id TEXT NOT NULL,
+  execution_id TEXT NOT NULL,
+  event_type INTEGER NOT NULL,
+  timestamp DATETIME NOT NULL,
+  schedule_event_id INT NOT NULL,
+  attributes BLOB NOT NULL,
+  visible_at DATETIME NULL,
+  locked_until DATETIME NULL,
+  worker TEXT NULL
+);`
+
+func Test_MigratesExistingDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workflows.sqlite")
+
+	db, err := sql.Open("sqlite3", "file:"+path)
+	require.NoError(t, err)
+	_, err = db.Exec(initialSchema)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	b := NewSqliteBackend(path, backend.WithStickyTimeout(0))
+	ctx := context.Background()
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	require.NoError(t, b.CreateWorkflowInstance(
+		ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+	))
+
+	tk, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.NoError(t, b.CompleteWorkflowTask(
+		ctx, tk, wfi, core.WorkflowInstanceStateActive, tk.NewEvents, []history.Event{}, []history.Event{}, []history.WorkflowEvent{}))
+	require.NoError(t, b.db.Close())
+
+	// Migrating an up-to-date database doesn't change it
+	b = NewSqliteBackend(path, backend.WithStickyTimeout(0))
+	h, err := b.GetWorkflowInstanceHistory(ctx, wfi, nil)
+	require.NoError(t, err)
+	require.Len(t, h, 1)
+	require.NoError(t, b.db.Close())
+}
@@ -0,0 +1,92 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/metrics"
+	"github.com/mattn/go-sqlite3"
+)
+
+var _ backend.StorageStatsProvider = (*sqliteBackend)(nil)
+
+func (sb *sqliteBackend) StorageStats(ctx context.Context) (*backend.StorageStats, error) {
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stats := &backend.StorageStats{
+		Sizes: make(map[string]int64),
+	}
+
+	for _, table := range []string{"instances", "pending_events", "history", "activities"} {
+		var n int64
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM `"+table+"`").Scan(&n); err != nil {
+			return nil, fmt.Errorf("counting %s: %w", table, err)
+		}
+
+		stats.Sizes[table] = n
+	}
+
+	now := sb.options.Now()
+
+	stats.OldestWorkflowTaskAge, err = oldestAge(ctx, tx, now,
+		"SELECT timestamp, visible_at FROM `pending_events` WHERE visible_at IS NULL OR visible_at <= ? ORDER BY COALESCE(visible_at, timestamp) LIMIT 1",
+		now)
+	if err != nil {
+		return nil, fmt.Errorf("finding oldest pending event: %w", err)
+	}
+
+	stats.OldestActivityTaskAge, err = oldestAge(ctx, tx, now,
+		"SELECT timestamp, visible_at FROM `activities` WHERE (locked_until IS NULL OR locked_until < ?) AND (visible_at IS NULL OR visible_at <= ?) ORDER BY COALESCE(visible_at, timestamp) LIMIT 1",
+		now, now)
+	if err != nil {
+		return nil, fmt.Errorf("finding oldest activity: %w", err)
+	}
+
+	if err := tx.QueryRowContext(
+		ctx,
+		"SELECT COUNT(*) FROM `pending_events` WHERE event_type = ? AND visible_at > ?",
+		history.EventType_TimerFired,
+		now,
+	).Scan(&stats.PendingTimers); err != nil {
+		return nil, fmt.Errorf("counting pending timers: %w", err)
+	}
+
+	return stats, nil
+}
+
+func oldestAge(ctx context.Context, tx *sql.Tx, now time.Time, query string, args ...interface{}) (time.Duration, error) {
+	var timestamp time.Time
+	var visibleAt *time.Time
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&timestamp, &visibleAt); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	// Delayed events and activities are only waiting since they became visible
+	if visibleAt != nil {
+		timestamp = *visibleAt
+	}
+
+	return now.Sub(timestamp), nil
+}
+
+// recordLockContention counts errors caused by another connection holding a lock on the database.
+func (sb *sqliteBackend) recordLockContention(operation string, err error) {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked) {
+		sb.Metrics().Counter(metrickeys.BackendLockContention, metrics.Tags{metrickeys.Operation: operation}, 1)
+	}
+}
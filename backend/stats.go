@@ -0,0 +1,76 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/metrics"
+)
+
+// StorageStats describes storage growth and queue state of a backend.
+type StorageStats struct {
+	// Sizes is the number of rows or keys, keyed by table or key space name.
+	Sizes map[string]int64
+
+	// OldestWorkflowTaskAge is the age of the oldest pending event waiting to be processed by a workflow
+	// task. 0 if there are none.
+	OldestWorkflowTaskAge time.Duration
+
+	// OldestActivityTaskAge is the age of the oldest activity task that is not locked by a worker. 0 if
+	// there are none.
+	OldestActivityTaskAge time.Duration
+
+	// PendingTimers is the number of timers that have not fired yet.
+	PendingTimers int64
+}
+
+// StorageStatsProvider is implemented by backends that can report StorageStats.
+type StorageStatsProvider interface {
+	StorageStats(ctx context.Context) (*StorageStats, error)
+}
+
+var ErrStorageStatsNotSupported = errors.New("backend does not support storage stats")
+
+// ReportStorageMetrics periodically reports the StorageStats of the given backend as gauges through the
+// backend's metrics client, until the given context is canceled. Lock contention is reported by the
+// backends as it happens, independent of this.
+func ReportStorageMetrics(ctx context.Context, b Backend, interval time.Duration) error {
+	p, ok := b.(StorageStatsProvider)
+	if !ok {
+		return ErrStorageStatsNotSupported
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		stats, err := p.StorageStats(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			b.Logger().Error("collecting storage stats", "error", err)
+		} else {
+			recordStorageStats(b.Metrics(), stats)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+func recordStorageStats(m metrics.Client, stats *StorageStats) {
+	for name, size := range stats.Sizes {
+		m.Gauge(metrickeys.BackendStorageSize, metrics.Tags{metrickeys.StorageName: name}, size)
+	}
+
+	m.Gauge(metrickeys.BackendOldestTaskAge, metrics.Tags{metrickeys.TaskQueue: "workflow"}, stats.OldestWorkflowTaskAge.Milliseconds())
+	m.Gauge(metrickeys.BackendOldestTaskAge, metrics.Tags{metrickeys.TaskQueue: "activity"}, stats.OldestActivityTaskAge.Milliseconds())
+	m.Gauge(metrickeys.BackendPendingTimers, metrics.Tags{}, stats.PendingTimers)
+}
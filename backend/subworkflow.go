@@ -0,0 +1,23 @@
+package backend
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+// SubWorkflowAlreadyExistsEvent returns the event failing the given sub-workflow in its parent. Backends add it to
+// the pending events of the parent when the instance ID of a sub-workflow is already taken, instead of starting
+// the sub-workflow.
+func SubWorkflowAlreadyExistsEvent(now time.Time, subWorkflowInstance *workflow.Instance) history.Event {
+	return history.NewPendingEvent(
+		now,
+		history.EventType_SubWorkflowFailed,
+		&history.SubWorkflowFailedAttributes{
+			Error: fmt.Sprintf("starting sub-workflow instance %s: %v", subWorkflowInstance.InstanceID, ErrInstanceAlreadyExists),
+		},
+		history.ScheduleEventID(subWorkflowInstance.ParentEventID),
+	)
+}
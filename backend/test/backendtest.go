@@ -3,6 +3,8 @@ package test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -82,6 +84,44 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 				require.Equal(t, *metadata, *task.Metadata)
 			},
 		},
+		{
+			name: "CreateWorkflowInstances_CreatesAllInstances",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				wfi1 := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				wfi2 := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+
+				err := b.CreateWorkflowInstances(ctx, []history.WorkflowEvent{
+					{WorkflowInstance: wfi1, HistoryEvent: history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{})},
+					{WorkflowInstance: wfi2, HistoryEvent: history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{})},
+				})
+				require.NoError(t, err)
+
+				for _, wfi := range []*core.WorkflowInstance{wfi1, wfi2} {
+					state, err := b.GetWorkflowInstanceState(ctx, wfi)
+					require.NoError(t, err)
+					require.Equal(t, core.WorkflowInstanceStateActive, state)
+				}
+			},
+		},
+		{
+			name: "CreateWorkflowInstances_CreatesNoneOnConflict",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				existing := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, existing, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+
+				err = b.CreateWorkflowInstances(ctx, []history.WorkflowEvent{
+					{WorkflowInstance: wfi, HistoryEvent: history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{})},
+					{WorkflowInstance: existing, HistoryEvent: history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{})},
+				})
+				require.ErrorIs(t, err, backend.ErrInstanceAlreadyExists)
+
+				_, err = b.GetWorkflowInstanceState(ctx, wfi)
+				require.ErrorIs(t, err, backend.ErrInstanceNotFound)
+			},
+		},
 		{
 			name: "GetWorkflowTask_ReturnsNilWhenTimeout",
 			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
@@ -133,6 +173,35 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 				require.True(t, err == nil || errors.Is(err, context.DeadlineExceeded))
 			},
 		},
+		{
+			name: "GetWorkflowTask_RoundRobinsInstances",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				for i := 0; i < 2; i++ {
+					wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+					err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+					require.NoError(t, err)
+				}
+
+				tk, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, tk)
+
+				// Make sure the first instance has new events to process right away
+				err = b.SignalWorkflow(ctx, tk.WorkflowInstance.InstanceID, history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{
+					Name: "signal",
+				}))
+				require.NoError(t, err)
+
+				err = b.CompleteWorkflowTask(ctx, tk, tk.WorkflowInstance, core.WorkflowInstanceStateActive, tk.NewEvents, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				// The other instance should be scheduled before the first one again
+				tk2, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, tk2)
+				require.NotEqual(t, tk.WorkflowInstance.InstanceID, tk2.WorkflowInstance.InstanceID)
+			},
+		},
 		{
 			name: "CompleteWorkflowTask_ReturnsErrorIfNotLocked",
 			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
@@ -196,8 +265,173 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 				for i, event := range events {
 					require.Equal(t, event.ID, h[i].ID)
 					require.Equal(t, event.Type, h[i].Type)
-					require.Equal(t, event.Attributes, h[i].Attributes)
+
+					a, err := event.Attributes()
+					require.NoError(t, err)
+					ha, err := h[i].Attributes()
+					require.NoError(t, err)
+					require.Equal(t, a, ha)
+				}
+			},
+		},
+		{
+			name: "AbandonWorkflowTask_MakesTaskAvailableAgain",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				a, ok := b.(backend.WorkflowTaskAbandoner)
+				if !ok {
+					t.Skip("backend does not support abandoning workflow tasks")
+				}
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				tk, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, tk)
+
+				// Task is locked
+				tk2, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.Nil(t, tk2)
+
+				err = a.AbandonWorkflowTask(ctx, tk, 0)
+				require.NoError(t, err)
+
+				tk2, err = b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, tk2)
+				require.Equal(t, wfi.InstanceID, tk2.WorkflowInstance.InstanceID)
+			},
+		},
+		{
+			name: "ParkWorkflowTask_MakesTaskAvailableAgain",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				p, ok := b.(backend.WorkflowTaskParker)
+				if !ok {
+					t.Skip("backend does not support parking workflow tasks")
 				}
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				tk, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, tk)
+
+				err = p.ParkWorkflowTask(ctx, tk, 0)
+				require.NoError(t, err)
+
+				// The instance is sticky to this worker, which can pick it up again
+				tk2, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, tk2)
+				require.Equal(t, wfi.InstanceID, tk2.WorkflowInstance.InstanceID)
+			},
+		},
+		{
+			name: "CheckpointWorkflowTask_KeepsTaskLocked",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				c, ok := b.(backend.WorkflowTaskCheckpointer)
+				if !ok {
+					t.Skip("backend does not support checkpointing workflow tasks")
+				}
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				err = b.SignalWorkflow(ctx, wfi.InstanceID, history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{
+					Name: "signal",
+				}))
+				require.NoError(t, err)
+
+				tk, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, tk)
+				require.Len(t, tk.NewEvents, 2)
+
+				for i := range tk.NewEvents {
+					tk.NewEvents[i].SequenceID = int64(i + 1)
+				}
+
+				err = c.CheckpointWorkflowTask(ctx, tk, wfi, tk.NewEvents[:1], []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				// The progress is committed, but the task is still locked
+				h, err := b.GetWorkflowInstanceHistory(ctx, wfi, nil)
+				require.NoError(t, err)
+				require.Len(t, h, 1)
+
+				tk2, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.Nil(t, tk2)
+
+				err = b.CompleteWorkflowTask(ctx, tk, wfi, core.WorkflowInstanceStateActive, tk.NewEvents[1:], []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				h, err = b.GetWorkflowInstanceHistory(ctx, wfi, nil)
+				require.NoError(t, err)
+				require.Len(t, h, 2)
+			},
+		},
+		{
+			name: "GetFilteredWorkflowInstanceHistory_FiltersEvents",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				startedEvent := history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name:   "some-workflow",
+					Inputs: []payload.Payload{[]byte("1")},
+				})
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, startedEvent)
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+
+				events := []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					startedEvent,
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+						Name:   "a1",
+						Inputs: []payload.Payload{[]byte("2")},
+					}, history.ScheduleEventID(1)),
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+						Name:   "a2",
+						Inputs: []payload.Payload{[]byte("3")},
+					}, history.ScheduleEventID(2)),
+				}
+
+				for i := range events {
+					events[i].SequenceID = int64(i + 1)
+				}
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, events, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				h, err := backend.GetFilteredWorkflowInstanceHistory(ctx, b, wfi, backend.HistoryFilter{
+					EventTypes:      []history.EventType{history.EventType_ActivityScheduled},
+					ExcludePayloads: true,
+				})
+				require.NoError(t, err)
+				require.Len(t, h, 2)
+				for i, name := range []string{"a1", "a2"} {
+					a := eventAttributes[*history.ActivityScheduledAttributes](t, &h[i])
+					require.Equal(t, name, a.Name)
+					require.Nil(t, a.Inputs[0])
+				}
+
+				h, err = backend.GetFilteredWorkflowInstanceHistory(ctx, b, wfi, backend.HistoryFilter{
+					FromSequenceID: 2,
+					ToSequenceID:   3,
+				})
+				require.NoError(t, err)
+				require.Len(t, h, 2)
+				require.Equal(t, history.EventType_WorkflowExecutionStarted, h[0].Type)
+				require.Equal(t, payload.Payload("1"), eventAttributes[*history.ExecutionStartedAttributes](t, &h[0]).Inputs[0])
+				require.Equal(t, int64(3), h[1].SequenceID)
 			},
 		},
 		{
@@ -240,6 +474,423 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 				require.NotNil(t, s.CompletedAt)
 			},
 		},
+		{
+			name: "SignalWorkflow_DeliversSignalsInSendOrder",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, task.NewEvents, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				const signals = 20
+				for i := 0; i < signals; i++ {
+					err = b.SignalWorkflow(ctx, wfi.InstanceID, history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{
+						Name: "signal",
+						Arg:  payload.Payload(fmt.Sprint(i)),
+					}))
+					require.NoError(t, err)
+				}
+
+				signalArgs := func(events []history.Event) []string {
+					r := make([]string, 0, len(events))
+					for _, e := range events {
+						r = append(r, string(eventAttributes[*history.SignalReceivedAttributes](t, &e).Arg))
+					}
+
+					return r
+				}
+
+				expected := make([]string, 0, signals)
+				for i := 0; i < signals; i++ {
+					expected = append(expected, fmt.Sprint(i))
+				}
+
+				task, err = b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.Equal(t, expected, signalArgs(task.NewEvents))
+
+				// Only execute some of the signals, the remaining ones are delivered with the next task
+				executed := task.NewEvents[:5]
+				for i := range executed {
+					executed[i].SequenceID = int64(i + 2)
+				}
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, executed, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				task, err = b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, expected[5:], signalArgs(task.NewEvents))
+			},
+		},
+		{
+			name: "SignalWorkflow_DeduplicatesEventsByID",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, task.NewEvents, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				signalEvent := history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{
+					Name: "signal",
+				})
+
+				// Deliver the same event twice
+				require.NoError(t, b.SignalWorkflow(ctx, wfi.InstanceID, signalEvent))
+				require.NoError(t, b.SignalWorkflow(ctx, wfi.InstanceID, signalEvent))
+
+				task, err = b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Len(t, task.NewEvents, 1)
+				require.Equal(t, signalEvent.ID, task.NewEvents[0].ID)
+
+				task.NewEvents[0].SequenceID = 2
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, task.NewEvents, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				// Events already in the history are ignored as well
+				require.NoError(t, b.SignalWorkflow(ctx, wfi.InstanceID, signalEvent))
+
+				tctx, cancel := context.WithTimeout(ctx, time.Millisecond*100)
+				defer cancel()
+
+				task, err = b.GetWorkflowTask(tctx)
+				require.Nil(t, task)
+				require.True(t, err == nil || errors.Is(err, context.DeadlineExceeded))
+			},
+		},
+		{
+			name: "CompleteWorkflowTask_RemovesFutureEventsWhenFinished",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+
+				// Schedule a timer far in the future
+				timerEvent := history.NewPendingEvent(
+					time.Now(),
+					history.EventType_TimerFired,
+					&history.TimerFiredAttributes{},
+					history.ScheduleEventID(1),
+					history.VisibleAt(time.Now().Add(time.Hour)),
+				)
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, task.NewEvents, []history.Event{}, []history.Event{timerEvent}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				tb := b.(TestBackend)
+				futureEvents, err := tb.GetFutureEvents(ctx)
+				require.NoError(t, err)
+				require.Len(t, futureEvents, 1)
+
+				// Finish the workflow instance without the timer firing
+				err = b.SignalWorkflow(ctx, wfi.InstanceID, history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{
+					Name: "signal",
+				}))
+				require.NoError(t, err)
+
+				task, err = b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+
+				events := append(task.NewEvents, history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{}))
+				for i := range events {
+					events[i].SequenceID = int64(i + 2)
+				}
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateFinished, events, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				futureEvents, err = tb.GetFutureEvents(ctx)
+				require.NoError(t, err)
+				require.Len(t, futureEvents, 0, "no future events should be left for finished instances")
+			},
+		},
+		{
+			name: "PendingTimers_ListsTimersByFireTime",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				l, ok := b.(backend.TimerLister)
+				if !ok {
+					t.Skip("backend does not support listing timers")
+				}
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+
+				fireAt := time.Now().Add(time.Hour)
+				timerEvents := []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_TimerFired, &history.TimerFiredAttributes{},
+						history.ScheduleEventID(1), history.VisibleAt(fireAt.Add(time.Minute))),
+					history.NewPendingEvent(time.Now(), history.EventType_TimerFired, &history.TimerFiredAttributes{},
+						history.ScheduleEventID(2), history.VisibleAt(fireAt)),
+					// Activity timeouts are not timers
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityFailed,
+						&history.ActivityFailedAttributes{Timeout: history.ActivityTimeout_StartToClose},
+						history.ScheduleEventID(3), history.VisibleAt(fireAt.Add(-time.Minute))),
+				}
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, task.NewEvents, []history.Event{}, timerEvents, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				timers, err := l.PendingTimers(ctx, 10)
+				require.NoError(t, err)
+				require.Len(t, timers, 2)
+
+				require.Equal(t, wfi.InstanceID, timers[0].InstanceID)
+				require.Equal(t, int64(2), timers[0].ScheduleEventID)
+				require.WithinDuration(t, fireAt, timers[0].FireAt, time.Second)
+				require.Equal(t, time.Duration(0), timers[0].Skew(time.Now()))
+				require.Equal(t, time.Minute, timers[0].Skew(timers[0].FireAt.Add(time.Minute)))
+
+				require.Equal(t, int64(1), timers[1].ScheduleEventID)
+
+				// Events that are not timers don't count towards the limit
+				timers, err = l.PendingTimers(ctx, 1)
+				require.NoError(t, err)
+				require.Len(t, timers, 1)
+				require.Equal(t, int64(2), timers[0].ScheduleEventID)
+			},
+		},
+		{
+			name: "OrphanedEvents_ReportsAndDeletesEventsOfFinishedInstances",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				c, ok := b.(backend.OrphanedEventCleaner)
+				if !ok {
+					t.Skip("backend does not support reporting orphaned events")
+				}
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+
+				// Running instance, its pending event is not orphaned
+				running := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err = b.CreateWorkflowInstance(ctx, running, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				events := append(task.NewEvents, history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{}))
+				for i := range events {
+					events[i].SequenceID = int64(i + 2)
+				}
+
+				// Activities still queued when the instance finishes are never executed
+				activityEvents := []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{}, history.ScheduleEventID(1)),
+				}
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateFinished, events, activityEvents, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				// Signals delivered after the instance finished are never processed
+				err = b.SignalWorkflow(ctx, wfi.InstanceID, history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{Name: "late"}))
+				require.NoError(t, err)
+
+				orphaned := func() []*backend.OrphanedEvent {
+					report, err := c.OrphanedEvents(ctx, 1000)
+					require.NoError(t, err)
+
+					var r []*backend.OrphanedEvent
+					for _, e := range report.Events {
+						require.NotEqual(t, running.InstanceID, e.InstanceID)
+
+						if e.InstanceID == wfi.InstanceID {
+							require.GreaterOrEqual(t, report.Counts[e.EventType], 1)
+							r = append(r, e)
+						}
+					}
+
+					return r
+				}
+
+				orphans := orphaned()
+				require.Len(t, orphans, 2)
+
+				var eventTypes []string
+				for _, e := range orphans {
+					eventTypes = append(eventTypes, e.EventType)
+					require.False(t, e.InstanceCompletedAt.IsZero())
+				}
+				require.ElementsMatch(t, []string{"ActivityScheduled", "SignalReceived"}, eventTypes)
+
+				// Only events of instances that finished before the given time are deleted
+				_, err = c.DeleteOrphanedEvents(ctx, time.Now().Add(-time.Hour))
+				require.NoError(t, err)
+				require.Len(t, orphaned(), 2)
+
+				n, err := c.DeleteOrphanedEvents(ctx, time.Now().Add(time.Second))
+				require.NoError(t, err)
+				require.GreaterOrEqual(t, n, 2)
+				require.Empty(t, orphaned())
+
+				// The running instance can still be executed
+				task, err = b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.Equal(t, running.InstanceID, task.WorkflowInstance.InstanceID)
+			},
+		},
+		{
+			name: "RemoveWorkflowInstance_SoftDeletesAndUndeletes",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				r, ok := b.(backend.InstanceRemover)
+				if !ok {
+					t.Skip("backend does not support removing workflow instances")
+				}
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				err = r.RemoveWorkflowInstance(ctx, wfi)
+				require.ErrorIs(t, err, backend.ErrInstanceNotFinished)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+
+				events := append(task.NewEvents, history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{}))
+				for i := range events {
+					events[i].SequenceID = int64(i + 2)
+				}
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateFinished, events, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				err = r.RemoveWorkflowInstance(ctx, wfi)
+				require.NoError(t, err)
+
+				_, err = b.GetWorkflowInstanceState(ctx, wfi)
+				require.ErrorIs(t, err, backend.ErrInstanceNotFound)
+
+				_, err = b.GetWorkflowInstanceHistory(ctx, wfi, nil)
+				require.ErrorIs(t, err, backend.ErrInstanceNotFound)
+
+				_, err = backend.GetFilteredWorkflowInstanceHistory(ctx, b, wfi, backend.HistoryFilter{})
+				require.ErrorIs(t, err, backend.ErrInstanceNotFound)
+
+				err = r.RemoveWorkflowInstance(ctx, wfi)
+				require.ErrorIs(t, err, backend.ErrInstanceNotFound)
+
+				err = r.UndeleteWorkflowInstance(ctx, wfi)
+				require.NoError(t, err)
+
+				state, err := b.GetWorkflowInstanceState(ctx, wfi)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateFinished, state)
+
+				h, err := b.GetWorkflowInstanceHistory(ctx, wfi, nil)
+				require.NoError(t, err)
+				require.Len(t, h, len(events))
+
+				err = r.RemoveWorkflowInstance(ctx, wfi)
+				require.NoError(t, err)
+
+				ph, hints := b.(backend.PrefetchHinter)
+				if hints {
+					require.NoError(t, ph.AddPrefetchHints(ctx, []*workflow.Instance{wfi}, time.Now().Add(time.Hour)))
+				}
+
+				n, err := r.PurgeRemovedWorkflowInstances(ctx, time.Now().Add(time.Second))
+				require.NoError(t, err)
+				require.GreaterOrEqual(t, n, 1)
+
+				err = r.UndeleteWorkflowInstance(ctx, wfi)
+				require.ErrorIs(t, err, backend.ErrInstanceNotFound)
+
+				// Purging removes all data of the instance
+				if hints {
+					instances, err := ph.PrefetchHints(ctx)
+					require.NoError(t, err)
+					require.NotContains(t, instances, wfi)
+				}
+			},
+		},
+		{
+			name: "WorkflowInstanceHold_PreventsRemovalAndPurge",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				h, ok := b.(backend.InstanceHolder)
+				if !ok {
+					t.Skip("backend does not support holds on workflow instances")
+				}
+
+				r, ok := b.(backend.InstanceRemover)
+				if !ok {
+					t.Skip("backend does not support removing workflow instances")
+				}
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				hold, err := h.GetWorkflowInstanceHold(ctx, wfi)
+				require.NoError(t, err)
+				require.Nil(t, hold)
+
+				err = h.PlaceWorkflowInstanceHold(ctx, wfi, "litigation")
+				require.NoError(t, err)
+
+				hold, err = h.GetWorkflowInstanceHold(ctx, wfi)
+				require.NoError(t, err)
+				require.NotNil(t, hold)
+				require.Equal(t, "litigation", hold.Reason)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+
+				events := append(task.NewEvents, history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{}))
+				for i := range events {
+					events[i].SequenceID = int64(i + 2)
+				}
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateFinished, events, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				err = r.RemoveWorkflowInstance(ctx, wfi)
+				require.ErrorIs(t, err, backend.ErrInstanceHeld)
+
+				err = h.ReleaseWorkflowInstanceHold(ctx, wfi)
+				require.NoError(t, err)
+
+				err = h.ReleaseWorkflowInstanceHold(ctx, wfi)
+				require.ErrorIs(t, err, backend.ErrInstanceNotHeld)
+
+				err = r.RemoveWorkflowInstance(ctx, wfi)
+				require.NoError(t, err)
+
+				// Removed instances placed on hold are not purged
+				err = h.PlaceWorkflowInstanceHold(ctx, wfi, "audit")
+				require.NoError(t, err)
+
+				_, err = r.PurgeRemovedWorkflowInstances(ctx, time.Now().Add(time.Second))
+				require.NoError(t, err)
+
+				err = r.UndeleteWorkflowInstance(ctx, wfi)
+				require.NoError(t, err)
+
+				err = h.PlaceWorkflowInstanceHold(ctx, core.NewWorkflowInstance(uuid.NewString(), uuid.NewString()), "audit")
+				require.ErrorIs(t, err, backend.ErrInstanceNotFound)
+			},
+		},
 		{
 			name: "SignalWorkflow_ErrorWhenInstanceDoesNotExist",
 			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
@@ -316,6 +967,237 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 				require.Nil(t, task)
 			},
 		},
+		{
+			name: "StorageStats_ReportsSizesAndPendingTimers",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				p, ok := b.(backend.StorageStatsProvider)
+				if !ok {
+					t.Skip("backend does not support storage stats")
+				}
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(
+					ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				stats, err := p.StorageStats(ctx)
+				require.NoError(t, err)
+				require.GreaterOrEqual(t, stats.Sizes["instances"], int64(1))
+				require.GreaterOrEqual(t, stats.OldestWorkflowTaskAge, time.Duration(0))
+				require.Zero(t, stats.PendingTimers)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+
+				timerEvent := history.NewPendingEvent(
+					time.Now(),
+					history.EventType_TimerFired,
+					&history.TimerFiredAttributes{},
+					history.ScheduleEventID(1),
+					history.VisibleAt(time.Now().Add(time.Hour)),
+				)
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, task.NewEvents, []history.Event{}, []history.Event{timerEvent}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				stats, err = p.StorageStats(ctx)
+				require.NoError(t, err)
+				require.Equal(t, int64(1), stats.PendingTimers)
+			},
+		},
+		{
+			name: "RewritePayloads_UpdatesPendingAndHistoryEvents",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				r, ok := b.(backend.PayloadRewriter)
+				if !ok {
+					t.Skip("backend does not support rewriting payloads")
+				}
+
+				replace := func(from, to string) backend.PayloadRewriteFunc {
+					return func(p payload.Payload) (payload.Payload, bool, error) {
+						if string(p) == from {
+							return payload.Payload(to), true, nil
+						}
+
+						return p, false, nil
+					}
+				}
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(
+					ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+						Inputs: []payload.Payload{payload.Payload(`"a"`), payload.Payload(`"b"`)},
+					}))
+				require.NoError(t, err)
+
+				n, err := r.RewritePayloads(ctx, replace(`"a"`, `"x"`))
+				require.NoError(t, err)
+				require.Equal(t, 1, n)
+
+				task, err := b.GetWorkflowTask(ctx)
+				require.NoError(t, err)
+				require.Equal(t, []payload.Payload{payload.Payload(`"x"`), payload.Payload(`"b"`)},
+					eventAttributes[*history.ExecutionStartedAttributes](t, &task.NewEvents[0]).Inputs)
+
+				executed := append(task.NewEvents,
+					history.NewHistoryEvent(2, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+						Name:             "a",
+						HeartbeatDetails: payload.Payload(`"h"`),
+					}, history.ScheduleEventID(1)),
+					history.NewHistoryEvent(3, time.Now(), history.EventType_ActivityFailed, &history.ActivityFailedAttributes{
+						Reason:           "failed",
+						HeartbeatDetails: payload.Payload(`"h"`),
+					}, history.ScheduleEventID(1)),
+				)
+
+				activityEvents := []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+						Name: "a",
+					}, history.ScheduleEventID(4)),
+				}
+
+				err = b.CompleteWorkflowTask(
+					ctx, task, wfi, core.WorkflowInstanceStateActive, executed, activityEvents, []history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				// The running activity records a heartbeat
+				rewrittenHeartbeats := 2
+				if hr, ok := b.(backend.ActivityHeartbeatRecorder); ok {
+					at, err := b.GetActivityTask(ctx)
+					require.NoError(t, err)
+					require.NotNil(t, at)
+					require.NoError(t, hr.RecordActivityHeartbeat(ctx, at.ID, payload.Payload(`"h"`)))
+
+					rewrittenHeartbeats++
+				}
+
+				n, err = r.RewritePayloads(ctx, replace(`"b"`, `"y"`))
+				require.NoError(t, err)
+				require.Equal(t, 1, n)
+
+				// Heartbeat details of scheduled and failed activities, and of running ones, are rewritten, too
+				n, err = r.RewritePayloads(ctx, replace(`"h"`, `"z"`))
+				require.NoError(t, err)
+				require.Equal(t, rewrittenHeartbeats, n)
+
+				n, err = r.RewritePayloads(ctx, replace(`"h"`, `"z"`))
+				require.NoError(t, err)
+				require.Zero(t, n)
+
+				h, err := b.GetWorkflowInstanceHistory(ctx, wfi, nil)
+				require.NoError(t, err)
+				require.Len(t, h, 3)
+				require.Equal(t, []payload.Payload{payload.Payload(`"x"`), payload.Payload(`"y"`)},
+					eventAttributes[*history.ExecutionStartedAttributes](t, &h[0]).Inputs)
+				require.Equal(t, payload.Payload(`"z"`),
+					eventAttributes[*history.ActivityScheduledAttributes](t, &h[1]).HeartbeatDetails)
+				require.Equal(t, payload.Payload(`"z"`),
+					eventAttributes[*history.ActivityFailedAttributes](t, &h[2]).HeartbeatDetails)
+			},
+		},
+		{
+			name: "Leases_AreExclusiveUntilReleasedOrExpired",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				l, ok := b.(backend.Leaser)
+				if !ok {
+					t.Skip("backend does not support leases")
+				}
+
+				acquired, err := l.AcquireLease(ctx, "janitor", "a", time.Minute)
+				require.NoError(t, err)
+				require.True(t, acquired)
+
+				acquired, err = l.AcquireLease(ctx, "janitor", "b", time.Minute)
+				require.NoError(t, err)
+				require.False(t, acquired)
+
+				// Other leases are independent
+				acquired, err = l.AcquireLease(ctx, "retention", "b", time.Second)
+				require.NoError(t, err)
+				require.True(t, acquired)
+
+				// Owner can extend its lease
+				acquired, err = l.AcquireLease(ctx, "janitor", "a", time.Minute)
+				require.NoError(t, err)
+				require.True(t, acquired)
+
+				// Releasing a lease held by another owner has no effect
+				require.NoError(t, l.ReleaseLease(ctx, "janitor", "b"))
+				acquired, err = l.AcquireLease(ctx, "janitor", "b", time.Minute)
+				require.NoError(t, err)
+				require.False(t, acquired)
+
+				require.NoError(t, l.ReleaseLease(ctx, "janitor", "a"))
+				acquired, err = l.AcquireLease(ctx, "janitor", "b", time.Minute)
+				require.NoError(t, err)
+				require.True(t, acquired)
+
+				// Expired leases can be taken over
+				time.Sleep(2 * time.Second)
+				acquired, err = l.AcquireLease(ctx, "retention", "a", time.Minute)
+				require.NoError(t, err)
+				require.True(t, acquired)
+			},
+		},
+		{
+			name: "RunAsLeader_RunsSingleLeader",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				if _, ok := b.(backend.Leaser); !ok {
+					t.Skip("backend does not support leases")
+				}
+
+				var mu sync.Mutex
+				running := 0
+				leaders := make(chan int, 10)
+
+				campaign := func(ctx context.Context, id int) <-chan error {
+					errc := make(chan error, 1)
+					go func() {
+						errc <- backend.RunAsLeader(ctx, b, "janitor", time.Second, func(ctx context.Context) {
+							mu.Lock()
+							running++
+							require.Equal(t, 1, running)
+							mu.Unlock()
+
+							leaders <- id
+							<-ctx.Done()
+
+							mu.Lock()
+							running--
+							mu.Unlock()
+						})
+					}()
+
+					return errc
+				}
+
+				ctx1, cancel1 := context.WithCancel(ctx)
+				defer cancel1()
+				ctx2, cancel2 := context.WithCancel(ctx)
+				defer cancel2()
+
+				errc1 := campaign(ctx1, 1)
+				errc2 := campaign(ctx2, 2)
+
+				first := <-leaders
+
+				// Stopping the leader releases the lease, the other campaigner takes over
+				cancels := map[int]context.CancelFunc{1: cancel1, 2: cancel2}
+				cancels[first]()
+
+				select {
+				case second := <-leaders:
+					require.NotEqual(t, first, second)
+				case <-time.After(5 * time.Second):
+					require.FailNow(t, "leadership was not taken over")
+				}
+
+				cancel1()
+				cancel2()
+				require.NoError(t, <-errc1)
+				require.NoError(t, <-errc2)
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -347,3 +1229,10 @@ func startWorkflow(t *testing.T, ctx context.Context, b backend.Backend, c clien
 		ctx, task, instance, core.WorkflowInstanceStateActive, task.NewEvents, []history.Event{}, []history.Event{}, []history.WorkflowEvent{})
 	require.NoError(t, err)
 }
+
+func eventAttributes[T any](t *testing.T, event *history.Event) T {
+	a, err := history.AttributesAs[T](event)
+	require.NoError(t, err)
+
+	return a
+}
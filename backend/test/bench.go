@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/cschleiden/go-workflows/client"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/worker"
 	"github.com/cschleiden/go-workflows/workflow"
 	"github.com/google/uuid"
@@ -54,6 +56,63 @@ func SimpleWorkflowBenchmark(b *testing.B, setup func() TestBackend, teardown fu
 	require.NoError(b, w.WaitForCompletion())
 }
 
+// CompleteWorkflowTaskBenchmark measures checkpointing workflow tasks that each produce the given number of
+// history events, half of them scheduling activities, and as many timers.
+func CompleteWorkflowTaskBenchmark(b *testing.B, setup func() TestBackend, teardown func(b TestBackend), events int) {
+	backend := setup()
+	if teardown != nil {
+		defer teardown(backend)
+	}
+
+	ctx := context.Background()
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+
+		wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+		require.NoError(b, backend.CreateWorkflowInstance(
+			ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+		))
+
+		t, err := backend.GetWorkflowTask(ctx)
+		require.NoError(b, err)
+		require.NotNil(b, t)
+
+		executedEvents := append([]history.Event{}, t.NewEvents...)
+		activityEvents := make([]history.Event, 0, events/2)
+		timerEvents := make([]history.Event, 0, events/2)
+		for j := 0; j < events; j++ {
+			if j%2 == 0 {
+				e := history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+					Name: "activity",
+				}, history.ScheduleEventID(int64(j)))
+				activityEvents = append(activityEvents, e)
+				executedEvents = append(executedEvents, e)
+			} else {
+				executedEvents = append(executedEvents, history.NewPendingEvent(time.Now(), history.EventType_TimerScheduled, &history.TimerScheduledAttributes{
+					At: time.Now().Add(time.Hour),
+				}, history.ScheduleEventID(int64(j))))
+				timerEvents = append(timerEvents, history.NewPendingEvent(time.Now(), history.EventType_TimerFired, &history.TimerFiredAttributes{
+					At: time.Now().Add(time.Hour),
+				}, history.ScheduleEventID(int64(j)), history.VisibleAt(time.Now().Add(time.Hour))))
+			}
+		}
+
+		for j := range executedEvents {
+			executedEvents[j].SequenceID = int64(j + 1)
+		}
+
+		b.StartTimer()
+
+		require.NoError(b, backend.CompleteWorkflowTask(
+			ctx, t, wfi, core.WorkflowInstanceStateActive, executedEvents, activityEvents, timerEvents, []history.WorkflowEvent{}))
+	}
+
+	b.ReportMetric(float64(events), "events/task")
+}
+
 func startAndCompleteWorkflow(ctx context.Context, b *testing.B, c client.Client, wg *sync.WaitGroup) {
 	wf, err := c.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
 		InstanceID: uuid.NewString(),
@@ -2,18 +2,28 @@ package test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/cschleiden/go-workflows/activity"
 	"github.com/cschleiden/go-workflows/backend"
 	"github.com/cschleiden/go-workflows/client"
+	"github.com/cschleiden/go-workflows/converter"
 	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/fn"
 	"github.com/cschleiden/go-workflows/internal/history"
 	internalwf "github.com/cschleiden/go-workflows/internal/workflow"
 	"github.com/cschleiden/go-workflows/worker"
 	"github.com/cschleiden/go-workflows/workflow"
+	"github.com/cschleiden/go-workflows/workflowerrors"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 )
@@ -38,92 +48,900 @@ func EndToEndBackendTest(t *testing.T, setup func() TestBackend, teardown func(b
 				require.Equal(t, "hello world", output)
 			},
 		},
+		{
+			name: "Workflow_FailedWithTypedError",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				swf := func(ctx workflow.Context) error {
+					return workflowerrors.NewApplicationError("InvalidInput", "invalid input")
+				}
+				wf := func(ctx workflow.Context) error {
+					_, err := workflow.CreateSubWorkflowInstance[any](ctx, workflow.DefaultSubWorkflowOptions, swf).Get(ctx)
+
+					var childErr *workflowerrors.ChildWorkflowError
+					if !errors.As(err, &childErr) || workflowerrors.Type(err) != "InvalidInput" {
+						return fmt.Errorf("unexpected sub-workflow error: %w", err)
+					}
+
+					return err
+				}
+				register(t, ctx, w, []interface{}{wf, swf}, nil)
+
+				_, err := runWorkflowWithResult[any](t, ctx, c, wf)
+
+				// The client decodes the type of the error the workflow failed with
+				var wfErr *workflowerrors.WorkflowError
+				require.ErrorAs(t, err, &wfErr)
+				require.Equal(t, "InvalidInput", wfErr.Type)
+				require.EqualError(t, err, "invalid input")
+
+				var appErr *workflowerrors.ApplicationError
+				require.ErrorAs(t, err, &appErr)
+			},
+		},
 		{
 			name: "SimpleWorkflow_ExpectedHistory",
 			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
-				wf := func(ctx workflow.Context, msg string) (string, error) {
-					return msg + " world", nil
+				wf := func(ctx workflow.Context, msg string) (string, error) {
+					return msg + " world", nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				instance := runWorkflow(t, ctx, c, wf, "hello")
+
+				require.NoError(t, c.WaitForWorkflowInstance(ctx, instance, time.Second*10))
+
+				events, err := b.GetWorkflowInstanceHistory(ctx, instance, nil)
+				require.NoError(t, err)
+
+				require.Equal(t, history.EventType_WorkflowTaskStarted, events[0].Type)
+				require.Equal(t, history.EventType_WorkflowExecutionStarted, events[1].Type)
+				require.Equal(t, int64(0), events[1].ScheduleEventID)
+				require.Equal(t, history.EventType_WorkflowExecutionFinished, events[2].Type)
+				require.Equal(t, int64(0), events[2].ScheduleEventID)
+			},
+		},
+		{
+			name: "UnregisteredWorkflow_Errors",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				wf := func(ctx workflow.Context, msg string) (string, error) {
+					return msg + " world", nil
+				}
+				register(t, ctx, w, nil, nil)
+
+				output, err := runWorkflowWithResult[string](t, ctx, c, wf, "hello")
+
+				require.Zero(t, output)
+				require.ErrorContains(t, err, "workflow 1 not found")
+			},
+		},
+		{
+			name: "WorkflowArgumentMismatch",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				wf := func(ctx workflow.Context, p1 int) (int, error) {
+					return 42, nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				output, err := runWorkflowWithResult[int](t, ctx, c, wf)
+
+				require.Zero(t, output)
+				require.ErrorContains(t, err, "converting workflow inputs: mismatched argument count: expected 1, got 0")
+			},
+		},
+		{
+			name: "UnregisteredActivity_Errors",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				a := func(context.Context) error { return nil }
+				wf := func(ctx workflow.Context) (int, error) {
+					return workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+						RetryOptions: workflow.RetryOptions{
+							MaxAttempts: 1,
+						},
+					}, a).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				output, err := runWorkflowWithResult[int](t, ctx, c, wf)
+
+				require.Zero(t, output)
+				require.ErrorContains(t, err, "activity not found")
+			},
+		},
+		{
+			name: "ActivityArgumentMismatch",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				a := func(context.Context, int, int) error { return nil }
+				wf := func(ctx workflow.Context) (int, error) {
+					return workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+						RetryOptions: workflow.RetryOptions{
+							MaxAttempts: 1,
+						},
+					}, a, 42).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				output, err := runWorkflowWithResult[int](t, ctx, c, wf)
+
+				require.Zero(t, output)
+				require.ErrorContains(t, err, "converting activity inputs: mismatched argument count: expected 2, got 1")
+			},
+		},
+		{
+			name: "Activity_DrainFinishesRunningActivities",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				started := make(chan struct{})
+				release := make(chan struct{})
+
+				a := func(ctx context.Context) (int, error) {
+					close(started)
+					<-release
+
+					return 42, nil
+				}
+				wf := func(ctx workflow.Context) (int, error) {
+					return workflow.ExecuteActivity[int](ctx, workflow.DefaultActivityOptions, a).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				<-started
+				w.DrainActivities()
+
+				status := w.ActivityDrainStatus()
+				require.True(t, status.Draining)
+				require.Equal(t, 1, status.ActiveTasks)
+				require.False(t, status.Drained())
+
+				close(release)
+
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 42, r)
+				require.Eventually(t, func() bool {
+					return w.ActivityDrainStatus().Drained()
+				}, time.Second, 10*time.Millisecond)
+			},
+		},
+		{
+			name: "Activity_MaxRuntimeLimit",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				release := make(chan struct{})
+
+				// Ignores its context, so only the limit stops it
+				a := func(ctx context.Context) (int, error) {
+					<-release
+
+					return 42, nil
+				}
+				wf := func(ctx workflow.Context) (int, error) {
+					return workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+						RetryOptions: workflow.RetryOptions{MaxAttempts: 1},
+					}, a).Get(ctx)
+				}
+
+				options := worker.DefaultWorkerOptions
+				options.ActivityLimits = map[string]worker.ActivityLimits{
+					fn.Name(a): {MaxRuntime: 50 * time.Millisecond},
+				}
+				w2 := worker.New(b, &options)
+				t.Cleanup(func() {
+					close(release)
+					require.NoError(t, w2.WaitForCompletion())
+				})
+
+				register(t, ctx, w2, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				_, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.ErrorContains(t, err, "exceeded limit max_runtime (50ms)")
+			},
+		},
+		{
+			name: "Activity_StartToCloseTimeout",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				a := func(ctx context.Context) (int, error) {
+					<-ctx.Done()
+
+					return 0, ctx.Err()
+				}
+				wf := func(ctx workflow.Context) (int, error) {
+					return workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+						RetryOptions:        workflow.RetryOptions{MaxAttempts: 1},
+						StartToCloseTimeout: 50 * time.Millisecond,
+					}, a).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				_, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.ErrorContains(t, err, "did not complete within 50ms")
+			},
+		},
+		{
+			name: "Activity_ScheduleToStartTimeout",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				a := func(ctx context.Context) (int, error) {
+					return 42, nil
+				}
+				wf := func(ctx workflow.Context) (int, error) {
+					// No worker serves the queue, so the activity is never started
+					return workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+						RetryOptions:           workflow.RetryOptions{MaxAttempts: 1},
+						Queue:                  "unserved",
+						ScheduleToStartTimeout: 50 * time.Millisecond,
+					}, a).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				_, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.ErrorContains(t, err, "was not started within 50ms")
+			},
+		},
+		{
+			name: "Workflow_ExecutionTimeout",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				wf := func(ctx workflow.Context) (string, error) {
+					_, err := workflow.ScheduleTimer(ctx, time.Second*10).Get(ctx)
+					if err != workflow.Canceled {
+						return "", err
+					}
+
+					return workflow.Cause(ctx).Error(), nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				instance, err := c.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+					InstanceID:       uuid.NewString(),
+					ExecutionTimeout: 100 * time.Millisecond,
+				}, wf)
+				require.NoError(t, err)
+
+				r, err := client.GetWorkflowResult[string](ctx, c, instance, time.Second*5)
+				require.NoError(t, err)
+				require.Equal(t, workflow.ErrTimedOut.Error(), r)
+			},
+		},
+		{
+			name: "QueryExternalWorkflow",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				target := func(ctx workflow.Context, start int) error {
+					count := start
+
+					if err := workflow.SetQueryHandler(ctx, "count", func(add int) (int, error) {
+						return count + add, nil
+					}); err != nil {
+						return err
+					}
+
+					workflow.NewSignalChannel[int](ctx, "inc").Receive(ctx)
+					count++
+
+					workflow.NewSignalChannel[any](ctx, "done").Receive(ctx)
+
+					return nil
+				}
+				wf := func(ctx workflow.Context, instanceID string) (int, error) {
+					options := workflow.ActivityOptions{RetryOptions: workflow.RetryOptions{MaxAttempts: 1}}
+
+					if _, err := workflow.QueryExternalWorkflow[int](ctx, options, instanceID, "unknown").Get(ctx); err == nil {
+						return 0, errors.New("expected query without handler to fail")
+					}
+
+					return workflow.QueryExternalWorkflow[int](ctx, options, instanceID, "count", 10).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{target, wf}, nil)
+
+				instance := runWorkflow(t, ctx, c, target, 30)
+				require.NoError(t, c.SignalWorkflow(ctx, instance.InstanceID, "inc", 1))
+
+				// Wait for the signal to be processed
+				require.Eventually(t, func() bool {
+					h, err := b.GetWorkflowInstanceHistory(ctx, instance, nil)
+					require.NoError(t, err)
+
+					for _, e := range h {
+						if e.Type == history.EventType_SignalReceived {
+							return true
+						}
+					}
+
+					return false
+				}, time.Second*10, time.Millisecond*100)
+
+				r, err := runWorkflowWithResult[int](t, ctx, c, wf, instance.InstanceID)
+				require.NoError(t, err)
+				require.Equal(t, 41, r)
+
+				require.NoError(t, c.SignalWorkflow(ctx, instance.InstanceID, "done", nil))
+				require.NoError(t, c.WaitForWorkflowInstance(ctx, instance, time.Second*10))
+			},
+		},
+		{
+			name: "QueryWorkflow_FromClient",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				wf := func(ctx workflow.Context) error {
+					items := []string{}
+
+					if err := workflow.SetQueryHandler(ctx, "items", func() ([]string, error) {
+						return items, nil
+					}); err != nil {
+						return err
+					}
+
+					ch := workflow.NewSignalChannel[string](ctx, "add")
+					for len(items) < 2 {
+						item, _ := ch.Receive(ctx)
+						items = append(items, item)
+					}
+
+					return nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				instance := runWorkflow(t, ctx, c, wf)
+				require.NoError(t, c.SignalWorkflow(ctx, instance.InstanceID, "add", "apple"))
+
+				qc := client.New(b, client.WithWorkflows(wf))
+
+				require.Eventually(t, func() bool {
+					// The instance has no history until the worker executed its first task
+					items, err := client.QueryWorkflow[[]string](ctx, qc, instance.InstanceID, "items")
+
+					return err == nil && len(items) == 1
+				}, time.Second*10, time.Millisecond*100)
+
+				_, err := client.QueryWorkflow[int](ctx, qc, instance.InstanceID, "unknown")
+				require.ErrorIs(t, err, client.ErrQueryHandlerNotFound)
+
+				// Instances of workflows that are not registered with the client can't be queried
+				_, err = client.QueryWorkflow[[]string](ctx, c, instance.InstanceID, "items")
+				require.Error(t, err)
+				require.NotErrorIs(t, err, client.ErrQueryHandlerNotFound)
+
+				require.NoError(t, c.SignalWorkflow(ctx, instance.InstanceID, "add", "banana"))
+				require.NoError(t, c.WaitForWorkflowInstance(ctx, instance, time.Second*10))
+
+				items, err := client.QueryWorkflow[[]string](ctx, qc, instance.InstanceID, "items")
+				require.NoError(t, err)
+				require.Equal(t, []string{"apple", "banana"}, items)
+			},
+		},
+		{
+			name: "Activity_OptionDefaults",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				var mu sync.Mutex
+				calls := map[string]int{}
+
+				a := func(ctx context.Context, id string) error {
+					mu.Lock()
+					defer mu.Unlock()
+
+					calls[id]++
+
+					return errors.New("failing")
+				}
+				wf := func(ctx workflow.Context) error {
+					// Uses the defaults registered on the worker
+					workflow.ExecuteActivity[any](ctx, workflow.DefaultActivityOptions, a, "defaults").Get(ctx)
+
+					// Overrides them
+					workflow.ExecuteActivity[any](ctx, workflow.ActivityOptions{
+						RetryOptions: workflow.RetryOptions{MaxAttempts: 1},
+					}, a, "override").Get(ctx)
+
+					return nil
+				}
+
+				options := worker.DefaultWorkerOptions
+				options.ActivityDefaults = map[string]workflow.ActivityOptions{
+					fn.Name(a): {RetryOptions: workflow.RetryOptions{MaxAttempts: 2, FirstRetryInterval: time.Millisecond}},
+				}
+				w2 := worker.New(b, &options)
+				t.Cleanup(func() {
+					require.NoError(t, w2.WaitForCompletion())
+				})
+
+				register(t, ctx, w2, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				_, err := client.GetWorkflowResult[any](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+
+				mu.Lock()
+				defer mu.Unlock()
+				require.Equal(t, map[string]int{"defaults": 2, "override": 1}, calls)
+			},
+		},
+		{
+			name: "Workflow_ConcurrencyLimit",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				if _, ok := b.(backend.WorkflowTaskAbandoner); !ok {
+					t.Skip("backend does not support abandoning workflow tasks")
+				}
+
+				var mu sync.Mutex
+				running, maxRunning := 0, 0
+
+				a := func(ctx context.Context) error {
+					mu.Lock()
+					running++
+					if running > maxRunning {
+						maxRunning = running
+					}
+					mu.Unlock()
+
+					time.Sleep(50 * time.Millisecond)
+
+					mu.Lock()
+					running--
+					mu.Unlock()
+
+					return nil
+				}
+				wf := func(ctx workflow.Context) error {
+					_, err := workflow.ExecuteActivity[any](ctx, workflow.DefaultActivityOptions, a).Get(ctx)
+					return err
+				}
+
+				options := worker.DefaultWorkerOptions
+				options.WorkflowConcurrencyLimiter = worker.NewWorkflowConcurrencyLimiter(map[string]int{
+					fn.Name(wf): 1,
+				})
+				w2 := worker.New(b, &options)
+				t.Cleanup(func() {
+					require.NoError(t, w2.WaitForCompletion())
+				})
+
+				register(t, ctx, w2, []interface{}{wf}, []interface{}{a})
+
+				instances := make([]*workflow.Instance, 0)
+				for i := 0; i < 3; i++ {
+					instances = append(instances, runWorkflow(t, ctx, c, wf))
+				}
+
+				for _, instance := range instances {
+					_, err := client.GetWorkflowResult[any](ctx, c, instance, time.Second*10)
+					require.NoError(t, err)
+				}
+
+				require.Equal(t, 1, maxRunning)
+			},
+		},
+		{
+			name: "Worker_WorkflowConcurrencyLimiter_ReleasesLeakedSlots",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				wf := func(ctx workflow.Context) error {
+					return nil
+				}
+
+				limiter := worker.NewWorkflowConcurrencyLimiter(map[string]int{
+					fn.Name(wf): 1,
+				})
+
+				// Slot held by an instance that doesn't exist, e.g., because it was removed
+				ok, err := limiter.Acquire(ctx, fn.Name(wf), uuid.NewString())
+				require.NoError(t, err)
+				require.True(t, ok)
+
+				options := worker.DefaultWorkerOptions
+				options.WorkflowConcurrencyLimiter = limiter
+				w2 := worker.New(b, &options)
+				t.Cleanup(func() {
+					require.NoError(t, w2.WaitForCompletion())
+				})
+
+				register(t, ctx, w2, []interface{}{wf}, nil)
+
+				instance := runWorkflow(t, ctx, c, wf)
+				_, err = client.GetWorkflowResult[any](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "Worker_LifecycleHooks",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				a := func(ctx context.Context) error {
+					return nil
+				}
+				wf := func(ctx workflow.Context) error {
+					_, err := workflow.ExecuteActivity[any](ctx, workflow.DefaultActivityOptions, a).Get(ctx)
+					return err
+				}
+
+				var mu sync.Mutex
+				started, stopped := 0, 0
+				taskStarts := map[worker.TaskKind]int{}
+				completed := map[string]int{}
+
+				options := worker.DefaultWorkerOptions
+				options.Hooks = worker.Hooks{
+					OnWorkerStart: func(ctx context.Context) {
+						mu.Lock()
+						defer mu.Unlock()
+						started++
+					},
+					OnWorkerStop: func() {
+						mu.Lock()
+						defer mu.Unlock()
+						stopped++
+					},
+					OnTaskStart: func(ctx context.Context, info worker.TaskInfo) {
+						mu.Lock()
+						defer mu.Unlock()
+						taskStarts[info.Kind]++
+					},
+					OnTaskComplete: func(ctx context.Context, info worker.TaskInfo, err error) {
+						mu.Lock()
+						defer mu.Unlock()
+						if err == nil {
+							completed[info.Name]++
+						}
+					},
+				}
+				w2 := worker.New(b, &options)
+				t.Cleanup(func() {
+					require.NoError(t, w2.WaitForCompletion())
+
+					mu.Lock()
+					defer mu.Unlock()
+					require.Equal(t, 1, stopped)
+				})
+
+				register(t, ctx, w2, []interface{}{wf}, []interface{}{a})
+
+				mu.Lock()
+				require.Equal(t, 1, started)
+				mu.Unlock()
+
+				instance := runWorkflow(t, ctx, c, wf)
+				_, err := client.GetWorkflowResult[any](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+
+				// The hook for the last workflow task is called after the task has been completed
+				require.Eventually(t, func() bool {
+					mu.Lock()
+					defer mu.Unlock()
+
+					return completed[fn.Name(wf)] >= 2 && completed[fn.Name(a)] == 1
+				}, time.Second*5, 10*time.Millisecond)
+
+				mu.Lock()
+				defer mu.Unlock()
+				require.GreaterOrEqual(t, taskStarts[worker.TaskKindWorkflow], 2)
+				require.Equal(t, 1, taskStarts[worker.TaskKindActivity])
+				require.Equal(t, 0, stopped)
+			},
+		},
+		{
+			name: "Worker_HealthListener",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				// Find a free port for the listener
+				l, err := net.Listen("tcp", "127.0.0.1:0")
+				require.NoError(t, err)
+				addr := l.Addr().String()
+				require.NoError(t, l.Close())
+
+				options := worker.DefaultWorkerOptions
+				options.HealthListenAddr = addr
+				w2 := worker.New(b, &options)
+				t.Cleanup(func() {
+					require.NoError(t, w2.WaitForCompletion())
+				})
+
+				wctx, cancel := context.WithCancel(ctx)
+				register(t, wctx, w2, nil, nil)
+
+				get := func(path string) int {
+					r, err := http.Get("http://" + addr + path)
+					require.NoError(t, err)
+					defer r.Body.Close()
+
+					return r.StatusCode
+				}
+
+				require.Equal(t, http.StatusOK, get("/healthz"))
+				require.Equal(t, http.StatusOK, get("/readyz"))
+				require.Equal(t, http.StatusOK, get("/inflight"))
+
+				// Draining workers are not ready for new work
+				w2.DrainActivities()
+				require.Equal(t, http.StatusServiceUnavailable, get("/readyz"))
+				require.Equal(t, http.StatusOK, get("/healthz"))
+
+				// The listener keeps serving while the worker shuts down
+				cancel()
+				time.Sleep(50 * time.Millisecond)
+				require.Equal(t, http.StatusOK, get("/healthz"))
+				require.Equal(t, http.StatusOK, get("/inflight"))
+			},
+		},
+		{
+			name: "Activity_Interceptors",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				a := func(ctx context.Context, msg string) (string, error) {
+					// Activities receive the intercepted input
+					require.Equal(t, "tenant/hello", msg)
+					return msg, nil
+				}
+				wf := func(ctx workflow.Context, msg string) (string, error) {
+					return workflow.ExecuteActivity[string](ctx, workflow.DefaultActivityOptions, a, msg).Get(ctx)
+				}
+
+				options := worker.DefaultWorkerOptions
+				options.ActivityInterceptors = []workflow.ActivityInterceptor{&tenantInterceptor{"tenant"}}
+				w2 := worker.New(b, &options)
+				t.Cleanup(func() {
+					require.NoError(t, w2.WaitForCompletion())
+				})
+
+				register(t, ctx, w2, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf, "hello")
+				r, err := client.GetWorkflowResult[string](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, "hello", r)
+
+				h, err := b.GetWorkflowInstanceHistory(ctx, instance, nil)
+				require.NoError(t, err)
+				for i := range h {
+					if h[i].Type == history.EventType_ActivityScheduled {
+						inputs := eventAttributes[*history.ActivityScheduledAttributes](t, &h[i]).Inputs
+						require.Equal(t, `"tenant/hello"`, string(inputs[0]))
+					}
+				}
+			},
+		},
+		{
+			name: "Activity_RetryNow",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				var attempts int32
+				a := func(ctx context.Context) (int, error) {
+					if atomic.AddInt32(&attempts, 1) == 1 {
+						return 0, errors.New("unavailable")
+					}
+
+					return 42, nil
+				}
+				wf := func(ctx workflow.Context) (int, error) {
+					return workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+						RetryOptions: workflow.RetryOptions{
+							MaxAttempts:        2,
+							FirstRetryInterval: time.Hour,
+							BackoffCoefficient: 1,
+						},
+					}, a).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				require.ErrorIs(t, c.RetryActivityNow(ctx, instance, 42), client.ErrActivityNotFailed)
+
+				scheduleEventID := waitForFailedActivity(t, ctx, b, instance)
+				require.NoError(t, c.RetryActivityNow(ctx, instance, scheduleEventID))
+
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 42, r)
+				require.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+			},
+		},
+		{
+			name: "Activity_FailNow",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				a := func(ctx context.Context) (int, error) {
+					return 0, errors.New("unavailable")
+				}
+				wf := func(ctx workflow.Context) (int, error) {
+					return workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+						RetryOptions: workflow.RetryOptions{
+							MaxAttempts:        10,
+							FirstRetryInterval: time.Hour,
+							BackoffCoefficient: 1,
+						},
+					}, a).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				scheduleEventID := waitForFailedActivity(t, ctx, b, instance)
+				require.NoError(t, c.FailActivityNow(ctx, instance, scheduleEventID, "skipped by operator"))
+
+				_, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.EqualError(t, err, "skipped by operator")
+			},
+		},
+		{
+			name: "Activity_Streaming",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				a := func(ctx context.Context) (int, error) {
+					for i := 1; i <= 3; i++ {
+						if err := activity.Stream(ctx, i); err != nil {
+							return 0, err
+						}
+					}
+
+					return 42, nil
 				}
-				register(t, ctx, w, []interface{}{wf}, nil)
+				wf := func(ctx workflow.Context) ([]int, error) {
+					chunks, f := workflow.ExecuteStreamingActivity[int, int](ctx, workflow.DefaultActivityOptions, a)
+
+					r := []int{}
+					for {
+						c, ok := chunks.Receive(ctx)
+						if !ok {
+							break
+						}
 
-				instance := runWorkflow(t, ctx, c, wf, "hello")
+						r = append(r, c)
+					}
 
-				require.NoError(t, c.WaitForWorkflowInstance(ctx, instance, time.Second*10))
+					result, err := f.Get(ctx)
+					if err != nil {
+						return nil, err
+					}
 
-				events, err := b.GetWorkflowInstanceHistory(ctx, instance, nil)
-				require.NoError(t, err)
+					return append(r, result), nil
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
 
-				require.Equal(t, history.EventType_WorkflowTaskStarted, events[0].Type)
-				require.Equal(t, history.EventType_WorkflowExecutionStarted, events[1].Type)
-				require.Equal(t, int64(0), events[1].ScheduleEventID)
-				require.Equal(t, history.EventType_WorkflowExecutionFinished, events[2].Type)
-				require.Equal(t, int64(0), events[2].ScheduleEventID)
+				output, err := runWorkflowWithResult[[]int](t, ctx, c, wf)
+
+				require.NoError(t, err)
+				require.Equal(t, []int{1, 2, 3, 42}, output)
 			},
 		},
 		{
-			name: "UnregisteredWorkflow_Errors",
+			name: "Signal_ExecutedInChunks",
 			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
-				wf := func(ctx workflow.Context, msg string) (string, error) {
-					return msg + " world", nil
+				if _, ok := b.(backend.WorkflowTaskCheckpointer); !ok {
+					t.Skip("backend does not support checkpointing workflow tasks")
 				}
-				register(t, ctx, w, nil, nil)
 
-				output, err := runWorkflowWithResult[string](t, ctx, c, wf, "hello")
+				wf := func(ctx workflow.Context) (int, error) {
+					sc := workflow.NewSignalChannel[int](ctx, "signal")
 
-				require.Zero(t, output)
-				require.ErrorContains(t, err, "workflow 1 not found")
+					sum := 0
+					for i := 0; i < 5; i++ {
+						v, _ := sc.Receive(ctx)
+						sum += v
+					}
+
+					return sum, nil
+				}
+
+				// Use a separate worker that commits the progress of workflow tasks every two new events
+				options := worker.DefaultWorkerOptions
+				options.WorkflowTaskChunkSize = 2
+				w2 := worker.New(b, &options)
+				t.Cleanup(func() {
+					require.NoError(t, w2.WaitForCompletion())
+				})
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				// Queue up all signals before the instance is executed for the first time
+				for i := 1; i <= 5; i++ {
+					require.NoError(t, c.SignalWorkflow(ctx, instance.InstanceID, "signal", i))
+				}
+
+				register(t, ctx, w2, []interface{}{wf}, nil)
+
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 15, r)
+
+				h, err := b.GetWorkflowInstanceHistory(ctx, instance, nil)
+				require.NoError(t, err)
+
+				// Every chunk is executed like a separate workflow task
+				chunks := 0
+				for _, e := range h {
+					if e.Type == history.EventType_WorkflowTaskStarted {
+						chunks++
+					}
+				}
+				require.GreaterOrEqual(t, chunks, 3)
 			},
 		},
 		{
-			name: "WorkflowArgumentMismatch",
+			name: "Workflow_RecordsDefinitionChecksum",
 			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
-				wf := func(ctx workflow.Context, p1 int) (int, error) {
-					return 42, nil
+				wf := func(ctx workflow.Context) error {
+					return nil
 				}
 				register(t, ctx, w, []interface{}{wf}, nil)
 
-				output, err := runWorkflowWithResult[int](t, ctx, c, wf)
+				instance := runWorkflow(t, ctx, c, wf)
 
-				require.Zero(t, output)
-				require.ErrorContains(t, err, "converting workflow inputs: mismatched argument count: expected 1, got 0")
+				_, err := client.GetWorkflowResult[any](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+
+				h, err := b.GetWorkflowInstanceHistory(ctx, instance, nil)
+				require.NoError(t, err)
+
+				var checksum string
+				for i := range h {
+					if h[i].Type == history.EventType_WorkflowExecutionStarted {
+						checksum = eventAttributes[*history.ExecutionStartedAttributes](t, &h[i]).DefinitionChecksum
+					}
+				}
+				require.NotEmpty(t, checksum)
 			},
 		},
 		{
-			name: "UnregisteredActivity_Errors",
+			name: "Workflow_RecordMarker",
 			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
-				a := func(context.Context) error { return nil }
-				wf := func(ctx workflow.Context) (int, error) {
-					return workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
-						RetryOptions: workflow.RetryOptions{
-							MaxAttempts: 1,
-						},
-					}, a).Get(ctx)
+				wf := func(ctx workflow.Context) error {
+					if err := workflow.RecordMarker(ctx, "checkpoint", 42); err != nil {
+						return err
+					}
+
+					// Force a second workflow task
+					workflow.Sleep(ctx, time.Millisecond*1)
+
+					return nil
 				}
 				register(t, ctx, w, []interface{}{wf}, nil)
 
-				output, err := runWorkflowWithResult[int](t, ctx, c, wf)
+				instance := runWorkflow(t, ctx, c, wf)
 
-				require.Zero(t, output)
-				require.ErrorContains(t, err, "activity not found")
+				_, err := client.GetWorkflowResult[any](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+
+				h, err := b.GetWorkflowInstanceHistory(ctx, instance, nil)
+				require.NoError(t, err)
+
+				markers := make([]*history.MarkerRecordedAttributes, 0)
+				for i := range h {
+					if h[i].Type == history.EventType_MarkerRecorded {
+						markers = append(markers, eventAttributes[*history.MarkerRecordedAttributes](t, &h[i]))
+					}
+				}
+				require.Len(t, markers, 1)
+				require.Equal(t, "checkpoint", markers[0].Name)
+				require.Equal(t, "42", string(markers[0].Details))
 			},
 		},
 		{
-			name: "ActivityArgumentMismatch",
+			name: "Workflow_WaitAllAndWaitAny",
 			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
-				a := func(context.Context, int, int) error { return nil }
+				a := func(ctx context.Context, i int) (int, error) {
+					return i, nil
+				}
 				wf := func(ctx workflow.Context) (int, error) {
-					return workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
-						RetryOptions: workflow.RetryOptions{
-							MaxAttempts: 1,
-						},
-					}, a, 42).Get(ctx)
+					futures := make([]workflow.Future[int], 0)
+					for i := 1; i <= 3; i++ {
+						futures = append(futures, workflow.ExecuteActivity[int](ctx, workflow.DefaultActivityOptions, a, i))
+					}
+
+					r, err := workflow.WaitAll(ctx, futures...)
+					if err != nil {
+						return 0, err
+					}
+
+					// All futures are resolved, the first one wins
+					i, v, err := workflow.WaitAny(ctx, futures...)
+					if err != nil {
+						return 0, err
+					}
+
+					return r[0] + r[1] + r[2] + i*10 + v*100, nil
 				}
 				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
 
-				output, err := runWorkflowWithResult[int](t, ctx, c, wf)
+				instance := runWorkflow(t, ctx, c, wf)
 
-				require.Zero(t, output)
-				require.ErrorContains(t, err, "converting activity inputs: mismatched argument count: expected 2, got 1")
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 106, r)
 			},
 		},
 		{
@@ -312,6 +1130,140 @@ func EndToEndBackendTest(t *testing.T, setup func() TestBackend, teardown func(b
 				require.Equal(t, backend.ErrInstanceNotFound, err)
 			},
 		},
+		{
+			name: "ContinueAsNew",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				a := func(ctx context.Context, n int) (int, error) {
+					return n + 1, nil
+				}
+				wf := func(ctx workflow.Context, n int) (int, error) {
+					n, err := workflow.ExecuteActivity[int](ctx, workflow.DefaultActivityOptions, a, n).Get(ctx)
+					if err != nil {
+						return 0, err
+					}
+
+					if n < 3 {
+						return 0, workflow.ContinueAsNew(ctx, n)
+					}
+
+					return n, nil
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf, 0)
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 3, r)
+
+				// The history of the instance is the one of the last execution
+				started := 0
+				historyIterate(ctx, t, b, instance, func(event *history.Event) bool {
+					if event.Type == history.EventType_WorkflowExecutionStarted {
+						started++
+					}
+
+					return true
+				})
+				require.Equal(t, 1, started)
+
+				if _, ok := b.(backend.ExecutionHistoryReader); !ok {
+					return
+				}
+
+				// Histories of the executions the instance continued from are kept
+				h, err := backend.GetWorkflowExecutionHistory(ctx, b, instance)
+				require.NoError(t, err)
+				require.NotEmpty(t, h)
+
+				last := h[len(h)-1]
+				require.Equal(t, history.EventType_WorkflowExecutionFinished, last.Type)
+				finished, err := history.AttributesAs[*history.ExecutionCompletedAttributes](&last)
+				require.NoError(t, err)
+				require.NotEmpty(t, finished.ContinuedAsNew)
+
+				if l, ok := b.(backend.CompletedInstanceLister); ok {
+					completed, err := l.GetCompletedWorkflowInstances(ctx, nil, time.Now().Add(time.Hour), 100)
+					require.NoError(t, err)
+
+					executions := map[string]bool{}
+					for _, ci := range completed {
+						if ci.Instance.InstanceID == instance.InstanceID {
+							executions[ci.Instance.ExecutionID] = true
+						}
+					}
+					require.Len(t, executions, 3)
+					require.True(t, executions[instance.ExecutionID])
+					require.True(t, executions[finished.ContinuedAsNew])
+				}
+			},
+		},
+		{
+			name: "ContinueAsNew_HierarchicalSubWorkflowInstanceIDs",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				swf := func(ctx workflow.Context, n int) (int, error) {
+					return n, nil
+				}
+				wf := func(ctx workflow.Context, n int) (int, error) {
+					r, err := workflow.CreateSubWorkflowInstance[int](ctx, workflow.DefaultSubWorkflowOptions, swf, n+1).Get(ctx)
+					if err != nil {
+						return 0, err
+					}
+
+					if r < 3 {
+						return 0, workflow.ContinueAsNew(ctx, r)
+					}
+
+					return r, nil
+				}
+
+				// Every execution starts its first sub-workflow, their IDs must not collide
+				options := worker.DefaultWorkerOptions
+				options.SubWorkflowInstanceID = workflow.HierarchicalSubWorkflowInstanceID
+				w2 := worker.New(b, &options)
+				t.Cleanup(func() {
+					require.NoError(t, w2.WaitForCompletion())
+				})
+
+				register(t, ctx, w2, []interface{}{wf, swf}, nil)
+
+				instance := runWorkflow(t, ctx, c, wf, 0)
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 3, r)
+			},
+		},
+		{
+			name: "SubWorkflow_DuplicateInstanceID",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				swf := func(ctx workflow.Context) (int, error) {
+					return 42, nil
+				}
+				wf := func(ctx workflow.Context) (int, error) {
+					if _, err := workflow.CreateSubWorkflowInstance[int](ctx, workflow.DefaultSubWorkflowOptions, swf).Get(ctx); err != nil {
+						return 0, err
+					}
+
+					// Derived instance ID is taken by the finished sub-workflow
+					return workflow.CreateSubWorkflowInstance[int](ctx, workflow.DefaultSubWorkflowOptions, swf).Get(ctx)
+				}
+
+				// Every sub-workflow of an instance gets the same ID
+				options := worker.DefaultWorkerOptions
+				options.SubWorkflowInstanceID = func(parentInstanceID, parentExecutionID, name string, n int) string {
+					return parentInstanceID + "/sub"
+				}
+				w2 := worker.New(b, &options)
+				t.Cleanup(func() {
+					require.NoError(t, w2.WaitForCompletion())
+				})
+
+				register(t, ctx, w2, []interface{}{wf, swf}, nil)
+
+				instance := runWorkflow(t, ctx, c, wf)
+				_, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.ErrorContains(t, err, backend.ErrInstanceAlreadyExists.Error())
+			},
+		},
 		{
 			name: "SubWorkflow_Signal",
 			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
@@ -459,6 +1411,37 @@ func EndToEndBackendTest(t *testing.T, setup func() TestBackend, teardown func(b
 				require.Len(t, futureEvents, 0, "no future events should be scheduled")
 			},
 		},
+		{
+			name: "CancelScope_CancelAfterCancelsOnlyScope",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				a := func(ctx context.Context) error {
+					return nil
+				}
+				wf := func(ctx workflow.Context) (string, error) {
+					sctx, scope := workflow.WithCancelScope(ctx)
+					scope.CancelAfter(time.Millisecond * 100)
+
+					if _, err := workflow.ScheduleTimer(sctx, time.Second*10).Get(ctx); err != workflow.Canceled {
+						return "", fmt.Errorf("expected timer to be canceled, got %v", err)
+					}
+
+					// The workflow itself continues
+					if _, err := workflow.ExecuteActivity[any](ctx, workflow.DefaultActivityOptions, a).Get(ctx); err != nil {
+						return "", err
+					}
+
+					return scope.Cause().Error(), nil
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf)
+				r, err := client.GetWorkflowResult[string](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, workflow.ErrCancelScopeTimedOut.Error(), r)
+
+				historyContains(ctx, t, b, instance, history.EventType_TimerScheduled, history.EventType_TimerCanceled)
+			},
+		},
 		{
 			name: "Timer_CancelBeforeFiringRemovesFutureEvent",
 			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
@@ -497,6 +1480,38 @@ func EndToEndBackendTest(t *testing.T, setup func() TestBackend, teardown func(b
 				require.Len(t, futureEvents, 0, "no future events should be scheduled")
 			},
 		},
+		{
+			name: "Timer_RescheduleReplacesFutureEvent",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				a := func(ctx context.Context) error {
+					return nil
+				}
+				wf := func(ctx workflow.Context) error {
+					f := workflow.ScheduleNamedTimer(ctx, "deadline", time.Hour)
+
+					// Force the checkpoint before continuing the execution
+					workflow.ExecuteActivity[any](ctx, workflow.DefaultActivityOptions, a).Get(ctx)
+
+					if err := workflow.RescheduleTimer(ctx, "deadline", time.Millisecond); err != nil {
+						return err
+					}
+
+					_, err := f.Get(ctx)
+					return err
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf)
+				_, err := client.GetWorkflowResult[any](ctx, c, instance, time.Second*5)
+				require.NoError(t, err)
+
+				historyContains(ctx, t, b, instance, history.EventType_TimerScheduled, history.EventType_TimerRescheduled, history.EventType_TimerFired)
+
+				futureEvents, err := b.GetFutureEvents(ctx)
+				require.NoError(t, err)
+				require.Len(t, futureEvents, 0, "no future events should be scheduled")
+			},
+		},
 		{
 			name:         "NonDeterminism",
 			withoutCache: true,
@@ -593,6 +1608,46 @@ func (*noopWorkflowExecutorCache) Store(ctx context.Context, instance *core.Work
 	return nil
 }
 
+// tenantInterceptor scopes string inputs of activities to a tenant, and removes the scope from their results.
+type tenantInterceptor struct {
+	tenant string
+}
+
+func (i *tenantInterceptor) OnSchedule(activityName string, inputs []converter.Payload) ([]converter.Payload, error) {
+	r := make([]converter.Payload, len(inputs))
+	for j, p := range inputs {
+		r[j] = converter.Payload(strings.Replace(string(p), `"`, `"`+i.tenant+"/", 1))
+	}
+
+	return r, nil
+}
+
+func (i *tenantInterceptor) OnComplete(activityName string, result converter.Payload) (converter.Payload, error) {
+	return converter.Payload(strings.Replace(string(result), `"`+i.tenant+"/", `"`, 1)), nil
+}
+
+// waitForFailedActivity waits until an activity of the given instance has failed, and returns the schedule event
+// ID of the failed attempt.
+func waitForFailedActivity(t *testing.T, ctx context.Context, b backend.Backend, instance *workflow.Instance) int64 {
+	var scheduleEventID int64
+
+	require.Eventually(t, func() bool {
+		h, err := b.GetWorkflowInstanceHistory(ctx, instance, nil)
+		require.NoError(t, err)
+
+		for i := range h {
+			if h[i].Type == history.EventType_ActivityFailed {
+				scheduleEventID = h[i].ScheduleEventID
+				return true
+			}
+		}
+
+		return false
+	}, 10*time.Second, 100*time.Millisecond)
+
+	return scheduleEventID
+}
+
 func register(t *testing.T, ctx context.Context, w worker.Worker, workflows []interface{}, activities []interface{}) {
 	for _, wf := range workflows {
 		require.NoError(t, w.RegisterWorkflow(wf))
@@ -0,0 +1,106 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/metrics"
+)
+
+// PendingTimer is a timer that has not been delivered to its workflow instance yet.
+type PendingTimer struct {
+	InstanceID      string
+	ScheduleEventID int64
+
+	// ScheduledAt is the time the timer was scheduled.
+	ScheduledAt time.Time
+
+	// FireAt is the time the timer is expected to become visible to its workflow instance.
+	FireAt time.Time
+}
+
+// Skew returns how far the timer is behind schedule at the given time, 0 if it's not due yet.
+func (t *PendingTimer) Skew(now time.Time) time.Duration {
+	if skew := now.Sub(t.FireAt); skew > 0 {
+		return skew
+	}
+
+	return 0
+}
+
+// TimerLister is implemented by backends that can list their pending timers.
+type TimerLister interface {
+	// PendingTimers returns up to count timers that have not been delivered to their workflow instances yet,
+	// ordered by the time they are expected to fire.
+	PendingTimers(ctx context.Context, count int) ([]*PendingTimer, error)
+}
+
+var ErrTimersNotSupported = errors.New("backend does not support listing timers")
+
+// MonitorTimerSkew periodically checks how far the most overdue timer of the given backend is behind schedule, until
+// the given context is canceled. The skew is reported as a gauge in milliseconds through the backend's metrics
+// client, and a warning is logged whenever it exceeds threshold, e.g., because workers are not keeping up with
+// delivering timers.
+func MonitorTimerSkew(ctx context.Context, b Backend, interval, threshold time.Duration) error {
+	l, ok := b.(TimerLister)
+	if !ok {
+		return ErrTimersNotSupported
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		timers, err := l.PendingTimers(ctx, 1)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			b.Logger().Error("listing pending timers", "error", err)
+		} else {
+			var skew time.Duration
+			if len(timers) > 0 {
+				skew = timers[0].Skew(time.Now())
+			}
+
+			b.Metrics().Gauge(metrickeys.BackendTimerSkew, metrics.Tags{}, skew.Milliseconds())
+
+			if threshold > 0 && skew > threshold {
+				b.Logger().Warn("Timers are behind schedule",
+					"instance_id", timers[0].InstanceID,
+					"schedule_event_id", timers[0].ScheduleEventID,
+					"fire_at", timers[0].FireAt,
+					"skew_ms", skew.Milliseconds(),
+				)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// RebaseFutureEvents returns the given events with their VisibleAt moved from the clock of the worker that scheduled
+// them to the given time of the backend. Workers compute VisibleAt from their local clock, the delay relative to the
+// event's Timestamp is kept, so timers fire after the requested delay even if the clock of the worker is skewed.
+// Backends call it with the timer events of a workflow task before scheduling them.
+func RebaseFutureEvents(now time.Time, events []history.Event) []history.Event {
+	rebased := make([]history.Event, len(events))
+	for i, event := range events {
+		if event.VisibleAt != nil {
+			visibleAt := now.Add(event.VisibleAt.Sub(event.Timestamp))
+			event.VisibleAt = &visibleAt
+		}
+
+		rebased[i] = event
+	}
+
+	return rebased
+}
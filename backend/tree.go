@@ -0,0 +1,121 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	core "github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+// WorkflowInstanceNode is a workflow instance in a tree of workflow instances and their sub-workflows. The
+// parent of a sub-workflow instance is set on Instance.
+type WorkflowInstanceNode struct {
+	Instance    *workflow.Instance
+	State       core.WorkflowInstanceState
+	CreatedAt   time.Time
+	CompletedAt *time.Time
+}
+
+// InstanceTreeReader is implemented by backends that can look up workflow instances by their parent, to build
+// trees of nested workflow instances.
+type InstanceTreeReader interface {
+	// GetWorkflowInstanceNode returns the instance with the given id. It returns ErrInstanceNotFound if the
+	// instance doesn't exist or has been removed.
+	GetWorkflowInstanceNode(ctx context.Context, instanceID string) (*WorkflowInstanceNode, error)
+
+	// GetSubWorkflowInstanceNodes returns the sub-workflow instances started by the given instance, oldest first.
+	GetSubWorkflowInstanceNodes(ctx context.Context, instanceID string) ([]*WorkflowInstanceNode, error)
+}
+
+var ErrInstanceTreeNotSupported = errors.New("backend does not support reading workflow instance trees")
+
+// WorkflowTreeNode is a workflow instance in a WorkflowTree, with the sub-workflow instances it started.
+type WorkflowTreeNode struct {
+	*WorkflowInstanceNode
+
+	Children []*WorkflowTreeNode
+}
+
+// WorkflowTree is the ancestry and the descendants of a workflow instance.
+type WorkflowTree struct {
+	// Root is the top-most ancestor of the instance, or the instance itself if it's not a sub-workflow. Ancestors
+	// only contain the child leading to the instance, not their other sub-workflows.
+	Root *WorkflowTreeNode
+
+	// Instance is the node of the requested instance within the tree. Its children contain all descendants.
+	Instance *WorkflowTreeNode
+}
+
+// GetWorkflowTree returns the ancestry of the given instance up to its top-most parent, and all sub-workflow
+// instances it started, directly or indirectly. It returns ErrInstanceTreeNotSupported if the backend doesn't
+// implement InstanceTreeReader.
+func GetWorkflowTree(ctx context.Context, b Backend, instanceID string) (*WorkflowTree, error) {
+	tr, ok := b.(InstanceTreeReader)
+	if !ok {
+		return nil, ErrInstanceTreeNotSupported
+	}
+
+	node, err := tr.GetWorkflowInstanceNode(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Guard against cycles in corrupted parent links
+	seen := map[string]bool{instanceID: true}
+
+	instance := &WorkflowTreeNode{WorkflowInstanceNode: node}
+	if err := addDescendants(ctx, tr, instance, seen); err != nil {
+		return nil, err
+	}
+
+	root := instance
+	for root.Instance.SubWorkflow() {
+		parentID := root.Instance.ParentInstanceID
+		if seen[parentID] {
+			return nil, fmt.Errorf("cycle in workflow instance tree at instance %s", parentID)
+		}
+		seen[parentID] = true
+
+		parent, err := tr.GetWorkflowInstanceNode(ctx, parentID)
+		if err != nil {
+			return nil, fmt.Errorf("getting parent workflow instance %s: %w", parentID, err)
+		}
+
+		root = &WorkflowTreeNode{
+			WorkflowInstanceNode: parent,
+			Children:             []*WorkflowTreeNode{root},
+		}
+	}
+
+	return &WorkflowTree{
+		Root:     root,
+		Instance: instance,
+	}, nil
+}
+
+func addDescendants(ctx context.Context, tr InstanceTreeReader, node *WorkflowTreeNode, seen map[string]bool) error {
+	children, err := tr.GetSubWorkflowInstanceNodes(ctx, node.Instance.InstanceID)
+	if err != nil {
+		return fmt.Errorf("getting sub-workflow instances of %s: %w", node.Instance.InstanceID, err)
+	}
+
+	node.Children = make([]*WorkflowTreeNode, 0, len(children))
+	for _, child := range children {
+		if seen[child.Instance.InstanceID] {
+			return fmt.Errorf("cycle in workflow instance tree at instance %s", child.Instance.InstanceID)
+		}
+		seen[child.Instance.InstanceID] = true
+
+		c := &WorkflowTreeNode{WorkflowInstanceNode: child}
+		if err := addDescendants(ctx, tr, c, seen); err != nil {
+			return err
+		}
+
+		node.Children = append(node.Children, c)
+	}
+
+	return nil
+}
@@ -15,20 +15,28 @@ import (
 	"github.com/cschleiden/go-workflows/internal/fn"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/internal/payload"
 	"github.com/cschleiden/go-workflows/internal/tracing"
+	workflowinternal "github.com/cschleiden/go-workflows/internal/workflow"
 	"github.com/cschleiden/go-workflows/metrics"
 	"github.com/cschleiden/go-workflows/workflow"
+	"github.com/cschleiden/go-workflows/workflowerrors"
 	"github.com/google/uuid"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
-var ErrWorkflowCanceled = errors.New("workflow canceled")
-var ErrWorkflowTerminated = errors.New("workflow terminated")
+var ErrWorkflowCanceled = &workflowerrors.CanceledError{Message: "workflow canceled"}
+var ErrWorkflowTerminated = &workflowerrors.TerminatedError{Message: "workflow terminated"}
+var ErrActivityNotFailed = errors.New("no failed activity with the given schedule event ID")
 
 type WorkflowInstanceOptions struct {
 	InstanceID string
 
+	// ExecutionTimeout limits how long the workflow instance may run. When it elapses, the workflow is
+	// canceled and workflow.Cause reports workflow.ErrTimedOut. Zero means no timeout.
+	ExecutionTimeout time.Duration
+
 	// FUTURE: Expose this to callers of the API. Use it only internally for now.
 	// Metadata *core.WorkflowInstanceMetadata
 }
@@ -36,56 +44,146 @@ type WorkflowInstanceOptions struct {
 type Client interface {
 	CreateWorkflowInstance(ctx context.Context, options WorkflowInstanceOptions, wf workflow.Workflow, args ...interface{}) (*workflow.Instance, error)
 
+	// CreateWorkflowInstances creates all requested workflow instances in a single backend transaction.
+	// If any of them can't be created, for example because an instance with the same ID already exists,
+	// none of them are.
+	CreateWorkflowInstances(ctx context.Context, requests []WorkflowInstanceRequest) ([]*workflow.Instance, error)
+
 	CancelWorkflowInstance(ctx context.Context, instance *workflow.Instance) error
 
+	// CancelWorkflowInstanceWithReason cancels the given instance like CancelWorkflowInstance, and attaches a
+	// reason and optional structured details. The workflow can read them with workflow.CancellationDetails to
+	// decide how to clean up.
+	CancelWorkflowInstanceWithReason(ctx context.Context, instance *workflow.Instance, reason string, details interface{}) error
+
 	WaitForWorkflowInstance(ctx context.Context, instance *workflow.Instance, timeout time.Duration) error
 
 	SignalWorkflow(ctx context.Context, instanceID string, name string, arg interface{}) error
+
+	// RetryActivityNow retries the activity attempt with the given schedule event ID right away, if it failed
+	// and is waiting for its next retry. The activity is retried even if it has exhausted its retry attempts.
+	RetryActivityNow(ctx context.Context, instance *workflow.Instance, scheduleEventID int64) error
+
+	// FailActivityNow stops retrying the activity attempt with the given schedule event ID, if it failed and
+	// is waiting for its next retry. The activity fails with the given reason.
+	FailActivityNow(ctx context.Context, instance *workflow.Instance, scheduleEventID int64, reason string) error
+
+	// RerunWorkflow starts a new instance of the workflow of the given instance, with the same inputs unless
+	// they are overridden in options. The new instance records the ID of the original one in its metadata
+	// under RerunOfMetadataKey.
+	RerunWorkflow(ctx context.Context, instanceID string, options RerunOptions) (*workflow.Instance, error)
+
+	// RemoveWorkflowInstance removes the given finished instance. Removed instances are hidden, but their history
+	// is kept until backend.PurgeRemovedInstances purges it, and they can be restored with UndeleteWorkflowInstance
+	// until then. Returns backend.ErrRemovalNotSupported if the backend doesn't support removing instances.
+	RemoveWorkflowInstance(ctx context.Context, instance *workflow.Instance) error
+
+	// UndeleteWorkflowInstance restores the given removed instance, if it hasn't been purged yet.
+	UndeleteWorkflowInstance(ctx context.Context, instance *workflow.Instance) error
+
+	// PlaceWorkflowInstanceHold places a legal or compliance hold on the given instance. Held instances can't be
+	// removed or archived, and are not purged, until the hold is released. Returns backend.ErrHoldNotSupported if
+	// the backend doesn't support holds.
+	PlaceWorkflowInstanceHold(ctx context.Context, instance *workflow.Instance, reason string) error
+
+	// ReleaseWorkflowInstanceHold releases the hold on the given instance.
+	ReleaseWorkflowInstanceHold(ctx context.Context, instance *workflow.Instance) error
+
+	// GetWorkflowInstanceHold returns the hold on the given instance, or nil if it isn't held.
+	GetWorkflowInstanceHold(ctx context.Context, instance *workflow.Instance) (*backend.Hold, error)
+
+	// HintPrefetch asks workers to prefetch the given instances until the given time, e.g., before a traffic
+	// spike. Only workers with a PrefetchHintInterval act on hints. Returns backend.ErrPrefetchHintsNotSupported
+	// if the backend doesn't support prefetch hints.
+	HintPrefetch(ctx context.Context, until time.Time, instances ...*workflow.Instance) error
+
+	// CompactWorkflowInstanceHistory collapses the activities and timers of the given instance that were resolved
+	// up to the event with the given sequence id into summary events, and returns how many were compacted. Use it
+	// for long-lived instances that can't easily continue as new. Returns backend.ErrCompactionNotSupported if the
+	// backend doesn't support compacting histories.
+	CompactWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, checkpoint int64) (int, error)
+
+	// GetWorkflowTree returns the ancestry of the given instance up to its top-most parent, and all sub-workflow
+	// instances it started, directly or indirectly, with their states. Returns backend.ErrInstanceNotFound if the
+	// instance doesn't exist, and backend.ErrInstanceTreeNotSupported if the backend can't look up sub-workflows.
+	GetWorkflowTree(ctx context.Context, instanceID string) (*backend.WorkflowTree, error)
+
+	// GetWorkflowInstanceHistory returns the history of the given instance restricted by filter. The redactor
+	// configured for the backend with backend.WithRedactor is applied to all payloads.
+	GetWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, filter backend.HistoryFilter) ([]history.Event, error)
 }
 
 type client struct {
-	backend backend.Backend
-	clock   clock.Clock
+	backend      backend.Backend
+	clock        clock.Clock
+	retryOptions RetryOptions
+
+	signalSchemas map[string]SignalSchema
+	interceptors  []Interceptor
+
+	// registry holds the workflows registered with WithWorkflows, registryErr is the first error registering them
+	registry    *workflowinternal.Registry
+	registryErr error
 }
 
-func New(backend backend.Backend) Client {
-	return &client{
-		backend: backend,
-		clock:   clock.New(),
+func New(backend backend.Backend, opts ...ClientOption) Client {
+	options := DefaultOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	c := &client{
+		backend:       backend,
+		clock:         clock.New(),
+		retryOptions:  options.RetryOptions,
+		signalSchemas: options.SignalSchemas,
+		interceptors:  options.Interceptors,
+		registry:      workflowinternal.NewRegistry(),
 	}
+
+	for _, wf := range options.Workflows {
+		if err := c.registry.RegisterWorkflow(wf); err != nil && c.registryErr == nil {
+			c.registryErr = fmt.Errorf("registering workflow: %w", err)
+		}
+	}
+
+	return c
 }
 
 func (c *client) CreateWorkflowInstance(ctx context.Context, options WorkflowInstanceOptions, wf workflow.Workflow, args ...interface{}) (*workflow.Instance, error) {
-	inputs, err := a.ArgsToInputs(converter.DefaultConverter, args...)
-	if err != nil {
-		return nil, fmt.Errorf("converting arguments: %w", err)
+	return c.startWorkflowInstance(ctx, "CreateWorkflowInstance", &StartWorkflowRequest{
+		Options:      options,
+		WorkflowName: fn.Name(wf),
+		Args:         args,
+		Metadata:     workflow.Metadata{},
+	})
+}
+
+// startWorkflowInstance passes the request through the interceptors and creates the instance it describes. operation
+// names the span recorded for the request.
+func (c *client) startWorkflowInstance(ctx context.Context, operation string, req *StartWorkflowRequest) (*workflow.Instance, error) {
+	if err := c.interceptStart(ctx, req); err != nil {
+		return nil, err
 	}
 
-	wfi := core.NewWorkflowInstance(options.InstanceID, uuid.NewString())
-	metadata := &workflow.Metadata{}
+	wfi := core.NewWorkflowInstance(req.Options.InstanceID, uuid.NewString())
 
-	workflowName := fn.Name(wf)
+	workflowName := req.WorkflowName
 
 	// Start new span and add to metadata
-	sctx, span := c.backend.Tracer().Start(ctx, fmt.Sprintf("CreateWorkflowInstance: %s", workflowName), trace.WithAttributes(
+	sctx, span := c.backend.Tracer().Start(ctx, fmt.Sprintf("%s: %s", operation, workflowName), trace.WithAttributes(
 		attribute.String(tracing.WorkflowInstanceID, wfi.InstanceID),
 		attribute.String(tracing.WorkflowName, workflowName),
 	))
 	defer span.End()
 
-	tracing.MarshalSpan(sctx, metadata)
-
-	startedEvent := history.NewPendingEvent(
-		c.clock.Now(),
-		history.EventType_WorkflowExecutionStarted,
-		&history.ExecutionStartedAttributes{
-			Metadata: metadata,
-			Name:     workflowName,
-			Inputs:   inputs,
-		})
+	startedEvent, err := c.newStartedEvent(sctx, workflowName, req.Args, req.Metadata, req.Options.ExecutionTimeout)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := c.backend.CreateWorkflowInstance(ctx, wfi, startedEvent); err != nil {
-		return nil, fmt.Errorf("creating workflow instance: %w", err)
+	if err := c.createWorkflowInstance(ctx, wfi, startedEvent); err != nil {
+		return nil, err
 	}
 
 	c.backend.Logger().Debug("Created workflow instance", "instance_id", wfi.InstanceID, "execution_id", wfi.ExecutionID)
@@ -95,12 +193,188 @@ func (c *client) CreateWorkflowInstance(ctx context.Context, options WorkflowIns
 	return wfi, nil
 }
 
+// WorkflowInstanceRequest describes a single workflow instance to create with CreateWorkflowInstances.
+type WorkflowInstanceRequest struct {
+	Options  WorkflowInstanceOptions
+	Workflow workflow.Workflow
+	Args     []interface{}
+}
+
+func (c *client) CreateWorkflowInstances(ctx context.Context, requests []WorkflowInstanceRequest) ([]*workflow.Instance, error) {
+	sctx, span := c.backend.Tracer().Start(ctx, "CreateWorkflowInstances", trace.WithAttributes(
+		attribute.Int("count", len(requests)),
+	))
+	defer span.End()
+
+	instances := make([]*workflow.Instance, 0, len(requests))
+	events := make([]history.WorkflowEvent, 0, len(requests))
+
+	for _, r := range requests {
+		req := &StartWorkflowRequest{
+			Options:      r.Options,
+			WorkflowName: fn.Name(r.Workflow),
+			Args:         r.Args,
+			Metadata:     workflow.Metadata{},
+		}
+		if err := c.interceptStart(ctx, req); err != nil {
+			return nil, err
+		}
+
+		wfi := core.NewWorkflowInstance(req.Options.InstanceID, uuid.NewString())
+
+		startedEvent, err := c.newStartedEvent(sctx, req.WorkflowName, req.Args, req.Metadata, req.Options.ExecutionTimeout)
+		if err != nil {
+			return nil, err
+		}
+
+		instances = append(instances, wfi)
+		events = append(events, history.WorkflowEvent{WorkflowInstance: wfi, HistoryEvent: startedEvent})
+	}
+
+	err := c.retry(ctx, func(attempt int) error {
+		err := c.backend.CreateWorkflowInstances(ctx, events)
+		if attempt > 1 && errors.Is(err, backend.ErrInstanceAlreadyExists) {
+			// An earlier attempt might have created the instances before failing
+			if created, cerr := c.createdByEarlierAttempt(ctx, instances); cerr != nil {
+				return cerr
+			} else if created {
+				return nil
+			}
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating workflow instances: %w", err)
+	}
+
+	c.backend.Logger().Debug("Created workflow instances", "count", len(instances))
+
+	c.backend.Metrics().Counter(metrickeys.WorkflowInstanceCreated, metrics.Tags{}, int64(len(instances)))
+
+	return instances, nil
+}
+
+// createWorkflowInstance creates the given instance, retrying transient errors. If a retry finds that the instance
+// already exists, it only succeeds if an earlier attempt created it.
+func (c *client) createWorkflowInstance(ctx context.Context, wfi *workflow.Instance, startedEvent history.Event) error {
+	err := c.retry(ctx, func(attempt int) error {
+		err := c.backend.CreateWorkflowInstance(ctx, wfi, startedEvent)
+		if attempt > 1 && errors.Is(err, backend.ErrInstanceAlreadyExists) {
+			// An earlier attempt might have created the instance before failing
+			if created, cerr := c.createdByEarlierAttempt(ctx, []*workflow.Instance{wfi}); cerr != nil {
+				return cerr
+			} else if created {
+				return nil
+			}
+		}
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("creating workflow instance: %w", err)
+	}
+
+	return nil
+}
+
+// createdByEarlierAttempt returns true if all given instances exist with the execution IDs generated for them, i.e.,
+// they have been created by an earlier attempt of the same call and not by someone else. It returns false if the
+// backend can't look up instances, see backend.InstanceTreeReader.
+func (c *client) createdByEarlierAttempt(ctx context.Context, instances []*workflow.Instance) (bool, error) {
+	tr, ok := c.backend.(backend.InstanceTreeReader)
+	if !ok {
+		return false, nil
+	}
+
+	for _, instance := range instances {
+		node, err := tr.GetWorkflowInstanceNode(ctx, instance.InstanceID)
+		if err != nil {
+			if errors.Is(err, backend.ErrInstanceNotFound) {
+				return false, nil
+			}
+
+			return false, fmt.Errorf("checking workflow instance %s: %w", instance.InstanceID, err)
+		}
+
+		if node.Instance.ExecutionID != instance.ExecutionID {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (c *client) newStartedEvent(
+	sctx context.Context, workflowName string, args []interface{}, metadata workflow.Metadata, executionTimeout time.Duration,
+) (history.Event, error) {
+	inputs, err := a.ArgsToInputs(converter.DefaultConverter, args...)
+	if err != nil {
+		return history.Event{}, fmt.Errorf("converting arguments: %w", err)
+	}
+
+	if metadata == nil {
+		metadata = workflow.Metadata{}
+	}
+
+	tracing.MarshalSpan(sctx, &metadata)
+
+	return history.NewPendingEvent(
+		c.clock.Now(),
+		history.EventType_WorkflowExecutionStarted,
+		&history.ExecutionStartedAttributes{
+			Metadata:         &metadata,
+			Name:             workflowName,
+			Inputs:           inputs,
+			ExecutionTimeout: executionTimeout,
+		}), nil
+}
+
 func (c *client) CancelWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
-	cancellationEvent := history.NewWorkflowCancellationEvent(time.Now())
-	return c.backend.CancelWorkflowInstance(ctx, instance, &cancellationEvent)
+	cancellationEvent := history.NewWorkflowCancellationEvent(time.Now(), history.CancellationReason_User)
+	return c.retry(ctx, func(int) error {
+		return c.backend.CancelWorkflowInstance(ctx, instance, &cancellationEvent)
+	})
+}
+
+func (c *client) CancelWorkflowInstanceWithReason(ctx context.Context, instance *workflow.Instance, reason string, details interface{}) error {
+	var detailsPayload payload.Payload
+	if details != nil {
+		var err error
+		detailsPayload, err = converter.DefaultConverter.To(details)
+		if err != nil {
+			return fmt.Errorf("converting cancellation details: %w", err)
+		}
+	}
+
+	cancellationEvent := history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionCanceled, &history.ExecutionCanceledAttributes{
+		Reason:  history.CancellationReason_User,
+		Message: reason,
+		Details: detailsPayload,
+	})
+
+	return c.retry(ctx, func(int) error {
+		return c.backend.CancelWorkflowInstance(ctx, instance, &cancellationEvent)
+	})
 }
 
 func (c *client) SignalWorkflow(ctx context.Context, instanceID string, name string, arg interface{}) error {
+	req := &SignalWorkflowRequest{
+		InstanceID: instanceID,
+		Name:       name,
+		Arg:        arg,
+	}
+	if err := c.interceptSignal(ctx, req); err != nil {
+		return err
+	}
+
+	instanceID, name = req.InstanceID, req.Name
+
+	arg, err := c.normalizeSignalArg(name, req.Arg)
+	if err != nil {
+		return err
+	}
+
 	input, err := converter.DefaultConverter.To(arg)
 	if err != nil {
 		return fmt.Errorf("converting arguments: %w", err)
@@ -115,7 +389,10 @@ func (c *client) SignalWorkflow(ctx context.Context, instanceID string, name str
 		},
 	)
 
-	err = c.backend.SignalWorkflow(ctx, instanceID, signalEvent)
+	// The same event is used for every attempt, so backends can detect duplicates by its ID
+	err = c.retry(ctx, func(int) error {
+		return c.backend.SignalWorkflow(ctx, instanceID, signalEvent)
+	})
 	if err != nil {
 		return err
 	}
@@ -125,6 +402,45 @@ func (c *client) SignalWorkflow(ctx context.Context, instanceID string, name str
 	return nil
 }
 
+func (c *client) RetryActivityNow(ctx context.Context, instance *workflow.Instance, scheduleEventID int64) error {
+	return c.sendActivityOperatorAction(ctx, instance, scheduleEventID, workflow.ActivityOperatorAction{})
+}
+
+func (c *client) FailActivityNow(ctx context.Context, instance *workflow.Instance, scheduleEventID int64, reason string) error {
+	return c.sendActivityOperatorAction(ctx, instance, scheduleEventID, workflow.ActivityOperatorAction{
+		Fail:   true,
+		Reason: reason,
+	})
+}
+
+func (c *client) sendActivityOperatorAction(
+	ctx context.Context, instance *workflow.Instance, scheduleEventID int64, action workflow.ActivityOperatorAction,
+) error {
+	var h []history.Event
+	err := c.retry(ctx, func(int) error {
+		var err error
+		h, err = c.backend.GetWorkflowInstanceHistory(ctx, instance, nil)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("getting workflow history: %w", err)
+	}
+
+	failed := false
+	for i := range h {
+		if h[i].Type == history.EventType_ActivityFailed && h[i].ScheduleEventID == scheduleEventID {
+			failed = true
+			break
+		}
+	}
+
+	if !failed {
+		return ErrActivityNotFailed
+	}
+
+	return c.SignalWorkflow(ctx, instance.InstanceID, workflow.ActivityOperatorSignal(scheduleEventID), action)
+}
+
 func (c *client) WaitForWorkflowInstance(ctx context.Context, instance *workflow.Instance, timeout time.Duration) error {
 	if timeout == 0 {
 		timeout = time.Second * 20
@@ -145,7 +461,12 @@ func (c *client) WaitForWorkflowInstance(ctx context.Context, instance *workflow
 	defer ticker.Stop()
 
 	for range ticker.C {
-		s, err := c.backend.GetWorkflowInstanceState(ctx, instance)
+		var s core.WorkflowInstanceState
+		err := c.retry(ctx, func(int) error {
+			var err error
+			s, err = c.backend.GetWorkflowInstanceState(ctx, instance)
+			return err
+		})
 		if err != nil {
 			return fmt.Errorf("getting workflow state: %w", err)
 		}
@@ -155,7 +476,7 @@ func (c *client) WaitForWorkflowInstance(ctx context.Context, instance *workflow
 		}
 	}
 
-	return errors.New("workflow did not finish in specified timeout")
+	return &workflowerrors.TimeoutError{Message: "workflow did not finish in specified timeout"}
 }
 
 // GetWorkflowResult gets the workflow result for the given workflow result. It first waits for the workflow to finish or until
@@ -168,19 +489,32 @@ func GetWorkflowResult[T any](ctx context.Context, c Client, instance *workflow.
 	ic := c.(*client)
 	b := ic.backend
 
-	h, err := b.GetWorkflowInstanceHistory(ctx, instance, nil)
+	var h []history.Event
+	err := ic.retry(ctx, func(int) error {
+		var err error
+		h, err = b.GetWorkflowInstanceHistory(ctx, instance, nil)
+		return err
+	})
 	if err != nil {
 		return *new(T), fmt.Errorf("getting workflow history: %w", err)
 	}
 
 	// Iterate over history backwards
 	for i := len(h) - 1; i >= 0; i-- {
-		event := h[i]
+		event := &h[i]
 		switch event.Type {
 		case history.EventType_WorkflowExecutionFinished:
-			a := event.Attributes.(*history.ExecutionCompletedAttributes)
+			a, err := history.AttributesAs[*history.ExecutionCompletedAttributes](event)
+			if err != nil {
+				return *new(T), fmt.Errorf("getting workflow result: %w", err)
+			}
+
 			if a.Error != "" {
-				return *new(T), errors.New(a.Error)
+				return *new(T), &workflowerrors.WorkflowError{
+					InstanceID: instance.InstanceID,
+					Type:       a.ErrorType,
+					Message:    a.Error,
+				}
 			}
 
 			var r T
@@ -3,6 +3,9 @@ package client
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,9 +15,13 @@ import (
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/logger"
+	mi "github.com/cschleiden/go-workflows/internal/metrics"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/workflow"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func Test_Client_GetWorkflowResultTimeout(t *testing.T) {
@@ -78,8 +85,8 @@ func Test_Client_SignalWorkflow(t *testing.T) {
 	b := &backend.MockBackend{}
 	b.On("Logger").Return(logger.NewDefaultLogger())
 	b.On("SignalWorkflow", ctx, instanceID, mock.MatchedBy(func(event history.Event) bool {
-		return event.Type == history.EventType_SignalReceived &&
-			event.Attributes.(*history.SignalReceivedAttributes).Name == "test"
+		a, err := history.AttributesAs[*history.SignalReceivedAttributes](&event)
+		return err == nil && event.Type == history.EventType_SignalReceived && a.Name == "test"
 	})).Return(nil)
 
 	c := &client{
@@ -105,9 +112,10 @@ func Test_Client_SignalWorkflow_WithArgs(t *testing.T) {
 	b := &backend.MockBackend{}
 	b.On("Logger").Return(logger.NewDefaultLogger())
 	b.On("SignalWorkflow", ctx, instanceID, mock.MatchedBy(func(event history.Event) bool {
-		return event.Type == history.EventType_SignalReceived &&
-			event.Attributes.(*history.SignalReceivedAttributes).Name == "test" &&
-			bytes.Equal(event.Attributes.(*history.SignalReceivedAttributes).Arg, input)
+		a, err := history.AttributesAs[*history.SignalReceivedAttributes](&event)
+		return err == nil && event.Type == history.EventType_SignalReceived &&
+			a.Name == "test" &&
+			bytes.Equal(a.Arg, input)
 	})).Return(nil)
 
 	c := &client{
@@ -120,3 +128,513 @@ func Test_Client_SignalWorkflow_WithArgs(t *testing.T) {
 	require.Nil(t, err)
 	b.AssertExpectations(t)
 }
+
+func Test_Client_SignalWorkflow_ValidatesSchema(t *testing.T) {
+	type approval struct {
+		Approver string `json:"approver"`
+	}
+
+	instanceID := uuid.NewString()
+
+	ctx := context.Background()
+
+	input, _ := converter.DefaultConverter.To(approval{Approver: "alice"})
+
+	b := &backend.MockBackend{}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("SignalWorkflow", ctx, instanceID, mock.MatchedBy(func(event history.Event) bool {
+		a, err := history.AttributesAs[*history.SignalReceivedAttributes](&event)
+		return err == nil && a.Name == "approve" && bytes.Equal(a.Arg, input)
+	})).Return(nil).Once()
+
+	options := DefaultOptions
+	WithSignalSchema("approve", SignalType[approval]())(&options)
+
+	c := &client{
+		backend:       b,
+		clock:         clock.New(),
+		signalSchemas: options.SignalSchemas,
+	}
+
+	// Values are converted to the registered type
+	err := c.SignalWorkflow(ctx, instanceID, "approve", map[string]interface{}{"approver": "alice"})
+	require.NoError(t, err)
+
+	err = c.SignalWorkflow(ctx, instanceID, "approve", map[string]interface{}{"approver": 42})
+	require.ErrorIs(t, err, ErrInvalidSignalArgument)
+
+	err = c.SignalWorkflow(ctx, instanceID, "approve", map[string]interface{}{"reviewer": "alice"})
+	require.ErrorIs(t, err, ErrInvalidSignalArgument)
+
+	b.AssertExpectations(t)
+}
+
+func Test_SignalJSONSchema(t *testing.T) {
+	schema := SignalJSONSchema(func(data []byte) error {
+		if string(data) != `{"approver":"alice"}` {
+			return errors.New("invalid")
+		}
+
+		return nil
+	})
+
+	v, err := schema.Normalize(map[string]string{"approver": "alice"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"approver": "alice"}, v)
+
+	_, err = schema.Normalize(map[string]string{"approver": "bob"})
+	require.Error(t, err)
+}
+
+func Test_Client_SignalWorkflow_RetriesTransientErrors(t *testing.T) {
+	instanceID := uuid.NewString()
+
+	ctx := context.Background()
+
+	var eventIDs []string
+
+	b := &backend.MockBackend{}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("SignalWorkflow", ctx, instanceID, mock.Anything).Return(errors.New("connection reset")).Twice().Run(func(args mock.Arguments) {
+		eventIDs = append(eventIDs, args.Get(2).(history.Event).ID)
+	})
+	b.On("SignalWorkflow", ctx, instanceID, mock.Anything).Return(nil).Once().Run(func(args mock.Arguments) {
+		eventIDs = append(eventIDs, args.Get(2).(history.Event).ID)
+	})
+
+	c := &client{
+		backend: b,
+		clock:   clock.New(),
+		retryOptions: RetryOptions{
+			MaxAttempts:        3,
+			FirstRetryInterval: time.Millisecond,
+		},
+	}
+
+	err := c.SignalWorkflow(ctx, instanceID, "test", "signal")
+
+	require.NoError(t, err)
+	b.AssertExpectations(t)
+
+	// Every attempt uses the same event
+	require.Len(t, eventIDs, 3)
+	require.Equal(t, eventIDs[0], eventIDs[1])
+	require.Equal(t, eventIDs[0], eventIDs[2])
+}
+
+func Test_Client_CancelWorkflowInstance_DoesNotRetryPermanentErrors(t *testing.T) {
+	instance := core.NewWorkflowInstance(uuid.NewString(), "test")
+
+	ctx := context.Background()
+
+	b := &backend.MockBackend{}
+	b.On("CancelWorkflowInstance", ctx, instance, mock.Anything).Return(backend.ErrInstanceNotFound).Once()
+
+	c := &client{
+		backend: b,
+		clock:   clock.New(),
+		retryOptions: RetryOptions{
+			MaxAttempts:        3,
+			FirstRetryInterval: time.Millisecond,
+		},
+	}
+
+	err := c.CancelWorkflowInstance(ctx, instance)
+
+	require.ErrorIs(t, err, backend.ErrInstanceNotFound)
+	b.AssertExpectations(t)
+}
+
+func Test_Client_CancelWorkflowInstanceWithReason(t *testing.T) {
+	instance := core.NewWorkflowInstance(uuid.NewString(), "test")
+
+	ctx := context.Background()
+
+	details, _ := converter.DefaultConverter.To(map[string]int{"amount": 42})
+
+	b := &backend.MockBackend{}
+	b.On("CancelWorkflowInstance", ctx, instance, mock.MatchedBy(func(event *history.Event) bool {
+		a, err := history.AttributesAs[*history.ExecutionCanceledAttributes](event)
+		return err == nil && a.Reason == history.CancellationReason_User && a.Message == "customer request" &&
+			string(a.Details) == string(details)
+	})).Return(nil)
+
+	c := &client{
+		backend: b,
+		clock:   clock.New(),
+	}
+
+	err := c.CancelWorkflowInstanceWithReason(ctx, instance, "customer request", map[string]int{"amount": 42})
+
+	require.NoError(t, err)
+	b.AssertExpectations(t)
+}
+
+func Test_Client_CreateWorkflowInstances(t *testing.T) {
+	ctx := context.Background()
+
+	wf := func(ctx workflow.Context, i int) error { return nil }
+
+	b := &backend.MockBackend{}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("Tracer").Return(trace.NewNoopTracerProvider().Tracer("test"))
+	b.On("Metrics").Return(mi.NewNoopMetricsClient())
+	b.On("CreateWorkflowInstances", ctx, mock.MatchedBy(func(events []history.WorkflowEvent) bool {
+		return len(events) == 2 &&
+			events[0].WorkflowInstance.InstanceID == "a" &&
+			events[1].WorkflowInstance.InstanceID == "b" &&
+			events[1].HistoryEvent.Type == history.EventType_WorkflowExecutionStarted
+	})).Return(nil).Once()
+
+	c := &client{
+		backend: b,
+		clock:   clock.New(),
+	}
+
+	instances, err := c.CreateWorkflowInstances(ctx, []WorkflowInstanceRequest{
+		{Options: WorkflowInstanceOptions{InstanceID: "a"}, Workflow: wf, Args: []interface{}{1}},
+		{Options: WorkflowInstanceOptions{InstanceID: "b"}, Workflow: wf, Args: []interface{}{2}},
+	})
+
+	require.NoError(t, err)
+	require.Len(t, instances, 2)
+	require.Equal(t, "a", instances[0].InstanceID)
+	require.Equal(t, "b", instances[1].InstanceID)
+	b.AssertExpectations(t)
+}
+
+type instanceLookupBackend struct {
+	*backend.MockBackend
+
+	instances map[string]*workflow.Instance
+}
+
+func (b *instanceLookupBackend) GetWorkflowInstanceNode(ctx context.Context, instanceID string) (*backend.WorkflowInstanceNode, error) {
+	instance, ok := b.instances[instanceID]
+	if !ok {
+		return nil, backend.ErrInstanceNotFound
+	}
+
+	return &backend.WorkflowInstanceNode{Instance: instance}, nil
+}
+
+func (b *instanceLookupBackend) GetSubWorkflowInstanceNodes(ctx context.Context, instanceID string) ([]*backend.WorkflowInstanceNode, error) {
+	return nil, nil
+}
+
+func Test_Client_CreateWorkflowInstances_Retry(t *testing.T) {
+	wf := func(ctx workflow.Context, i int) error { return nil }
+
+	tests := []struct {
+		name string
+
+		// owner returns the instance with the given ID after the first attempt, given the created instance
+		owner   func(created *workflow.Instance) *workflow.Instance
+		wantErr bool
+	}{
+		{
+			name:  "created by earlier attempt",
+			owner: func(created *workflow.Instance) *workflow.Instance { return created },
+		},
+		{
+			name: "created by someone else",
+			owner: func(created *workflow.Instance) *workflow.Instance {
+				if created.InstanceID == "b" {
+					return core.NewWorkflowInstance("b", uuid.NewString())
+				}
+
+				return created
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			b := &instanceLookupBackend{MockBackend: &backend.MockBackend{}, instances: map[string]*workflow.Instance{}}
+			b.On("Logger").Return(logger.NewDefaultLogger())
+			b.On("Tracer").Return(trace.NewNoopTracerProvider().Tracer("test"))
+			b.On("Metrics").Return(mi.NewNoopMetricsClient()).Maybe()
+			b.On("CreateWorkflowInstances", ctx, mock.Anything).Return(errors.New("connection reset")).Once().Run(func(args mock.Arguments) {
+				// The first attempt creates the instances, but fails afterwards
+				for _, e := range args.Get(1).([]history.WorkflowEvent) {
+					b.instances[e.WorkflowInstance.InstanceID] = tt.owner(e.WorkflowInstance)
+				}
+			})
+			b.On("CreateWorkflowInstances", ctx, mock.Anything).Return(backend.ErrInstanceAlreadyExists).Once()
+
+			c := &client{
+				backend: b,
+				clock:   clock.New(),
+				retryOptions: RetryOptions{
+					MaxAttempts:        2,
+					FirstRetryInterval: time.Millisecond,
+				},
+			}
+
+			instances, err := c.CreateWorkflowInstances(ctx, []WorkflowInstanceRequest{
+				{Options: WorkflowInstanceOptions{InstanceID: "a"}, Workflow: wf, Args: []interface{}{1}},
+				{Options: WorkflowInstanceOptions{InstanceID: "b"}, Workflow: wf, Args: []interface{}{2}},
+			})
+
+			if tt.wantErr {
+				require.ErrorIs(t, err, backend.ErrInstanceAlreadyExists)
+			} else {
+				require.NoError(t, err)
+				require.Len(t, instances, 2)
+			}
+
+			b.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_Client_CreateWorkflowInstance_Retry(t *testing.T) {
+	wf := func(ctx workflow.Context, i int) error { return nil }
+
+	tests := []struct {
+		name string
+
+		// owner returns the instance with the given ID after the first attempt, given the created instance
+		owner   func(created *workflow.Instance) *workflow.Instance
+		wantErr bool
+	}{
+		{
+			name:  "created by earlier attempt",
+			owner: func(created *workflow.Instance) *workflow.Instance { return created },
+		},
+		{
+			name: "created by someone else",
+			owner: func(created *workflow.Instance) *workflow.Instance {
+				return core.NewWorkflowInstance(created.InstanceID, uuid.NewString())
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			b := &instanceLookupBackend{MockBackend: &backend.MockBackend{}, instances: map[string]*workflow.Instance{}}
+			b.On("Logger").Return(logger.NewDefaultLogger())
+			b.On("Tracer").Return(trace.NewNoopTracerProvider().Tracer("test"))
+			b.On("Metrics").Return(mi.NewNoopMetricsClient()).Maybe()
+			b.On("CreateWorkflowInstance", ctx, mock.Anything, mock.Anything).Return(errors.New("connection reset")).Once().Run(func(args mock.Arguments) {
+				// The first attempt creates the instance, but fails afterwards
+				instance := args.Get(1).(*workflow.Instance)
+				b.instances[instance.InstanceID] = tt.owner(instance)
+			})
+			b.On("CreateWorkflowInstance", ctx, mock.Anything, mock.Anything).Return(backend.ErrInstanceAlreadyExists).Once()
+
+			c := &client{
+				backend: b,
+				clock:   clock.New(),
+				retryOptions: RetryOptions{
+					MaxAttempts:        2,
+					FirstRetryInterval: time.Millisecond,
+				},
+			}
+
+			instance, err := c.CreateWorkflowInstance(ctx, WorkflowInstanceOptions{InstanceID: "a"}, wf, 1)
+
+			if tt.wantErr {
+				require.ErrorIs(t, err, backend.ErrInstanceAlreadyExists)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, "a", instance.InstanceID)
+			}
+
+			b.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_Client_RerunWorkflow(t *testing.T) {
+	ctx := context.Background()
+
+	input, _ := converter.DefaultConverter.To(1)
+	override, _ := converter.DefaultConverter.To(2)
+
+	b := &backend.MockBackend{}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("Tracer").Return(trace.NewNoopTracerProvider().Tracer("test"))
+	b.On("Metrics").Return(mi.NewNoopMetricsClient())
+	b.On("GetWorkflowInstanceHistory", ctx, mock.MatchedBy(func(i *core.WorkflowInstance) bool {
+		return i.InstanceID == "original"
+	}), (*int64)(nil)).Return([]history.Event{
+		history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+			Name:   "wf",
+			Inputs: []payload.Payload{input},
+		}),
+	}, nil)
+	b.On("CreateWorkflowInstance", ctx, mock.MatchedBy(func(i *core.WorkflowInstance) bool {
+		return i.InstanceID == "rerun"
+	}), mock.MatchedBy(func(e history.Event) bool {
+		a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&e)
+		return err == nil && a.Name == "wf" && bytes.Equal(a.Inputs[0], override) && a.Metadata.Get(RerunOfMetadataKey) == "original"
+	})).Return(nil).Once()
+
+	c := &client{
+		backend: b,
+		clock:   clock.New(),
+	}
+
+	instance, err := c.RerunWorkflow(ctx, "original", RerunOptions{InstanceID: "rerun", Args: []interface{}{2}})
+	require.NoError(t, err)
+	require.Equal(t, "rerun", instance.InstanceID)
+	b.AssertExpectations(t)
+}
+
+type auditInterceptor struct {
+	InterceptorBase
+}
+
+var errMissingPrefix = errors.New("instance IDs must start with team-")
+
+func (auditInterceptor) OnStart(ctx context.Context, req *StartWorkflowRequest) error {
+	if !strings.HasPrefix(req.Options.InstanceID, "team-") {
+		return errMissingPrefix
+	}
+
+	req.Metadata.Set("created_by", "alice")
+
+	return nil
+}
+
+func (auditInterceptor) OnSignal(ctx context.Context, req *SignalWorkflowRequest) error {
+	req.Arg = fmt.Sprintf("%v (audited)", req.Arg)
+
+	return nil
+}
+
+func Test_Client_Interceptors(t *testing.T) {
+	ctx := context.Background()
+
+	wf := func(ctx workflow.Context) error { return nil }
+
+	input, _ := converter.DefaultConverter.To("approved (audited)")
+
+	b := &backend.MockBackend{}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("Tracer").Return(trace.NewNoopTracerProvider().Tracer("test"))
+	b.On("Metrics").Return(mi.NewNoopMetricsClient())
+	b.On("CreateWorkflowInstance", ctx, mock.MatchedBy(func(i *core.WorkflowInstance) bool {
+		return i.InstanceID == "team-1"
+	}), mock.MatchedBy(func(e history.Event) bool {
+		a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&e)
+		return err == nil && a.Metadata.Get("created_by") == "alice"
+	})).Return(nil).Once()
+	b.On("SignalWorkflow", ctx, "team-1", mock.MatchedBy(func(event history.Event) bool {
+		a, err := history.AttributesAs[*history.SignalReceivedAttributes](&event)
+		return err == nil && bytes.Equal(a.Arg, input)
+	})).Return(nil).Once()
+
+	options := DefaultOptions
+	WithInterceptors(auditInterceptor{})(&options)
+
+	c := &client{
+		backend:      b,
+		clock:        clock.New(),
+		interceptors: options.Interceptors,
+	}
+
+	_, err := c.CreateWorkflowInstance(ctx, WorkflowInstanceOptions{InstanceID: "other"}, wf)
+	require.ErrorIs(t, err, errMissingPrefix)
+
+	_, err = c.CreateWorkflowInstance(ctx, WorkflowInstanceOptions{InstanceID: "team-1"}, wf)
+	require.NoError(t, err)
+
+	err = c.SignalWorkflow(ctx, "team-1", "approval", "approved")
+	require.NoError(t, err)
+
+	b.AssertExpectations(t)
+}
+
+func Test_Client_RerunWorkflow_Interceptors(t *testing.T) {
+	ctx := context.Background()
+
+	input, _ := converter.DefaultConverter.To(map[string]int{"a": 1})
+
+	b := &backend.MockBackend{}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("Tracer").Return(trace.NewNoopTracerProvider().Tracer("test"))
+	b.On("Metrics").Return(mi.NewNoopMetricsClient())
+	b.On("GetWorkflowInstanceHistory", ctx, mock.Anything, (*int64)(nil)).Return([]history.Event{
+		history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+			Name:   "wf",
+			Inputs: []payload.Payload{input},
+		}),
+	}, nil)
+	b.On("CreateWorkflowInstance", ctx, mock.MatchedBy(func(i *core.WorkflowInstance) bool {
+		return i.InstanceID == "team-rerun"
+	}), mock.MatchedBy(func(e history.Event) bool {
+		a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&e)
+		return err == nil && bytes.Equal(a.Inputs[0], input) &&
+			a.Metadata.Get("created_by") == "alice" && a.Metadata.Get(RerunOfMetadataKey) == "team-1"
+	})).Return(nil).Once()
+
+	options := DefaultOptions
+	WithInterceptors(auditInterceptor{})(&options)
+
+	c := &client{
+		backend:      b,
+		clock:        clock.New(),
+		interceptors: options.Interceptors,
+	}
+
+	// Reruns go through the same interceptors as new instances
+	_, err := c.RerunWorkflow(ctx, "team-1", RerunOptions{InstanceID: "other"})
+	require.ErrorIs(t, err, errMissingPrefix)
+
+	_, err = c.RerunWorkflow(ctx, "team-1", RerunOptions{InstanceID: "team-rerun"})
+	require.NoError(t, err)
+
+	b.AssertExpectations(t)
+}
+
+func Test_IsTransientError(t *testing.T) {
+	require.True(t, IsTransientError(errors.New("connection reset")))
+	require.False(t, IsTransientError(context.Canceled))
+	require.False(t, IsTransientError(fmt.Errorf("creating instance: %w", backend.ErrInstanceAlreadyExists)))
+	require.False(t, IsTransientError(backend.NewPermanentError(errors.New("constraint violation"))))
+}
+
+type redactingBackend struct {
+	*backend.MockBackend
+}
+
+func (b *redactingBackend) Redactor() backend.Redactor {
+	return func(data []byte) []byte {
+		return []byte(`"redacted"`)
+	}
+}
+
+func Test_Client_GetWorkflowInstanceHistory_RedactsPayloads(t *testing.T) {
+	instance := core.NewWorkflowInstance(uuid.NewString(), "test")
+
+	input, _ := converter.DefaultConverter.To("secret")
+
+	b := &redactingBackend{&backend.MockBackend{}}
+	b.On("GetWorkflowInstanceHistory", mock.Anything, instance, (*int64)(nil)).Return([]history.Event{
+		history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+			Inputs: []payload.Payload{input},
+		}),
+	}, nil)
+
+	c := &client{
+		backend: b,
+		clock:   clock.New(),
+	}
+
+	h, err := c.GetWorkflowInstanceHistory(context.Background(), instance, backend.HistoryFilter{})
+	require.NoError(t, err)
+	require.Len(t, h, 1)
+
+	a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&h[0])
+	require.NoError(t, err)
+	require.Equal(t, []payload.Payload{payload.Payload(`"redacted"`)}, a.Inputs)
+	b.AssertExpectations(t)
+}
@@ -0,0 +1,25 @@
+package client
+
+import (
+	"context"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+func (c *client) CompactWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, checkpoint int64) (int, error) {
+	hc, ok := c.backend.(backend.HistoryCompactor)
+	if !ok {
+		return 0, backend.ErrCompactionNotSupported
+	}
+
+	var compacted int
+	err := c.retry(ctx, func(int) error {
+		n, err := hc.CompactWorkflowInstanceHistory(ctx, instance, checkpoint)
+		compacted += n
+
+		return err
+	})
+
+	return compacted, err
+}
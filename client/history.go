@@ -0,0 +1,21 @@
+package client
+
+import (
+	"context"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+func (c *client) GetWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, filter backend.HistoryFilter) ([]history.Event, error) {
+	var h []history.Event
+	err := c.retry(ctx, func(int) error {
+		var err error
+		h, err = backend.GetFilteredWorkflowInstanceHistory(ctx, c.backend, instance, filter)
+
+		return err
+	})
+
+	return h, err
+}
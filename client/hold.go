@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+func (c *client) PlaceWorkflowInstanceHold(ctx context.Context, instance *workflow.Instance, reason string) error {
+	h, ok := c.backend.(backend.InstanceHolder)
+	if !ok {
+		return backend.ErrHoldNotSupported
+	}
+
+	err := c.retry(ctx, func(int) error {
+		return h.PlaceWorkflowInstanceHold(ctx, instance, reason)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.backend.Logger().Debug("Placed hold on workflow instance", "instance_id", instance.InstanceID, "execution_id", instance.ExecutionID, "reason", reason)
+
+	return nil
+}
+
+func (c *client) ReleaseWorkflowInstanceHold(ctx context.Context, instance *workflow.Instance) error {
+	h, ok := c.backend.(backend.InstanceHolder)
+	if !ok {
+		return backend.ErrHoldNotSupported
+	}
+
+	err := c.retry(ctx, func(attempt int) error {
+		err := h.ReleaseWorkflowInstanceHold(ctx, instance)
+		if attempt > 1 && errors.Is(err, backend.ErrInstanceNotHeld) {
+			// An earlier attempt might have released the hold before failing
+			return nil
+		}
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	c.backend.Logger().Debug("Released hold on workflow instance", "instance_id", instance.InstanceID, "execution_id", instance.ExecutionID)
+
+	return nil
+}
+
+func (c *client) GetWorkflowInstanceHold(ctx context.Context, instance *workflow.Instance) (*backend.Hold, error) {
+	h, ok := c.backend.(backend.InstanceHolder)
+	if !ok {
+		return nil, backend.ErrHoldNotSupported
+	}
+
+	var hold *backend.Hold
+	err := c.retry(ctx, func(int) error {
+		var err error
+		hold, err = h.GetWorkflowInstanceHold(ctx, instance)
+		return err
+	})
+
+	return hold, err
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+// StartWorkflowRequest describes a workflow instance that's about to be created.
+type StartWorkflowRequest struct {
+	Options WorkflowInstanceOptions
+
+	WorkflowName string
+
+	Args []interface{}
+
+	// Metadata is recorded with the instance. The client sets the keys traceparent, tracestate, and
+	// baggage for tracing after all interceptors ran.
+	Metadata workflow.Metadata
+}
+
+// SignalWorkflowRequest describes a signal that's about to be sent to a workflow instance.
+type SignalWorkflowRequest struct {
+	InstanceID string
+
+	Name string
+
+	Arg interface{}
+}
+
+// Interceptor inspects and rewrites requests of the client before they are sent to the backend, e.g.,
+// to enforce naming conventions or to add audit metadata to every started instance. Returning an error
+// rejects the request.
+type Interceptor interface {
+	// OnStart is called for every instance created with CreateWorkflowInstance, CreateWorkflowInstances, or
+	// RerunWorkflow. For reruns that don't override the inputs, Args holds the recorded inputs as json.RawMessage.
+	OnStart(ctx context.Context, req *StartWorkflowRequest) error
+
+	// OnSignal is called for every signal sent with SignalWorkflow.
+	OnSignal(ctx context.Context, req *SignalWorkflowRequest) error
+}
+
+// InterceptorBase implements Interceptor without changing any requests. Embed it to implement only some
+// of the methods.
+type InterceptorBase struct{}
+
+var _ Interceptor = InterceptorBase{}
+
+func (InterceptorBase) OnStart(ctx context.Context, req *StartWorkflowRequest) error {
+	return nil
+}
+
+func (InterceptorBase) OnSignal(ctx context.Context, req *SignalWorkflowRequest) error {
+	return nil
+}
+
+// interceptStart passes the request through all interceptors, in the order they were configured.
+func (c *client) interceptStart(ctx context.Context, req *StartWorkflowRequest) error {
+	for _, i := range c.interceptors {
+		if err := i.OnStart(ctx, req); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// interceptSignal passes the request through all interceptors, in the order they were configured.
+func (c *client) interceptSignal(ctx context.Context, req *SignalWorkflowRequest) error {
+	for _, i := range c.interceptors {
+		if err := i.OnSignal(ctx, req); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package client
+
+import "github.com/cschleiden/go-workflows/workflow"
+
+type Options struct {
+	// RetryOptions configure retries of backend operations for all client methods
+	RetryOptions RetryOptions
+
+	// SignalSchemas validate signal arguments before they are sent, keyed by signal name
+	SignalSchemas map[string]SignalSchema
+
+	// Interceptors inspect and rewrite requests before they are sent to the backend, in the given order
+	Interceptors []Interceptor
+
+	// Workflows are the workflows whose instances can be queried with QueryWorkflow
+	Workflows []workflow.Workflow
+}
+
+var DefaultOptions = Options{
+	RetryOptions: DefaultRetryOptions,
+}
+
+type ClientOption func(*Options)
+
+func WithRetryOptions(retryOptions RetryOptions) ClientOption {
+	return func(o *Options) {
+		o.RetryOptions = retryOptions
+	}
+}
+
+// WithSignalSchema validates arguments of the signal with the given name before SignalWorkflow sends it.
+func WithSignalSchema(name string, schema SignalSchema) ClientOption {
+	return func(o *Options) {
+		schemas := make(map[string]SignalSchema, len(o.SignalSchemas)+1)
+		for n, s := range o.SignalSchemas {
+			schemas[n] = s
+		}
+
+		schemas[name] = schema
+		o.SignalSchemas = schemas
+	}
+}
+
+// WithInterceptors adds the given interceptors, see Interceptor.
+func WithInterceptors(interceptors ...Interceptor) ClientOption {
+	return func(o *Options) {
+		o.Interceptors = append(append([]Interceptor{}, o.Interceptors...), interceptors...)
+	}
+}
+
+// WithWorkflows registers the given workflows with the client, so that QueryWorkflow can replay their instances.
+func WithWorkflows(workflows ...workflow.Workflow) ClientOption {
+	return func(o *Options) {
+		o.Workflows = append(append([]workflow.Workflow{}, o.Workflows...), workflows...)
+	}
+}
@@ -0,0 +1,20 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+func (c *client) HintPrefetch(ctx context.Context, until time.Time, instances ...*workflow.Instance) error {
+	h, ok := c.backend.(backend.PrefetchHinter)
+	if !ok {
+		return backend.ErrPrefetchHintsNotSupported
+	}
+
+	return c.retry(ctx, func(int) error {
+		return h.AddPrefetchHints(ctx, instances, until)
+	})
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/backend"
+	a "github.com/cschleiden/go-workflows/internal/args"
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/core"
+	workflowinternal "github.com/cschleiden/go-workflows/internal/workflow"
+)
+
+// ErrQueryHandlerNotFound is returned by QueryWorkflow if the workflow hasn't set a query handler with the
+// requested name.
+var ErrQueryHandlerNotFound = workflowinternal.ErrQueryHandlerNotFound
+
+// QueryWorkflow calls the query handler with the given name of the given instance, see workflow.SetQueryHandler,
+// and returns its result. The history of the instance is replayed by the client, so its workflow has to be
+// registered with WithWorkflows. Running and finished instances can be queried, queries don't change them. The
+// redactor configured for the backend with backend.WithRedactor is applied to the result before it's decoded.
+func QueryWorkflow[TResult any](ctx context.Context, c Client, instanceID, name string, args ...interface{}) (TResult, error) {
+	ic := c.(*client)
+	if ic.registryErr != nil {
+		return *new(TResult), ic.registryErr
+	}
+
+	inputs, err := a.ArgsToInputs(converter.DefaultConverter, args...)
+	if err != nil {
+		return *new(TResult), fmt.Errorf("converting query arguments: %w", err)
+	}
+
+	b := ic.backend
+	instance := core.NewWorkflowInstance(instanceID, "")
+
+	result, err := workflowinternal.ExecuteQuery(ctx, b.Logger(), b.Tracer(), b.Metrics(), ic.registry, b, instance, name, inputs)
+	if err != nil {
+		return *new(TResult), fmt.Errorf("querying workflow instance: %w", err)
+	}
+
+	// Query results are returned to callers outside of the workflow like histories, redact them the same way
+	if redactor := backend.RedactorOf(b); redactor != nil {
+		result = redactor(result)
+	}
+
+	var r TResult
+	if err := converter.DefaultConverter.From(result, &r); err != nil {
+		return *new(TResult), fmt.Errorf("converting query result: %w", err)
+	}
+
+	return r, nil
+}
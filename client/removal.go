@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+func (c *client) RemoveWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	r, ok := c.backend.(backend.InstanceRemover)
+	if !ok {
+		return backend.ErrRemovalNotSupported
+	}
+
+	err := c.retry(ctx, func(attempt int) error {
+		err := r.RemoveWorkflowInstance(ctx, instance)
+		if attempt > 1 && errors.Is(err, backend.ErrInstanceNotFound) {
+			// An earlier attempt might have removed the instance before failing
+			return nil
+		}
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	c.backend.Logger().Debug("Removed workflow instance", "instance_id", instance.InstanceID, "execution_id", instance.ExecutionID)
+
+	return nil
+}
+
+func (c *client) UndeleteWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
+	r, ok := c.backend.(backend.InstanceRemover)
+	if !ok {
+		return backend.ErrRemovalNotSupported
+	}
+
+	err := c.retry(ctx, func(attempt int) error {
+		err := r.UndeleteWorkflowInstance(ctx, instance)
+		if attempt > 1 && errors.Is(err, backend.ErrInstanceNotFound) {
+			// An earlier attempt might have restored the instance before failing
+			return nil
+		}
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	c.backend.Logger().Debug("Restored workflow instance", "instance_id", instance.InstanceID, "execution_id", instance.ExecutionID)
+
+	return nil
+}
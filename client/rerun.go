@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/workflow"
+	"github.com/google/uuid"
+)
+
+// RerunOfMetadataKey is the metadata key under which a rerun instance records the ID of the original instance.
+const RerunOfMetadataKey = "rerun_of"
+
+// RerunOptions configure the instance started by RerunWorkflow.
+type RerunOptions struct {
+	// InstanceID of the new instance. If empty, a random ID is used.
+	InstanceID string
+
+	// Args override the inputs of the original instance, if set.
+	Args []interface{}
+}
+
+func (c *client) RerunWorkflow(ctx context.Context, instanceID string, options RerunOptions) (*workflow.Instance, error) {
+	var h []history.Event
+	err := c.retry(ctx, func(int) error {
+		var err error
+		h, err = c.backend.GetWorkflowInstanceHistory(ctx, core.NewWorkflowInstance(instanceID, ""), nil)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting workflow history: %w", err)
+	}
+
+	var started *history.ExecutionStartedAttributes
+	for i := range h {
+		if h[i].Type == history.EventType_WorkflowExecutionStarted {
+			started, err = history.AttributesAs[*history.ExecutionStartedAttributes](&h[i])
+			if err != nil {
+				return nil, fmt.Errorf("getting workflow inputs: %w", err)
+			}
+
+			break
+		}
+	}
+
+	if started == nil {
+		return nil, backend.ErrInstanceNotFound
+	}
+
+	args := options.Args
+	if args == nil {
+		// Pass the recorded inputs through unchanged, interceptors see them as raw JSON
+		args = make([]interface{}, len(started.Inputs))
+		for i, input := range started.Inputs {
+			args[i] = json.RawMessage(input)
+		}
+	}
+
+	if options.InstanceID == "" {
+		options.InstanceID = uuid.NewString()
+	}
+
+	metadata := workflow.Metadata{}
+	metadata.Set(RerunOfMetadataKey, instanceID)
+
+	wfi, err := c.startWorkflowInstance(ctx, "RerunWorkflow", &StartWorkflowRequest{
+		Options: WorkflowInstanceOptions{
+			InstanceID:       options.InstanceID,
+			ExecutionTimeout: started.ExecutionTimeout,
+		},
+		WorkflowName: started.Name,
+		Args:         args,
+		Metadata:     metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.backend.Logger().Debug("Reran workflow instance", "instance_id", wfi.InstanceID, "rerun_of", instanceID)
+
+	return wfi, nil
+}
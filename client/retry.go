@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+)
+
+type RetryOptions struct {
+	// Maximum number of attempts for each backend operation. If <= 1, operations are not retried.
+	MaxAttempts int
+
+	// Time to wait before the first retry
+	FirstRetryInterval time.Duration
+
+	// Maximum delay for any individual retry attempt
+	MaxRetryInterval time.Duration
+
+	// Coefficient for calculating the next retry delay
+	BackoffCoefficient float64
+
+	// Jitter randomizes each retry delay by up to the given fraction, e.g., 0.2 for +/- 20%
+	Jitter float64
+
+	// IsTransient decides whether a failed operation is retried. Defaults to IsTransientError.
+	IsTransient func(err error) bool
+}
+
+// DefaultRetryOptions don't retry any operation.
+var DefaultRetryOptions = RetryOptions{
+	MaxAttempts:        1,
+	FirstRetryInterval: 50 * time.Millisecond,
+	MaxRetryInterval:   5 * time.Second,
+	BackoffCoefficient: 2,
+	Jitter:             0.2,
+}
+
+// IsTransientError returns true if the given error returned by a backend might go away when the
+// operation is retried. Canceled contexts, errors marked with backend.PermanentError, and the well-known
+// backend errors are permanent, everything else is considered transient.
+func IsTransientError(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, backend.ErrInstanceNotFound),
+		errors.Is(err, backend.ErrInstanceAlreadyExists),
+		errors.Is(err, backend.ErrInstanceNotFinished),
+		backend.IsPermanentError(err):
+		return false
+	}
+
+	return true
+}
+
+// retry calls op until it succeeds, fails with a permanent error, or the maximum number of attempts
+// is reached. The attempt number starting at 1 is passed to op.
+func (c *client) retry(ctx context.Context, op func(attempt int) error) error {
+	ro := c.retryOptions
+
+	isTransient := ro.IsTransient
+	if isTransient == nil {
+		isTransient = IsTransientError
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := op(attempt)
+		if err == nil || attempt >= ro.MaxAttempts || !isTransient(err) {
+			return err
+		}
+
+		c.backend.Logger().Warn("Retrying client operation after transient error", "attempt", attempt, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-c.clock.After(retryDelay(ro, attempt)):
+		}
+	}
+}
+
+func retryDelay(ro RetryOptions, attempt int) time.Duration {
+	delay := float64(ro.FirstRetryInterval) * math.Pow(math.Max(ro.BackoffCoefficient, 1), float64(attempt-1))
+	if ro.MaxRetryInterval > 0 {
+		delay = math.Min(delay, float64(ro.MaxRetryInterval))
+	}
+
+	if ro.Jitter > 0 {
+		delay += delay * ro.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidSignalArgument = errors.New("invalid signal argument")
+
+// SignalSchema validates the argument of a signal before it's sent to a workflow instance.
+type SignalSchema interface {
+	// Normalize validates arg and returns the value to send.
+	Normalize(arg interface{}) (interface{}, error)
+}
+
+type signalType[T any] struct{}
+
+// SignalType returns a schema for signals received as T. Arguments of type T are sent as-is. Other values,
+// e.g., a map[string]interface{}, are converted to T via their JSON representation, and rejected if they
+// have fields T doesn't have or values of the wrong type.
+func SignalType[T any]() SignalSchema {
+	return signalType[T]{}
+}
+
+func (signalType[T]) Normalize(arg interface{}) (interface{}, error) {
+	if v, ok := arg.(T); ok {
+		return v, nil
+	}
+
+	data, err := json.Marshal(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	var v T
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("expected %T: %w", v, err)
+	}
+
+	return v, nil
+}
+
+type signalJSONSchema struct {
+	validate func(data []byte) error
+}
+
+// SignalJSONSchema returns a schema validating the JSON representation of arguments with validate, e.g., using a
+// compiled JSON Schema.
+func SignalJSONSchema(validate func(data []byte) error) SignalSchema {
+	return &signalJSONSchema{
+		validate: validate,
+	}
+}
+
+func (s *signalJSONSchema) Normalize(arg interface{}) (interface{}, error) {
+	data, err := json.Marshal(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.validate(data); err != nil {
+		return nil, err
+	}
+
+	return arg, nil
+}
+
+func (c *client) normalizeSignalArg(name string, arg interface{}) (interface{}, error) {
+	schema, ok := c.signalSchemas[name]
+	if !ok {
+		return arg, nil
+	}
+
+	arg, err := schema.Normalize(arg)
+	if err != nil {
+		return nil, fmt.Errorf("%w for signal %s: %v", ErrInvalidSignalArgument, name, err)
+	}
+
+	return arg, nil
+}
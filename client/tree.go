@@ -0,0 +1,19 @@
+package client
+
+import (
+	"context"
+
+	"github.com/cschleiden/go-workflows/backend"
+)
+
+func (c *client) GetWorkflowTree(ctx context.Context, instanceID string) (*backend.WorkflowTree, error) {
+	var tree *backend.WorkflowTree
+	err := c.retry(ctx, func(int) error {
+		var err error
+		tree, err = backend.GetWorkflowTree(ctx, c.backend, instanceID)
+
+		return err
+	})
+
+	return tree, err
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cschleiden/go-workflows/backend"
+)
+
+func runBackup(args []string) error {
+	if len(args) == 0 {
+		backupUsage(os.Stderr)
+		return errors.New("missing backup command")
+	}
+
+	switch args[0] {
+	case "dump":
+		return runBackupDump(args[1:])
+
+	case "restore":
+		return runBackupRestore(args[1:])
+
+	case "help", "-h", "-help", "--help":
+		backupUsage(os.Stderr)
+		return flag.ErrHelp
+	}
+
+	backupUsage(os.Stderr)
+	return fmt.Errorf("unknown backup command %q", args[0])
+}
+
+func backupUsage(w io.Writer) {
+	fmt.Fprint(w, `Usage: workflows backup <command> [flags]
+
+Commands:
+  dump       write all workflow instances of a backend to a JSON archive
+  restore    restore the workflow instances of an archive into a backend
+
+Run "workflows backup <command> -h" for the flags of a command.
+`)
+}
+
+func runBackupDump(args []string) error {
+	fs := flag.NewFlagSet("backup dump", flag.ContinueOnError)
+	backendName := fs.String("backend", "sqlite", "backend to use: "+strings.Join(orphansBackends, ", "))
+	db := fs.String("db", "", "path of the SQLite database, or MySQL DSN, e.g., user:password@tcp(localhost:3306)/workflows")
+	out := fs.String("out", "", "path of the archive to write, defaults to stdout")
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: workflows backup dump [flags]")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Writes all workflow instances, including their histories and pending work, to a JSON archive.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return errors.New("unexpected arguments")
+	}
+
+	if *db == "" {
+		fs.Usage()
+		return errors.New("-db is required")
+	}
+
+	b, err := openBackend(*backendName, *db, false)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		return dump(context.Background(), os.Stdout, b)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return fmt.Errorf("creating archive: %w", err)
+	}
+
+	if err := dump(context.Background(), f, b); err != nil {
+		f.Close()
+		os.Remove(*out)
+		return err
+	}
+
+	return f.Close()
+}
+
+func runBackupRestore(args []string) error {
+	fs := flag.NewFlagSet("backup restore", flag.ContinueOnError)
+	backendName := fs.String("backend", "sqlite", "backend to use: "+strings.Join(orphansBackends, ", "))
+	db := fs.String("db", "", "path of the SQLite database, created if it doesn't exist, or MySQL DSN")
+	in := fs.String("in", "", "path of the archive to read, defaults to stdin")
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: workflows backup restore [flags]")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Restores the workflow instances of a JSON archive written by dump. Nothing is restored if one of")
+		fmt.Fprintln(fs.Output(), "the instances already exists.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return errors.New("unexpected arguments")
+	}
+
+	if *db == "" {
+		fs.Usage()
+		return errors.New("-db is required")
+	}
+
+	r := io.Reader(os.Stdin)
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return fmt.Errorf("opening archive: %w", err)
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	b, err := openBackend(*backendName, *db, true)
+	if err != nil {
+		return err
+	}
+
+	return restore(context.Background(), r, b)
+}
+
+// dump writes a backup of all workflow instances of the backend to w.
+func dump(ctx context.Context, w io.Writer, b backend.Backend) error {
+	if err := backend.WriteBackup(ctx, b, w); err != nil {
+		return fmt.Errorf("writing backup: %w", err)
+	}
+
+	return nil
+}
+
+// restore restores the backup read from r into the backend.
+func restore(ctx context.Context, r io.Reader, b backend.Backend) error {
+	if err := backend.RestoreBackup(ctx, b, r); err != nil {
+		if errors.Is(err, backend.ErrInstanceAlreadyExists) {
+			return fmt.Errorf("restoring backup, nothing was restored: %w", err)
+		}
+
+		return fmt.Errorf("restoring backup: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/backend/sqlite"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Backup(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.sqlite")
+	target := filepath.Join(dir, "target.sqlite")
+	archive := filepath.Join(dir, "backup.json")
+
+	b := sqlite.NewSqliteBackend(source)
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+	require.NoError(t, err)
+
+	require.NoError(t, runBackup([]string{"dump", "-db", source, "-out", archive}))
+	require.NoError(t, runBackup([]string{"restore", "-db", target, "-in", archive}))
+
+	task, err := sqlite.NewSqliteBackend(target, backend.WithStickyTimeout(0)).GetWorkflowTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, task)
+	require.Equal(t, wfi.InstanceID, task.WorkflowInstance.InstanceID)
+
+	// Restoring into a database that already contains the instances fails
+	err = runBackup([]string{"restore", "-db", target, "-in", archive})
+	require.ErrorIs(t, err, backend.ErrInstanceAlreadyExists)
+
+	// Mistyped paths are reported instead of creating a new database
+	err = runBackup([]string{"dump", "-db", filepath.Join(dir, "missing.sqlite")})
+	require.Error(t, err)
+	require.NoFileExists(t, filepath.Join(dir, "missing.sqlite"))
+
+	err = runBackup([]string{"restore", "-db", filepath.Join(dir, "other.sqlite"), "-in", filepath.Join(dir, "missing.json")})
+	require.Error(t, err)
+	require.NoFileExists(t, filepath.Join(dir, "other.sqlite"))
+
+	require.Error(t, runBackup([]string{"copy"}))
+}
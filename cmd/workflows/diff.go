@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cschleiden/go-workflows/historydiff"
+	"github.com/cschleiden/go-workflows/internal/history"
+)
+
+// errHistoriesDiffer is returned by diff if the compared histories differ. The differences have been printed
+// already, so the command only exits with a non-zero status.
+var errHistoriesDiffer = errors.New("histories differ")
+
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	ignoreTimestamps := fs.Bool("ignore-timestamps", false, "ignore time values in event attributes")
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: workflows diff [flags] <a.json> <b.json>")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Compares two histories stored as JSON arrays of events, and exits with a non-zero status if they differ.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("expected two history files")
+	}
+
+	var opts []historydiff.Option
+	if *ignoreTimestamps {
+		opts = append(opts, historydiff.WithIgnoreTimestamps())
+	}
+
+	return diff(os.Stdout, fs.Arg(0), fs.Arg(1), opts...)
+}
+
+// diff writes the differences between the histories stored in the given files to w. It returns
+// errHistoriesDiffer if there are any.
+func diff(w io.Writer, pathA, pathB string, opts ...historydiff.Option) error {
+	a, err := readHistory(pathA)
+	if err != nil {
+		return err
+	}
+
+	b, err := readHistory(pathB)
+	if err != nil {
+		return err
+	}
+
+	diffs, err := historydiff.Diff(a, b, opts...)
+	if err != nil {
+		return fmt.Errorf("comparing histories: %w", err)
+	}
+
+	if err := historydiff.Format(w, diffs); err != nil {
+		return err
+	}
+
+	if len(diffs) > 0 {
+		return errHistoriesDiffer
+	}
+
+	return nil
+}
+
+func readHistory(path string) ([]history.Event, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+
+	var h []history.Event
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("parsing history %s: %w", path, err)
+	}
+
+	return h, nil
+}
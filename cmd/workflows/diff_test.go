@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Diff(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name string, h []history.Event) string {
+		data, err := json.Marshal(h)
+		require.NoError(t, err)
+
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+
+		return path
+	}
+
+	now := time.Now()
+	started := history.NewHistoryEvent(1, now, history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{})
+	a := write("a.json", []history.Event{started})
+	b := write("b.json", []history.Event{
+		started,
+		history.NewHistoryEvent(2, now, history.EventType_TimerScheduled, &history.TimerScheduledAttributes{}, history.ScheduleEventID(1)),
+	})
+
+	var out bytes.Buffer
+	require.NoError(t, diff(&out, a, a))
+
+	out.Reset()
+	require.ErrorIs(t, diff(&out, a, b), errHistoriesDiffer)
+	require.Contains(t, out.String(), "TimerScheduled")
+
+	// Missing and malformed files are reported
+	require.Error(t, diff(&out, a, filepath.Join(dir, "missing.json")))
+
+	malformed := filepath.Join(dir, "malformed.json")
+	require.NoError(t, os.WriteFile(malformed, []byte("{"), 0o644))
+	err := diff(&out, malformed, a)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, errHistoriesDiffer)
+
+	require.Error(t, runDiff([]string{a}))
+}
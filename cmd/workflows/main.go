@@ -0,0 +1,87 @@
+// Command workflows is a command line tool for working with go-workflows.
+//
+// Usage:
+//
+//	workflows scaffold [-module path] [-backend sqlite|mysql|redis] [-force] <dir>
+//	workflows orphans [-backend sqlite|mysql] -db path|dsn [-count n] [-delete] [-completed-before duration]
+//	workflows backup dump [-backend sqlite|mysql] -db path|dsn [-out archive]
+//	workflows backup restore [-backend sqlite|mysql] -db path|dsn [-in archive]
+//	workflows diff [-ignore-timestamps] <a.json> <b.json>
+//
+// scaffold generates a runnable project in the given directory: a worker, a sample workflow and activity with a
+// test, the configuration for the chosen backend, and a docker-compose file for the database, if needed.
+//
+// orphans reports the pending events and queued activities of finished workflow instances, which are never
+// processed, and deletes those of instances that finished before the given duration if -delete is set.
+//
+// backup dump writes all workflow instances of a backend to a JSON archive, and backup restore adds the instances
+// of an archive to a backend, see backend.WriteBackup and backend.RestoreBackup.
+//
+// diff compares two histories stored as JSON arrays of events, see package historydiff, and exits with a non-zero
+// status if they differ.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+
+	switch os.Args[1] {
+	case "scaffold":
+		err = runScaffold(os.Args[2:])
+
+	case "orphans":
+		err = runOrphans(os.Args[2:])
+
+	case "backup":
+		err = runBackup(os.Args[2:])
+
+	case "diff":
+		err = runDiff(os.Args[2:])
+
+	case "help", "-h", "-help", "--help":
+		usage()
+		return
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+
+		if errors.Is(err, errHistoriesDiffer) {
+			os.Exit(1)
+		}
+
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `Usage: workflows <command> [arguments]
+
+Commands:
+  scaffold    generate a runnable go-workflows project
+  orphans     report and delete orphaned events of finished instances
+  backup      dump workflow instances to an archive, and restore them
+  diff        compare two workflow histories
+
+Run "workflows <command> -h" for the arguments of a command.
+`)
+}
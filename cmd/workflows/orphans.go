@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/backend/mysql"
+	"github.com/cschleiden/go-workflows/backend/sqlite"
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+var orphansBackends = []string{"sqlite", "mysql"}
+
+type orphansOptions struct {
+	// Count is the number of orphaned events listed
+	Count int
+
+	// Delete deletes orphaned events after reporting them
+	Delete bool
+
+	// CompletedBefore is the time before which instances need to have finished for their events to be deleted
+	CompletedBefore time.Time
+}
+
+func runOrphans(args []string) error {
+	fs := flag.NewFlagSet("orphans", flag.ContinueOnError)
+	backendName := fs.String("backend", "sqlite", "backend to use: "+strings.Join(orphansBackends, ", "))
+	db := fs.String("db", "", "path of the SQLite database, or MySQL DSN, e.g., user:password@tcp(localhost:3306)/workflows")
+	count := fs.Int("count", 20, "number of orphaned events to list")
+	del := fs.Bool("delete", false, "delete orphaned events after reporting them")
+	completedBefore := fs.Duration("completed-before", 24*time.Hour,
+		"only delete orphaned events of instances that finished at least this long ago")
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: workflows orphans [flags]")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Reports pending events and queued activities of finished workflow instances, and optionally deletes them.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return errors.New("unexpected arguments")
+	}
+
+	if *db == "" {
+		fs.Usage()
+		return errors.New("-db is required")
+	}
+
+	b, err := openBackend(*backendName, *db, false)
+	if err != nil {
+		return err
+	}
+
+	c, ok := b.(backend.OrphanedEventCleaner)
+	if !ok {
+		return fmt.Errorf("backend %q does not support orphaned events", *backendName)
+	}
+
+	return orphans(context.Background(), os.Stdout, c, orphansOptions{
+		Count:           *count,
+		Delete:          *del,
+		CompletedBefore: time.Now().Add(-*completedBefore),
+	})
+}
+
+// openBackend connects to the given backend. Backends panic if they can't initialize their database, which is
+// returned as an error instead. A new SQLite database is only created if create is set.
+func openBackend(name, db string, create bool) (b backend.Backend, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("opening %s backend: %v", name, r)
+		}
+	}()
+
+	switch name {
+	case "sqlite":
+		// Don't create a new database for a mistyped path
+		if _, err := os.Stat(db); err != nil && !(create && errors.Is(err, os.ErrNotExist)) {
+			return nil, fmt.Errorf("opening SQLite database: %w", err)
+		}
+
+		return sqlite.NewSqliteBackend(db), nil
+
+	case "mysql":
+		cfg, err := mysqldriver.ParseDSN(db)
+		if err != nil {
+			return nil, fmt.Errorf("parsing MySQL DSN: %w", err)
+		}
+
+		host, p, err := net.SplitHostPort(cfg.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing MySQL address: %w", err)
+		}
+
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("parsing MySQL port: %w", err)
+		}
+
+		return mysql.NewMysqlBackend(host, port, cfg.User, cfg.Passwd, cfg.DBName), nil
+	}
+
+	return nil, fmt.Errorf("unknown backend %q, supported are: %s", name, strings.Join(orphansBackends, ", "))
+}
+
+// orphans writes a report of the orphaned events of the backend to w, and deletes them if requested.
+func orphans(ctx context.Context, w io.Writer, c backend.OrphanedEventCleaner, o orphansOptions) error {
+	report, err := c.OrphanedEvents(ctx, o.Count)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%d orphaned events\n", report.Total())
+
+	if report.Total() > 0 {
+		types := make([]string, 0, len(report.Counts))
+		for t := range report.Counts {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "TYPE\tCOUNT")
+		for _, t := range types {
+			fmt.Fprintf(tw, "%s\t%d\n", t, report.Counts[t])
+		}
+
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "INSTANCE\tEVENT\tTYPE\tCREATED\tINSTANCE COMPLETED")
+		for _, e := range report.Events {
+			completed := "-"
+			if !e.InstanceCompletedAt.IsZero() {
+				completed = e.InstanceCompletedAt.Format(time.RFC3339)
+			}
+
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+				e.InstanceID, e.EventID, e.EventType, e.Timestamp.Format(time.RFC3339), completed)
+		}
+
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if !o.Delete {
+		return nil
+	}
+
+	n, err := c.DeleteOrphanedEvents(ctx, o.CompletedBefore)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nDeleted %d orphaned events of instances that finished before %s\n",
+		n, o.CompletedBefore.Format(time.RFC3339))
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/backend/sqlite"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Orphans(t *testing.T) {
+	ctx := context.Background()
+	b := sqlite.NewInMemoryBackend(backend.WithStickyTimeout(0))
+
+	wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+	err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+	require.NoError(t, err)
+
+	task, err := b.GetWorkflowTask(ctx)
+	require.NoError(t, err)
+
+	events := append(task.NewEvents, history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{}))
+	for i := range events {
+		events[i].SequenceID = int64(i + 2)
+	}
+
+	activityEvents := []history.Event{
+		history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{}, history.ScheduleEventID(1)),
+	}
+
+	err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateFinished, events, activityEvents, []history.Event{}, []history.WorkflowEvent{})
+	require.NoError(t, err)
+
+	err = b.SignalWorkflow(ctx, wfi.InstanceID, history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{Name: "late"}))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, orphans(ctx, &out, b, orphansOptions{Count: 10}))
+	require.Contains(t, out.String(), "2 orphaned events")
+	require.Contains(t, out.String(), "ActivityScheduled")
+	require.Contains(t, out.String(), "SignalReceived")
+	require.Contains(t, out.String(), wfi.InstanceID)
+
+	// Events of instances that finished recently are kept
+	out.Reset()
+	require.NoError(t, orphans(ctx, &out, b, orphansOptions{Count: 10, Delete: true, CompletedBefore: time.Now().Add(-time.Hour)}))
+	require.Contains(t, out.String(), "Deleted 0 orphaned events")
+
+	out.Reset()
+	require.NoError(t, orphans(ctx, &out, b, orphansOptions{Count: 10, Delete: true, CompletedBefore: time.Now().Add(time.Second)}))
+	require.Contains(t, out.String(), "Deleted 2 orphaned events")
+
+	out.Reset()
+	require.NoError(t, orphans(ctx, &out, b, orphansOptions{Count: 10}))
+	require.Equal(t, "0 orphaned events\n", out.String())
+}
+
+func Test_OpenBackend(t *testing.T) {
+	_, err := openBackend("redis", "localhost:6379", false)
+	require.Error(t, err)
+
+	// Missing SQLite databases are not created
+	_, err = openBackend("sqlite", t.TempDir()+"/missing.db", false)
+	require.Error(t, err)
+}
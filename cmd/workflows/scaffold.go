@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var scaffoldBackends = []string{"sqlite", "mysql", "redis"}
+
+type scaffoldOptions struct {
+	// Dir is the directory the project is generated in
+	Dir string
+
+	// Module is the module path of the project, defaults to the name of the directory
+	Module string
+
+	// Backend is the backend the worker uses, one of scaffoldBackends
+	Backend string
+
+	// Force overwrites existing files
+	Force bool
+}
+
+type scaffoldData struct {
+	Module  string
+	Name    string
+	Backend string
+
+	// Version is the version of go-workflows to require, empty if unknown
+	Version string
+}
+
+type scaffoldFile struct {
+	name     string
+	template string
+
+	// backends the file is generated for, all if empty
+	backends []string
+}
+
+var scaffoldFiles = []scaffoldFile{
+	{name: "go.mod", template: "go.mod.tmpl"},
+	{name: "main.go", template: "main.go.tmpl"},
+	{name: "backend.go", template: "backend.go.tmpl"},
+	{name: "workflow.go", template: "workflow.go.tmpl"},
+	{name: "workflow_test.go", template: "workflow_test.go.tmpl"},
+	{name: "docker-compose.yml", template: "docker-compose.yml.tmpl", backends: []string{"mysql", "redis"}},
+	{name: "README.md", template: "README.md.tmpl"},
+}
+
+func runScaffold(args []string) error {
+	fs := flag.NewFlagSet("scaffold", flag.ContinueOnError)
+	module := fs.String("module", "", "module path of the generated project, defaults to the name of the directory")
+	backend := fs.String("backend", "sqlite", "backend to use: "+strings.Join(scaffoldBackends, ", "))
+	force := fs.Bool("force", false, "overwrite existing files")
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: workflows scaffold [flags] <dir>")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Generates a runnable go-workflows project in the given directory.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected exactly one directory")
+	}
+
+	o := scaffoldOptions{
+		Dir:     fs.Arg(0),
+		Module:  *module,
+		Backend: *backend,
+		Force:   *force,
+	}
+
+	files, err := scaffold(o)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		fmt.Println("created", filepath.Join(o.Dir, f))
+	}
+
+	fmt.Printf("\nNext steps:\n\n  cd %s\n", o.Dir)
+	if o.Backend != "sqlite" {
+		fmt.Println("  docker compose up -d")
+	}
+	fmt.Println("  go mod tidy")
+	fmt.Println("  go run . -start")
+
+	return nil
+}
+
+// scaffold generates a project with the given options, and returns the names of the generated files.
+func scaffold(o scaffoldOptions) ([]string, error) {
+	if !contains(scaffoldBackends, o.Backend) {
+		return nil, fmt.Errorf("unknown backend %q, supported are: %s", o.Backend, strings.Join(scaffoldBackends, ", "))
+	}
+
+	dir, err := filepath.Abs(o.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	data := scaffoldData{
+		Module:  o.Module,
+		Name:    filepath.Base(dir),
+		Backend: o.Backend,
+		Version: moduleVersion(),
+	}
+
+	if data.Module == "" {
+		data.Module = data.Name
+	}
+
+	tmpl, err := template.ParseFS(templates, "templates/*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("parsing templates: %w", err)
+	}
+
+	// Render all files before writing any, so that errors don't leave a partial project behind
+	contents := make(map[string][]byte)
+	var files []string
+
+	for _, f := range scaffoldFiles {
+		if len(f.backends) > 0 && !contains(f.backends, o.Backend) {
+			continue
+		}
+
+		if !o.Force {
+			if _, err := os.Stat(filepath.Join(dir, f.name)); err == nil {
+				return nil, fmt.Errorf("%s already exists, use -force to overwrite it", filepath.Join(o.Dir, f.name))
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&buf, f.template, data); err != nil {
+			return nil, fmt.Errorf("rendering %s: %w", f.name, err)
+		}
+
+		content := buf.Bytes()
+		if path.Ext(f.name) == ".go" {
+			if content, err = format.Source(content); err != nil {
+				return nil, fmt.Errorf("formatting %s: %w", f.name, err)
+			}
+		}
+
+		contents[f.name] = content
+		files = append(files, f.name)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating directory: %w", err)
+	}
+
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), contents[name], 0o644); err != nil {
+			return nil, fmt.Errorf("writing %s: %w", name, err)
+		}
+	}
+
+	return files, nil
+}
+
+// moduleVersion returns the version of go-workflows this tool was built from, empty if it's not a release,
+// e.g., when running from a local checkout.
+func moduleVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" || info.Main.Version == "(devel)" {
+		return ""
+	}
+
+	return info.Main.Version
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
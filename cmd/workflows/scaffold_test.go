@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Scaffold(t *testing.T) {
+	for _, backend := range scaffoldBackends {
+		backend := backend
+
+		t.Run(backend, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "orders")
+
+			files, err := scaffold(scaffoldOptions{Dir: dir, Module: "example.com/orders", Backend: backend})
+			require.NoError(t, err)
+			require.Contains(t, files, "main.go")
+			require.Equal(t, backend != "sqlite", contains(files, "docker-compose.yml"))
+
+			for _, f := range files {
+				_, err := os.Stat(filepath.Join(dir, f))
+				require.NoError(t, err)
+			}
+
+			gomod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+			require.NoError(t, err)
+			require.Contains(t, string(gomod), "module example.com/orders")
+
+			b, err := os.ReadFile(filepath.Join(dir, "backend.go"))
+			require.NoError(t, err)
+			require.Contains(t, string(b), "github.com/cschleiden/go-workflows/backend/"+backend)
+
+			// Existing files are only overwritten with Force
+			_, err = scaffold(scaffoldOptions{Dir: dir, Backend: backend})
+			require.Error(t, err)
+
+			_, err = scaffold(scaffoldOptions{Dir: dir, Backend: backend, Force: true})
+			require.NoError(t, err)
+
+			gomod, err = os.ReadFile(filepath.Join(dir, "go.mod"))
+			require.NoError(t, err)
+			require.Contains(t, string(gomod), "module orders")
+		})
+	}
+}
+
+func Test_Scaffold_UnknownBackend(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := scaffold(scaffoldOptions{Dir: dir, Backend: "postgres"})
+	require.Error(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -0,0 +1,22 @@
+package converter
+
+import (
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+type Payload = payload.Payload
+
+// Converter converts workflow and activity inputs and results to and from payloads.
+type Converter = converter.Converter
+
+// NewJSONConverter returns the converter used by default, which encodes values as JSON.
+func NewJSONConverter() Converter {
+	return converter.NewJSONConverter()
+}
+
+// SetDefault replaces the converter used for all payloads. It has to be called before any client or
+// worker is created, and all clients and workers need to use a compatible converter.
+func SetDefault(c Converter) {
+	converter.DefaultConverter = c
+}
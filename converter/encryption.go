@@ -0,0 +1,212 @@
+package converter
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+// EncryptionKeyIDKey is the payload metadata key holding the ID of the key a payload was encrypted with.
+const EncryptionKeyIDKey = "encryption-key-id"
+
+// KeyProvider provides AES keys for encrypting payloads. Keys must be 16, 24, or 32 bytes long.
+type KeyProvider interface {
+	// CurrentKey returns the key new payloads are encrypted with, and its ID.
+	CurrentKey() (string, []byte, error)
+
+	// Key returns the key with the given ID. Keys that were rotated out must still be returned as long as
+	// payloads encrypted with them are stored.
+	Key(id string) ([]byte, error)
+}
+
+type staticKeyProvider struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+// NewStaticKeyProvider returns a KeyProvider for a fixed set of keys, with currentID identifying the key
+// used for new payloads.
+func NewStaticKeyProvider(currentID string, keys map[string][]byte) KeyProvider {
+	return &staticKeyProvider{
+		currentID: currentID,
+		keys:      keys,
+	}
+}
+
+func (kp *staticKeyProvider) CurrentKey() (string, []byte, error) {
+	key, err := kp.Key(kp.currentID)
+	return kp.currentID, key, err
+}
+
+func (kp *staticKeyProvider) Key(id string) ([]byte, error) {
+	key, ok := kp.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", id)
+	}
+
+	return key, nil
+}
+
+type encryptionConverter struct {
+	keys KeyProvider
+	c    Converter
+}
+
+// NewEncryptionConverter returns a converter that encrypts payloads encoded by c with AES-GCM, using the
+// current key of the given provider. The ID of the key is stored in the payload metadata, so keys can be
+// rotated while payloads encrypted with older keys are still stored. Payloads without a key ID are
+// decoded without decryption.
+func NewEncryptionConverter(keys KeyProvider, c Converter) Converter {
+	return &encryptionConverter{
+		keys: keys,
+		c:    c,
+	}
+}
+
+func (ec *encryptionConverter) To(v interface{}) (Payload, error) {
+	data, err := ec.c.To(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return encrypt(ec.keys, data, nil)
+}
+
+func (ec *encryptionConverter) From(p Payload, v interface{}) error {
+	data, _, err := decrypt(ec.keys, p)
+	if err != nil {
+		return err
+	}
+
+	return ec.c.From(data, v)
+}
+
+// ReEncryptPayload decrypts the given payload and encrypts it again with the current key. Payloads
+// already encrypted with the current key, and payloads that are not encrypted, are returned unchanged.
+func ReEncryptPayload(keys KeyProvider, p Payload) (Payload, bool, error) {
+	_, metadata, err := payload.SplitMetadata(p)
+	if err != nil {
+		return nil, false, err
+	}
+
+	keyID := metadata[EncryptionKeyIDKey]
+	if keyID == "" {
+		return p, false, nil
+	}
+
+	currentID, _, err := keys.CurrentKey()
+	if err != nil {
+		return nil, false, fmt.Errorf("getting current encryption key: %w", err)
+	}
+
+	if keyID == currentID {
+		return p, false, nil
+	}
+
+	data, metadata, err := decrypt(keys, p)
+	if err != nil {
+		return nil, false, err
+	}
+
+	np, err := encrypt(keys, data, metadata)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return np, true, nil
+}
+
+// ErrPayloadRewriteNotSupported is returned by ReEncrypt for backends that can't update stored payloads.
+var ErrPayloadRewriteNotSupported = errors.New("backend does not support rewriting payloads")
+
+// ReEncrypt scans all payloads stored in the given backend, and re-encrypts the ones encrypted with an
+// older key with the current key of the given provider. It returns the number of updated events. Once it
+// completed, keys that were rotated out are no longer needed, except for activity tasks the Redis backend
+// had already queued, which keep their payloads until they are executed. Workers can keep running while
+// payloads are re-encrypted, as long as they can still decrypt payloads encrypted with any of the keys.
+// It returns ErrPayloadRewriteNotSupported for backends that don't implement backend.PayloadRewriter.
+func ReEncrypt(ctx context.Context, b backend.Backend, keys KeyProvider) (int, error) {
+	r, ok := b.(backend.PayloadRewriter)
+	if !ok {
+		return 0, ErrPayloadRewriteNotSupported
+	}
+
+	return r.RewritePayloads(ctx, func(p payload.Payload) (payload.Payload, bool, error) {
+		return ReEncryptPayload(keys, p)
+	})
+}
+
+func encrypt(keys KeyProvider, data []byte, metadata map[string]string) (Payload, error) {
+	keyID, key, err := keys.CurrentKey()
+	if err != nil {
+		return nil, fmt.Errorf("getting current encryption key: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+
+	md := make(map[string]string, len(metadata)+1)
+	for k, v := range metadata {
+		md[k] = v
+	}
+	md[EncryptionKeyIDKey] = keyID
+
+	return payload.WithMetadata(gcm.Seal(nonce, nonce, data, nil), md)
+}
+
+func decrypt(keys KeyProvider, p Payload) ([]byte, map[string]string, error) {
+	data, metadata, err := payload.SplitMetadata(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyID := metadata[EncryptionKeyIDKey]
+	if keyID == "" {
+		return data, metadata, nil
+	}
+
+	key, err := keys.Key(keyID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting encryption key: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, nil, errors.New("encrypted payload too short")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decrypting payload with key %s: %w", keyID, err)
+	}
+
+	return plaintext, metadata, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,105 @@
+package converter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/logger"
+	"github.com/cschleiden/go-workflows/internal/metrics"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/sync"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+	"github.com/cschleiden/go-workflows/workflow"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	key1 = bytes.Repeat([]byte{1}, 32)
+	key2 = bytes.Repeat([]byte{2}, 32)
+)
+
+func Test_EncryptionConverter_RoundTrip(t *testing.T) {
+	c := NewEncryptionConverter(NewStaticKeyProvider("k1", map[string][]byte{"k1": key1}), NewJSONConverter())
+
+	p, err := c.To(order{ID: 42})
+	require.NoError(t, err)
+	require.NotContains(t, string(p), "42")
+
+	_, metadata, err := payload.SplitMetadata(p)
+	require.NoError(t, err)
+	require.Equal(t, "k1", metadata[EncryptionKeyIDKey])
+
+	var o order
+	require.NoError(t, c.From(p, &o))
+	require.Equal(t, order{ID: 42}, o)
+}
+
+func Test_EncryptionConverter_DecodesUnencryptedPayloads(t *testing.T) {
+	c := NewEncryptionConverter(NewStaticKeyProvider("k1", map[string][]byte{"k1": key1}), NewJSONConverter())
+
+	var i int
+	require.NoError(t, c.From(Payload(`23`), &i))
+	require.Equal(t, 23, i)
+}
+
+func Test_EncryptionConverter_UnknownKey(t *testing.T) {
+	p, err := NewEncryptionConverter(NewStaticKeyProvider("k1", map[string][]byte{"k1": key1}), NewJSONConverter()).To(42)
+	require.NoError(t, err)
+
+	c := NewEncryptionConverter(NewStaticKeyProvider("k2", map[string][]byte{"k2": key2}), NewJSONConverter())
+
+	var i int
+	require.ErrorContains(t, c.From(p, &i), `unknown encryption key "k1"`)
+}
+
+func Test_ReEncryptPayload(t *testing.T) {
+	old := NewStaticKeyProvider("k1", map[string][]byte{"k1": key1})
+	rotated := NewStaticKeyProvider("k2", map[string][]byte{"k1": key1, "k2": key2})
+
+	p, err := NewEncryptionConverter(old, NewJSONConverter()).To(order{ID: 42})
+	require.NoError(t, err)
+
+	np, changed, err := ReEncryptPayload(rotated, p)
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	_, metadata, err := payload.SplitMetadata(np)
+	require.NoError(t, err)
+	require.Equal(t, "k2", metadata[EncryptionKeyIDKey])
+
+	// Only the new key is required to decode re-encrypted payloads
+	var o order
+	c := NewEncryptionConverter(NewStaticKeyProvider("k2", map[string][]byte{"k2": key2}), NewJSONConverter())
+	require.NoError(t, c.From(np, &o))
+	require.Equal(t, order{ID: 42}, o)
+
+	// Payloads encrypted with the current key are not touched
+	_, changed, err = ReEncryptPayload(rotated, np)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	_, changed, err = ReEncryptPayload(rotated, Payload(`23`))
+	require.NoError(t, err)
+	require.False(t, changed)
+}
+
+func Test_EncryptionConverter_StableWorkflowHash(t *testing.T) {
+	defer SetDefault(NewJSONConverter())
+	SetDefault(NewEncryptionConverter(NewStaticKeyProvider("k1", map[string][]byte{"k1": key1}), NewJSONConverter()))
+
+	hash := func() uint64 {
+		instance := core.NewWorkflowInstance("instance", "execution")
+		wfState := workflowstate.NewWorkflowState(instance, logger.NewDefaultLogger(), metrics.NewNoopMetricsClient(), clock.New())
+		ctx := workflowstate.WithWorkflowState(sync.Background(), wfState)
+
+		h, err := workflow.Hash(ctx, order{ID: 42})
+		require.NoError(t, err)
+
+		return h
+	}
+
+	// Hashes don't depend on the converter
+	require.Equal(t, hash(), hash())
+}
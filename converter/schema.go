@@ -0,0 +1,74 @@
+package converter
+
+import (
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+// SchemaIDKey is the payload metadata key holding the ID of the schema a payload was validated against.
+const SchemaIDKey = "schema-id"
+
+// SchemaRegistry looks up and validates against registered schemas, for example by wrapping a
+// Confluent or Buf schema registry client.
+type SchemaRegistry interface {
+	// SchemaID returns the ID of the schema registered for the type of v. If no schema is registered, an
+	// empty ID is returned and the value is converted without validation.
+	SchemaID(v interface{}) (string, error)
+
+	// Validate checks the encoded data against the schema with the given ID.
+	Validate(schemaID string, data []byte) error
+}
+
+type schemaRegistryConverter struct {
+	registry SchemaRegistry
+	c        Converter
+}
+
+// NewSchemaRegistryConverter returns a converter that validates values against the schemas in the given
+// registry. Payloads are encoded with c, and the ID of the schema is stored in the payload metadata.
+// When decoding, payloads are validated against the schema they were written with, so older payloads
+// keep working while schemas evolve. Payloads without a schema ID are decoded without validation.
+func NewSchemaRegistryConverter(registry SchemaRegistry, c Converter) Converter {
+	return &schemaRegistryConverter{
+		registry: registry,
+		c:        c,
+	}
+}
+
+func (sc *schemaRegistryConverter) To(v interface{}) (Payload, error) {
+	data, err := sc.c.To(v)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaID, err := sc.registry.SchemaID(v)
+	if err != nil {
+		return nil, fmt.Errorf("looking up schema: %w", err)
+	}
+
+	if schemaID == "" {
+		return data, nil
+	}
+
+	if err := sc.registry.Validate(schemaID, data); err != nil {
+		return nil, fmt.Errorf("validating payload against schema %s: %w", schemaID, err)
+	}
+
+	return payload.WithMetadata(data, map[string]string{SchemaIDKey: schemaID})
+}
+
+func (sc *schemaRegistryConverter) From(p Payload, v interface{}) error {
+	data, metadata, err := payload.SplitMetadata(p)
+	if err != nil {
+		return err
+	}
+
+	if schemaID := metadata[SchemaIDKey]; schemaID != "" {
+		if err := sc.registry.Validate(schemaID, data); err != nil {
+			return fmt.Errorf("validating payload against schema %s: %w", schemaID, err)
+		}
+	}
+
+	return sc.c.From(data, v)
+}
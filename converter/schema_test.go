@@ -0,0 +1,68 @@
+package converter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type order struct {
+	ID int `json:"id"`
+}
+
+type testRegistry struct {
+	validated []string
+}
+
+func (r *testRegistry) SchemaID(v interface{}) (string, error) {
+	if _, ok := v.(order); ok {
+		return "order-v1", nil
+	}
+
+	return "", nil
+}
+
+func (r *testRegistry) Validate(schemaID string, data []byte) error {
+	r.validated = append(r.validated, schemaID)
+
+	if string(data) == `{"id":0}` {
+		return errors.New("id is required")
+	}
+
+	return nil
+}
+
+func Test_SchemaRegistryConverter_RoundTrip(t *testing.T) {
+	r := &testRegistry{}
+	c := NewSchemaRegistryConverter(r, NewJSONConverter())
+
+	p, err := c.To(order{ID: 42})
+	require.NoError(t, err)
+
+	var o order
+	require.NoError(t, c.From(p, &o))
+	require.Equal(t, order{ID: 42}, o)
+	require.Equal(t, []string{"order-v1", "order-v1"}, r.validated)
+}
+
+func Test_SchemaRegistryConverter_InvalidValue(t *testing.T) {
+	c := NewSchemaRegistryConverter(&testRegistry{}, NewJSONConverter())
+
+	_, err := c.To(order{})
+	require.ErrorContains(t, err, "id is required")
+}
+
+func Test_SchemaRegistryConverter_WithoutSchema(t *testing.T) {
+	r := &testRegistry{}
+	c := NewSchemaRegistryConverter(r, NewJSONConverter())
+
+	p, err := c.To(23)
+	require.NoError(t, err)
+	require.Equal(t, Payload(`23`), p)
+
+	var i int
+	require.NoError(t, c.From(p, &i))
+	require.Equal(t, 23, i)
+	require.Empty(t, r.validated)
+}
@@ -0,0 +1,135 @@
+// Package devserver runs a backend, a worker, and the diagnostics web UI in a single process. It's meant for
+// local development, samples, and demos, and requires no infrastructure.
+package devserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/backend/sqlite"
+	"github.com/cschleiden/go-workflows/client"
+	"github.com/cschleiden/go-workflows/diag"
+	"github.com/cschleiden/go-workflows/worker"
+)
+
+type Options struct {
+	// Addr is the address the diagnostics web UI and API are served on. Defaults to "localhost:3000". Use
+	// "localhost:0" to pick a random free port.
+	Addr string
+
+	// SQLitePath is the SQLite database file used to store state. If empty, an in-memory database is used
+	// and all state is lost when the process exits.
+	SQLitePath string
+
+	// BackendOptions are passed to the SQLite backend.
+	BackendOptions []backend.BackendOption
+
+	// WorkerOptions are used for the worker. If nil, the default worker options are used.
+	WorkerOptions *worker.Options
+
+	// Workflows and Activities are registered with the worker before it is started.
+	Workflows  []interface{}
+	Activities []interface{}
+}
+
+var DefaultOptions = Options{
+	Addr: "localhost:3000",
+}
+
+type Server struct {
+	Backend backend.Backend
+	Client  client.Client
+	Worker  worker.Worker
+
+	// Addr is the address the diagnostics web UI and API are served on
+	Addr string
+
+	httpServer   *http.Server
+	cancelWorker context.CancelFunc
+}
+
+// Start creates the backend, starts a worker with the given workflows and activities, and serves the diagnostics
+// web UI and API. Call Stop to shut everything down.
+func Start(ctx context.Context, options *Options) (*Server, error) {
+	if options == nil {
+		options = &DefaultOptions
+	}
+
+	addr := options.Addr
+	if addr == "" {
+		addr = DefaultOptions.Addr
+	}
+
+	var b interface {
+		backend.Backend
+		diag.Backend
+	}
+	if options.SQLitePath != "" {
+		b = sqlite.NewSqliteBackend(options.SQLitePath, options.BackendOptions...)
+	} else {
+		b = sqlite.NewInMemoryBackend(options.BackendOptions...)
+	}
+
+	w := worker.New(b, options.WorkerOptions)
+
+	for _, wf := range options.Workflows {
+		if err := w.RegisterWorkflow(wf); err != nil {
+			return nil, fmt.Errorf("registering workflow: %w", err)
+		}
+	}
+
+	for _, a := range options.Activities {
+		if err := w.RegisterActivity(a); err != nil {
+			return nil, fmt.Errorf("registering activity: %w", err)
+		}
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %v: %w", addr, err)
+	}
+
+	workerCtx, cancelWorker := context.WithCancel(ctx)
+	if err := w.Start(workerCtx); err != nil {
+		cancelWorker()
+		l.Close()
+		return nil, fmt.Errorf("starting worker: %w", err)
+	}
+
+	s := &Server{
+		Backend: b,
+		Client:  client.New(b),
+		Worker:  w,
+		Addr:    l.Addr().String(),
+
+		httpServer:   &http.Server{Handler: diag.NewServeMux(b, diag.WithRegistry(w))},
+		cancelWorker: cancelWorker,
+	}
+
+	go func() {
+		if err := s.httpServer.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			b.Logger().Error("serving diagnostics", "error", err)
+		}
+	}()
+
+	return s, nil
+}
+
+// Stop stops serving the diagnostics web UI and waits for the worker to finish any in-progress tasks.
+func (s *Server) Stop(ctx context.Context) error {
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		return fmt.Errorf("stopping http server: %w", err)
+	}
+
+	s.cancelWorker()
+
+	if err := s.Worker.WaitForCompletion(); err != nil {
+		return fmt.Errorf("stopping worker: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package devserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/client"
+	"github.com/cschleiden/go-workflows/diag"
+	"github.com/cschleiden/go-workflows/workflow"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DevServer(t *testing.T) {
+	wf := func(ctx workflow.Context, msg string) (string, error) {
+		return msg + " world", nil
+	}
+
+	ctx := context.Background()
+
+	s, err := Start(ctx, &Options{
+		Addr:      "localhost:0",
+		Workflows: []interface{}{wf},
+	})
+	require.NoError(t, err)
+
+	instance, err := s.Client.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+		InstanceID: uuid.NewString(),
+	}, wf, "hello")
+	require.NoError(t, err)
+
+	r, err := client.GetWorkflowResult[string](ctx, s.Client, instance, time.Second*10)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", r)
+
+	res, err := http.Get("http://" + s.Addr + "/api/" + instance.InstanceID)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, err = http.Get("http://" + s.Addr + "/api/registry")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var registry diag.RegistryInfo
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&registry))
+	require.Len(t, registry.Workflows, 1)
+	require.Equal(t, []string{"string"}, registry.Workflows[0].Params)
+	require.Equal(t, []string{"string"}, registry.Workflows[0].Results)
+
+	require.NoError(t, s.Stop(ctx))
+}
+
+func Test_DevServer_WatchEvents(t *testing.T) {
+	wf := func(ctx workflow.Context) error {
+		workflow.NewSignalChannel[string](ctx, "continue").Receive(ctx)
+		return nil
+	}
+
+	ctx := context.Background()
+
+	s, err := Start(ctx, &Options{
+		Addr:      "localhost:0",
+		Workflows: []interface{}{wf},
+	})
+	require.NoError(t, err)
+
+	instance, err := s.Client.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+		InstanceID: uuid.NewString(),
+	}, wf)
+	require.NoError(t, err)
+
+	res, err := http.Get("http://" + s.Addr + "/api/" + instance.InstanceID + "/events")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	require.NoError(t, s.Client.SignalWorkflow(ctx, instance.InstanceID, "continue", "go"))
+
+	var types []string
+	var finished bool
+	var name string
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: ") && name == "event":
+			var e diag.Event
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e))
+			types = append(types, e.Type)
+		case strings.HasPrefix(line, "data: ") && name == "finished":
+			finished = true
+		}
+	}
+	require.NoError(t, scanner.Err())
+
+	require.True(t, finished)
+	require.Contains(t, types, "WorkflowExecutionStarted")
+	require.Contains(t, types, "SignalReceived")
+	require.Equal(t, "WorkflowExecutionFinished", types[len(types)-1])
+
+	require.NoError(t, s.Stop(ctx))
+}
@@ -100,14 +100,19 @@ function eventColor(event: string): [Color, string] {
     case "TimerScheduled":
     case "TimerFired":
     case "TimerCanceled":
+    case "TimerRescheduled":
       return ["light", "primary"];
 
     case "SignalReceived":
       return ["light", "dark"];
 
     case "SideEffectResult":
+    case "Compacted":
       return ["dark", "secondary"];
 
+    case "MarkerRecorded":
+      return ["light", "success"];
+
     case "WorkflowTaskStarted":
       return ["dark", "light"];
 
@@ -1,10 +1,16 @@
-import { Accordion, Alert, Badge, Card } from "react-bootstrap";
+import { useEffect, useState } from "react";
+import { Accordion, Alert, Badge, Button, Card } from "react-bootstrap";
 import { Link, useParams } from "react-router-dom";
 import {
   ExecutionCompletedAttributes,
   ExecutionStartedAttributes,
   HistoryEvent,
+  sendActivityOperatorAction,
+  watchInstance,
   WorkflowInstanceInfo,
+  WorkflowInstanceRef,
+  WorkflowInstanceTree,
+  WorkflowInstanceTreeNode,
 } from "./client";
 import {
   decodePayload,
@@ -20,20 +26,40 @@ function Instance() {
   let params = useParams();
 
   const instanceId = params.instanceId;
+  const apiUrl = document.location.pathname + "api/" + instanceId;
 
   const {
     isLoading,
-    data: instance,
+    data: fetched,
     error,
-  } = useFetch<WorkflowInstanceInfo>(
-    document.location.pathname + "api/" + instanceId
-  );
+  } = useFetch<WorkflowInstanceInfo>(apiUrl);
+
+  // Events committed after the instance was fetched, streamed while it's active
+  const [liveEvents, setLiveEvents] = useState<HistoryEvent<any>[]>([]);
+  const [finishedRef, setFinishedRef] = useState<WorkflowInstanceRef>();
+
+  useEffect(() => {
+    if (!fetched || fetched.state !== 0) {
+      return;
+    }
+
+    setLiveEvents([]);
+    setFinishedRef(undefined);
+
+    const last = fetched.history[fetched.history.length - 1];
+    return watchInstance(
+      apiUrl,
+      last ? last.sequence_id : 0,
+      (event) => setLiveEvents((events) => [...events, event]),
+      setFinishedRef
+    );
+  }, [apiUrl, fetched]);
 
   if (isLoading) {
     return <div>Loading...</div>;
   }
 
-  if (error || !instance) {
+  if (error || !fetched) {
     return (
       <div>
         <Alert variant="danger">
@@ -43,6 +69,12 @@ function Instance() {
     );
   }
 
+  const instance: WorkflowInstanceInfo = {
+    ...fetched,
+    ...finishedRef,
+    history: [...fetched.history, ...liveEvents],
+  };
+
   const startedEvent = instance.history.find(
     (e) => e.type === "WorkflowExecutionStarted"
   ) as HistoryEvent<ExecutionStartedAttributes>;
@@ -60,6 +92,10 @@ function Instance() {
     wfError = finishedEvent.attributes.error;
   }
 
+  // Map event ids to sequence ids to render causality links
+  const sequenceIDs: { [id: string]: number } = {};
+  instance.history.forEach((e) => (sequenceIDs[e.id] = e.sequence_id));
+
   return (
     <div>
       <div className="d-flex align-items-center">
@@ -123,6 +159,8 @@ function Instance() {
         </Card.Body>
       </Card>
 
+      <InstanceTree apiUrl={apiUrl} />
+
       <h2 className="mt-3">History</h2>
       <Accordion alwaysOpen>
         {instance.history.map((event, idx) => (
@@ -154,12 +192,37 @@ function Instance() {
                     event.schedule_event_id
                   )}
                 </dd>
+                {event.caused_by && (
+                  <>
+                    <dt>Caused By</dt>
+                    <dd>
+                      {event.caused_by}
+                      {sequenceIDs[event.caused_by] !== undefined && (
+                        <span className="text-secondary">
+                          {" "}
+                          (#{sequenceIDs[event.caused_by]})
+                        </span>
+                      )}
+                    </dd>
+                  </>
+                )}
                 {event.visible_at && (
                   <>
                     <dt>Visible At</dt>
                     <dd>{event.visible_at}</dd>
                   </>
                 )}
+                {event.type === "ActivityFailed" && instance.state === 0 && (
+                  <>
+                    <dt>Operator actions</dt>
+                    <dd>
+                      <ActivityOperatorActions
+                        apiUrl={apiUrl}
+                        scheduleEventId={event.schedule_event_id!}
+                      />
+                    </dd>
+                  </>
+                )}
                 <dt>Attributes</dt>
                 <dd>
                   <Payload
@@ -181,4 +244,99 @@ function Instance() {
   );
 }
 
+// Parent and sub-workflow instances, only shown for nested instances
+function InstanceTree(props: { apiUrl: string }) {
+  const { data: tree } = useFetch<WorkflowInstanceTree>(
+    `${props.apiUrl}/tree`
+  );
+
+  if (!tree || tree.root.children.length === 0) {
+    return null;
+  }
+
+  return (
+    <Card className="mt-3">
+      <Card.Header as="h5">Workflow tree</Card.Header>
+      <Card.Body>
+        <ul className="list-unstyled mb-0">
+          <InstanceTreeNode node={tree.root} current={tree.instance_id} />
+        </ul>
+      </Card.Body>
+    </Card>
+  );
+}
+
+function InstanceTreeNode(props: {
+  node: WorkflowInstanceTreeNode;
+  current: string;
+}) {
+  const { node, current } = props;
+  const instanceId = node.instance.instance_id;
+
+  return (
+    <li>
+      {instanceId === current ? (
+        <b>{instanceId}</b>
+      ) : (
+        <Link to={`/${instanceId}`}>{instanceId}</Link>
+      )}{" "}
+      {node.state === 0 ? (
+        <Badge bg="info">Active</Badge>
+      ) : (
+        <Badge bg="success">Completed</Badge>
+      )}
+      {node.children.length > 0 && (
+        <ul className="ms-3" style={{ listStyleType: "none" }}>
+          {node.children.map((child) => (
+            <InstanceTreeNode
+              key={child.instance.instance_id}
+              node={child}
+              current={current}
+            />
+          ))}
+        </ul>
+      )}
+    </li>
+  );
+}
+
+// Actions for a failed activity waiting for its next retry
+function ActivityOperatorActions(props: {
+  apiUrl: string;
+  scheduleEventId: number;
+}) {
+  const { apiUrl, scheduleEventId } = props;
+
+  const send = async (action: "retry" | "fail", reason?: string) => {
+    try {
+      await sendActivityOperatorAction(apiUrl, scheduleEventId, action, {
+        reason,
+      });
+      window.location.reload();
+    } catch (e) {
+      window.alert(`Could not ${action} activity: ${e}`);
+    }
+  };
+
+  return (
+    <div className="d-flex gap-2">
+      <Button size="sm" variant="primary" onClick={() => send("retry")}>
+        Retry now
+      </Button>
+      <Button
+        size="sm"
+        variant="danger"
+        onClick={() => {
+          const reason = window.prompt("Reason for failing the activity");
+          if (reason !== null) {
+            send("fail", reason);
+          }
+        }}
+      >
+        Fail now
+      </Button>
+    </div>
+  );
+}
+
 export default Instance;
@@ -17,6 +17,15 @@ export type WorkflowInstanceInfo = WorkflowInstanceRef & {
   history: HistoryEvent<any>[];
 };
 
+export type WorkflowInstanceTreeNode = WorkflowInstanceRef & {
+  children: WorkflowInstanceTreeNode[];
+};
+
+export interface WorkflowInstanceTree {
+  instance_id: string;
+  root: WorkflowInstanceTreeNode;
+}
+
 export interface HistoryEvent<TAttributes> {
   id: string;
   sequence_id: number;
@@ -25,6 +34,7 @@ export interface HistoryEvent<TAttributes> {
   schedule_event_id?: number;
   attributes: TAttributes;
   visible_at?: string;
+  caused_by?: string;
 }
 
 export interface ExecutionStartedAttributes {
@@ -36,3 +46,57 @@ export interface ExecutionCompletedAttributes {
   result: string;
   error: string;
 }
+
+export interface TimerRef {
+  instance_id: string;
+  schedule_event_id: number;
+  scheduled_at: string;
+  fire_at: string;
+  skew_ms: number;
+}
+
+export interface ActivityOperatorRequest {
+  reason?: string;
+}
+
+// Retry a failed activity waiting for its next retry right away, or fail it
+export async function sendActivityOperatorAction(
+  apiUrl: string,
+  scheduleEventId: number,
+  action: "retry" | "fail",
+  request: ActivityOperatorRequest = {}
+): Promise<void> {
+  const response = await fetch(
+    `${apiUrl}/activities/${scheduleEventId}/${action}`,
+    {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify(request),
+    }
+  );
+
+  if (!response.ok) {
+    throw new Error(`Request failed with status ${response.status}`);
+  }
+}
+
+// Subscribe to new history events of an instance, after the given sequence id. Returns a function that closes
+// the subscription.
+export function watchInstance(
+  apiUrl: string,
+  afterSequenceId: number,
+  onEvent: (event: HistoryEvent<any>) => void,
+  onFinished: (instance: WorkflowInstanceRef) => void
+): () => void {
+  const source = new EventSource(`${apiUrl}/events?after=${afterSequenceId}`);
+
+  source.addEventListener("event", (e) =>
+    onEvent(JSON.parse((e as MessageEvent).data))
+  );
+  source.addEventListener("finished", (e) => {
+    source.close();
+    onFinished(JSON.parse((e as MessageEvent).data));
+  });
+
+  return () => source.close();
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/cschleiden/go-workflows/backend"
 	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/workflow"
 )
 
 // json: serialization in this file needs to be kept in sync with client.ts in the web app
@@ -25,6 +26,7 @@ type Event struct {
 	ScheduleEventID int64       `json:"schedule_event_id,omitempty"`
 	Attributes      interface{} `json:"attributes,omitempty"`
 	VisibleAt       *time.Time  `json:"visible_at,omitempty"`
+	CausedBy        string      `json:"caused_by,omitempty"`
 }
 
 type WorkflowInstanceInfo struct {
@@ -33,6 +35,35 @@ type WorkflowInstanceInfo struct {
 	History []*Event `json:"history,omitempty"`
 }
 
+// WorkflowInstanceTreeNode is a workflow instance in a tree of nested workflow instances.
+type WorkflowInstanceTreeNode struct {
+	*WorkflowInstanceRef
+
+	Children []*WorkflowInstanceTreeNode `json:"children"`
+}
+
+// WorkflowInstanceTree is the ancestry and the descendants of the workflow instance with InstanceID.
+type WorkflowInstanceTree struct {
+	InstanceID string                    `json:"instance_id"`
+	Root       *WorkflowInstanceTreeNode `json:"root"`
+}
+
+// TimerRef is a timer that has not been delivered to its workflow instance yet.
+type TimerRef struct {
+	InstanceID      string    `json:"instance_id"`
+	ScheduleEventID int64     `json:"schedule_event_id"`
+	ScheduledAt     time.Time `json:"scheduled_at"`
+	FireAt          time.Time `json:"fire_at"`
+
+	// SkewMs is how far the timer is behind schedule in milliseconds, 0 if it's not due yet.
+	SkewMs int64 `json:"skew_ms"`
+}
+
+type RegistryInfo struct {
+	Workflows  []workflow.FunctionInfo `json:"workflows"`
+	Activities []workflow.FunctionInfo `json:"activities"`
+}
+
 type Backend interface {
 	backend.Backend
 
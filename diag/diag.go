@@ -3,30 +3,114 @@ package diag
 import (
 	"embed"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/fs"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
+
+	wfbackend "github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/client"
+	h "github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/worker"
+	"github.com/cschleiden/go-workflows/internal/workflow"
 )
 
 //go:embed app/build
 var embeddedFiles embed.FS
 
+// Redactor is applied to every payload (workflow and activity inputs and results, signal arguments, ...) before
+// it is returned by the diagnostics API or rendered in the web app. It's applied in addition to the redactor
+// configured for the backend with backend.WithRedactor, which also covers histories read by other APIs.
+type Redactor = wfbackend.Redactor
+
+// Registry provides information about registered workflows and activities, e.g., a worker.Worker.
+type Registry interface {
+	RegisteredWorkflows() []workflow.FunctionInfo
+	RegisteredActivities() []workflow.FunctionInfo
+}
+
+// InFlightTasks provides the tasks currently executed by a worker, e.g., a worker.Worker.
+type InFlightTasks interface {
+	InFlightTasks() []worker.InFlightTask
+}
+
+type options struct {
+	redactor      Redactor
+	registry      Registry
+	inFlight      InFlightTasks
+	watchInterval time.Duration
+}
+
+type Option func(*options)
+
+// WithRedactor sets a redactor which is applied to all payloads before they are returned, in addition to the
+// redactor of the backend.
+func WithRedactor(r Redactor) Option {
+	return func(o *options) {
+		o.redactor = r
+	}
+}
+
+// WithRegistry serves the workflows and activities of the given registry at /api/registry.
+func WithRegistry(r Registry) Option {
+	return func(o *options) {
+		o.registry = r
+	}
+}
+
+// WithInFlightTasks serves the tasks currently executed by the given worker at /api/inflight.
+func WithInFlightTasks(w InFlightTasks) Option {
+	return func(o *options) {
+		o.inFlight = w
+	}
+}
+
+// WithWatchInterval sets how often the event stream at /api/{instanceID}/events polls the backend for new
+// events. Defaults to DefaultWatchInterval.
+func WithWatchInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.watchInterval = interval
+	}
+}
+
 // NewServeMux returns an *http.ServeMux that serves the diagnostics web app at / and the diagnostics API at /api which is
 // used by the web app.
-func NewServeMux(backend Backend) *http.ServeMux {
+func NewServeMux(backend Backend, opts ...Option) *http.ServeMux {
+	options := &options{
+		watchInterval: DefaultWatchInterval,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
 	mux := http.NewServeMux()
 
 	// API
 	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
-		// Only support GET requests
+		relativeURL := strings.TrimPrefix(r.URL.Path, "/api/")
+		segments := strings.Split(relativeURL, "/")
+
+		// /api/{instanceID}/activities/{scheduleEventID}/{retry|fail}
+		if len(segments) == 4 && segments[1] == "activities" {
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			handleActivityOperatorAction(w, r, backend, segments[0], segments[2], segments[3])
+			return
+		}
+
+		// Only support GET requests otherwise
 		if r.Method != http.MethodGet {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
 
-		relativeURL := strings.TrimPrefix(r.URL.Path, "/api/")
-
 		// /api/
 		if relativeURL == "" {
 			// Index
@@ -58,7 +142,17 @@ func NewServeMux(backend Backend) *http.ServeMux {
 			return
 		}
 
-		segments := strings.Split(relativeURL, "/")
+		// /api/{instanceID}/tree
+		if len(segments) == 2 && segments[1] == "tree" {
+			handleTree(w, r, backend, segments[0])
+			return
+		}
+
+		// /api/{instanceID}/events
+		if len(segments) == 2 && segments[1] == "events" {
+			handleWatch(w, r, backend, options, segments[0])
+			return
+		}
 
 		// /api/{instanceID}
 		if len(segments) == 1 {
@@ -75,7 +169,13 @@ func NewServeMux(backend Backend) *http.ServeMux {
 				return
 			}
 
-			history, err := backend.GetWorkflowInstanceHistory(r.Context(), instance.Instance, nil)
+			filter, err := parseHistoryFilter(r.URL.Query())
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			history, err := wfbackend.GetFilteredWorkflowInstanceHistory(r.Context(), backend, instance.Instance, filter)
 			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				return
@@ -83,15 +183,13 @@ func NewServeMux(backend Backend) *http.ServeMux {
 
 			newHistory := make([]*Event, 0)
 			for _, event := range history {
-				newHistory = append(newHistory, &Event{
-					ID:              event.ID,
-					SequenceID:      event.SequenceID,
-					Type:            event.Type.String(),
-					Timestamp:       event.Timestamp,
-					ScheduleEventID: event.ScheduleEventID,
-					Attributes:      event.Attributes,
-					VisibleAt:       event.VisibleAt,
-				})
+				e, err := toEvent(options, event)
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				newHistory = append(newHistory, e)
 			}
 
 			result := &WorkflowInstanceInfo{
@@ -109,12 +207,258 @@ func NewServeMux(backend Backend) *http.ServeMux {
 		}
 	})
 
+	// /api/registry
+	mux.HandleFunc("/api/registry", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if options.registry == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		result := &RegistryInfo{
+			Workflows:  options.registry.RegisteredWorkflows(),
+			Activities: options.registry.RegisteredActivities(),
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	})
+
+	// /api/inflight
+	mux.HandleFunc("/api/inflight", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if options.inFlight == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(options.inFlight.InFlightTasks()); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	})
+
+	// /api/timers
+	mux.HandleFunc("/api/timers", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		l, ok := backend.(wfbackend.TimerLister)
+		if !ok {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+
+		count := 100
+		if countStr := r.URL.Query().Get("count"); countStr != "" {
+			var err error
+			count, err = strconv.Atoi(countStr)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		timers, err := l.PendingTimers(r.Context(), count)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		result := make([]*TimerRef, 0, len(timers))
+		for _, t := range timers {
+			result = append(result, &TimerRef{
+				InstanceID:      t.InstanceID,
+				ScheduleEventID: t.ScheduleEventID,
+				ScheduledAt:     t.ScheduledAt,
+				FireAt:          t.FireAt,
+				SkewMs:          t.Skew(now).Milliseconds(),
+			})
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	})
+
 	// App
 	mux.Handle("/", http.FileServer(getFileSystem()))
 
 	return mux
 }
 
+// toEvent converts a history event for the diagnostics API, redacting its payloads.
+func toEvent(options *options, event h.Event) (*Event, error) {
+	if options.redactor != nil {
+		event = options.redactor.RedactEvent(event)
+	}
+
+	attributes, err := event.Attributes()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Event{
+		ID:              event.ID,
+		SequenceID:      event.SequenceID,
+		Type:            event.Type.String(),
+		Timestamp:       event.Timestamp,
+		ScheduleEventID: event.ScheduleEventID,
+		Attributes:      attributes,
+		VisibleAt:       event.VisibleAt,
+		CausedBy:        event.CausedBy,
+	}, nil
+}
+
+// parseHistoryFilter reads the optional history filter from the query parameters of a request:
+//
+//   - types: comma separated event types, e.g., "ActivityScheduled,ActivityCompleted"
+//   - from, to: inclusive range of sequence ids
+//   - payloads: "false" to strip payloads from the returned events
+func parseHistoryFilter(query url.Values) (wfbackend.HistoryFilter, error) {
+	filter := wfbackend.HistoryFilter{}
+
+	if types := query.Get("types"); types != "" {
+		for _, name := range strings.Split(types, ",") {
+			et, ok := h.ParseEventType(name)
+			if !ok {
+				return filter, fmt.Errorf("unknown event type: %v", name)
+			}
+
+			filter.EventTypes = append(filter.EventTypes, et)
+		}
+	}
+
+	var err error
+	if from := query.Get("from"); from != "" {
+		if filter.FromSequenceID, err = strconv.ParseInt(from, 10, 64); err != nil {
+			return filter, fmt.Errorf("parsing from: %w", err)
+		}
+	}
+
+	if to := query.Get("to"); to != "" {
+		if filter.ToSequenceID, err = strconv.ParseInt(to, 10, 64); err != nil {
+			return filter, fmt.Errorf("parsing to: %w", err)
+		}
+	}
+
+	filter.ExcludePayloads = query.Get("payloads") == "false"
+
+	return filter, nil
+}
+
+// ActivityOperatorRequest is the optional body of requests to retry or fail an activity.
+type ActivityOperatorRequest struct {
+	// Reason is the error a failed activity fails with.
+	Reason string `json:"reason,omitempty"`
+}
+
+// handleActivityOperatorAction retries a failed activity waiting for its next retry right away, or fails it.
+func handleActivityOperatorAction(w http.ResponseWriter, r *http.Request, backend Backend, instanceID, scheduleEventIDStr, action string) {
+	scheduleEventID, err := strconv.ParseInt(scheduleEventIDStr, 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var req ActivityOperatorRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	instance, err := backend.GetWorkflowInstance(r.Context(), instanceID)
+	if err != nil || instance == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	c := client.New(backend)
+
+	switch action {
+	case "retry":
+		err = c.RetryActivityNow(r.Context(), instance.Instance, scheduleEventID)
+	case "fail":
+		err = c.FailActivityNow(r.Context(), instance.Instance, scheduleEventID, req.Reason)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, client.ErrActivityNotFailed) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleTree(w http.ResponseWriter, r *http.Request, backend Backend, instanceID string) {
+	tree, err := wfbackend.GetWorkflowTree(r.Context(), backend, instanceID)
+	if err != nil {
+		switch {
+		case errors.Is(err, wfbackend.ErrInstanceTreeNotSupported):
+			w.WriteHeader(http.StatusNotImplemented)
+		case errors.Is(err, wfbackend.ErrInstanceNotFound):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	result := &WorkflowInstanceTree{
+		InstanceID: instanceID,
+		Root:       toTreeNode(tree.Root),
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func toTreeNode(node *wfbackend.WorkflowTreeNode) *WorkflowInstanceTreeNode {
+	children := make([]*WorkflowInstanceTreeNode, 0, len(node.Children))
+	for _, child := range node.Children {
+		children = append(children, toTreeNode(child))
+	}
+
+	return &WorkflowInstanceTreeNode{
+		WorkflowInstanceRef: &WorkflowInstanceRef{
+			Instance:    node.Instance,
+			CreatedAt:   node.CreatedAt,
+			CompletedAt: node.CompletedAt,
+			State:       node.State,
+		},
+		Children: children,
+	}
+}
+
 func getFileSystem() http.FileSystem {
 	// Get the build subdirectory as the
 	// root directory so that it can be passed
@@ -0,0 +1,117 @@
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	wfbackend "github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+)
+
+// DefaultWatchInterval is how often the event stream of an instance polls the backend for new events.
+const DefaultWatchInterval = time.Second
+
+// handleWatch streams new history events of the given instance as server-sent events, until the instance has
+// finished or the client disconnects. Each event is sent as an `event` message with its sequence id as message
+// id, so clients can resume after the last received event using the Last-Event-ID header. Streaming starts
+// after the sequence id given in the `after` query parameter, or from the beginning of the history. Once all
+// events of a finished instance have been sent, a `finished` message with the WorkflowInstanceRef is sent.
+func handleWatch(w http.ResponseWriter, r *http.Request, backend Backend, options *options, instanceID string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	after := r.Header.Get("Last-Event-ID")
+	if after == "" {
+		after = r.URL.Query().Get("after")
+	}
+
+	var lastSequenceID int64
+	if after != "" {
+		var err error
+		if lastSequenceID, err = strconv.ParseInt(after, 10, 64); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	instance, err := backend.GetWorkflowInstance(ctx, instanceID)
+	if err != nil || instance == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(options.watchInterval)
+	defer ticker.Stop()
+
+	for {
+		// Read the state before the history, so that all events are sent once the instance is seen as finished
+		ref, err := backend.GetWorkflowInstance(ctx, instanceID)
+		if err != nil || ref == nil {
+			return
+		}
+
+		events, err := wfbackend.GetFilteredWorkflowInstanceHistory(ctx, backend, instance.Instance, wfbackend.HistoryFilter{
+			FromSequenceID: lastSequenceID + 1,
+		})
+		if err != nil {
+			return
+		}
+
+		for _, event := range events {
+			e, err := toEvent(options, event)
+			if err != nil {
+				return
+			}
+
+			if err := writeServerSentEvent(w, strconv.FormatInt(event.SequenceID, 10), "event", e); err != nil {
+				return
+			}
+
+			lastSequenceID = event.SequenceID
+		}
+
+		if ref.State == core.WorkflowInstanceStateFinished {
+			writeServerSentEvent(w, "", "finished", ref)
+			flusher.Flush()
+			return
+		}
+
+		flusher.Flush()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func writeServerSentEvent(w http.ResponseWriter, id, name string, data interface{}) error {
+	d, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, d)
+	return err
+}
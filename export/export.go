@@ -0,0 +1,294 @@
+// Package export continuously exports the history events of workflow instances to object storage, e.g., for
+// analytics pipelines. Events are exported in the order they were committed, including the events of running
+// instances and of executions that were continued as new, and are written as files partitioned by date and hour of
+// the event timestamp. Delivery is at-least-once: progress is checkpointed only after files have been written, so
+// events might be exported again after a crash, and consumers should deduplicate them by their event ID.
+//
+// Backends need to implement backend.CommittedEventLister, the SQLite and MySQL backends do. The Redis backend
+// doesn't, exporting from it returns backend.ErrCommittedEventsNotSupported. See archive.NewS3Store for a store
+// writing to S3 or other S3 compatible object storage, like Google Cloud Storage.
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cschleiden/go-workflows/archive"
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/historyfeed"
+	"github.com/google/uuid"
+)
+
+// Record is an exported history event.
+type Record struct {
+	InstanceID  string `json:"instance_id"`
+	ExecutionID string `json:"execution_id"`
+
+	EventID         string      `json:"event_id"`
+	SequenceID      int64       `json:"sequence_id"`
+	Type            string      `json:"type"`
+	Timestamp       time.Time   `json:"timestamp"`
+	ScheduleEventID int64       `json:"schedule_event_id,omitempty"`
+	Attributes      interface{} `json:"attributes,omitempty"`
+}
+
+// Encoder writes records to an exported file. Records are written as JSON lines by default, other formats like
+// Parquet can be supported by implementing an Encoder.
+type Encoder interface {
+	// Extension is the file extension of exported files, e.g., "jsonl".
+	Extension() string
+
+	Encode(w io.Writer, records []*Record) error
+}
+
+type jsonlEncoder struct{}
+
+func (jsonlEncoder) Extension() string {
+	return "jsonl"
+}
+
+func (jsonlEncoder) Encode(w io.Writer, records []*Record) error {
+	e := json.NewEncoder(w)
+	for _, r := range records {
+		if err := e.Encode(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type options struct {
+	prefix      string
+	encoder     Encoder
+	batchSize   int
+	settleDelay time.Duration
+}
+
+type Option func(*options)
+
+// WithPrefix sets the prefix of all keys written to the store, e.g., "history/".
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = prefix
+	}
+}
+
+// WithEncoder sets the format of exported files. Defaults to JSON lines.
+func WithEncoder(e Encoder) Option {
+	return func(o *options) {
+		o.encoder = e
+	}
+}
+
+// WithBatchSize sets the number of events exported and checkpointed together. Defaults to 1000.
+func WithBatchSize(n int) Option {
+	return func(o *options) {
+		o.batchSize = n
+	}
+}
+
+// WithSettleDelay sets how long ago events need to have been committed to be exported, so that events committed
+// concurrently are not skipped. Defaults to one minute.
+func WithSettleDelay(d time.Duration) Option {
+	return func(o *options) {
+		o.settleDelay = d
+	}
+}
+
+// checkpointKey is the key of the checkpoint in the store, relative to the prefix.
+const checkpointKey = "_checkpoint.json"
+
+type checkpoint struct {
+	// Cursor is the position after the last exported event, nil if nothing has been exported yet.
+	Cursor *backend.EventCursor `json:"cursor,omitempty"`
+}
+
+type Exporter struct {
+	backend backend.Backend
+	store   archive.Store
+	options *options
+}
+
+// NewExporter returns an exporter writing the history events of all instances of the given backend to store. The store also holds the checkpoint of the exporter.
+func NewExporter(backend backend.Backend, store archive.Store, opts ...Option) *Exporter {
+	o := &options{
+		encoder:     jsonlEncoder{},
+		batchSize:   1000,
+		settleDelay: historyfeed.DefaultSettleDelay,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &Exporter{
+		backend: backend,
+		store:   store,
+		options: o,
+	}
+}
+
+// Run exports new events every interval until the given context is canceled. Errors are logged, and the export
+// is retried from the last checkpoint in the next interval. It returns backend.ErrCommittedEventsNotSupported
+// right away if the backend can't list committed events.
+func (e *Exporter) Run(ctx context.Context, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if _, err := e.Export(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			if errors.Is(err, backend.ErrCommittedEventsNotSupported) {
+				return err
+			}
+
+			e.backend.Logger().Error("exporting history events", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// Export writes all events committed since the last checkpoint, and returns the number of exported events. It
+// returns backend.ErrCommittedEventsNotSupported if the backend can't list committed events.
+func (e *Exporter) Export(ctx context.Context) (int, error) {
+	cp, err := e.loadCheckpoint(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	f := &historyfeed.Feed{Backend: e.backend, BatchSize: e.options.batchSize, SettleDelay: e.options.settleDelay}
+
+	exported := 0
+	err = f.Read(ctx, cp.Cursor, func(events []*backend.CommittedEvent, next *backend.EventCursor) error {
+		records := make([]*Record, 0, len(events))
+		for _, ev := range events {
+			r, err := newRecord(ev.Instance, ev.Event)
+			if err != nil {
+				return err
+			}
+
+			records = append(records, r)
+		}
+
+		// Write the files before checkpointing, so that no event is lost
+		if err := e.write(ctx, records); err != nil {
+			return err
+		}
+
+		if err := e.saveCheckpoint(ctx, &checkpoint{Cursor: next}); err != nil {
+			return err
+		}
+
+		exported += len(records)
+
+		return nil
+	})
+
+	return exported, err
+}
+
+func newRecord(instance *core.WorkflowInstance, event *history.Event) (*Record, error) {
+	attributes, err := event.Attributes()
+	if err != nil {
+		return nil, fmt.Errorf("decoding attributes of event %s: %w", event.ID, err)
+	}
+
+	return &Record{
+		InstanceID:      instance.InstanceID,
+		ExecutionID:     instance.ExecutionID,
+		EventID:         event.ID,
+		SequenceID:      event.SequenceID,
+		Type:            event.Type.String(),
+		Timestamp:       event.Timestamp,
+		ScheduleEventID: event.ScheduleEventID,
+		Attributes:      attributes,
+	}, nil
+}
+
+// write stores the given records in one file per partition.
+func (e *Exporter) write(ctx context.Context, records []*Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	partitions := map[string][]*Record{}
+	var keys []string
+	for _, r := range records {
+		p := partition(r.Timestamp)
+		if _, ok := partitions[p]; !ok {
+			keys = append(keys, p)
+		}
+
+		partitions[p] = append(partitions[p], r)
+	}
+
+	batchID := uuid.NewString()
+
+	for _, p := range keys {
+		var buf bytes.Buffer
+		if err := e.options.encoder.Encode(&buf, partitions[p]); err != nil {
+			return fmt.Errorf("encoding records: %w", err)
+		}
+
+		key := fmt.Sprintf("%s%s/%s.%s", e.options.prefix, p, batchID, e.options.encoder.Extension())
+		if err := e.store.Put(ctx, key, buf.Bytes()); err != nil {
+			return fmt.Errorf("writing %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// partition returns the partition for events with the given timestamp, e.g., "date=2022-03-01/hour=13".
+func partition(t time.Time) string {
+	t = t.UTC()
+	return fmt.Sprintf("date=%s/hour=%02d", t.Format("2006-01-02"), t.Hour())
+}
+
+func (e *Exporter) loadCheckpoint(ctx context.Context) (*checkpoint, error) {
+	cp := &checkpoint{}
+
+	data, err := e.store.Get(ctx, e.options.prefix+checkpointKey)
+	if err != nil {
+		if errors.Is(err, archive.ErrNotFound) {
+			return cp, nil
+		}
+
+		return nil, fmt.Errorf("loading checkpoint: %w", err)
+	}
+
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("unmarshaling checkpoint: %w", err)
+	}
+
+	return cp, nil
+}
+
+func (e *Exporter) saveCheckpoint(ctx context.Context, cp *checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("marshaling checkpoint: %w", err)
+	}
+
+	if err := e.store.Put(ctx, e.options.prefix+checkpointKey, data); err != nil {
+		return fmt.Errorf("saving checkpoint: %w", err)
+	}
+
+	return nil
+}
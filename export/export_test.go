@@ -0,0 +1,148 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/archive"
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/historyfeed/historyfeedtest"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingStore struct {
+	archive.Store
+
+	mu   sync.Mutex
+	keys []string
+}
+
+func (s *recordingStore) Put(ctx context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	s.keys = append(s.keys, key)
+	s.mu.Unlock()
+
+	return s.Store.Put(ctx, key, data)
+}
+
+func (s *recordingStore) records(t *testing.T, ctx context.Context) []*Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []*Record
+	for _, key := range s.keys {
+		if strings.HasSuffix(key, checkpointKey) {
+			continue
+		}
+
+		require.Regexp(t, `^history/date=\d{4}-\d{2}-\d{2}/hour=\d{2}/.+\.jsonl$`, key)
+
+		data, err := s.Store.Get(ctx, key)
+		require.NoError(t, err)
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			var r Record
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+			records = append(records, &r)
+		}
+	}
+
+	return records
+}
+
+func Test_Exporter(t *testing.T) {
+	ctx := context.Background()
+	f := historyfeedtest.New(t)
+
+	i1 := f.Run(t)
+
+	store := &recordingStore{Store: archive.NewMemoryStore()}
+	newExporter := func() *Exporter {
+		return NewExporter(f.Backend, store, WithPrefix("history/"), WithBatchSize(1), WithSettleDelay(0))
+	}
+
+	n, err := newExporter().Export(ctx)
+	require.NoError(t, err)
+	require.Greater(t, n, 0)
+
+	records := store.records(t, ctx)
+	require.Len(t, records, n)
+	for _, r := range records {
+		require.Equal(t, i1.InstanceID, r.InstanceID)
+		require.NotEmpty(t, r.EventID)
+	}
+	require.Equal(t, "WorkflowExecutionFinished", records[len(records)-1].Type)
+
+	// Nothing new to export
+	n, err = newExporter().Export(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	// Only events of the new instance are exported, resuming from the stored checkpoint
+	i2 := f.Run(t)
+
+	n, err = newExporter().Export(ctx)
+	require.NoError(t, err)
+	require.Greater(t, n, 0)
+
+	records = store.records(t, ctx)[len(records):]
+	require.Len(t, records, n)
+	for _, r := range records {
+		require.Equal(t, i2.InstanceID, r.InstanceID)
+	}
+}
+
+func Test_Exporter_RunningAndContinuedInstances(t *testing.T) {
+	ctx := context.Background()
+	f := historyfeedtest.New(t)
+
+	running := f.Start(t)
+	continued := f.RunContinued(t, 2)
+
+	store := &recordingStore{Store: archive.NewMemoryStore()}
+	n, err := NewExporter(f.Backend, store, WithPrefix("history/"), WithSettleDelay(0)).Export(ctx)
+	require.NoError(t, err)
+
+	records := store.records(t, ctx)
+	require.Len(t, records, n)
+
+	executions := map[string]bool{}
+	runningExported := false
+	for _, r := range records {
+		switch r.InstanceID {
+		case running.InstanceID:
+			runningExported = true
+
+		case continued.InstanceID:
+			executions[r.ExecutionID] = true
+		}
+	}
+
+	// Events of the running instance, and of both executions of the continued instance, are exported
+	require.True(t, runningExported)
+	require.Len(t, executions, 2)
+}
+
+func Test_Exporter_NotSupported(t *testing.T) {
+	_, err := NewExporter(&backend.MockBackend{}, archive.NewMemoryStore()).Export(context.Background())
+	require.ErrorIs(t, err, backend.ErrCommittedEventsNotSupported)
+}
+
+func Test_Exporter_SettleDelay(t *testing.T) {
+	ctx := context.Background()
+	f := historyfeedtest.New(t)
+
+	f.Run(t)
+
+	// Recently committed events are exported once the delay has passed
+	n, err := NewExporter(f.Backend, archive.NewMemoryStore(), WithSettleDelay(time.Hour)).Export(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+}
@@ -0,0 +1,376 @@
+// Package historydiff compares two workflow instance histories event by event, e.g., the original history of
+// an instance with the events produced when replaying it, or the histories of two resets of the same instance.
+// It's meant for debugging non-determinism and validating reset operations.
+package historydiff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+type ChangeType string
+
+const (
+	// ChangeAdded marks events that are only in the second history.
+	ChangeAdded ChangeType = "added"
+
+	// ChangeRemoved marks events that are only in the first history.
+	ChangeRemoved ChangeType = "removed"
+
+	// ChangeModified marks events that are in both histories, but with different attributes.
+	ChangeModified ChangeType = "modified"
+)
+
+// FieldDiff is a difference in a single attribute of an event. Payloads are rendered as their encoded value.
+type FieldDiff struct {
+	// Path is the path of the attribute, using the names of the attributes' JSON encoding, e.g., "inputs[0]".
+	Path string
+
+	// A and B are the values in the first and second history. Empty if the attribute is missing.
+	A, B string
+}
+
+// Difference is a difference between two histories.
+type Difference struct {
+	Type ChangeType
+
+	// A and B are the events in the first and second history. A is nil for added events, B for removed events.
+	A, B *history.Event
+
+	// Fields are the differing attributes of modified events.
+	Fields []FieldDiff
+}
+
+type options struct {
+	ignoreTimestamps bool
+}
+
+type Option func(*options)
+
+// WithIgnoreTimestamps ignores time values in event attributes, e.g., when timers fire. Those are expected to
+// differ between two runs of the same workflow.
+func WithIgnoreTimestamps() Option {
+	return func(o *options) {
+		o.ignoreTimestamps = true
+	}
+}
+
+// maxAlignmentCells limits the work spent on aligning histories. Beyond it, events are compared by position.
+const maxAlignmentCells = 4_000_000
+
+// Diff returns the differences between histories a and b. Events are matched by their type and schedule event
+// ID, in order. IDs, sequence IDs, and timestamps of events are not compared, since they always differ between
+// runs.
+func Diff(a, b []history.Event, opts ...Option) ([]Difference, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var diffs []Difference
+
+	for _, p := range align(a, b) {
+		switch {
+		case p.a < 0:
+			diffs = append(diffs, Difference{Type: ChangeAdded, B: &b[p.b]})
+
+		case p.b < 0:
+			diffs = append(diffs, Difference{Type: ChangeRemoved, A: &a[p.a]})
+
+		default:
+			fields, err := diffEvents(&a[p.a], &b[p.b], o)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(fields) > 0 {
+				diffs = append(diffs, Difference{Type: ChangeModified, A: &a[p.a], B: &b[p.b], Fields: fields})
+			}
+		}
+	}
+
+	return diffs, nil
+}
+
+// Format writes the given differences in a human readable form.
+func Format(w io.Writer, diffs []Difference) error {
+	for _, d := range diffs {
+		var err error
+
+		switch d.Type {
+		case ChangeAdded:
+			_, err = fmt.Fprintf(w, "+ %s\n", describe(d.B))
+
+		case ChangeRemoved:
+			_, err = fmt.Fprintf(w, "- %s\n", describe(d.A))
+
+		case ChangeModified:
+			if _, err = fmt.Fprintf(w, "~ %s\n", describe(d.A)); err != nil {
+				return err
+			}
+
+			for _, f := range d.Fields {
+				if _, err = fmt.Fprintf(w, "    %s: %s -> %s\n", f.Path, f.A, f.B); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func describe(e *history.Event) string {
+	s := fmt.Sprintf("#%d %s", e.SequenceID, e.Type)
+	if e.ScheduleEventID != 0 {
+		s += fmt.Sprintf(" (schedule event %d)", e.ScheduleEventID)
+	}
+
+	return s
+}
+
+type pair struct {
+	a, b int
+}
+
+func sameEvent(a, b *history.Event) bool {
+	return a.Type == b.Type && a.ScheduleEventID == b.ScheduleEventID
+}
+
+// align matches events of both histories using their longest common subsequence. Unmatched events have an
+// index of -1 on the other side.
+func align(a, b []history.Event) []pair {
+	// Skip common prefix and suffix
+	start := 0
+	for start < len(a) && start < len(b) && sameEvent(&a[start], &b[start]) {
+		start++
+	}
+
+	endA, endB := len(a), len(b)
+	for endA > start && endB > start && sameEvent(&a[endA-1], &b[endB-1]) {
+		endA--
+		endB--
+	}
+
+	pairs := make([]pair, 0, len(a)+len(b))
+	for i := 0; i < start; i++ {
+		pairs = append(pairs, pair{i, i})
+	}
+
+	n, m := endA-start, endB-start
+	if n*m > maxAlignmentCells {
+		for i := 0; i < n || i < m; i++ {
+			switch {
+			case i >= n:
+				pairs = append(pairs, pair{-1, start + i})
+			case i >= m:
+				pairs = append(pairs, pair{start + i, -1})
+			case sameEvent(&a[start+i], &b[start+i]):
+				pairs = append(pairs, pair{start + i, start + i})
+			default:
+				pairs = append(pairs, pair{start + i, -1}, pair{-1, start + i})
+			}
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of a[start+i:endA] and b[start+j:endB]
+		lcs := make([][]int, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int, m+1)
+		}
+
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if sameEvent(&a[start+i], &b[start+j]) {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && sameEvent(&a[start+i], &b[start+j]):
+				pairs = append(pairs, pair{start + i, start + j})
+				i++
+				j++
+			case j >= m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+				pairs = append(pairs, pair{start + i, -1})
+				i++
+			default:
+				pairs = append(pairs, pair{-1, start + j})
+				j++
+			}
+		}
+	}
+
+	for i := 0; i < len(a)-endA; i++ {
+		pairs = append(pairs, pair{endA + i, endB + i})
+	}
+
+	return pairs
+}
+
+func diffEvents(a, b *history.Event, o *options) ([]FieldDiff, error) {
+	aa, err := a.Attributes()
+	if err != nil {
+		return nil, fmt.Errorf("decoding attributes of event %s: %w", a.ID, err)
+	}
+
+	ba, err := b.Attributes()
+	if err != nil {
+		return nil, fmt.Errorf("decoding attributes of event %s: %w", b.ID, err)
+	}
+
+	var fields []FieldDiff
+	diffValues("", reflect.ValueOf(aa), reflect.ValueOf(ba), o, &fields)
+
+	return fields, nil
+}
+
+var (
+	payloadType = reflect.TypeOf(payload.Payload{})
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+func diffValues(path string, a, b reflect.Value, o *options, fields *[]FieldDiff) {
+	if !a.IsValid() || !b.IsValid() {
+		if a.IsValid() != b.IsValid() {
+			*fields = append(*fields, FieldDiff{Path: path, A: render(a), B: render(b)})
+		}
+
+		return
+	}
+
+	if a.Type() != b.Type() {
+		*fields = append(*fields, FieldDiff{Path: path, A: render(a), B: render(b)})
+		return
+	}
+
+	switch {
+	case a.Type() == payloadType:
+		if !bytes.Equal(a.Bytes(), b.Bytes()) {
+			*fields = append(*fields, FieldDiff{Path: path, A: render(a), B: render(b)})
+		}
+
+		return
+
+	case a.Type() == timeType:
+		if !o.ignoreTimestamps && !a.Interface().(time.Time).Equal(b.Interface().(time.Time)) {
+			*fields = append(*fields, FieldDiff{Path: path, A: render(a), B: render(b)})
+		}
+
+		return
+	}
+
+	switch a.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				*fields = append(*fields, FieldDiff{Path: path, A: render(a), B: render(b)})
+			}
+
+			return
+		}
+
+		diffValues(path, a.Elem(), b.Elem(), o, fields)
+
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			f := a.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+
+			diffValues(joinPath(path, fieldName(f)), a.Field(i), b.Field(i), o, fields)
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < a.Len() || i < b.Len(); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+
+			switch {
+			case i >= a.Len():
+				*fields = append(*fields, FieldDiff{Path: p, B: render(b.Index(i))})
+			case i >= b.Len():
+				*fields = append(*fields, FieldDiff{Path: p, A: render(a.Index(i))})
+			default:
+				diffValues(p, a.Index(i), b.Index(i), o, fields)
+			}
+		}
+
+	case reflect.Map:
+		keys := map[string]reflect.Value{}
+		for _, k := range a.MapKeys() {
+			keys[fmt.Sprint(k.Interface())] = k
+		}
+		for _, k := range b.MapKeys() {
+			keys[fmt.Sprint(k.Interface())] = k
+		}
+
+		names := make([]string, 0, len(keys))
+		for name := range keys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			k := keys[name]
+			diffValues(joinPath(path, name), a.MapIndex(k), b.MapIndex(k), o, fields)
+		}
+
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*fields = append(*fields, FieldDiff{Path: path, A: render(a), B: render(b)})
+		}
+	}
+}
+
+func render(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+
+	if v.Type() == payloadType {
+		return string(v.Bytes())
+	}
+
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return "<nil>"
+	}
+
+	return fmt.Sprintf("%+v", v.Interface())
+}
+
+func fieldName(f reflect.StructField) string {
+	if tag, ok := f.Tag.Lookup("json"); ok {
+		if name := strings.Split(tag, ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+
+	return f.Name
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
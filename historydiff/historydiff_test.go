@@ -0,0 +1,130 @@
+package historydiff
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/stretchr/testify/require"
+)
+
+func started(sequenceID int64, input string) history.Event {
+	return history.NewHistoryEvent(sequenceID, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+		Name:   "wf",
+		Inputs: []payload.Payload{payload.Payload(input)},
+	})
+}
+
+func activityScheduled(sequenceID, scheduleEventID int64, name string, inputs ...string) history.Event {
+	a := &history.ActivityScheduledAttributes{Name: name}
+	for _, i := range inputs {
+		a.Inputs = append(a.Inputs, payload.Payload(i))
+	}
+
+	return history.NewHistoryEvent(sequenceID, time.Now(), history.EventType_ActivityScheduled, a, history.ScheduleEventID(scheduleEventID))
+}
+
+func timerScheduled(sequenceID, scheduleEventID int64, at time.Time) history.Event {
+	return history.NewHistoryEvent(sequenceID, time.Now(), history.EventType_TimerScheduled, &history.TimerScheduledAttributes{
+		At: at,
+	}, history.ScheduleEventID(scheduleEventID))
+}
+
+func Test_Diff_IdenticalHistories(t *testing.T) {
+	a := []history.Event{started(1, `"x"`), activityScheduled(2, 1, "a", `1`)}
+
+	// IDs and timestamps differ between runs
+	b := []history.Event{started(1, `"x"`), activityScheduled(2, 1, "a", `1`)}
+
+	diffs, err := Diff(a, b)
+	require.NoError(t, err)
+	require.Empty(t, diffs)
+}
+
+func Test_Diff_ModifiedAttributes(t *testing.T) {
+	a := []history.Event{started(1, `"x"`), activityScheduled(2, 1, "a", `1`, `2`)}
+	b := []history.Event{started(1, `"y"`), activityScheduled(2, 1, "b", `1`)}
+
+	diffs, err := Diff(a, b)
+	require.NoError(t, err)
+	require.Len(t, diffs, 2)
+
+	require.Equal(t, ChangeModified, diffs[0].Type)
+	require.Equal(t, []FieldDiff{{Path: "inputs[0]", A: `"x"`, B: `"y"`}}, diffs[0].Fields)
+
+	require.Equal(t, ChangeModified, diffs[1].Type)
+	require.Equal(t, []FieldDiff{
+		{Path: "name", A: "a", B: "b"},
+		{Path: "inputs[1]", A: "2"},
+	}, diffs[1].Fields)
+}
+
+func Test_Diff_AddedAndRemovedEvents(t *testing.T) {
+	at := time.Now()
+
+	a := []history.Event{
+		started(1, `"x"`),
+		activityScheduled(2, 1, "a"),
+		activityScheduled(3, 2, "b"),
+	}
+	b := []history.Event{
+		started(1, `"x"`),
+		timerScheduled(2, 1, at),
+		activityScheduled(3, 2, "b"),
+		activityScheduled(4, 3, "c"),
+	}
+
+	diffs, err := Diff(a, b)
+	require.NoError(t, err)
+	require.Len(t, diffs, 3)
+
+	require.Equal(t, ChangeRemoved, diffs[0].Type)
+	require.Equal(t, history.EventType_ActivityScheduled, diffs[0].A.Type)
+	require.Nil(t, diffs[0].B)
+
+	require.Equal(t, ChangeAdded, diffs[1].Type)
+	require.Equal(t, history.EventType_TimerScheduled, diffs[1].B.Type)
+
+	require.Equal(t, ChangeAdded, diffs[2].Type)
+	require.Equal(t, int64(3), diffs[2].B.ScheduleEventID)
+
+	var buf bytes.Buffer
+	require.NoError(t, Format(&buf, diffs))
+	require.Equal(t, `- #2 ActivityScheduled (schedule event 1)
++ #2 TimerScheduled (schedule event 1)
++ #4 ActivityScheduled (schedule event 3)
+`, buf.String())
+}
+
+func Test_Diff_IgnoreTimestamps(t *testing.T) {
+	a := []history.Event{timerScheduled(1, 1, time.Now())}
+	b := []history.Event{timerScheduled(1, 1, time.Now().Add(time.Hour))}
+
+	diffs, err := Diff(a, b)
+	require.NoError(t, err)
+	require.Len(t, diffs, 1)
+	require.Equal(t, "at", diffs[0].Fields[0].Path)
+
+	diffs, err = Diff(a, b, WithIgnoreTimestamps())
+	require.NoError(t, err)
+	require.Empty(t, diffs)
+}
+
+func Test_Diff_SerializedAttributes(t *testing.T) {
+	a := []history.Event{activityScheduled(1, 1, "a", `1`)}
+	b := []history.Event{activityScheduled(1, 1, "a", `2`)}
+
+	// Events loaded from a backend only carry serialized attributes
+	for _, e := range []*history.Event{&a[0], &b[0]} {
+		raw, err := e.SerializedAttributes()
+		require.NoError(t, err)
+		e.SetSerializedAttributes(raw)
+	}
+
+	diffs, err := Diff(a, b)
+	require.NoError(t, err)
+	require.Len(t, diffs, 1)
+	require.Equal(t, []FieldDiff{{Path: "inputs[0]", A: "1", B: "2"}}, diffs[0].Fields)
+}
@@ -3,6 +3,7 @@ package activity
 import (
 	"context"
 
+	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/log"
 	"github.com/cschleiden/go-workflows/workflow"
 )
@@ -11,9 +12,12 @@ type ActivityState struct {
 	ActivityID string
 	Instance   *workflow.Instance
 	Logger     log.Logger
+
+	// Attributes of the event that scheduled the activity
+	Attributes *history.ActivityScheduledAttributes
 }
 
-func NewActivityState(activityID string, instance *workflow.Instance, logger log.Logger) *ActivityState {
+func NewActivityState(activityID string, instance *workflow.Instance, logger log.Logger, attributes *history.ActivityScheduledAttributes) *ActivityState {
 	return &ActivityState{
 		activityID,
 		instance,
@@ -21,7 +25,9 @@ func NewActivityState(activityID string, instance *workflow.Instance, logger log
 			"activity_id", activityID,
 			"instance_id", instance.InstanceID,
 			"execution_id", instance.ExecutionID,
-		)}
+		),
+		attributes,
+	}
 }
 
 type key int
@@ -33,7 +33,10 @@ func NewExecutor(logger log.Logger, tracer trace.Tracer, r *workflow.Registry) E
 }
 
 func (e *Executor) ExecuteActivity(ctx context.Context, task *task.Activity) (payload.Payload, error) {
-	a := task.Event.Attributes.(*history.ActivityScheduledAttributes)
+	a, err := history.AttributesAs[*history.ActivityScheduledAttributes](&task.Event)
+	if err != nil {
+		return nil, err
+	}
 
 	activity, err := e.r.GetActivity(a.Name)
 	if err != nil {
@@ -54,7 +57,8 @@ func (e *Executor) ExecuteActivity(ctx context.Context, task *task.Activity) (pa
 	as := NewActivityState(
 		task.Event.ID,
 		task.WorkflowInstance,
-		e.logger)
+		e.logger,
+		a)
 	activityCtx := WithActivityState(ctx, as)
 
 	activityCtx = tracing.UnmarshalSpan(activityCtx, task.Metadata)
@@ -0,0 +1,83 @@
+package activity
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+// Heartbeat tracks the heartbeats recorded by an activity while it's executed.
+type Heartbeat struct {
+	mu      sync.Mutex
+	details payload.Payload
+	last    time.Time
+	pending bool
+}
+
+// NewHeartbeat returns a heartbeat for an activity that starts executing now. details are the details of the last
+// heartbeat recorded by a previous execution, if any.
+func NewHeartbeat(details payload.Payload) *Heartbeat {
+	return &Heartbeat{
+		details: details,
+		last:    time.Now(),
+	}
+}
+
+// Record records a heartbeat with the given details.
+func (h *Heartbeat) Record(details payload.Payload) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.last = time.Now()
+
+	if details != nil {
+		h.details = details
+		h.pending = true
+	}
+}
+
+// Details returns the details of the last heartbeat.
+func (h *Heartbeat) Details() payload.Payload {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.details
+}
+
+// Last returns the time the last heartbeat was recorded, or the time the activity started executing.
+func (h *Heartbeat) Last() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.last
+}
+
+// TakePending returns the details of the last heartbeat if they haven't been taken yet.
+func (h *Heartbeat) TakePending() (payload.Payload, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.pending {
+		return nil, false
+	}
+
+	h.pending = false
+
+	return h.details, true
+}
+
+type heartbeatKey int
+
+var heartbeatCtxKey heartbeatKey
+
+func WithHeartbeat(ctx context.Context, h *Heartbeat) context.Context {
+	return context.WithValue(ctx, heartbeatCtxKey, h)
+}
+
+// GetHeartbeat returns the heartbeat for the current activity, or nil if the activity is not executed by a worker.
+func GetHeartbeat(ctx context.Context) *Heartbeat {
+	h, _ := ctx.Value(heartbeatCtxKey).(*Heartbeat)
+	return h
+}
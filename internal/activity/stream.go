@@ -0,0 +1,24 @@
+package activity
+
+import (
+	"context"
+
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+// Streamer delivers an intermediate result of an activity to the workflow instance that scheduled it.
+type Streamer func(ctx context.Context, chunk payload.Payload) error
+
+type streamerKey int
+
+var streamerCtxKey streamerKey
+
+func WithStreamer(ctx context.Context, s Streamer) context.Context {
+	return context.WithValue(ctx, streamerCtxKey, s)
+}
+
+// GetStreamer returns the streamer for the current activity, or nil if the activity is not streaming.
+func GetStreamer(ctx context.Context) Streamer {
+	s, _ := ctx.Value(streamerCtxKey).(Streamer)
+	return s
+}
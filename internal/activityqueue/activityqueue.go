@@ -0,0 +1,70 @@
+// Package activityqueue names the queues activities requiring labels are dispatched to.
+package activityqueue
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MaxLabels is the maximum number of labels of an activity or a worker. Workers serve a queue for every
+// combination of their labels, 2^n queues for n labels.
+const MaxLabels = 8
+
+// Separators of labeled queue names, which queues and labels can't contain.
+const (
+	labelsSeparator = "#"
+	labelSeparator  = ","
+)
+
+// Validate returns an error if activities can't be dispatched to the given queue with the given labels.
+func Validate(queue string, labels ...string) error {
+	if strings.Contains(queue, labelsSeparator) {
+		return fmt.Errorf("activity queue %q must not contain %q", queue, labelsSeparator)
+	}
+
+	for _, label := range labels {
+		if strings.Contains(label, labelsSeparator) || strings.Contains(label, labelSeparator) {
+			return fmt.Errorf("activity label %q must not contain %q or %q", label, labelsSeparator, labelSeparator)
+		}
+	}
+
+	if len(Normalize(labels)) > MaxLabels {
+		return errors.New("too many activity labels")
+	}
+
+	return nil
+}
+
+// Labeled returns the queue for activities on the given queue that require the given labels.
+func Labeled(queue string, labels ...string) string {
+	labels = Normalize(labels)
+	if len(labels) == 0 {
+		return queue
+	}
+
+	return queue + labelsSeparator + strings.Join(labels, labelSeparator)
+}
+
+// Normalize returns the given labels sorted and without duplicates and empty labels.
+func Normalize(labels []string) []string {
+	if len(labels) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(labels))
+	r := make([]string, 0, len(labels))
+	for _, label := range labels {
+		if label == "" || seen[label] {
+			continue
+		}
+
+		seen[label] = true
+		r = append(r, label)
+	}
+
+	sort.Strings(r)
+
+	return r
+}
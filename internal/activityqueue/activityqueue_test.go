@@ -0,0 +1,29 @@
+package activityqueue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Validate(t *testing.T) {
+	require.NoError(t, Validate("", "gpu", "region=eu"))
+	require.NoError(t, Validate("vpn"))
+
+	// Separators would make queue names ambiguous, e.g., labels "a,b" and "a", "b"
+	require.Error(t, Validate("a#b"))
+	require.Error(t, Validate("", "a,b"))
+	require.Error(t, Validate("", "a#b"))
+
+	labels := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	require.NoError(t, Validate("", labels...))
+
+	// Duplicates don't count
+	require.NoError(t, Validate("", append(labels, "a")...))
+	require.Error(t, Validate("", append(labels, "i")...))
+}
+
+func Test_Labeled(t *testing.T) {
+	require.Equal(t, "q", Labeled("q"))
+	require.Equal(t, "q#a,b", Labeled("q", "b", "a", "b", ""))
+}
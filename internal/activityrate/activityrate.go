@@ -0,0 +1,24 @@
+// Package activityrate spreads the activities scheduled by a workflow instance over time. Backends use it to cap
+// the rate at which a single instance's activities become visible to workers.
+package activityrate
+
+import "time"
+
+// Schedule returns the times at which n activities scheduled at now become visible, if at most perSecond
+// activities of an instance may become visible per second. next is the earliest time at which the next activity
+// of the instance may become visible, or the zero time. Schedule also returns the updated value of next.
+func Schedule(now, next time.Time, n, perSecond int) ([]time.Time, time.Time) {
+	interval := time.Second / time.Duration(perSecond)
+
+	visibleAt := make([]time.Time, n)
+	for i := range visibleAt {
+		if next.Before(now) {
+			next = now
+		}
+
+		visibleAt[i] = next
+		next = next.Add(interval)
+	}
+
+	return visibleAt, next
+}
@@ -0,0 +1,27 @@
+package activityrate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Schedule(t *testing.T) {
+	now := time.Now()
+
+	visibleAt, next := Schedule(now, time.Time{}, 3, 10)
+	require.Equal(t, []time.Time{now, now.Add(100 * time.Millisecond), now.Add(200 * time.Millisecond)}, visibleAt)
+	require.Equal(t, now.Add(300*time.Millisecond), next)
+
+	// Continues after previously scheduled activities
+	visibleAt, next = Schedule(now.Add(100*time.Millisecond), next, 1, 10)
+	require.Equal(t, []time.Time{now.Add(300 * time.Millisecond)}, visibleAt)
+	require.Equal(t, now.Add(400*time.Millisecond), next)
+
+	// Slots in the past are not used
+	later := now.Add(time.Second)
+	visibleAt, next = Schedule(later, next, 1, 10)
+	require.Equal(t, []time.Time{later}, visibleAt)
+	require.Equal(t, later.Add(100*time.Millisecond), next)
+}
@@ -37,7 +37,7 @@ func InputsToArgs(c converter.Converter, fn reflect.Value, inputs []payload.Payl
 		argT := activityFnT.In(i)
 
 		// Insert context if requested
-		if i == 0 && (IsOwnContext(argT) || isContext(argT)) {
+		if i == 0 && (IsOwnContext(argT) || IsContext(argT)) {
 			addContext = true
 			continue
 		}
@@ -66,12 +66,30 @@ func InputsToArgs(c converter.Converter, fn reflect.Value, inputs []payload.Payl
 	return args, addContext, nil
 }
 
+// ParamTypes returns the types of the parameters of the given function that are passed as inputs, i.e.,
+// without a leading context parameter.
+func ParamTypes(fnT reflect.Type) []reflect.Type {
+	params := make([]reflect.Type, 0, fnT.NumIn())
+	for i := 0; i < fnT.NumIn(); i++ {
+		argT := fnT.In(i)
+
+		if i == 0 && (IsOwnContext(argT) || IsContext(argT)) {
+			continue
+		}
+
+		params = append(params, argT)
+	}
+
+	return params
+}
+
 func IsOwnContext(inType reflect.Type) bool {
 	contextElem := reflect.TypeOf((*sync.Context)(nil)).Elem()
 	return inType != nil && inType.Implements(contextElem)
 }
 
-func isContext(inType reflect.Type) bool {
+// IsContext returns true if the given type implements context.Context.
+func IsContext(inType reflect.Type) bool {
 	contextElem := reflect.TypeOf((*context.Context)(nil)).Elem()
 	return inType != nil && inType.Implements(contextElem)
 }
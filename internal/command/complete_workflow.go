@@ -5,6 +5,7 @@ import (
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/workflowerrors"
 )
 
 type CompleteWorkflowCommand struct {
@@ -13,15 +14,18 @@ type CompleteWorkflowCommand struct {
 	Instance *core.WorkflowInstance
 	Result   payload.Payload
 	Error    string
+
+	// ErrorType is the type of the error, see workflowerrors.Type
+	ErrorType string
 }
 
 var _ Command = (*CompleteWorkflowCommand)(nil)
 
 func NewCompleteWorkflowCommand(id int64, instance *core.WorkflowInstance, result payload.Payload, err error) *CompleteWorkflowCommand {
-	// TODO: ERRORS: Better error handling
-	var error string
+	var error, errorType string
 	if err != nil {
 		error = err.Error()
+		errorType = workflowerrors.Type(err)
 	}
 
 	return &CompleteWorkflowCommand{
@@ -30,9 +34,10 @@ func NewCompleteWorkflowCommand(id int64, instance *core.WorkflowInstance, resul
 			name:  "CompleteWorkflow",
 			state: CommandState_Pending,
 		},
-		Instance: instance,
-		Result:   result,
-		Error:    error,
+		Instance:  instance,
+		Result:    result,
+		Error:     error,
+		ErrorType: errorType,
 	}
 }
 
@@ -58,8 +63,9 @@ func (c *CompleteWorkflowCommand) Execute(clock clock.Clock) *CommandResult {
 					clock.Now(),
 					history.EventType_WorkflowExecutionFinished,
 					&history.ExecutionCompletedAttributes{
-						Result: c.Result,
-						Error:  c.Error,
+						Result:    c.Result,
+						Error:     c.Error,
+						ErrorType: c.ErrorType,
 					},
 					history.ScheduleEventID(0),
 				),
@@ -76,7 +82,8 @@ func (c *CompleteWorkflowCommand) Execute(clock clock.Clock) *CommandResult {
 					clock.Now(),
 					history.EventType_SubWorkflowFailed,
 					&history.SubWorkflowFailedAttributes{
-						Error: c.Error,
+						Error:     c.Error,
+						ErrorType: c.ErrorType,
 					},
 					// Ensure the message gets sent back to the parent workflow with the right schedule event ID
 					history.ScheduleEventID(c.Instance.ParentEventID),
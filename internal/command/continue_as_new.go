@@ -0,0 +1,111 @@
+package command
+
+import (
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/google/uuid"
+)
+
+// ContinueAsNewCommand completes the current execution of a workflow instance and starts a new execution of the
+// same instance, with a new execution ID.
+type ContinueAsNewCommand struct {
+	command
+
+	Instance *core.WorkflowInstance
+
+	// NewInstance is the instance of the new execution
+	NewInstance *core.WorkflowInstance
+
+	Name     string
+	Metadata *core.WorkflowMetadata
+	Inputs   []payload.Payload
+	Attempt  int
+	Version  string
+
+	// ExecutionTimeout limits how long the new execution may run, 0 if unlimited
+	ExecutionTimeout time.Duration
+}
+
+var _ Command = (*ContinueAsNewCommand)(nil)
+
+func NewContinueAsNewCommand(
+	id int64, instance *core.WorkflowInstance, name string, inputs []payload.Payload, metadata *core.WorkflowMetadata,
+	attempt int, version string, executionTimeout time.Duration,
+) *ContinueAsNewCommand {
+	newInstance := *instance
+	newInstance.ExecutionID = uuid.NewString()
+
+	return &ContinueAsNewCommand{
+		command: command{
+			id:    id,
+			name:  "ContinueAsNew",
+			state: CommandState_Pending,
+		},
+		Instance:    instance,
+		NewInstance: &newInstance,
+		Name:        name,
+		Metadata:    metadata,
+		Inputs:      inputs,
+		Attempt:     attempt,
+		Version:     version,
+
+		ExecutionTimeout: executionTimeout,
+	}
+}
+
+func (c *ContinueAsNewCommand) Commit() {
+	switch c.state {
+	case CommandState_Pending:
+		c.state = CommandState_Done
+
+	default:
+		c.invalidStateTransition(CommandState_Done)
+	}
+}
+
+func (c *ContinueAsNewCommand) Execute(clock clock.Clock) *CommandResult {
+	switch c.state {
+	case CommandState_Pending:
+		c.state = CommandState_Done
+
+		return &CommandResult{
+			Completed: true,
+			Events: []history.Event{
+				history.NewPendingEvent(
+					clock.Now(),
+					history.EventType_WorkflowExecutionFinished,
+					&history.ExecutionCompletedAttributes{
+						ContinuedAsNew: c.NewInstance.ExecutionID,
+					},
+					history.ScheduleEventID(0),
+				),
+			},
+			// Start the new execution. Backends replace the current execution of the instance with it.
+			WorkflowEvents: []history.WorkflowEvent{
+				{
+					WorkflowInstance: c.NewInstance,
+					HistoryEvent: history.NewPendingEvent(
+						clock.Now(),
+						history.EventType_WorkflowExecutionStarted,
+						&history.ExecutionStartedAttributes{
+							Name:     c.Name,
+							Inputs:   c.Inputs,
+							Metadata: c.Metadata,
+							Attempt:  c.Attempt,
+							Version:  c.Version,
+
+							ExecutionTimeout: c.ExecutionTimeout,
+						},
+						history.ScheduleEventID(0),
+					),
+				},
+			},
+		}
+	}
+
+	return nil
+}
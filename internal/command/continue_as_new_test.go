@@ -0,0 +1,51 @@
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContinueAsNewCommand_Execute(t *testing.T) {
+	instance := core.NewSubWorkflowInstance(uuid.NewString(), uuid.NewString(), "parent", 4)
+	inputs := []payload.Payload{[]byte("1")}
+
+	cmd := NewContinueAsNewCommand(1, instance, "Counter", inputs, &core.WorkflowMetadata{}, 0, "v2", time.Minute)
+
+	// The new execution keeps the instance, but gets a new execution ID
+	require.Equal(t, instance.InstanceID, cmd.NewInstance.InstanceID)
+	require.NotEqual(t, instance.ExecutionID, cmd.NewInstance.ExecutionID)
+	require.Equal(t, "parent", cmd.NewInstance.ParentInstanceID)
+	require.Equal(t, int64(4), cmd.NewInstance.ParentEventID)
+
+	r := cmd.Execute(clock.NewMock())
+	require.NotNil(t, r)
+	require.True(t, r.Completed)
+	require.Equal(t, CommandState_Done, cmd.State())
+
+	require.Len(t, r.Events, 1)
+	require.Equal(t, history.EventType_WorkflowExecutionFinished, r.Events[0].Type)
+	ca, err := history.AttributesAs[*history.ExecutionCompletedAttributes](&r.Events[0])
+	require.NoError(t, err)
+	require.Equal(t, cmd.NewInstance.ExecutionID, ca.ContinuedAsNew)
+
+	// No completion is sent to the parent, only the new execution is started
+	require.Len(t, r.WorkflowEvents, 1)
+	require.Equal(t, cmd.NewInstance, r.WorkflowEvents[0].WorkflowInstance)
+	require.Equal(t, history.EventType_WorkflowExecutionStarted, r.WorkflowEvents[0].HistoryEvent.Type)
+
+	a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&r.WorkflowEvents[0].HistoryEvent)
+	require.NoError(t, err)
+	require.Equal(t, "Counter", a.Name)
+	require.Equal(t, inputs, a.Inputs)
+	require.Equal(t, "v2", a.Version)
+	require.Equal(t, time.Minute, a.ExecutionTimeout)
+
+	require.Nil(t, cmd.Execute(clock.NewMock()))
+}
@@ -0,0 +1,65 @@
+package command
+
+import (
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+type RecordMarkerCommand struct {
+	command
+
+	Name    string
+	Details payload.Payload
+
+	// Validate makes strict replay compare the details with the ones recorded in the history
+	Validate bool
+}
+
+var _ Command = (*RecordMarkerCommand)(nil)
+
+func NewRecordMarkerCommand(id int64, name string, details payload.Payload) *RecordMarkerCommand {
+	return &RecordMarkerCommand{
+		command: command{
+			id:    id,
+			name:  "RecordMarker",
+			state: CommandState_Pending,
+		},
+
+		Name:    name,
+		Details: details,
+	}
+}
+
+func (c *RecordMarkerCommand) Execute(clock clock.Clock) *CommandResult {
+	switch c.state {
+	case CommandState_Pending:
+		// Markers are only added to the history, transition to Done
+		c.state = CommandState_Done
+
+		return &CommandResult{
+			Events: []history.Event{
+				history.NewPendingEvent(
+					clock.Now(),
+					history.EventType_MarkerRecorded,
+					&history.MarkerRecordedAttributes{
+						Name:    c.Name,
+						Details: c.Details,
+					},
+					history.ScheduleEventID(c.id),
+				),
+			},
+		}
+	}
+
+	return nil
+}
+
+func (c *RecordMarkerCommand) Done() {
+	switch c.state {
+	case CommandState_Pending, CommandState_Committed:
+		c.state = CommandState_Done
+	default:
+		c.invalidStateTransition(CommandState_Done)
+	}
+}
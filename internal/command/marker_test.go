@@ -0,0 +1,41 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordMarkerCommand_StateTransitions(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func(t *testing.T, c *RecordMarkerCommand, clock clock.Clock)
+	}{
+		{"Execute records marker", func(t *testing.T, c *RecordMarkerCommand, clock clock.Clock) {
+			r := assertExecuteWithEvent(t, c, CommandState_Done, history.EventType_MarkerRecorded)
+
+			a, err := history.AttributesAs[*history.MarkerRecordedAttributes](&r.Events[0])
+			require.NoError(t, err)
+			require.Equal(t, "checkpoint", a.Name)
+			require.Equal(t, payload.Payload("42"), a.Details)
+		}},
+		{"Done", func(t *testing.T, c *RecordMarkerCommand, _ clock.Clock) {
+			require.Equal(t, CommandState_Pending, c.State())
+
+			c.Done()
+			require.Equal(t, CommandState_Done, c.State())
+
+			assertExecuteNoEvent(t, c, CommandState_Done)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := clock.NewMock()
+			cmd := NewRecordMarkerCommand(1, "checkpoint", payload.Payload("42"))
+			tt.f(t, cmd, clock)
+		})
+	}
+}
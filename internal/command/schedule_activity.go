@@ -1,29 +1,61 @@
 package command
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/benbjohnson/clock"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/workflowerrors"
 )
 
 type ScheduleActivityCommand struct {
 	command
 
-	Name   string
-	Inputs []payload.Payload
+	Name    string
+	Inputs  []payload.Payload
+	Attempt int
+
+	// Stream is set for streaming activities
+	Stream string
+
+	// Queue is the queue the activity is dispatched to
+	Queue string
+
+	// Labels are the labels required to execute the activity
+	Labels []string
+
+	MaxAttempts           int
+	RetryDeadline         time.Time
+	CancellationRequested bool
+
+	// HeartbeatTimeout is the maximum time between heartbeats of the activity
+	HeartbeatTimeout time.Duration
+
+	// HeartbeatDetails are the details of the last heartbeat of the previous attempt
+	HeartbeatDetails payload.Payload
+
+	// ScheduleToStartTimeout and StartToCloseTimeout limit how long the activity may wait for and take to execute
+	ScheduleToStartTimeout time.Duration
+	StartToCloseTimeout    time.Duration
+
+	// Started is set once a worker reported starting the activity
+	Started bool
 }
 
 var _ Command = (*ScheduleActivityCommand)(nil)
 
-func NewScheduleActivityCommand(id int64, name string, inputs []payload.Payload) *ScheduleActivityCommand {
+func NewScheduleActivityCommand(id int64, name string, inputs []payload.Payload, attempt int) *ScheduleActivityCommand {
 	return &ScheduleActivityCommand{
 		command: command{
 			id:    id,
 			name:  "ScheduleActivity",
 			state: CommandState_Pending,
 		},
-		Name:   name,
-		Inputs: inputs,
+		Name:    name,
+		Inputs:  inputs,
+		Attempt: attempt,
 	}
 }
 
@@ -32,18 +64,54 @@ func (c *ScheduleActivityCommand) Execute(clock clock.Clock) *CommandResult {
 	case CommandState_Pending:
 		c.state = CommandState_Committed
 
+		var retryDeadline *time.Time
+		if !c.RetryDeadline.IsZero() {
+			retryDeadline = &c.RetryDeadline
+		}
+
+		now := clock.Now()
 		event := history.NewPendingEvent(
-			clock.Now(),
+			now,
 			history.EventType_ActivityScheduled,
 			&history.ActivityScheduledAttributes{
-				Name:   c.Name,
-				Inputs: c.Inputs,
+				Name:    c.Name,
+				Inputs:  c.Inputs,
+				Attempt: c.Attempt,
+				Stream:  c.Stream,
+				Queue:   c.Queue,
+				Labels:  c.Labels,
+
+				MaxAttempts:           c.MaxAttempts,
+				RetryDeadline:         retryDeadline,
+				CancellationRequested: c.CancellationRequested,
+				HeartbeatTimeout:      c.HeartbeatTimeout,
+				HeartbeatDetails:      c.HeartbeatDetails,
+
+				ScheduleToStartTimeout: c.ScheduleToStartTimeout,
+				StartToCloseTimeout:    c.StartToCloseTimeout,
 			},
 			history.ScheduleEventID(c.id))
 
+		// Fail the activity if no worker starts it in time, even if no worker picks it up at all
+		var timerEvents []history.Event
+		if c.ScheduleToStartTimeout > 0 {
+			timerEvents = append(timerEvents, history.NewPendingEvent(
+				now,
+				history.EventType_ActivityFailed,
+				&history.ActivityFailedAttributes{
+					Reason:  fmt.Sprintf("activity %s was not started within %v", c.Name, c.ScheduleToStartTimeout),
+					Type:    workflowerrors.TimeoutErrorType,
+					Timeout: history.ActivityTimeout_ScheduleToStart,
+				},
+				history.ScheduleEventID(c.id),
+				history.VisibleAt(now.Add(c.ScheduleToStartTimeout)),
+			))
+		}
+
 		return &CommandResult{
 			Events:         []history.Event{event},
 			ActivityEvents: []history.Event{event},
+			TimerEvents:    timerEvents,
 		}
 	}
 
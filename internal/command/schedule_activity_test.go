@@ -2,6 +2,7 @@ package command
 
 import (
 	"testing"
+	"time"
 
 	"github.com/benbjohnson/clock"
 	"github.com/cschleiden/go-workflows/internal/history"
@@ -17,6 +18,55 @@ func TestScheduleActivityCommand_StateTransitions(t *testing.T) {
 		{"Execute schedules activity", func(t *testing.T, c *ScheduleActivityCommand, clock clock.Clock) {
 			assertExecuteWithEvent(t, c, CommandState_Committed, history.EventType_ActivityScheduled)
 		}},
+		{"Execute records queue", func(t *testing.T, c *ScheduleActivityCommand, clock clock.Clock) {
+			c.Queue = "vpn"
+
+			r := c.Execute(clock)
+			require.Len(t, r.ActivityEvents, 1)
+
+			a, err := history.AttributesAs[*history.ActivityScheduledAttributes](&r.ActivityEvents[0])
+			require.NoError(t, err)
+			require.Equal(t, "vpn", a.Queue)
+		}},
+		{"Execute records retry options", func(t *testing.T, c *ScheduleActivityCommand, clock clock.Clock) {
+			r := c.Execute(clock)
+
+			a, err := history.AttributesAs[*history.ActivityScheduledAttributes](&r.ActivityEvents[0])
+			require.NoError(t, err)
+			require.Nil(t, a.RetryDeadline)
+
+			deadline := clock.Now().Add(time.Minute)
+			c = NewScheduleActivityCommand(2, "activity", []payload.Payload{}, 1)
+			c.MaxAttempts = 3
+			c.RetryDeadline = deadline
+			c.CancellationRequested = true
+
+			r = c.Execute(clock)
+
+			a, err = history.AttributesAs[*history.ActivityScheduledAttributes](&r.ActivityEvents[0])
+			require.NoError(t, err)
+			require.Equal(t, 1, a.Attempt)
+			require.Equal(t, 3, a.MaxAttempts)
+			require.Equal(t, &deadline, a.RetryDeadline)
+			require.True(t, a.CancellationRequested)
+		}},
+		{"Execute schedules schedule-to-start timeout", func(t *testing.T, c *ScheduleActivityCommand, clock clock.Clock) {
+			r := c.Execute(clock)
+			require.Empty(t, r.TimerEvents)
+
+			c = NewScheduleActivityCommand(2, "activity", []payload.Payload{}, 1)
+			c.ScheduleToStartTimeout = time.Minute
+
+			r = c.Execute(clock)
+			require.Len(t, r.TimerEvents, 1)
+			require.Equal(t, history.EventType_ActivityFailed, r.TimerEvents[0].Type)
+			require.Equal(t, int64(2), r.TimerEvents[0].ScheduleEventID)
+			require.Equal(t, clock.Now().Add(time.Minute), *r.TimerEvents[0].VisibleAt)
+
+			a, err := history.AttributesAs[*history.ActivityFailedAttributes](&r.TimerEvents[0])
+			require.NoError(t, err)
+			require.Equal(t, history.ActivityTimeout_ScheduleToStart, a.Timeout)
+		}},
 		{"Commit", func(t *testing.T, c *ScheduleActivityCommand, _ clock.Clock) {
 			require.Equal(t, CommandState_Pending, c.State())
 
@@ -36,7 +86,7 @@ func TestScheduleActivityCommand_StateTransitions(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			clock := clock.NewMock()
-			cmd := NewScheduleActivityCommand(1, "activity", []payload.Payload{})
+			cmd := NewScheduleActivityCommand(1, "activity", []payload.Payload{}, 0)
 
 			tt.f(t, cmd, clock)
 		})
@@ -14,14 +14,20 @@ type ScheduleSubWorkflowCommand struct {
 	Instance *core.WorkflowInstance
 	Metadata *core.WorkflowMetadata
 
-	Name   string
-	Inputs []payload.Payload
+	Name    string
+	Inputs  []payload.Payload
+	Attempt int
+	Version string
+
+	// DerivedInstanceID is set if the instance ID of the sub-workflow was not set explicitly
+	DerivedInstanceID bool
 }
 
 var _ CancelableCommand = (*ScheduleSubWorkflowCommand)(nil)
 
 func NewScheduleSubWorkflowCommand(
 	id int64, parentInstance *core.WorkflowInstance, subWorkflowInstanceID, name string, inputs []payload.Payload, metadata *core.WorkflowMetadata,
+	attempt int, version string,
 ) *ScheduleSubWorkflowCommand {
 	if subWorkflowInstanceID == "" {
 		subWorkflowInstanceID = uuid.New().String()
@@ -39,8 +45,10 @@ func NewScheduleSubWorkflowCommand(
 		Instance: core.NewSubWorkflowInstance(subWorkflowInstanceID, uuid.NewString(), parentInstance.InstanceID, id),
 		Metadata: metadata,
 
-		Name:   name,
-		Inputs: inputs,
+		Name:    name,
+		Inputs:  inputs,
+		Attempt: attempt,
+		Version: version,
 	}
 }
 
@@ -59,6 +67,7 @@ func (c *ScheduleSubWorkflowCommand) Execute(clock clock.Clock) *CommandResult {
 						Metadata:            c.Metadata,
 						Name:                c.Name,
 						Inputs:              c.Inputs,
+						Version:             c.Version,
 					},
 					history.ScheduleEventID(c.id),
 				),
@@ -74,6 +83,10 @@ func (c *ScheduleSubWorkflowCommand) Execute(clock clock.Clock) *CommandResult {
 							Name:     c.Name,
 							Inputs:   c.Inputs,
 							Metadata: c.Metadata,
+							Attempt:  c.Attempt,
+							Version:  c.Version,
+
+							DerivedInstanceID: c.DerivedInstanceID,
 						},
 						history.ScheduleEventID(0),
 					),
@@ -100,7 +113,7 @@ func (c *ScheduleSubWorkflowCommand) Execute(clock clock.Clock) *CommandResult {
 			WorkflowEvents: []history.WorkflowEvent{
 				{
 					WorkflowInstance: c.Instance,
-					HistoryEvent:     history.NewWorkflowCancellationEvent(clock.Now()),
+					HistoryEvent:     history.NewWorkflowCancellationEvent(clock.Now(), history.CancellationReason_Parent),
 				},
 			},
 		}
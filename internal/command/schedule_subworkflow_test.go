@@ -20,6 +20,19 @@ func TestScheduleSubWorkflowCommand_StateTransitions(t *testing.T) {
 			r := assertExecuteWithEvent(t, c, CommandState_Committed, history.EventType_SubWorkflowScheduled)
 			require.Equal(t, r.WorkflowEvents[0].HistoryEvent.Type, history.EventType_WorkflowExecutionStarted)
 		}},
+		{"Execute records pinned version", func(t *testing.T, c *ScheduleSubWorkflowCommand, clock clock.Clock) {
+			c.Version = "2"
+
+			r := assertExecuteWithEvent(t, c, CommandState_Committed, history.EventType_SubWorkflowScheduled)
+
+			scheduled, err := history.AttributesAs[*history.SubWorkflowScheduledAttributes](&r.Events[0])
+			require.NoError(t, err)
+			require.Equal(t, "2", scheduled.Version)
+
+			started, err := history.AttributesAs[*history.ExecutionStartedAttributes](&r.WorkflowEvents[0].HistoryEvent)
+			require.NoError(t, err)
+			require.Equal(t, "2", started.Version)
+		}},
 		{"Cancel after schedule yields cancel event", func(t *testing.T, c *ScheduleSubWorkflowCommand, clock clock.Clock) {
 			assertExecuteWithEvent(t, c, CommandState_Committed, history.EventType_SubWorkflowScheduled)
 
@@ -96,7 +109,7 @@ func TestScheduleSubWorkflowCommand_StateTransitions(t *testing.T) {
 
 			parentInstance := core.NewWorkflowInstance(uuid.NewString(), "")
 
-			cmd := NewScheduleSubWorkflowCommand(1, parentInstance, uuid.NewString(), "SubWorkflow", []payload.Payload{}, &core.WorkflowMetadata{})
+			cmd := NewScheduleSubWorkflowCommand(1, parentInstance, uuid.NewString(), "SubWorkflow", []payload.Payload{}, &core.WorkflowMetadata{}, 0, "")
 
 			tt.f(t, cmd, clock)
 		})
@@ -1,6 +1,7 @@
 package command
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/benbjohnson/clock"
@@ -11,6 +12,11 @@ type ScheduleTimerCommand struct {
 	cancelableCommand
 
 	at time.Time
+
+	// rescheduleAt is set when the timer is rescheduled after it has been committed
+	rescheduleAt *time.Time
+
+	rescheduled bool
 }
 
 var _ CancelableCommand = (*ScheduleTimerCommand)(nil)
@@ -28,6 +34,46 @@ func NewScheduleTimerCommand(id int64, at time.Time) *ScheduleTimerCommand {
 	}
 }
 
+// At returns the time the timer is scheduled to fire at.
+func (c *ScheduleTimerCommand) At() time.Time {
+	if c.rescheduleAt != nil {
+		return *c.rescheduleAt
+	}
+
+	return c.at
+}
+
+// Rescheduled returns true if the timer has been rescheduled after it was committed.
+func (c *ScheduleTimerCommand) Rescheduled() bool {
+	return c.rescheduled
+}
+
+// Reschedule moves the timer to fire at the given time.
+func (c *ScheduleTimerCommand) Reschedule(at time.Time) {
+	switch c.state {
+	case CommandState_Pending:
+		// Not yet scheduled, just update the time
+		c.at = at
+	case CommandState_Committed:
+		c.rescheduleAt = &at
+		c.rescheduled = true
+	default:
+		panic(fmt.Errorf("cannot reschedule timer in state %s", c.state.String()))
+	}
+}
+
+// HandleReschedule handles a reschedule event during replay
+func (c *ScheduleTimerCommand) HandleReschedule(at time.Time) {
+	switch c.state {
+	case CommandState_Committed:
+		c.at = at
+		c.rescheduleAt = nil
+		c.rescheduled = true
+	default:
+		panic(fmt.Errorf("cannot handle reschedule of timer in state %s", c.state.String()))
+	}
+}
+
 func (c *ScheduleTimerCommand) Execute(clock clock.Clock) *CommandResult {
 	switch c.state {
 	case CommandState_Pending:
@@ -58,6 +104,40 @@ func (c *ScheduleTimerCommand) Execute(clock clock.Clock) *CommandResult {
 			},
 		}
 
+	case CommandState_Committed:
+		if c.rescheduleAt == nil {
+			return nil
+		}
+
+		c.at = *c.rescheduleAt
+		c.rescheduleAt = nil
+
+		return &CommandResult{
+			Events: []history.Event{
+				history.NewPendingEvent(
+					clock.Now(),
+					history.EventType_TimerRescheduled,
+					&history.TimerRescheduledAttributes{
+						At: c.at,
+					},
+					history.ScheduleEventID(c.id),
+				),
+			},
+
+			// Replaces the previously scheduled TimerFired event
+			TimerEvents: []history.Event{
+				history.NewPendingEvent(
+					clock.Now(),
+					history.EventType_TimerFired,
+					&history.TimerFiredAttributes{
+						At: c.at,
+					},
+					history.ScheduleEventID(c.id),
+					history.VisibleAt(c.at),
+				),
+			},
+		}
+
 	case CommandState_CancelPending:
 		c.state = CommandState_Canceled
 
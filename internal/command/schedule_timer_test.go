@@ -78,6 +78,46 @@ func TestScheduleTimerCommand_StateTransitions(t *testing.T) {
 				c.HandleCancel()
 			})
 		}},
+		{"Reschedule before commit updates time", func(t *testing.T, c *ScheduleTimerCommand, clock clock.Clock) {
+			at := clock.Now().Add(time.Hour)
+			c.Reschedule(at)
+			require.Equal(t, at, c.At())
+			require.False(t, c.Rescheduled())
+
+			r := assertExecuteWithEvent(t, c, CommandState_Committed, history.EventType_TimerScheduled)
+			a, err := history.AttributesAs[*history.TimerFiredAttributes](&r.TimerEvents[0])
+			require.NoError(t, err)
+			require.Equal(t, at, a.At)
+		}},
+		{"Reschedule after commit yields reschedule event", func(t *testing.T, c *ScheduleTimerCommand, clock clock.Clock) {
+			c.Commit()
+
+			at := clock.Now().Add(time.Hour)
+			c.Reschedule(at)
+			require.Equal(t, at, c.At())
+			require.True(t, c.Rescheduled())
+
+			r := assertExecuteWithEvent(t, c, CommandState_Committed, history.EventType_TimerRescheduled)
+			a, err := history.AttributesAs[*history.TimerRescheduledAttributes](&r.Events[0])
+			require.NoError(t, err)
+			require.Equal(t, at, a.At)
+			require.Len(t, r.TimerEvents, 1)
+			require.Equal(t, int64(1), r.TimerEvents[0].ScheduleEventID)
+			require.Equal(t, at, *r.TimerEvents[0].VisibleAt)
+
+			assertExecuteNoEvent(t, c, CommandState_Committed)
+		}},
+		{"HandleReschedule", func(t *testing.T, c *ScheduleTimerCommand, clock clock.Clock) {
+			c.Commit()
+
+			c.Reschedule(clock.Now().Add(time.Hour))
+
+			at := clock.Now().Add(2 * time.Hour)
+			c.HandleReschedule(at)
+			require.Equal(t, at, c.At())
+
+			assertExecuteNoEvent(t, c, CommandState_Committed)
+		}},
 		{"Invalid_Commit", func(t *testing.T, c *ScheduleTimerCommand, clock clock.Clock) {
 			c.Cancel()
 
@@ -9,4 +9,4 @@ type Converter interface {
 	From(data payload.Payload, v interface{}) error
 }
 
-var DefaultConverter Converter = &jsonConverter{}
+var DefaultConverter Converter = NewJSONConverter()
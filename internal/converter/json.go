@@ -1,17 +1,281 @@
 package converter
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"sort"
 
 	"github.com/cschleiden/go-workflows/internal/payload"
 )
 
 type jsonConverter struct{}
 
+// To encodes v canonically: object keys are sorted, including keys of struct fields and of values with a
+// custom MarshalJSON, and there is no insignificant whitespace. Encoding equal values always produces the
+// same payload, so payloads can be hashed or compared.
 func (jc *jsonConverter) To(v interface{}) (payload.Payload, error) {
-	return json.Marshal(v)
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return canonicalJSON(data)
 }
 
 func (jc *jsonConverter) From(data payload.Payload, vptr interface{}) error {
 	return json.Unmarshal(data, vptr)
 }
+
+func NewJSONConverter() Converter {
+	return &jsonConverter{}
+}
+
+// canonicalJSON re-encodes data, as returned by json.Marshal, with sorted object keys in a single pass over its
+// tokens. Numbers are kept as they are. If an object has duplicate keys, the last one wins.
+func canonicalJSON(data []byte) ([]byte, error) {
+	// Only objects can be encoded in different ways
+	if bytes.IndexByte(data, '{') < 0 {
+		return data, nil
+	}
+
+	e := &canonicalEncoder{data: data, out: make([]byte, 0, len(data))}
+	if err := e.value(); err != nil {
+		return nil, err
+	}
+
+	return e.out, nil
+}
+
+var errInvalidJSON = errors.New("invalid JSON")
+
+// canonicalEncoder copies the JSON value in data to out. Members of objects are written in their original order
+// first, and only reordered if their keys are not sorted already.
+type canonicalEncoder struct {
+	data []byte
+	pos  int
+	out  []byte
+}
+
+// member is an object member written to out[start:end]. key is set if the key contains escape sequences, otherwise
+// the key is out[start+1:keyEnd-1].
+type member struct {
+	key                []byte
+	start, keyEnd, end int
+}
+
+func (e *canonicalEncoder) value() error {
+	if e.pos >= len(e.data) {
+		return errInvalidJSON
+	}
+
+	switch e.data[e.pos] {
+	case '{':
+		return e.object()
+
+	case '[':
+		return e.array()
+
+	case '"':
+		_, err := e.string()
+		return err
+
+	default:
+		// Numbers and literals are copied as they are
+		start := e.pos
+		for e.pos < len(e.data) {
+			switch e.data[e.pos] {
+			case ',', ']', '}', ':':
+				e.out = append(e.out, e.data[start:e.pos]...)
+				return nil
+			}
+
+			e.pos++
+		}
+
+		e.out = append(e.out, e.data[start:]...)
+		return nil
+	}
+}
+
+func (e *canonicalEncoder) array() error {
+	e.out = append(e.out, '[')
+	e.pos++
+
+	if e.pos < len(e.data) && e.data[e.pos] == ']' {
+		e.out = append(e.out, ']')
+		e.pos++
+		return nil
+	}
+
+	for {
+		if err := e.value(); err != nil {
+			return err
+		}
+
+		if e.pos >= len(e.data) {
+			return errInvalidJSON
+		}
+
+		c := e.data[e.pos]
+		e.out = append(e.out, c)
+		e.pos++
+
+		switch c {
+		case ',':
+		case ']':
+			return nil
+		default:
+			return errInvalidJSON
+		}
+	}
+}
+
+// string copies the string starting at the current position. Strings with escape sequences are normalized the way
+// json.Marshal encodes them, and the decoded string is returned.
+func (e *canonicalEncoder) string() ([]byte, error) {
+	start := e.pos
+	escaped := false
+
+	for e.pos++; e.pos < len(e.data); e.pos++ {
+		switch e.data[e.pos] {
+		case '\\':
+			escaped = true
+			e.pos++
+
+		case '"':
+			e.pos++
+			raw := e.data[start:e.pos]
+
+			if !escaped {
+				e.out = append(e.out, raw...)
+				return nil, nil
+			}
+
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return nil, err
+			}
+
+			normalized, err := json.Marshal(s)
+			if err != nil {
+				return nil, err
+			}
+
+			e.out = append(e.out, normalized...)
+			return []byte(s), nil
+		}
+	}
+
+	return nil, errInvalidJSON
+}
+
+func (e *canonicalEncoder) object() error {
+	e.out = append(e.out, '{')
+	e.pos++
+
+	if e.pos < len(e.data) && e.data[e.pos] == '}' {
+		e.out = append(e.out, '}')
+		e.pos++
+		return nil
+	}
+
+	start := len(e.out)
+	members := make([]member, 0, 8)
+
+	for {
+		if e.pos >= len(e.data) || e.data[e.pos] != '"' {
+			return errInvalidJSON
+		}
+
+		m := member{start: len(e.out)}
+
+		key, err := e.string()
+		if err != nil {
+			return err
+		}
+
+		m.key, m.keyEnd = key, len(e.out)
+
+		if e.pos >= len(e.data) || e.data[e.pos] != ':' {
+			return errInvalidJSON
+		}
+
+		e.out = append(e.out, ':')
+		e.pos++
+
+		if err := e.value(); err != nil {
+			return err
+		}
+
+		m.end = len(e.out)
+		members = append(members, m)
+
+		if e.pos >= len(e.data) {
+			return errInvalidJSON
+		}
+
+		c := e.data[e.pos]
+		e.pos++
+
+		if c == '}' {
+			break
+		}
+
+		if c != ',' {
+			return errInvalidJSON
+		}
+
+		e.out = append(e.out, ',')
+	}
+
+	e.sortMembers(start, members)
+	e.out = append(e.out, '}')
+
+	return nil
+}
+
+// sortMembers rewrites the given members, starting at out[start:], ordered by their keys
+func (e *canonicalEncoder) sortMembers(start int, members []member) {
+	// Members are read from a copy, since out is overwritten
+	written := e.out[start:]
+	keyOf := func(m member) []byte {
+		if m.key != nil {
+			return m.key
+		}
+
+		return written[m.start-start+1 : m.keyEnd-start-1]
+	}
+
+	sorted := true
+	for i := 1; i < len(members); i++ {
+		if bytes.Compare(keyOf(members[i-1]), keyOf(members[i])) >= 0 {
+			sorted = false
+			break
+		}
+	}
+
+	if sorted {
+		return
+	}
+
+	// Duplicate keys keep their order, so the last one can be picked
+	sort.SliceStable(members, func(i, j int) bool {
+		return bytes.Compare(keyOf(members[i]), keyOf(members[j])) < 0
+	})
+
+	written = append([]byte(nil), written...)
+	e.out = e.out[:start]
+
+	for i, m := range members {
+		if i+1 < len(members) && bytes.Equal(keyOf(m), keyOf(members[i+1])) {
+			continue
+		}
+
+		if len(e.out) > start {
+			e.out = append(e.out, ',')
+		}
+
+		e.out = append(e.out, written[m.start-start:m.end-start]...)
+	}
+}
@@ -0,0 +1,153 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type unorderedMarshaler struct{}
+
+func (unorderedMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`{ "b": 1, "a": {"d": 2, "c": 3} }`), nil
+}
+
+func Test_JSONConverter_Canonical(t *testing.T) {
+	c := NewJSONConverter()
+
+	tests := []struct {
+		name     string
+		v        interface{}
+		expected string
+	}{
+		{"map", map[string]int{"b": 1, "a": 2, "c": 3}, `{"a":2,"b":1,"c":3}`},
+		{"struct", struct {
+			B string
+			A int
+		}{"x", 1}, `{"A":1,"B":"x"}`},
+		{"custom marshaler", unorderedMarshaler{}, `{"a":{"c":3,"d":2},"b":1}`},
+		{"raw message", json.RawMessage(`{"b":1,"a":2}`), `{"a":2,"b":1}`},
+		{"nested", []interface{}{map[string]interface{}{"y": []int{1}, "x": nil}}, `[{"x":null,"y":[1]}]`},
+		{"numbers", map[string]interface{}{"n": json.Number("12345678901234567890.5")}, `{"n":12345678901234567890.5}`},
+		{"scalar", "a{b", `"a{b"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := c.To(tt.v)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(p))
+		})
+	}
+}
+
+func Test_JSONConverter_MapsEncodeDeterministically(t *testing.T) {
+	c := NewJSONConverter()
+
+	m := map[string]interface{}{}
+	for _, k := range []string{"e", "d", "c", "b", "a"} {
+		m[k] = map[string]int{k + "2": 2, k + "1": 1}
+	}
+
+	expected, err := c.To(m)
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		p, err := c.To(m)
+		require.NoError(t, err)
+		require.Equal(t, expected, p)
+	}
+}
+
+// decodeAndMarshal is the straightforward way to produce canonical JSON, which canonicalJSON has to match
+func decodeAndMarshal(data []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+func Test_CanonicalJSON_MatchesDecodeAndMarshal(t *testing.T) {
+	inputs := []string{
+		`{}`,
+		`[]`,
+		`{"a":{},"b":[]}`,
+		`{"b":1,"a":2,"b":3}`,
+		`{"b":1,"a":2}`,
+		`{"b\"":1,"b":2,"a\\":3}`,
+		`{"s":"café \u003cb\u003e \u00e9","t":"tab\there"}`,
+		`{"z":[{"y":true,"x":false},null,"{"],"a":-1.5e+10}`,
+		`["{",{"b":{"d":1,"c":{"f":2,"e":3}},"a":0}]`,
+		`{"é":1,"e":2,"z":3}`,
+		`"a{b"`,
+	}
+
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			expected, err := decodeAndMarshal([]byte(input))
+			require.NoError(t, err)
+
+			p, err := canonicalJSON([]byte(input))
+			require.NoError(t, err)
+			require.Equal(t, string(expected), string(p))
+		})
+	}
+}
+
+func Test_CanonicalJSON_Invalid(t *testing.T) {
+	for _, input := range []string{`{"a":1`, `{"a" 1}`, `[{"a":1},2`, `{"a":"b}`} {
+		_, err := canonicalJSON([]byte(input))
+		require.Error(t, err, input)
+	}
+}
+
+type benchmarkPayload struct {
+	Name    string
+	ID      int
+	Tags    []string
+	Details map[string]interface{}
+	Nested  struct {
+		Zeta  float64
+		Alpha bool
+	}
+}
+
+func Benchmark_JSONConverter_To(b *testing.B) {
+	v := benchmarkPayload{
+		Name:    "order",
+		ID:      42,
+		Tags:    []string{"a", "b", "c"},
+		Details: map[string]interface{}{"customer": "c-1", "total": 99.5, "items": []int{1, 2, 3}},
+	}
+
+	data, err := json.Marshal(v)
+	require.NoError(b, err)
+
+	b.Run("Marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = json.Marshal(v)
+		}
+	})
+
+	b.Run("DecodeAndMarshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = decodeAndMarshal(data)
+		}
+	})
+
+	b.Run("CanonicalJSON", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = canonicalJSON(data)
+		}
+	})
+}
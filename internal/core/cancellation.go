@@ -0,0 +1,15 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/cschleiden/go-workflows/workflowerrors"
+)
+
+// Cancellation causes returned by Cause for the context of a canceled workflow instance.
+var (
+	ErrCanceledByUser   = errors.New("workflow canceled by user")
+	ErrCanceledByParent = errors.New("workflow canceled by parent")
+	ErrTimedOut         = &workflowerrors.TimeoutError{Message: "workflow timed out"}
+	ErrTerminated       = errors.New("workflow terminated")
+)
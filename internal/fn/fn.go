@@ -1,6 +1,7 @@
 package fn
 
 import (
+	"fmt"
 	"reflect"
 	"runtime"
 	"strings"
@@ -15,3 +16,15 @@ func Name(i interface{}) string {
 
 	return strings.TrimSuffix(fnName, "-fm")
 }
+
+// Source returns the file and line the given function is defined at.
+func Source(i interface{}) string {
+	f := runtime.FuncForPC(reflect.ValueOf(i).Pointer())
+	if f == nil {
+		return ""
+	}
+
+	file, line := f.FileLine(f.Entry())
+
+	return fmt.Sprintf("%s:%d", file, line)
+}
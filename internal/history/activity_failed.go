@@ -1,5 +1,55 @@
 package history
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/workflowerrors"
+)
+
+// ActivityTimeout identifies the timeout that failed an activity, if the failure was scheduled as a future event
+// by the executor instead of being reported by a worker.
+type ActivityTimeout int
+
+const (
+	ActivityTimeout_None ActivityTimeout = iota
+	ActivityTimeout_ScheduleToStart
+	ActivityTimeout_StartToClose
+	ActivityTimeout_Heartbeat
+)
+
 type ActivityFailedAttributes struct {
 	Reason string `json:"reason,omitempty"`
+
+	// Type is the type of the error returned by the activity, see activity.NewError
+	Type string `json:"type,omitempty"`
+
+	// HeartbeatDetails are the details of the last heartbeat recorded by the activity, see activity.RecordHeartbeat
+	HeartbeatDetails payload.Payload `json:"heartbeat_details,omitempty"`
+
+	// Timeout is set for failures scheduled by the executor when the activity timed out. They are ignored if the
+	// activity was resolved before, or, for schedule-to-start timeouts, if a worker started it in time.
+	Timeout ActivityTimeout `json:"timeout,omitempty"`
+}
+
+// NewActivityHeartbeatTimeoutEvent returns the future event that fails an activity if it doesn't record a heartbeat
+// before the given deadline. The event has a stable ID derived from the ID of the activity event, so the worker
+// executing the activity can move the deadline with every heartbeat, see backend.ActivityHeartbeatTimeoutRescheduler.
+func NewActivityHeartbeatTimeoutEvent(
+	timestamp, deadline time.Time, activityEventID string, scheduleEventID int64, name string, timeout time.Duration, details payload.Payload,
+) Event {
+	return NewPendingEvent(
+		timestamp,
+		EventType_ActivityFailed,
+		&ActivityFailedAttributes{
+			Reason:           fmt.Sprintf("activity %s did not record a heartbeat within %v", name, timeout),
+			Type:             workflowerrors.TimeoutErrorType,
+			HeartbeatDetails: details,
+			Timeout:          ActivityTimeout_Heartbeat,
+		},
+		EventID(activityEventID+"-heartbeat-timeout"),
+		ScheduleEventID(scheduleEventID),
+		VisibleAt(deadline),
+	)
 }
@@ -1,6 +1,8 @@
 package history
 
 import (
+	"time"
+
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/payload"
 )
@@ -11,4 +13,40 @@ type ActivityScheduledAttributes struct {
 	Inputs []payload.Payload `json:"inputs,omitempty"`
 
 	Metadata core.WorkflowMetadata `json:"metadata,omitempty"`
+
+	// Attempt is the retry attempt this activity was scheduled for, starting at 0
+	Attempt int `json:"attempt,omitempty"`
+
+	// Stream is the name of the signal intermediate results of a streaming activity are delivered as
+	Stream string `json:"stream,omitempty"`
+
+	// Queue is the queue the activity is dispatched to, empty for the default queue
+	Queue string `json:"queue,omitempty"`
+
+	// Labels are the labels a worker has to advertise to execute the activity
+	Labels []string `json:"labels,omitempty"`
+
+	// MaxAttempts is the maximum number of attempts configured in the retry options of the activity
+	MaxAttempts int `json:"max_attempts,omitempty"`
+
+	// RetryDeadline is the time after which the activity is not retried anymore, nil if retries are not
+	// limited by time
+	RetryDeadline *time.Time `json:"retry_deadline,omitempty"`
+
+	// CancellationRequested is true if the workflow instance had been canceled when the activity was scheduled,
+	// e.g., for cleanup activities scheduled with a disconnected context
+	CancellationRequested bool `json:"cancellation_requested,omitempty"`
+
+	// HeartbeatTimeout is the maximum time between heartbeats of the activity, 0 if heartbeats are not required
+	HeartbeatTimeout time.Duration `json:"heartbeat_timeout,omitempty"`
+
+	// HeartbeatDetails are the details of the last heartbeat recorded by the previous attempt of the activity
+	HeartbeatDetails payload.Payload `json:"heartbeat_details,omitempty"`
+
+	// ScheduleToStartTimeout is the maximum time between scheduling the activity and a worker starting it, 0 if
+	// not limited
+	ScheduleToStartTimeout time.Duration `json:"schedule_to_start_timeout,omitempty"`
+
+	// StartToCloseTimeout is the maximum time a single attempt of the activity may run, 0 if not limited
+	StartToCloseTimeout time.Duration `json:"start_to_close_timeout,omitempty"`
 }
@@ -0,0 +1,7 @@
+package history
+
+type ActivityStartedAttributes struct {
+	// ReschedulesHeartbeatTimeout is set if the worker executing the activity moves its heartbeat timeout with every
+	// heartbeat, see NewActivityHeartbeatTimeoutEvent. The executor only schedules heartbeat timeouts for such workers.
+	ReschedulesHeartbeatTimeout bool `json:"reschedules_heartbeat_timeout,omitempty"`
+}
@@ -0,0 +1,25 @@
+package history
+
+import "github.com/cschleiden/go-workflows/internal/payload"
+
+// CompactedAttributes summarize a resolved request, for example a scheduled activity, and its response. The
+// response is kept as a snapshot, so replaying the compacted history resolves the request the same way.
+type CompactedAttributes struct {
+	// Type is the type of the compacted request event, e.g., EventType_ActivityScheduled
+	Type EventType `json:"type,omitempty"`
+
+	// Name is the name of the activity, if the request scheduled one
+	Name string `json:"name,omitempty"`
+
+	Result payload.Payload `json:"result,omitempty"`
+
+	// Failed is true if the request failed with Error
+	Failed bool   `json:"failed,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	// ErrorType is the type of the error a failed activity returned, see activity.NewError
+	ErrorType string `json:"error_type,omitempty"`
+
+	// HeartbeatDetails are the details of the last heartbeat recorded by a failed activity
+	HeartbeatDetails payload.Payload `json:"heartbeat_details,omitempty"`
+}
@@ -0,0 +1,100 @@
+package history
+
+import "fmt"
+
+// Compact collapses resolved activity and timer requests in the given history whose response has a sequence id
+// up to checkpoint. Each pair of request and response is replaced by a single EventType_Compacted event, which
+// keeps the ID, sequence id, and position of the response. It returns the summary events and the sequence ids of
+// the request events they replace. Requests with more than one follow-up event, e.g., rescheduled or canceled
+// timers or started activities, and activities that failed with a timeout are not compacted.
+func Compact(events []Event, checkpoint int64) ([]Event, []int64, error) {
+	pairs := make(map[int64][]int)
+	for i := range events {
+		if events[i].ScheduleEventID == 0 || events[i].SequenceID > checkpoint {
+			continue
+		}
+
+		switch events[i].Type {
+		case EventType_ActivityScheduled, EventType_ActivityStarted, EventType_ActivityCompleted, EventType_ActivityFailed,
+			EventType_TimerScheduled, EventType_TimerFired, EventType_TimerCanceled, EventType_TimerRescheduled:
+			pairs[events[i].ScheduleEventID] = append(pairs[events[i].ScheduleEventID], i)
+		}
+	}
+
+	summaries := make([]Event, 0)
+	removed := make([]int64, 0)
+
+	for i := range events {
+		p := pairs[events[i].ScheduleEventID]
+		if len(p) != 2 || p[1] != i {
+			continue
+		}
+
+		request, response := &events[p[0]], &events[p[1]]
+
+		a, ok, err := compactedAttributes(request, response)
+		if err != nil {
+			return nil, nil, fmt.Errorf("compacting event %d: %w", request.SequenceID, err)
+		}
+
+		if !ok {
+			continue
+		}
+
+		summary := *response
+		summary.Type = EventType_Compacted
+		summary.SetAttributes(a)
+
+		summaries = append(summaries, summary)
+		removed = append(removed, request.SequenceID)
+	}
+
+	return summaries, removed, nil
+}
+
+func compactedAttributes(request, response *Event) (*CompactedAttributes, bool, error) {
+	switch {
+	case request.Type == EventType_ActivityScheduled && response.Type == EventType_ActivityCompleted:
+		ra, err := AttributesAs[*ActivityScheduledAttributes](request)
+		if err != nil {
+			return nil, false, err
+		}
+
+		a, err := AttributesAs[*ActivityCompletedAttributes](response)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return &CompactedAttributes{Type: request.Type, Name: ra.Name, Result: a.Result}, true, nil
+
+	case request.Type == EventType_ActivityScheduled && response.Type == EventType_ActivityFailed:
+		ra, err := AttributesAs[*ActivityScheduledAttributes](request)
+		if err != nil {
+			return nil, false, err
+		}
+
+		a, err := AttributesAs[*ActivityFailedAttributes](response)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if a.Timeout != ActivityTimeout_None {
+			// Timeouts are only applied if the activity is still pending when they fire, keep the full history
+			return nil, false, nil
+		}
+
+		return &CompactedAttributes{
+			Type:             request.Type,
+			Name:             ra.Name,
+			Failed:           true,
+			Error:            a.Reason,
+			ErrorType:        a.Type,
+			HeartbeatDetails: a.HeartbeatDetails,
+		}, true, nil
+
+	case request.Type == EventType_TimerScheduled && response.Type == EventType_TimerFired:
+		return &CompactedAttributes{Type: request.Type}, true, nil
+	}
+
+	return nil, false, nil
+}
@@ -0,0 +1,85 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompact(t *testing.T) {
+	now := time.Now()
+
+	events := []Event{
+		NewHistoryEvent(1, now, EventType_WorkflowExecutionStarted, &ExecutionStartedAttributes{Name: "wf"}),
+		NewHistoryEvent(2, now, EventType_ActivityScheduled, &ActivityScheduledAttributes{Name: "a"}, ScheduleEventID(1)),
+		NewHistoryEvent(3, now, EventType_TimerScheduled, &TimerScheduledAttributes{}, ScheduleEventID(2)),
+		NewHistoryEvent(4, now, EventType_ActivityScheduled, &ActivityScheduledAttributes{Name: "b"}, ScheduleEventID(3)),
+		NewHistoryEvent(5, now, EventType_TimerScheduled, &TimerScheduledAttributes{}, ScheduleEventID(4)),
+		NewHistoryEvent(6, now, EventType_ActivityCompleted, &ActivityCompletedAttributes{Result: payload.Payload(`42`)}, ScheduleEventID(1)),
+		NewHistoryEvent(7, now, EventType_TimerFired, &TimerFiredAttributes{}, ScheduleEventID(2)),
+		NewHistoryEvent(8, now, EventType_ActivityFailed, &ActivityFailedAttributes{Reason: "boom", Type: "Boom", HeartbeatDetails: payload.Payload(`"h"`)}, ScheduleEventID(3)),
+		NewHistoryEvent(9, now, EventType_TimerRescheduled, &TimerRescheduledAttributes{}, ScheduleEventID(4)),
+		NewHistoryEvent(10, now, EventType_TimerFired, &TimerFiredAttributes{}, ScheduleEventID(4)),
+	}
+
+	summaries, removed, err := Compact(events, 8)
+	require.NoError(t, err)
+
+	// The rescheduled timer is not compacted
+	require.Equal(t, []int64{2, 3, 4}, removed)
+	require.Len(t, summaries, 3)
+
+	require.Equal(t, events[5].ID, summaries[0].ID)
+	require.Equal(t, int64(6), summaries[0].SequenceID)
+	require.Equal(t, EventType_Compacted, summaries[0].Type)
+
+	a, err := AttributesAs[*CompactedAttributes](&summaries[0])
+	require.NoError(t, err)
+	require.Equal(t, &CompactedAttributes{Type: EventType_ActivityScheduled, Name: "a", Result: payload.Payload(`42`)}, a)
+
+	a, err = AttributesAs[*CompactedAttributes](&summaries[1])
+	require.NoError(t, err)
+	require.Equal(t, &CompactedAttributes{Type: EventType_TimerScheduled}, a)
+
+	a, err = AttributesAs[*CompactedAttributes](&summaries[2])
+	require.NoError(t, err)
+	require.Equal(t, &CompactedAttributes{
+		Type:             EventType_ActivityScheduled,
+		Name:             "b",
+		Failed:           true,
+		Error:            "boom",
+		ErrorType:        "Boom",
+		HeartbeatDetails: payload.Payload(`"h"`),
+	}, a)
+
+	// Responses after the checkpoint are not compacted
+	summaries, removed, err = Compact(events, 6)
+	require.NoError(t, err)
+	require.Equal(t, []int64{2}, removed)
+	require.Len(t, summaries, 1)
+}
+
+func TestCompact_ActivityTimeouts(t *testing.T) {
+	now := time.Now()
+
+	events := []Event{
+		NewHistoryEvent(1, now, EventType_WorkflowExecutionStarted, &ExecutionStartedAttributes{Name: "wf"}),
+		NewHistoryEvent(2, now, EventType_ActivityScheduled, &ActivityScheduledAttributes{Name: "a"}, ScheduleEventID(1)),
+		NewHistoryEvent(3, now, EventType_ActivityScheduled, &ActivityScheduledAttributes{Name: "b"}, ScheduleEventID(2)),
+		NewHistoryEvent(4, now, EventType_ActivityStarted, &ActivityStartedAttributes{}, ScheduleEventID(1)),
+		// The schedule-to-start timeout of a is ignored, a already started
+		NewHistoryEvent(5, now, EventType_ActivityFailed, &ActivityFailedAttributes{Reason: "timeout", Timeout: ActivityTimeout_ScheduleToStart}, ScheduleEventID(1)),
+		NewHistoryEvent(6, now, EventType_ActivityFailed, &ActivityFailedAttributes{Reason: "timeout", Timeout: ActivityTimeout_ScheduleToStart}, ScheduleEventID(2)),
+		NewHistoryEvent(7, now, EventType_ActivityCompleted, &ActivityCompletedAttributes{Result: payload.Payload(`42`)}, ScheduleEventID(1)),
+	}
+
+	// Started activities and timeouts are not compacted, whatever the checkpoint
+	for checkpoint := int64(1); checkpoint <= 7; checkpoint++ {
+		summaries, removed, err := Compact(events, checkpoint)
+		require.NoError(t, err)
+		require.Empty(t, removed)
+		require.Empty(t, summaries)
+	}
+}
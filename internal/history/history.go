@@ -56,6 +56,19 @@ const (
 
 	// Signal other workflow
 	EventType_SignalWorkflow
+
+	// Timer has been rescheduled to fire at a different time
+	EventType_TimerRescheduled
+
+	// User-defined marker, for example a business checkpoint
+	EventType_MarkerRecorded
+
+	// Summary of a resolved request and its response, replacing both after the history has been compacted
+	EventType_Compacted
+
+	// Activity task has been started by a worker. Only recorded for activities with a schedule-to-start or
+	// start-to-close timeout.
+	EventType_ActivityStarted
 )
 
 func (et EventType) String() string {
@@ -94,6 +107,8 @@ func (et EventType) String() string {
 		return "TimerFired"
 	case EventType_TimerCanceled:
 		return "TimerCanceled"
+	case EventType_TimerRescheduled:
+		return "TimerRescheduled"
 
 	case EventType_SignalReceived:
 		return "SignalReceived"
@@ -104,13 +119,34 @@ func (et EventType) String() string {
 	case EventType_SignalWorkflow:
 		return "WorkflowSignalRequested"
 
+	case EventType_MarkerRecorded:
+		return "MarkerRecorded"
+
+	case EventType_Compacted:
+		return "Compacted"
+
+	case EventType_ActivityStarted:
+		return "ActivityStarted"
+
 	default:
 		return "Unknown"
 	}
 }
 
+// ParseEventType returns the event type with the given name, as returned by EventType.String.
+func ParseEventType(name string) (EventType, bool) {
+	for et := EventType_WorkflowExecutionStarted; et <= EventType_ActivityStarted; et++ {
+		if et.String() == name {
+			return et, true
+		}
+	}
+
+	return 0, false
+}
+
 type Event struct {
-	// ID is a unique identifier for this event
+	// ID is a globally unique identifier for this event. Backends deduplicate pending events of an instance by
+	// their ID, so an event that is delivered more than once is only added to the history once.
 	ID string `json:"id,omitempty"`
 
 	// SequenceID is a monotonically increasing sequence number this event. It's only set for events that have
@@ -126,10 +162,16 @@ type Event struct {
 	// completion/failure event are the same.
 	ScheduleEventID int64 `json:"seid,omitempty"`
 
-	// Attributes are event type specific attributes
-	Attributes interface{} `json:"attr,omitempty"`
+	// attributes are event type specific attributes. Events loaded from a backend keep their attributes
+	// serialized in rawAttributes until they are first accessed via Attributes.
+	attributes    interface{}
+	rawAttributes []byte
 
 	VisibleAt *time.Time `json:"vat,omitempty"`
+
+	// CausedBy is the ID of the event that led to this event being generated. For example, events
+	// created while executing a workflow task point to that task's WorkflowTaskStarted event.
+	CausedBy string `json:"cb,omitempty"`
 }
 
 func (e Event) String() string {
@@ -144,19 +186,34 @@ func ScheduleEventID(scheduleEventID int64) HistoryEventOption {
 	}
 }
 
+// EventID sets the ID of the event, instead of a random one.
+func EventID(id string) HistoryEventOption {
+	return func(e *Event) {
+		e.ID = id
+	}
+}
+
+func CausedBy(eventID string) HistoryEventOption {
+	return func(e *Event) {
+		e.CausedBy = eventID
+	}
+}
+
 func VisibleAt(visibleAt time.Time) HistoryEventOption {
 	return func(e *Event) {
 		e.VisibleAt = &visibleAt
 	}
 }
 
+// NewHistoryEvent creates a new event with a random UUID as its ID. Use EventID to set a stable ID, e.g., when
+// the event might be delivered more than once.
 func NewHistoryEvent(sequenceID int64, timestamp time.Time, eventType EventType, attributes interface{}, opts ...HistoryEventOption) Event {
 	e := Event{
 		ID:         uuid.NewString(),
 		SequenceID: sequenceID,
 		Type:       eventType,
 		Timestamp:  timestamp,
-		Attributes: attributes,
+		attributes: attributes,
 	}
 
 	for _, opt := range opts {
@@ -166,10 +223,13 @@ func NewHistoryEvent(sequenceID int64, timestamp time.Time, eventType EventType,
 	return e
 }
 
+// NewPendingEvent creates a new event that has not been executed yet, see NewHistoryEvent.
 func NewPendingEvent(timestamp time.Time, eventType EventType, attributes interface{}, opts ...HistoryEventOption) Event {
 	return NewHistoryEvent(0, timestamp, eventType, attributes, opts...)
 }
 
-func NewWorkflowCancellationEvent(timestamp time.Time) Event {
-	return NewPendingEvent(timestamp, EventType_WorkflowExecutionCanceled, &ExecutionCanceledAttributes{})
+func NewWorkflowCancellationEvent(timestamp time.Time, reason CancellationReason) Event {
+	return NewPendingEvent(timestamp, EventType_WorkflowExecutionCanceled, &ExecutionCanceledAttributes{
+		Reason: reason,
+	})
 }
@@ -0,0 +1,8 @@
+package history
+
+import "github.com/cschleiden/go-workflows/internal/payload"
+
+type MarkerRecordedAttributes struct {
+	Name    string          `json:"name,omitempty"`
+	Details payload.Payload `json:"details,omitempty"`
+}
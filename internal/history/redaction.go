@@ -0,0 +1,105 @@
+package history
+
+import "github.com/cschleiden/go-workflows/internal/payload"
+
+// RedactPayloads returns a copy of the given event with every payload contained in its attributes passed
+// through redact. The original event is not modified. Attributes that cannot be deserialized are dropped.
+func RedactPayloads(e Event, redact func(payload.Payload) payload.Payload) Event {
+	redactAll := func(payloads []payload.Payload) []payload.Payload {
+		if payloads == nil {
+			return nil
+		}
+
+		r := make([]payload.Payload, len(payloads))
+		for i, p := range payloads {
+			r[i] = redact(p)
+		}
+
+		return r
+	}
+
+	attributes, err := e.Attributes()
+	if err != nil {
+		e.SetAttributes(nil)
+		return e
+	}
+
+	switch a := attributes.(type) {
+	case *ExecutionStartedAttributes:
+		c := *a
+		c.Inputs = redactAll(a.Inputs)
+		e.SetAttributes(&c)
+
+	case *ExecutionCompletedAttributes:
+		c := *a
+		c.Result = redact(a.Result)
+		e.SetAttributes(&c)
+
+	case *ActivityScheduledAttributes:
+		c := *a
+		c.Inputs = redactAll(a.Inputs)
+		if a.HeartbeatDetails != nil {
+			c.HeartbeatDetails = redact(a.HeartbeatDetails)
+		}
+		e.SetAttributes(&c)
+
+	case *ActivityFailedAttributes:
+		if a.HeartbeatDetails != nil {
+			c := *a
+			c.HeartbeatDetails = redact(a.HeartbeatDetails)
+			e.SetAttributes(&c)
+		}
+
+	case *ActivityCompletedAttributes:
+		c := *a
+		c.Result = redact(a.Result)
+		e.SetAttributes(&c)
+
+	case *SignalReceivedAttributes:
+		c := *a
+		c.Arg = redact(a.Arg)
+		e.SetAttributes(&c)
+
+	case *SideEffectResultAttributes:
+		c := *a
+		c.Result = redact(a.Result)
+		e.SetAttributes(&c)
+
+	case *SubWorkflowScheduledAttributes:
+		c := *a
+		c.Inputs = redactAll(a.Inputs)
+		e.SetAttributes(&c)
+
+	case *SubWorkflowCompletedAttributes:
+		c := *a
+		c.Result = redact(a.Result)
+		e.SetAttributes(&c)
+
+	case *SignalWorkflowAttributes:
+		c := *a
+		c.Arg = redact(a.Arg)
+		e.SetAttributes(&c)
+
+	case *MarkerRecordedAttributes:
+		c := *a
+		c.Details = redact(a.Details)
+		e.SetAttributes(&c)
+
+	case *ExecutionCanceledAttributes:
+		if a.Details != nil {
+			c := *a
+			c.Details = redact(a.Details)
+			e.SetAttributes(&c)
+		}
+
+	case *CompactedAttributes:
+		c := *a
+		c.Result = redact(a.Result)
+		if a.HeartbeatDetails != nil {
+			c.HeartbeatDetails = redact(a.HeartbeatDetails)
+		}
+		e.SetAttributes(&c)
+	}
+
+	return e
+}
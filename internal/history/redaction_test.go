@@ -0,0 +1,91 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactPayloads(t *testing.T) {
+	redact := func(p payload.Payload) payload.Payload {
+		return payload.Payload(`"***"`)
+	}
+
+	attr := &ExecutionStartedAttributes{
+		Name:   "my-workflow",
+		Inputs: []payload.Payload{payload.Payload(`"secret"`), payload.Payload(`42`)},
+	}
+	event := NewHistoryEvent(1, time.Now(), EventType_WorkflowExecutionStarted, attr)
+
+	redacted := RedactPayloads(event, redact)
+
+	a, err := redacted.Attributes()
+	require.NoError(t, err)
+	ra := a.(*ExecutionStartedAttributes)
+	require.Equal(t, "my-workflow", ra.Name)
+	require.Equal(t, []payload.Payload{payload.Payload(`"***"`), payload.Payload(`"***"`)}, ra.Inputs)
+
+	// Original event is not modified
+	require.Equal(t, payload.Payload(`"secret"`), attr.Inputs[0])
+	require.Equal(t, event.ID, redacted.ID)
+}
+
+func TestRedactPayloads_NoPayloads(t *testing.T) {
+	event := NewHistoryEvent(1, time.Now(), EventType_TimerFired, &TimerFiredAttributes{})
+
+	redacted := RedactPayloads(event, func(p payload.Payload) payload.Payload {
+		require.FailNow(t, "should not be called")
+		return p
+	})
+
+	require.Equal(t, event, redacted)
+}
+
+func TestRedactPayloads_HeartbeatDetails(t *testing.T) {
+	redact := func(p payload.Payload) payload.Payload {
+		return payload.Payload(`"***"`)
+	}
+
+	scheduled := NewHistoryEvent(1, time.Now(), EventType_ActivityScheduled, &ActivityScheduledAttributes{
+		Name:             "activity",
+		Inputs:           []payload.Payload{payload.Payload(`"secret"`)},
+		HeartbeatDetails: payload.Payload(`"progress"`),
+	})
+
+	redacted := RedactPayloads(scheduled, redact)
+	a, err := redacted.Attributes()
+	require.NoError(t, err)
+	require.Equal(t, []payload.Payload{payload.Payload(`"***"`)}, a.(*ActivityScheduledAttributes).Inputs)
+	require.Equal(t, payload.Payload(`"***"`), a.(*ActivityScheduledAttributes).HeartbeatDetails)
+
+	failed := NewHistoryEvent(2, time.Now(), EventType_ActivityFailed, &ActivityFailedAttributes{
+		Reason:           "failed",
+		HeartbeatDetails: payload.Payload(`"progress"`),
+	})
+
+	redacted = RedactPayloads(failed, redact)
+	a, err = redacted.Attributes()
+	require.NoError(t, err)
+	require.Equal(t, "failed", a.(*ActivityFailedAttributes).Reason)
+	require.Equal(t, payload.Payload(`"***"`), a.(*ActivityFailedAttributes).HeartbeatDetails)
+
+	// Failures without heartbeat details are left alone
+	noDetails := NewHistoryEvent(3, time.Now(), EventType_ActivityFailed, &ActivityFailedAttributes{Reason: "failed"})
+	redacted = RedactPayloads(noDetails, redact)
+	a, err = redacted.Attributes()
+	require.NoError(t, err)
+	require.Nil(t, a.(*ActivityFailedAttributes).HeartbeatDetails)
+
+	compacted := NewHistoryEvent(4, time.Now(), EventType_Compacted, &CompactedAttributes{
+		Type:             EventType_ActivityScheduled,
+		Failed:           true,
+		HeartbeatDetails: payload.Payload(`"progress"`),
+	})
+
+	redacted = RedactPayloads(compacted, redact)
+	a, err = redacted.Attributes()
+	require.NoError(t, err)
+	require.Equal(t, payload.Payload(`"***"`), a.(*CompactedAttributes).HeartbeatDetails)
+}
@@ -0,0 +1,33 @@
+package history
+
+import "github.com/cschleiden/go-workflows/internal/payload"
+
+// RewritePayloads returns a copy of the given event with every payload contained in its attributes passed
+// through rewrite. changed is true if rewrite changed any of the payloads. The original event is not
+// modified.
+func RewritePayloads(e Event, rewrite func(payload.Payload) (payload.Payload, bool, error)) (Event, bool, error) {
+	if _, err := e.Attributes(); err != nil {
+		return e, false, err
+	}
+
+	var err error
+	changed := false
+
+	r := RedactPayloads(e, func(p payload.Payload) payload.Payload {
+		if err != nil {
+			return p
+		}
+
+		np, c, rerr := rewrite(p)
+		if rerr != nil {
+			err = rerr
+			return p
+		}
+
+		changed = changed || c
+
+		return np
+	})
+
+	return r, changed, err
+}
@@ -3,12 +3,91 @@ package history
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 )
 
+// Attributes returns the event type specific attributes of the event. Serialized attributes are
+// deserialized on first access and cached on the event.
+func (e *Event) Attributes() (interface{}, error) {
+	if e.attributes == nil && e.rawAttributes != nil {
+		attributes, err := DeserializeAttributes(e.Type, e.rawAttributes)
+		if err != nil {
+			return nil, fmt.Errorf("deserializing attributes of event %v: %w", e.ID, err)
+		}
+
+		e.attributes = attributes
+		e.rawAttributes = nil
+	}
+
+	return e.attributes, nil
+}
+
+// AttributesAs returns the attributes of the given event as T, deserializing them if necessary.
+func AttributesAs[T any](e *Event) (T, error) {
+	var r T
+
+	a, err := e.Attributes()
+	if err != nil {
+		return r, err
+	}
+
+	r, ok := a.(T)
+	if !ok {
+		return r, fmt.Errorf("unexpected attributes %T for event type %v", a, e.Type)
+	}
+
+	return r, nil
+}
+
+// SetAttributes replaces the attributes of the event.
+func (e *Event) SetAttributes(attributes interface{}) {
+	e.attributes = attributes
+	e.rawAttributes = nil
+}
+
+// SetSerializedAttributes replaces the attributes of the event with serialized attributes, which are only
+// deserialized when they are accessed.
+func (e *Event) SetSerializedAttributes(attributes []byte) {
+	e.attributes = nil
+	e.rawAttributes = attributes
+}
+
+// SerializedAttributes returns the serialized attributes of the event. If the attributes have not been
+// accessed yet, they are returned without a round trip through deserialization.
+func (e *Event) SerializedAttributes() ([]byte, error) {
+	if e.attributes == nil && e.rawAttributes != nil {
+		return e.rawAttributes, nil
+	}
+
+	return SerializeAttributes(e.attributes)
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	type Aevent Event
+	a := &struct {
+		// Has to match the struct tag in UnmarshalJSON
+		Attributes json.RawMessage `json:"attr,omitempty"`
+		*Aevent
+	}{
+		Aevent: (*Aevent)(&e),
+	}
+
+	if e.attributes != nil || e.rawAttributes != nil {
+		attributes, err := e.SerializedAttributes()
+		if err != nil {
+			return nil, err
+		}
+
+		a.Attributes = attributes
+	}
+
+	return json.Marshal(a)
+}
+
 func (e *Event) UnmarshalJSON(data []byte) error {
 	type Aevent Event
 	a := &struct {
-		// Attributes allows us to defer unmarshaling the events. Has to match the struct tag in Event
+		// Attributes allows us to defer unmarshaling the events. Has to match the struct tag in MarshalJSON
 		Attributes json.RawMessage `json:"attr,omitempty"`
 		*Aevent
 	}{}
@@ -18,13 +97,10 @@ func (e *Event) UnmarshalJSON(data []byte) error {
 	}
 
 	*e = *(*Event)(a.Aevent)
-	attributes, err := DeserializeAttributes(e.Type, a.Attributes)
-	if err != nil {
-		return err
+	if len(a.Attributes) > 0 && string(a.Attributes) != "null" {
+		e.SetSerializedAttributes(a.Attributes)
 	}
 
-	e.Attributes = attributes
-
 	return nil
 }
 
@@ -63,6 +139,8 @@ func DeserializeAttributes(eventType EventType, attributes []byte) (attr interfa
 		attr = &TimerFiredAttributes{}
 	case EventType_TimerCanceled:
 		attr = &TimerCanceledAttributes{}
+	case EventType_TimerRescheduled:
+		attr = &TimerRescheduledAttributes{}
 
 	case EventType_SubWorkflowScheduled:
 		attr = &SubWorkflowScheduledAttributes{}
@@ -76,6 +154,15 @@ func DeserializeAttributes(eventType EventType, attributes []byte) (attr interfa
 	case EventType_SignalWorkflow:
 		attr = &SignalWorkflowAttributes{}
 
+	case EventType_MarkerRecorded:
+		attr = &MarkerRecordedAttributes{}
+
+	case EventType_Compacted:
+		attr = &CompactedAttributes{}
+
+	case EventType_ActivityStarted:
+		attr = &ActivityStartedAttributes{}
+
 	default:
 		return nil, errors.New("unknown event type when deserializing attributes")
 	}
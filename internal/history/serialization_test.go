@@ -24,5 +24,42 @@ func TestRoundtripJSON(t *testing.T) {
 	require.Equal(t, event.SequenceID, event2.SequenceID)
 	require.Equal(t, event.Type, event2.Type)
 	require.Equal(t, event.VisibleAt, event2.VisibleAt)
-	require.Equal(t, event.Attributes, event2.Attributes)
+
+	a, err := event.Attributes()
+	require.NoError(t, err)
+	a2, err := event2.Attributes()
+	require.NoError(t, err)
+	require.Equal(t, a, a2)
+}
+
+func TestSerializedAttributes_DeserializedOnAccess(t *testing.T) {
+	raw, err := SerializeAttributes(&ActivityScheduledAttributes{Name: "my-activity"})
+	require.NoError(t, err)
+
+	event := NewHistoryEvent(42, time.Now(), EventType_ActivityScheduled, nil)
+	event.SetSerializedAttributes(raw)
+	require.Nil(t, event.attributes)
+
+	// Serialized attributes are passed through without deserializing them
+	sa, err := event.SerializedAttributes()
+	require.NoError(t, err)
+	require.Equal(t, raw, sa)
+	require.Nil(t, event.attributes)
+
+	a, err := event.Attributes()
+	require.NoError(t, err)
+	require.Equal(t, &ActivityScheduledAttributes{Name: "my-activity"}, a)
+
+	// Subsequent accesses return the cached attributes
+	a2, err := event.Attributes()
+	require.NoError(t, err)
+	require.Same(t, a, a2)
+}
+
+func TestSerializedAttributes_InvalidAttributes(t *testing.T) {
+	event := NewHistoryEvent(42, time.Now(), EventType_ActivityScheduled, nil)
+	event.SetSerializedAttributes([]byte("{"))
+
+	_, err := event.Attributes()
+	require.Error(t, err)
 }
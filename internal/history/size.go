@@ -0,0 +1,72 @@
+package history
+
+import "github.com/cschleiden/go-workflows/internal/payload"
+
+// PayloadSize returns the number of payload bytes contained in the attributes of the given event. For
+// attributes that have not been deserialized yet, the size of the serialized attributes is returned.
+func PayloadSize(e Event) int {
+	if e.attributes == nil && e.rawAttributes != nil {
+		return len(e.rawAttributes)
+	}
+
+	size := 0
+
+	visitPayloads(e.attributes, func(p payload.Payload) {
+		size += len(p)
+	})
+
+	return size
+}
+
+// visitPayloads calls visit for every payload contained in the given attributes. Unlike RedactPayloads, it
+// doesn't copy the attributes, so it can be used on hot paths.
+func visitPayloads(attributes interface{}, visit func(payload.Payload)) {
+	switch a := attributes.(type) {
+	case *ExecutionStartedAttributes:
+		for _, p := range a.Inputs {
+			visit(p)
+		}
+
+	case *ExecutionCompletedAttributes:
+		visit(a.Result)
+
+	case *ActivityScheduledAttributes:
+		for _, p := range a.Inputs {
+			visit(p)
+		}
+		visit(a.HeartbeatDetails)
+
+	case *ActivityFailedAttributes:
+		visit(a.HeartbeatDetails)
+
+	case *ActivityCompletedAttributes:
+		visit(a.Result)
+
+	case *SignalReceivedAttributes:
+		visit(a.Arg)
+
+	case *SideEffectResultAttributes:
+		visit(a.Result)
+
+	case *SubWorkflowScheduledAttributes:
+		for _, p := range a.Inputs {
+			visit(p)
+		}
+
+	case *SubWorkflowCompletedAttributes:
+		visit(a.Result)
+
+	case *SignalWorkflowAttributes:
+		visit(a.Arg)
+
+	case *MarkerRecordedAttributes:
+		visit(a.Details)
+
+	case *ExecutionCanceledAttributes:
+		visit(a.Details)
+
+	case *CompactedAttributes:
+		visit(a.Result)
+		visit(a.HeartbeatDetails)
+	}
+}
@@ -0,0 +1,43 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PayloadSize(t *testing.T) {
+	events := []Event{
+		NewHistoryEvent(1, time.Now(), EventType_WorkflowExecutionStarted, &ExecutionStartedAttributes{
+			Inputs: []payload.Payload{payload.Payload(`"a"`), payload.Payload(`42`)},
+		}),
+		NewHistoryEvent(2, time.Now(), EventType_ActivityScheduled, &ActivityScheduledAttributes{
+			Inputs:           []payload.Payload{payload.Payload(`"input"`)},
+			HeartbeatDetails: payload.Payload(`"details"`),
+		}),
+		NewHistoryEvent(3, time.Now(), EventType_ActivityFailed, &ActivityFailedAttributes{Reason: "failed"}),
+		NewHistoryEvent(4, time.Now(), EventType_Compacted, &CompactedAttributes{
+			Result:           payload.Payload(`"result"`),
+			HeartbeatDetails: payload.Payload(`"details"`),
+		}),
+		NewHistoryEvent(5, time.Now(), EventType_TimerFired, &TimerFiredAttributes{}),
+	}
+
+	for _, e := range events {
+		// Counts the same payloads that are redacted
+		want := 0
+		RedactPayloads(e, func(p payload.Payload) payload.Payload {
+			want += len(p)
+			return p
+		})
+
+		require.Equal(t, want, PayloadSize(e), e.Type.String())
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		PayloadSize(events[1])
+	})
+	require.Zero(t, allocs)
+}
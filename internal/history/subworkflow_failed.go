@@ -2,4 +2,7 @@ package history
 
 type SubWorkflowFailedAttributes struct {
 	Error string `json:"error,omitempty"`
+
+	// ErrorType is the type of the error the sub-workflow failed with, if any, see workflowerrors.Type
+	ErrorType string `json:"error_type,omitempty"`
 }
@@ -13,4 +13,7 @@ type SubWorkflowScheduledAttributes struct {
 	Inputs []payload.Payload `json:"inputs,omitempty"`
 
 	Metadata *core.WorkflowMetadata `json:"metadata,omitempty"`
+
+	// Version is the version of the workflow definition the sub-workflow is pinned to, if any
+	Version string `json:"version,omitempty"`
 }
@@ -0,0 +1,7 @@
+package history
+
+import "time"
+
+type TimerRescheduledAttributes struct {
+	At time.Time `json:"at,omitempty"`
+}
@@ -1,4 +1,23 @@
 package history
 
+import "github.com/cschleiden/go-workflows/internal/payload"
+
+// CancellationReason describes why a workflow instance was canceled.
+type CancellationReason int
+
+const (
+	// CancellationReason_User is used for instances canceled via the client. It's the zero value, so events
+	// recorded before reasons were tracked are treated as user cancellations.
+	CancellationReason_User CancellationReason = iota
+	CancellationReason_Parent
+	CancellationReason_Timeout
+	CancellationReason_Terminate
+)
+
 type ExecutionCanceledAttributes struct {
+	Reason CancellationReason `json:"reason,omitempty"`
+
+	// Message and Details are optionally attached by the client canceling the instance.
+	Message string          `json:"message,omitempty"`
+	Details payload.Payload `json:"details,omitempty"`
 }
@@ -5,4 +5,11 @@ import "github.com/cschleiden/go-workflows/internal/payload"
 type ExecutionCompletedAttributes struct {
 	Result payload.Payload `json:"result,omitempty"`
 	Error  string          `json:"error,omitempty"`
+
+	// ErrorType is the type of the error the workflow failed with, if any, see workflowerrors.Type
+	ErrorType string `json:"error_type,omitempty"`
+
+	// ContinuedAsNew is the execution ID of the new execution started for the instance, if the workflow
+	// continued as new
+	ContinuedAsNew string `json:"continued_as_new,omitempty"`
 }
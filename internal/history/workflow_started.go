@@ -1,6 +1,8 @@
 package history
 
 import (
+	"time"
+
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/payload"
 )
@@ -11,4 +13,27 @@ type ExecutionStartedAttributes struct {
 	Metadata *core.WorkflowMetadata `json:"metadata,omitempty"`
 
 	Inputs []payload.Payload `json:"inputs,omitempty"`
+
+	// Attempt is the retry attempt this sub-workflow instance was started for, starting at 0
+	Attempt int `json:"attempt,omitempty"`
+
+	// DefinitionChecksum identifies the workflow definition the instance was started with. It's recorded
+	// by the worker executing the instance for the first time.
+	DefinitionChecksum string `json:"definition_checksum,omitempty"`
+
+	// Version pins the instance to workers that registered the workflow with this version. Workers with a
+	// different version leave its tasks to other workers.
+	Version string `json:"version,omitempty"`
+
+	// RandomSeed seeds the deterministic random number generator of the execution. It's recorded by the worker
+	// executing the instance for the first time.
+	RandomSeed int64 `json:"random_seed,omitempty"`
+
+	// ExecutionTimeout limits how long the execution may run. When it elapses, the execution is canceled
+	// with CancellationReason_Timeout.
+	ExecutionTimeout time.Duration `json:"execution_timeout,omitempty"`
+
+	// DerivedInstanceID is set for sub-workflows whose instance ID was not set in their options. Backends fail such
+	// sub-workflows in their parent if the instance ID is already taken.
+	DerivedInstanceID bool `json:"derived_instance_id,omitempty"`
 }
@@ -0,0 +1,113 @@
+// Package historycache reads and updates workflow histories through a backend.HistoryCache for the SQL backends.
+// Errors of the cache are logged and never fail the backend operation, the database stays the source of truth.
+package historycache
+
+import (
+	"context"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/log"
+	"github.com/cschleiden/go-workflows/metrics"
+)
+
+// Get returns the history of the given instance after lastSequenceID from the cache. If it's not cached, the
+// history is read using load, and cached if it's the full history. latest returns the sequence ID of the last event
+// in the history of the instance in the database. Cached histories not ending with that event are stale, e.g.,
+// because invalidating them failed, and are treated as a miss.
+func Get(
+	ctx context.Context, cache backend.HistoryCache, logger log.Logger, m metrics.Client,
+	instanceID string, lastSequenceID *int64, latest func() (int64, error), load func() ([]history.Event, error),
+) ([]history.Event, error) {
+	if cache == nil {
+		return load()
+	}
+
+	var after int64
+	if lastSequenceID != nil {
+		after = *lastSequenceID
+	}
+
+	events, token, ok, err := cache.Get(ctx, instanceID, after)
+	if err != nil {
+		logger.Warn("Could not read history from cache", "instance_id", instanceID, "error", err)
+	} else if ok {
+		cachedLast := after
+		if len(events) > 0 {
+			cachedLast = events[len(events)-1].SequenceID
+		}
+
+		last, err := latest()
+		if err != nil {
+			return nil, err
+		}
+
+		if cachedLast == last {
+			m.Counter(metrickeys.BackendHistoryCacheHit, metrics.Tags{}, 1)
+			return events, nil
+		}
+
+		logger.Warn("Cached history is stale, invalidating", "instance_id", instanceID, "cached_sequence_id", cachedLast, "sequence_id", last)
+
+		Invalidate(ctx, cache, logger, instanceID)
+	}
+
+	m.Counter(metrickeys.BackendHistoryCacheMiss, metrics.Tags{}, 1)
+
+	h, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the full history can be cached
+	if after == 0 && len(h) > 0 {
+		if err := cache.Put(ctx, instanceID, h, token); err != nil {
+			logger.Warn("Could not add history to cache", "instance_id", instanceID, "error", err)
+		}
+	}
+
+	return h, nil
+}
+
+// Append adds the given events, which have been appended to the history of the instance, to the cache. The history
+// of finished instances is removed from the cache.
+func Append(ctx context.Context, cache backend.HistoryCache, logger log.Logger, instanceID string, events []history.Event, finished bool) {
+	if cache == nil {
+		return
+	}
+
+	if finished {
+		Invalidate(ctx, cache, logger, instanceID)
+		return
+	}
+
+	if len(events) == 0 {
+		return
+	}
+
+	if err := cache.Append(ctx, instanceID, events); err != nil {
+		logger.Error("Could not append history events to cache, invalidating", "instance_id", instanceID, "error", err)
+
+		Invalidate(ctx, cache, logger, instanceID)
+	}
+}
+
+// Invalidate removes the histories of the given instances from the cache.
+func Invalidate(ctx context.Context, cache backend.HistoryCache, logger log.Logger, instanceIDs ...string) {
+	if cache == nil {
+		return
+	}
+
+	invalidated := make(map[string]bool, len(instanceIDs))
+	for _, instanceID := range instanceIDs {
+		if invalidated[instanceID] {
+			continue
+		}
+		invalidated[instanceID] = true
+
+		if err := cache.Invalidate(ctx, instanceID); err != nil {
+			logger.Error("Could not invalidate cached history", "instance_id", instanceID, "error", err)
+		}
+	}
+}
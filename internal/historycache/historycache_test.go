@@ -0,0 +1,207 @@
+package historycache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/logger"
+	mi "github.com/cschleiden/go-workflows/internal/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryCache struct {
+	histories   map[string][]history.Event
+	generations map[string]int64
+	err         error
+
+	invalidateErr error
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{
+		histories:   make(map[string][]history.Event),
+		generations: make(map[string]int64),
+	}
+}
+
+func (c *memoryCache) Get(ctx context.Context, instanceID string, afterSequenceID int64) ([]history.Event, int64, bool, error) {
+	if c.err != nil {
+		return nil, 0, false, c.err
+	}
+
+	h, ok := c.histories[instanceID]
+	if !ok {
+		return nil, c.generations[instanceID], false, nil
+	}
+
+	return h[afterSequenceID:], c.generations[instanceID], true, nil
+}
+
+func (c *memoryCache) Put(ctx context.Context, instanceID string, events []history.Event, token int64) error {
+	if c.generations[instanceID] == token {
+		c.histories[instanceID] = events
+	}
+
+	return c.err
+}
+
+func (c *memoryCache) Append(ctx context.Context, instanceID string, events []history.Event) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	c.generations[instanceID]++
+	if h, ok := c.histories[instanceID]; ok {
+		c.histories[instanceID] = append(h, events...)
+	}
+
+	return nil
+}
+
+func (c *memoryCache) Invalidate(ctx context.Context, instanceID string) error {
+	if c.invalidateErr != nil {
+		return c.invalidateErr
+	}
+
+	c.generations[instanceID]++
+	delete(c.histories, instanceID)
+
+	return nil
+}
+
+func events(from, to int64) []history.Event {
+	r := make([]history.Event, 0)
+	for i := from; i <= to; i++ {
+		r = append(r, history.NewHistoryEvent(i, time.Now(), history.EventType_TimerFired, &history.TimerFiredAttributes{}))
+	}
+
+	return r
+}
+
+func latest(sequenceID int64) func() (int64, error) {
+	return func() (int64, error) {
+		return sequenceID, nil
+	}
+}
+
+func Test_Get(t *testing.T) {
+	ctx := context.Background()
+	l := logger.NewDefaultLogger()
+	m := mi.NewNoopMetricsClient()
+
+	t.Run("reads through the cache", func(t *testing.T) {
+		c := newMemoryCache()
+
+		loads := 0
+		load := func() ([]history.Event, error) {
+			loads++
+			return events(1, 3), nil
+		}
+
+		h, err := Get(ctx, c, l, m, "i", nil, latest(3), load)
+		require.NoError(t, err)
+		require.Len(t, h, 3)
+		require.Equal(t, 1, loads)
+
+		after := int64(1)
+		h, err = Get(ctx, c, l, m, "i", &after, latest(3), load)
+		require.NoError(t, err)
+		require.Len(t, h, 2)
+		require.Equal(t, int64(2), h[0].SequenceID)
+		require.Equal(t, 1, loads)
+	})
+
+	t.Run("only caches full histories", func(t *testing.T) {
+		c := newMemoryCache()
+
+		after := int64(2)
+		_, err := Get(ctx, c, l, m, "i", &after, latest(4), func() ([]history.Event, error) {
+			return events(3, 4), nil
+		})
+		require.NoError(t, err)
+		require.Empty(t, c.histories)
+	})
+
+	t.Run("reads from the database when the cache fails", func(t *testing.T) {
+		c := newMemoryCache()
+		c.err = errors.New("connection refused")
+
+		h, err := Get(ctx, c, l, m, "i", nil, latest(2), func() ([]history.Event, error) {
+			return events(1, 2), nil
+		})
+		require.NoError(t, err)
+		require.Len(t, h, 2)
+	})
+
+	t.Run("treats stale histories as a miss", func(t *testing.T) {
+		c := newMemoryCache()
+		c.histories["i"] = events(1, 2)
+
+		// Appending fails, and so does invalidating the cached history
+		c.err = errors.New("timeout")
+		c.invalidateErr = errors.New("timeout")
+		Append(ctx, c, l, "i", events(3, 3), false)
+		require.Len(t, c.histories["i"], 2)
+
+		c.err = nil
+
+		loads := 0
+		h, err := Get(ctx, c, l, m, "i", nil, latest(3), func() ([]history.Event, error) {
+			loads++
+			return events(1, 3), nil
+		})
+		require.NoError(t, err)
+		require.Len(t, h, 3)
+		require.Equal(t, 1, loads)
+
+		// The stale history has been replaced
+		require.Len(t, c.histories["i"], 3)
+
+		after := int64(2)
+		h, err = Get(ctx, c, l, m, "i", &after, latest(3), func() ([]history.Event, error) {
+			loads++
+			return events(3, 3), nil
+		})
+		require.NoError(t, err)
+		require.Len(t, h, 1)
+		require.Equal(t, int64(3), h[0].SequenceID)
+		require.Equal(t, 1, loads)
+	})
+
+	t.Run("invalidates stale histories", func(t *testing.T) {
+		c := newMemoryCache()
+		c.histories["i"] = events(1, 2)
+
+		after := int64(1)
+		h, err := Get(ctx, c, l, m, "i", &after, latest(3), func() ([]history.Event, error) {
+			return events(2, 3), nil
+		})
+		require.NoError(t, err)
+		require.Len(t, h, 2)
+		require.NotContains(t, c.histories, "i")
+	})
+}
+
+func Test_Append(t *testing.T) {
+	ctx := context.Background()
+	l := logger.NewDefaultLogger()
+
+	c := newMemoryCache()
+	c.histories["i"] = events(1, 2)
+
+	Append(ctx, c, l, "i", events(3, 3), false)
+	require.Len(t, c.histories["i"], 3)
+
+	// Histories of finished instances are removed
+	Append(ctx, c, l, "i", events(4, 4), true)
+	require.NotContains(t, c.histories, "i")
+
+	// Failing appends invalidate the cached history
+	c.histories["i"] = events(1, 2)
+	c.err = errors.New("timeout")
+	Append(ctx, c, l, "i", events(3, 3), false)
+	require.NotContains(t, c.histories, "i")
+}
@@ -0,0 +1,58 @@
+// Package historyfeed reads the history events of workflow instances in the order they were committed, for
+// consumers that process them incrementally and checkpoint their progress.
+package historyfeed
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+)
+
+// DefaultSettleDelay is the default for Feed.SettleDelay.
+const DefaultSettleDelay = time.Minute
+
+// Feed reads committed history events from a backend implementing backend.CommittedEventLister, including the
+// events of running instances and of executions that were continued as new.
+type Feed struct {
+	Backend backend.Backend
+
+	// BatchSize is the number of events read per batch.
+	BatchSize int
+
+	// SettleDelay is how long ago events need to have been committed to be read. Events committed in concurrent
+	// transactions might become visible out of order. Events committed within the delay are read once it has
+	// passed, so that no event is skipped when the cursor moves past its commit time.
+	SettleDelay time.Duration
+}
+
+// Read calls fn with batches of events committed after the given cursor, and the cursor right after the last
+// event of the batch, until all events have been read. It stops at the first error.
+func (f *Feed) Read(
+	ctx context.Context, after *backend.EventCursor, fn func(events []*backend.CommittedEvent, next *backend.EventCursor) error,
+) error {
+	l, ok := f.Backend.(backend.CommittedEventLister)
+	if !ok {
+		return backend.ErrCommittedEventsNotSupported
+	}
+
+	committedBefore := backend.Now(f.Backend).Add(-f.SettleDelay)
+
+	for {
+		events, err := l.GetCommittedEvents(ctx, after, committedBefore, f.BatchSize)
+		if err != nil {
+			return fmt.Errorf("listing committed events: %w", err)
+		}
+
+		if len(events) == 0 {
+			return nil
+		}
+
+		after = events[len(events)-1].Cursor()
+
+		if err := fn(events, after); err != nil {
+			return err
+		}
+	}
+}
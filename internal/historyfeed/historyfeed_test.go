@@ -0,0 +1,48 @@
+package historyfeed
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/historyfeed/historyfeedtest"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Feed_Read(t *testing.T) {
+	ctx := context.Background()
+	f := historyfeedtest.New(t)
+
+	i1 := f.Run(t)
+	i2 := f.Start(t)
+
+	feed := &Feed{Backend: f.Backend, BatchSize: 2}
+
+	var read []*backend.CommittedEvent
+	var cursors []*backend.EventCursor
+	require.NoError(t, feed.Read(ctx, nil, func(events []*backend.CommittedEvent, next *backend.EventCursor) error {
+		read = append(read, events...)
+		cursors = append(cursors, next)
+		return nil
+	}))
+
+	// Events of finished and running instances are read in the order they were committed
+	instances := map[string]bool{}
+	for j, e := range read {
+		instances[e.Instance.InstanceID] = true
+
+		if j > 0 {
+			require.False(t, e.CommittedAt.Before(read[j-1].CommittedAt))
+		}
+	}
+	require.Equal(t, map[string]bool{i1.InstanceID: true, i2.InstanceID: true}, instances)
+
+	// Reading resumes after the cursor
+	var resumed []*backend.CommittedEvent
+	require.NoError(t, feed.Read(ctx, cursors[0], func(events []*backend.CommittedEvent, next *backend.EventCursor) error {
+		resumed = append(resumed, events...)
+		return nil
+	}))
+	require.Len(t, resumed, len(read)-2)
+	require.Equal(t, read[2].Event.ID, resumed[0].Event.ID)
+}
@@ -0,0 +1,106 @@
+// Package historyfeedtest provides a fixture for testing consumers of the history events of workflow instances.
+package historyfeedtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/backend/sqlite"
+	"github.com/cschleiden/go-workflows/client"
+	"github.com/cschleiden/go-workflows/worker"
+	"github.com/cschleiden/go-workflows/workflow"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// Fixture runs workflow instances to completion on an in-memory SQLite backend.
+type Fixture struct {
+	Backend backend.Backend
+
+	ctx    context.Context
+	client client.Client
+}
+
+// Workflow is the workflow run by the fixture.
+func Workflow(ctx workflow.Context) (int, error) {
+	return 42, nil
+}
+
+// ContinuingWorkflow continues as new until it has run the given number of times.
+func ContinuingWorkflow(ctx workflow.Context, runs int) (int, error) {
+	if runs > 1 {
+		return 0, workflow.ContinueAsNew(ctx, runs-1)
+	}
+
+	return 42, nil
+}
+
+// WaitingWorkflow waits for a signal, and keeps running until it receives one.
+func WaitingWorkflow(ctx workflow.Context) error {
+	workflow.NewSignalChannel[string](ctx, "done").Receive(ctx)
+
+	return nil
+}
+
+// New returns a fixture with a started worker, which is stopped when the test finishes.
+func New(t *testing.T) *Fixture {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	b := sqlite.NewInMemoryBackend(backend.WithStickyTimeout(0))
+	w := worker.New(b, nil)
+	require.NoError(t, w.RegisterWorkflow(Workflow))
+	require.NoError(t, w.RegisterWorkflow(ContinuingWorkflow))
+	require.NoError(t, w.RegisterWorkflow(WaitingWorkflow))
+	require.NoError(t, w.Start(ctx))
+
+	return &Fixture{
+		Backend: b,
+		ctx:     ctx,
+		client:  client.New(b),
+	}
+}
+
+// Run starts a new instance of Workflow and waits for it to finish.
+func (f *Fixture) Run(t *testing.T) *workflow.Instance {
+	instance, err := f.client.CreateWorkflowInstance(f.ctx, client.WorkflowInstanceOptions{
+		InstanceID: uuid.NewString(),
+	}, Workflow)
+	require.NoError(t, err)
+	require.NoError(t, f.client.WaitForWorkflowInstance(f.ctx, instance, time.Second*5))
+
+	return instance
+}
+
+// RunContinued starts a new instance of ContinuingWorkflow running the given number of times, and waits for its
+// last execution to finish.
+func (f *Fixture) RunContinued(t *testing.T, runs int) *workflow.Instance {
+	instance, err := f.client.CreateWorkflowInstance(f.ctx, client.WorkflowInstanceOptions{
+		InstanceID: uuid.NewString(),
+	}, ContinuingWorkflow, runs)
+	require.NoError(t, err)
+
+	_, err = client.GetWorkflowResult[int](f.ctx, f.client, instance, time.Second*5)
+	require.NoError(t, err)
+
+	return instance
+}
+
+// Start starts a new instance of WaitingWorkflow, and waits for its first workflow task to be committed.
+func (f *Fixture) Start(t *testing.T) *workflow.Instance {
+	instance, err := f.client.CreateWorkflowInstance(f.ctx, client.WorkflowInstanceOptions{
+		InstanceID: uuid.NewString(),
+	}, WaitingWorkflow)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		h, err := f.Backend.GetWorkflowInstanceHistory(f.ctx, instance, nil)
+		require.NoError(t, err)
+
+		return len(h) > 0
+	}, time.Second*5, time.Millisecond*10)
+
+	return instance
+}
@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"encoding/json"
+
+	"github.com/cschleiden/go-workflows/internal/payload"
+	lg "github.com/cschleiden/go-workflows/log"
+)
+
+type redactingLogger struct {
+	l      lg.Logger
+	redact func([]byte) []byte
+}
+
+var _ lg.Logger = (*redactingLogger)(nil)
+
+// NewRedactingLogger returns a logger passing payload field values, i.e., payloads, raw JSON, and byte slices,
+// through redact before they are logged by the given logger.
+func NewRedactingLogger(l lg.Logger, redact func([]byte) []byte) lg.Logger {
+	return &redactingLogger{
+		l:      l,
+		redact: redact,
+	}
+}
+
+func (rl *redactingLogger) Debug(msg string, fields ...interface{}) {
+	rl.l.Debug(msg, rl.redactFields(fields)...)
+}
+
+func (rl *redactingLogger) Warn(msg string, fields ...interface{}) {
+	rl.l.Warn(msg, rl.redactFields(fields)...)
+}
+
+func (rl *redactingLogger) Error(msg string, fields ...interface{}) {
+	rl.l.Error(msg, rl.redactFields(fields)...)
+}
+
+func (rl *redactingLogger) Panic(msg string, fields ...interface{}) {
+	rl.l.Panic(msg, rl.redactFields(fields)...)
+}
+
+func (rl *redactingLogger) With(fields ...interface{}) lg.Logger {
+	return &redactingLogger{
+		l:      rl.l.With(rl.redactFields(fields)...),
+		redact: rl.redact,
+	}
+}
+
+func (rl *redactingLogger) redactFields(fields []interface{}) []interface{} {
+	var r []interface{}
+
+	// Fields are passed in pairs, only values are redacted
+	for i := 1; i < len(fields); i += 2 {
+		var redacted interface{}
+		switch v := fields[i].(type) {
+		case payload.Payload:
+			redacted = payload.Payload(rl.redact(v))
+		case []payload.Payload:
+			payloads := make([]payload.Payload, len(v))
+			for j, p := range v {
+				payloads[j] = rl.redact(p)
+			}
+			redacted = payloads
+		case json.RawMessage:
+			redacted = json.RawMessage(rl.redact(v))
+		case []byte:
+			redacted = rl.redact(v)
+		default:
+			continue
+		}
+
+		r = copyFields(r, fields)
+		r[i] = redacted
+	}
+
+	if r == nil {
+		return fields
+	}
+
+	return r
+}
+
+// copyFields copies the fields on the first redaction, so that the caller's slice isn't modified
+func copyFields(r, fields []interface{}) []interface{} {
+	if r != nil {
+		return r
+	}
+
+	return append([]interface{}{}, fields...)
+}
@@ -0,0 +1,42 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/cschleiden/go-workflows/internal/payload"
+	lg "github.com/cschleiden/go-workflows/log"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLogger struct {
+	lg.Logger
+
+	fields []interface{}
+}
+
+func (l *recordingLogger) Debug(msg string, fields ...interface{}) {
+	l.fields = fields
+}
+
+func (l *recordingLogger) With(fields ...interface{}) lg.Logger {
+	l.fields = fields
+	return l
+}
+
+func Test_RedactingLogger(t *testing.T) {
+	l := &recordingLogger{}
+	rl := NewRedactingLogger(l, func(data []byte) []byte {
+		return []byte("***")
+	})
+
+	fields := []interface{}{"input", payload.Payload("secret"), "instance_id", "id", "data", []byte("secret")}
+	rl.Debug("msg", fields...)
+
+	require.Equal(t, []interface{}{"input", payload.Payload("***"), "instance_id", "id", "data", []byte("***")}, l.fields)
+
+	// The fields of the caller are not modified
+	require.Equal(t, payload.Payload("secret"), fields[1])
+
+	rl.With("inputs", []payload.Payload{payload.Payload("secret")})
+	require.Equal(t, []interface{}{"inputs", []payload.Payload{payload.Payload("***")}}, l.fields)
+}
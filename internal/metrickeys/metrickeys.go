@@ -10,14 +10,53 @@ const (
 	WorkflowTaskScheduled = Prefix + "workflow.task.scheduled"
 	WorkflowTaskProcessed = Prefix + "workflow.task.processed"
 	WorkflowTaskDelay     = Prefix + "workflow.task.time_in_queue"
+	WorkflowTaskThrottled = Prefix + "workflow.task.throttled"
+	WorkflowTaskRetried   = Prefix + "workflow.task.retried"
+
+	WorkflowConcurrencyLimited = Prefix + "workflow.concurrency.limited"
 
 	WorkflowInstanceCacheSize     = Prefix + "workflow.cache.size"
 	WorkflowInstanceCacheEviction = Prefix + "workflow.cache.eviction"
+	WorkflowInstanceCacheMemory   = Prefix + "workflow.cache.memory"
+	WorkflowInstancePrefetched    = Prefix + "workflow.cache.prefetched"
+	WorkflowInstanceCacheHit      = Prefix + "workflow.cache.hit"
+	WorkflowInstanceCacheMiss     = Prefix + "workflow.cache.miss"
+
+	WorkflowSLOViolation = Prefix + "workflow.slo.violation"
+
+	WorkflowComputeSlow = Prefix + "workflow.compute.slow"
+	WorkflowComputeTime = Prefix + "workflow.compute.time"
+
+	WorkflowReplayBudgetExceeded = Prefix + "workflow.replay.budget_exceeded"
+	WorkflowReplayed             = Prefix + "workflow.replay.count"
+	WorkflowReplayedEvents       = Prefix + "workflow.replay.events"
 
 	// Activities
 	ActivityTaskScheduled = Prefix + "activity.task.scheduled"
 	ActivityTaskProcessed = Prefix + "activity.task.processed"
 	ActivityTaskDelay     = Prefix + "activity.task.time_in_queue"
+	ActivityTaskAttempt   = Prefix + "activity.task.attempt"
+	ActivityInputSize     = Prefix + "activity.input.size"
+	ActivityResultSize    = Prefix + "activity.result.size"
+	ActivityTaskSlow      = Prefix + "activity.task.slow"
+	ActivityLimitExceeded = Prefix + "activity.limit.exceeded"
+
+	ActivityCircuitOpened   = Prefix + "activity.circuit.opened"
+	ActivityCircuitRejected = Prefix + "activity.circuit.rejected"
+
+	// Backends
+	BackendStorageSize     = Prefix + "backend.storage.size"
+	BackendOldestTaskAge   = Prefix + "backend.task.oldest_age"
+	BackendPendingTimers   = Prefix + "backend.timers.pending"
+	BackendTimerSkew       = Prefix + "backend.timers.skew"
+	BackendLockContention  = Prefix + "backend.lock.contention"
+	BackendQueryDuration   = Prefix + "backend.query.duration"
+	BackendQueryRows       = Prefix + "backend.query.rows"
+	BackendQueryRetries    = Prefix + "backend.query.retries"
+	BackendInstancesPurged = Prefix + "backend.instances.purged"
+
+	BackendHistoryCacheHit  = Prefix + "backend.history_cache.hit"
+	BackendHistoryCacheMiss = Prefix + "backend.history_cache.miss"
 )
 
 // Tag names
@@ -31,4 +70,24 @@ const (
 	SubWorkflow = "subworkflow"
 
 	ActivityName = "activity"
+
+	WorkflowName = "workflow"
+
+	// Objective of a workflow SLO that was violated
+	SLO = "slo"
+
+	// Activity limit that was exceeded
+	ActivityLimit = "limit"
+
+	// Table or key space of a backend
+	StorageName = "storage"
+
+	// Task queue, either "workflow" or "activity"
+	TaskQueue = "queue"
+
+	// Operation that ran into lock contention
+	Operation = "operation"
+
+	// Kind of SQL statement, e.g., "SELECT"
+	Statement = "statement"
 )
@@ -0,0 +1,44 @@
+package payload
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// metadataPrefix marks payloads wrapped in a metadata envelope. A NUL byte never starts a valid JSON
+// document, so wrapped payloads can't be confused with plain ones.
+var metadataPrefix = []byte("\x00md:")
+
+type envelope struct {
+	Metadata map[string]string `json:"m"`
+	Data     []byte            `json:"d"`
+}
+
+// WithMetadata wraps the given payload in an envelope carrying the given metadata.
+func WithMetadata(p Payload, metadata map[string]string) (Payload, error) {
+	e, err := json.Marshal(&envelope{
+		Metadata: metadata,
+		Data:     p,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling payload envelope: %w", err)
+	}
+
+	return append(append(Payload{}, metadataPrefix...), e...), nil
+}
+
+// SplitMetadata returns the original payload and metadata of a payload created by WithMetadata. Payloads
+// without metadata are returned as-is with nil metadata.
+func SplitMetadata(p Payload) (Payload, map[string]string, error) {
+	if !bytes.HasPrefix(p, metadataPrefix) {
+		return p, nil, nil
+	}
+
+	var e envelope
+	if err := json.Unmarshal(p[len(metadataPrefix):], &e); err != nil {
+		return nil, nil, fmt.Errorf("unmarshaling payload envelope: %w", err)
+	}
+
+	return e.Data, e.Metadata, nil
+}
@@ -0,0 +1,24 @@
+package payload
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadata_RoundTrip(t *testing.T) {
+	p, err := WithMetadata(Payload(`{"a":1}`), map[string]string{"key": "value"})
+	require.NoError(t, err)
+
+	data, md, err := SplitMetadata(p)
+	require.NoError(t, err)
+	require.Equal(t, Payload(`{"a":1}`), data)
+	require.Equal(t, map[string]string{"key": "value"}, md)
+}
+
+func TestMetadata_PlainPayload(t *testing.T) {
+	data, md, err := SplitMetadata(Payload(`42`))
+	require.NoError(t, err)
+	require.Equal(t, Payload(`42`), data)
+	require.Nil(t, md)
+}
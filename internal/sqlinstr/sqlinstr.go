@@ -0,0 +1,378 @@
+// Package sqlinstr instruments the queries of the SQL backends. It wraps a database/sql driver, so that every
+// statement is measured, regardless of whether it runs on a connection, in a transaction, or as a prepared
+// statement.
+package sqlinstr
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/log"
+	"github.com/cschleiden/go-workflows/metrics"
+)
+
+type Options struct {
+	Logger log.Logger
+
+	Metrics metrics.Client
+
+	// SlowQueryThreshold is the duration above which queries are logged as slow. 0 disables the slow query log.
+	SlowQueryThreshold time.Duration
+}
+
+// Open returns a database using the given driver, which records the duration, the number of rows, and the
+// retries of every query.
+func Open(d driver.Driver, dsn string, options Options) (*sql.DB, error) {
+	var c driver.Connector = &dsnConnector{d: d, dsn: dsn}
+
+	if dc, ok := d.(driver.DriverContext); ok {
+		var err error
+		if c, err = dc.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+
+	return sql.OpenDB(&connector{Connector: c, options: options}), nil
+}
+
+type instanceIDKey struct{}
+
+// WithInstanceID returns a context that tags queries executed with it with the given workflow instance ID. The
+// ID is added as a comment to the query, to correlate queries in database monitoring tools.
+func WithInstanceID(ctx context.Context, instanceID string) context.Context {
+	return context.WithValue(ctx, instanceIDKey{}, instanceID)
+}
+
+func instanceID(ctx context.Context) string {
+	id, _ := ctx.Value(instanceIDKey{}).(string)
+	return id
+}
+
+// tag prefixes the query with a comment containing the instance ID of the context, if any. The ID is escaped so
+// that it cannot terminate the comment.
+func tag(ctx context.Context, query string) string {
+	id := instanceID(ctx)
+	if id == "" {
+		return query
+	}
+
+	return "/*instance_id='" + url.PathEscape(id) + "'*/ " + query
+}
+
+// statement returns the kind of the query, e.g., SELECT, used to tag metrics.
+func statement(query string) string {
+	q := strings.TrimSpace(query)
+	for strings.HasPrefix(q, "/*") {
+		end := strings.Index(q, "*/")
+		if end < 0 {
+			return ""
+		}
+
+		q = strings.TrimSpace(q[end+2:])
+	}
+
+	if i := strings.IndexAny(q, " \t\r\n("); i >= 0 {
+		q = q[:i]
+	}
+
+	return strings.ToUpper(q)
+}
+
+func (o *Options) record(ctx context.Context, query string, start time.Time, rows int64, err error) {
+	duration := time.Since(start)
+	tags := metrics.Tags{metrickeys.Statement: statement(query)}
+
+	o.Metrics.Timing(metrickeys.BackendQueryDuration, tags, duration)
+
+	if rows >= 0 {
+		o.Metrics.Distribution(metrickeys.BackendQueryRows, tags, float64(rows))
+	}
+
+	// database/sql retries queries that fail with ErrBadConn on another connection
+	if errors.Is(err, driver.ErrBadConn) {
+		o.Metrics.Counter(metrickeys.BackendQueryRetries, tags, 1)
+	}
+
+	if o.SlowQueryThreshold > 0 && duration > o.SlowQueryThreshold {
+		o.Logger.Warn("Slow query",
+			"query", query,
+			"instance_id", instanceID(ctx),
+			"duration_ms", duration.Milliseconds(),
+			"rows", rows,
+		)
+	}
+}
+
+type dsnConnector struct {
+	d   driver.Driver
+	dsn string
+}
+
+func (c *dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.d.Open(c.dsn)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.d
+}
+
+type connector struct {
+	driver.Connector
+
+	options Options
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	dc, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn{Conn: dc, options: &c.options}, nil
+}
+
+type conn struct {
+	driver.Conn
+
+	options *Options
+}
+
+var (
+	_ driver.ConnPrepareContext = (*conn)(nil)
+	_ driver.ConnBeginTx        = (*conn)(nil)
+	_ driver.ExecerContext      = (*conn)(nil)
+	_ driver.QueryerContext     = (*conn)(nil)
+	_ driver.Pinger             = (*conn)(nil)
+	_ driver.SessionResetter    = (*conn)(nil)
+	_ driver.Validator          = (*conn)(nil)
+	_ driver.NamedValueChecker  = (*conn)(nil)
+)
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	query = tag(ctx, query)
+
+	var s driver.Stmt
+	var err error
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		s, err = pc.PrepareContext(ctx, query)
+	} else {
+		s, err = c.Conn.Prepare(query)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &stmt{Stmt: s, query: query, options: c.options}, nil
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bc, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return bc.BeginTx(ctx, opts)
+	}
+
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errors.New("driver does not support transaction options")
+	}
+
+	return c.Conn.Begin()
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ec, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	query = tag(ctx, query)
+
+	start := time.Now()
+	r, err := ec.ExecContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		// Query will be prepared and executed as a statement
+		return nil, err
+	}
+
+	c.options.record(ctx, query, start, rowsAffected(r, err), err)
+
+	return r, err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	query = tag(ctx, query)
+
+	start := time.Now()
+	r, err := qc.QueryContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		return nil, err
+	}
+
+	if err != nil {
+		c.options.record(ctx, query, start, -1, err)
+		return nil, err
+	}
+
+	return &rows{Rows: r, ctx: ctx, query: query, start: start, options: c.options}, nil
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+
+	return true
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+
+	// Fall back to the default conversion
+	return driver.ErrSkip
+}
+
+type stmt struct {
+	driver.Stmt
+
+	query   string
+	options *Options
+}
+
+var (
+	_ driver.StmtExecContext  = (*stmt)(nil)
+	_ driver.StmtQueryContext = (*stmt)(nil)
+)
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+
+	var r driver.Result
+	var err error
+	if ec, ok := s.Stmt.(driver.StmtExecContext); ok {
+		r, err = ec.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			r, err = s.Stmt.Exec(values)
+		}
+	}
+
+	s.options.record(ctx, s.query, start, rowsAffected(r, err), err)
+
+	return r, err
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+
+	var r driver.Rows
+	var err error
+	if qc, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		r, err = qc.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			r, err = s.Stmt.Query(values)
+		}
+	}
+
+	if err != nil {
+		s.options.record(ctx, s.query, start, -1, err)
+		return nil, err
+	}
+
+	return &rows{Rows: r, ctx: ctx, query: s.query, start: start, options: s.options}, nil
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		if a.Name != "" {
+			return nil, errors.New("driver does not support the use of named parameters")
+		}
+
+		values[i] = a.Value
+	}
+
+	return values, nil
+}
+
+// rows counts the rows read from a query, and records the query once they are closed.
+type rows struct {
+	driver.Rows
+
+	ctx     context.Context
+	query   string
+	start   time.Time
+	options *Options
+
+	count  int64
+	err    error
+	closed bool
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err == nil {
+		r.count++
+	} else if err != io.EOF {
+		r.err = err
+	}
+
+	return err
+}
+
+func (r *rows) Close() error {
+	if !r.closed {
+		r.closed = true
+		r.options.record(r.ctx, r.query, r.start, r.count, r.err)
+	}
+
+	return r.Rows.Close()
+}
+
+func rowsAffected(r driver.Result, err error) int64 {
+	if err != nil || r == nil {
+		return -1
+	}
+
+	n, err := r.RowsAffected()
+	if err != nil {
+		return -1
+	}
+
+	return n
+}
@@ -0,0 +1,116 @@
+package sqlinstr
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/log"
+	"github.com/cschleiden/go-workflows/metrics"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingMetrics struct {
+	sync.Mutex
+
+	timings map[string]int
+	rows    []float64
+}
+
+func (m *recordingMetrics) Counter(name string, tags metrics.Tags, value int64) {}
+
+func (m *recordingMetrics) Distribution(name string, tags metrics.Tags, value float64) {
+	m.Lock()
+	defer m.Unlock()
+
+	if name == metrickeys.BackendQueryRows {
+		m.rows = append(m.rows, value)
+	}
+}
+
+func (m *recordingMetrics) Gauge(name string, tags metrics.Tags, value int64) {}
+
+func (m *recordingMetrics) Timing(name string, tags metrics.Tags, duration time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+
+	if name == metrickeys.BackendQueryDuration {
+		m.timings[tags[metrickeys.Statement]]++
+	}
+}
+
+func (m *recordingMetrics) WithTags(tags metrics.Tags) metrics.Client {
+	return m
+}
+
+type recordingLogger struct {
+	sync.Mutex
+
+	warnings [][]interface{}
+}
+
+func (l *recordingLogger) Debug(msg string, fields ...interface{}) {}
+
+func (l *recordingLogger) Warn(msg string, fields ...interface{}) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.warnings = append(l.warnings, append([]interface{}{msg}, fields...))
+}
+
+func (l *recordingLogger) Error(msg string, fields ...interface{}) {}
+
+func (l *recordingLogger) Panic(msg string, fields ...interface{}) {}
+
+func (l *recordingLogger) With(fields ...interface{}) log.Logger {
+	return l
+}
+
+func Test_Open_RecordsQueries(t *testing.T) {
+	m := &recordingMetrics{timings: map[string]int{}}
+	l := &recordingLogger{}
+
+	db, err := Open(&sqlite3.SQLiteDriver{}, "file::memory:", Options{
+		Logger:             l,
+		Metrics:            m,
+		SlowQueryThreshold: time.Nanosecond,
+	})
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	ctx := WithInstanceID(context.Background(), "i*/1")
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE t (id INTEGER)")
+	require.NoError(t, err)
+
+	_, err = db.ExecContext(ctx, "INSERT INTO t (id) VALUES (?), (?)", 1, 2)
+	require.NoError(t, err)
+
+	var n int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM t WHERE id > ?", 0).Scan(&n))
+	require.Equal(t, 2, n)
+
+	rows, err := db.QueryContext(ctx, "SELECT id FROM t")
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Close())
+
+	require.Equal(t, map[string]int{"CREATE": 1, "INSERT": 1, "SELECT": 2}, m.timings)
+	require.Equal(t, []float64{0, 2, 1, 2}, m.rows)
+
+	require.Len(t, l.warnings, 4)
+	require.Equal(t, "Slow query", l.warnings[1][0])
+	require.Equal(t, "/*instance_id='i%2A%2F1'*/ INSERT INTO t (id) VALUES (?), (?)", l.warnings[1][2])
+	require.Equal(t, "i*/1", l.warnings[1][4])
+}
+
+func Test_Statement(t *testing.T) {
+	require.Equal(t, "SELECT", statement("/*instance_id='a'*/ select 1"))
+	require.Equal(t, "UPDATE", statement("\n\t\tUPDATE instances SET x = 1"))
+	require.Equal(t, "", statement("/* unterminated"))
+}
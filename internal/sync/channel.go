@@ -90,7 +90,7 @@ func (c *channel[T]) Send(ctx Context, v T) {
 		}
 
 		// No waiting receiver, yield
-		cr.Yield()
+		cr.block(BlockReasonChannelSend)
 
 		// Was our sender called while we yielded? If so, we can return
 		if sentValue {
@@ -129,7 +129,7 @@ func (c *channel[T]) Receive(ctx Context) (v T, ok bool) {
 			addedListener = true
 		}
 
-		cr.Yield()
+		cr.block(BlockReasonChannelReceive)
 
 		// If we received a value via the callback, return
 		if receivedValue {
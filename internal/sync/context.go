@@ -1,9 +1,10 @@
 package sync
 
 import (
-	"errors"
 	"reflect"
 	"time"
+
+	"github.com/cschleiden/go-workflows/workflowerrors"
 )
 
 type CancelChannel ChannelInternal[struct{}]
@@ -103,7 +104,7 @@ type Context interface {
 
 // Canceled is the error returned by Context.Err when the context is canceled.
 //lint:ignore ST1012 for compat with "context" package
-var Canceled = errors.New("context canceled")
+var Canceled error = &workflowerrors.CanceledError{Message: "context canceled"}
 
 // An emptyCtx is never canceled, has no values, and has no deadline. It is not
 // struct{}, since vars of this type must have distinct addresses.
@@ -166,7 +167,37 @@ func WithCancel(parent Context) (ctx Context, cancel CancelFunc) {
 	}
 	c := newCancelCtx(parent)
 	propagateCancel(parent, &c)
-	return &c, func() { c.cancel(true, Canceled) }
+	return &c, func() { c.cancel(true, Canceled, nil) }
+}
+
+// A CancelCauseFunc behaves like a CancelFunc but additionally sets the
+// cancellation cause. This cause can be retrieved by calling Cause on the
+// canceled Context or on any of its derived Contexts.
+type CancelCauseFunc func(cause error)
+
+// WithCancelCause behaves like WithCancel but returns a CancelCauseFunc instead
+// of a CancelFunc. Calling cancel with a non-nil error (the "cause") records
+// that error in ctx; it can then be retrieved using Cause(ctx). Calling cancel
+// with nil sets the cause to Canceled. Err still returns Canceled.
+func WithCancelCause(parent Context) (ctx Context, cancel CancelCauseFunc) {
+	if parent == nil {
+		panic("cannot create context from nil parent")
+	}
+	c := newCancelCtx(parent)
+	propagateCancel(parent, &c)
+	return &c, func(cause error) { c.cancel(true, Canceled, cause) }
+}
+
+// Cause returns a non-nil error explaining why c was canceled. The first
+// cancellation of c or one of its parents sets the cause. If that cancellation
+// happened via a call to CancelCauseFunc(err), then Cause returns err.
+// Otherwise Cause(c) returns the same value as c.Err(). Cause returns nil if c
+// has not been canceled yet.
+func Cause(c Context) error {
+	if cc, ok := c.Value(&cancelCtxKey).(*cancelCtx); ok {
+		return cc.cause
+	}
+	return c.Err()
 }
 
 // newCancelCtx returns an initialized cancelCtx.
@@ -188,7 +219,7 @@ func propagateCancel(parent Context, child canceler) {
 		parent,
 		Receive(done, func(ctx Context, _ struct{}, _ bool) {
 			// Parent is already canceled
-			child.cancel(false, parent.Err())
+			child.cancel(false, parent.Err(), Cause(parent))
 		}),
 		Default(func(_ Context) {
 			// Ignore
@@ -198,12 +229,9 @@ func propagateCancel(parent Context, child canceler) {
 	if p, ok := parentCancelCtx(parent); ok {
 		if p.err != nil {
 			// parent has already been canceled
-			child.cancel(false, p.err)
+			child.cancel(false, p.err, p.cause)
 		} else {
-			if p.children == nil {
-				p.children = make(map[canceler]struct{})
-			}
-			p.children[child] = struct{}{}
+			p.children = append(p.children, child)
 		}
 	} else {
 		panic("not implemented")
@@ -248,15 +276,18 @@ func removeChild(parent Context, child canceler) {
 	if !ok {
 		return
 	}
-	if p.children != nil {
-		delete(p.children, child)
+	for i, c := range p.children {
+		if c == child {
+			p.children = append(p.children[:i], p.children[i+1:]...)
+			break
+		}
 	}
 }
 
 // A canceler is a context type that can be canceled directly. The
 // implementations are *cancelCtx and *timerCtx.
 type canceler interface {
-	cancel(removeFromParent bool, err error)
+	cancel(removeFromParent bool, err, cause error)
 	Done() Channel[struct{}]
 }
 
@@ -273,8 +304,9 @@ type cancelCtx struct {
 	Context
 
 	done     Channel[struct{}]
-	children map[canceler]struct{} // set to nil by the first cancel call
-	err      error                 // set to non-nil by the first cancel call
+	children []canceler // in order of creation, set to nil by the first cancel call
+	err      error      // set to non-nil by the first cancel call
+	cause    error      // set to non-nil by the first cancel call
 }
 
 func (c *cancelCtx) Value(key interface{}) interface{} {
@@ -295,21 +327,28 @@ func (c *cancelCtx) Err() error {
 
 // cancel closes c.done, cancels each of c's children, and, if
 // removeFromParent is true, removes c from its parent's children.
-func (c *cancelCtx) cancel(removeFromParent bool, err error) {
+// cancel sets c.cause to cause if this is the first time c is canceled.
+func (c *cancelCtx) cancel(removeFromParent bool, err, cause error) {
 	if err == nil {
 		panic("context: internal error: missing cancel error")
 	}
+	if cause == nil {
+		cause = err
+	}
 	if c.err != nil {
 		return // already canceled
 	}
 	c.err = err
+	c.cause = cause
 	if c.done == nil {
 		c.done = closedchan
 	} else {
 		c.done.Close()
 	}
-	for child := range c.children {
-		child.cancel(false, err)
+	// Cancel children in the order they were created, so that the commands canceled as a result are
+	// the same when the workflow is replayed
+	for _, child := range c.children {
+		child.cancel(false, err, cause)
 	}
 	c.children = nil
 
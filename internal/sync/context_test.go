@@ -1,6 +1,7 @@
 package sync
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -43,3 +44,83 @@ func TestWithCancel(t *testing.T) {
 
 	require.True(t, canceled)
 }
+
+func TestWithCancel_CancelsChildrenInOrder(t *testing.T) {
+	ctx, cancel := WithCancel(Background())
+
+	var canceled []int
+
+	cr := NewCoroutine(ctx, func(ctx Context) error {
+		cancels := make([]CancelFunc, 0)
+		for i := 0; i < 20; i++ {
+			i := i
+
+			child, childCancel := WithCancel(ctx)
+			cancels = append(cancels, childCancel)
+			child.Done().(CancelChannel).AddReceiveCallback(func(struct{}, bool) {
+				canceled = append(canceled, i)
+			})
+		}
+
+		// Removing a child keeps the order of the others
+		cancels[3]()
+
+		return nil
+	})
+
+	cr.Execute()
+	require.True(t, cr.Finished())
+	require.Equal(t, []int{3}, canceled)
+
+	cancel()
+
+	expected := []int{3}
+	for i := 0; i < 20; i++ {
+		if i != 3 {
+			expected = append(expected, i)
+		}
+	}
+	require.Equal(t, expected, canceled)
+}
+
+func TestWithCancelCause(t *testing.T) {
+	ctx, cancel := WithCancelCause(Background())
+	require.Nil(t, Cause(ctx))
+
+	var childErr, childCause error
+
+	cr := NewCoroutine(ctx, func(ctx Context) error {
+		// Create child context, canceled with the cause of the parent
+		ctx, _ = WithCancel(ctx)
+
+		ctx.Done().Receive(ctx)
+
+		childErr = ctx.Err()
+		childCause = Cause(ctx)
+
+		return nil
+	})
+
+	cr.Execute()
+	require.False(t, cr.Finished())
+
+	cause := errors.New("cause")
+	cancel(cause)
+	cancel(errors.New("ignored"))
+
+	cr.Execute()
+	require.True(t, cr.Finished())
+
+	require.Equal(t, Canceled, ctx.Err())
+	require.Equal(t, cause, Cause(ctx))
+	require.Equal(t, Canceled, childErr)
+	require.Equal(t, cause, childCause)
+}
+
+func TestCause_WithoutCause(t *testing.T) {
+	ctx, cancel := WithCancel(Background())
+	cancel()
+
+	require.Equal(t, Canceled, Cause(ctx))
+	require.Nil(t, Cause(Background()))
+}
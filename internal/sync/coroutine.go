@@ -1,12 +1,14 @@
 package sync
 
 import (
-	"fmt"
 	"io"
 	"log"
 	"runtime"
+	"runtime/debug"
 	"sync/atomic"
 	"time"
+
+	"github.com/cschleiden/go-workflows/workflowerrors"
 )
 
 const DeadlockDetection = 40 * time.Second
@@ -26,6 +28,13 @@ type Coroutine interface {
 	Finished() bool
 	Progress() bool
 
+	// BlockReason returns why the coroutine is blocked.
+	BlockReason() BlockReason
+
+	// LastSlice returns how long the coroutine ran during the last call to Execute before it
+	// blocked, yielded, or finished.
+	LastSlice() time.Duration
+
 	Error() error
 
 	SetScheduler(s Scheduler)
@@ -47,6 +56,9 @@ type coState struct {
 	shouldExit atomic.Value // coroutine should exit
 	progress   atomic.Value // did the coroutine make progress since last yield?
 
+	lastSlice   time.Duration // time spent running during the last call to Execute
+	blockReason BlockReason   // why the coroutine is blocked, set before blocking
+
 	err error
 
 	logger logger
@@ -64,7 +76,7 @@ func NewCoroutine(ctx Context, fn func(ctx Context) error) Coroutine {
 		defer s.finish() // Ensure we always mark the coroutine as finished
 		defer func() {
 			if r := recover(); r != nil {
-				s.err = fmt.Errorf("panic: %v", r)
+				s.err = &workflowerrors.PanicError{Value: r, Stack: string(debug.Stack())}
 			}
 		}()
 
@@ -89,6 +101,7 @@ func newState() *coState {
 		logger: log.New(io.Discard, "[co]", log.LstdFlags),
 		// logger:            log.New(os.Stderr, fmt.Sprintf("[co %v]", i), log.Lmsgprefix|log.Ltime),
 		deadlockDetection: DeadlockDetection,
+		blockReason:       BlockReasonNotStarted,
 	}
 }
 
@@ -128,9 +141,18 @@ func (s *coState) Progress() bool {
 }
 
 func (s *coState) Yield() {
+	s.block(BlockReasonYield)
+}
+
+func (s *coState) block(reason BlockReason) {
+	s.blockReason = reason
 	s.yield(true)
 }
 
+func (s *coState) BlockReason() BlockReason {
+	return s.blockReason
+}
+
 func (s *coState) yield(markBlocking bool) {
 	s.logger.Println("yielding")
 
@@ -166,6 +188,11 @@ func (s *coState) Execute() {
 	t := time.NewTimer(s.deadlockDetection)
 	defer t.Stop()
 
+	start := time.Now()
+	defer func() {
+		s.lastSlice = time.Since(start)
+	}()
+
 	s.logger.Println("execute: unblocking")
 	s.unblock <- true
 	s.logger.Println("execute: unblocked")
@@ -181,6 +208,10 @@ func (s *coState) Execute() {
 	}
 }
 
+func (s *coState) LastSlice() time.Duration {
+	return s.lastSlice
+}
+
 func (s *coState) Exit() {
 	s.logger.Println("exit")
 
@@ -54,7 +54,7 @@ func (f *future[T]) Get(ctx Context) (T, error) {
 			return f.v, nil
 		}
 
-		cr.Yield()
+		cr.block(BlockReasonFuture)
 	}
 }
 
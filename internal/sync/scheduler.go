@@ -1,5 +1,38 @@
 package sync
 
+import "time"
+
+// BlockReason describes why a coroutine is blocked.
+type BlockReason string
+
+const (
+	BlockReasonNotStarted     BlockReason = "not_started"
+	BlockReasonYield          BlockReason = "yield"
+	BlockReasonFuture         BlockReason = "future"
+	BlockReasonChannelSend    BlockReason = "channel_send"
+	BlockReasonChannelReceive BlockReason = "channel_receive"
+	BlockReasonSelect         BlockReason = "select"
+)
+
+// SchedulerStats are statistics about the coroutines of a scheduler.
+type SchedulerStats struct {
+	// Coroutines is the number of coroutines that haven't finished yet.
+	Coroutines int
+
+	// Started is the total number of coroutines started.
+	Started int
+
+	// Wakes is the total number of times coroutines were resumed.
+	Wakes int64
+
+	// Blocked is the number of unfinished coroutines by the reason they are blocked for.
+	Blocked map[BlockReason]int
+}
+
+// Scheduler runs coroutines cooperatively, one at a time. The order is deterministic: Execute resumes
+// coroutines round-robin in the order they were started, and coroutines started during Execute, e.g.,
+// by Go, are appended and resumed within the same round. Rounds are repeated until no coroutine makes
+// progress anymore.
 type Scheduler interface {
 	// Starts a new co-routine and tracks it in this scheduler
 	NewCoroutine(ctx Context, fn func(Context) error)
@@ -9,11 +42,29 @@ type Scheduler interface {
 
 	RunningCoroutines() int
 
+	// LongestSlice returns the longest time a single coroutine ran without yielding since the
+	// last call to LongestSlice, and resets it.
+	LongestSlice() time.Duration
+
+	// Stats returns statistics about the coroutines of this scheduler. It must not be called
+	// concurrently with Execute.
+	Stats() SchedulerStats
+
+	// SetTracer sets a tracer receiving the creation, wake-ups, blocking, and exit of coroutines. A nil
+	// tracer disables tracing.
+	SetTracer(t SchedulerTracer)
+
 	Exit()
 }
 
 type scheduler struct {
-	coroutines []Coroutine
+	coroutines   []Coroutine
+	longestSlice time.Duration
+	started      int
+	wakes        int64
+
+	tracer SchedulerTracer
+	ids    map[Coroutine]int
 }
 
 func NewScheduler() Scheduler {
@@ -25,7 +76,13 @@ func NewScheduler() Scheduler {
 func (s *scheduler) NewCoroutine(ctx Context, fn func(Context) error) {
 	c := NewCoroutine(ctx, fn)
 	s.coroutines = append(s.coroutines, c)
+	s.started++
 	c.SetScheduler(s)
+
+	if s.tracer != nil {
+		s.ids[c] = s.started
+		s.trace(c, SchedulerEventCreated, "", nil)
+	}
 }
 
 func (s *scheduler) Execute() error {
@@ -35,7 +92,14 @@ func (s *scheduler) Execute() error {
 		for i := 0; i < len(s.coroutines); i++ {
 			c := s.coroutines[i]
 
+			s.trace(c, SchedulerEventWoken, "", nil)
+
 			c.Execute()
+			s.wakes++
+
+			if d := c.LastSlice(); d > s.longestSlice {
+				s.longestSlice = d
+			}
 
 			if c.Finished() {
 				// Coroutine finished, this counts as progress
@@ -46,6 +110,9 @@ func (s *scheduler) Execute() error {
 				s.coroutines = append(s.coroutines[:i], s.coroutines[i+1:]...)
 				i--
 
+				s.trace(c, SchedulerEventExited, "", c.Error())
+				delete(s.ids, c)
+
 				if err := c.Error(); err != nil {
 					// Coroutine encountered an error, abort execution
 					return err
@@ -53,6 +120,8 @@ func (s *scheduler) Execute() error {
 			} else {
 				// Determine if coroutine made any progress or if it stayed blocked
 				allBlocked = allBlocked && !c.Progress()
+
+				s.trace(c, SchedulerEventBlocked, c.BlockReason(), nil)
 			}
 		}
 	}
@@ -64,8 +133,51 @@ func (s *scheduler) RunningCoroutines() int {
 	return len(s.coroutines)
 }
 
+func (s *scheduler) LongestSlice() time.Duration {
+	d := s.longestSlice
+	s.longestSlice = 0
+	return d
+}
+
+func (s *scheduler) Stats() SchedulerStats {
+	blocked := map[BlockReason]int{}
+	for _, c := range s.coroutines {
+		blocked[c.BlockReason()]++
+	}
+
+	return SchedulerStats{
+		Coroutines: len(s.coroutines),
+		Started:    s.started,
+		Wakes:      s.wakes,
+		Blocked:    blocked,
+	}
+}
+
+func (s *scheduler) SetTracer(t SchedulerTracer) {
+	s.tracer = t
+
+	if t != nil && s.ids == nil {
+		s.ids = make(map[Coroutine]int)
+	}
+}
+
+func (s *scheduler) trace(c Coroutine, kind SchedulerEventKind, reason BlockReason, err error) {
+	if s.tracer == nil {
+		return
+	}
+
+	s.tracer.Trace(SchedulerEvent{
+		Time:      time.Now(),
+		Kind:      kind,
+		Coroutine: s.ids[c],
+		Reason:    reason,
+		Err:       err,
+	})
+}
+
 func (s *scheduler) Exit() {
 	for _, c := range s.coroutines {
 		c.Exit()
+		s.trace(c, SchedulerEventExited, "", nil)
 	}
 }
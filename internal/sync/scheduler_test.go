@@ -1,6 +1,7 @@
 package sync
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -171,3 +172,149 @@ func Test_Scheduler_Panic(t *testing.T) {
 	require.Equal(t, "panic: something went wrong", err.Error())
 	require.Equal(t, 0, s.RunningCoroutines())
 }
+
+func Test_Scheduler_DeterministicOrder(t *testing.T) {
+	run := func() []int {
+		s := NewScheduler()
+
+		var order []int
+
+		ctx := Background()
+		s.NewCoroutine(ctx, func(ctx Context) error {
+			for i := 0; i < 50; i++ {
+				i := i
+
+				Go(ctx, func(ctx Context) {
+					order = append(order, i)
+
+					// Coroutines started by other coroutines are scheduled after existing ones
+					if i%10 == 0 {
+						Go(ctx, func(ctx Context) {
+							order = append(order, 100+i)
+						})
+					}
+
+					Yield(ctx)
+
+					order = append(order, 200+i)
+				})
+			}
+
+			return nil
+		})
+
+		require.NoError(t, s.Execute())
+		require.Equal(t, 0, s.RunningCoroutines())
+
+		return order
+	}
+
+	var expected []int
+	for i := 0; i < 50; i++ {
+		expected = append(expected, i)
+	}
+	for i := 0; i < 50; i += 10 {
+		expected = append(expected, 100+i)
+	}
+	for i := 0; i < 50; i++ {
+		expected = append(expected, 200+i)
+	}
+
+	for i := 0; i < 10; i++ {
+		require.Equal(t, expected, run())
+	}
+}
+
+func Test_Scheduler_Stats(t *testing.T) {
+	s := NewScheduler()
+
+	ctx := Background()
+	c := NewChannel[int]()
+	f := NewFuture[int]()
+
+	s.NewCoroutine(ctx, func(ctx Context) error {
+		Go(ctx, func(ctx Context) {
+			c.Receive(ctx)
+		})
+
+		Go(ctx, func(ctx Context) {
+			c.Send(ctx, 42)
+			c.Send(ctx, 23)
+		})
+
+		_, err := f.Get(ctx)
+		return err
+	})
+
+	stats := s.Stats()
+	require.Equal(t, 1, stats.Coroutines)
+	require.Equal(t, 1, stats.Blocked[BlockReasonNotStarted])
+
+	require.NoError(t, s.Execute())
+
+	stats = s.Stats()
+	require.Equal(t, 3, stats.Started)
+	require.Equal(t, 2, stats.Coroutines)
+	require.Equal(t, map[BlockReason]int{
+		BlockReasonFuture:      1,
+		BlockReasonChannelSend: 1,
+	}, stats.Blocked)
+	require.Greater(t, stats.Wakes, int64(3))
+
+	s.Exit()
+}
+
+func Test_Scheduler_Tracer(t *testing.T) {
+	s := NewScheduler()
+	trace := NewSchedulerTrace(0)
+	s.SetTracer(trace)
+
+	ctx := Background()
+	f := NewFuture[int]()
+
+	s.NewCoroutine(ctx, func(ctx Context) error {
+		_, err := f.Get(ctx)
+		return err
+	})
+
+	s.NewCoroutine(ctx, func(ctx Context) error {
+		f.Set(42, nil)
+		return nil
+	})
+
+	require.NoError(t, s.Execute())
+
+	kinds := []string{}
+	for _, e := range trace.Events() {
+		kinds = append(kinds, fmt.Sprintf("%d %s %s", e.Coroutine, e.Kind, e.Reason))
+	}
+
+	require.Equal(t, []string{
+		"1 created ",
+		"2 created ",
+		"1 woken ",
+		"1 blocked future",
+		"2 woken ",
+		"2 exited ",
+		"1 woken ",
+		"1 exited ",
+	}, kinds)
+
+	require.Contains(t, trace.String(), "co 1 blocked (future)")
+
+	trace.Reset()
+	require.Empty(t, trace.Events())
+}
+
+func Test_SchedulerTrace_MaxEvents(t *testing.T) {
+	trace := NewSchedulerTrace(2)
+
+	for i := 1; i <= 3; i++ {
+		trace.Trace(SchedulerEvent{Kind: SchedulerEventWoken, Coroutine: i})
+	}
+
+	events := trace.Events()
+	require.Len(t, events, 2)
+	require.Equal(t, 2, events[0].Coroutine)
+	require.Contains(t, trace.String(), "(1 earlier events dropped)")
+}
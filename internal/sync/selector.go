@@ -46,7 +46,7 @@ func Select(ctx Context, cases ...SelectCase) {
 		}
 
 		// else, yield and wait for result
-		cs.Yield()
+		cs.block(BlockReasonSelect)
 	}
 }
 
@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchedulerEventKind is the kind of a scheduling event.
+type SchedulerEventKind string
+
+const (
+	SchedulerEventCreated SchedulerEventKind = "created"
+	SchedulerEventWoken   SchedulerEventKind = "woken"
+	SchedulerEventBlocked SchedulerEventKind = "blocked"
+	SchedulerEventExited  SchedulerEventKind = "exited"
+)
+
+// SchedulerEvent describes a change in the state of a coroutine.
+type SchedulerEvent struct {
+	Time time.Time
+	Kind SchedulerEventKind
+
+	// Coroutine identifies the coroutine, coroutines are numbered in the order they were started, starting at 1.
+	Coroutine int
+
+	// Reason is set for SchedulerEventBlocked events.
+	Reason BlockReason
+
+	// Err is set for SchedulerEventExited events of coroutines that returned an error.
+	Err error
+}
+
+func (e SchedulerEvent) String() string {
+	s := fmt.Sprintf("%s co %d %s", e.Time.Format("15:04:05.000000"), e.Coroutine, e.Kind)
+
+	if e.Reason != "" {
+		s += " (" + string(e.Reason) + ")"
+	}
+
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+
+	return s
+}
+
+// SchedulerTracer receives the scheduling events of a scheduler. It's called synchronously while the
+// scheduler executes coroutines, so it should not block.
+type SchedulerTracer interface {
+	Trace(e SchedulerEvent)
+}
+
+// SchedulerTrace is a SchedulerTracer that records the most recent scheduling events.
+type SchedulerTrace struct {
+	mu        sync.Mutex
+	maxEvents int
+	events    []SchedulerEvent
+	dropped   int
+}
+
+// NewSchedulerTrace returns a trace keeping the last maxEvents events. If maxEvents is 0, all events are kept.
+func NewSchedulerTrace(maxEvents int) *SchedulerTrace {
+	return &SchedulerTrace{
+		maxEvents: maxEvents,
+	}
+}
+
+func (t *SchedulerTrace) Trace(e SchedulerEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.maxEvents > 0 && len(t.events) >= t.maxEvents {
+		t.events = append(t.events[:0], t.events[1:]...)
+		t.dropped++
+	}
+
+	t.events = append(t.events, e)
+}
+
+// Events returns the recorded events, oldest first.
+func (t *SchedulerTrace) Events() []SchedulerEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]SchedulerEvent(nil), t.events...)
+}
+
+// Reset removes all recorded events.
+func (t *SchedulerTrace) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events = t.events[:0]
+	t.dropped = 0
+}
+
+// String returns the recorded events, one per line.
+func (t *SchedulerTrace) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var sb strings.Builder
+
+	if t.dropped > 0 {
+		fmt.Fprintf(&sb, "(%d earlier events dropped)\n", t.dropped)
+	}
+
+	for _, e := range t.events {
+		sb.WriteString(e.String())
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,45 @@
+package sync
+
+import "errors"
+
+var ErrNoFutures = errors.New("no futures to wait for")
+
+// WaitAll blocks until all futures are resolved. Results are returned in the order of the given futures. If
+// any future resolved with an error, the error of the first such future in argument order is returned.
+func WaitAll[T any](ctx Context, futures ...Future[T]) ([]T, error) {
+	results := make([]T, len(futures))
+
+	var firstErr error
+	for i, f := range futures {
+		v, err := f.Get(ctx)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+
+		results[i] = v
+	}
+
+	return results, firstErr
+}
+
+// WaitAny blocks until at least one of the futures is resolved and returns its index, value, and error. If
+// multiple futures are resolved, the one passed first wins, so the result does not depend on the order in
+// which events were received.
+func WaitAny[T any](ctx Context, futures ...Future[T]) (int, T, error) {
+	if len(futures) == 0 {
+		return -1, *new(T), ErrNoFutures
+	}
+
+	cs := getCoState(ctx)
+
+	for {
+		for i, f := range futures {
+			if f.(FutureInternal[T]).Ready() {
+				v, err := f.Get(ctx)
+				return i, v, err
+			}
+		}
+
+		cs.block(BlockReasonFuture)
+	}
+}
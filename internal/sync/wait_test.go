@@ -0,0 +1,76 @@
+package sync
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WaitAll(t *testing.T) {
+	f1, f2, f3 := NewFuture[int](), NewFuture[int](), NewFuture[int]()
+
+	var results []int
+	var err error
+
+	c := NewCoroutine(Background(), func(ctx Context) error {
+		results, err = WaitAll[int](ctx, f1, f2, f3)
+
+		return nil
+	})
+
+	c.Execute()
+	require.False(t, c.Finished())
+
+	f3.Set(3, errors.New("f3"))
+	f1.Set(1, nil)
+	c.Execute()
+	require.False(t, c.Finished())
+
+	f2.Set(2, errors.New("f2"))
+	c.Execute()
+	require.True(t, c.Finished())
+
+	require.Equal(t, []int{1, 0, 0}, results)
+	require.EqualError(t, err, "f2")
+}
+
+func Test_WaitAny_ReturnsFirstReadyInArgumentOrder(t *testing.T) {
+	f1, f2, f3 := NewFuture[int](), NewFuture[int](), NewFuture[int]()
+
+	var i, v int
+	var err error
+
+	c := NewCoroutine(Background(), func(ctx Context) error {
+		i, v, err = WaitAny[int](ctx, f1, f2, f3)
+
+		return nil
+	})
+
+	c.Execute()
+	require.False(t, c.Finished())
+
+	// Resolve both in reverse order, the first future in argument order wins
+	f3.Set(3, nil)
+	f2.Set(2, nil)
+	c.Execute()
+	require.True(t, c.Finished())
+
+	require.Equal(t, 1, i)
+	require.Equal(t, 2, v)
+	require.NoError(t, err)
+}
+
+func Test_WaitAny_NoFutures(t *testing.T) {
+	var err error
+
+	c := NewCoroutine(Background(), func(ctx Context) error {
+		_, _, err = WaitAny[int](ctx)
+
+		return nil
+	})
+
+	c.Execute()
+	require.True(t, c.Finished())
+	require.ErrorIs(t, err, ErrNoFutures)
+}
@@ -0,0 +1,11 @@
+package sync
+
+// Yield suspends the current coroutine and lets the scheduler run other coroutines before
+// continuing. Unlike blocking on a future or channel, the coroutine is resumed within the same
+// call to Execute.
+func Yield(ctx Context) {
+	cs := getCoState(ctx)
+
+	cs.MadeProgress()
+	cs.Yield()
+}
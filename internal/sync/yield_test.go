@@ -0,0 +1,57 @@
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Yield_InterleavesCoroutines(t *testing.T) {
+	s := NewScheduler()
+
+	order := make([]string, 0)
+
+	ctx := Background()
+	s.NewCoroutine(ctx, func(ctx Context) error {
+		for i := 0; i < 2; i++ {
+			order = append(order, "a")
+			Yield(ctx)
+		}
+
+		return nil
+	})
+
+	s.NewCoroutine(ctx, func(ctx Context) error {
+		for i := 0; i < 2; i++ {
+			order = append(order, "b")
+			Yield(ctx)
+		}
+
+		return nil
+	})
+
+	require.NoError(t, s.Execute())
+	require.Equal(t, []string{"a", "b", "a", "b"}, order)
+	require.Equal(t, 0, s.RunningCoroutines())
+}
+
+func Test_Scheduler_LongestSlice(t *testing.T) {
+	s := NewScheduler()
+
+	ctx := Background()
+	s.NewCoroutine(ctx, func(ctx Context) error {
+		time.Sleep(20 * time.Millisecond)
+		Yield(ctx)
+
+		time.Sleep(time.Millisecond)
+
+		return nil
+	})
+
+	require.NoError(t, s.Execute())
+	require.GreaterOrEqual(t, s.LongestSlice(), 20*time.Millisecond)
+
+	// Reading resets the value
+	require.Equal(t, time.Duration(0), s.LongestSlice())
+}
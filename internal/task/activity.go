@@ -3,6 +3,7 @@ package task
 import (
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
 )
 
 type Activity struct {
@@ -13,4 +14,8 @@ type Activity struct {
 	Metadata *core.WorkflowMetadata
 
 	Event history.Event
+
+	// HeartbeatDetails are the details of the last heartbeat recorded for this task, if it has been delivered
+	// before and the backend stores heartbeat details
+	HeartbeatDetails payload.Payload
 }
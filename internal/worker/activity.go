@@ -2,8 +2,10 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/benbjohnson/clock"
@@ -11,9 +13,11 @@ import (
 	"github.com/cschleiden/go-workflows/internal/activity"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/internal/payload"
 	"github.com/cschleiden/go-workflows/internal/task"
 	"github.com/cschleiden/go-workflows/internal/workflow"
 	"github.com/cschleiden/go-workflows/metrics"
+	"github.com/cschleiden/go-workflows/workflowerrors"
 )
 
 type ActivityWorker struct {
@@ -21,41 +25,145 @@ type ActivityWorker struct {
 
 	options *Options
 
-	activityTaskQueue    chan *task.Activity
+	activityTaskQueue    ActivityTaskQueue
 	activityTaskExecutor activity.Executor
 
+	pollers *pollers
+	sem     *semaphore
+	guard   *activityGuard
+
+	circuits *circuitBreakers
+
+	// Number of activity tasks received from the backend that have not been completed yet
+	activeTasks int64
+
+	drainMu  sync.Mutex
+	draining bool
+
 	wg *sync.WaitGroup
 
+	inFlight *inFlightTasks
+
 	clock clock.Clock
 }
 
+// DrainStatus describes the progress of draining an activity worker.
+type DrainStatus struct {
+	// Draining is true once Drain has been called.
+	Draining bool
+
+	// ActiveTasks is the number of activity tasks the worker is still processing.
+	ActiveTasks int
+}
+
+// Drained returns true if the worker is draining and has no more active tasks.
+func (s DrainStatus) Drained() bool {
+	return s.Draining && s.ActiveTasks == 0
+}
+
 func NewActivityWorker(backend backend.Backend, registry *workflow.Registry, clock clock.Clock, options *Options) *ActivityWorker {
+	queue := options.ActivityTaskQueue
+	if queue == nil {
+		queue = newChannelActivityTaskQueue()
+	}
+
 	return &ActivityWorker{
 		backend: backend,
 
 		options: options,
 
-		activityTaskQueue:    make(chan *task.Activity),
+		activityTaskQueue:    queue,
 		activityTaskExecutor: activity.NewExecutor(backend.Logger(), backend.Tracer(), registry),
 
+		sem:   newSemaphore(options.MaxParallelActivityTasks),
+		guard: newActivityGuard(options.ActivityLimits),
+
+		circuits: newCircuitBreakers(options.ActivityCircuitBreakers, clock),
+
 		wg: &sync.WaitGroup{},
 
+		inFlight: newInFlightTasks(),
+
 		clock: clock,
 	}
 }
 
+// InFlightTasks returns the activity tasks currently being executed, longest running first.
+func (aw *ActivityWorker) InFlightTasks() []InFlightTask {
+	return aw.inFlight.List()
+}
+
 func (aw *ActivityWorker) Start(ctx context.Context) error {
-	for i := 0; i <= aw.options.ActivityPollers; i++ {
-		go aw.runPoll(ctx)
+	aw.drainMu.Lock()
+	aw.pollers = newPollers(ctx, aw.runPoll)
+	if !aw.draining {
+		aw.pollers.Resize(aw.options.ActivityPollers)
 	}
+	aw.drainMu.Unlock()
 
+	aw.wg.Add(1)
 	go aw.runDispatcher(context.Background())
 
 	return nil
 }
 
+// ApplyDynamicOptions adjusts the number of pollers, the task concurrency, the rate limits, and the activity
+// limits of a running worker.
+func (aw *ActivityWorker) ApplyDynamicOptions(o DynamicOptions) {
+	aw.drainMu.Lock()
+	if o.ActivityPollers > 0 && !aw.draining && aw.pollers != nil {
+		aw.pollers.Resize(o.ActivityPollers)
+	}
+	aw.drainMu.Unlock()
+
+	if o.MaxParallelActivityTasks != 0 {
+		aw.sem.SetMax(o.MaxParallelActivityTasks)
+	}
+
+	if o.ActivityRateLimits != nil {
+		if rl, ok := aw.options.ActivityRateLimiter.(ConfigurableActivityRateLimiter); ok {
+			rl.SetLimits(o.ActivityRateLimits)
+		} else {
+			aw.backend.Logger().Warn("Activity rate limiter does not support changing limits, ignoring update")
+		}
+	}
+
+	if o.ActivityLimits != nil {
+		aw.guard.setLimits(o.ActivityLimits)
+	}
+}
+
+// Drain stops polling for new activity tasks. Activity tasks that have already been received keep being
+// executed and heartbeated until they complete. A worker drained before it's started doesn't poll at all.
+func (aw *ActivityWorker) Drain() {
+	aw.drainMu.Lock()
+	defer aw.drainMu.Unlock()
+
+	if aw.draining {
+		return
+	}
+
+	aw.draining = true
+	if aw.pollers != nil {
+		aw.pollers.Resize(0)
+	}
+
+	aw.backend.Logger().Debug("Draining activity worker", "active_tasks", atomic.LoadInt64(&aw.activeTasks))
+}
+
+// DrainStatus returns the current drain progress.
+func (aw *ActivityWorker) DrainStatus() DrainStatus {
+	aw.drainMu.Lock()
+	defer aw.drainMu.Unlock()
+
+	return DrainStatus{
+		Draining:    aw.draining,
+		ActiveTasks: int(atomic.LoadInt64(&aw.activeTasks)),
+	}
+}
+
 func (aw *ActivityWorker) WaitForCompletion() error {
-	close(aw.activityTaskQueue)
+	aw.activityTaskQueue.Close()
 
 	aw.wg.Wait()
 
@@ -75,24 +183,32 @@ func (aw *ActivityWorker) runPoll(ctx context.Context) {
 			}
 
 			if task != nil {
-				aw.activityTaskQueue <- task
+				atomic.AddInt64(&aw.activeTasks, 1)
+
+				if err := aw.activityTaskQueue.Push(ctx, newActivityTask(task, aw.clock.Now())); err != nil {
+					// The task is picked up again once its lock expires
+					atomic.AddInt64(&aw.activeTasks, -1)
+					aw.backend.Logger().Warn("Could not queue activity task", "task_id", task.ID, "error", err)
+				}
 			}
 		}
 	}
 }
 
 func (aw *ActivityWorker) runDispatcher(ctx context.Context) {
-	var sem chan struct{}
-	if aw.options.MaxParallelActivityTasks > 0 {
-		sem = make(chan struct{}, aw.options.MaxParallelActivityTasks)
-	}
+	defer aw.wg.Done()
+
+	for {
+		// Wait for capacity before popping, so that tasks wait in the queue and are executed in its order
+		aw.sem.Acquire()
 
-	for task := range aw.activityTaskQueue {
-		if sem != nil {
-			sem <- struct{}{}
+		t, ok := aw.activityTaskQueue.Pop()
+		if !ok {
+			aw.sem.Release()
+			return
 		}
 
-		task := task
+		task := t.task
 
 		aw.wg.Add(1)
 		go func() {
@@ -101,27 +217,65 @@ func (aw *ActivityWorker) runDispatcher(ctx context.Context) {
 			// Create new context to allow activities to complete when root context is canceled
 			taskCtx := context.Background()
 			aw.handleTask(taskCtx, task)
+			atomic.AddInt64(&aw.activeTasks, -1)
 
-			if sem != nil {
-				<-sem
-			}
+			aw.sem.Release()
 		}()
 	}
 }
 
 func (aw *ActivityWorker) handleTask(ctx context.Context, task *task.Activity) {
-	a := task.Event.Attributes.(*history.ActivityScheduledAttributes)
+	a, err := history.AttributesAs[*history.ActivityScheduledAttributes](&task.Event)
+	if err != nil {
+		aw.backend.Logger().Error("Could not read activity attributes", "activity_id", task.ID, "error", err)
+
+		event := history.NewPendingEvent(
+			aw.clock.Now(),
+			history.EventType_ActivityFailed,
+			&history.ActivityFailedAttributes{
+				Reason: err.Error(),
+			},
+			history.ScheduleEventID(task.Event.ScheduleEventID),
+			history.CausedBy(task.Event.ID),
+		)
+
+		if err := aw.backend.CompleteActivityTask(ctx, task.WorkflowInstance, task.ID, event); err != nil {
+			aw.backend.Logger().Panic("completing activity task", "error", err)
+		}
+
+		return
+	}
+
 	ametrics := aw.backend.Metrics().WithTags(metrics.Tags{metrickeys.ActivityName: a.Name})
 
 	// Record how long this task was in the queue
 	scheduledAt := task.Event.Timestamp
 	timeInQueue := time.Since(scheduledAt)
 	ametrics.Distribution(metrickeys.ActivityTaskDelay, metrics.Tags{}, float64(timeInQueue/time.Millisecond))
+	ametrics.Distribution(metrickeys.ActivityTaskAttempt, metrics.Tags{}, float64(a.Attempt))
+
+	inputSize := payloadSize(a.Inputs...)
+	ametrics.Distribution(metrickeys.ActivityInputSize, metrics.Tags{}, float64(inputSize))
+
+	// Details of the last heartbeat, either stored with the task if it's delivered again, or passed on from the
+	// previous attempt
+	heartbeatDetails := task.HeartbeatDetails
+	if heartbeatDetails == nil {
+		heartbeatDetails = a.HeartbeatDetails
+	}
+	hb := activity.NewHeartbeat(heartbeatDetails)
+	ctx = activity.WithHeartbeat(ctx, hb)
 
 	// Start heartbeat while activity is running
 	heartbeatCtx, cancelHeartbeat := context.WithCancel(ctx)
 	go func(ctx context.Context) {
-		t := time.NewTicker(aw.options.ActivityHeartbeatInterval)
+		interval := aw.options.ActivityHeartbeatInterval
+		if d := a.HeartbeatTimeout / 2; d > 0 && d < interval {
+			// Move the heartbeat timeout scheduled by the executor before it fires
+			interval = d
+		}
+
+		t := time.NewTicker(interval)
 		defer t.Stop()
 
 		for {
@@ -129,20 +283,158 @@ func (aw *ActivityWorker) handleTask(ctx context.Context, task *task.Activity) {
 			case <-ctx.Done():
 				return
 			case <-t.C:
+				if details, ok := hb.TakePending(); ok {
+					if r, ok := aw.backend.(backend.ActivityHeartbeatRecorder); ok {
+						if err := r.RecordActivityHeartbeat(ctx, task.ID, details); err != nil {
+							aw.backend.Logger().Panic("recording activity heartbeat", "error", err)
+						}
+
+						aw.rescheduleHeartbeatTimeout(ctx, task, a, hb)
+
+						continue
+					}
+				}
+
 				if err := aw.backend.ExtendActivityTask(ctx, task.ID); err != nil {
 					aw.backend.Logger().Panic("extending activity task", "error", err)
 				}
+
+				aw.rescheduleHeartbeatTimeout(ctx, task, a, hb)
 			}
 		}
 	}(heartbeatCtx)
 
+	// Fail attempts that were not started in time right away, without executing them
+	startErr := checkScheduleToStart(a, visibleSince(task.Event), aw.clock.Now())
+
+	// Wait until this activity may be executed. If that fails, the task is not completed, and is picked up
+	// again once its lock expires.
+	if startErr == nil && aw.options.ActivityRateLimiter != nil {
+		if err := aw.options.ActivityRateLimiter.Wait(ctx, a.Name); err != nil {
+			cancelHeartbeat()
+
+			aw.backend.Logger().Error("Waiting for activity rate limiter, not executing activity",
+				"activity", a.Name,
+				"activity_id", task.ID,
+				"instance_id", task.WorkflowInstance.InstanceID,
+				"error", err,
+			)
+
+			return
+		}
+	}
+
+	// Fail fast while the circuit for this activity is open
+	var circuitDone func(error) bool
+	allowed := false
+	if startErr == nil {
+		circuitDone, allowed = aw.circuits.acquire(a.Name)
+	}
+
+	info := TaskInfo{Kind: TaskKindActivity, ID: task.ID, Instance: task.WorkflowInstance, Name: a.Name}
+	aw.options.Hooks.taskStarted(ctx, info)
+
+	done := aw.inFlight.add(info)
+	defer done()
+
 	timer := metrics.Timer(ametrics, metrickeys.ActivityTaskProcessed, metrics.Tags{})
 	defer timer.Stop()
 
-	result, err := aw.activityTaskExecutor.ExecuteActivity(ctx, task)
+	if a.Stream != "" {
+		ctx = activity.WithStreamer(ctx, func(ctx context.Context, chunk payload.Payload) error {
+			return aw.backend.SignalWorkflow(ctx, task.WorkflowInstance.InstanceID, history.NewPendingEvent(
+				aw.clock.Now(),
+				history.EventType_SignalReceived,
+				&history.SignalReceivedAttributes{
+					Name: a.Stream,
+					Arg:  chunk,
+				},
+				history.CausedBy(task.Event.ID),
+			))
+		})
+	}
+
+	start := time.Now()
+	var result payload.Payload
+	if allowed {
+		aw.reportStarted(ctx, task, a)
+
+		execute := func(ctx context.Context) (payload.Payload, error) {
+			return aw.activityTaskExecutor.ExecuteActivity(ctx, task)
+		}
+
+		if a.HeartbeatTimeout > 0 {
+			executeActivity := execute
+			execute = func(ctx context.Context) (payload.Payload, error) {
+				return executeWithHeartbeatTimeout(ctx, a.Name, a.HeartbeatTimeout, hb, executeActivity)
+			}
+		}
+
+		if a.StartToCloseTimeout > 0 {
+			executeActivity := execute
+			execute = func(ctx context.Context) (payload.Payload, error) {
+				return executeWithStartToCloseTimeout(ctx, a.Name, a.StartToCloseTimeout, executeActivity)
+			}
+		}
+
+		withProfilerLabels(ctx, aw.options.ProfilerLabels, info, func(ctx context.Context) {
+			result, err = aw.guard.execute(ctx, a.Name, execute)
+		})
+
+		if circuitDone(err) {
+			ametrics.Counter(metrickeys.ActivityCircuitOpened, metrics.Tags{}, 1)
+
+			aw.backend.Logger().Warn("Activity circuit opened",
+				"activity", a.Name,
+				"activity_id", task.ID,
+				"instance_id", task.WorkflowInstance.InstanceID,
+				"error", err,
+			)
+		}
+	} else if startErr != nil {
+		err = startErr
+	} else {
+		err = &ActivityCircuitOpenError{Activity: a.Name}
+		ametrics.Counter(metrickeys.ActivityCircuitRejected, metrics.Tags{}, 1)
+	}
+	duration := time.Since(start)
+
+	var limitErr *ActivityLimitError
+	if errors.As(err, &limitErr) {
+		ametrics.Counter(metrickeys.ActivityLimitExceeded, metrics.Tags{metrickeys.ActivityLimit: string(limitErr.Limit)}, 1)
+
+		aw.backend.Logger().Warn("Activity exceeded limit",
+			"activity", a.Name,
+			"activity_id", task.ID,
+			"instance_id", task.WorkflowInstance.InstanceID,
+			"limit", limitErr.Limit,
+		)
+	}
 
 	cancelHeartbeat()
 
+	resultSize := payloadSize(result)
+	if err == nil {
+		ametrics.Distribution(metrickeys.ActivityResultSize, metrics.Tags{}, float64(resultSize))
+	}
+
+	if aw.options.SlowActivityThreshold > 0 && duration > aw.options.SlowActivityThreshold {
+		ametrics.Counter(metrickeys.ActivityTaskSlow, metrics.Tags{}, 1)
+
+		aw.backend.Logger().Warn("Slow activity execution",
+			"activity", a.Name,
+			"activity_id", task.ID,
+			"instance_id", task.WorkflowInstance.InstanceID,
+			"execution_id", task.WorkflowInstance.ExecutionID,
+			"attempt", a.Attempt,
+			"duration_ms", duration.Milliseconds(),
+			"time_in_queue_ms", timeInQueue.Milliseconds(),
+			"input_size", inputSize,
+			"result_size", resultSize,
+			"failed", err != nil,
+		)
+	}
+
 	var event history.Event
 
 	if err != nil {
@@ -150,9 +442,12 @@ func (aw *ActivityWorker) handleTask(ctx context.Context, task *task.Activity) {
 			aw.clock.Now(),
 			history.EventType_ActivityFailed,
 			&history.ActivityFailedAttributes{
-				Reason: err.Error(),
+				Reason:           err.Error(),
+				Type:             workflowerrors.Type(err),
+				HeartbeatDetails: hb.Details(),
 			},
 			history.ScheduleEventID(task.Event.ScheduleEventID),
+			history.CausedBy(task.Event.ID),
 		)
 	} else {
 		event = history.NewPendingEvent(
@@ -161,12 +456,25 @@ func (aw *ActivityWorker) handleTask(ctx context.Context, task *task.Activity) {
 			&history.ActivityCompletedAttributes{
 				Result: result,
 			},
-			history.ScheduleEventID(task.Event.ScheduleEventID))
+			history.ScheduleEventID(task.Event.ScheduleEventID),
+			history.CausedBy(task.Event.ID),
+		)
 	}
 
 	if err := aw.backend.CompleteActivityTask(ctx, task.WorkflowInstance, task.ID, event); err != nil {
 		aw.backend.Logger().Panic("completing activity task", "error", err)
 	}
+
+	aw.options.Hooks.taskCompleted(ctx, info, err)
+}
+
+func payloadSize(payloads ...payload.Payload) int {
+	size := 0
+	for _, p := range payloads {
+		size += len(p)
+	}
+
+	return size
 }
 
 func (aw *ActivityWorker) poll(ctx context.Context, timeout time.Duration) (*task.Activity, error) {
@@ -194,3 +502,58 @@ func (aw *ActivityWorker) poll(ctx context.Context, timeout time.Duration) (*tas
 		return task, err
 	}
 }
+
+// reportStarted records in the history of the workflow instance that the activity has been started, if it has a
+// schedule-to-start or start-to-close timeout, or a heartbeat timeout the backend can reschedule. The executor
+// enforces these timeouts with future events, even if no worker picks up the activity or the worker executing it
+// goes away.
+func (aw *ActivityWorker) reportStarted(ctx context.Context, task *task.Activity, a *history.ActivityScheduledAttributes) {
+	_, reschedules := aw.backend.(backend.ActivityHeartbeatTimeoutRescheduler)
+	reschedulesHeartbeatTimeout := reschedules && a.HeartbeatTimeout > 0
+
+	if a.ScheduleToStartTimeout <= 0 && a.StartToCloseTimeout <= 0 && !reschedulesHeartbeatTimeout {
+		return
+	}
+
+	if err := aw.backend.SignalWorkflow(ctx, task.WorkflowInstance.InstanceID, history.NewPendingEvent(
+		aw.clock.Now(),
+		history.EventType_ActivityStarted,
+		&history.ActivityStartedAttributes{
+			ReschedulesHeartbeatTimeout: reschedulesHeartbeatTimeout,
+		},
+		history.ScheduleEventID(task.Event.ScheduleEventID),
+		history.CausedBy(task.Event.ID),
+	)); err != nil {
+		// The activity is still executed, but the executor might fail it when its schedule-to-start timeout expires
+		aw.backend.Logger().Warn("Reporting activity start",
+			"activity", a.Name,
+			"activity_id", task.ID,
+			"instance_id", task.WorkflowInstance.InstanceID,
+			"error", err,
+		)
+	}
+}
+
+// rescheduleHeartbeatTimeout moves the heartbeat timeout the executor scheduled when the activity was started, so
+// that it fires one heartbeat timeout after the last heartbeat of the activity.
+func (aw *ActivityWorker) rescheduleHeartbeatTimeout(
+	ctx context.Context, task *task.Activity, a *history.ActivityScheduledAttributes, hb *activity.Heartbeat,
+) {
+	r, ok := aw.backend.(backend.ActivityHeartbeatTimeoutRescheduler)
+	if !ok || a.HeartbeatTimeout <= 0 {
+		return
+	}
+
+	// Heartbeats are timed with the local clock, the backend rebases the deadline onto its own
+	event := history.NewActivityHeartbeatTimeoutEvent(
+		time.Now(), hb.Last().Add(a.HeartbeatTimeout), task.Event.ID, task.Event.ScheduleEventID, a.Name, a.HeartbeatTimeout, hb.Details())
+	if err := r.RescheduleActivityHeartbeatTimeout(ctx, task.WorkflowInstance, &event); err != nil {
+		// The activity is still executed, but the executor might fail it when its heartbeat timeout expires
+		aw.backend.Logger().Warn("Rescheduling activity heartbeat timeout",
+			"activity", a.Name,
+			"activity_id", task.ID,
+			"instance_id", task.WorkflowInstance.InstanceID,
+			"error", err,
+		)
+	}
+}
@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/logger"
+	mi "github.com/cschleiden/go-workflows/internal/metrics"
+	"github.com/cschleiden/go-workflows/internal/task"
+	"github.com/cschleiden/go-workflows/internal/workflow"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type failingRateLimiter struct{}
+
+func (failingRateLimiter) Wait(ctx context.Context, activityName string) error {
+	return errors.New("rate limiter unavailable")
+}
+
+func Test_HandleTask_RateLimiterError(t *testing.T) {
+	executed := false
+	r := workflow.NewRegistry()
+	require.NoError(t, r.RegisterActivityWithName("a", func(ctx context.Context) error {
+		executed = true
+		return nil
+	}))
+
+	// Any call to complete the task fails the test, since it's not set up on the mock
+	b := &backend.MockBackend{}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("Metrics").Return(mi.NewNoopMetricsClient())
+	b.On("Tracer").Return(trace.NewNoopTracerProvider().Tracer("test"))
+
+	aw := NewActivityWorker(b, r, clock.New(), &Options{
+		ActivityHeartbeatInterval: time.Minute,
+		ActivityRateLimiter:       failingRateLimiter{},
+	})
+
+	aw.handleTask(context.Background(), &task.Activity{
+		ID:               "activity",
+		WorkflowInstance: core.NewWorkflowInstance("instance", "execution"),
+		Event: history.NewHistoryEvent(1, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+			Name: "a",
+		}),
+	})
+
+	require.False(t, executed)
+	b.AssertNotCalled(t, "CompleteActivityTask")
+}
+
+func Test_Drain_BeforeStart(t *testing.T) {
+	b := &backend.MockBackend{}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("Metrics").Return(mi.NewNoopMetricsClient())
+	b.On("Tracer").Return(trace.NewNoopTracerProvider().Tracer("test"))
+
+	aw := NewActivityWorker(b, workflow.NewRegistry(), clock.New(), &Options{
+		ActivityPollers:          2,
+		MaxParallelActivityTasks: 1,
+	})
+
+	aw.Drain()
+	aw.ApplyDynamicOptions(DynamicOptions{ActivityPollers: 4})
+	require.Equal(t, DrainStatus{Draining: true}, aw.DrainStatus())
+
+	// A drained worker doesn't poll once it's started
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, aw.Start(ctx))
+	cancel()
+	require.NoError(t, aw.WaitForCompletion())
+
+	b.AssertNotCalled(t, "GetActivityTask")
+}
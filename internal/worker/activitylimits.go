@@ -0,0 +1,168 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/workflowerrors"
+)
+
+// ActivityLimits are resource guards for executions of an activity on a single worker.
+type ActivityLimits struct {
+	// MaxRuntime is the maximum wall-clock duration of a single execution. When it's exceeded, the context
+	// passed to the activity is canceled and the execution fails right away, even if the activity does not
+	// return. Activities that ignore their context keep running in the background until they return, and
+	// count towards MaxConcurrency until then. 0 is no limit.
+	MaxRuntime time.Duration
+
+	// MaxMemory is the heap size of the worker process in bytes above which new executions of the activity
+	// are not started. Go can't attribute memory to single activities, so this guards memory-hungry
+	// activities from being started on an already loaded worker. 0 is no limit.
+	MaxMemory uint64
+
+	// MaxConcurrency is the maximum number of concurrent executions on this worker, including ones that exceeded
+	// MaxRuntime but did not return yet. Executions exceeding it fail right away. 0 is no limit.
+	MaxConcurrency int
+}
+
+// ActivityLimit identifies the limit violated by an activity execution.
+type ActivityLimit string
+
+const (
+	ActivityLimitMaxRuntime     ActivityLimit = "max_runtime"
+	ActivityLimitMaxMemory      ActivityLimit = "max_memory"
+	ActivityLimitMaxConcurrency ActivityLimit = "max_concurrency"
+)
+
+// ActivityLimitError is the error an activity execution fails with when it violates one of its
+// ActivityLimits.
+type ActivityLimitError struct {
+	Activity string
+	Limit    ActivityLimit
+
+	// Value is the configured limit, e.g. "5s" for MaxRuntime.
+	Value string
+}
+
+func (e *ActivityLimitError) Error() string {
+	return fmt.Sprintf("activity %s exceeded limit %s (%s)", e.Activity, e.Limit, e.Value)
+}
+
+// Unwrap returns a *workflowerrors.TimeoutError for MaxRuntime violations, and a *workflowerrors.QuotaExceededError
+// for the other limits. Their type is recorded in the history.
+func (e *ActivityLimitError) Unwrap() error {
+	if e.Limit == ActivityLimitMaxRuntime {
+		return &workflowerrors.TimeoutError{Message: e.Error()}
+	}
+
+	return &workflowerrors.QuotaExceededError{Message: e.Error()}
+}
+
+type activityGuard struct {
+	limits map[string]ActivityLimits
+
+	mu      sync.Mutex
+	running map[string]int
+
+	// heapAlloc returns the current heap size, replaced in tests
+	heapAlloc func() uint64
+}
+
+func newActivityGuard(limits map[string]ActivityLimits) *activityGuard {
+	return &activityGuard{
+		limits:  limits,
+		running: make(map[string]int),
+		heapAlloc: func() uint64 {
+			var m runtime.MemStats
+			runtime.ReadMemStats(&m)
+			return m.HeapAlloc
+		},
+	}
+}
+
+// execute runs f within the limits configured for the given activity.
+func (g *activityGuard) execute(
+	ctx context.Context, name string, f func(ctx context.Context) (payload.Payload, error),
+) (payload.Payload, error) {
+	g.mu.Lock()
+	l, ok := g.limits[name]
+	g.mu.Unlock()
+
+	if !ok {
+		return f(ctx)
+	}
+
+	if l.MaxMemory > 0 && g.heapAlloc() > l.MaxMemory {
+		return nil, &ActivityLimitError{Activity: name, Limit: ActivityLimitMaxMemory, Value: fmt.Sprint(l.MaxMemory)}
+	}
+
+	release := func() {}
+	if l.MaxConcurrency > 0 {
+		if !g.acquire(name, l.MaxConcurrency) {
+			return nil, &ActivityLimitError{Activity: name, Limit: ActivityLimitMaxConcurrency, Value: fmt.Sprint(l.MaxConcurrency)}
+		}
+		release = func() { g.release(name) }
+	}
+
+	if l.MaxRuntime <= 0 {
+		defer release()
+		return f(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, l.MaxRuntime)
+	defer cancel()
+
+	type result struct {
+		p   payload.Payload
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		// Hold the concurrency slot until the activity returns, even if it outlives MaxRuntime
+		defer release()
+
+		p, err := f(ctx)
+		done <- result{p, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.p, r.err
+	case <-ctx.Done():
+		// The activity keeps running in the background if it ignores its context, but its result is dropped
+		return nil, &ActivityLimitError{Activity: name, Limit: ActivityLimitMaxRuntime, Value: l.MaxRuntime.String()}
+	}
+}
+
+// setLimits replaces the limits for subsequent executions. Running executions keep their limits.
+func (g *activityGuard) setLimits(limits map[string]ActivityLimits) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.limits = limits
+}
+
+func (g *activityGuard) acquire(name string, max int) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.running[name] >= max {
+		return false
+	}
+
+	g.running[name]++
+
+	return true
+}
+
+func (g *activityGuard) release(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.running[name]--
+}
@@ -0,0 +1,47 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ActivityGuard_MaxRuntimeKeepsConcurrencySlot(t *testing.T) {
+	g := newActivityGuard(map[string]ActivityLimits{
+		"a": {MaxRuntime: 10 * time.Millisecond, MaxConcurrency: 1},
+	})
+
+	// The activity ignores its context and returns only once unblocked
+	unblock := make(chan struct{})
+	returned := make(chan struct{})
+	_, err := g.execute(context.Background(), "a", func(ctx context.Context) (payload.Payload, error) {
+		defer close(returned)
+		<-unblock
+		return nil, nil
+	})
+
+	var lerr *ActivityLimitError
+	require.True(t, errors.As(err, &lerr))
+	require.Equal(t, ActivityLimitMaxRuntime, lerr.Limit)
+
+	// The timed out execution still runs, so there is no slot for another one
+	_, err = g.execute(context.Background(), "a", func(ctx context.Context) (payload.Payload, error) {
+		return nil, nil
+	})
+	require.True(t, errors.As(err, &lerr))
+	require.Equal(t, ActivityLimitMaxConcurrency, lerr.Limit)
+
+	close(unblock)
+	<-returned
+
+	require.Eventually(t, func() bool {
+		_, err := g.execute(context.Background(), "a", func(ctx context.Context) (payload.Payload, error) {
+			return nil, nil
+		})
+		return err == nil
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,261 @@
+package worker
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/task"
+)
+
+// ErrActivityTaskQueueClosed is returned by ActivityTaskQueue.Push once the queue has been closed.
+var ErrActivityTaskQueueClosed = errors.New("activity task queue closed")
+
+// ActivityTask is an activity task that has been received from the backend and waits to be executed.
+type ActivityTask struct {
+	// ID is the backend specific identifier of the task.
+	ID string
+
+	Instance *core.WorkflowInstance
+
+	// Name is the name of the activity.
+	Name string
+
+	// Queue is the queue the task was received from, see backend.WithActivityQueues.
+	Queue string
+
+	// Attempt is the retry attempt of the activity, starting at 0.
+	Attempt int
+
+	// ScheduledAt is the time the workflow scheduled the activity.
+	ScheduledAt time.Time
+
+	// ReceivedAt is the time the worker received the task from the backend.
+	ReceivedAt time.Time
+
+	// RetryDeadline is the time after which the activity is not retried anymore, zero if retries are not limited
+	// by time.
+	RetryDeadline time.Time
+
+	task *task.Activity
+}
+
+func newActivityTask(t *task.Activity, receivedAt time.Time) *ActivityTask {
+	at := &ActivityTask{
+		ID:          t.ID,
+		Instance:    t.WorkflowInstance,
+		Queue:       backend.ActivityQueue(&t.Event),
+		ScheduledAt: t.Event.Timestamp,
+		ReceivedAt:  receivedAt,
+		task:        t,
+	}
+
+	// Tasks with invalid attributes are failed when they are executed
+	if a, err := history.AttributesAs[*history.ActivityScheduledAttributes](&t.Event); err == nil {
+		at.Name = a.Name
+		at.Attempt = a.Attempt
+
+		if a.RetryDeadline != nil {
+			at.RetryDeadline = *a.RetryDeadline
+		}
+	}
+
+	return at
+}
+
+// ActivityTaskQueue holds activity tasks between receiving them from the backend and executing them. The worker
+// pops the next task whenever it can execute another one, see Options.MaxParallelActivityTasks, so the queue
+// decides the order in which tasks are executed.
+//
+// Tasks stay locked in the backend while they are queued, but they are only heartbeated once they are executed.
+// Queues should hold fewer tasks than can be executed within the activity lock timeout of the backend.
+type ActivityTaskQueue interface {
+	// Push adds a task received from the backend. It may block until there is room for the task, or the context
+	// is canceled. It returns ErrActivityTaskQueueClosed once the queue has been closed.
+	Push(ctx context.Context, t *ActivityTask) error
+
+	// Pop blocks until a task is available and removes it from the queue. It returns false once the queue has
+	// been closed and all queued tasks have been popped.
+	Pop() (*ActivityTask, bool)
+
+	// Close closes the queue. Tasks already in the queue can still be popped.
+	Close()
+}
+
+// channelActivityTaskQueue hands tasks directly from pollers to the worker. Pollers wait until the worker can
+// execute another task.
+type channelActivityTaskQueue struct {
+	c         chan *ActivityTask
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newChannelActivityTaskQueue() *channelActivityTaskQueue {
+	return &channelActivityTaskQueue{
+		c:      make(chan *ActivityTask),
+		closed: make(chan struct{}),
+	}
+}
+
+func (q *channelActivityTaskQueue) Push(ctx context.Context, t *ActivityTask) error {
+	select {
+	case <-q.closed:
+		return ErrActivityTaskQueueClosed
+	default:
+	}
+
+	select {
+	case q.c <- t:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-q.closed:
+		return ErrActivityTaskQueueClosed
+	}
+}
+
+func (q *channelActivityTaskQueue) Pop() (*ActivityTask, bool) {
+	select {
+	case t := <-q.c:
+		return t, true
+	case <-q.closed:
+		return nil, false
+	}
+}
+
+func (q *channelActivityTaskQueue) Close() {
+	q.closeOnce.Do(func() {
+		close(q.closed)
+	})
+}
+
+// priorityActivityTaskQueue holds up to size tasks and pops them in the order given by less.
+type priorityActivityTaskQueue struct {
+	mu    sync.Mutex
+	tasks activityTaskHeap
+
+	// slots bounds the number of queued tasks, available has a token for every queued task
+	slots     chan struct{}
+	available chan struct{}
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewPriorityActivityTaskQueue returns an ActivityTaskQueue holding up to size tasks, which are executed in the
+// order given by less: a task a is executed before a task b if less(a, b) is true. Tasks that are equal are
+// executed in the order they were received. Push blocks while the queue is full.
+//
+// For example, to execute the most recently scheduled activities first:
+//
+//	worker.NewPriorityActivityTaskQueue(10, func(a, b *worker.ActivityTask) bool {
+//		return a.ScheduledAt.After(b.ScheduledAt)
+//	})
+func NewPriorityActivityTaskQueue(size int, less func(a, b *ActivityTask) bool) ActivityTaskQueue {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &priorityActivityTaskQueue{
+		tasks:     activityTaskHeap{less: less},
+		slots:     make(chan struct{}, size),
+		available: make(chan struct{}, size),
+		closed:    make(chan struct{}),
+	}
+}
+
+func (q *priorityActivityTaskQueue) Push(ctx context.Context, t *ActivityTask) error {
+	select {
+	case <-q.closed:
+		return ErrActivityTaskQueueClosed
+	default:
+	}
+
+	select {
+	case q.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-q.closed:
+		return ErrActivityTaskQueueClosed
+	}
+
+	q.mu.Lock()
+	heap.Push(&q.tasks, t)
+	q.mu.Unlock()
+
+	q.available <- struct{}{}
+
+	return nil
+}
+
+func (q *priorityActivityTaskQueue) Pop() (*ActivityTask, bool) {
+	select {
+	case <-q.available:
+	case <-q.closed:
+		// Return tasks that were queued before the queue was closed
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.tasks.Len() == 0 {
+		return nil, false
+	}
+
+	t := heap.Pop(&q.tasks).(*ActivityTask)
+	<-q.slots
+
+	return t, true
+}
+
+func (q *priorityActivityTaskQueue) Close() {
+	q.closeOnce.Do(func() {
+		close(q.closed)
+	})
+}
+
+type queuedActivityTask struct {
+	t   *ActivityTask
+	seq uint64
+}
+
+type activityTaskHeap struct {
+	tasks []queuedActivityTask
+	less  func(a, b *ActivityTask) bool
+	seq   uint64
+}
+
+func (h activityTaskHeap) Len() int { return len(h.tasks) }
+
+func (h activityTaskHeap) Less(i, j int) bool {
+	a, b := h.tasks[i], h.tasks[j]
+	if h.less(a.t, b.t) {
+		return true
+	}
+
+	if h.less(b.t, a.t) {
+		return false
+	}
+
+	return a.seq < b.seq
+}
+
+func (h activityTaskHeap) Swap(i, j int) { h.tasks[i], h.tasks[j] = h.tasks[j], h.tasks[i] }
+
+func (h *activityTaskHeap) Push(x interface{}) {
+	h.seq++
+	h.tasks = append(h.tasks, queuedActivityTask{t: x.(*ActivityTask), seq: h.seq})
+}
+
+func (h *activityTaskHeap) Pop() interface{} {
+	n := len(h.tasks)
+	t := h.tasks[n-1]
+	h.tasks = h.tasks[:n-1]
+
+	return t.t
+}
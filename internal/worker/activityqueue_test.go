@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PriorityActivityTaskQueue(t *testing.T) {
+	base := time.Now()
+
+	// Most recently scheduled first
+	q := NewPriorityActivityTaskQueue(3, func(a, b *ActivityTask) bool {
+		return a.ScheduledAt.After(b.ScheduledAt)
+	})
+
+	ctx := context.Background()
+	require.NoError(t, q.Push(ctx, &ActivityTask{ID: "1", ScheduledAt: base}))
+	require.NoError(t, q.Push(ctx, &ActivityTask{ID: "2", ScheduledAt: base.Add(time.Second)}))
+	require.NoError(t, q.Push(ctx, &ActivityTask{ID: "3", ScheduledAt: base.Add(time.Second)}))
+
+	// Queue is full
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, q.Push(tctx, &ActivityTask{ID: "4"}), context.DeadlineExceeded)
+
+	next, ok := q.Pop()
+	require.True(t, ok)
+	require.Equal(t, "2", next.ID)
+
+	// Popping frees up a slot
+	require.NoError(t, q.Push(ctx, &ActivityTask{ID: "4", ScheduledAt: base.Add(-time.Second)}))
+
+	q.Close()
+	require.ErrorIs(t, q.Push(ctx, &ActivityTask{ID: "5"}), ErrActivityTaskQueueClosed)
+
+	// Queued tasks can still be popped after closing
+	var ids []string
+	for {
+		next, ok := q.Pop()
+		if !ok {
+			break
+		}
+
+		ids = append(ids, next.ID)
+	}
+
+	require.Equal(t, []string{"3", "1", "4"}, ids)
+}
+
+func Test_ChannelActivityTaskQueue(t *testing.T) {
+	q := newChannelActivityTaskQueue()
+
+	go func() {
+		require.NoError(t, q.Push(context.Background(), &ActivityTask{ID: "1"}))
+	}()
+
+	next, ok := q.Pop()
+	require.True(t, ok)
+	require.Equal(t, "1", next.ID)
+
+	q.Close()
+
+	_, ok = q.Pop()
+	require.False(t, ok)
+	require.ErrorIs(t, q.Push(context.Background(), &ActivityTask{ID: "2"}), ErrActivityTaskQueueClosed)
+}
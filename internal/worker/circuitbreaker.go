@@ -0,0 +1,196 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+// ActivityCircuitBreaker protects a worker from executions of an activity whose dependency is down. When the
+// share of failed executions within Window reaches FailureRate, the circuit opens and executions fail right
+// away with an *ActivityCircuitOpenError instead of occupying a task slot. After OpenDuration, a single
+// execution is let through as a probe: if it succeeds the circuit closes, otherwise it stays open for another
+// OpenDuration.
+type ActivityCircuitBreaker struct {
+	// FailureRate is the share of failed executions, between 0 and 1, at which the circuit opens.
+	FailureRate float64
+
+	// MinExecutions is the number of executions within Window required before the circuit can open.
+	// Defaults to 10.
+	MinExecutions int
+
+	// Window is the period over which executions are counted. Defaults to 1 minute.
+	Window time.Duration
+
+	// OpenDuration is how long executions fail fast before a probe execution is let through. Defaults to
+	// 30 seconds.
+	OpenDuration time.Duration
+}
+
+// ActivityCircuitOpenError is the error an activity execution fails with while the circuit for the activity is
+// open. Like any other activity error, it's subject to the retry options of the activity.
+type ActivityCircuitOpenError struct {
+	Activity string
+}
+
+func (e *ActivityCircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit for activity %s is open", e.Activity)
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+type circuit struct {
+	state circuitState
+
+	windowStart time.Time
+	executions  int
+	failures    int
+
+	openedAt time.Time
+}
+
+type circuitBreakers struct {
+	breakers map[string]ActivityCircuitBreaker
+	clock    clock.Clock
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+func newCircuitBreakers(breakers map[string]ActivityCircuitBreaker, clock clock.Clock) *circuitBreakers {
+	return &circuitBreakers{
+		breakers: breakers,
+		clock:    clock,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// acquire returns whether an execution of the given activity may start. If it may, done has to be called with
+// the outcome of the execution. opened is true if that outcome opened the circuit.
+func (cb *circuitBreakers) acquire(name string) (done func(err error) (opened bool), ok bool) {
+	b, configured := cb.breakers[name]
+	if !configured || b.FailureRate <= 0 {
+		return func(error) bool { return false }, true
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := cb.clock.Now()
+
+	c, found := cb.circuits[name]
+	if !found {
+		c = &circuit{windowStart: now}
+		cb.circuits[name] = c
+	}
+
+	switch c.state {
+	case circuitHalfOpen:
+		// A probe is already running
+		return nil, false
+
+	case circuitOpen:
+		if now.Sub(c.openedAt) < durationOrDefault(b.OpenDuration, 30*time.Second) {
+			return nil, false
+		}
+
+		c.state = circuitHalfOpen
+
+		return func(err error) bool {
+			return cb.probed(c, err)
+		}, true
+	}
+
+	return func(err error) bool {
+		return cb.record(c, b, err)
+	}, true
+}
+
+func (cb *circuitBreakers) record(c *circuit, b ActivityCircuitBreaker, err error) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	// Executions started before the circuit opened don't count towards the next window
+	if c.state != circuitClosed {
+		return false
+	}
+
+	now := cb.clock.Now()
+	if now.Sub(c.windowStart) >= durationOrDefault(b.Window, time.Minute) {
+		c.windowStart = now
+		c.executions = 0
+		c.failures = 0
+	}
+
+	c.executions++
+	if countsAsFailure(err) {
+		c.failures++
+	}
+
+	minExecutions := b.MinExecutions
+	if minExecutions <= 0 {
+		minExecutions = 10
+	}
+
+	if c.executions < minExecutions || float64(c.failures)/float64(c.executions) < b.FailureRate {
+		return false
+	}
+
+	c.state = circuitOpen
+	c.openedAt = now
+
+	return true
+}
+
+func (cb *circuitBreakers) probed(c *circuit, err error) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := cb.clock.Now()
+
+	if countsAsFailure(err) {
+		c.state = circuitOpen
+		c.openedAt = now
+
+		return true
+	}
+
+	c.state = circuitClosed
+	c.windowStart = now
+	c.executions = 0
+	c.failures = 0
+
+	return false
+}
+
+// countsAsFailure returns whether err indicates a failing dependency. Limits on memory and concurrency are
+// local to the worker and are not counted, exceeding the maximum runtime is.
+func countsAsFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var limitErr *ActivityLimitError
+	if errors.As(err, &limitErr) {
+		return limitErr.Limit == ActivityLimitMaxRuntime
+	}
+
+	return true
+}
+
+func durationOrDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+
+	return d
+}
@@ -0,0 +1,87 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CircuitBreakers(t *testing.T) {
+	c := clock.NewMock()
+	cb := newCircuitBreakers(map[string]ActivityCircuitBreaker{
+		"Charge": {FailureRate: 0.5, MinExecutions: 4, Window: time.Minute, OpenDuration: 10 * time.Second},
+	}, c)
+
+	execute := func(name string, err error) (opened, ok bool) {
+		done, ok := cb.acquire(name)
+		if !ok {
+			return false, false
+		}
+
+		return done(err), true
+	}
+
+	failure := errors.New("connection refused")
+
+	// Failures below the minimum number of executions don't open the circuit
+	for i := 0; i < 3; i++ {
+		opened, ok := execute("Charge", failure)
+		require.True(t, ok)
+		require.False(t, opened)
+	}
+
+	opened, ok := execute("Charge", nil)
+	require.True(t, ok)
+	require.True(t, opened, "3 of 4 executions failed")
+
+	// Open circuits fail fast, other activities are not affected
+	_, ok = execute("Charge", nil)
+	require.False(t, ok)
+
+	_, ok = execute("Refund", failure)
+	require.True(t, ok)
+
+	// After the open duration a single probe is let through
+	c.Add(10 * time.Second)
+
+	done, ok := cb.acquire("Charge")
+	require.True(t, ok)
+
+	_, ok = execute("Charge", nil)
+	require.False(t, ok, "only one probe at a time")
+
+	require.True(t, done(failure), "failed probe reopens the circuit")
+
+	_, ok = execute("Charge", nil)
+	require.False(t, ok)
+
+	c.Add(10 * time.Second)
+
+	opened, ok = execute("Charge", nil)
+	require.True(t, ok)
+	require.False(t, opened, "successful probe closes the circuit")
+
+	_, ok = execute("Charge", nil)
+	require.True(t, ok)
+}
+
+func Test_CircuitBreakers_Window(t *testing.T) {
+	c := clock.NewMock()
+	cb := newCircuitBreakers(map[string]ActivityCircuitBreaker{
+		"Charge": {FailureRate: 0.5, MinExecutions: 2, Window: time.Minute},
+	}, c)
+
+	done, _ := cb.acquire("Charge")
+	require.False(t, done(errors.New("timeout")))
+
+	// The failure from the previous window is not counted, and local limits don't count as failures
+	c.Add(time.Minute)
+
+	done, _ = cb.acquire("Charge")
+	require.False(t, done(&ActivityLimitError{Activity: "Charge", Limit: ActivityLimitMaxConcurrency}))
+	done, _ = cb.acquire("Charge")
+	require.True(t, done(&ActivityLimitError{Activity: "Charge", Limit: ActivityLimitMaxRuntime}))
+}
@@ -0,0 +1,72 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+type workflowConcurrencyLimiter struct {
+	mu     sync.Mutex
+	limits map[string]int
+	slots  map[string]map[string]struct{}
+}
+
+// NewWorkflowConcurrencyLimiter returns a WorkflowConcurrencyLimiter enforcing the given limits, keyed by
+// workflow name, for a single worker. Workflows without a limit are not restricted. Slots are kept in memory,
+// so the limiter only works if a single worker executes the limited workflows. Use a backend specific limiter
+// to enforce limits across multiple workers.
+func NewWorkflowConcurrencyLimiter(limits map[string]int) WorkflowConcurrencyLimiter {
+	return &workflowConcurrencyLimiter{
+		limits: limits,
+		slots:  make(map[string]map[string]struct{}),
+	}
+}
+
+func (l *workflowConcurrencyLimiter) Acquire(_ context.Context, workflowName, instanceID string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit, ok := l.limits[workflowName]
+	if !ok || limit <= 0 {
+		return true, nil
+	}
+
+	slots, ok := l.slots[workflowName]
+	if !ok {
+		slots = make(map[string]struct{})
+		l.slots[workflowName] = slots
+	}
+
+	if _, ok := slots[instanceID]; ok {
+		return true, nil
+	}
+
+	if len(slots) >= limit {
+		return false, nil
+	}
+
+	slots[instanceID] = struct{}{}
+
+	return true, nil
+}
+
+func (l *workflowConcurrencyLimiter) Release(_ context.Context, workflowName, instanceID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.slots[workflowName], instanceID)
+
+	return nil
+}
+
+func (l *workflowConcurrencyLimiter) Holders(_ context.Context, workflowName string) ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	holders := make([]string, 0, len(l.slots[workflowName]))
+	for instanceID := range l.slots[workflowName] {
+		holders = append(holders, instanceID)
+	}
+
+	return holders, nil
+}
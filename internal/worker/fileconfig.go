@@ -0,0 +1,148 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// fileConfig is the format of configuration files read by FileConfigWatcher. It covers all DynamicOptions, other
+// worker options can't be changed at runtime. Durations are strings as accepted by time.ParseDuration.
+type fileConfig struct {
+	WorkflowPollers          int `json:"workflow_pollers"`
+	MaxParallelWorkflowTasks int `json:"max_parallel_workflow_tasks"`
+	ActivityPollers          int `json:"activity_pollers"`
+	MaxParallelActivityTasks int `json:"max_parallel_activity_tasks"`
+
+	// ActivityRateLimits are keyed by activity name.
+	ActivityRateLimits map[string]int `json:"activity_rate_limits"`
+
+	// ActivityLimits are keyed by activity name.
+	ActivityLimits map[string]fileActivityLimits `json:"activity_limits"`
+}
+
+type fileActivityLimits struct {
+	MaxRuntime     string `json:"max_runtime"`
+	MaxMemory      uint64 `json:"max_memory"`
+	MaxConcurrency int    `json:"max_concurrency"`
+}
+
+func (c *fileConfig) dynamicOptions() (DynamicOptions, error) {
+	o := DynamicOptions{
+		WorkflowPollers:          c.WorkflowPollers,
+		MaxParallelWorkflowTasks: c.MaxParallelWorkflowTasks,
+		ActivityPollers:          c.ActivityPollers,
+		MaxParallelActivityTasks: c.MaxParallelActivityTasks,
+		ActivityRateLimits:       c.ActivityRateLimits,
+	}
+
+	if c.ActivityLimits != nil {
+		o.ActivityLimits = make(map[string]ActivityLimits, len(c.ActivityLimits))
+		for name, l := range c.ActivityLimits {
+			var maxRuntime time.Duration
+			if l.MaxRuntime != "" {
+				var err error
+				if maxRuntime, err = time.ParseDuration(l.MaxRuntime); err != nil {
+					return o, fmt.Errorf("parsing max_runtime of activity %s: %w", name, err)
+				}
+			}
+
+			o.ActivityLimits[name] = ActivityLimits{
+				MaxRuntime:     maxRuntime,
+				MaxMemory:      l.MaxMemory,
+				MaxConcurrency: l.MaxConcurrency,
+			}
+		}
+	}
+
+	return o, nil
+}
+
+// FileConfigWatcher is a ConfigWatcher reading DynamicOptions from a JSON file. The file is checked for changes
+// periodically, and the options are delivered whenever its contents change.
+//
+// Supported keys are workflow_pollers, max_parallel_workflow_tasks, activity_pollers, max_parallel_activity_tasks,
+// activity_rate_limits, and activity_limits with max_runtime, max_memory, and max_concurrency per activity. Unknown
+// keys are ignored.
+type FileConfigWatcher struct {
+	path     string
+	interval time.Duration
+}
+
+// NewFileConfigWatcher returns a ConfigWatcher for the JSON file at the given path, checked for changes every
+// interval. Defaults to checking every 10 seconds.
+func NewFileConfigWatcher(path string, interval time.Duration) *FileConfigWatcher {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	return &FileConfigWatcher{
+		path:     path,
+		interval: interval,
+	}
+}
+
+// Watch reads the file and delivers its options right away. It fails if the file cannot be read or parsed.
+// Later changes that cannot be read or parsed are skipped, and the file is checked again after the next
+// interval.
+func (fw *FileConfigWatcher) Watch(ctx context.Context) (<-chan DynamicOptions, error) {
+	data, o, err := fw.read()
+	if err != nil {
+		return nil, err
+	}
+
+	c := make(chan DynamicOptions, 1)
+	c <- o
+
+	go func() {
+		defer close(c)
+
+		t := time.NewTicker(fw.interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-t.C:
+				newData, o, err := fw.read()
+				if err != nil || bytes.Equal(data, newData) {
+					continue
+				}
+
+				data = newData
+
+				select {
+				case c <- o:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+func (fw *FileConfigWatcher) read() ([]byte, DynamicOptions, error) {
+	data, err := os.ReadFile(fw.path)
+	if err != nil {
+		return nil, DynamicOptions{}, fmt.Errorf("reading worker configuration: %w", err)
+	}
+
+	var c fileConfig
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, DynamicOptions{}, fmt.Errorf("parsing worker configuration: %w", err)
+	}
+
+	o, err := c.dynamicOptions()
+	if err != nil {
+		return nil, DynamicOptions{}, err
+	}
+
+	return data, o, nil
+}
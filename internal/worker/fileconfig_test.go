@@ -0,0 +1,65 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FileConfigWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"workflow_pollers": 4,
+		"activity_rate_limits": {"send-email": 10},
+		"activity_limits": {"resize-image": {"max_runtime": "30s", "max_concurrency": 2}}
+	}`), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fw := NewFileConfigWatcher(path, time.Millisecond)
+	c, err := fw.Watch(ctx)
+	require.NoError(t, err)
+
+	o := <-c
+	require.Equal(t, 4, o.WorkflowPollers)
+	require.Equal(t, map[string]int{"send-email": 10}, o.ActivityRateLimits)
+	require.Equal(t, map[string]ActivityLimits{
+		"resize-image": {MaxRuntime: 30 * time.Second, MaxConcurrency: 2},
+	}, o.ActivityLimits)
+
+	// Invalid changes are skipped
+	require.NoError(t, os.WriteFile(path, []byte(`{"workflow_pollers": `), 0o600))
+	time.Sleep(10 * time.Millisecond)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"workflow_pollers": 8}`), 0o600))
+
+	select {
+	case o := <-c:
+		require.Equal(t, 8, o.WorkflowPollers)
+		require.Nil(t, o.ActivityLimits)
+	case <-time.After(time.Second):
+		require.FailNow(t, "configuration change was not delivered")
+	}
+
+	cancel()
+
+	// Channel is closed once the context is canceled
+	for range c {
+	}
+}
+
+func Test_FileConfigWatcher_InvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"activity_limits": {"a": {"max_runtime": "soon"}}}`), 0o600))
+
+	_, err := NewFileConfigWatcher(path, 0).Watch(context.Background())
+	require.Error(t, err)
+
+	_, err = NewFileConfigWatcher(filepath.Join(t.TempDir(), "missing.json"), 0).Watch(context.Background())
+	require.Error(t, err)
+}
@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// NewHealthHandler returns a handler for the health listener of a worker. /healthz succeeds as long as the
+// worker process serves requests, /readyz only while ready returns true. /inflight lists the tasks returned by
+// inFlight as JSON, and /metrics is served by metrics, if it's not nil.
+func NewHealthHandler(ready func() bool, inFlight func() []InFlightTask, metrics http.Handler) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("not ready"))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	mux.HandleFunc("/inflight", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(inFlight()); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	})
+
+	if metrics != nil {
+		mux.Handle("/metrics", metrics)
+	}
+
+	return mux
+}
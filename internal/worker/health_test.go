@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_HealthHandler(t *testing.T) {
+	ready := false
+	tasks := []InFlightTask{
+		{Kind: TaskKindActivity, ID: "1", Instance: core.NewWorkflowInstance("instance", "execution"), Name: "Activity"},
+	}
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("tasks 1"))
+	})
+
+	h := NewHealthHandler(func() bool { return ready }, func() []InFlightTask { return tasks }, metrics)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, get("/healthz").Code)
+	require.Equal(t, http.StatusServiceUnavailable, get("/readyz").Code)
+
+	ready = true
+	require.Equal(t, http.StatusOK, get("/readyz").Code)
+
+	rec := get("/inflight")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var inFlight []InFlightTask
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &inFlight))
+	require.Len(t, inFlight, 1)
+	require.Equal(t, "Activity", inFlight[0].Name)
+
+	rec = get("/metrics")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "tasks 1", rec.Body.String())
+}
+
+func Test_HealthHandler_WithoutMetrics(t *testing.T) {
+	h := NewHealthHandler(func() bool { return true }, func() []InFlightTask { return nil }, nil)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/activity"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/workflowerrors"
+)
+
+// executeWithHeartbeatTimeout executes f and fails it with a TimeoutError if it doesn't record a heartbeat
+// within the given timeout.
+func executeWithHeartbeatTimeout(
+	ctx context.Context, name string, timeout time.Duration, hb *activity.Heartbeat,
+	f func(ctx context.Context) (payload.Payload, error),
+) (payload.Payload, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		p   payload.Payload
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		p, err := f(ctx)
+		done <- result{p, err}
+	}()
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+
+	for {
+		select {
+		case r := <-done:
+			return r.p, r.err
+		case <-t.C:
+			remaining := timeout - time.Since(hb.Last())
+			if remaining > 0 {
+				t.Reset(remaining)
+				continue
+			}
+
+			// The activity keeps running in the background if it ignores its context, but its result is dropped
+			return nil, &workflowerrors.TimeoutError{
+				Message: fmt.Sprintf("activity %s did not record a heartbeat within %v", name, timeout),
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/activity"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/workflowerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ExecuteWithHeartbeatTimeout(t *testing.T) {
+	t.Run("HeartbeatsKeepActivityAlive", func(t *testing.T) {
+		hb := activity.NewHeartbeat(nil)
+
+		r, err := executeWithHeartbeatTimeout(context.Background(), "a", 50*time.Millisecond, hb, func(ctx context.Context) (payload.Payload, error) {
+			for i := 0; i < 5; i++ {
+				time.Sleep(20 * time.Millisecond)
+				hb.Record(payload.Payload("progress"))
+			}
+
+			return payload.Payload("done"), nil
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, payload.Payload("done"), r)
+		require.Equal(t, payload.Payload("progress"), hb.Details())
+	})
+
+	t.Run("MissingHeartbeatTimesOut", func(t *testing.T) {
+		hb := activity.NewHeartbeat(nil)
+
+		_, err := executeWithHeartbeatTimeout(context.Background(), "a", 20*time.Millisecond, hb, func(ctx context.Context) (payload.Payload, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+		var terr *workflowerrors.TimeoutError
+		require.True(t, errors.As(err, &terr))
+		require.Equal(t, "activity a did not record a heartbeat within 20ms", err.Error())
+	})
+}
@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/sync"
+)
+
+type TaskKind string
+
+const (
+	TaskKindWorkflow TaskKind = "workflow"
+	TaskKindActivity TaskKind = "activity"
+)
+
+// TaskInfo describes a task processed by the worker.
+type TaskInfo struct {
+	Kind TaskKind
+
+	// ID is the backend specific identifier of the task.
+	ID string
+
+	Instance *core.WorkflowInstance
+
+	// Name is the name of the activity for activity tasks. For workflow tasks, it's the name of the workflow
+	// and only known once the task has been executed.
+	Name string
+
+	// Scheduler holds statistics about the workflow goroutines of the instance after a workflow task has
+	// been executed.
+	Scheduler *sync.SchedulerStats
+}
+
+// Hooks are called at points in the lifecycle of a worker, e.g., to integrate with custom telemetry or the
+// startup and shutdown of a service framework. All hooks are optional. They are called synchronously, so
+// they should return quickly.
+type Hooks struct {
+	// OnWorkerStart is called once the worker has started polling for tasks.
+	OnWorkerStart func(ctx context.Context)
+
+	// OnWorkerStop is called from WaitForCompletion, once all in-flight tasks have finished.
+	OnWorkerStop func()
+
+	// OnTaskStart is called before a workflow or activity task is executed.
+	OnTaskStart func(ctx context.Context, info TaskInfo)
+
+	// OnTaskComplete is called after a task has been executed and its result has been handed to the backend.
+	// err is the error the task failed with, if any.
+	OnTaskComplete func(ctx context.Context, info TaskInfo, err error)
+
+	// OnCacheEvict is called when a workflow executor is evicted from the default executor cache. reason is
+	// one of "expired", "capacity", or "memory". It's not called for a custom WorkflowExecutorCache.
+	OnCacheEvict func(instance *core.WorkflowInstance, reason string)
+
+	// OnSLOViolation is called when an instance violates an objective declared in Options.WorkflowSLOs.
+	OnSLOViolation func(ctx context.Context, violation SLOViolation)
+}
+
+func (h *Hooks) taskStarted(ctx context.Context, info TaskInfo) {
+	if h.OnTaskStart != nil {
+		h.OnTaskStart(ctx, info)
+	}
+}
+
+func (h *Hooks) taskCompleted(ctx context.Context, info TaskInfo, err error) {
+	if h.OnTaskComplete != nil {
+		h.OnTaskComplete(ctx, info, err)
+	}
+}
@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/core"
+)
+
+// InFlightTask is a workflow or activity task that's currently being executed by the worker.
+type InFlightTask struct {
+	Kind TaskKind `json:"kind"`
+
+	// ID is the backend specific identifier of the task.
+	ID string `json:"id"`
+
+	Instance *core.WorkflowInstance `json:"instance"`
+
+	// Name is the name of the activity for activity tasks. It's empty for workflow tasks.
+	Name string `json:"name,omitempty"`
+
+	StartedAt time.Time `json:"started_at"`
+
+	// Elapsed is the time since the task was started, when the list of in-flight tasks was taken.
+	Elapsed time.Duration `json:"elapsed_ns"`
+}
+
+// inFlightTasks keeps track of the tasks being executed by a worker.
+type inFlightTasks struct {
+	mu    sync.Mutex
+	next  int
+	tasks map[int]InFlightTask
+}
+
+func newInFlightTasks() *inFlightTasks {
+	return &inFlightTasks{
+		tasks: make(map[int]InFlightTask),
+	}
+}
+
+// add records the start of the given task, and returns a function to call once it has finished.
+func (f *inFlightTasks) add(info TaskInfo) func() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := f.next
+	f.next++
+
+	f.tasks[id] = InFlightTask{
+		Kind:      info.Kind,
+		ID:        info.ID,
+		Instance:  info.Instance,
+		Name:      info.Name,
+		StartedAt: time.Now(),
+	}
+
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		delete(f.tasks, id)
+	}
+}
+
+// List returns the in-flight tasks, longest running first.
+func (f *inFlightTasks) List() []InFlightTask {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+
+	r := make([]InFlightTask, 0, len(f.tasks))
+	for _, t := range f.tasks {
+		t.Elapsed = now.Sub(t.StartedAt)
+		r = append(r, t)
+	}
+
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].StartedAt.Before(r[j].StartedAt)
+	})
+
+	return r
+}
@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_InFlightTasks(t *testing.T) {
+	f := newInFlightTasks()
+
+	wfi := core.NewWorkflowInstance("instance", "execution")
+
+	done1 := f.add(TaskInfo{Kind: TaskKindWorkflow, ID: "1", Instance: wfi})
+	time.Sleep(time.Millisecond)
+	done2 := f.add(TaskInfo{Kind: TaskKindActivity, ID: "2", Instance: wfi, Name: "Activity"})
+
+	tasks := f.List()
+	require.Len(t, tasks, 2)
+	require.Equal(t, "1", tasks[0].ID)
+	require.Equal(t, "2", tasks[1].ID)
+	require.Equal(t, "Activity", tasks[1].Name)
+	require.GreaterOrEqual(t, tasks[0].Elapsed, tasks[1].Elapsed)
+
+	done1()
+
+	tasks = f.List()
+	require.Len(t, tasks, 1)
+	require.Equal(t, "2", tasks[0].ID)
+
+	done2()
+	require.Empty(t, f.List())
+}
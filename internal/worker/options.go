@@ -1,9 +1,14 @@
 package worker
 
 import (
+	"context"
+	"net/http"
 	"time"
 
+	"github.com/cschleiden/go-workflows/internal/sync"
 	"github.com/cschleiden/go-workflows/internal/workflow"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+	wf "github.com/cschleiden/go-workflows/workflow"
 )
 
 type Options struct {
@@ -14,6 +19,18 @@ type Options struct {
 	// by the worker. The default is 0 which is no limit.
 	MaxParallelWorkflowTasks int
 
+	// WorkflowTaskChunkSize is the number of new events executed before the progress of a large workflow task is
+	// committed. Tasks with more new events are executed in chunks, and each chunk is committed while the task
+	// stays locked, so that a crash only requires executing the current chunk again. If the WorkflowExecutionStarted
+	// event comes after the first events, the first chunk includes all events up to it. Only applies to backends
+	// implementing backend.WorkflowTaskCheckpointer. The default is 0 which executes tasks in one go.
+	WorkflowTaskChunkSize int
+
+	// MaxConsecutiveInstanceTasks is the maximum number of tasks for the same workflow instance the worker
+	// processes in a row before yielding to tasks of other instances. Only applies to backends implementing
+	// backend.WorkflowTaskAbandoner. The default is 0 which is no limit.
+	MaxConsecutiveInstanceTasks int
+
 	// ActivityPollers is the number of pollers to start. Defaults to 2.
 	ActivityPollers int
 
@@ -25,6 +42,80 @@ type Options struct {
 	// to 25 seconds
 	ActivityHeartbeatInterval time.Duration
 
+	// ActivityRateLimiter, if set, is consulted before every activity execution. It allows limiting the
+	// rate at which activities are executed, potentially across a fleet of workers.
+	ActivityRateLimiter ActivityRateLimiter
+
+	// ActivityTaskQueue, if set, holds activity tasks received from the backend until they can be executed and
+	// determines the order in which they are executed, see NewPriorityActivityTaskQueue. Tasks only wait in the
+	// queue if MaxParallelActivityTasks is set. A queue must not be shared between workers. Defaults to handing
+	// tasks directly to the next free execution slot.
+	ActivityTaskQueue ActivityTaskQueue
+
+	// WorkflowConcurrencyLimiter, if set, limits the number of concurrently running instances per
+	// workflow name. Starting an instance while all slots are taken is delayed until a slot frees up. Slots of
+	// instances that finished or were removed without releasing them are freed when a start is delayed.
+	WorkflowConcurrencyLimiter WorkflowConcurrencyLimiter
+
+	// ActivityLimits are resource guards for activities executed by this worker, keyed by activity name.
+	// Executions violating a limit fail with an *ActivityLimitError.
+	ActivityLimits map[string]ActivityLimits
+
+	// ActivityCircuitBreakers stop executing activities whose dependencies are failing, keyed by activity
+	// name. While a circuit is open, executions fail with an *ActivityCircuitOpenError.
+	ActivityCircuitBreakers map[string]ActivityCircuitBreaker
+
+	// WorkflowSLOs are service level objectives for workflows, keyed by workflow name. Violations are counted in
+	// the workflows.workflow.slo.violation metric and reported to Hooks.OnSLOViolation.
+	WorkflowSLOs map[string]WorkflowSLO
+
+	// SlowActivityThreshold is the execution duration above which an activity invocation is logged as
+	// slow. The default is 0 which disables the slow activity log.
+	SlowActivityThreshold time.Duration
+
+	// WorkflowComputeWarningThreshold is the time workflow code may run without yielding before a
+	// warning is logged. CPU heavy logic should live in activities, see also workflow.Yield. The
+	// default is 0 which disables the check.
+	WorkflowComputeWarningThreshold time.Duration
+
+	// WorkflowReplayBudget is the maximum time a workflow task may spend replaying the history of an instance,
+	// e.g., after the executor was evicted from the cache. When it's exceeded, the task is parked and picked up
+	// again shortly after, instead of blocking a task slot until the whole history has been replayed. Parked tasks
+	// don't count as failed attempts and stay sticky to the worker, so replay resumes where it stopped as long as
+	// the executor stays cached. Exceeding the budget is counted in the workflows.workflow.replay.budget_exceeded
+	// metric. The default is 0 which disables the budget.
+	WorkflowReplayBudget time.Duration
+
+	// CompactHistoryOnReplayBudgetExceeded compacts the history replayed so far when the replay budget is
+	// exceeded, for backends implementing backend.HistoryCompactor, so that future replays are faster.
+	CompactHistoryOnReplayBudgetExceeded bool
+
+	// StrictReplay makes workflow executions fail when an activity or sub-workflow scheduled during replay
+	// has different inputs than recorded in the history. By default only their names are compared. Inputs
+	// are compared in their serialized form, so this requires a deterministic converter, e.g., it can't be
+	// used with payload encryption.
+	StrictReplay bool
+
+	// SchedulerTracer, if set, is called for every workflow executor to create a tracer for the scheduling of the
+	// workflow's coroutines. Tracers are reset at the start of every workflow task if they have a Reset method,
+	// and logged when replaying or executing a workflow fails if they implement fmt.Stringer, which is useful
+	// to debug non-determinism in workflows with concurrent coroutines. See NewSchedulerTrace.
+	SchedulerTracer func() sync.SchedulerTracer
+
+	// LenientPayloadDecoding decodes activity, sub-workflow, and side effect results that don't match the
+	// type expected by the workflow as far as possible, using zero values for mismatching fields and logging
+	// a warning. By default, such mismatches fail the workflow with a workflow.SchemaMismatchError.
+	LenientPayloadDecoding bool
+
+	// ConfigSource resolves the configuration values read by workflow code using workflow.GetConfigValue. If
+	// nil, values are read from environment variables.
+	ConfigSource workflowstate.ConfigSource
+
+	// WorkflowTaskRetryBackoff is the delay before a workflow task that failed with a transient error,
+	// e.g., because the history could not be fetched, is retried. It doubles with every consecutive
+	// failure for the same instance, up to one minute. Defaults to 1 second.
+	WorkflowTaskRetryBackoff time.Duration
+
 	// HeartbeatWorkflowTasks determines if the lock on workflow tasks should be periodically
 	// extended while they are being processed. Given that workflow executions should be
 	// very quick, this is usually not necessary.
@@ -39,9 +130,117 @@ type Options struct {
 	// WorkflowExecutorCache is the max TTL of the workflow executor cache. Defaults to 10 seconds
 	WorkflowExecutorCacheTTL time.Duration
 
+	// WorkflowExecutorCacheMaxMemory is the approximate memory budget in bytes for all executors held in
+	// the default workflow executor cache. The size of an executor is estimated from its history, payloads,
+	// and pending futures. When the budget is exceeded, least recently used executors are evicted, and
+	// executors exceeding the budget on their own are not cached. If 0, no budget is enforced.
+	WorkflowExecutorCacheMaxMemory int64
+
 	// WorkflowExecutorCache is the cache to use for workflow executors. If nil, a default cache implementation
 	// will be used.
 	WorkflowExecutorCache workflow.ExecutorCache
+
+	// PrefetchHintInterval is the interval at which the worker reads prefetch hints from backends implementing
+	// backend.PrefetchHinter, and prefetches the hinted instances. Hinted instances stay cached while they are
+	// hinted, as long as the interval is shorter than WorkflowExecutorCacheTTL. If 0, hints are ignored.
+	PrefetchHintInterval time.Duration
+
+	// ActivityInterceptors rewrite the inputs of activities when they are scheduled, and their results when
+	// they complete. Inputs are passed through the interceptors in order, results in reverse order.
+	ActivityInterceptors []workflowstate.ActivityInterceptor
+
+	// ActivityDefaults are default options for activities, keyed by activity name. They are used when a workflow
+	// executes the activity with the zero value or the default retry options, so that retry policies and timeouts
+	// don't have to be repeated at every call site. Like workflow code, changes affect the replay of running
+	// instances.
+	ActivityDefaults map[string]wf.ActivityOptions
+
+	// SubWorkflowDefaults are default options for sub-workflows, keyed by workflow name. They are used when a
+	// workflow starts the sub-workflow with the zero value or the default retry options.
+	SubWorkflowDefaults map[string]wf.SubWorkflowOptions
+
+	// SubWorkflowInstanceID, if set, derives the instance ID of sub-workflows started without an explicit
+	// instance ID. By default, a random ID is used.
+	SubWorkflowInstanceID workflowstate.SubWorkflowInstanceIDFunc
+
+	// Hooks are called at points in the lifecycle of the worker and its tasks.
+	Hooks Hooks
+
+	// ConfigWatcher, if set, is watched for updated DynamicOptions while the worker is running. This allows
+	// adjusting concurrency, pollers, and rate limits without restarting the worker.
+	ConfigWatcher ConfigWatcher
+
+	// ProfilerLabels adds pprof labels identifying the task to the goroutines executing workflow and activity
+	// tasks, so CPU and goroutine profiles can be broken down by task kind, instance, and activity.
+	ProfilerLabels bool
+
+	// HealthListenAddr, if set, is the address of an HTTP listener started with the worker. It serves
+	// /healthz, /readyz, /metrics, and the in-flight tasks at /inflight, see NewHealthHandler.
+	HealthListenAddr string
+
+	// MetricsHandler serves /metrics on the health listener, e.g., the handler of a Prometheus registry
+	// backing the metrics client. Without it, /metrics is not served.
+	MetricsHandler http.Handler
+}
+
+// DynamicOptions are the worker options that can be changed while a worker is running.
+type DynamicOptions struct {
+	// WorkflowPollers is the number of workflow task pollers. Values < 1 leave the current number unchanged.
+	WorkflowPollers int
+
+	// MaxParallelWorkflowTasks is the maximum number of concurrent workflow tasks. 0 leaves the current limit
+	// unchanged, values < 0 remove the limit.
+	MaxParallelWorkflowTasks int
+
+	// ActivityPollers is the number of activity task pollers. Values < 1 leave the current number unchanged.
+	ActivityPollers int
+
+	// MaxParallelActivityTasks is the maximum number of concurrent activity tasks. 0 leaves the current limit
+	// unchanged, values < 0 remove the limit.
+	MaxParallelActivityTasks int
+
+	// ActivityRateLimits are passed to the configured ActivityRateLimiter, if it implements
+	// ConfigurableActivityRateLimiter. nil leaves the current limits unchanged.
+	ActivityRateLimits map[string]int
+
+	// ActivityLimits replace the resource guards for activities, keyed by activity name. nil leaves the current
+	// limits unchanged.
+	ActivityLimits map[string]ActivityLimits
+}
+
+// ConfigWatcher provides updates for DynamicOptions, for example from a file, environment variables, or
+// a remote configuration service.
+type ConfigWatcher interface {
+	// Watch returns a channel on which updated options are delivered until the given context is canceled.
+	Watch(ctx context.Context) (<-chan DynamicOptions, error)
+}
+
+// ActivityRateLimiter limits how often activities can be executed.
+type ActivityRateLimiter interface {
+	// Wait blocks until the activity with the given name may be executed, or the context is canceled.
+	Wait(ctx context.Context, activityName string) error
+}
+
+// ConfigurableActivityRateLimiter is an ActivityRateLimiter whose limits can be changed at runtime.
+type ConfigurableActivityRateLimiter interface {
+	ActivityRateLimiter
+
+	// SetLimits replaces the current limits, keyed by activity name.
+	SetLimits(limits map[string]int)
+}
+
+// WorkflowConcurrencyLimiter limits how many instances of a workflow can run at the same time.
+type WorkflowConcurrencyLimiter interface {
+	// Acquire reserves a slot for the given instance. It returns false if all slots for the workflow are
+	// taken. Acquiring a slot the instance already holds succeeds.
+	Acquire(ctx context.Context, workflowName, instanceID string) (bool, error)
+
+	// Release frees the slot held by the given instance.
+	Release(ctx context.Context, workflowName, instanceID string) error
+
+	// Holders returns the IDs of the instances holding a slot for the given workflow. Workers use it to free
+	// slots of instances that finished or were removed without releasing them, e.g., because a worker crashed.
+	Holders(ctx context.Context, workflowName string) ([]string, error)
 }
 
 var DefaultOptions = Options{
@@ -50,9 +249,15 @@ var DefaultOptions = Options{
 	MaxParallelWorkflowTasks:  0,
 	MaxParallelActivityTasks:  0,
 	ActivityHeartbeatInterval: 25 * time.Second,
+	SlowActivityThreshold:     0,
 	WorkflowHeartbeatInterval: 25 * time.Second,
+	WorkflowTaskRetryBackoff:  time.Second,
+
+	WorkflowTaskChunkSize:       0,
+	MaxConsecutiveInstanceTasks: 0,
 
-	WorkflowExecutorCacheSize: 128,
-	WorkflowExecutorCacheTTL:  time.Second * 10,
-	WorkflowExecutorCache:     nil,
+	WorkflowExecutorCacheSize:      128,
+	WorkflowExecutorCacheTTL:       time.Second * 10,
+	WorkflowExecutorCacheMaxMemory: 0,
+	WorkflowExecutorCache:          nil,
 }
@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ApplyDynamicOptions_KeepsTaskLimits(t *testing.T) {
+	ww := &WorkflowWorker{sem: newSemaphore(4)}
+	aw := &ActivityWorker{sem: newSemaphore(8), guard: newActivityGuard(nil)}
+
+	// Updates that don't set the limits keep them
+	ww.ApplyDynamicOptions(DynamicOptions{})
+	aw.ApplyDynamicOptions(DynamicOptions{
+		ActivityLimits: map[string]ActivityLimits{"a": {MaxConcurrency: 1}},
+	})
+
+	require.Equal(t, 4, ww.sem.max)
+	require.Equal(t, 8, aw.sem.max)
+
+	ww.ApplyDynamicOptions(DynamicOptions{MaxParallelWorkflowTasks: 2})
+	aw.ApplyDynamicOptions(DynamicOptions{MaxParallelActivityTasks: 16})
+
+	require.Equal(t, 2, ww.sem.max)
+	require.Equal(t, 16, aw.sem.max)
+
+	// Negative values remove the limit
+	ww.ApplyDynamicOptions(DynamicOptions{MaxParallelWorkflowTasks: -1})
+	aw.ApplyDynamicOptions(DynamicOptions{MaxParallelActivityTasks: -1})
+
+	require.LessOrEqual(t, ww.sem.max, 0)
+	require.LessOrEqual(t, aw.sem.max, 0)
+}
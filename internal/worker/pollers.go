@@ -0,0 +1,41 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// pollers manages a set of polling goroutines that can be resized while the worker is running.
+type pollers struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	run     func(ctx context.Context)
+	cancels []context.CancelFunc
+}
+
+func newPollers(ctx context.Context, run func(ctx context.Context)) *pollers {
+	return &pollers{
+		ctx: ctx,
+		run: run,
+	}
+}
+
+// Resize starts or stops pollers until n are running. Stopped pollers finish their current poll
+// and dispatch any task they have received before exiting.
+func (p *pollers) Resize(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.cancels) < n {
+		ctx, cancel := context.WithCancel(p.ctx)
+		p.cancels = append(p.cancels, cancel)
+
+		go p.run(ctx)
+	}
+
+	for len(p.cancels) > n {
+		last := len(p.cancels) - 1
+		p.cancels[last]()
+		p.cancels = p.cancels[:last]
+	}
+}
@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/metrics"
+)
+
+// Prefetch creates executors for the given instances and replays their histories, so that their next tasks
+// don't have to. Instances that are already cached or have finished are skipped. Instances that can't be
+// prefetched are logged, the returned error reports how many failed.
+func (ww *WorkflowWorker) Prefetch(ctx context.Context, instances []*core.WorkflowInstance) error {
+	var failed int
+	var firstErr error
+
+	for _, instance := range instances {
+		if err := ww.prefetch(ctx, instance); err != nil {
+			ww.logger.Warn("could not prefetch workflow instance", "instance_id", instance.InstanceID, "error", err)
+
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("prefetching %d of %d instances failed: %w", failed, len(instances), firstErr)
+	}
+
+	return nil
+}
+
+func (ww *WorkflowWorker) prefetch(ctx context.Context, instance *core.WorkflowInstance) error {
+	if _, ok, _ := ww.cache.Get(ctx, instance); ok {
+		return nil
+	}
+
+	state, err := ww.backend.GetWorkflowInstanceState(ctx, instance)
+	if err != nil {
+		return fmt.Errorf("getting workflow instance state: %w", err)
+	}
+
+	if state == core.WorkflowInstanceStateFinished {
+		return nil
+	}
+
+	executor, err := ww.newExecutor(instance)
+	if err != nil {
+		return err
+	}
+
+	if err := executor.Replay(ctx, instance); err != nil {
+		executor.Close()
+		return err
+	}
+
+	// A task might have cached an executor for the instance in the meantime, keep that one
+	if _, ok, _ := ww.cache.Get(ctx, instance); ok {
+		executor.Close()
+		return nil
+	}
+
+	if err := ww.cache.Store(ctx, instance, executor); err != nil {
+		return fmt.Errorf("caching workflow executor: %w", err)
+	}
+
+	ww.backend.Metrics().Counter(metrickeys.WorkflowInstancePrefetched, metrics.Tags{}, 1)
+
+	return nil
+}
+
+// runPrefetchHints periodically prefetches the instances hinted by the backend until ctx is canceled.
+func (ww *WorkflowWorker) runPrefetchHints(ctx context.Context, h backend.PrefetchHinter) {
+	t := time.NewTicker(ww.options.PrefetchHintInterval)
+	defer t.Stop()
+
+	for {
+		instances, err := h.PrefetchHints(ctx)
+		if err != nil {
+			ww.logger.Error("could not get prefetch hints", "error", err)
+		} else if len(instances) > 0 {
+			// Failures are logged for each instance
+			_ = ww.Prefetch(ctx, instances)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/logger"
+	mi "github.com/cschleiden/go-workflows/internal/metrics"
+	"github.com/cschleiden/go-workflows/internal/sync"
+	"github.com/cschleiden/go-workflows/internal/workflow"
+	wf "github.com/cschleiden/go-workflows/workflow"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func waitForSignal(ctx sync.Context) error {
+	wf.NewSignalChannel[string](ctx, "signal").Receive(ctx)
+	return nil
+}
+
+func Test_Prefetch(t *testing.T) {
+	r := workflow.NewRegistry()
+	require.NoError(t, r.RegisterWorkflow(waitForSignal))
+
+	active := core.NewWorkflowInstance("active", "execution")
+	finished := core.NewWorkflowInstance("finished", "execution")
+
+	b := &backend.MockBackend{}
+	b.On("Logger").Return(logger.NewDefaultLogger())
+	b.On("Metrics").Return(mi.NewNoopMetricsClient())
+	b.On("Tracer").Return(trace.NewNoopTracerProvider().Tracer("test"))
+	b.On("GetWorkflowInstanceState", mock.Anything, active).Return(core.WorkflowInstanceStateActive, nil)
+	b.On("GetWorkflowInstanceState", mock.Anything, finished).Return(core.WorkflowInstanceStateFinished, nil)
+	b.On("GetWorkflowInstanceHistory", mock.Anything, active, mock.Anything).Return([]history.Event{
+		history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+			Name: "waitForSignal",
+		}),
+	}, nil).Once()
+
+	ww := NewWorkflowWorker(b, r, &Options{
+		WorkflowExecutorCacheSize: 10,
+		WorkflowExecutorCacheTTL:  time.Minute,
+	})
+
+	ctx := context.Background()
+	require.NoError(t, ww.Prefetch(ctx, []*core.WorkflowInstance{active, finished}))
+
+	_, ok, err := ww.cache.Get(ctx, active)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, ok, err = ww.cache.Get(ctx, finished)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// Cached instances are not fetched again
+	require.NoError(t, ww.Prefetch(ctx, []*core.WorkflowInstance{active}))
+	b.AssertNumberOfCalls(t, "GetWorkflowInstanceHistory", 1)
+}
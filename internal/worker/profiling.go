@@ -0,0 +1,25 @@
+package worker
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// withProfilerLabels calls f with pprof labels describing the given task, if enabled. Goroutines started by f,
+// e.g., the goroutines of a workflow, inherit the labels.
+func withProfilerLabels(ctx context.Context, enabled bool, info TaskInfo, f func(ctx context.Context)) {
+	if !enabled {
+		f(ctx)
+		return
+	}
+
+	labels := []string{
+		"workflows.task", string(info.Kind),
+		"workflows.instance_id", info.Instance.InstanceID,
+	}
+	if info.Name != "" {
+		labels = append(labels, "workflows.activity", info.Name)
+	}
+
+	pprof.Do(ctx, pprof.Labels(labels...), f)
+}
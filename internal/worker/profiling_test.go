@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"context"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WithProfilerLabels(t *testing.T) {
+	info := TaskInfo{Kind: TaskKindActivity, Instance: core.NewWorkflowInstance("instance", "execution"), Name: "Activity"}
+
+	t.Run("Enabled", func(t *testing.T) {
+		called := false
+		withProfilerLabels(context.Background(), true, info, func(ctx context.Context) {
+			called = true
+
+			kind, _ := pprof.Label(ctx, "workflows.task")
+			require.Equal(t, "activity", kind)
+
+			instanceID, _ := pprof.Label(ctx, "workflows.instance_id")
+			require.Equal(t, "instance", instanceID)
+
+			activity, _ := pprof.Label(ctx, "workflows.activity")
+			require.Equal(t, "Activity", activity)
+		})
+		require.True(t, called)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		called := false
+		withProfilerLabels(context.Background(), false, info, func(ctx context.Context) {
+			called = true
+
+			_, ok := pprof.Label(ctx, "workflows.task")
+			require.False(t, ok)
+		})
+		require.True(t, called)
+	})
+}
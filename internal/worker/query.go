@@ -0,0 +1,21 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/workflow"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+)
+
+// NewQueryActivity returns the activity built into workers that answers queries of other workflow instances,
+// see workflow.QueryExternalWorkflow. It's registered as workflowstate.QueryActivityName.
+func NewQueryActivity(b backend.Backend, registry *workflow.Registry) func(ctx context.Context, req workflowstate.QueryRequest) (payload.Payload, error) {
+	return func(ctx context.Context, req workflowstate.QueryRequest) (payload.Payload, error) {
+		instance := core.NewWorkflowInstance(req.InstanceID, "")
+
+		return workflow.ExecuteQuery(ctx, b.Logger(), b.Tracer(), b.Metrics(), registry, b, instance, req.Name, req.Inputs)
+	}
+}
@@ -0,0 +1,49 @@
+package worker
+
+import "sync"
+
+// semaphore limits the number of concurrently processed tasks. Unlike a buffered channel, its capacity
+// can be changed while tasks are being processed.
+type semaphore struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	n    int
+	max  int
+}
+
+// newSemaphore creates a semaphore allowing max concurrent holders. A max <= 0 means no limit.
+func newSemaphore(max int) *semaphore {
+	s := &semaphore{max: max}
+	s.cond = sync.NewCond(&s.mu)
+
+	return s
+}
+
+func (s *semaphore) Acquire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.max > 0 && s.n >= s.max {
+		s.cond.Wait()
+	}
+
+	s.n++
+}
+
+func (s *semaphore) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.n--
+	s.cond.Broadcast()
+}
+
+// SetMax changes the capacity of the semaphore. Lowering it does not affect current holders, but new
+// acquisitions block until enough have been released.
+func (s *semaphore) SetMax(max int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.max = max
+	s.cond.Broadcast()
+}
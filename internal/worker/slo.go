@@ -0,0 +1,103 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/internal/task"
+	"github.com/cschleiden/go-workflows/internal/workflow"
+	"github.com/cschleiden/go-workflows/metrics"
+)
+
+// WorkflowSLO declares service level objectives for the instances of a workflow. Violations are counted in the
+// workflows.workflow.slo.violation metric and reported to Hooks.OnSLOViolation.
+type WorkflowSLO struct {
+	// MaxDuration is the maximum end-to-end duration of an instance, from its start until it finishes. It's
+	// evaluated when the instance finishes. 0 is no objective.
+	MaxDuration time.Duration
+
+	// MaxTaskLatency is the maximum time a workflow task waits until a worker picks it up, measured from when
+	// the first event of the task became visible, e.g., when an activity completed or a timer fired. 0 is no
+	// objective.
+	MaxTaskLatency time.Duration
+}
+
+// SLO identifies an objective of a WorkflowSLO.
+type SLO string
+
+const (
+	SLOMaxDuration    SLO = "max_duration"
+	SLOMaxTaskLatency SLO = "max_task_latency"
+)
+
+// SLOViolation describes an instance that didn't meet an objective of its WorkflowSLO.
+type SLOViolation struct {
+	Workflow string
+	Instance *core.WorkflowInstance
+	SLO      SLO
+
+	// Objective is the configured objective, Actual the measured duration.
+	Objective time.Duration
+	Actual    time.Duration
+}
+
+// checkSLOs reports violations of the objectives declared for the workflow of the executed task. pickedUpAt is
+// the time the worker received the task.
+func (ww *WorkflowWorker) checkSLOs(ctx context.Context, t *task.Workflow, result *workflow.ExecutionResult, pickedUpAt time.Time) {
+	slo, ok := ww.options.WorkflowSLOs[result.WorkflowName]
+	if !ok {
+		return
+	}
+
+	if slo.MaxTaskLatency > 0 && len(t.NewEvents) > 0 {
+		// Future events like fired timers are created when they are scheduled, use the time they became visible
+		visibleAt := t.NewEvents[0].Timestamp
+		if v := t.NewEvents[0].VisibleAt; v != nil && v.After(visibleAt) {
+			visibleAt = *v
+		}
+
+		if latency := pickedUpAt.Sub(visibleAt); latency > slo.MaxTaskLatency {
+			ww.reportSLOViolation(ctx, SLOViolation{
+				Workflow:  result.WorkflowName,
+				Instance:  t.WorkflowInstance,
+				SLO:       SLOMaxTaskLatency,
+				Objective: slo.MaxTaskLatency,
+				Actual:    latency,
+			})
+		}
+	}
+
+	finished := result.Completed && t.WorkflowInstanceState != core.WorkflowInstanceStateFinished
+	if slo.MaxDuration > 0 && finished && !result.StartedAt.IsZero() {
+		if duration := time.Since(result.StartedAt); duration > slo.MaxDuration {
+			ww.reportSLOViolation(ctx, SLOViolation{
+				Workflow:  result.WorkflowName,
+				Instance:  t.WorkflowInstance,
+				SLO:       SLOMaxDuration,
+				Objective: slo.MaxDuration,
+				Actual:    duration,
+			})
+		}
+	}
+}
+
+func (ww *WorkflowWorker) reportSLOViolation(ctx context.Context, v SLOViolation) {
+	ww.backend.Metrics().Counter(metrickeys.WorkflowSLOViolation, metrics.Tags{
+		metrickeys.WorkflowName: v.Workflow,
+		metrickeys.SLO:          string(v.SLO),
+	}, 1)
+
+	ww.logger.Warn("Workflow instance violated SLO",
+		"instance_id", v.Instance.InstanceID,
+		"workflow", v.Workflow,
+		"slo", v.SLO,
+		"objective_ms", v.Objective.Milliseconds(),
+		"actual_ms", v.Actual.Milliseconds(),
+	)
+
+	if ww.options.Hooks.OnSLOViolation != nil {
+		ww.options.Hooks.OnSLOViolation(ctx, v)
+	}
+}
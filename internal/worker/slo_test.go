@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/logger"
+	mi "github.com/cschleiden/go-workflows/internal/metrics"
+	"github.com/cschleiden/go-workflows/internal/task"
+	"github.com/cschleiden/go-workflows/internal/workflow"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CheckSLOs(t *testing.T) {
+	b := &backend.MockBackend{}
+	b.On("Metrics").Return(mi.NewNoopMetricsClient())
+
+	var violations []SLOViolation
+
+	ww := &WorkflowWorker{
+		backend: b,
+		logger:  logger.NewDefaultLogger(),
+		options: &Options{
+			WorkflowSLOs: map[string]WorkflowSLO{
+				"Checkout": {MaxDuration: time.Minute, MaxTaskLatency: time.Second},
+			},
+			Hooks: Hooks{
+				OnSLOViolation: func(ctx context.Context, v SLOViolation) {
+					violations = append(violations, v)
+				},
+			},
+		},
+	}
+
+	now := time.Now()
+	wfi := core.NewWorkflowInstance("instance", "execution")
+
+	newTask := func(timestamp time.Time, visibleAt *time.Time) *task.Workflow {
+		e := history.NewPendingEvent(timestamp, history.EventType_TimerFired, &history.TimerFiredAttributes{})
+		e.VisibleAt = visibleAt
+
+		return &task.Workflow{
+			WorkflowInstance: wfi,
+			NewEvents:        []history.Event{e},
+		}
+	}
+
+	// Within objectives
+	ww.checkSLOs(context.Background(), newTask(now.Add(-time.Hour), &now), &workflow.ExecutionResult{
+		WorkflowName: "Checkout",
+		StartedAt:    now.Add(-time.Hour),
+	}, now)
+	require.Empty(t, violations)
+
+	// No objectives for other workflows
+	ww.checkSLOs(context.Background(), newTask(now.Add(-time.Hour), nil), &workflow.ExecutionResult{
+		WorkflowName: "Other",
+		Completed:    true,
+		StartedAt:    now.Add(-time.Hour),
+	}, now)
+	require.Empty(t, violations)
+
+	ww.checkSLOs(context.Background(), newTask(now.Add(-2*time.Second), nil), &workflow.ExecutionResult{
+		WorkflowName: "Checkout",
+		Completed:    true,
+		StartedAt:    now.Add(-time.Hour),
+	}, now)
+	require.Len(t, violations, 2)
+
+	require.Equal(t, SLOMaxTaskLatency, violations[0].SLO)
+	require.Equal(t, 2*time.Second, violations[0].Actual)
+	require.Equal(t, time.Second, violations[0].Objective)
+
+	require.Equal(t, SLOMaxDuration, violations[1].SLO)
+	require.Equal(t, "Checkout", violations[1].Workflow)
+	require.GreaterOrEqual(t, violations[1].Actual, time.Hour)
+}
@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/workflowerrors"
+)
+
+// visibleSince returns the time since which the given activity event has been visible to workers. Backends that
+// delay activities, e.g., to limit the rate of activities of an instance, set VisibleAt.
+func visibleSince(event history.Event) time.Time {
+	if event.VisibleAt != nil && event.VisibleAt.After(event.Timestamp) {
+		return *event.VisibleAt
+	}
+
+	return event.Timestamp
+}
+
+// checkScheduleToStart returns a TimeoutError if the activity visible since the given time has waited longer than
+// its schedule-to-start timeout to be started.
+func checkScheduleToStart(a *history.ActivityScheduledAttributes, visibleSince, now time.Time) error {
+	if a.ScheduleToStartTimeout <= 0 || now.Sub(visibleSince) <= a.ScheduleToStartTimeout {
+		return nil
+	}
+
+	return &workflowerrors.TimeoutError{
+		Message: fmt.Sprintf("activity %s was not started within %v", a.Name, a.ScheduleToStartTimeout),
+	}
+}
+
+// executeWithStartToCloseTimeout executes f and fails it with a TimeoutError if it doesn't finish within the given
+// timeout. The context passed to f is canceled when the timeout expires.
+func executeWithStartToCloseTimeout(
+	ctx context.Context, name string, timeout time.Duration,
+	f func(ctx context.Context) (payload.Payload, error),
+) (payload.Payload, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		p   payload.Payload
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		p, err := f(ctx)
+		done <- result{p, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.p, r.err
+	case <-ctx.Done():
+		if ctx.Err() != context.DeadlineExceeded {
+			return nil, ctx.Err()
+		}
+
+		// The activity keeps running in the background if it ignores its context, but its result is dropped
+		return nil, &workflowerrors.TimeoutError{
+			Message: fmt.Sprintf("activity %s did not complete within %v", name, timeout),
+		}
+	}
+}
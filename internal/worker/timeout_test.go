@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/workflowerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CheckScheduleToStart(t *testing.T) {
+	scheduledAt := time.Now()
+
+	t.Run("NoTimeout", func(t *testing.T) {
+		a := &history.ActivityScheduledAttributes{Name: "a"}
+
+		require.NoError(t, checkScheduleToStart(a, scheduledAt, scheduledAt.Add(time.Hour)))
+	})
+
+	t.Run("StartedInTime", func(t *testing.T) {
+		a := &history.ActivityScheduledAttributes{Name: "a", ScheduleToStartTimeout: time.Minute}
+
+		require.NoError(t, checkScheduleToStart(a, scheduledAt, scheduledAt.Add(time.Minute)))
+	})
+
+	t.Run("StartedTooLate", func(t *testing.T) {
+		a := &history.ActivityScheduledAttributes{Name: "a", ScheduleToStartTimeout: time.Minute}
+
+		err := checkScheduleToStart(a, scheduledAt, scheduledAt.Add(time.Minute+time.Second))
+
+		var timeoutErr *workflowerrors.TimeoutError
+		require.True(t, errors.As(err, &timeoutErr))
+		require.Contains(t, timeoutErr.Message, "was not started within 1m0s")
+	})
+}
+
+func Test_VisibleSince(t *testing.T) {
+	scheduledAt := time.Now()
+
+	event := history.Event{Timestamp: scheduledAt}
+	require.Equal(t, scheduledAt, visibleSince(event))
+
+	// Activities delayed by the backend are measured from when they became visible
+	visibleAt := scheduledAt.Add(time.Minute)
+	event.VisibleAt = &visibleAt
+	require.Equal(t, visibleAt, visibleSince(event))
+}
+
+func Test_ExecuteWithStartToCloseTimeout(t *testing.T) {
+	t.Run("CompletesInTime", func(t *testing.T) {
+		r, err := executeWithStartToCloseTimeout(context.Background(), "a", time.Second, func(ctx context.Context) (payload.Payload, error) {
+			return payload.Payload("done"), nil
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, payload.Payload("done"), r)
+	})
+
+	t.Run("ReturnsActivityError", func(t *testing.T) {
+		activityErr := errors.New("activity failed")
+
+		_, err := executeWithStartToCloseTimeout(context.Background(), "a", time.Second, func(ctx context.Context) (payload.Payload, error) {
+			return nil, activityErr
+		})
+
+		require.ErrorIs(t, err, activityErr)
+	})
+
+	t.Run("SlowActivityTimesOut", func(t *testing.T) {
+		_, err := executeWithStartToCloseTimeout(context.Background(), "a", 20*time.Millisecond, func(ctx context.Context) (payload.Payload, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+		var timeoutErr *workflowerrors.TimeoutError
+		require.True(t, errors.As(err, &timeoutErr))
+		require.Contains(t, timeoutErr.Message, "did not complete within 20ms")
+	})
+
+	t.Run("CanceledContext", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := executeWithStartToCloseTimeout(ctx, "a", time.Second, func(ctx context.Context) (payload.Payload, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -2,6 +2,7 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -9,10 +10,12 @@ import (
 	"github.com/benbjohnson/clock"
 	"github.com/cschleiden/go-workflows/backend"
 	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/metrickeys"
 	"github.com/cschleiden/go-workflows/internal/task"
 	"github.com/cschleiden/go-workflows/internal/workflow"
 	"github.com/cschleiden/go-workflows/internal/workflow/cache"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
 	"github.com/cschleiden/go-workflows/log"
 	"github.com/cschleiden/go-workflows/metrics"
 )
@@ -26,19 +29,60 @@ type WorkflowWorker struct {
 
 	cache workflow.ExecutorCache
 
+	optionDefaults workflowstate.OptionDefaults
+
 	workflowTaskQueue chan *task.Workflow
 
+	pollers *pollers
+	sem     *semaphore
+
 	logger log.Logger
 
 	wg *sync.WaitGroup
+
+	// Track consecutive tasks for the same instance
+	consecutiveMu       sync.Mutex
+	consecutiveInstance string
+	consecutiveTasks    int
+
+	// Track consecutive transient failures per instance
+	attemptsMu sync.Mutex
+	attempts   map[string]int
+
+	// Track when concurrency slots were last reconciled per workflow
+	reconciledMu sync.Mutex
+	reconciled   map[string]time.Time
+
+	inFlight *inFlightTasks
 }
 
+// consecutiveInstanceTasksBackoff is the delay before a task for an instance that has exceeded
+// MaxConsecutiveInstanceTasks can be picked up again, giving pollers a chance to pick up tasks for other instances.
+const consecutiveInstanceTasksBackoff = 50 * time.Millisecond
+
+// workflowConcurrencyBackoff is the delay before the start of an instance is retried when all slots for its workflow
+// are taken.
+const workflowConcurrencyBackoff = 250 * time.Millisecond
+
+// replayBudgetParkDelay is the delay before a task whose replay exceeded the replay budget is picked up again,
+// giving pollers a chance to pick up tasks for other instances.
+const replayBudgetParkDelay = 50 * time.Millisecond
+
+// workflowConcurrencyReconcileInterval is the minimum delay between checks for concurrency slots held by instances
+// that are no longer running.
+const workflowConcurrencyReconcileInterval = 5 * time.Second
+
+// maxWorkflowTaskRetryBackoff is the maximum delay before retrying a workflow task that failed with a transient error.
+const maxWorkflowTaskRetryBackoff = time.Minute
+
 func NewWorkflowWorker(backend backend.Backend, registry *workflow.Registry, options *Options) *WorkflowWorker {
 	var c workflow.ExecutorCache
 	if options.WorkflowExecutorCache != nil {
 		c = options.WorkflowExecutorCache
 	} else {
-		c = cache.NewWorkflowExecutorLRUCache(backend.Metrics(), options.WorkflowExecutorCacheSize, options.WorkflowExecutorCacheTTL)
+		c = cache.NewWorkflowExecutorLRUCache(
+			backend.Metrics(), options.WorkflowExecutorCacheSize, options.WorkflowExecutorCacheTTL, options.WorkflowExecutorCacheMaxMemory,
+			cache.WithEvictionHook(options.Hooks.OnCacheEvict))
 	}
 
 	return &WorkflowWorker{
@@ -51,22 +95,68 @@ func NewWorkflowWorker(backend backend.Backend, registry *workflow.Registry, opt
 
 		cache: c,
 
+		optionDefaults: newOptionDefaults(options),
+
+		sem: newSemaphore(options.MaxParallelWorkflowTasks),
+
 		logger: backend.Logger(),
 
 		wg: &sync.WaitGroup{},
+
+		attempts: make(map[string]int),
+
+		reconciled: make(map[string]time.Time),
+
+		inFlight: newInFlightTasks(),
 	}
 }
 
-func (ww *WorkflowWorker) Start(ctx context.Context) error {
-	for i := 0; i <= ww.options.WorkflowPollers; i++ {
-		go ww.runPoll(ctx)
+// InFlightTasks returns the workflow tasks currently being executed, longest running first.
+func (ww *WorkflowWorker) InFlightTasks() []InFlightTask {
+	return ww.inFlight.List()
+}
+
+func newOptionDefaults(options *Options) workflowstate.OptionDefaults {
+	d := workflowstate.OptionDefaults{
+		Activities:   make(map[string]interface{}, len(options.ActivityDefaults)),
+		SubWorkflows: make(map[string]interface{}, len(options.SubWorkflowDefaults)),
+	}
+
+	for name, o := range options.ActivityDefaults {
+		d.Activities[name] = o
 	}
 
+	for name, o := range options.SubWorkflowDefaults {
+		d.SubWorkflows[name] = o
+	}
+
+	return d
+}
+
+func (ww *WorkflowWorker) Start(ctx context.Context) error {
+	ww.pollers = newPollers(ctx, ww.runPoll)
+	ww.pollers.Resize(ww.options.WorkflowPollers)
+
 	go ww.runDispatcher()
 
+	if h, ok := ww.backend.(backend.PrefetchHinter); ok && ww.options.PrefetchHintInterval > 0 {
+		go ww.runPrefetchHints(ctx, h)
+	}
+
 	return nil
 }
 
+// ApplyDynamicOptions adjusts the number of pollers and the task concurrency of a running worker.
+func (ww *WorkflowWorker) ApplyDynamicOptions(o DynamicOptions) {
+	if o.WorkflowPollers > 0 {
+		ww.pollers.Resize(o.WorkflowPollers)
+	}
+
+	if o.MaxParallelWorkflowTasks != 0 {
+		ww.sem.SetMax(o.MaxParallelWorkflowTasks)
+	}
+}
+
 func (ww *WorkflowWorker) WaitForCompletion() error {
 	close(ww.workflowTaskQueue)
 
@@ -89,6 +179,10 @@ func (ww *WorkflowWorker) runPoll(ctx context.Context) {
 			}
 
 			if task != nil {
+				if ww.yieldToOtherInstances(task) {
+					continue
+				}
+
 				ww.wg.Add(1)
 				ww.workflowTaskQueue <- task
 			}
@@ -96,17 +190,55 @@ func (ww *WorkflowWorker) runPoll(ctx context.Context) {
 	}
 }
 
-func (ww *WorkflowWorker) runDispatcher() {
-	var sem chan (struct{})
+// yieldToOtherInstances abandons the given task if the worker has processed too many tasks for its instance in a
+// row, so that it can pick up tasks of other instances first. It returns true if the task was abandoned. Tasks
+// are only abandoned if the backend supports it, the lock on a task is never held without processing it.
+func (ww *WorkflowWorker) yieldToOtherInstances(t *task.Workflow) bool {
+	a, ok := ww.backend.(backend.WorkflowTaskAbandoner)
+	if !ok || !ww.exceedsConsecutiveTasks(t) {
+		return false
+	}
+
+	ww.backend.Metrics().Counter(metrickeys.WorkflowTaskThrottled, metrics.Tags{}, 1)
+	ww.logger.Debug("Throttling workflow task for instance", "instance_id", t.WorkflowInstance.InstanceID)
+
+	// Use a new context, the task has to be released even if the worker is shutting down
+	if err := a.AbandonWorkflowTask(context.Background(), t, consecutiveInstanceTasksBackoff); err != nil {
+		// The task will be picked up again once its lock expires
+		ww.logger.Error("could not abandon workflow task", "error", err)
+	}
 
-	if ww.options.MaxParallelWorkflowTasks > 0 {
-		sem = make(chan struct{}, ww.options.MaxParallelWorkflowTasks)
+	return true
+}
+
+// exceedsConsecutiveTasks records the given task and returns true if the worker has processed more than the
+// configured number of tasks for the same instance in a row. The count starts over once it has been exceeded.
+func (ww *WorkflowWorker) exceedsConsecutiveTasks(t *task.Workflow) bool {
+	if ww.options.MaxConsecutiveInstanceTasks <= 0 {
+		return false
 	}
 
+	ww.consecutiveMu.Lock()
+	defer ww.consecutiveMu.Unlock()
+
+	if ww.consecutiveInstance == t.WorkflowInstance.InstanceID {
+		ww.consecutiveTasks++
+	} else {
+		ww.consecutiveInstance = t.WorkflowInstance.InstanceID
+		ww.consecutiveTasks = 1
+	}
+
+	if ww.consecutiveTasks > ww.options.MaxConsecutiveInstanceTasks {
+		ww.consecutiveTasks = 0
+		return true
+	}
+
+	return false
+}
+
+func (ww *WorkflowWorker) runDispatcher() {
 	for t := range ww.workflowTaskQueue {
-		if sem != nil {
-			sem <- struct{}{}
-		}
+		ww.sem.Acquire()
 
 		t := t
 
@@ -117,26 +249,64 @@ func (ww *WorkflowWorker) runDispatcher() {
 			taskCtx := context.Background()
 			ww.handle(taskCtx, t)
 
-			if sem != nil {
-				<-sem
-			}
+			ww.sem.Release()
 		}()
 	}
 }
 
 func (ww *WorkflowWorker) handle(ctx context.Context, t *task.Workflow) {
+	pickedUpAt := time.Now()
+
 	// Record how long this task was in the queue
 	scheduledAt := t.NewEvents[0].Timestamp // Use the timestamp of the first event as the schedule time
 	timeInQueue := time.Since(scheduledAt)
 	ww.backend.Metrics().Distribution(metrickeys.WorkflowTaskDelay, metrics.Tags{}, float64(timeInQueue/time.Millisecond))
 
+	if !ww.acquireConcurrencySlot(ctx, t) {
+		return
+	}
+
+	info := TaskInfo{Kind: TaskKindWorkflow, ID: t.ID, Instance: t.WorkflowInstance}
+	ww.options.Hooks.taskStarted(ctx, info)
+
+	done := ww.inFlight.add(info)
+	defer done()
+
 	timer := metrics.Timer(ww.backend.Metrics(), metrickeys.WorkflowTaskProcessed, metrics.Tags{})
 
-	result, err := ww.handleTask(ctx, t)
+	var result *workflow.ExecutionResult
+	var err error
+	withProfilerLabels(ctx, ww.options.ProfilerLabels, info, func(ctx context.Context) {
+		result, err = ww.handleTask(ctx, t)
+	})
 	if err != nil {
+		ww.options.Hooks.taskCompleted(ctx, info, err)
+
+		if workflow.IsTransientError(err) {
+			var budgetErr *workflow.ReplayBudgetExceededError
+			if errors.As(err, &budgetErr) {
+				// The replay made progress, park the task instead of counting it as a failed attempt
+				ww.replayBudgetExceeded(ctx, t, budgetErr)
+				ww.parkTask(ctx, t)
+				return
+			}
+
+			var pinnedErr *workflow.PinnedVersionError
+			if errors.As(err, &pinnedErr) {
+				// Another worker might have the pinned version registered, this isn't a failed attempt
+				ww.releasePinnedTask(ctx, t, pinnedErr)
+				return
+			}
+
+			ww.retryTask(ctx, t, err)
+			return
+		}
+
 		ww.logger.Panic("could not handle workflow task", "error", err)
 	}
 
+	ww.resetAttempts(t)
+
 	// Only record the time spent in the workflow code
 	timer.Stop()
 
@@ -158,6 +328,274 @@ func (ww *WorkflowWorker) handle(ctx context.Context, t *task.Workflow) {
 		ctx, t, t.WorkflowInstance, state, result.Executed, result.ActivityEvents, result.TimerEvents, result.WorkflowEvents); err != nil {
 		ww.logger.Panic("could not complete workflow task", "error", err)
 	}
+
+	info.Name = result.WorkflowName
+	info.Scheduler = result.SchedulerStats
+	ww.options.Hooks.taskCompleted(ctx, info, nil)
+
+	ww.checkSLOs(ctx, t, result, pickedUpAt)
+
+	if result.Completed && ww.options.WorkflowConcurrencyLimiter != nil && result.WorkflowName != "" {
+		if err := ww.options.WorkflowConcurrencyLimiter.Release(ctx, result.WorkflowName, t.WorkflowInstance.InstanceID); err != nil {
+			ww.logger.Error("could not release workflow concurrency slot", "error", err)
+		}
+	}
+}
+
+// acquireConcurrencySlot reserves a slot with the configured WorkflowConcurrencyLimiter for tasks starting a
+// workflow instance. If no slot is available, the task is released to be retried later and false is returned.
+func (ww *WorkflowWorker) acquireConcurrencySlot(ctx context.Context, t *task.Workflow) bool {
+	limiter := ww.options.WorkflowConcurrencyLimiter
+	if limiter == nil {
+		return true
+	}
+
+	var workflowName string
+	for i := range t.NewEvents {
+		if t.NewEvents[i].Type != history.EventType_WorkflowExecutionStarted {
+			continue
+		}
+
+		a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&t.NewEvents[i])
+		if err != nil {
+			// Let the executor surface the error
+			return true
+		}
+
+		workflowName = a.Name
+		break
+	}
+
+	if workflowName == "" {
+		// Instance is already running
+		return true
+	}
+
+	acquired, err := limiter.Acquire(ctx, workflowName, t.WorkflowInstance.InstanceID)
+	if err == nil && !acquired && ww.reconcileConcurrencySlots(ctx, limiter, workflowName) {
+		acquired, err = limiter.Acquire(ctx, workflowName, t.WorkflowInstance.InstanceID)
+	}
+
+	if err != nil {
+		ww.logger.Error("could not acquire workflow concurrency slot", "error", err)
+	} else if acquired {
+		return true
+	}
+
+	ww.backend.Metrics().Counter(metrickeys.WorkflowConcurrencyLimited, metrics.Tags{}, 1)
+	ww.logger.Debug("Delaying start of workflow instance, concurrency limit reached",
+		"instance_id", t.WorkflowInstance.InstanceID, "workflow", workflowName)
+
+	if a, ok := ww.backend.(backend.WorkflowTaskAbandoner); ok {
+		if err := a.AbandonWorkflowTask(ctx, t, workflowConcurrencyBackoff); err != nil {
+			ww.logger.Error("could not abandon workflow task", "error", err)
+		}
+	}
+
+	return false
+}
+
+// reconcileConcurrencySlots releases slots for the given workflow held by instances that are finished or don't
+// exist anymore. Their slots are leaked if the worker holding them crashed before releasing them, the instance was
+// removed, or the instance finished on a worker not sharing the limiter. Slots are reconciled at most once every
+// workflowConcurrencyReconcileInterval per workflow. Returns true if any slot was released.
+func (ww *WorkflowWorker) reconcileConcurrencySlots(ctx context.Context, limiter WorkflowConcurrencyLimiter, workflowName string) bool {
+	ww.reconciledMu.Lock()
+	if last, ok := ww.reconciled[workflowName]; ok && time.Since(last) < workflowConcurrencyReconcileInterval {
+		ww.reconciledMu.Unlock()
+		return false
+	}
+	ww.reconciled[workflowName] = time.Now()
+	ww.reconciledMu.Unlock()
+
+	holders, err := limiter.Holders(ctx, workflowName)
+	if err != nil {
+		ww.logger.Error("could not read workflow concurrency slots", "error", err)
+		return false
+	}
+
+	released := false
+	for _, instanceID := range holders {
+		state, err := ww.backend.GetWorkflowInstanceState(ctx, core.NewWorkflowInstance(instanceID, ""))
+		if err != nil && !errors.Is(err, backend.ErrInstanceNotFound) {
+			ww.logger.Error("could not read workflow instance state", "instance_id", instanceID, "error", err)
+			continue
+		}
+
+		if err == nil && state != core.WorkflowInstanceStateFinished {
+			continue
+		}
+
+		ww.logger.Debug("Releasing workflow concurrency slot of instance that is not running",
+			"instance_id", instanceID, "workflow", workflowName)
+
+		if err := limiter.Release(ctx, workflowName, instanceID); err != nil {
+			ww.logger.Error("could not release workflow concurrency slot", "error", err)
+			continue
+		}
+
+		released = true
+	}
+
+	return released
+}
+
+// replayBudgetExceeded records that replaying the history of the task's instance exceeded the replay budget, and
+// compacts the replayed part of the history if configured.
+func (ww *WorkflowWorker) replayBudgetExceeded(ctx context.Context, t *task.Workflow, err *workflow.ReplayBudgetExceededError) {
+	ww.backend.Metrics().Counter(metrickeys.WorkflowReplayBudgetExceeded, metrics.Tags{}, 1)
+	ww.logger.Warn("Replaying workflow history exceeded budget, parking task",
+		"instance_id", t.WorkflowInstance.InstanceID,
+		"task_id", t.ID,
+		"budget_ms", err.Budget.Milliseconds(),
+		"replayed", err.Replayed,
+	)
+
+	if !ww.options.CompactHistoryOnReplayBudgetExceeded {
+		return
+	}
+
+	hc, ok := ww.backend.(backend.HistoryCompactor)
+	if !ok {
+		return
+	}
+
+	// Only events that have been replayed are compacted, so a cached executor can resume replaying
+	n, cerr := hc.CompactWorkflowInstanceHistory(ctx, t.WorkflowInstance, err.LastSequenceID)
+	if cerr != nil {
+		ww.logger.Error("could not compact workflow history", "error", cerr)
+		return
+	}
+
+	ww.logger.Debug("Compacted workflow history", "instance_id", t.WorkflowInstance.InstanceID, "compacted", n)
+}
+
+// parkTask releases a task whose replay exceeded the replay budget, so that it's picked up again after
+// replayBudgetParkDelay. If the backend supports it, the instance stays sticky to this worker, which holds the
+// partially replayed executor.
+func (ww *WorkflowWorker) parkTask(ctx context.Context, t *task.Workflow) {
+	if p, ok := ww.backend.(backend.WorkflowTaskParker); ok {
+		if err := p.ParkWorkflowTask(ctx, t, replayBudgetParkDelay); err != nil {
+			ww.logger.Error("could not park workflow task", "error", err)
+		}
+
+		return
+	}
+
+	if a, ok := ww.backend.(backend.WorkflowTaskAbandoner); ok {
+		if err := a.AbandonWorkflowTask(ctx, t, replayBudgetParkDelay); err != nil {
+			ww.logger.Error("could not abandon workflow task", "error", err)
+		}
+	}
+}
+
+// releasePinnedTask releases a task of an instance that's pinned to a version of its workflow this worker doesn't
+// have registered. The task is available again right away, so that a worker with the pinned version can pick it
+// up without waiting for a backoff.
+func (ww *WorkflowWorker) releasePinnedTask(ctx context.Context, t *task.Workflow, err *workflow.PinnedVersionError) {
+	ww.logger.Debug("Workflow instance is pinned to another version, releasing task",
+		"instance_id", t.WorkflowInstance.InstanceID,
+		"task_id", t.ID,
+		"version", err.Version,
+		"registered_version", err.RegisteredVersion,
+	)
+
+	a, ok := ww.backend.(backend.WorkflowTaskAbandoner)
+	if !ok {
+		// The task will be picked up again once its lock expires
+		return
+	}
+
+	if err := a.AbandonWorkflowTask(ctx, t, 0); err != nil {
+		ww.logger.Error("could not abandon workflow task", "error", err)
+	}
+}
+
+// retryTask releases a task that failed with a transient error, so that it's retried after a backoff instead
+// of failing the workflow instance.
+func (ww *WorkflowWorker) retryTask(ctx context.Context, t *task.Workflow, err error) {
+	ww.attemptsMu.Lock()
+	ww.attempts[t.WorkflowInstance.InstanceID]++
+	attempt := ww.attempts[t.WorkflowInstance.InstanceID]
+	ww.attemptsMu.Unlock()
+
+	delay := retryBackoff(ww.options.WorkflowTaskRetryBackoff, attempt)
+
+	ww.backend.Metrics().Counter(metrickeys.WorkflowTaskRetried, metrics.Tags{}, 1)
+	ww.logger.Warn("Transient error while executing workflow task, retrying",
+		"instance_id", t.WorkflowInstance.InstanceID,
+		"task_id", t.ID,
+		"attempt", attempt,
+		"delay_ms", delay.Milliseconds(),
+		"error", err,
+	)
+
+	a, ok := ww.backend.(backend.WorkflowTaskAbandoner)
+	if !ok {
+		// The task will be picked up again once its lock expires
+		return
+	}
+
+	if err := a.AbandonWorkflowTask(ctx, t, delay); err != nil {
+		ww.logger.Error("could not abandon workflow task", "error", err)
+	}
+}
+
+func (ww *WorkflowWorker) resetAttempts(t *task.Workflow) {
+	ww.attemptsMu.Lock()
+	defer ww.attemptsMu.Unlock()
+
+	delete(ww.attempts, t.WorkflowInstance.InstanceID)
+}
+
+// retryBackoff doubles the initial delay for every attempt after the first, up to maxWorkflowTaskRetryBackoff.
+func retryBackoff(initial time.Duration, attempt int) time.Duration {
+	if initial <= 0 {
+		initial = DefaultOptions.WorkflowTaskRetryBackoff
+	}
+
+	delay := initial
+	for i := 1; i < attempt && delay < maxWorkflowTaskRetryBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > maxWorkflowTaskRetryBackoff {
+		delay = maxWorkflowTaskRetryBackoff
+	}
+
+	return delay
+}
+
+// chunkNewEvents splits the events into chunks of the given size. If the WorkflowExecutionStarted event is not
+// part of the first chunk, the first chunk is extended up to and including it, since no other event can be executed
+// before it. The order of the events is kept.
+func chunkNewEvents(events []history.Event, size int) [][]history.Event {
+	first := size
+	for i, e := range events {
+		if e.Type == history.EventType_WorkflowExecutionStarted {
+			if i >= first {
+				first = i + 1
+			}
+
+			break
+		}
+	}
+
+	if first > len(events) {
+		first = len(events)
+	}
+
+	chunks := [][]history.Event{events[:first]}
+	for i := first; i < len(events); i += size {
+		end := i + size
+		if end > len(events) {
+			end = len(events)
+		}
+
+		chunks = append(chunks, events[i:end])
+	}
+
+	return chunks
 }
 
 func (ww *WorkflowWorker) handleTask(
@@ -176,12 +614,95 @@ func (ww *WorkflowWorker) handleTask(
 		go ww.heartbeatTask(heartbeatCtx, t)
 	}
 
+	if c, ok := ww.backend.(backend.WorkflowTaskCheckpointer); ok {
+		if size := ww.options.WorkflowTaskChunkSize; size > 0 && len(t.NewEvents) > size {
+			return ww.executeChunks(ctx, executor, c, t, size)
+		}
+	}
+
+	_, result, err := ww.executeTask(ctx, executor, t)
+	return result, err
+}
+
+// executeChunks executes the new events of a large task in chunks of the given size. The progress of every chunk
+// but the last one is checkpointed while the task stays locked, the caller completes the task with the result of
+// the last chunk. If the workflow completes before all events are executed, the remaining ones are not executed.
+func (ww *WorkflowWorker) executeChunks(
+	ctx context.Context,
+	executor workflow.WorkflowExecutor,
+	checkpointer backend.WorkflowTaskCheckpointer,
+	t *task.Workflow,
+	size int,
+) (*workflow.ExecutionResult, error) {
+	chunks := chunkNewEvents(t.NewEvents, size)
+	lastSequenceID := t.LastSequenceID
+
+	for i, events := range chunks {
+		ct := *t
+		ct.NewEvents = events
+		ct.LastSequenceID = lastSequenceID
+
+		var result *workflow.ExecutionResult
+		var err error
+		executor, result, err = ww.executeTask(ctx, executor, &ct)
+		if err != nil {
+			return nil, err
+		}
+
+		if i == len(chunks)-1 || result.Completed {
+			return result, nil
+		}
+
+		ww.logger.Debug("Checkpointing workflow task",
+			"instance_id", t.WorkflowInstance.InstanceID, "task_id", t.ID, "chunk", i+1, "chunks", len(chunks))
+
+		ww.backend.Metrics().Counter(metrickeys.ActivityTaskScheduled, metrics.Tags{}, int64(len(result.ActivityEvents)))
+
+		if err := checkpointer.CheckpointWorkflowTask(
+			ctx, t, t.WorkflowInstance, result.Executed, result.ActivityEvents, result.TimerEvents, result.WorkflowEvents); err != nil {
+			ww.logger.Panic("could not checkpoint workflow task", "error", err)
+		}
+
+		if len(result.Executed) > 0 {
+			lastSequenceID = result.Executed[len(result.Executed)-1].SequenceID
+		}
+	}
+
+	// Unreachable, there is always at least one chunk
+	return nil, errors.New("workflow task without events")
+}
+
+// executeTask executes the task with the given executor. If the executor is ahead of the task, it's replaced with
+// a new one, which is returned.
+func (ww *WorkflowWorker) executeTask(
+	ctx context.Context,
+	executor workflow.WorkflowExecutor,
+	t *task.Workflow,
+) (workflow.WorkflowExecutor, *workflow.ExecutionResult, error) {
 	result, err := executor.ExecuteTask(ctx, t)
+	if errors.Is(err, workflow.ErrExecutorAhead) {
+		// The cached executor has executed events that were never committed, e.g., because the lock of an earlier
+		// delivery of this task expired. Replay the history with a new executor instead.
+		ww.logger.Warn("Cached executor is ahead of workflow task, replaying history",
+			"instance_id", t.WorkflowInstance.InstanceID, "task_id", t.ID)
+
+		executor.Close()
+
+		if executor, err = ww.newExecutor(t.WorkflowInstance); err != nil {
+			return nil, nil, err
+		}
+
+		if err := ww.cache.Store(ctx, t.WorkflowInstance, executor); err != nil {
+			ww.logger.Error("error while caching workflow task executor:", "error", err)
+		}
+
+		result, err = executor.ExecuteTask(ctx, t)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("executing workflow task: %w", err)
+		return executor, nil, fmt.Errorf("executing workflow task: %w", err)
 	}
 
-	return result, nil
+	return executor, result, nil
 }
 
 func (ww *WorkflowWorker) getExecutor(ctx context.Context, t *task.Workflow) (workflow.WorkflowExecutor, error) {
@@ -191,11 +712,14 @@ func (ww *WorkflowWorker) getExecutor(ctx context.Context, t *task.Workflow) (wo
 		ww.logger.Error("could not get cached workflow task executor", "error", err)
 	}
 
-	if !ok {
-		executor, err = workflow.NewExecutor(
-			ww.backend.Logger(), ww.backend.Tracer(), ww.registry, ww.backend, t.WorkflowInstance, clock.New())
+	if ok {
+		ww.backend.Metrics().Counter(metrickeys.WorkflowInstanceCacheHit, metrics.Tags{}, 1)
+	} else {
+		ww.backend.Metrics().Counter(metrickeys.WorkflowInstanceCacheMiss, metrics.Tags{}, 1)
+
+		executor, err = ww.newExecutor(t.WorkflowInstance)
 		if err != nil {
-			return nil, fmt.Errorf("creating workflow executor: %w", err)
+			return nil, err
 		}
 	}
 
@@ -207,6 +731,31 @@ func (ww *WorkflowWorker) getExecutor(ctx context.Context, t *task.Workflow) (wo
 	return executor, nil
 }
 
+func (ww *WorkflowWorker) newExecutor(instance *core.WorkflowInstance) (workflow.WorkflowExecutor, error) {
+	opts := []workflow.ExecutorOption{
+		workflow.WithComputeWarningThreshold(ww.options.WorkflowComputeWarningThreshold),
+		workflow.WithReplayBudget(ww.options.WorkflowReplayBudget),
+		workflow.WithStrictReplay(ww.options.StrictReplay),
+		workflow.WithLenientDecoding(ww.options.LenientPayloadDecoding),
+		workflow.WithConfigSource(ww.options.ConfigSource),
+		workflow.WithActivityInterceptors(ww.options.ActivityInterceptors),
+		workflow.WithOptionDefaults(ww.optionDefaults),
+	}
+
+	if ww.options.SchedulerTracer != nil {
+		opts = append(opts, workflow.WithSchedulerTracer(ww.options.SchedulerTracer()))
+	}
+
+	executor, err := workflow.NewExecutor(
+		ww.backend.Logger(), ww.backend.Tracer(), ww.backend.Metrics(), ww.registry, ww.backend, instance, clock.New(),
+		ww.options.SubWorkflowInstanceID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating workflow executor: %w", err)
+	}
+
+	return executor, nil
+}
+
 func (ww *WorkflowWorker) heartbeatTask(ctx context.Context, task *task.Workflow) {
 	t := time.NewTicker(ww.options.WorkflowHeartbeatInterval)
 	defer t.Stop()
@@ -0,0 +1,139 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/logger"
+	mi "github.com/cschleiden/go-workflows/internal/metrics"
+	"github.com/cschleiden/go-workflows/internal/task"
+	"github.com/cschleiden/go-workflows/internal/workflow"
+	"github.com/stretchr/testify/require"
+)
+
+type abandoningBackend struct {
+	*backend.MockBackend
+
+	abandoned []*task.Workflow
+	delays    []time.Duration
+}
+
+func (b *abandoningBackend) AbandonWorkflowTask(ctx context.Context, t *task.Workflow, delay time.Duration) error {
+	b.abandoned = append(b.abandoned, t)
+	b.delays = append(b.delays, delay)
+	return nil
+}
+
+func Test_YieldToOtherInstances(t *testing.T) {
+	mb := &backend.MockBackend{}
+	mb.On("Metrics").Return(mi.NewNoopMetricsClient())
+
+	b := &abandoningBackend{MockBackend: mb}
+
+	ww := &WorkflowWorker{
+		backend: b,
+		logger:  logger.NewDefaultLogger(),
+		options: &Options{MaxConsecutiveInstanceTasks: 2},
+	}
+
+	a := &task.Workflow{ID: "a", WorkflowInstance: core.NewWorkflowInstance("a", "execution")}
+	other := &task.Workflow{ID: "other", WorkflowInstance: core.NewWorkflowInstance("other", "execution")}
+
+	require.False(t, ww.yieldToOtherInstances(a))
+	require.False(t, ww.yieldToOtherInstances(a))
+
+	// The third task in a row is released instead of being dispatched
+	require.True(t, ww.yieldToOtherInstances(a))
+	require.Equal(t, []*task.Workflow{a}, b.abandoned)
+
+	// Count starts over
+	require.False(t, ww.yieldToOtherInstances(a))
+	require.False(t, ww.yieldToOtherInstances(other))
+	require.False(t, ww.yieldToOtherInstances(a))
+	require.Len(t, b.abandoned, 1)
+}
+
+func Test_YieldToOtherInstances_NotSupported(t *testing.T) {
+	ww := &WorkflowWorker{
+		backend: &backend.MockBackend{},
+		logger:  logger.NewDefaultLogger(),
+		options: &Options{MaxConsecutiveInstanceTasks: 1},
+	}
+
+	a := &task.Workflow{ID: "a", WorkflowInstance: core.NewWorkflowInstance("a", "execution")}
+
+	// Tasks are always dispatched if they can't be released
+	for i := 0; i < 3; i++ {
+		require.False(t, ww.yieldToOtherInstances(a))
+	}
+}
+
+type parkingBackend struct {
+	abandoningBackend
+
+	parked []*task.Workflow
+}
+
+func (b *parkingBackend) ParkWorkflowTask(ctx context.Context, t *task.Workflow, delay time.Duration) error {
+	b.parked = append(b.parked, t)
+	return nil
+}
+
+func Test_ParkTask(t *testing.T) {
+	b := &parkingBackend{abandoningBackend: abandoningBackend{MockBackend: &backend.MockBackend{}}}
+
+	ww := &WorkflowWorker{
+		backend:  b,
+		logger:   logger.NewDefaultLogger(),
+		options:  &Options{},
+		attempts: make(map[string]int),
+	}
+
+	a := &task.Workflow{ID: "a", WorkflowInstance: core.NewWorkflowInstance("a", "execution")}
+
+	// Parked tasks stay sticky and don't count as failed attempts
+	ww.parkTask(context.Background(), a)
+	require.Equal(t, []*task.Workflow{a}, b.parked)
+	require.Empty(t, b.abandoned)
+	require.Empty(t, ww.attempts)
+}
+
+func Test_ReleasePinnedTask(t *testing.T) {
+	b := &abandoningBackend{MockBackend: &backend.MockBackend{}}
+
+	ww := &WorkflowWorker{
+		backend:  b,
+		logger:   logger.NewDefaultLogger(),
+		options:  &Options{},
+		attempts: make(map[string]int),
+	}
+
+	a := &task.Workflow{ID: "a", WorkflowInstance: core.NewWorkflowInstance("a", "execution")}
+
+	// Pinned tasks are released right away and don't count as failed attempts
+	ww.releasePinnedTask(context.Background(), a, &workflow.PinnedVersionError{Workflow: "wf", Version: "2", RegisteredVersion: "1"})
+	require.Equal(t, []*task.Workflow{a}, b.abandoned)
+	require.Equal(t, []time.Duration{0}, b.delays)
+	require.Empty(t, ww.attempts)
+}
+
+func Test_ChunkNewEvents(t *testing.T) {
+	signal := func() history.Event {
+		return history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{})
+	}
+	started := history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{})
+
+	events := []history.Event{signal(), signal(), signal()}
+	require.Equal(t, [][]history.Event{events[:2], events[2:]}, chunkNewEvents(events, 2))
+
+	// Events up to the start of the instance are kept in the first chunk, in order
+	events = []history.Event{signal(), signal(), started, signal(), signal(), signal()}
+	require.Equal(t, [][]history.Event{events[:3], events[3:5], events[5:]}, chunkNewEvents(events, 2))
+
+	events = []history.Event{signal(), started}
+	require.Equal(t, [][]history.Event{events}, chunkNewEvents(events, 1))
+}
@@ -2,6 +2,8 @@ package cache
 
 import (
 	"context"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/cschleiden/go-workflows/internal/core"
@@ -13,18 +15,45 @@ import (
 
 type LruCache struct {
 	mc metrics.Client
-	c  *ttlcache.Cache[string, workflow.WorkflowExecutor]
+	c  *ttlcache.Cache[string, *entry]
+
+	// maxMemory is the approximate memory budget for all cached executors. If <= 0, no budget is enforced.
+	maxMemory int64
+	memoryMu  sync.Mutex
+}
+
+type entry struct {
+	instance *core.WorkflowInstance
+	executor workflow.WorkflowExecutor
+}
+
+type LruCacheOption func(o *lruCacheOptions)
+
+type lruCacheOptions struct {
+	onEvict func(instance *core.WorkflowInstance, reason string)
+}
+
+// WithEvictionHook registers a function that's called whenever an executor is evicted from the cache.
+func WithEvictionHook(onEvict func(instance *core.WorkflowInstance, reason string)) LruCacheOption {
+	return func(o *lruCacheOptions) {
+		o.onEvict = onEvict
+	}
 }
 
-func NewWorkflowExecutorLRUCache(mc metrics.Client, size int, expiration time.Duration) workflow.ExecutorCache {
+func NewWorkflowExecutorLRUCache(mc metrics.Client, size int, expiration time.Duration, maxMemory int64, opts ...LruCacheOption) workflow.ExecutorCache {
+	var options lruCacheOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	c := ttlcache.New(
-		ttlcache.WithCapacity[string, workflow.WorkflowExecutor](uint64(size)),
-		ttlcache.WithTTL[string, workflow.WorkflowExecutor](expiration),
+		ttlcache.WithCapacity[string, *entry](uint64(size)),
+		ttlcache.WithTTL[string, *entry](expiration),
 	)
 
-	c.OnEviction(func(ctx context.Context, er ttlcache.EvictionReason, i *ttlcache.Item[string, workflow.WorkflowExecutor]) {
+	c.OnEviction(func(ctx context.Context, er ttlcache.EvictionReason, i *ttlcache.Item[string, *entry]) {
 		// Close the executor to allow it to clean up resources.
-		i.Value().Close()
+		i.Value().executor.Close()
 
 		reason := ""
 		switch er {
@@ -32,34 +61,84 @@ func NewWorkflowExecutorLRUCache(mc metrics.Client, size int, expiration time.Du
 			reason = "expired"
 		case ttlcache.EvictionReasonCapacityReached:
 			reason = "capacity"
+		case ttlcache.EvictionReasonDeleted:
+			// Entries are only deleted explicitly when the memory budget is exceeded
+			reason = "memory"
 		}
 
 		mc.Counter(metrickeys.WorkflowInstanceCacheEviction, metrics.Tags{metrickeys.EvictionReason: reason}, 1)
+
+		if options.onEvict != nil {
+			options.onEvict(i.Value().instance, reason)
+		}
 	})
 
 	return &LruCache{
-		mc: mc,
-		c:  c,
+		mc:        mc,
+		c:         c,
+		maxMemory: maxMemory,
 	}
 }
 
 func (lc *LruCache) Get(ctx context.Context, instance *core.WorkflowInstance) (workflow.WorkflowExecutor, bool, error) {
 	e := lc.c.Get(getKey(instance))
 	if e != nil {
-		return e.Value(), true, nil
+		return e.Value().executor, true, nil
 	}
 
 	return nil, false, nil
 }
 
 func (lc *LruCache) Store(ctx context.Context, instance *core.WorkflowInstance, executor workflow.WorkflowExecutor) error {
-	lc.c.Set(getKey(instance), executor, ttlcache.DefaultTTL)
+	key := getKey(instance)
+
+	lc.c.Set(key, &entry{instance, executor}, ttlcache.DefaultTTL)
+
+	if lc.maxMemory > 0 {
+		if executor.MemoryUsage() > lc.maxMemory {
+			// The executor alone exceeds the budget, evict it right away
+			lc.c.Delete(key)
+		} else {
+			lc.enforceMemoryBudget()
+		}
+	}
 
 	lc.mc.Gauge(metrickeys.WorkflowInstanceCacheSize, metrics.Tags{}, int64(lc.c.Len()))
 
 	return nil
 }
 
+// enforceMemoryBudget evicts the least recently used executors until the approximate memory held by all
+// cached executors is within the budget.
+func (lc *LruCache) enforceMemoryBudget() {
+	lc.memoryMu.Lock()
+	defer lc.memoryMu.Unlock()
+
+	items := make([]*ttlcache.Item[string, *entry], 0, lc.c.Len())
+	usage := int64(0)
+	for _, item := range lc.c.Items() {
+		items = append(items, item)
+		usage += item.Value().executor.MemoryUsage()
+	}
+
+	// All entries share the same TTL which is extended on access, so the entry expiring first is
+	// the least recently used one.
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ExpiresAt().Before(items[j].ExpiresAt())
+	})
+
+	for _, item := range items {
+		if usage <= lc.maxMemory {
+			break
+		}
+
+		usage -= item.Value().executor.MemoryUsage()
+		lc.c.Delete(item.Key())
+	}
+
+	lc.mc.Gauge(metrickeys.WorkflowInstanceCacheMemory, metrics.Tags{}, usage)
+}
+
 func (lc *LruCache) StartEviction(ctx context.Context) {
 	go lc.c.Start()
 
@@ -12,6 +12,7 @@ import (
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/logger"
 	"github.com/cschleiden/go-workflows/internal/metrics"
+	"github.com/cschleiden/go-workflows/internal/task"
 	wf "github.com/cschleiden/go-workflows/internal/workflow"
 	"github.com/cschleiden/go-workflows/workflow"
 	"github.com/stretchr/testify/require"
@@ -19,19 +20,19 @@ import (
 )
 
 func Test_Cache_StoreAndGet(t *testing.T) {
-	c := NewWorkflowExecutorLRUCache(metrics.NewNoopMetricsClient(), 1, time.Second*10)
+	c := NewWorkflowExecutorLRUCache(metrics.NewNoopMetricsClient(), 1, time.Second*10, 0)
 
 	r := wf.NewRegistry()
 	r.RegisterWorkflow(workflowWithActivity)
 
 	i := core.NewWorkflowInstance("instanceID", "executionID")
 	e, err := wf.NewExecutor(
-		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), r, &testHistoryProvider{}, i, clock.New())
+		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), metrics.NewNoopMetricsClient(), r, &testHistoryProvider{}, i, clock.New(), nil)
 	require.NoError(t, err)
 
 	i2 := core.NewWorkflowInstance("instanceID2", "executionID2")
 	e2, err := wf.NewExecutor(
-		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), r, &testHistoryProvider{}, i, clock.New())
+		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), metrics.NewNoopMetricsClient(), r, &testHistoryProvider{}, i, clock.New(), nil)
 	require.NoError(t, err)
 
 	err = c.Store(context.Background(), i, e)
@@ -51,18 +52,58 @@ func Test_Cache_StoreAndGet(t *testing.T) {
 	require.False(t, ok)
 }
 
+func Test_Cache_EvictionHook(t *testing.T) {
+	type eviction struct {
+		instance *core.WorkflowInstance
+		reason   string
+	}
+
+	evictions := make(chan eviction, 1)
+	c := NewWorkflowExecutorLRUCache(metrics.NewNoopMetricsClient(), 1, time.Second*10, 0,
+		WithEvictionHook(func(instance *core.WorkflowInstance, reason string) {
+			evictions <- eviction{instance, reason}
+		}))
+
+	r := wf.NewRegistry()
+	r.RegisterWorkflow(workflowWithActivity)
+
+	i := core.NewWorkflowInstance("instanceID", "executionID")
+	e, err := wf.NewExecutor(
+		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), metrics.NewNoopMetricsClient(), r, &testHistoryProvider{}, i, clock.New(), nil)
+	require.NoError(t, err)
+
+	i2 := core.NewWorkflowInstance("instanceID2", "executionID2")
+	e2, err := wf.NewExecutor(
+		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), metrics.NewNoopMetricsClient(), r, &testHistoryProvider{}, i2, clock.New(), nil)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Store(context.Background(), i, e))
+
+	// Storing another executor evicts the first one. Eviction callbacks are called asynchronously.
+	require.NoError(t, c.Store(context.Background(), i2, e2))
+
+	select {
+	case ev := <-evictions:
+		require.Equal(t, i, ev.instance)
+		require.Equal(t, "capacity", ev.reason)
+	case <-time.After(time.Second):
+		require.FailNow(t, "executor was not evicted")
+	}
+}
+
 func Test_Cache_Evict(t *testing.T) {
 	c := NewWorkflowExecutorLRUCache(
 		metrics.NewNoopMetricsClient(),
 		128,
 		1, // Should evict immediately
+		0,
 	)
 
 	i := core.NewWorkflowInstance("instanceID", "executionID")
 	r := wf.NewRegistry()
 	r.RegisterWorkflow(workflowWithActivity)
 	e, err := wf.NewExecutor(
-		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), r, &testHistoryProvider{}, i, clock.New())
+		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), metrics.NewNoopMetricsClient(), r, &testHistoryProvider{}, i, clock.New(), nil)
 	require.NoError(t, err)
 
 	err = c.Store(context.Background(), i, e)
@@ -78,6 +119,67 @@ func Test_Cache_Evict(t *testing.T) {
 	require.Nil(t, e2)
 }
 
+func Test_Cache_MemoryBudget(t *testing.T) {
+	c := NewWorkflowExecutorLRUCache(metrics.NewNoopMetricsClient(), 128, time.Second*10, 100)
+	ctx := context.Background()
+
+	i1 := core.NewWorkflowInstance("instanceID1", "executionID")
+	i2 := core.NewWorkflowInstance("instanceID2", "executionID")
+	i3 := core.NewWorkflowInstance("instanceID3", "executionID")
+
+	require.NoError(t, c.Store(ctx, i1, &sizedExecutor{size: 40}))
+	time.Sleep(time.Millisecond)
+	require.NoError(t, c.Store(ctx, i2, &sizedExecutor{size: 40}))
+	time.Sleep(time.Millisecond)
+
+	// Access the first executor, making the second one the least recently used
+	_, ok, err := c.Get(ctx, i1)
+	require.NoError(t, err)
+	require.True(t, ok)
+	time.Sleep(time.Millisecond)
+
+	// Exceeds the budget, should evict the second executor
+	require.NoError(t, c.Store(ctx, i3, &sizedExecutor{size: 40}))
+
+	_, ok, _ = c.Get(ctx, i1)
+	require.True(t, ok)
+	_, ok, _ = c.Get(ctx, i2)
+	require.False(t, ok)
+	_, ok, _ = c.Get(ctx, i3)
+	require.True(t, ok)
+}
+
+func Test_Cache_MemoryBudget_ExecutorExceedsBudget(t *testing.T) {
+	c := NewWorkflowExecutorLRUCache(metrics.NewNoopMetricsClient(), 128, time.Second*10, 100)
+	ctx := context.Background()
+
+	i := core.NewWorkflowInstance("instanceID", "executionID")
+	require.NoError(t, c.Store(ctx, i, &sizedExecutor{size: 200}))
+
+	_, ok, err := c.Get(ctx, i)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+type sizedExecutor struct {
+	size int64
+}
+
+func (e *sizedExecutor) ExecuteTask(ctx context.Context, t *task.Workflow) (*wf.ExecutionResult, error) {
+	return &wf.ExecutionResult{}, nil
+}
+
+func (e *sizedExecutor) Replay(ctx context.Context, instance *core.WorkflowInstance) error {
+	return nil
+}
+
+func (e *sizedExecutor) MemoryUsage() int64 {
+	return e.size
+}
+
+func (e *sizedExecutor) Close() {
+}
+
 func workflowWithActivity(ctx workflow.Context) (int, error) {
 	r, err := workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
 		RetryOptions: workflow.RetryOptions{
@@ -0,0 +1,81 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/workflowerrors"
+)
+
+// TransientError is returned by the executor when a workflow task could not be executed for reasons
+// unrelated to the workflow code, e.g., because the history could not be fetched from the backend.
+// The task can be retried, unlike errors in the workflow itself which fail the workflow instance.
+type TransientError struct {
+	Err error
+}
+
+func (e *TransientError) Error() string {
+	return "transient error: " + e.Err.Error()
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// IsTransientError returns true if err is a TransientError or a timeout.
+func IsTransientError(err error) bool {
+	var terr *TransientError
+	if errors.As(err, &terr) {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// ErrExecutorAhead is returned by the executor when a task has older history than the executor, e.g., because the
+// executor executed an earlier delivery of the task whose completion was never committed. The history has to be
+// replayed by a new executor to execute the task.
+var ErrExecutorAhead = errors.New("task has older history than current state, cannot execute")
+
+// PinnedVersionError is returned by the executor when a workflow instance is pinned to a version of its
+// workflow that differs from the version registered with the worker. It's returned as a TransientError so
+// that the task is left for a worker with the matching version. Workers release such tasks without a backoff
+// and without counting them as failed attempts.
+type PinnedVersionError struct {
+	Workflow string
+
+	// Version is the version the instance is pinned to.
+	Version string
+
+	// RegisteredVersion is the version registered with this worker.
+	RegisteredVersion string
+}
+
+func (e *PinnedVersionError) Error() string {
+	return fmt.Sprintf("workflow %s is pinned to version %q, but version %q is registered", e.Workflow, e.Version, e.RegisteredVersion)
+}
+
+// ReplayBudgetExceededError is returned by the executor when replaying the history of a workflow instance takes
+// longer than the configured replay budget. It's returned as a TransientError. The events replayed so far are
+// kept, so a cached executor resumes replaying where it stopped when the task is retried.
+type ReplayBudgetExceededError struct {
+	Budget time.Duration
+
+	// Replayed is the number of events replayed before the budget was exceeded.
+	Replayed int
+
+	// LastSequenceID is the sequence id of the last replayed event.
+	LastSequenceID int64
+}
+
+func (e *ReplayBudgetExceededError) Error() string {
+	return fmt.Sprintf("replaying history exceeded budget of %v after %d events", e.Budget, e.Replayed)
+}
+
+// nonDeterminismError returns a *workflowerrors.NonDeterminismError with the given message. The executor returns it
+// when replaying the history doesn't produce the commands of the previous execution.
+func nonDeterminismError(format string, args ...interface{}) error {
+	return &workflowerrors.NonDeterminismError{Message: fmt.Sprintf(format, args...)}
+}
@@ -1,15 +1,21 @@
 package workflow
 
 import (
+	"bytes"
 	"context"
+	crand "crypto/rand"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"reflect"
+	"sync/atomic"
+	"time"
 
 	"github.com/benbjohnson/clock"
 	"github.com/cschleiden/go-workflows/internal/command"
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/metrickeys"
 	"github.com/cschleiden/go-workflows/internal/payload"
 	"github.com/cschleiden/go-workflows/internal/sync"
 	"github.com/cschleiden/go-workflows/internal/task"
@@ -17,16 +23,27 @@ import (
 	"github.com/cschleiden/go-workflows/internal/workflowstate"
 	"github.com/cschleiden/go-workflows/internal/workflowtracer"
 	"github.com/cschleiden/go-workflows/log"
+	"github.com/cschleiden/go-workflows/metrics"
+	"github.com/cschleiden/go-workflows/workflowerrors"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type ExecutionResult struct {
+	// WorkflowName is the name of the executed workflow, if known
+	WorkflowName string
+
 	Completed      bool
 	Executed       []history.Event
 	ActivityEvents []history.Event
 	TimerEvents    []history.Event
 	WorkflowEvents []history.WorkflowEvent
+
+	// SchedulerStats are statistics about the coroutines of the workflow, if it was started
+	SchedulerStats *sync.SchedulerStats
+
+	// StartedAt is the time the workflow instance was started, if known
+	StartedAt time.Time
 }
 
 type WorkflowHistoryProvider interface {
@@ -36,9 +53,92 @@ type WorkflowHistoryProvider interface {
 type WorkflowExecutor interface {
 	ExecuteTask(ctx context.Context, t *task.Workflow) (*ExecutionResult, error)
 
+	// Replay fetches the history of the instance the executor has not seen yet and replays it, without
+	// executing a task. It's used to prefetch instances before their next task arrives.
+	Replay(ctx context.Context, instance *core.WorkflowInstance) error
+
+	// MemoryUsage returns the approximate number of bytes held by the executor. It is safe to call
+	// concurrently with ExecuteTask.
+	MemoryUsage() int64
+
 	Close()
 }
 
+// Rough estimates for the memory held per history event and per pending future, in addition to
+// the payloads of the events.
+const (
+	eventMemoryOverhead  = 256
+	futureMemoryOverhead = 512
+)
+
+type ExecutorOption func(e *executor)
+
+// WithComputeWarningThreshold configures the executor to log a warning and emit a metric when workflow
+// code runs longer than the given duration without yielding. A threshold of 0 disables the check.
+func WithComputeWarningThreshold(threshold time.Duration) ExecutorOption {
+	return func(e *executor) {
+		e.computeWarningThreshold = threshold
+	}
+}
+
+// WithActivityInterceptors configures interceptors for the inputs and results of activities scheduled by
+// the workflow.
+func WithActivityInterceptors(interceptors []workflowstate.ActivityInterceptor) ExecutorOption {
+	return func(e *executor) {
+		e.workflowState.SetActivityInterceptors(interceptors)
+	}
+}
+
+// WithOptionDefaults configures default options for activities and sub-workflows started by the workflow.
+func WithOptionDefaults(defaults workflowstate.OptionDefaults) ExecutorOption {
+	return func(e *executor) {
+		e.workflowState.SetOptionDefaults(defaults)
+	}
+}
+
+// WithLenientDecoding configures the executor to decode payloads that don't match the expected type as far as
+// possible, using zero values for mismatching fields and logging a warning, instead of failing with a
+// workflowstate.SchemaMismatchError.
+func WithLenientDecoding(lenient bool) ExecutorOption {
+	return func(e *executor) {
+		e.workflowState.SetLenientDecoding(lenient)
+	}
+}
+
+// WithConfigSource sets the source of the configuration values read by workflow.GetConfigValue.
+func WithConfigSource(source workflowstate.ConfigSource) ExecutorOption {
+	return func(e *executor) {
+		e.workflowState.SetConfigSource(source)
+	}
+}
+
+// WithStrictReplay configures the executor to compare the inputs of activities and sub-workflows scheduled
+// during replay with the ones recorded in the history, in addition to their names.
+func WithStrictReplay(strict bool) ExecutorOption {
+	return func(e *executor) {
+		e.strictReplay = strict
+	}
+}
+
+// WithReplayBudget limits the time spent replaying history in a single workflow task. When the budget is
+// exceeded, the task fails with a *ReplayBudgetExceededError wrapped in a TransientError. A budget of 0 disables
+// the limit.
+func WithReplayBudget(budget time.Duration) ExecutorOption {
+	return func(e *executor) {
+		e.replayBudget = budget
+	}
+}
+
+// WithSchedulerTracer configures a tracer receiving the creation, wake-ups, blocking, and exit of the
+// coroutines of the workflow. Tracers with a Reset method are reset at the start of every workflow task, and
+// tracers implementing fmt.Stringer are logged when replaying or executing the workflow fails, e.g., because
+// of non-deterministic workflow code.
+func WithSchedulerTracer(t sync.SchedulerTracer) ExecutorOption {
+	return func(e *executor) {
+		e.schedulerTracer = t
+	}
+}
+
 type executor struct {
 	registry           *Registry
 	historyProvider    WorkflowHistoryProvider
@@ -46,20 +146,56 @@ type executor struct {
 	workflowTracer     *workflowtracer.WorkflowTracer
 	workflowState      *workflowstate.WfState
 	workflowCtx        sync.Context
-	workflowCtxCancel  sync.CancelFunc
+	workflowCtxCancel  sync.CancelCauseFunc
 	clock              clock.Clock
 	logger             log.Logger
 	tracer             trace.Tracer
+	metrics            metrics.Client
 	lastSequenceID     int64
 	wfStartedEventSeen bool
+	workflowName       string
+
+	// started holds the attributes the current execution was started with
+	started *history.ExecutionStartedAttributes
+
+	// historySize is the approximate size of the history seen by this executor
+	historySize int64
+
+	// memoryUsage is updated after every task and read atomically
+	memoryUsage int64
+
+	// computeWarningThreshold is the time workflow code may run without yielding before a warning
+	// is logged
+	computeWarningThreshold time.Duration
+
+	// strictReplay determines if inputs of replayed commands are compared with the history
+	strictReplay bool
+
+	// schedulerTracer, if set, traces the coroutine scheduling of the workflow
+	schedulerTracer sync.SchedulerTracer
+
+	// replayBudget is the maximum time spent replaying history in a single task, 0 if unlimited
+	replayBudget time.Duration
+
+	// executionTimeoutEvent is the cancellation event scheduled when a new execution with a timeout starts
+	executionTimeoutEvent *history.Event
+
+	// activityTimeoutEvents are the failure events scheduled when activities with a start-to-close or heartbeat
+	// timeout start
+	activityTimeoutEvents []history.Event
 }
 
-func NewExecutor(logger log.Logger, tracer trace.Tracer, registry *Registry, historyProvider WorkflowHistoryProvider, instance *core.WorkflowInstance, clock clock.Clock) (WorkflowExecutor, error) {
-	s := workflowstate.NewWorkflowState(instance, logger, clock)
+func NewExecutor(
+	logger log.Logger, tracer trace.Tracer, metrics metrics.Client, registry *Registry, historyProvider WorkflowHistoryProvider,
+	instance *core.WorkflowInstance, clock clock.Clock, subWorkflowInstanceID workflowstate.SubWorkflowInstanceIDFunc,
+	opts ...ExecutorOption,
+) (WorkflowExecutor, error) {
+	s := workflowstate.NewWorkflowState(instance, logger, metrics, clock)
+	s.SetSubWorkflowInstanceIDFunc(subWorkflowInstanceID)
 
 	wfTracer := workflowtracer.New(tracer)
 
-	wfCtx, cancel := sync.WithCancel(
+	wfCtx, cancel := sync.WithCancelCause(
 		workflowstate.WithWorkflowState(
 			workflowtracer.WithWorkflowTracer(
 				sync.Background(),
@@ -69,7 +205,7 @@ func NewExecutor(logger log.Logger, tracer trace.Tracer, registry *Registry, his
 		),
 	)
 
-	return &executor{
+	e := &executor{
 		registry:           registry,
 		historyProvider:    historyProvider,
 		workflowTracer:     wfTracer,
@@ -79,8 +215,15 @@ func NewExecutor(logger log.Logger, tracer trace.Tracer, registry *Registry, his
 		clock:              clock,
 		logger:             logger,
 		tracer:             tracer,
+		metrics:            metrics,
 		wfStartedEventSeen: false,
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e, nil
 }
 
 func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*ExecutionResult, error) {
@@ -101,6 +244,10 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 
 	logger.Debug("Executing workflow task", "task_last_sequence_id", t.LastSequenceID)
 
+	if r, ok := e.schedulerTracer.(interface{ Reset() }); ok {
+		r.Reset()
+	}
+
 	if t.WorkflowInstanceState == core.WorkflowInstanceStateFinished {
 		// This could happen if signals are delivered after the workflow is finished
 		logger.Error("Received workflow task for finished workflow instance, discarding events")
@@ -115,6 +262,12 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 		}, nil
 	}
 
+	if !e.wfStartedEventSeen {
+		if err := e.checkPinnedVersion(t.NewEvents); err != nil {
+			return nil, err
+		}
+	}
+
 	skipNewEvents := false
 
 	if t.LastSequenceID > e.lastSequenceID {
@@ -122,11 +275,23 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 
 		h, err := e.historyProvider.GetWorkflowInstanceHistory(ctx, t.WorkflowInstance, &e.lastSequenceID)
 		if err != nil {
-			return nil, fmt.Errorf("getting workflow history: %w", err)
+			// The executor state has not been modified yet, so the task can be retried
+			return nil, &TransientError{Err: fmt.Errorf("getting workflow history: %w", err)}
 		}
 
-		if err := e.replayHistory(h); err != nil {
+		if err := e.checkPinnedVersion(h); err != nil {
+			return nil, err
+		}
+
+		if err := e.replayHistory(h, e.replayBudget); err != nil {
+			var budgetErr *ReplayBudgetExceededError
+			if errors.As(err, &budgetErr) {
+				// The executor state is consistent up to the last replayed event, so the task can be retried
+				return nil, &TransientError{Err: err}
+			}
+
 			logger.Error("Error while replaying history", "error", err)
+			e.logSchedulerTrace(logger)
 
 			// Fail workflow with an error. Skip executing new events, but still go through the commands
 			e.workflowCompleted(nil, err)
@@ -140,7 +305,7 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 			return nil, errors.New("even after fetching history and replaying history executor state does not match task")
 		}
 	} else if t.LastSequenceID < e.lastSequenceID {
-		return nil, fmt.Errorf("task has older history than current state, cannot execute")
+		return nil, ErrExecutorAhead
 	}
 
 	// Potentially reorder new events here, protecting against
@@ -185,6 +350,7 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 		executedEvents, err = e.executeNewEvents(toExecute)
 		if err != nil {
 			logger.Error("Error while executing new events", "error", err)
+			e.logSchedulerTrace(logger)
 
 			e.workflowCompleted(nil, err)
 		}
@@ -214,6 +380,36 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 		workflowEvents = append(workflowEvents, r.WorkflowEvents...)
 	}
 
+	// Schedule the timeout of a new execution, unless it already completed in its first task
+	if e.executionTimeoutEvent != nil {
+		if !completed {
+			timerEvents = append(timerEvents, *e.executionTimeoutEvent)
+		}
+
+		e.executionTimeoutEvent = nil
+	}
+
+	if !completed {
+		timerEvents = append(timerEvents, e.activityTimeoutEvents...)
+	}
+
+	e.activityTimeoutEvents = nil
+
+	// Link all events generated by this task to the task's WorkflowTaskStarted event
+	taskStartedID := toExecute[0].ID
+	for i := range newCommandEvents {
+		newCommandEvents[i].CausedBy = taskStartedID
+	}
+	for i := range activityEvents {
+		activityEvents[i].CausedBy = taskStartedID
+	}
+	for i := range timerEvents {
+		timerEvents[i].CausedBy = taskStartedID
+	}
+	for i := range workflowEvents {
+		workflowEvents[i].HistoryEvent.CausedBy = taskStartedID
+	}
+
 	// Events from commands don't have to be executed again, add them to the executed events.
 	executedEvents = append(executedEvents, newCommandEvents...)
 
@@ -222,6 +418,17 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 		executedEvents[i].SequenceID = e.nextSequenceID()
 	}
 
+	e.trackHistorySize(executedEvents)
+	e.updateMemoryUsage()
+
+	var stats *sync.SchedulerStats
+	if e.workflow != nil {
+		s := e.workflow.SchedulerStats()
+		stats = &s
+
+		logger = logger.With("coroutines", s.Coroutines, "coroutine_wakes", s.Wakes)
+	}
+
 	logger.Debug("Finished workflow task",
 		"executed", len(executedEvents),
 		"last_sequence_id", e.lastSequenceID,
@@ -229,17 +436,78 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 	)
 
 	return &ExecutionResult{
+		WorkflowName:   e.workflowName,
 		Completed:      completed,
 		Executed:       executedEvents,
 		ActivityEvents: activityEvents,
 		TimerEvents:    timerEvents,
 		WorkflowEvents: workflowEvents,
+		SchedulerStats: stats,
+		StartedAt:      e.workflowState.StartedAt(),
 	}, nil
 }
 
-func (e *executor) replayHistory(h []history.Event) error {
+// logSchedulerTrace logs the coroutine scheduling trace of the current workflow task, if available.
+func (e *executor) logSchedulerTrace(logger log.Logger) {
+	if s, ok := e.schedulerTracer.(fmt.Stringer); ok {
+		logger.Error("Coroutine scheduling trace", "trace", s.String())
+	}
+}
+
+func (e *executor) Replay(ctx context.Context, instance *core.WorkflowInstance) error {
+	h, err := e.historyProvider.GetWorkflowInstanceHistory(ctx, instance, &e.lastSequenceID)
+	if err != nil {
+		return &TransientError{Err: fmt.Errorf("getting workflow history: %w", err)}
+	}
+
+	if err := e.checkPinnedVersion(h); err != nil {
+		return err
+	}
+
+	if err := e.replayHistory(h, 0); err != nil {
+		return fmt.Errorf("replaying history: %w", err)
+	}
+
+	e.updateMemoryUsage()
+
+	return nil
+}
+
+func (e *executor) MemoryUsage() int64 {
+	return atomic.LoadInt64(&e.memoryUsage)
+}
+
+func (e *executor) trackHistorySize(events []history.Event) {
+	for _, event := range events {
+		e.historySize += eventMemoryOverhead + int64(history.PayloadSize(event))
+	}
+}
+
+func (e *executor) updateMemoryUsage() {
+	usage := e.historySize + int64(e.workflowState.PendingFutures())*futureMemoryOverhead
+	atomic.StoreInt64(&e.memoryUsage, usage)
+}
+
+// replayHistory replays the given events. If budget is not 0 and replaying takes longer, it stops after the
+// current event and returns a *ReplayBudgetExceededError.
+func (e *executor) replayHistory(h []history.Event, budget time.Duration) error {
 	e.workflowState.SetReplaying(true)
-	for _, event := range h {
+
+	if len(h) > 0 {
+		e.metrics.Counter(metrickeys.WorkflowReplayed, metrics.Tags{}, 1)
+		e.metrics.Counter(metrickeys.WorkflowReplayedEvents, metrics.Tags{}, int64(len(h)))
+	}
+
+	start := e.clock.Now()
+
+	// Replayed calls to workflow.GetConfigValue need to know whether a value was recorded for them
+	e.workflowState.TrackRecordedConfigValues(h)
+
+	// Replayed calls to workflow.GetVersion need to know which version was recorded for a change
+	e.workflowState.TrackRecordedVersions(h)
+
+	for i := range h {
+		event := &h[i]
 		if event.SequenceID < e.lastSequenceID {
 			e.logger.Panic("history has older events than current state")
 		}
@@ -257,20 +525,35 @@ func (e *executor) replayHistory(h []history.Event) error {
 		}
 
 		e.lastSequenceID = event.SequenceID
+
+		if budget > 0 && i < len(h)-1 && e.clock.Since(start) > budget {
+			e.trackHistorySize(h[:i+1])
+
+			return &ReplayBudgetExceededError{Budget: budget, Replayed: i + 1, LastSequenceID: e.lastSequenceID}
+		}
 	}
 
+	e.trackHistorySize(h)
+
 	return nil
 }
 
 func (e *executor) executeNewEvents(newEvents []history.Event) ([]history.Event, error) {
 	e.workflowState.SetReplaying(false)
 
-	for i, event := range newEvents {
-		if err := e.executeEvent(event); err != nil {
+	if e.workflow != nil {
+		// Only account for time spent executing new events, not for replaying history
+		e.workflow.LongestSlice()
+	}
+
+	for i := range newEvents {
+		if err := e.executeEvent(&newEvents[i]); err != nil {
 			return newEvents[:i], err
 		}
 	}
 
+	e.checkComputeTime()
+
 	if e.workflow.Completed() {
 		// TODO: Is this too early? We haven't committed some of the commands
 		if e.workflowState.HasPendingFutures() {
@@ -283,6 +566,31 @@ func (e *executor) executeNewEvents(newEvents []history.Event) ([]history.Event,
 	return newEvents, nil
 }
 
+// checkComputeTime warns if workflow code ran longer than the configured threshold without yielding.
+// Long stretches of computation block the worker and usually belong in an activity.
+func (e *executor) checkComputeTime() {
+	if e.workflow == nil || e.computeWarningThreshold <= 0 {
+		return
+	}
+
+	longest := e.workflow.LongestSlice()
+	if longest <= e.computeWarningThreshold {
+		return
+	}
+
+	instance := e.workflowState.Instance()
+
+	e.metrics.Counter(metrickeys.WorkflowComputeSlow, metrics.Tags{}, 1)
+	e.metrics.Timing(metrickeys.WorkflowComputeTime, metrics.Tags{}, longest)
+
+	e.logger.Warn("Workflow code ran for a long time without yielding, consider moving computation into an activity or calling workflow.Yield",
+		"instance_id", instance.InstanceID,
+		"execution_id", instance.ExecutionID,
+		"duration_ms", longest.Milliseconds(),
+		"threshold_ms", e.computeWarningThreshold.Milliseconds(),
+	)
+}
+
 func (e *executor) Close() {
 	if e.workflow != nil {
 		e.logger.Debug("Stopping workflow executor", "instance_id", e.workflowState.Instance().InstanceID)
@@ -292,7 +600,9 @@ func (e *executor) Close() {
 	}
 }
 
-func (e *executor) executeEvent(event history.Event) error {
+// executeEvent executes the given event. Attributes are only deserialized by the handlers that need them, most
+// events replayed from the history only need their type and schedule event ID.
+func (e *executor) executeEvent(event *history.Event) error {
 	e.logger.Debug("Executing event",
 		"instance_id", e.workflowState.Instance().InstanceID,
 		"event_id", event.ID,
@@ -302,56 +612,72 @@ func (e *executor) executeEvent(event history.Event) error {
 		"is_replaying", e.workflowState.Replaying(),
 	)
 
+	// Identify the event in case its payload doesn't match the expected type
+	e.workflowState.SetDecodingEvent(event.ID, event.Type.String(), event.SequenceID)
+	defer e.workflowState.SetDecodingEvent("", "", 0)
+
 	var err error
 
 	switch event.Type {
 	case history.EventType_WorkflowExecutionStarted:
-		err = e.handleWorkflowExecutionStarted(event.Attributes.(*history.ExecutionStartedAttributes))
+		err = e.handleWorkflowExecutionStarted(event)
 
 	case history.EventType_WorkflowExecutionFinished:
 	// Ignore
 
 	case history.EventType_WorkflowExecutionCanceled:
-		err = e.handleWorkflowCanceled()
+		err = e.handleWorkflowCanceled(event)
 
 	case history.EventType_WorkflowTaskStarted:
-		err = e.handleWorkflowTaskStarted(event, event.Attributes.(*history.WorkflowTaskStartedAttributes))
+		err = e.handleWorkflowTaskStarted(event)
 
 	case history.EventType_ActivityScheduled:
-		err = e.handleActivityScheduled(event, event.Attributes.(*history.ActivityScheduledAttributes))
+		err = e.handleActivityScheduled(event)
+
+	case history.EventType_ActivityStarted:
+		err = e.handleActivityStarted(event)
 
 	case history.EventType_ActivityFailed:
-		err = e.handleActivityFailed(event, event.Attributes.(*history.ActivityFailedAttributes))
+		err = e.handleActivityFailed(event)
 
 	case history.EventType_ActivityCompleted:
-		err = e.handleActivityCompleted(event, event.Attributes.(*history.ActivityCompletedAttributes))
+		err = e.handleActivityCompleted(event)
 
 	case history.EventType_TimerScheduled:
-		err = e.handleTimerScheduled(event, event.Attributes.(*history.TimerScheduledAttributes))
+		err = e.handleTimerScheduled(event)
 
 	case history.EventType_TimerFired:
-		err = e.handleTimerFired(event, event.Attributes.(*history.TimerFiredAttributes))
+		err = e.handleTimerFired(event)
 
 	case history.EventType_TimerCanceled:
-		err = e.handleTimerCanceled(event, event.Attributes.(*history.TimerCanceledAttributes))
+		err = e.handleTimerCanceled(event)
+
+	case history.EventType_TimerRescheduled:
+		err = e.handleTimerRescheduled(event)
 
 	case history.EventType_SignalReceived:
-		err = e.handleSignalReceived(event, event.Attributes.(*history.SignalReceivedAttributes))
+		err = e.handleSignalReceived(event)
 
 	case history.EventType_SideEffectResult:
-		err = e.handleSideEffectResult(event, event.Attributes.(*history.SideEffectResultAttributes))
+		err = e.handleSideEffectResult(event)
 
 	case history.EventType_SubWorkflowScheduled:
-		err = e.handleSubWorkflowScheduled(event, event.Attributes.(*history.SubWorkflowScheduledAttributes))
+		err = e.handleSubWorkflowScheduled(event)
 	case history.EventType_SubWorkflowCancellationRequested:
-		err = e.handleSubWorkflowCancellationRequest(event, event.Attributes.(*history.SubWorkflowCancellationRequestedAttributes))
+		err = e.handleSubWorkflowCancellationRequest(event)
 	case history.EventType_SubWorkflowFailed:
-		err = e.handleSubWorkflowFailed(event, event.Attributes.(*history.SubWorkflowFailedAttributes))
+		err = e.handleSubWorkflowFailed(event)
 	case history.EventType_SubWorkflowCompleted:
-		err = e.handleSubWorkflowCompleted(event, event.Attributes.(*history.SubWorkflowCompletedAttributes))
+		err = e.handleSubWorkflowCompleted(event)
 
 	case history.EventType_SignalWorkflow:
-		err = e.handleSignalWorkflow(event, event.Attributes.(*history.SignalWorkflowAttributes))
+		err = e.handleSignalWorkflow(event)
+
+	case history.EventType_MarkerRecorded:
+		err = e.handleMarkerRecorded(event)
+
+	case history.EventType_Compacted:
+		err = e.handleCompacted(event)
 
 	default:
 		return fmt.Errorf("unknown event type: %v", event.Type)
@@ -360,43 +686,136 @@ func (e *executor) executeEvent(event history.Event) error {
 	return err
 }
 
-func (e *executor) handleWorkflowExecutionStarted(a *history.ExecutionStartedAttributes) error {
+// checkPinnedVersion returns a TransientError if the given events start an instance that's pinned to a
+// different version of its workflow than the one registered with this worker.
+func (e *executor) checkPinnedVersion(events []history.Event) error {
+	for i := range events {
+		if events[i].Type != history.EventType_WorkflowExecutionStarted {
+			continue
+		}
+
+		a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&events[i])
+		if err != nil || a.Version == "" {
+			// Invalid attributes are surfaced when the event is executed
+			return nil
+		}
+
+		if version := e.registry.WorkflowVersion(a.Name); version != a.Version {
+			return &TransientError{Err: &PinnedVersionError{Workflow: a.Name, Version: a.Version, RegisteredVersion: version}}
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+func (e *executor) handleWorkflowExecutionStarted(event *history.Event) error {
+	a, err := history.AttributesAs[*history.ExecutionStartedAttributes](event)
+	if err != nil {
+		return err
+	}
+
+	e.workflowName = a.Name
+	e.started = a
+	e.workflowState.SetExecutionStarted(a.Name, event.Timestamp, a.Attempt)
+
 	wfFn, err := e.registry.GetWorkflow(a.Name)
 	if err != nil {
 		return fmt.Errorf("workflow %s not found", a.Name)
 	}
 
+	checksum := e.registry.WorkflowChecksum(a.Name)
+	if !e.workflowState.Replaying() {
+		// Record the definition and the random seed on the event before it's added to the history
+		a.DefinitionChecksum = checksum
+
+		if a.RandomSeed == 0 {
+			a.RandomSeed = newRandomSeed()
+		}
+	} else if a.DefinitionChecksum != "" && a.DefinitionChecksum != checksum {
+		e.logger.Warn("Workflow definition changed since the instance was started",
+			"instance_id", e.workflowState.Instance().InstanceID,
+			"workflow", a.Name,
+			"started_checksum", a.DefinitionChecksum,
+			"checksum", checksum,
+		)
+	}
+
+	if !e.workflowState.Replaying() && a.ExecutionTimeout > 0 {
+		timeoutEvent := history.NewPendingEvent(
+			e.clock.Now(),
+			history.EventType_WorkflowExecutionCanceled,
+			&history.ExecutionCanceledAttributes{
+				Reason:  history.CancellationReason_Timeout,
+				Message: fmt.Sprintf("workflow did not complete within %v", a.ExecutionTimeout),
+			},
+			history.VisibleAt(event.Timestamp.Add(a.ExecutionTimeout)),
+		)
+		e.executionTimeoutEvent = &timeoutEvent
+	}
+
+	e.workflowState.SetRandomSeed(a.RandomSeed)
+
 	e.workflow = NewWorkflow(reflect.ValueOf(wfFn))
+	if e.schedulerTracer != nil {
+		e.workflow.SetSchedulerTracer(e.schedulerTracer)
+	}
 
 	return e.workflow.Execute(e.workflowCtx, a.Inputs)
 }
 
-func (e *executor) handleWorkflowCanceled() error {
-	e.workflowCtxCancel()
+func (e *executor) handleWorkflowCanceled(event *history.Event) error {
+	a, err := history.AttributesAs[*history.ExecutionCanceledAttributes](event)
+	if err != nil {
+		return err
+	}
+
+	cause := cancellationCause(a.Reason)
+
+	// Like the cause of the workflow context, the first cancellation wins
+	if !e.workflowState.CancellationRequested() {
+		e.workflowState.SetCancellation(&workflowstate.Cancellation{
+			Cause:   cause,
+			Message: a.Message,
+			Details: a.Details,
+		})
+	}
+
+	e.workflowCtxCancel(cause)
 
 	return e.workflow.Continue()
 }
 
-func (e *executor) handleWorkflowTaskStarted(event history.Event, a *history.WorkflowTaskStartedAttributes) error {
+func (e *executor) handleWorkflowTaskStarted(event *history.Event) error {
 	e.workflowState.SetTime(event.Timestamp)
 
 	return nil
 }
 
-func (e *executor) handleActivityScheduled(event history.Event, a *history.ActivityScheduledAttributes) error {
+func (e *executor) handleActivityScheduled(event *history.Event) error {
+	a, err := history.AttributesAs[*history.ActivityScheduledAttributes](event)
+	if err != nil {
+		return err
+	}
+
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
 	if c == nil {
-		return fmt.Errorf("previous workflow execution scheduled an activity which could not be found")
+		return nonDeterminismError("previous workflow execution scheduled an activity which could not be found")
 	}
 
 	sac, ok := c.(*command.ScheduleActivityCommand)
 	if !ok {
-		return fmt.Errorf("previous workflow execution scheduled an activity, not: %v", c.Type())
+		return nonDeterminismError("previous workflow execution scheduled an activity, not: %v", c.Type())
 	}
 
 	// Ensure the same activity was scheduled again
 	if a.Name != sac.Name {
-		return fmt.Errorf("previous workflow execution scheduled different type of activity: %s, %s", a.Name, sac.Name)
+		return nonDeterminismError("previous workflow execution scheduled different type of activity: %s, %s", a.Name, sac.Name)
+	}
+
+	if e.strictReplay && !payloadsEqual(a.Inputs, sac.Inputs) {
+		return nonDeterminismError("previous workflow execution scheduled activity %s with different inputs", a.Name)
 	}
 
 	c.Commit()
@@ -404,14 +823,79 @@ func (e *executor) handleActivityScheduled(event history.Event, a *history.Activ
 	return nil
 }
 
-func (e *executor) handleActivityCompleted(event history.Event, a *history.ActivityCompletedAttributes) error {
+func (e *executor) handleActivityStarted(event *history.Event) error {
+	sac, ok := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID).(*command.ScheduleActivityCommand)
+	if !ok || sac.State() == command.CommandState_Done {
+		// The activity has already been resolved, e.g., because it timed out
+		return nil
+	}
+
+	sac.Started = true
+
+	// Fail the activity if it doesn't complete in time, even if the worker executing it goes away
+	if !e.workflowState.Replaying() && sac.StartToCloseTimeout > 0 {
+		e.activityTimeoutEvents = append(e.activityTimeoutEvents, history.NewPendingEvent(
+			e.clock.Now(),
+			history.EventType_ActivityFailed,
+			&history.ActivityFailedAttributes{
+				Reason:  fmt.Sprintf("activity %s did not complete within %v", sac.Name, sac.StartToCloseTimeout),
+				Type:    workflowerrors.TimeoutErrorType,
+				Timeout: history.ActivityTimeout_StartToClose,
+			},
+			history.ScheduleEventID(event.ScheduleEventID),
+			history.VisibleAt(event.Timestamp.Add(sac.StartToCloseTimeout)),
+		))
+	}
+
+	// Fail the activity if it doesn't record a heartbeat in time. The worker moves the deadline with every heartbeat,
+	// so the timeout only fires if the activity is stuck or the worker executing it goes away.
+	if !e.workflowState.Replaying() && sac.HeartbeatTimeout > 0 && event.CausedBy != "" {
+		a, err := history.AttributesAs[*history.ActivityStartedAttributes](event)
+		if err != nil {
+			return err
+		}
+
+		if !a.ReschedulesHeartbeatTimeout {
+			return nil
+		}
+
+		e.activityTimeoutEvents = append(e.activityTimeoutEvents, history.NewActivityHeartbeatTimeoutEvent(
+			e.clock.Now(),
+			event.Timestamp.Add(sac.HeartbeatTimeout),
+			event.CausedBy,
+			event.ScheduleEventID,
+			sac.Name,
+			sac.HeartbeatTimeout,
+			sac.HeartbeatDetails,
+		))
+	}
+
+	return nil
+}
+
+// activityResolved returns true if the activity with the given schedule event ID has already been resolved
+func (e *executor) activityResolved(scheduleEventID int64) bool {
+	sac, ok := e.workflowState.CommandByScheduleEventID(scheduleEventID).(*command.ScheduleActivityCommand)
+	return ok && sac.State() == command.CommandState_Done
+}
+
+func (e *executor) handleActivityCompleted(event *history.Event) error {
+	a, err := history.AttributesAs[*history.ActivityCompletedAttributes](event)
+	if err != nil {
+		return err
+	}
+
 	f, ok := e.workflowState.FutureByScheduleEventID(event.ScheduleEventID)
 	if !ok {
+		if e.activityResolved(event.ScheduleEventID) {
+			// The activity timed out before the worker reported its result
+			return nil
+		}
+
 		return fmt.Errorf("could not find pending future for activity completion")
 	}
 
-	err := f(a.Result, nil)
-	if err != nil {
+	if err := f(a.Result, nil); err != nil {
 		return fmt.Errorf("setting activity completed result: %w", err)
 	}
 
@@ -419,12 +903,12 @@ func (e *executor) handleActivityCompleted(event history.Event, a *history.Activ
 
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
 	if c == nil {
-		return fmt.Errorf("previous workflow execution scheduled an activity which could not be found")
+		return nonDeterminismError("previous workflow execution scheduled an activity which could not be found")
 	}
 
 	sac, ok := c.(*command.ScheduleActivityCommand)
 	if !ok {
-		return fmt.Errorf("previous workflow execution scheduled an activity, not: %v", c.Type())
+		return nonDeterminismError("previous workflow execution scheduled an activity, not: %v", c.Type())
 	}
 
 	sac.Done()
@@ -432,41 +916,62 @@ func (e *executor) handleActivityCompleted(event history.Event, a *history.Activ
 	return e.workflow.Continue()
 }
 
-func (e *executor) handleActivityFailed(event history.Event, a *history.ActivityFailedAttributes) error {
+func (e *executor) handleActivityFailed(event *history.Event) error {
+	a, err := history.AttributesAs[*history.ActivityFailedAttributes](event)
+	if err != nil {
+		return err
+	}
+
 	f, ok := e.workflowState.FutureByScheduleEventID(event.ScheduleEventID)
 	if !ok {
-		return errors.New("no pending future for activity failed event")
-	}
+		if e.activityResolved(event.ScheduleEventID) {
+			// The activity timed out before the worker reported its failure, or it was resolved before its timeout
+			return nil
+		}
 
-	if err := f(nil, errors.New(a.Reason)); err != nil {
-		return fmt.Errorf("setting activity failed result: %w", err)
+		return errors.New("no pending future for activity failed event")
 	}
 
-	e.workflowState.RemoveFuture(event.ScheduleEventID)
-
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
 	if c == nil {
-		return fmt.Errorf("previous workflow execution scheduled an activity which could not be found")
+		return nonDeterminismError("previous workflow execution scheduled an activity which could not be found")
 	}
 
 	sac, ok := c.(*command.ScheduleActivityCommand)
 	if !ok {
-		return fmt.Errorf("previous workflow execution scheduled an activity, not: %v", c.Type())
+		return nonDeterminismError("previous workflow execution scheduled an activity, not: %v", c.Type())
 	}
 
+	if a.Timeout == history.ActivityTimeout_ScheduleToStart && sac.Started {
+		// A worker started the activity in time
+		return nil
+	}
+
+	if err := f(nil, &workflowerrors.ActivityError{
+		Activity:         sac.Name,
+		ScheduleEventID:  event.ScheduleEventID,
+		Type:             a.Type,
+		Message:          a.Reason,
+		HeartbeatDetails: a.HeartbeatDetails,
+	}); err != nil {
+		return fmt.Errorf("setting activity failed result: %w", err)
+	}
+
+	e.workflowState.RemoveFuture(event.ScheduleEventID)
+
 	sac.Done()
 
 	return e.workflow.Continue()
 }
 
-func (e *executor) handleTimerScheduled(event history.Event, a *history.TimerScheduledAttributes) error {
+func (e *executor) handleTimerScheduled(event *history.Event) error {
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
 	if c == nil {
-		return fmt.Errorf("previous workflow execution scheduled a timer")
+		return nonDeterminismError("previous workflow execution scheduled a timer")
 	}
 
 	if _, ok := c.(*command.ScheduleTimerCommand); !ok {
-		return fmt.Errorf("previous workflow execution scheduled a timer, not: %v", c.Type())
+		return nonDeterminismError("previous workflow execution scheduled a timer, not: %v", c.Type())
 	}
 
 	c.Commit()
@@ -474,7 +979,21 @@ func (e *executor) handleTimerScheduled(event history.Event, a *history.TimerSch
 	return nil
 }
 
-func (e *executor) handleTimerFired(event history.Event, a *history.TimerFiredAttributes) error {
+func (e *executor) handleTimerFired(event *history.Event) error {
+	if stc, ok := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID).(*command.ScheduleTimerCommand); ok {
+		if stc.Rescheduled() {
+			a, err := history.AttributesAs[*history.TimerFiredAttributes](event)
+			if err != nil {
+				return err
+			}
+
+			if !stc.At().Equal(a.At) {
+				// Timer has been rescheduled, ignore the event for the previous time
+				return nil
+			}
+		}
+	}
+
 	f, ok := e.workflowState.FutureByScheduleEventID(event.ScheduleEventID)
 	if !ok {
 		// Timer already canceled ignore
@@ -501,15 +1020,15 @@ func (e *executor) handleTimerFired(event history.Event, a *history.TimerFiredAt
 	return e.workflow.Continue()
 }
 
-func (e *executor) handleTimerCanceled(event history.Event, a *history.TimerCanceledAttributes) error {
+func (e *executor) handleTimerCanceled(event *history.Event) error {
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
 	if c == nil {
-		return fmt.Errorf("previous workflow execution canceled a timer")
+		return nonDeterminismError("previous workflow execution canceled a timer")
 	}
 
 	stc, ok := c.(*command.ScheduleTimerCommand)
 	if !ok {
-		return fmt.Errorf("previous workflow execution canceled a timer, not: %v", c.Type())
+		return nonDeterminismError("previous workflow execution canceled a timer, not: %v", c.Type())
 	}
 
 	stc.HandleCancel()
@@ -530,19 +1049,49 @@ func (e *executor) handleTimerCanceled(event history.Event, a *history.TimerCanc
 	return e.workflow.Continue()
 }
 
-func (e *executor) handleSubWorkflowScheduled(event history.Event, a *history.SubWorkflowScheduledAttributes) error {
+func (e *executor) handleTimerRescheduled(event *history.Event) error {
+	a, err := history.AttributesAs[*history.TimerRescheduledAttributes](event)
+	if err != nil {
+		return err
+	}
+
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
 	if c == nil {
-		return fmt.Errorf("previous workflow execution scheduled a sub workflow")
+		return nonDeterminismError("previous workflow execution rescheduled a timer")
+	}
+
+	stc, ok := c.(*command.ScheduleTimerCommand)
+	if !ok {
+		return nonDeterminismError("previous workflow execution rescheduled a timer, not: %v", c.Type())
+	}
+
+	stc.HandleReschedule(a.At)
+
+	return nil
+}
+
+func (e *executor) handleSubWorkflowScheduled(event *history.Event) error {
+	a, err := history.AttributesAs[*history.SubWorkflowScheduledAttributes](event)
+	if err != nil {
+		return err
+	}
+
+	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
+	if c == nil {
+		return nonDeterminismError("previous workflow execution scheduled a sub workflow")
 	}
 
 	sswc, ok := c.(*command.ScheduleSubWorkflowCommand)
 	if !ok {
-		return fmt.Errorf("previous workflow execution scheduled a sub workflow, not: %v", c.Type())
+		return nonDeterminismError("previous workflow execution scheduled a sub workflow, not: %v", c.Type())
 	}
 
 	if a.Name != sswc.Name {
-		return fmt.Errorf("previous workflow execution scheduled different type of sub workflow: %s, %s", a.Name, sswc.Name)
+		return nonDeterminismError("previous workflow execution scheduled different type of sub workflow: %s, %s", a.Name, sswc.Name)
+	}
+
+	if e.strictReplay && !payloadsEqual(a.Inputs, sswc.Inputs) {
+		return nonDeterminismError("previous workflow execution scheduled sub workflow %s with different inputs", a.Name)
 	}
 
 	// If we are replaying this event, the command will have generated a new instance ID. Ensure we use the same one as
@@ -554,15 +1103,15 @@ func (e *executor) handleSubWorkflowScheduled(event history.Event, a *history.Su
 	return nil
 }
 
-func (e *executor) handleSubWorkflowCancellationRequest(event history.Event, a *history.SubWorkflowCancellationRequestedAttributes) error {
+func (e *executor) handleSubWorkflowCancellationRequest(event *history.Event) error {
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
 	if c == nil {
-		return fmt.Errorf("previous workflow execution cancelled a sub-workflow execution")
+		return nonDeterminismError("previous workflow execution cancelled a sub-workflow execution")
 	}
 
 	sswc, ok := c.(*command.ScheduleSubWorkflowCommand)
 	if !ok {
-		return fmt.Errorf("previous workflow execution cancelled a sub-workflow execution, not: %v", c.Type())
+		return nonDeterminismError("previous workflow execution cancelled a sub-workflow execution, not: %v", c.Type())
 	}
 
 	sswc.HandleCancel()
@@ -570,35 +1119,51 @@ func (e *executor) handleSubWorkflowCancellationRequest(event history.Event, a *
 	return e.workflow.Continue()
 }
 
-func (e *executor) handleSubWorkflowFailed(event history.Event, a *history.SubWorkflowFailedAttributes) error {
+func (e *executor) handleSubWorkflowFailed(event *history.Event) error {
+	a, err := history.AttributesAs[*history.SubWorkflowFailedAttributes](event)
+	if err != nil {
+		return err
+	}
+
 	f, ok := e.workflowState.FutureByScheduleEventID(event.ScheduleEventID)
 	if !ok {
 		return errors.New("no pending future found for sub workflow failed event")
 	}
 
-	if err := f(nil, errors.New(a.Error)); err != nil {
-		return fmt.Errorf("setting sub workflow failed result: %w", err)
-	}
-
-	e.workflowState.RemoveFuture(event.ScheduleEventID)
-
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
 	if c == nil {
 		// TODO: Adjust
-		return fmt.Errorf("previous workflow execution scheduled a sub-workflow execution")
+		return nonDeterminismError("previous workflow execution scheduled a sub-workflow execution")
 	}
 
-	if _, ok := c.(*command.ScheduleSubWorkflowCommand); !ok {
+	sswc, ok := c.(*command.ScheduleSubWorkflowCommand)
+	if !ok {
 		// TODO: Adjust
-		return fmt.Errorf("previous workflow execution cancelled a sub-workflow execution, not: %v", c.Type())
+		return nonDeterminismError("previous workflow execution cancelled a sub-workflow execution, not: %v", c.Type())
+	}
+
+	if err := f(nil, &workflowerrors.ChildWorkflowError{
+		Workflow:   sswc.Name,
+		InstanceID: sswc.Instance.InstanceID,
+		Type:       a.ErrorType,
+		Message:    a.Error,
+	}); err != nil {
+		return fmt.Errorf("setting sub workflow failed result: %w", err)
 	}
 
+	e.workflowState.RemoveFuture(event.ScheduleEventID)
+
 	c.Done()
 
 	return e.workflow.Continue()
 }
 
-func (e *executor) handleSubWorkflowCompleted(event history.Event, a *history.SubWorkflowCompletedAttributes) error {
+func (e *executor) handleSubWorkflowCompleted(event *history.Event) error {
+	a, err := history.AttributesAs[*history.SubWorkflowCompletedAttributes](event)
+	if err != nil {
+		return err
+	}
+
 	f, ok := e.workflowState.FutureByScheduleEventID(event.ScheduleEventID)
 	if !ok {
 		return errors.New("no pending future found for sub workflow completed event")
@@ -613,12 +1178,12 @@ func (e *executor) handleSubWorkflowCompleted(event history.Event, a *history.Su
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
 	if c == nil {
 		// TODO: Adjust
-		return fmt.Errorf("previous workflow execution cancelled a sub-workflow execution")
+		return nonDeterminismError("previous workflow execution cancelled a sub-workflow execution")
 	}
 
 	if _, ok := c.(*command.ScheduleSubWorkflowCommand); !ok {
 		// TODO: Adjust
-		return fmt.Errorf("previous workflow execution cancelled a sub-workflow execution, not: %v", c.Type())
+		return nonDeterminismError("previous workflow execution cancelled a sub-workflow execution, not: %v", c.Type())
 	}
 
 	c.Done()
@@ -626,22 +1191,27 @@ func (e *executor) handleSubWorkflowCompleted(event history.Event, a *history.Su
 	return e.workflow.Continue()
 }
 
-func (e *executor) handleSignalReceived(event history.Event, a *history.SignalReceivedAttributes) error {
+func (e *executor) handleSignalReceived(event *history.Event) error {
+	a, err := history.AttributesAs[*history.SignalReceivedAttributes](event)
+	if err != nil {
+		return err
+	}
+
 	// Send signal to workflow channel
 	workflowstate.ReceiveSignal(e.workflowState, a.Name, a.Arg)
 
 	return e.workflow.Continue()
 }
 
-func (e *executor) handleSignalWorkflow(event history.Event, a *history.SignalWorkflowAttributes) error {
+func (e *executor) handleSignalWorkflow(event *history.Event) error {
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
 	if c == nil {
-		return fmt.Errorf("previous workflow execution requested a signal")
+		return nonDeterminismError("previous workflow execution requested a signal")
 	}
 
 	sewc, ok := c.(*command.SignalWorkflowCommand)
 	if !ok {
-		return fmt.Errorf("previous workflow execution requested to signal a workflow, not: %v", c.Type())
+		return nonDeterminismError("previous workflow execution requested to signal a workflow, not: %v", c.Type())
 	}
 
 	sewc.Done()
@@ -649,15 +1219,49 @@ func (e *executor) handleSignalWorkflow(event history.Event, a *history.SignalWo
 	return e.workflow.Continue()
 }
 
-func (e *executor) handleSideEffectResult(event history.Event, a *history.SideEffectResultAttributes) error {
+func (e *executor) handleMarkerRecorded(event *history.Event) error {
+	a, err := history.AttributesAs[*history.MarkerRecordedAttributes](event)
+	if err != nil {
+		return err
+	}
+
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
 	if c == nil {
-		return fmt.Errorf("previous workflow execution scheduled a side effect")
+		return nonDeterminismError("previous workflow execution recorded a marker")
+	}
+
+	rmc, ok := c.(*command.RecordMarkerCommand)
+	if !ok {
+		return nonDeterminismError("previous workflow execution recorded a marker, not: %v", c.Type())
+	}
+
+	if rmc.Name != a.Name {
+		return nonDeterminismError("previous workflow execution recorded marker %q, not %q", a.Name, rmc.Name)
+	}
+
+	if e.strictReplay && rmc.Validate && !bytes.Equal(a.Details, rmc.Details) {
+		return nonDeterminismError("previous workflow execution recorded marker %q with different details", a.Name)
+	}
+
+	rmc.Done()
+
+	return e.workflow.Continue()
+}
+
+func (e *executor) handleSideEffectResult(event *history.Event) error {
+	a, err := history.AttributesAs[*history.SideEffectResultAttributes](event)
+	if err != nil {
+		return err
+	}
+
+	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
+	if c == nil {
+		return nonDeterminismError("previous workflow execution scheduled a side effect")
 	}
 
 	sec, ok := c.(*command.SideEffectCommand)
 	if !ok {
-		return fmt.Errorf("previous workflow execution scheduled a side effect, not: %v", c.Type())
+		return nonDeterminismError("previous workflow execution scheduled a side effect, not: %v", c.Type())
 	}
 
 	sec.Done()
@@ -676,9 +1280,79 @@ func (e *executor) handleSideEffectResult(event history.Event, a *history.SideEf
 	return e.workflow.Continue()
 }
 
+// handleCompacted replays a request and its response that have been compacted into a single event.
+func (e *executor) handleCompacted(event *history.Event) error {
+	a, err := history.AttributesAs[*history.CompactedAttributes](event)
+	if err != nil {
+		return err
+	}
+
+	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
+	if c == nil {
+		return nonDeterminismError("previous workflow execution scheduled a compacted %v", a.Type)
+	}
+
+	switch a.Type {
+	case history.EventType_ActivityScheduled:
+		sac, ok := c.(*command.ScheduleActivityCommand)
+		if !ok {
+			return nonDeterminismError("previous workflow execution scheduled an activity, not: %v", c.Type())
+		}
+
+		if a.Name != sac.Name {
+			return nonDeterminismError("previous workflow execution scheduled different type of activity: %s, %s", a.Name, sac.Name)
+		}
+
+	case history.EventType_TimerScheduled:
+		if _, ok := c.(*command.ScheduleTimerCommand); !ok {
+			return nonDeterminismError("previous workflow execution scheduled a timer, not: %v", c.Type())
+		}
+
+	default:
+		return fmt.Errorf("unknown compacted event type: %v", a.Type)
+	}
+
+	c.Commit()
+
+	f, ok := e.workflowState.FutureByScheduleEventID(event.ScheduleEventID)
+	if !ok {
+		return errors.New("no pending future found for compacted event")
+	}
+
+	var ferr error
+	if a.Failed {
+		ferr = &workflowerrors.ActivityError{
+			Activity:         a.Name,
+			ScheduleEventID:  event.ScheduleEventID,
+			Type:             a.ErrorType,
+			Message:          a.Error,
+			HeartbeatDetails: a.HeartbeatDetails,
+		}
+	}
+
+	if err := f(a.Result, ferr); err != nil {
+		return fmt.Errorf("setting compacted result: %w", err)
+	}
+
+	e.workflowState.RemoveFuture(event.ScheduleEventID)
+
+	c.Done()
+
+	return e.workflow.Continue()
+}
+
 func (e *executor) workflowCompleted(result payload.Payload, err error) {
 	eventId := e.workflowState.GetNextScheduleEventID()
 
+	var canErr *workflowstate.ContinueAsNewError
+	if errors.As(err, &canErr) && e.started != nil {
+		cmd := command.NewContinueAsNewCommand(
+			eventId, e.workflowState.Instance(), e.started.Name, canErr.Inputs, e.started.Metadata, e.started.Attempt, e.started.Version,
+			e.started.ExecutionTimeout)
+		e.workflowState.AddCommand(cmd)
+		return
+	}
+
 	cmd := command.NewCompleteWorkflowCommand(eventId, e.workflowState.Instance(), result, err)
 	e.workflowState.AddCommand(cmd)
 }
@@ -696,3 +1370,44 @@ func (e *executor) createNewEvent(eventType history.EventType, attributes interf
 		opts...,
 	)
 }
+
+// newRandomSeed returns a non-zero seed for the random number generator of a new execution.
+func newRandomSeed() int64 {
+	var b [8]byte
+	for {
+		if _, err := crand.Read(b[:]); err != nil {
+			panic(fmt.Errorf("generating random seed: %w", err))
+		}
+
+		if seed := int64(binary.LittleEndian.Uint64(b[:])); seed != 0 {
+			return seed
+		}
+	}
+}
+
+func cancellationCause(reason history.CancellationReason) error {
+	switch reason {
+	case history.CancellationReason_Parent:
+		return core.ErrCanceledByParent
+	case history.CancellationReason_Timeout:
+		return core.ErrTimedOut
+	case history.CancellationReason_Terminate:
+		return core.ErrTerminated
+	default:
+		return core.ErrCanceledByUser
+	}
+}
+
+func payloadsEqual(a, b []payload.Payload) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -2,6 +2,7 @@ package workflow
 
 import (
 	"context"
+	"errors"
 	"log"
 	"testing"
 	"time"
@@ -14,10 +15,14 @@ import (
 	"github.com/cschleiden/go-workflows/internal/fn"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/logger"
+	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/internal/metrics"
 	"github.com/cschleiden/go-workflows/internal/payload"
 	"github.com/cschleiden/go-workflows/internal/sync"
 	"github.com/cschleiden/go-workflows/internal/task"
+	wfmetrics "github.com/cschleiden/go-workflows/metrics"
 	wf "github.com/cschleiden/go-workflows/workflow"
+	"github.com/cschleiden/go-workflows/workflowerrors"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel/trace"
@@ -31,11 +36,19 @@ func (t *testHistoryProvider) GetWorkflowInstanceHistory(ctx context.Context, in
 	return t.history, nil
 }
 
+type failingHistoryProvider struct {
+	err error
+}
+
+func (f *failingHistoryProvider) GetWorkflowInstanceHistory(ctx context.Context, instance *core.WorkflowInstance, lastSequenceID *int64) ([]history.Event, error) {
+	return nil, f.err
+}
+
 func newExecutor(r *Registry, i *core.WorkflowInstance, historyProvider WorkflowHistoryProvider) *executor {
 	logger := logger.NewDefaultLogger()
 	tracer := trace.NewNoopTracerProvider().Tracer("test")
 
-	e, err := NewExecutor(logger, tracer, r, historyProvider, i, clock.New())
+	e, err := NewExecutor(logger, tracer, metrics.NewNoopMetricsClient(), r, historyProvider, i, clock.New(), nil)
 	if err != nil {
 		panic(err)
 	}
@@ -75,6 +88,185 @@ func Test_Executor(t *testing.T) {
 				require.IsType(t, &command.CompleteWorkflowCommand{}, e.workflowState.Commands()[0])
 			},
 		},
+		{
+			name: "Task with older history than the executor",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				wf := func(ctx sync.Context) error {
+					return nil
+				}
+
+				r.RegisterWorkflow(wf)
+
+				task := startWorkflowTask(i.InstanceID, wf)
+
+				_, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+
+				// Executing the task again, e.g., because its completion was never committed, requires a new executor
+				_, err = e.ExecuteTask(context.Background(), task)
+				require.ErrorIs(t, err, ErrExecutorAhead)
+			},
+		},
+		{
+			name: "Records definition checksum on start",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				wf := func(ctx sync.Context) error {
+					return nil
+				}
+
+				r.RegisterWorkflow(wf)
+
+				task := startWorkflowTask(i.InstanceID, wf)
+
+				result, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+
+				// The first executed event is WorkflowTaskStarted
+				a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&result.Executed[1])
+				require.NoError(t, err)
+				require.Equal(t, r.WorkflowChecksum(fn.Name(wf)), a.DefinitionChecksum)
+			},
+		},
+		{
+			name: "Records definition checksum on serialized start event",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				wf := func(ctx sync.Context) error {
+					return nil
+				}
+
+				r.RegisterWorkflow(wf)
+
+				task := startWorkflowTask(i.InstanceID, wf)
+
+				// Events loaded from a backend only carry serialized attributes
+				raw, err := task.NewEvents[0].SerializedAttributes()
+				require.NoError(t, err)
+				task.NewEvents[0].SetSerializedAttributes(raw)
+
+				result, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+
+				a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&result.Executed[1])
+				require.NoError(t, err)
+				require.Equal(t, r.WorkflowChecksum(fn.Name(wf)), a.DefinitionChecksum)
+			},
+		},
+		{
+			name: "Returns transient error when history cannot be fetched",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				e.historyProvider = &failingHistoryProvider{err: errors.New("connection reset")}
+
+				task := continueTask(i.InstanceID, []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{Name: "signal"}),
+				}, 5)
+
+				_, err := e.ExecuteTask(context.Background(), task)
+				require.Error(t, err)
+				require.True(t, IsTransientError(err))
+				require.Equal(t, int64(0), e.lastSequenceID)
+			},
+		},
+		{
+			name: "Leaves instances pinned to other versions to other workers",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflowHits := 0
+				wf := func(ctx sync.Context) error {
+					workflowHits++
+					return nil
+				}
+
+				r.RegisterWorkflow(wf, WithVersion("2"))
+
+				task := startWorkflowTask(i.InstanceID, wf)
+				a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&task.NewEvents[0])
+				require.NoError(t, err)
+				a.Version = "1"
+
+				_, err = e.ExecuteTask(context.Background(), task)
+				require.True(t, IsTransientError(err))
+
+				var perr *PinnedVersionError
+				require.ErrorAs(t, err, &perr)
+				require.Equal(t, "1", perr.Version)
+				require.Equal(t, "2", perr.RegisteredVersion)
+				require.Equal(t, 0, workflowHits)
+
+				a.Version = "2"
+
+				_, err = e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.Equal(t, 1, workflowHits)
+			},
+		},
+		{
+			name: "Reports workflow code running without yielding",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				mc := &counterMetricsClient{counters: map[string]int64{}}
+				e.metrics = mc
+				e.computeWarningThreshold = 10 * time.Millisecond
+
+				wf := func(ctx sync.Context) error {
+					time.Sleep(20 * time.Millisecond)
+
+					return nil
+				}
+
+				r.RegisterWorkflow(wf)
+
+				_, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, wf))
+				require.NoError(t, err)
+				require.Equal(t, int64(1), mc.counters[metrickeys.WorkflowComputeSlow])
+			},
+		},
+		{
+			name: "Reports scheduler stats",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflowWithGoroutines := func(ctx sync.Context) error {
+					for i := 0; i < 3; i++ {
+						sync.Go(ctx, func(ctx sync.Context) {
+							wf.NewSignalChannel[int](ctx, "signal").Receive(ctx)
+						})
+					}
+
+					wf.NewSignalChannel[int](ctx, "done").Receive(ctx)
+
+					return nil
+				}
+
+				r.RegisterWorkflow(workflowWithGoroutines)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, workflowWithGoroutines))
+				require.NoError(t, err)
+				require.NotNil(t, result.SchedulerStats)
+				require.Equal(t, 4, result.SchedulerStats.Started)
+				require.Equal(t, 4, result.SchedulerStats.Coroutines)
+				require.Equal(t, 4, result.SchedulerStats.Blocked[sync.BlockReasonChannelReceive])
+			},
+		},
+		{
+			name: "Yielding resets workflow compute time",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				mc := &counterMetricsClient{counters: map[string]int64{}}
+				e.metrics = mc
+				e.computeWarningThreshold = 50 * time.Millisecond
+
+				wf := func(ctx sync.Context) error {
+					for i := 0; i < 3; i++ {
+						time.Sleep(20 * time.Millisecond)
+						sync.Yield(ctx)
+					}
+
+					return nil
+				}
+
+				r.RegisterWorkflow(wf)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, wf))
+				require.NoError(t, err)
+				require.True(t, result.Completed)
+				require.Zero(t, mc.counters[metrickeys.WorkflowComputeSlow])
+			},
+		},
 		{
 			name: "Workflow with activity command",
 			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
@@ -121,6 +313,83 @@ func Test_Executor(t *testing.T) {
 				require.Equal(t, []payload.Payload{inputs}, e.workflowState.Commands()[0].(*command.ScheduleActivityCommand).Inputs)
 			},
 		},
+		{
+			name: "Generated events are linked to WorkflowTaskStarted",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflowWithActivity := func(ctx sync.Context) error {
+					_, err := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+					return err
+				}
+
+				r.RegisterWorkflow(workflowWithActivity)
+				r.RegisterActivity(activity1)
+
+				task := &task.Workflow{
+					ID:               "taskID",
+					WorkflowInstance: core.NewWorkflowInstance("instanceID", "executionID"),
+					Metadata:         &core.WorkflowMetadata{},
+					NewEvents: []history.Event{
+						history.NewHistoryEvent(
+							1,
+							time.Now(),
+							history.EventType_WorkflowExecutionStarted,
+							&history.ExecutionStartedAttributes{
+								Name:   fn.Name(workflowWithActivity),
+								Inputs: []payload.Payload{},
+							},
+						),
+					},
+				}
+
+				result, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.Len(t, result.Executed, 3)
+				require.Equal(t, history.EventType_WorkflowTaskStarted, result.Executed[0].Type)
+				require.Empty(t, result.Executed[0].CausedBy)
+				require.Equal(t, history.EventType_ActivityScheduled, result.Executed[2].Type)
+				require.Equal(t, result.Executed[0].ID, result.Executed[2].CausedBy)
+				require.Len(t, result.ActivityEvents, 1)
+				require.Equal(t, result.Executed[0].ID, result.ActivityEvents[0].CausedBy)
+			},
+		},
+		{
+			name: "Memory usage accounts for history and pending futures",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflowWithActivity := func(ctx sync.Context) error {
+					_, err := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+					return err
+				}
+
+				r.RegisterWorkflow(workflowWithActivity)
+				r.RegisterActivity(activity1)
+
+				require.Equal(t, int64(0), e.MemoryUsage())
+
+				task := &task.Workflow{
+					ID:               "taskID",
+					WorkflowInstance: core.NewWorkflowInstance("instanceID", "executionID"),
+					Metadata:         &core.WorkflowMetadata{},
+					NewEvents: []history.Event{
+						history.NewHistoryEvent(
+							1,
+							time.Now(),
+							history.EventType_WorkflowExecutionStarted,
+							&history.ExecutionStartedAttributes{
+								Name:   fn.Name(workflowWithActivity),
+								Inputs: []payload.Payload{},
+							},
+						),
+					},
+				}
+
+				result, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.Len(t, result.Executed, 3)
+
+				// Three events, the activity input `42`, and the pending activity future
+				require.Equal(t, int64(3*eventMemoryOverhead+2+futureMemoryOverhead), e.MemoryUsage())
+			},
+		},
 		{
 			name: "Workflow with activity replay",
 			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
@@ -137,273 +406,1332 @@ func Test_Executor(t *testing.T) {
 				r.RegisterWorkflow(workflowWithActivity)
 				r.RegisterActivity(activity1)
 
-				inputs, _ := converter.DefaultConverter.To(42)
-				result, _ := converter.DefaultConverter.To(42)
-
-				task := &task.Workflow{
-					ID:               "taskID",
-					WorkflowInstance: core.NewWorkflowInstance("instanceID", "executionID"),
-					Metadata:         &core.WorkflowMetadata{},
-					LastSequenceID:   3,
-				}
+				inputs, _ := converter.DefaultConverter.To(42)
+				result, _ := converter.DefaultConverter.To(42)
+
+				task := &task.Workflow{
+					ID:               "taskID",
+					WorkflowInstance: core.NewWorkflowInstance("instanceID", "executionID"),
+					Metadata:         &core.WorkflowMetadata{},
+					LastSequenceID:   3,
+				}
+
+				hp.history = []history.Event{
+					history.NewHistoryEvent(
+						1,
+						time.Now(),
+						history.EventType_WorkflowExecutionStarted,
+						&history.ExecutionStartedAttributes{
+							Name:   fn.Name(workflowWithActivity),
+							Inputs: []payload.Payload{},
+						},
+					),
+					history.NewHistoryEvent(
+						2,
+						time.Now(),
+						history.EventType_ActivityScheduled,
+						&history.ActivityScheduledAttributes{
+							Name:   "activity1",
+							Inputs: []payload.Payload{inputs},
+						},
+						history.ScheduleEventID(1),
+					),
+					history.NewHistoryEvent(
+						3,
+						time.Now(),
+						history.EventType_ActivityCompleted,
+						&history.ActivityCompletedAttributes{
+							Result: result,
+						},
+						history.ScheduleEventID(1),
+					),
+				}
+
+				_, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				require.Equal(t, 2, workflowActivityHit)
+				require.True(t, e.workflow.Completed())
+				require.Len(t, e.workflowState.Commands(), 2)
+			},
+		},
+		{
+			name: "Strict replay detects changed activity inputs",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflowWithActivity := func(ctx sync.Context) error {
+					_, err := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+					return err
+				}
+
+				r.RegisterWorkflow(workflowWithActivity)
+				r.RegisterActivity(activity1)
+
+				inputs, _ := converter.DefaultConverter.To(23)
+
+				hp.history = []history.Event{
+					history.NewHistoryEvent(
+						1,
+						time.Now(),
+						history.EventType_WorkflowExecutionStarted,
+						&history.ExecutionStartedAttributes{
+							Name:   fn.Name(workflowWithActivity),
+							Inputs: []payload.Payload{},
+						},
+					),
+					history.NewHistoryEvent(
+						2,
+						time.Now(),
+						history.EventType_ActivityScheduled,
+						&history.ActivityScheduledAttributes{
+							Name:   "activity1",
+							Inputs: []payload.Payload{inputs},
+						},
+						history.ScheduleEventID(1),
+					),
+				}
+
+				task := &task.Workflow{
+					ID:               "taskID",
+					WorkflowInstance: core.NewWorkflowInstance("instanceID", "executionID"),
+					Metadata:         &core.WorkflowMetadata{},
+					LastSequenceID:   2,
+				}
+
+				// Only names are compared by default
+				_, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+
+				e = newExecutor(r, i, hp)
+				WithStrictReplay(true)(e)
+
+				// Replay errors fail the workflow
+				result, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.True(t, result.Completed)
+
+				finished := result.Executed[len(result.Executed)-1]
+				require.Equal(t, history.EventType_WorkflowExecutionFinished, finished.Type)
+				a, err := history.AttributesAs[*history.ExecutionCompletedAttributes](&finished)
+				require.NoError(t, err)
+				require.Contains(t, a.Error, "previous workflow execution scheduled activity activity1 with different inputs")
+			},
+		},
+		{
+			name: "Records hashes and validates them with strict replay",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				var hash uint64
+				workflowWithHash := func(ctx sync.Context) error {
+					var err error
+					hash, err = wf.Hash(ctx, "key")
+					return err
+				}
+
+				r.RegisterWorkflow(workflowWithHash)
+
+				// Hashes are stable, and recorded regardless of strict replay
+				var result *ExecutionResult
+				for _, strict := range []bool{false, true} {
+					e = newExecutor(r, i, hp)
+					WithStrictReplay(strict)(e)
+
+					var err error
+					result, err = e.ExecuteTask(context.Background(), startWorkflowTask("instanceID", workflowWithHash))
+					require.NoError(t, err)
+					require.Equal(t, uint64(2264023121570675018), hash)
+					require.Equal(t, history.EventType_MarkerRecorded, result.Executed[2].Type)
+				}
+
+				marker := result.Executed[2]
+				require.Equal(t, history.EventType_MarkerRecorded, marker.Type)
+				a, err := history.AttributesAs[*history.MarkerRecordedAttributes](&marker)
+				require.NoError(t, err)
+				require.Equal(t, wf.HashMarkerName, a.Name)
+
+				// Replaying a different hash fails the workflow
+				details, _ := converter.DefaultConverter.To(uint64(42))
+				hp.history = []history.Event{
+					history.NewHistoryEvent(
+						1,
+						time.Now(),
+						history.EventType_WorkflowExecutionStarted,
+						&history.ExecutionStartedAttributes{
+							Name:   fn.Name(workflowWithHash),
+							Inputs: []payload.Payload{},
+						},
+					),
+					history.NewHistoryEvent(
+						2,
+						time.Now(),
+						history.EventType_MarkerRecorded,
+						&history.MarkerRecordedAttributes{
+							Name:    wf.HashMarkerName,
+							Details: details,
+						},
+						history.ScheduleEventID(1),
+					),
+				}
+
+				replayTask := &task.Workflow{
+					ID:               "taskID",
+					WorkflowInstance: core.NewWorkflowInstance("instanceID", "executionID"),
+					Metadata:         &core.WorkflowMetadata{},
+					LastSequenceID:   2,
+				}
+
+				// Without strict replay, only the marker's name is compared
+				e = newExecutor(r, i, hp)
+
+				result, err = e.ExecuteTask(context.Background(), replayTask)
+				require.NoError(t, err)
+				require.True(t, result.Completed)
+
+				finished := result.Executed[len(result.Executed)-1]
+				ca, err := history.AttributesAs[*history.ExecutionCompletedAttributes](&finished)
+				require.NoError(t, err)
+				require.Empty(t, ca.Error)
+
+				e = newExecutor(r, i, hp)
+				WithStrictReplay(true)(e)
+
+				result, err = e.ExecuteTask(context.Background(), replayTask)
+				require.NoError(t, err)
+				require.True(t, result.Completed)
+
+				finished = result.Executed[len(result.Executed)-1]
+				ca, err = history.AttributesAs[*history.ExecutionCompletedAttributes](&finished)
+				require.NoError(t, err)
+				require.Contains(t, ca.Error, `recorded marker "workflow.Hash" with different details`)
+			},
+		},
+		{
+			name: "Reports schema mismatches of activity results",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				type order struct {
+					Name  string
+					Total int
+				}
+
+				var result order
+				workflowWithActivity := func(ctx sync.Context) error {
+					var err error
+					result, err = wf.ExecuteActivity[order](ctx, wf.DefaultActivityOptions, activity1).Get(ctx)
+					return err
+				}
+
+				r.RegisterWorkflow(workflowWithActivity)
+				r.RegisterActivity(activity1)
+
+				// Total was recorded as a string by an earlier version of the activity
+				recorded := payload.Payload(`{"Name":"order-1","Total":"12.50"}`)
+
+				hp.history = []history.Event{
+					history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+						Name:   fn.Name(workflowWithActivity),
+						Inputs: []payload.Payload{},
+					}),
+					history.NewHistoryEvent(2, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+						Name:   "activity1",
+						Inputs: []payload.Payload{},
+					}, history.ScheduleEventID(1)),
+				}
+				completed := history.NewHistoryEvent(3, time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{
+					Result: recorded,
+				}, history.ScheduleEventID(1))
+				hp.history = append(hp.history, completed)
+
+				task := &task.Workflow{
+					ID:               "taskID",
+					WorkflowInstance: core.NewWorkflowInstance("instanceID", "executionID"),
+					Metadata:         &core.WorkflowMetadata{},
+					LastSequenceID:   3,
+				}
+
+				res, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.True(t, res.Completed)
+
+				finished := res.Executed[len(res.Executed)-1]
+				a, err := history.AttributesAs[*history.ExecutionCompletedAttributes](&finished)
+				require.NoError(t, err)
+				require.Contains(t, a.Error, "field Total is a string")
+				require.Contains(t, a.Error, completed.ID)
+
+				// Lenient decoding uses zero values for mismatching fields
+				e = newExecutor(r, i, hp)
+				WithLenientDecoding(true)(e)
+
+				res, err = e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.True(t, res.Completed)
+				require.NoError(t, e.workflow.err)
+				require.Equal(t, order{Name: "order-1"}, result)
+			},
+		},
+		{
+			name: "Exposes execution info to workflow code",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				infos := []wf.WorkflowExecutionInfo{}
+				workflowWithActivity := func(ctx sync.Context) error {
+					infos = append(infos, wf.ExecutionInfo(ctx))
+					if _, err := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx); err != nil {
+						panic("error getting activity 1 result")
+					}
+					infos = append(infos, wf.ExecutionInfo(ctx))
+					return nil
+				}
+
+				r.RegisterWorkflow(workflowWithActivity)
+				r.RegisterActivity(activity1)
+
+				inputs, _ := converter.DefaultConverter.To(42)
+				result, _ := converter.DefaultConverter.To(42)
+				startedAt := time.Now().Add(-time.Hour)
+
+				hp.history = []history.Event{
+					history.NewHistoryEvent(
+						1,
+						startedAt,
+						history.EventType_WorkflowExecutionStarted,
+						&history.ExecutionStartedAttributes{
+							Name:    fn.Name(workflowWithActivity),
+							Inputs:  []payload.Payload{},
+							Attempt: 2,
+						},
+					),
+					history.NewHistoryEvent(
+						2,
+						time.Now(),
+						history.EventType_ActivityScheduled,
+						&history.ActivityScheduledAttributes{
+							Name:   "activity1",
+							Inputs: []payload.Payload{inputs},
+						},
+						history.ScheduleEventID(1),
+					),
+				}
+
+				i = core.NewSubWorkflowInstance(uuid.NewString(), uuid.NewString(), "parentInstanceID", 1)
+				e = newExecutor(r, i, hp)
+
+				task := &task.Workflow{
+					ID:               "taskID",
+					WorkflowInstance: i,
+					Metadata:         &core.WorkflowMetadata{},
+					NewEvents: []history.Event{
+						history.NewPendingEvent(
+							time.Now(),
+							history.EventType_ActivityCompleted,
+							&history.ActivityCompletedAttributes{
+								Result: result,
+							},
+							history.ScheduleEventID(1),
+						),
+					},
+					LastSequenceID: 2,
+				}
+
+				_, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				require.Len(t, infos, 2)
+
+				expected := wf.WorkflowExecutionInfo{
+					InstanceID:       i.InstanceID,
+					ExecutionID:      i.ExecutionID,
+					WorkflowName:     fn.Name(workflowWithActivity),
+					ParentInstanceID: "parentInstanceID",
+					Attempt:          2,
+					StartTime:        startedAt,
+					IsReplaying:      true,
+				}
+				require.Equal(t, expected, infos[0])
+
+				expected.IsReplaying = false
+				require.Equal(t, expected, infos[1])
+			},
+		},
+		{
+			name: "Workflow with new events",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflowActivityHit := 0
+				workflowWithActivity := func(ctx sync.Context) error {
+					workflowActivityHit++
+					if _, err := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx); err != nil {
+						panic("error getting activity 1 result")
+					}
+					workflowActivityHit++
+					return nil
+				}
+
+				r.RegisterWorkflow(workflowWithActivity)
+				r.RegisterActivity(activity1)
+
+				inputs, _ := converter.DefaultConverter.To(42)
+				result, _ := converter.DefaultConverter.To(42)
+
+				oldTask := &task.Workflow{
+					ID:               "oldtaskid",
+					WorkflowInstance: core.NewWorkflowInstance("instanceID", "executionID"),
+					Metadata:         &core.WorkflowMetadata{},
+					NewEvents: []history.Event{
+						history.NewPendingEvent(
+							time.Now(),
+							history.EventType_WorkflowExecutionStarted,
+							&history.ExecutionStartedAttributes{
+								Name:   fn.Name(workflowWithActivity),
+								Inputs: []payload.Payload{},
+							},
+						),
+						history.NewPendingEvent(
+							time.Now(),
+							history.EventType_ActivityScheduled,
+							&history.ActivityScheduledAttributes{
+								Name:   "activity1",
+								Inputs: []payload.Payload{inputs},
+							},
+							history.ScheduleEventID(1),
+						),
+					},
+				}
+
+				taskResult, err := e.ExecuteTask(context.Background(), oldTask)
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				require.Equal(t, 1, workflowActivityHit)
+				require.False(t, e.workflow.Completed())
+				require.Len(t, e.workflowState.Commands(), 1)
+
+				h := []history.Event{}
+				h = append(h, oldTask.NewEvents...)
+				h = append(h, taskResult.Executed...)
+
+				newTask := &task.Workflow{
+					ID:               "taskID",
+					WorkflowInstance: oldTask.WorkflowInstance,
+					Metadata:         &core.WorkflowMetadata{},
+					NewEvents: []history.Event{
+						history.NewHistoryEvent(
+							1,
+							time.Now(),
+							history.EventType_ActivityCompleted,
+							&history.ActivityCompletedAttributes{
+								Result: result,
+							},
+							history.ScheduleEventID(1),
+						),
+					},
+					LastSequenceID: taskResult.Executed[len(taskResult.Executed)-1].SequenceID,
+				}
+
+				// Execute the workflow again with the activity completed event
+				_, err = e.ExecuteTask(context.Background(), newTask)
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				require.Equal(t, 2, workflowActivityHit)
+				require.True(t, e.workflow.Completed())
+				require.Len(t, e.workflowState.Commands(), 2)
+			},
+		},
+		{
+			name: "Workflow with selector",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				var workflowWithSelectorHits int
+
+				workflowWithSelector := func(ctx sync.Context) error {
+					workflowWithSelectorHits++
+
+					f1 := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42)
+					t := wf.ScheduleTimer(ctx, time.Millisecond*2)
+
+					sync.Select(
+						ctx,
+						sync.Await[int](f1, func(ctx sync.Context, f sync.Future[int]) {
+							workflowWithSelectorHits++
+						}),
+						sync.Await[struct{}](t, func(ctx sync.Context, _ sync.Future[struct{}]) {
+							workflowWithSelectorHits++
+						}),
+					)
+
+					workflowWithSelectorHits++
+
+					return nil
+				}
+
+				r.RegisterWorkflow(workflowWithSelector)
+				r.RegisterActivity(activity1)
+
+				task := &task.Workflow{
+					ID:               "taskID",
+					WorkflowInstance: core.NewWorkflowInstance("instanceID", "executionID"),
+					Metadata:         &core.WorkflowMetadata{},
+					NewEvents: []history.Event{
+						history.NewHistoryEvent(
+							1,
+							time.Now(),
+							history.EventType_WorkflowExecutionStarted,
+							&history.ExecutionStartedAttributes{
+								Name:   fn.Name(workflowWithSelector),
+								Inputs: []payload.Payload{},
+							},
+						),
+					},
+				}
+
+				_, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				require.Equal(t, 1, workflowWithSelectorHits)
+				require.Len(t, e.workflowState.Commands(), 2)
+
+				require.IsType(t, &command.ScheduleActivityCommand{}, e.workflowState.Commands()[0])
+				require.IsType(t, &command.ScheduleTimerCommand{}, e.workflowState.Commands()[1])
+			},
+		},
+		{
+			name: "Workflow with timer",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflowTimerHits := 0
+
+				workflowWithTimer := func(ctx sync.Context) error {
+					workflowTimerHits++
+
+					if _, err := wf.ScheduleTimer(ctx, time.Millisecond*5).Get(ctx); err != nil {
+						panic("error getting timer future")
+					}
+
+					workflowTimerHits++
+
+					return nil
+				}
+
+				r.RegisterWorkflow(workflowWithTimer)
+
+				task := &task.Workflow{
+					ID:               "taskID",
+					WorkflowInstance: core.NewWorkflowInstance("instanceID", "executionID"),
+					Metadata:         &core.WorkflowMetadata{},
+					NewEvents: []history.Event{
+						history.NewHistoryEvent(
+							1,
+							time.Now(),
+							history.EventType_WorkflowExecutionStarted,
+							&history.ExecutionStartedAttributes{
+								Name:   fn.Name(workflowWithTimer),
+								Inputs: []payload.Payload{},
+							},
+						),
+					},
+				}
+
+				_, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				require.Equal(t, 1, workflowTimerHits)
+				require.Len(t, e.workflowState.Commands(), 1)
+
+				require.Equal(t, int64(1), e.workflowState.Commands()[0].ID())
+				require.IsType(t, &command.ScheduleTimerCommand{}, e.workflowState.Commands()[0])
+			},
+		},
+		{
+			name: "Cancel timer multiple times",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflowWithTimer := func(ctx sync.Context) error {
+					tctx, cancel := wf.WithCancel(ctx)
+
+					wf.ScheduleTimer(tctx, time.Millisecond*5)
+
+					// Cause checkpoint
+					wf.ExecuteActivity[any](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+
+					cancel()
+					cancel()
+
+					return nil
+				}
+
+				r.RegisterWorkflow(workflowWithTimer)
+				r.RegisterActivity(activity1)
+
+				task := startWorkflowTask(i.InstanceID, workflowWithTimer)
+
+				result, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				require.Len(t, e.workflowState.Commands(), 2)
+
+				task2 := continueTask(i.InstanceID, []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{}, history.ScheduleEventID(2)),
+				}, result.Executed[len(result.Executed)-1].SequenceID)
+
+				result, err = e.ExecuteTask(context.Background(), task2)
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+			},
+		},
+		{
+			name: "Cancellation cause reflects the cancellation reason",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				var ctxErr, cause error
+
+				workflowWithCancellation := func(ctx sync.Context) error {
+					ctx.Done().Receive(ctx)
+
+					ctxErr = ctx.Err()
+					cause = wf.Cause(ctx)
+
+					return nil
+				}
+
+				r.RegisterWorkflow(workflowWithCancellation)
+
+				task := startWorkflowTask(i.InstanceID, workflowWithCancellation)
+
+				result, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.Nil(t, cause)
+
+				task2 := continueTask(i.InstanceID, []history.Event{
+					history.NewWorkflowCancellationEvent(time.Now(), history.CancellationReason_Parent),
+				}, result.Executed[len(result.Executed)-1].SequenceID)
+
+				_, err = e.ExecuteTask(context.Background(), task2)
+				require.NoError(t, err)
+				require.ErrorIs(t, ctxErr, sync.Canceled)
+				require.ErrorIs(t, cause, wf.ErrCanceledByParent)
+			},
+		},
+		{
+			name: "Cancellation details are available to the workflow",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				type refund struct {
+					Amount int
+				}
+
+				var before, after *wf.Cancellation
+				var details refund
+
+				workflowWithCancellation := func(ctx sync.Context) error {
+					before = wf.CancellationDetails(ctx)
+
+					ctx.Done().Receive(ctx)
+
+					after = wf.CancellationDetails(ctx)
+
+					return after.Details(&details)
+				}
+
+				r.RegisterWorkflow(workflowWithCancellation)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, workflowWithCancellation))
+				require.NoError(t, err)
+
+				d, err := converter.DefaultConverter.To(refund{Amount: 42})
+				require.NoError(t, err)
+
+				task2 := continueTask(i.InstanceID, []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionCanceled, &history.ExecutionCanceledAttributes{
+						Message: "customer request",
+						Details: d,
+					}),
+				}, result.Executed[len(result.Executed)-1].SequenceID)
+
+				_, err = e.ExecuteTask(context.Background(), task2)
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.Error())
+
+				require.Nil(t, before)
+				require.NotNil(t, after)
+				require.ErrorIs(t, after.Cause, wf.ErrCanceledByUser)
+				require.Equal(t, "customer request", after.Reason)
+				require.True(t, after.HasDetails())
+				require.Equal(t, refund{Amount: 42}, details)
+			},
+		},
+		{
+			name: "Activities scheduled after cancellation record it",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflowWithCleanup := func(ctx sync.Context) error {
+					ctx.Done().Receive(ctx)
+
+					_, err := wf.ExecuteActivity[int](wf.NewDisconnectedContext(ctx), wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+					return err
+				}
+
+				r.RegisterWorkflow(workflowWithCleanup)
+				r.RegisterActivity(activity1)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, workflowWithCleanup))
+				require.NoError(t, err)
+
+				result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{
+					history.NewWorkflowCancellationEvent(time.Now(), history.CancellationReason_User),
+				}, result.Executed[len(result.Executed)-1].SequenceID))
+				require.NoError(t, err)
+				require.Len(t, result.ActivityEvents, 1)
+
+				a, err := history.AttributesAs[*history.ActivityScheduledAttributes](&result.ActivityEvents[0])
+				require.NoError(t, err)
+				require.True(t, a.CancellationRequested)
+				require.Equal(t, wf.DefaultActivityOptions.RetryOptions.MaxAttempts, a.MaxAttempts)
+			},
+		},
+		{
+			name: "Traces coroutine scheduling per task",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflowWithGo := func(ctx sync.Context) error {
+					wf.Go(ctx, func(ctx sync.Context) {
+						wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+					})
+
+					return nil
+				}
+
+				r.RegisterWorkflow(workflowWithGo)
+				r.RegisterActivity(activity1)
+
+				trace := sync.NewSchedulerTrace(0)
+				WithSchedulerTracer(trace)(e)
+
+				_, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, workflowWithGo))
+				require.NoError(t, err)
+
+				var created, blocked int
+				for _, event := range trace.Events() {
+					switch event.Kind {
+					case sync.SchedulerEventCreated:
+						created++
+					case sync.SchedulerEventBlocked:
+						if event.Reason == sync.BlockReasonFuture {
+							blocked++
+						}
+					}
+				}
+
+				require.GreaterOrEqual(t, created, 2)
+				require.GreaterOrEqual(t, blocked, 1)
+
+				// The trace is reset for the next task
+				_, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{}, e.lastSequenceID))
+				require.NoError(t, err)
+
+				for _, event := range trace.Events() {
+					require.NotEqual(t, sync.SchedulerEventCreated, event.Kind)
+				}
+			},
+		},
+		{
+			name: "Records config values when they change",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				var values []string
+
+				workflowWithConfig := func(ctx sync.Context) error {
+					values = nil
+
+					for j := 0; j < 2; j++ {
+						v, err := wf.GetConfigValue(ctx, "REGION")
+						if err != nil {
+							return err
+						}
+						values = append(values, v)
+					}
+
+					// Cause checkpoint
+					wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+
+					v, err := wf.GetConfigValue(ctx, "REGION")
+					if err != nil {
+						return err
+					}
+					values = append(values, v)
+
+					return nil
+				}
+
+				r.RegisterWorkflow(workflowWithConfig)
+				r.RegisterActivity(activity1)
+
+				markers := func(events []history.Event) int {
+					n := 0
+					for _, event := range events {
+						if event.Type == history.EventType_MarkerRecorded {
+							n++
+						}
+					}
+					return n
+				}
+
+				WithConfigSource(func(key string) string { return "westus" })(e)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, workflowWithConfig))
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				require.Equal(t, []string{"westus", "westus"}, values)
+				require.Equal(t, 1, markers(result.Executed))
+
+				// Replay on a new executor, with a changed value
+				hp.history = result.Executed
+				e = newExecutor(r, i, hp)
+				WithConfigSource(func(key string) string { return "eastus" })(e)
+
+				task2 := continueTask(i.InstanceID, []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{}, history.ScheduleEventID(3)),
+				}, result.Executed[len(result.Executed)-1].SequenceID)
+
+				result, err = e.ExecuteTask(context.Background(), task2)
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				require.Equal(t, []string{"westus", "westus", "eastus"}, values)
+				require.Equal(t, 1, markers(result.Executed))
+			},
+		},
+		{
+			name: "Records versions of changes",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				withVersions := false
+				var versions []int
+
+				workflowWithVersions := func(ctx sync.Context) error {
+					versions = nil
+
+					if withVersions {
+						v, err := wf.GetVersion(ctx, "before", wf.DefaultVersion, 1)
+						if err != nil {
+							return err
+						}
+						versions = append(versions, v)
+					}
+
+					wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+
+					if withVersions {
+						v, err := wf.GetVersion(ctx, "after", wf.DefaultVersion, 1)
+						if err != nil {
+							return err
+						}
+						versions = append(versions, v)
+					}
+
+					return nil
+				}
+
+				r.RegisterWorkflow(workflowWithVersions)
+				r.RegisterActivity(activity1)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, workflowWithVersions))
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				h := result.Executed
+
+				// Deploy the changed code and continue on a new executor
+				withVersions = true
+				hp.history = h
+				e = newExecutor(r, i, hp)
+
+				task2 := continueTask(i.InstanceID, []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{}, history.ScheduleEventID(1)),
+				}, h[len(h)-1].SequenceID)
+
+				result, err = e.ExecuteTask(context.Background(), task2)
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				require.Equal(t, []int{wf.DefaultVersion, 1}, versions)
+				h = append(h, result.Executed...)
+
+				var markers []string
+				for j := range result.Executed {
+					if result.Executed[j].Type == history.EventType_MarkerRecorded {
+						a, err := history.AttributesAs[*history.MarkerRecordedAttributes](&result.Executed[j])
+						require.NoError(t, err)
+						markers = append(markers, a.Name)
+					}
+				}
+				require.Equal(t, []string{"version:after"}, markers)
+
+				// Replaying returns the recorded versions
+				hp.history = h
+				e = newExecutor(r, i, hp)
+
+				require.NoError(t, e.Replay(context.Background(), i))
+				require.NoError(t, e.workflow.err)
+				require.Equal(t, []int{wf.DefaultVersion, 1}, versions)
+			},
+		},
+		{
+			name: "Schedules execution timeout",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				var cause error
+				workflowWithTimer := func(ctx sync.Context) error {
+					_, err := wf.ScheduleTimer(ctx, time.Hour).Get(ctx)
+					cause = wf.Cause(ctx)
+					return err
+				}
+
+				r.RegisterWorkflow(workflowWithTimer)
+
+				task := startWorkflowTask(i.InstanceID, workflowWithTimer)
+				a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&task.NewEvents[0])
+				require.NoError(t, err)
+				a.ExecutionTimeout = time.Minute
+
+				result, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.False(t, result.Completed)
+
+				// The timeout is scheduled next to the workflow's timer, without consuming a schedule event ID
+				require.Len(t, result.TimerEvents, 2)
+				timeoutEvent := result.TimerEvents[1]
+				require.Equal(t, history.EventType_WorkflowExecutionCanceled, timeoutEvent.Type)
+				require.Equal(t, int64(0), timeoutEvent.ScheduleEventID)
+				require.Equal(t, task.NewEvents[0].Timestamp.Add(time.Minute), *timeoutEvent.VisibleAt)
+
+				ca, err := history.AttributesAs[*history.ExecutionCanceledAttributes](&timeoutEvent)
+				require.NoError(t, err)
+				require.Equal(t, history.CancellationReason_Timeout, ca.Reason)
+
+				// Delivering the timeout cancels the workflow
+				h := result.Executed
+				hp.history = h
+				e = newExecutor(r, i, hp)
+
+				result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{timeoutEvent}, h[len(h)-1].SequenceID))
+				require.NoError(t, err)
+				require.True(t, result.Completed)
+				require.Empty(t, result.TimerEvents)
+				require.ErrorIs(t, cause, core.ErrTimedOut)
+			},
+		},
+		{
+			name: "Records random seed",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				var numbers []int64
+				var ids []string
+				workflowWithRandom := func(ctx sync.Context) error {
+					numbers = []int64{wf.Random(ctx).Int63()}
+					ids = []string{wf.NewUUID(ctx)}
+
+					wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+
+					numbers = append(numbers, wf.Random(ctx).Int63())
+					ids = append(ids, wf.NewUUID(ctx))
+
+					return nil
+				}
+
+				r.RegisterWorkflow(workflowWithRandom)
+				r.RegisterActivity(activity1)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, workflowWithRandom))
+				require.NoError(t, err)
+				h := result.Executed
+
+				a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&h[1])
+				require.NoError(t, err)
+				require.NotZero(t, a.RandomSeed)
+
+				result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{}, history.ScheduleEventID(1)),
+				}, h[len(h)-1].SequenceID))
+				require.NoError(t, err)
+				require.True(t, result.Completed)
+				h = append(h, result.Executed...)
+
+				recordedNumbers, recordedIDs := numbers, ids
+				require.Len(t, recordedNumbers, 2)
+				require.NotEqual(t, recordedIDs[0], recordedIDs[1])
+
+				// Replaying on another executor returns the same sequence
+				hp.history = h
+				e = newExecutor(r, i, hp)
+
+				require.NoError(t, e.Replay(context.Background(), i))
+				require.Equal(t, recordedNumbers, numbers)
+				require.Equal(t, recordedIDs, ids)
+			},
+		},
+		{
+			name: "Schedules activity timeouts",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				var activityErr error
+				workflowWithActivity := func(ctx sync.Context) error {
+					_, activityErr = wf.ExecuteActivity[int](ctx, wf.ActivityOptions{
+						RetryOptions:           wf.RetryOptions{MaxAttempts: 1},
+						ScheduleToStartTimeout: time.Minute,
+						StartToCloseTimeout:    time.Hour,
+					}, activity1, 42).Get(ctx)
+
+					wf.NewSignalChannel[string](ctx, "signal").Receive(ctx)
+
+					return nil
+				}
+
+				r.RegisterWorkflow(workflowWithActivity)
+				r.RegisterActivity(activity1)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, workflowWithActivity))
+				require.NoError(t, err)
+				h := result.Executed
+
+				// The schedule-to-start timeout is scheduled with the activity
+				require.Len(t, result.ActivityEvents, 1)
+				require.Len(t, result.TimerEvents, 1)
+				scheduleToStart := result.TimerEvents[0]
+				require.Equal(t, history.EventType_ActivityFailed, scheduleToStart.Type)
+				require.Equal(t, result.ActivityEvents[0].ScheduleEventID, scheduleToStart.ScheduleEventID)
+				require.Equal(t, result.ActivityEvents[0].Timestamp.Add(time.Minute), *scheduleToStart.VisibleAt)
+
+				// The start-to-close timeout is scheduled once a worker starts the activity
+				started := history.NewPendingEvent(time.Now(), history.EventType_ActivityStarted, &history.ActivityStartedAttributes{},
+					history.ScheduleEventID(scheduleToStart.ScheduleEventID))
+				result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{started}, h[len(h)-1].SequenceID))
+				require.NoError(t, err)
+				h = append(h, result.Executed...)
+
+				require.Len(t, result.TimerEvents, 1)
+				startToClose := result.TimerEvents[0]
+				require.Equal(t, started.Timestamp.Add(time.Hour), *startToClose.VisibleAt)
+
+				// The schedule-to-start timeout is ignored, since the activity was started in time
+				result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{scheduleToStart}, h[len(h)-1].SequenceID))
+				require.NoError(t, err)
+				h = append(h, result.Executed...)
+				require.Nil(t, activityErr)
+
+				// The start-to-close timeout fails the activity
+				result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{startToClose}, h[len(h)-1].SequenceID))
+				require.NoError(t, err)
+				h = append(h, result.Executed...)
+
+				var terr *workflowerrors.TimeoutError
+				require.ErrorAs(t, activityErr, &terr)
+
+				// The result reported by the worker after the timeout is ignored, also when replaying
+				r1, _ := converter.DefaultConverter.To(42)
+				s, _ := converter.DefaultConverter.To("")
+				newEvents := []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{Result: r1},
+						history.ScheduleEventID(scheduleToStart.ScheduleEventID)),
+					history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{Name: "signal", Arg: s}),
+				}
+
+				hp.history = h
+				e = newExecutor(r, i, hp)
+
+				result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, newEvents, h[len(h)-1].SequenceID))
+				require.NoError(t, err)
+				require.True(t, result.Completed)
+				require.Empty(t, result.TimerEvents)
+				require.ErrorAs(t, activityErr, &terr)
+			},
+		},
+		{
+			name: "Schedules heartbeat timeouts",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflowWithActivity := func(ctx sync.Context) (int, error) {
+					return wf.ExecuteActivity[int](ctx, wf.ActivityOptions{
+						RetryOptions:     wf.RetryOptions{MaxAttempts: 2},
+						HeartbeatTimeout: time.Minute,
+					}, activity1, 42).Get(ctx)
+				}
+
+				r.RegisterWorkflow(workflowWithActivity)
+				r.RegisterActivity(activity1)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, workflowWithActivity))
+				require.NoError(t, err)
+				h := result.Executed
+				require.Len(t, result.ActivityEvents, 1)
+				require.Empty(t, result.TimerEvents)
+				activityEvent := result.ActivityEvents[0]
+
+				// Workers that can't move the timeout don't get one
+				started := history.NewPendingEvent(time.Now(), history.EventType_ActivityStarted, &history.ActivityStartedAttributes{},
+					history.ScheduleEventID(activityEvent.ScheduleEventID), history.CausedBy(activityEvent.ID))
+				result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{started}, h[len(h)-1].SequenceID))
+				require.NoError(t, err)
+				h = append(h, result.Executed...)
+				require.Empty(t, result.TimerEvents)
+
+				// The heartbeat timeout is scheduled once a worker that moves it with every heartbeat starts the activity
+				started = history.NewPendingEvent(time.Now(), history.EventType_ActivityStarted,
+					&history.ActivityStartedAttributes{ReschedulesHeartbeatTimeout: true},
+					history.ScheduleEventID(activityEvent.ScheduleEventID), history.CausedBy(activityEvent.ID))
+				result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{started}, h[len(h)-1].SequenceID))
+				require.NoError(t, err)
+				h = append(h, result.Executed...)
 
-				hp.history = []history.Event{
-					history.NewHistoryEvent(
-						1,
-						time.Now(),
-						history.EventType_WorkflowExecutionStarted,
-						&history.ExecutionStartedAttributes{
-							Name:   fn.Name(workflowWithActivity),
-							Inputs: []payload.Payload{},
-						},
-					),
-					history.NewHistoryEvent(
-						2,
-						time.Now(),
-						history.EventType_ActivityScheduled,
-						&history.ActivityScheduledAttributes{
-							Name:   "activity1",
-							Inputs: []payload.Payload{inputs},
-						},
-						history.ScheduleEventID(1),
-					),
-					history.NewHistoryEvent(
-						3,
-						time.Now(),
-						history.EventType_ActivityCompleted,
-						&history.ActivityCompletedAttributes{
-							Result: result,
-						},
-						history.ScheduleEventID(1),
-					),
+				require.Len(t, result.TimerEvents, 1)
+				heartbeatTimeout := result.TimerEvents[0]
+				require.Equal(t, history.EventType_ActivityFailed, heartbeatTimeout.Type)
+				require.Equal(t, activityEvent.ID+"-heartbeat-timeout", heartbeatTimeout.ID)
+				require.Equal(t, activityEvent.ScheduleEventID, heartbeatTimeout.ScheduleEventID)
+				require.Equal(t, started.Timestamp.Add(time.Minute), *heartbeatTimeout.VisibleAt)
+
+				// The worker moves the timeout with the details of the last heartbeat, when it fires the activity is retried
+				d, _ := converter.DefaultConverter.To("checkpoint")
+				heartbeatTimeout = history.NewActivityHeartbeatTimeoutEvent(
+					time.Now(), time.Now().Add(time.Minute), activityEvent.ID, activityEvent.ScheduleEventID, "activity1", time.Minute, d)
+				result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{heartbeatTimeout}, h[len(h)-1].SequenceID))
+				require.NoError(t, err)
+				require.False(t, result.Completed)
+				h = append(h, result.Executed...)
+
+				for len(result.ActivityEvents) == 0 {
+					// Fire the backoff timer before the retry
+					require.Len(t, result.TimerEvents, 1)
+					fired := history.NewPendingEvent(time.Now(), history.EventType_TimerFired, &history.TimerFiredAttributes{},
+						history.ScheduleEventID(result.TimerEvents[0].ScheduleEventID))
+					result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{fired}, h[len(h)-1].SequenceID))
+					require.NoError(t, err)
+					h = append(h, result.Executed...)
 				}
 
-				_, err := e.ExecuteTask(context.Background(), task)
+				require.Len(t, result.ActivityEvents, 1)
+				a, err := history.AttributesAs[*history.ActivityScheduledAttributes](&result.ActivityEvents[0])
 				require.NoError(t, err)
-				require.NoError(t, e.workflow.err)
-				require.Equal(t, 2, workflowActivityHit)
-				require.True(t, e.workflow.Completed())
-				require.Len(t, e.workflowState.Commands(), 2)
+				require.Equal(t, 1, a.Attempt)
+				require.Equal(t, d, a.HeartbeatDetails)
 			},
 		},
 		{
-			name: "Workflow with new events",
+			name: "Replays history without executing a task",
 			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
-				workflowActivityHit := 0
+				workflowHits := 0
 				workflowWithActivity := func(ctx sync.Context) error {
-					workflowActivityHit++
-					if _, err := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx); err != nil {
-						panic("error getting activity 1 result")
-					}
-					workflowActivityHit++
-					return nil
+					workflowHits++
+					_, err := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+					return err
 				}
 
 				r.RegisterWorkflow(workflowWithActivity)
 				r.RegisterActivity(activity1)
 
-				inputs, _ := converter.DefaultConverter.To(42)
-				result, _ := converter.DefaultConverter.To(42)
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, workflowWithActivity))
+				require.NoError(t, err)
 
-				oldTask := &task.Workflow{
-					ID:               "oldtaskid",
-					WorkflowInstance: core.NewWorkflowInstance("instanceID", "executionID"),
-					Metadata:         &core.WorkflowMetadata{},
-					NewEvents: []history.Event{
-						history.NewPendingEvent(
-							time.Now(),
-							history.EventType_WorkflowExecutionStarted,
-							&history.ExecutionStartedAttributes{
-								Name:   fn.Name(workflowWithActivity),
-								Inputs: []payload.Payload{},
-							},
-						),
-						history.NewPendingEvent(
-							time.Now(),
-							history.EventType_ActivityScheduled,
-							&history.ActivityScheduledAttributes{
-								Name:   "activity1",
-								Inputs: []payload.Payload{inputs},
-							},
-							history.ScheduleEventID(1),
-						),
-					},
-				}
+				hp.history = result.Executed
+				e = newExecutor(r, i, hp)
 
-				taskResult, err := e.ExecuteTask(context.Background(), oldTask)
-				require.NoError(t, err)
-				require.NoError(t, e.workflow.err)
-				require.Equal(t, 1, workflowActivityHit)
-				require.False(t, e.workflow.Completed())
+				require.NoError(t, e.Replay(context.Background(), i))
+				require.Equal(t, 2, workflowHits)
 				require.Len(t, e.workflowState.Commands(), 1)
 
-				h := []history.Event{}
-				h = append(h, oldTask.NewEvents...)
-				h = append(h, taskResult.Executed...)
+				// The next task continues without fetching the history again
+				e.historyProvider = &failingHistoryProvider{err: errors.New("history fetched")}
 
-				newTask := &task.Workflow{
-					ID:               "taskID",
-					WorkflowInstance: oldTask.WorkflowInstance,
-					Metadata:         &core.WorkflowMetadata{},
-					NewEvents: []history.Event{
-						history.NewHistoryEvent(
-							1,
-							time.Now(),
-							history.EventType_ActivityCompleted,
-							&history.ActivityCompletedAttributes{
-								Result: result,
-							},
-							history.ScheduleEventID(1),
-						),
-					},
-					LastSequenceID: taskResult.Executed[len(taskResult.Executed)-1].SequenceID,
-				}
+				r42, _ := converter.DefaultConverter.To(42)
+				task2 := continueTask(i.InstanceID, []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{Result: r42}, history.ScheduleEventID(1)),
+				}, result.Executed[len(result.Executed)-1].SequenceID)
 
-				// Execute the workflow again with the activity completed event
-				_, err = e.ExecuteTask(context.Background(), newTask)
+				result, err = e.ExecuteTask(context.Background(), task2)
 				require.NoError(t, err)
+				require.True(t, result.Completed)
 				require.NoError(t, e.workflow.err)
-				require.Equal(t, 2, workflowActivityHit)
-				require.True(t, e.workflow.Completed())
-				require.Len(t, e.workflowState.Commands(), 2)
 			},
 		},
 		{
-			name: "Workflow with selector",
+			name: "Replays compacted history",
 			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
-				var workflowWithSelectorHits int
-
-				workflowWithSelector := func(ctx sync.Context) error {
-					workflowWithSelectorHits++
-
-					f1 := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42)
-					t := wf.ScheduleTimer(ctx, time.Millisecond*2)
-
-					sync.Select(
-						ctx,
-						sync.Await[int](f1, func(ctx sync.Context, f sync.Future[int]) {
-							workflowWithSelectorHits++
-						}),
-						sync.Await[struct{}](t, func(ctx sync.Context, _ sync.Future[struct{}]) {
-							workflowWithSelectorHits++
-						}),
-					)
+				workflowWithActivity := func(ctx sync.Context) (int, error) {
+					r, err := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+					if err != nil {
+						return 0, err
+					}
 
-					workflowWithSelectorHits++
+					wf.NewSignalChannel[string](ctx, "signal").Receive(ctx)
 
-					return nil
+					return r, nil
 				}
 
-				r.RegisterWorkflow(workflowWithSelector)
+				r.RegisterWorkflow(workflowWithActivity)
 				r.RegisterActivity(activity1)
 
-				task := &task.Workflow{
-					ID:               "taskID",
-					WorkflowInstance: core.NewWorkflowInstance("instanceID", "executionID"),
-					Metadata:         &core.WorkflowMetadata{},
-					NewEvents: []history.Event{
-						history.NewHistoryEvent(
-							1,
-							time.Now(),
-							history.EventType_WorkflowExecutionStarted,
-							&history.ExecutionStartedAttributes{
-								Name:   fn.Name(workflowWithSelector),
-								Inputs: []payload.Payload{},
-							},
-						),
-					},
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, workflowWithActivity))
+				require.NoError(t, err)
+				h := result.Executed
+
+				r42, _ := converter.DefaultConverter.To(42)
+				result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{Result: r42}, history.ScheduleEventID(1)),
+				}, h[len(h)-1].SequenceID))
+				require.NoError(t, err)
+				h = append(h, result.Executed...)
+
+				summaries, removed, err := history.Compact(h, h[len(h)-1].SequenceID)
+				require.NoError(t, err)
+				require.Len(t, summaries, 1)
+				require.Len(t, removed, 1)
+
+				compacted := make([]history.Event, 0)
+				for _, event := range h {
+					switch event.SequenceID {
+					case removed[0]:
+					case summaries[0].SequenceID:
+						compacted = append(compacted, summaries[0])
+					default:
+						compacted = append(compacted, event)
+					}
 				}
 
-				_, err := e.ExecuteTask(context.Background(), task)
+				hp.history = compacted
+				e = newExecutor(r, i, hp)
+
+				s, _ := converter.DefaultConverter.To("")
+				result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{Name: "signal", Arg: s}),
+				}, h[len(h)-1].SequenceID))
 				require.NoError(t, err)
-				require.NoError(t, e.workflow.err)
-				require.Equal(t, 1, workflowWithSelectorHits)
-				require.Len(t, e.workflowState.Commands(), 2)
+				require.True(t, result.Completed)
+				require.Empty(t, result.ActivityEvents)
 
-				require.IsType(t, &command.ScheduleActivityCommand{}, e.workflowState.Commands()[0])
-				require.IsType(t, &command.ScheduleTimerCommand{}, e.workflowState.Commands()[1])
+				a, err := history.AttributesAs[*history.ExecutionCompletedAttributes](&result.Executed[len(result.Executed)-1])
+				require.NoError(t, err)
+				require.Equal(t, r42, a.Result)
 			},
 		},
 		{
-			name: "Workflow with timer",
+			name: "Replays compacted activity failure",
 			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
-				workflowTimerHits := 0
+				workflowWithActivity := func(ctx sync.Context) (string, error) {
+					options := wf.ActivityOptions{RetryOptions: wf.RetryOptions{MaxAttempts: 1}}
+					_, err := wf.ExecuteActivity[int](ctx, options, activity1, 42).Get(ctx)
 
-				workflowWithTimer := func(ctx sync.Context) error {
-					workflowTimerHits++
+					var aerr *workflowerrors.ActivityError
+					if !errors.As(err, &aerr) {
+						return "", errors.New("expected activity error")
+					}
 
-					if _, err := wf.ScheduleTimer(ctx, time.Millisecond*5).Get(ctx); err != nil {
-						panic("error getting timer future")
+					var details string
+					if ok, err := aerr.DecodeHeartbeatDetails(&details); err != nil || !ok {
+						return "", errors.New("expected heartbeat details")
 					}
 
-					workflowTimerHits++
+					wf.NewSignalChannel[string](ctx, "signal").Receive(ctx)
 
-					return nil
+					return aerr.Type + ":" + details, nil
 				}
 
-				r.RegisterWorkflow(workflowWithTimer)
+				r.RegisterWorkflow(workflowWithActivity)
+				r.RegisterActivity(activity1)
 
-				task := &task.Workflow{
-					ID:               "taskID",
-					WorkflowInstance: core.NewWorkflowInstance("instanceID", "executionID"),
-					Metadata:         &core.WorkflowMetadata{},
-					NewEvents: []history.Event{
-						history.NewHistoryEvent(
-							1,
-							time.Now(),
-							history.EventType_WorkflowExecutionStarted,
-							&history.ExecutionStartedAttributes{
-								Name:   fn.Name(workflowWithTimer),
-								Inputs: []payload.Payload{},
-							},
-						),
-					},
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, workflowWithActivity))
+				require.NoError(t, err)
+				h := result.Executed
+
+				result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityFailed, &history.ActivityFailedAttributes{
+						Reason:           "not found",
+						Type:             "NotFound",
+						HeartbeatDetails: payload.Payload(`"progress"`),
+					}, history.ScheduleEventID(1)),
+				}, h[len(h)-1].SequenceID))
+				require.NoError(t, err)
+				h = append(h, result.Executed...)
+
+				summaries, removed, err := history.Compact(h, h[len(h)-1].SequenceID)
+				require.NoError(t, err)
+				require.Len(t, summaries, 1)
+				require.Len(t, removed, 1)
+
+				compacted := make([]history.Event, 0)
+				for _, event := range h {
+					switch event.SequenceID {
+					case removed[0]:
+					case summaries[0].SequenceID:
+						compacted = append(compacted, summaries[0])
+					default:
+						compacted = append(compacted, event)
+					}
 				}
 
-				_, err := e.ExecuteTask(context.Background(), task)
+				hp.history = compacted
+				e = newExecutor(r, i, hp)
+
+				s, _ := converter.DefaultConverter.To("")
+				result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{Name: "signal", Arg: s}),
+				}, h[len(h)-1].SequenceID))
 				require.NoError(t, err)
-				require.NoError(t, e.workflow.err)
-				require.Equal(t, 1, workflowTimerHits)
-				require.Len(t, e.workflowState.Commands(), 1)
+				require.True(t, result.Completed)
 
-				require.Equal(t, int64(1), e.workflowState.Commands()[0].ID())
-				require.IsType(t, &command.ScheduleTimerCommand{}, e.workflowState.Commands()[0])
+				a, err := history.AttributesAs[*history.ExecutionCompletedAttributes](&result.Executed[len(result.Executed)-1])
+				require.NoError(t, err)
+				require.Empty(t, a.Error)
+
+				var res string
+				require.NoError(t, converter.DefaultConverter.From(a.Result, &res))
+				require.Equal(t, "NotFound:progress", res)
 			},
 		},
 		{
-			name: "Cancel timer multiple times",
+			name: "Replay budget parks task and resumes replay",
 			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
-				workflowWithTimer := func(ctx sync.Context) error {
-					tctx, cancel := wf.WithCancel(ctx)
-
-					wf.ScheduleTimer(tctx, time.Millisecond*5)
-
-					// Cause checkpoint
-					wf.ExecuteActivity[any](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+				workflowWithActivity := func(ctx sync.Context) (int, error) {
+					r, err := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+					if err != nil {
+						return 0, err
+					}
 
-					cancel()
-					cancel()
+					wf.NewSignalChannel[string](ctx, "signal").Receive(ctx)
 
-					return nil
+					return r, nil
 				}
 
-				r.RegisterWorkflow(workflowWithTimer)
+				r.RegisterWorkflow(workflowWithActivity)
 				r.RegisterActivity(activity1)
 
-				task := startWorkflowTask(i.InstanceID, workflowWithTimer)
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask(i.InstanceID, workflowWithActivity))
+				require.NoError(t, err)
+				h := result.Executed
 
-				result, err := e.ExecuteTask(context.Background(), task)
+				r42, _ := converter.DefaultConverter.To(42)
+				result, err = e.ExecuteTask(context.Background(), continueTask(i.InstanceID, []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{Result: r42}, history.ScheduleEventID(1)),
+				}, h[len(h)-1].SequenceID))
 				require.NoError(t, err)
-				require.NoError(t, e.workflow.err)
-				require.Len(t, e.workflowState.Commands(), 2)
+				h = append(h, result.Executed...)
+
+				e = newExecutor(r, i, hp)
+				WithReplayBudget(time.Nanosecond)(e)
+
+				s, _ := converter.DefaultConverter.To("")
+				task := continueTask(i.InstanceID, []history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{Name: "signal", Arg: s}),
+				}, h[len(h)-1].SequenceID)
+
+				// Every attempt replays at least one event, and resumes where the previous one stopped
+				attempts := 0
+				for {
+					hp.history = nil
+					for _, event := range h {
+						if event.SequenceID > e.lastSequenceID {
+							hp.history = append(hp.history, event)
+						}
+					}
 
-				task2 := continueTask(i.InstanceID, []history.Event{
-					history.NewPendingEvent(time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{}, history.ScheduleEventID(2)),
-				}, result.Executed[len(result.Executed)-1].SequenceID)
+					result, err = e.ExecuteTask(context.Background(), task)
+					if err == nil {
+						break
+					}
 
-				result, err = e.ExecuteTask(context.Background(), task2)
+					attempts++
+					require.True(t, IsTransientError(err))
+
+					var budgetErr *ReplayBudgetExceededError
+					require.ErrorAs(t, err, &budgetErr)
+					require.Equal(t, e.lastSequenceID, budgetErr.LastSequenceID)
+					require.Less(t, attempts, len(h))
+				}
+
+				require.Greater(t, attempts, 0)
+				require.True(t, result.Completed)
+
+				a, err := history.AttributesAs[*history.ExecutionCompletedAttributes](&result.Executed[len(result.Executed)-1])
 				require.NoError(t, err)
-				require.NoError(t, e.workflow.err)
+				require.Equal(t, r42, a.Result)
 			},
 		},
 		{
@@ -650,6 +1978,24 @@ func Test_Executor(t *testing.T) {
 	}
 }
 
+type counterMetricsClient struct {
+	counters map[string]int64
+}
+
+func (c *counterMetricsClient) Counter(name string, tags wfmetrics.Tags, value int64) {
+	c.counters[name] += value
+}
+
+func (*counterMetricsClient) Distribution(name string, tags wfmetrics.Tags, value float64) {}
+
+func (*counterMetricsClient) Gauge(name string, tags wfmetrics.Tags, value int64) {}
+
+func (*counterMetricsClient) Timing(name string, tags wfmetrics.Tags, duration time.Duration) {}
+
+func (c *counterMetricsClient) WithTags(tags wfmetrics.Tags) wfmetrics.Client {
+	return c
+}
+
 func startWorkflowTask(instanceID string, workflow interface{}, workflowArgs ...interface{}) *task.Workflow {
 	inputs, err := args.ArgsToInputs(converter.DefaultConverter, workflowArgs...)
 	if err != nil {
@@ -0,0 +1,59 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/log"
+	"github.com/cschleiden/go-workflows/metrics"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrQueryHandlerNotFound = errors.New("query handler not found")
+
+// ExecuteQuery replays the history of the given instance, and calls the query handler the workflow has
+// registered under the given name. Commands the workflow produces while being replayed are discarded.
+func ExecuteQuery(
+	ctx context.Context, logger log.Logger, tracer trace.Tracer, metrics metrics.Client, registry *Registry,
+	historyProvider WorkflowHistoryProvider, instance *core.WorkflowInstance, name string, inputs []payload.Payload,
+) (payload.Payload, error) {
+	h, err := historyProvider.GetWorkflowInstanceHistory(ctx, instance, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting workflow history: %w", err)
+	}
+
+	if len(h) == 0 {
+		return nil, fmt.Errorf("workflow instance %s has no history", instance.InstanceID)
+	}
+
+	// Replay exactly the fetched history, even if new events are added in the meantime
+	we, err := NewExecutor(logger, tracer, metrics, registry, &fixedHistory{h}, instance, clock.New(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer we.Close()
+
+	if err := we.Replay(ctx, instance); err != nil {
+		return nil, fmt.Errorf("replaying workflow instance: %w", err)
+	}
+
+	handler, ok := we.(*executor).workflowState.QueryHandler(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrQueryHandlerNotFound, name)
+	}
+
+	return handler(inputs)
+}
+
+type fixedHistory struct {
+	h []history.Event
+}
+
+func (f *fixedHistory) GetWorkflowInstanceHistory(ctx context.Context, instance *core.WorkflowInstance, lastSequenceID *int64) ([]history.Event, error) {
+	return f.h, nil
+}
@@ -1,8 +1,11 @@
 package workflow
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"reflect"
+	"sort"
 	"sync"
 
 	"github.com/cschleiden/go-workflows/internal/args"
@@ -14,15 +17,19 @@ type Activity interface{}
 type Registry struct {
 	sync.Mutex
 
-	workflowMap map[string]Workflow
-	activityMap map[string]interface{}
+	workflowMap       map[string]Workflow
+	workflowChecksums map[string]string
+	workflowVersions  map[string]string
+	activityMap       map[string]interface{}
 }
 
 func NewRegistry() *Registry {
 	return &Registry{
-		Mutex:       sync.Mutex{},
-		workflowMap: make(map[string]Workflow),
-		activityMap: make(map[string]interface{}),
+		Mutex:             sync.Mutex{},
+		workflowMap:       make(map[string]Workflow),
+		workflowChecksums: make(map[string]string),
+		workflowVersions:  make(map[string]string),
+		activityMap:       make(map[string]interface{}),
 	}
 }
 
@@ -42,7 +49,23 @@ func (e *ErrInvalidActivity) Error() string {
 	return e.msg
 }
 
-func (r *Registry) RegisterWorkflow(workflow Workflow) error {
+type registerOptions struct {
+	version string
+}
+
+// RegisterOption configures the registration of a workflow.
+type RegisterOption func(*registerOptions)
+
+// WithVersion sets a version for the workflow definition. The version is included in the definition
+// checksum recorded for new instances, so bumping it marks code changes that are risky for running
+// instances.
+func WithVersion(version string) RegisterOption {
+	return func(o *registerOptions) {
+		o.version = version
+	}
+}
+
+func (r *Registry) RegisterWorkflow(workflow Workflow, opts ...RegisterOption) error {
 	r.Lock()
 	defer r.Unlock()
 
@@ -73,12 +96,26 @@ func (r *Registry) RegisterWorkflow(workflow Workflow) error {
 		return &ErrInvalidWorkflow{"workflow must return error as last return value"}
 	}
 
+	var o registerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	name := fn.Name(workflow)
 	r.workflowMap[name] = workflow
+	r.workflowChecksums[name] = definitionChecksum(name, wfType, o.version)
+	r.workflowVersions[name] = o.version
 
 	return nil
 }
 
+// definitionChecksum hashes the identity of a workflow definition: its fully qualified name, which
+// includes the package path, its signature, and the optional version.
+func definitionChecksum(name string, wfType reflect.Type, version string) string {
+	h := sha256.Sum256([]byte(name + "\n" + wfType.String() + "\n" + version))
+	return hex.EncodeToString(h[:8])
+}
+
 func (r *Registry) RegisterActivity(activity interface{}) error {
 	r.Lock()
 	defer r.Unlock()
@@ -101,6 +138,21 @@ func (r *Registry) RegisterActivity(activity interface{}) error {
 	return nil
 }
 
+// RegisterActivityWithName registers the given activity function under the given name, e.g., for activities
+// built into the worker.
+func (r *Registry) RegisterActivityWithName(name string, activity interface{}) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if err := checkActivity(reflect.TypeOf(activity)); err != nil {
+		return err
+	}
+
+	r.activityMap[name] = activity
+
+	return nil
+}
+
 func (r *Registry) registerActivitiesFromStruct(a interface{}) error {
 	// Enumerate functions defined on a
 	v := reflect.ValueOf(a)
@@ -142,6 +194,67 @@ func checkActivity(actType reflect.Type) error {
 	return nil
 }
 
+// FunctionInfo describes a registered workflow or activity.
+type FunctionInfo struct {
+	Name string `json:"name"`
+
+	// Params are the types of the parameters passed as inputs, without a context parameter
+	Params []string `json:"params"`
+
+	// Results are the types of the results, without the error
+	Results []string `json:"results"`
+
+	// Source is the location the function is defined at
+	Source string `json:"source,omitempty"`
+}
+
+// Workflows returns information about all registered workflows, ordered by name.
+func (r *Registry) Workflows() []FunctionInfo {
+	r.Lock()
+	defer r.Unlock()
+
+	return functionInfos(r.workflowMap)
+}
+
+// Activities returns information about all registered activities, ordered by name.
+func (r *Registry) Activities() []FunctionInfo {
+	r.Lock()
+	defer r.Unlock()
+
+	return functionInfos(r.activityMap)
+}
+
+func functionInfos[T any](fns map[string]T) []FunctionInfo {
+	infos := make([]FunctionInfo, 0, len(fns))
+	for name, f := range fns {
+		fnT := reflect.TypeOf(f)
+
+		params := []string{}
+		for _, p := range args.ParamTypes(fnT) {
+			params = append(params, p.String())
+		}
+
+		// The last result is always the error
+		results := []string{}
+		for i := 0; i < fnT.NumOut()-1; i++ {
+			results = append(results, fnT.Out(i).String())
+		}
+
+		infos = append(infos, FunctionInfo{
+			Name:    name,
+			Params:  params,
+			Results: results,
+			Source:  fn.Source(f),
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+
+	return infos
+}
+
 func (r *Registry) GetWorkflow(name string) (Workflow, error) {
 	r.Lock()
 	defer r.Unlock()
@@ -153,6 +266,22 @@ func (r *Registry) GetWorkflow(name string) (Workflow, error) {
 	return nil, errors.New("workflow not found")
 }
 
+// WorkflowChecksum returns the definition checksum of the registered workflow with the given name.
+func (r *Registry) WorkflowChecksum(name string) string {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.workflowChecksums[name]
+}
+
+// WorkflowVersion returns the version the workflow with the given name was registered with, see WithVersion.
+func (r *Registry) WorkflowVersion(name string) string {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.workflowVersions[name]
+}
+
 func (r *Registry) GetActivity(name string) (interface{}, error) {
 	r.Lock()
 	defer r.Unlock()
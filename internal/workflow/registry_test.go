@@ -178,3 +178,94 @@ func Test_ActivityRegistrationOnStruct_Invalid(t *testing.T) {
 	err := r.RegisterActivity(a)
 	require.Error(t, err)
 }
+
+func reg_workflow_with_args(ctx sync.Context, name string, count int) (bool, error) {
+	return true, nil
+}
+
+func Test_RegistryIntrospection(t *testing.T) {
+	r := NewRegistry()
+
+	require.NoError(t, r.RegisterWorkflow(reg_workflow_with_args))
+	require.NoError(t, r.RegisterWorkflow(reg_workflow1))
+	require.NoError(t, r.RegisterActivity(&reg_activities{}))
+
+	wfs := r.Workflows()
+	require.Len(t, wfs, 2)
+	require.Equal(t, "reg_workflow1", wfs[0].Name)
+	require.Empty(t, wfs[0].Params)
+	require.Empty(t, wfs[0].Results)
+
+	require.Equal(t, "reg_workflow_with_args", wfs[1].Name)
+	require.Equal(t, []string{"string", "int"}, wfs[1].Params)
+	require.Equal(t, []string{"bool"}, wfs[1].Results)
+	require.Contains(t, wfs[1].Source, "registry_test.go:")
+
+	activities := r.Activities()
+	require.Len(t, activities, 1)
+	require.Equal(t, "Activity1", activities[0].Name)
+	require.Empty(t, activities[0].Params)
+	require.Equal(t, []string{"string"}, activities[0].Results)
+}
+
+func Test_RegistryWorkflowChecksum(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.RegisterWorkflow(reg_workflow1))
+	require.NoError(t, r.RegisterWorkflow(reg_workflow_with_args, WithVersion("v1")))
+
+	checksum := r.WorkflowChecksum(fn.Name(reg_workflow1))
+	require.NotEmpty(t, checksum)
+	require.NotEqual(t, checksum, r.WorkflowChecksum(fn.Name(reg_workflow_with_args)))
+
+	// Stable for the same definition
+	r2 := NewRegistry()
+	require.NoError(t, r2.RegisterWorkflow(reg_workflow1))
+	require.Equal(t, checksum, r2.WorkflowChecksum(fn.Name(reg_workflow1)))
+
+	// Changes with the version
+	require.NoError(t, r2.RegisterWorkflow(reg_workflow1, WithVersion("v2")))
+	require.NotEqual(t, checksum, r2.WorkflowChecksum(fn.Name(reg_workflow1)))
+}
+
+type reg_notifier interface {
+	Notify() error
+}
+
+type reg_input struct {
+	Name     string
+	Callback func() `json:"-"`
+	Values   map[string]interface{}
+}
+
+func reg_valid_workflow(ctx sync.Context, in reg_input, v interface{}) (map[int]string, error) {
+	return nil, nil
+}
+
+func reg_invalid_workflow(ctx sync.Context, ch chan int, n reg_notifier, names ...string) (func(), error) {
+	return nil, nil
+}
+
+func reg_invalid_activity(ctx context.Context, in struct{ C complex128 }) error {
+	return nil
+}
+
+func Test_RegistryValidate(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.RegisterWorkflow(reg_valid_workflow))
+	require.NoError(t, r.RegisterActivity(&reg_activities{}))
+	require.NoError(t, r.Validate())
+
+	require.NoError(t, r.RegisterWorkflow(reg_invalid_workflow))
+	require.NoError(t, r.RegisterActivity(reg_invalid_activity))
+
+	err := r.Validate()
+
+	var regErr *ErrInvalidRegistry
+	require.ErrorAs(t, err, &regErr)
+	require.Len(t, regErr.Errors, 5)
+	require.Contains(t, err.Error(), "variadic parameters are not supported")
+	require.Contains(t, err.Error(), "parameter 0: type chan int is not serializable")
+	require.Contains(t, err.Error(), "parameter 1: interface type workflow.reg_notifier cannot be decoded")
+	require.Contains(t, err.Error(), "result 0: type func() is not serializable")
+	require.Contains(t, err.Error(), "activity reg_invalid_activity: parameter 0: field C")
+}
@@ -0,0 +1,148 @@
+package workflow
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/cschleiden/go-workflows/internal/args"
+)
+
+// ErrInvalidRegistry is returned by Validate, and lists all problems found with registered workflows and
+// activities.
+type ErrInvalidRegistry struct {
+	Errors []error
+}
+
+func (e *ErrInvalidRegistry) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+
+	return fmt.Sprintf("invalid registry: %d problem(s):\n\t%s", len(e.Errors), strings.Join(msgs, "\n\t"))
+}
+
+// Validate checks the signatures of all registered workflows and activities: parameters and results have to
+// be serializable by the converter, functions must not be variadic, and the error has to be the last result.
+// All problems are returned together in an *ErrInvalidRegistry.
+func (r *Registry) Validate() error {
+	r.Lock()
+	defer r.Unlock()
+
+	var errs []error
+	errs = append(errs, validateFunctions("workflow", r.workflowMap)...)
+	errs = append(errs, validateFunctions("activity", r.activityMap)...)
+
+	if len(errs) > 0 {
+		return &ErrInvalidRegistry{Errors: errs}
+	}
+
+	return nil
+}
+
+func validateFunctions[T any](kind string, fns map[string]T) []error {
+	names := make([]string, 0, len(fns))
+	for name := range fns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		for _, problem := range validateSignature(reflect.TypeOf(fns[name])) {
+			errs = append(errs, fmt.Errorf("%s %s: %s", kind, name, problem))
+		}
+	}
+
+	return errs
+}
+
+func validateSignature(fnT reflect.Type) []string {
+	var problems []string
+
+	if fnT.IsVariadic() {
+		problems = append(problems, "variadic parameters are not supported")
+	}
+
+	for i, p := range args.ParamTypes(fnT) {
+		if p.Kind() == reflect.Interface && p.NumMethod() > 0 {
+			problems = append(problems, fmt.Sprintf("parameter %d: interface type %v cannot be decoded by the converter", i, p))
+			continue
+		}
+
+		if reason := unserializable(p, map[reflect.Type]bool{}); reason != "" {
+			problems = append(problems, fmt.Sprintf("parameter %d: %s", i, reason))
+		}
+	}
+
+	errType := reflect.TypeOf((*error)(nil)).Elem()
+	if fnT.NumOut() == 0 || !fnT.Out(fnT.NumOut()-1).Implements(errType) {
+		problems = append(problems, "error must be the last return value")
+	}
+
+	for i := 0; i < fnT.NumOut()-1; i++ {
+		if reason := unserializable(fnT.Out(i), map[reflect.Type]bool{}); reason != "" {
+			problems = append(problems, fmt.Sprintf("result %d: %s", i, reason))
+		}
+	}
+
+	return problems
+}
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// unserializable returns why values of the given type can't be serialized by the default converter, or an
+// empty string if they can.
+func unserializable(t reflect.Type, seen map[reflect.Type]bool) string {
+	if seen[t] {
+		return ""
+	}
+	seen[t] = true
+
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return ""
+	}
+
+	switch t.Kind() {
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return fmt.Sprintf("type %v is not serializable", t)
+
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return unserializable(t.Elem(), seen)
+
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			if !t.Key().Implements(textMarshalerType) {
+				return fmt.Sprintf("map key type %v is not serializable", t.Key())
+			}
+		}
+
+		return unserializable(t.Elem(), seen)
+
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() || f.Tag.Get("json") == "-" {
+				continue
+			}
+
+			if reason := unserializable(f.Type, seen); reason != "" {
+				return fmt.Sprintf("field %s of %v: %s", f.Name, t, reason)
+			}
+		}
+	}
+
+	return ""
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/cschleiden/go-workflows/internal/args"
 	"github.com/cschleiden/go-workflows/internal/converter"
@@ -88,6 +89,21 @@ func (w *workflow) Continue() error {
 	return w.s.Execute()
 }
 
+// SetSchedulerTracer sets the tracer receiving the coroutine scheduling events of the workflow.
+func (w *workflow) SetSchedulerTracer(t sync.SchedulerTracer) {
+	w.s.SetTracer(t)
+}
+
+// LongestSlice returns the longest time workflow code ran without yielding since the last call.
+func (w *workflow) LongestSlice() time.Duration {
+	return w.s.LongestSlice()
+}
+
+// SchedulerStats returns statistics about the coroutines of the workflow.
+func (w *workflow) SchedulerStats() sync.SchedulerStats {
+	return w.s.Stats()
+}
+
 func (w *workflow) Completed() bool {
 	return w.s.RunningCoroutines() == 0
 }
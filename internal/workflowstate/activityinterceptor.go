@@ -0,0 +1,59 @@
+package workflowstate
+
+import (
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+// ActivityInterceptor rewrites the inputs and results of activities scheduled by workflows, e.g., to scope
+// inputs to a tenant or to replace secrets with tokens before they are recorded in the history.
+//
+// Interceptors run as part of the workflow code, also when the history is replayed, so they have to be
+// deterministic.
+type ActivityInterceptor interface {
+	// OnSchedule is called with the inputs of an activity before it's scheduled. The returned inputs are
+	// recorded in the history and passed to the activity.
+	OnSchedule(activityName string, inputs []payload.Payload) ([]payload.Payload, error)
+
+	// OnComplete is called with the result of a successful activity before it's returned to the workflow.
+	OnComplete(activityName string, result payload.Payload) (payload.Payload, error)
+}
+
+func (wf *WfState) SetActivityInterceptors(interceptors []ActivityInterceptor) {
+	wf.activityInterceptors = interceptors
+}
+
+// InterceptActivityInputs passes the inputs of the given activity through all interceptors, in the order they
+// were configured.
+func (wf *WfState) InterceptActivityInputs(activityName string, inputs []payload.Payload) ([]payload.Payload, error) {
+	for _, i := range wf.activityInterceptors {
+		var err error
+		if inputs, err = i.OnSchedule(activityName, inputs); err != nil {
+			return nil, err
+		}
+	}
+
+	return inputs, nil
+}
+
+// InterceptActivityResult wraps f so that results of the given activity are passed through all interceptors
+// before they are decoded. Interceptors are called in reverse order, mirroring InterceptActivityInputs.
+func (wf *WfState) InterceptActivityResult(activityName string, f DecodingSettable) DecodingSettable {
+	if len(wf.activityInterceptors) == 0 {
+		return f
+	}
+
+	return func(v payload.Payload, err error) error {
+		if err != nil {
+			return f(v, err)
+		}
+
+		for i := len(wf.activityInterceptors) - 1; i >= 0; i-- {
+			var ierr error
+			if v, ierr = wf.activityInterceptors[i].OnComplete(activityName, v); ierr != nil {
+				return f(nil, ierr)
+			}
+		}
+
+		return f(v, nil)
+	}
+}
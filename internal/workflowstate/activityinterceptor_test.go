@@ -0,0 +1,78 @@
+package workflowstate
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/logger"
+	"github.com/cschleiden/go-workflows/internal/metrics"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingInterceptor struct {
+	name  string
+	calls *[]string
+	err   error
+}
+
+func (i *recordingInterceptor) OnSchedule(activityName string, inputs []payload.Payload) ([]payload.Payload, error) {
+	*i.calls = append(*i.calls, i.name+".OnSchedule")
+	return append(inputs, payload.Payload(i.name)), i.err
+}
+
+func (i *recordingInterceptor) OnComplete(activityName string, result payload.Payload) (payload.Payload, error) {
+	*i.calls = append(*i.calls, i.name+".OnComplete")
+	return payload.Payload(string(result) + i.name), i.err
+}
+
+func Test_ActivityInterceptors(t *testing.T) {
+	i := core.NewWorkflowInstance(uuid.NewString(), "")
+	wfState := NewWorkflowState(i, logger.NewDefaultLogger(), metrics.NewNoopMetricsClient(), clock.New())
+
+	calls := []string{}
+	wfState.SetActivityInterceptors([]ActivityInterceptor{
+		&recordingInterceptor{name: "a", calls: &calls},
+		&recordingInterceptor{name: "b", calls: &calls},
+	})
+
+	inputs, err := wfState.InterceptActivityInputs("activity", []payload.Payload{})
+	require.NoError(t, err)
+	require.Equal(t, []payload.Payload{payload.Payload("a"), payload.Payload("b")}, inputs)
+
+	var result payload.Payload
+	f := wfState.InterceptActivityResult("activity", func(v payload.Payload, err error) error {
+		result = v
+		return err
+	})
+	require.NoError(t, f(payload.Payload("r"), nil))
+	require.Equal(t, payload.Payload("rba"), result)
+
+	require.Equal(t, []string{"a.OnSchedule", "b.OnSchedule", "b.OnComplete", "a.OnComplete"}, calls)
+}
+
+func Test_ActivityInterceptors_Error(t *testing.T) {
+	i := core.NewWorkflowInstance(uuid.NewString(), "")
+	wfState := NewWorkflowState(i, logger.NewDefaultLogger(), metrics.NewNoopMetricsClient(), clock.New())
+
+	calls := []string{}
+	interceptorErr := errors.New("interceptor failed")
+	wfState.SetActivityInterceptors([]ActivityInterceptor{
+		&recordingInterceptor{name: "a", calls: &calls, err: interceptorErr},
+	})
+
+	_, err := wfState.InterceptActivityInputs("activity", []payload.Payload{})
+	require.ErrorIs(t, err, interceptorErr)
+
+	var resultErr error
+	f := wfState.InterceptActivityResult("activity", func(v payload.Payload, err error) error {
+		require.Nil(t, v)
+		resultErr = err
+		return nil
+	})
+	require.NoError(t, f(payload.Payload(`"r"`), nil))
+	require.ErrorIs(t, resultErr, interceptorErr)
+}
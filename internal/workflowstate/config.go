@@ -0,0 +1,69 @@
+package workflowstate
+
+import (
+	"os"
+	"strings"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+// ConfigMarkerPrefix prefixes the names of markers recording configuration values read by workflow code, see
+// workflow.GetConfigValue.
+const ConfigMarkerPrefix = "config:"
+
+// ConfigSource resolves configuration values for workflow code.
+type ConfigSource func(key string) string
+
+// SetConfigSource sets the source of configuration values. If not set, values are read from environment
+// variables.
+func (wf *WfState) SetConfigSource(source ConfigSource) {
+	wf.configSource = source
+}
+
+// ResolveConfigValue reads the current value of the given key from the configuration source.
+func (wf *WfState) ResolveConfigValue(key string) string {
+	if wf.configSource == nil {
+		return os.Getenv(key)
+	}
+
+	return wf.configSource(key)
+}
+
+// ConfigValue returns the last value of the given key seen by the workflow.
+func (wf *WfState) ConfigValue(key string) (string, bool) {
+	v, ok := wf.configValues[key]
+	return v, ok
+}
+
+func (wf *WfState) SetConfigValue(key, value string) {
+	wf.configValues[key] = value
+}
+
+// TrackRecordedConfigValues remembers the configuration values recorded in the given history events, so that
+// replaying workflow code can tell whether a value was recorded at a given schedule event id.
+func (wf *WfState) TrackRecordedConfigValues(events []history.Event) {
+	for i := range events {
+		if events[i].Type != history.EventType_MarkerRecorded {
+			continue
+		}
+
+		a, err := history.AttributesAs[*history.MarkerRecordedAttributes](&events[i])
+		if err != nil || !strings.HasPrefix(a.Name, ConfigMarkerPrefix) {
+			// Invalid attributes are surfaced when the event is executed
+			continue
+		}
+
+		wf.recordedConfigValues[events[i].ScheduleEventID] = a.Details
+	}
+}
+
+// RecordedConfigValue returns the configuration value recorded for the given schedule event id, if any.
+func (wf *WfState) RecordedConfigValue(scheduleEventID int64) (payload.Payload, bool) {
+	p, ok := wf.recordedConfigValues[scheduleEventID]
+	if ok {
+		delete(wf.recordedConfigValues, scheduleEventID)
+	}
+
+	return p, ok
+}
@@ -0,0 +1,13 @@
+package workflowstate
+
+import "github.com/cschleiden/go-workflows/internal/payload"
+
+// ContinueAsNewError is returned by a workflow to complete the current execution of its instance, and to start a
+// new execution of the same workflow with the given inputs.
+type ContinueAsNewError struct {
+	Inputs []payload.Payload
+}
+
+func (e *ContinueAsNewError) Error() string {
+	return "workflow continued as new"
+}
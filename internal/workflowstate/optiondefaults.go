@@ -0,0 +1,24 @@
+package workflowstate
+
+// OptionDefaults are default options for activities and sub-workflows, keyed by activity or workflow name. The
+// values are the option types of the workflow package, which cannot be referenced here.
+type OptionDefaults struct {
+	Activities   map[string]interface{}
+	SubWorkflows map[string]interface{}
+}
+
+func (wf *WfState) SetOptionDefaults(defaults OptionDefaults) {
+	wf.optionDefaults = defaults
+}
+
+// ActivityOptionDefaults returns the default options registered for the given activity, if any.
+func (wf *WfState) ActivityOptionDefaults(activityName string) (interface{}, bool) {
+	o, ok := wf.optionDefaults.Activities[activityName]
+	return o, ok
+}
+
+// SubWorkflowOptionDefaults returns the default options registered for the given workflow, if any.
+func (wf *WfState) SubWorkflowOptionDefaults(workflowName string) (interface{}, bool) {
+	o, ok := wf.optionDefaults.SubWorkflows[workflowName]
+	return o, ok
+}
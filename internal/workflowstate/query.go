@@ -0,0 +1,31 @@
+package workflowstate
+
+import "github.com/cschleiden/go-workflows/internal/payload"
+
+// QueryActivityName is the name of the activity built into workers that queries other workflow instances.
+const QueryActivityName = "go-workflows:QueryWorkflow"
+
+// QueryRequest is the input of the built-in query activity.
+type QueryRequest struct {
+	InstanceID string            `json:"instance_id"`
+	Name       string            `json:"name"`
+	Inputs     []payload.Payload `json:"inputs,omitempty"`
+}
+
+// QueryHandler answers a query with the current state of the workflow. It's called outside of the workflow
+// coroutines, so it must not block.
+type QueryHandler func(inputs []payload.Payload) (payload.Payload, error)
+
+func (wf *WfState) SetQueryHandler(name string, handler QueryHandler) {
+	if wf.queryHandlers == nil {
+		wf.queryHandlers = map[string]QueryHandler{}
+	}
+
+	wf.queryHandlers[name] = handler
+}
+
+// QueryHandler returns the query handler registered under the given name, if any.
+func (wf *WfState) QueryHandler(name string) (QueryHandler, bool) {
+	h, ok := wf.queryHandlers[name]
+	return h, ok
+}
@@ -0,0 +1,19 @@
+package workflowstate
+
+import "math/rand"
+
+// SetRandomSeed seeds the random number generator of the workflow with the seed recorded when the execution
+// was started.
+func (wf *WfState) SetRandomSeed(seed int64) {
+	wf.random = rand.New(rand.NewSource(seed))
+}
+
+// Random returns the deterministic random number generator of the workflow.
+func (wf *WfState) Random() *rand.Rand {
+	if wf.random == nil {
+		// Executions started before seeds were recorded
+		wf.SetRandomSeed(0)
+	}
+
+	return wf.random
+}
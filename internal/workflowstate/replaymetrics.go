@@ -0,0 +1,50 @@
+package workflowstate
+
+import (
+	"time"
+
+	"github.com/cschleiden/go-workflows/metrics"
+)
+
+type replayMetricsClient struct {
+	state  *WfState
+	client metrics.Client
+}
+
+// NewReplayMetricsClient returns a metrics client that only emits metrics while the workflow is not replaying.
+func NewReplayMetricsClient(state *WfState, client metrics.Client) metrics.Client {
+	return &replayMetricsClient{state, client}
+}
+
+// Counter implements metrics.Client
+func (r *replayMetricsClient) Counter(name string, tags metrics.Tags, value int64) {
+	if !r.state.replaying {
+		r.client.Counter(name, tags, value)
+	}
+}
+
+// Distribution implements metrics.Client
+func (r *replayMetricsClient) Distribution(name string, tags metrics.Tags, value float64) {
+	if !r.state.replaying {
+		r.client.Distribution(name, tags, value)
+	}
+}
+
+// Gauge implements metrics.Client
+func (r *replayMetricsClient) Gauge(name string, tags metrics.Tags, value int64) {
+	if !r.state.replaying {
+		r.client.Gauge(name, tags, value)
+	}
+}
+
+// Timing implements metrics.Client
+func (r *replayMetricsClient) Timing(name string, tags metrics.Tags, duration time.Duration) {
+	if !r.state.replaying {
+		r.client.Timing(name, tags, duration)
+	}
+}
+
+// WithTags implements metrics.Client
+func (r *replayMetricsClient) WithTags(tags metrics.Tags) metrics.Client {
+	return NewReplayMetricsClient(r.state, r.client.WithTags(tags))
+}
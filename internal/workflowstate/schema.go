@@ -0,0 +1,103 @@
+package workflowstate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+// SchemaMismatchError is returned when a payload recorded in the history, e.g., the result of an activity,
+// can't be decoded into the type the workflow expects, usually because the type has changed since the
+// payload was recorded.
+type SchemaMismatchError struct {
+	// Type is the Go type the payload was decoded into.
+	Type string
+
+	// Field is the path of the field that doesn't match, e.g., "Order.Total". It's empty if the payload
+	// doesn't match the type as a whole.
+	Field string
+
+	// Value describes the JSON value found in the payload, e.g., "string".
+	Value string
+
+	// EventID, EventType, and SequenceID identify the history event holding the payload.
+	EventID    string
+	EventType  string
+	SequenceID int64
+
+	Err error
+}
+
+func (e *SchemaMismatchError) Error() string {
+	field := e.Field
+	if field == "" {
+		field = "<value>"
+	}
+
+	return fmt.Sprintf("payload of %s event %s (sequence id %d) does not match type %s: field %s is a %s: %v",
+		e.EventType, e.EventID, e.SequenceID, e.Type, field, e.Value, e.Err)
+}
+
+func (e *SchemaMismatchError) Unwrap() error {
+	return e.Err
+}
+
+// SetLenientDecoding configures whether payloads that don't match the expected type are decoded as far
+// as possible, with zero values for the fields that don't match, instead of returning a SchemaMismatchError.
+func (wf *WfState) SetLenientDecoding(lenient bool) {
+	wf.lenientDecoding = lenient
+}
+
+// SetDecodingEvent sets the history event whose payloads are currently being decoded. It's reported in
+// SchemaMismatchErrors.
+func (wf *WfState) SetDecodingEvent(id, eventType string, sequenceID int64) {
+	wf.decodingEvent = decodingEvent{id, eventType, sequenceID}
+}
+
+type decodingEvent struct {
+	id         string
+	eventType  string
+	sequenceID int64
+}
+
+// decode decodes the given payload into a value of type T.
+func decode[T any](wf *WfState, v payload.Payload) (T, error) {
+	var t T
+
+	err := converter.DefaultConverter.From(v, &t)
+	if err == nil {
+		return t, nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		return t, fmt.Errorf("failed to decode future: %v", err)
+	}
+
+	mismatch := &SchemaMismatchError{
+		Type:  reflect.TypeOf(&t).Elem().String(),
+		Field: typeErr.Field,
+		Value: typeErr.Value,
+		Err:   err,
+	}
+
+	if wf != nil {
+		mismatch.EventID = wf.decodingEvent.id
+		mismatch.EventType = wf.decodingEvent.eventType
+		mismatch.SequenceID = wf.decodingEvent.sequenceID
+	}
+
+	if wf != nil && wf.lenientDecoding {
+		// The JSON decoder skips mismatching fields and decodes the rest
+		wf.schemaLogger.Warn("Payload does not match type, using zero value for mismatching field",
+			"type", mismatch.Type, "field", mismatch.Field, "value", mismatch.Value)
+
+		return t, nil
+	}
+
+	return t, mismatch
+}
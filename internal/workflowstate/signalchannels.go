@@ -6,7 +6,16 @@ import (
 	"github.com/cschleiden/go-workflows/internal/sync"
 )
 
+// SignalHandler is called with the argument of every signal it's registered for. It's called outside of the
+// workflow coroutines, so it must not block.
+type SignalHandler func(arg payload.Payload)
+
 func ReceiveSignal(wf *WfState, name string, arg payload.Payload) {
+	if h, ok := wf.signalHandlers[name]; ok {
+		h(arg)
+		return
+	}
+
 	sc, ok := wf.signalChannels[name]
 	if ok {
 		sc.receive(arg)
@@ -22,6 +31,22 @@ func ReceiveSignal(wf *WfState, name string, arg payload.Payload) {
 	wf.pendingSignals[name] = append(ps, arg)
 }
 
+// SetSignalHandler registers handler for the signals with the given name. Signals that arrived before are passed to
+// the handler right away, in the order they arrived.
+func (wf *WfState) SetSignalHandler(name string, handler SignalHandler) {
+	if wf.signalHandlers == nil {
+		wf.signalHandlers = map[string]SignalHandler{}
+	}
+
+	wf.signalHandlers[name] = handler
+
+	for _, arg := range wf.pendingSignals[name] {
+		handler(arg)
+	}
+
+	delete(wf.pendingSignals, name)
+}
+
 func GetSignalChannel[T any](ctx sync.Context, wf *WfState, name string) sync.Channel[T] {
 	// Check for existing channel, if exists return
 	sc, ok := wf.signalChannels[name]
@@ -35,6 +60,11 @@ func GetSignalChannel[T any](ctx sync.Context, wf *WfState, name string) sync.Ch
 	// Add channel to map
 	wf.signalChannels[name] = &signalChannel{
 		receive: func(input payload.Payload) {
+			// Drop signals for channels that have been closed
+			if ci, ok := c.(sync.ChannelInternal[T]); ok && ci.Closed() {
+				return
+			}
+
 			var t T
 			if err := converter.DefaultConverter.From(input, &t); err != nil {
 				panic(err)
@@ -0,0 +1,49 @@
+package workflowstate
+
+import (
+	"strings"
+
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+// VersionMarkerPrefix prefixes the names of markers recording the versions returned by workflow.GetVersion.
+const VersionMarkerPrefix = "version:"
+
+// Version returns the version of the given change used by the workflow, if it has been determined.
+func (wf *WfState) Version(changeID string) (int, bool) {
+	v, ok := wf.versions[changeID]
+	return v, ok
+}
+
+func (wf *WfState) SetVersion(changeID string, version int) {
+	wf.versions[changeID] = version
+}
+
+// TrackRecordedVersions remembers the versions recorded in the given history events, so that replaying workflow
+// code can tell whether a version was recorded for a change.
+func (wf *WfState) TrackRecordedVersions(events []history.Event) {
+	for i := range events {
+		if events[i].Type != history.EventType_MarkerRecorded {
+			continue
+		}
+
+		a, err := history.AttributesAs[*history.MarkerRecordedAttributes](&events[i])
+		if err != nil || !strings.HasPrefix(a.Name, VersionMarkerPrefix) {
+			// Invalid attributes are surfaced when the event is executed
+			continue
+		}
+
+		wf.recordedVersions[strings.TrimPrefix(a.Name, VersionMarkerPrefix)] = a.Details
+	}
+}
+
+// RecordedVersion returns the version recorded for the given change, if any.
+func (wf *WfState) RecordedVersion(changeID string) (payload.Payload, bool) {
+	p, ok := wf.recordedVersions[changeID]
+	if ok {
+		delete(wf.recordedVersions, changeID)
+	}
+
+	return p, ok
+}
@@ -2,15 +2,16 @@ package workflowstate
 
 import (
 	"fmt"
+	"math/rand"
 	"time"
 
 	"github.com/benbjohnson/clock"
 	"github.com/cschleiden/go-workflows/internal/command"
-	"github.com/cschleiden/go-workflows/internal/converter"
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/payload"
 	"github.com/cschleiden/go-workflows/internal/sync"
 	"github.com/cschleiden/go-workflows/log"
+	"github.com/cschleiden/go-workflows/metrics"
 )
 
 type key int
@@ -20,17 +21,18 @@ var workflowCtxKey key
 type DecodingSettable func(v payload.Payload, err error) error
 
 // Use this to track futures for the workflow state. It's required to map the generic Future interface
-// to a type without type parameters.
-func AsDecodingSettable[T any](f sync.SettableFuture[T]) DecodingSettable {
+// to a type without type parameters. Payloads that don't match T result in a *SchemaMismatchError, unless
+// lenient decoding is enabled for the given workflow state.
+func AsDecodingSettable[T any](wf *WfState, f sync.SettableFuture[T]) DecodingSettable {
 	return func(v payload.Payload, err error) error {
 		if f.HasValue() {
 			return fmt.Errorf("future already has value")
 		}
 
 		if v != nil {
-			var t T
-			if err := converter.DefaultConverter.From(v, &t); err != nil {
-				return fmt.Errorf("failed to decode future: %v", err)
+			t, derr := decode[T](wf, v)
+			if derr != nil {
+				return derr
 			}
 			f.Set(t, err)
 		} else {
@@ -46,6 +48,10 @@ type signalChannel struct {
 	channel interface{}
 }
 
+// SubWorkflowInstanceIDFunc derives the instance ID of a sub-workflow from its parent. n is the number of
+// sub-workflows with the given name the parent execution has started so far, starting at 1.
+type SubWorkflowInstanceIDFunc func(parentInstanceID, parentExecutionID, name string, n int) string
+
 type WfState struct {
 	instance        *core.WorkflowInstance
 	scheduleEventID int64
@@ -53,16 +59,49 @@ type WfState struct {
 	pendingFutures  map[int64]DecodingSettable
 	replaying       bool
 
+	workflowName string
+	startedAt    time.Time
+	attempt      int
+
+	cancellation *Cancellation
+
 	pendingSignals map[string][]payload.Payload
 	signalChannels map[string]*signalChannel
+	signalHandlers map[string]SignalHandler
+
+	subWorkflowInstanceID SubWorkflowInstanceIDFunc
+	subWorkflowCounts     map[string]int
+
+	namedTimers map[string]int64
+
+	activityInterceptors []ActivityInterceptor
+
+	optionDefaults OptionDefaults
+
+	queryHandlers map[string]QueryHandler
+
+	configSource         ConfigSource
+	configValues         map[string]string
+	recordedConfigValues map[int64]payload.Payload
+
+	versions         map[string]int
+	recordedVersions map[string]payload.Payload
+
+	random *rand.Rand
+
+	lenientDecoding bool
+	decodingEvent   decodingEvent
 
 	logger log.Logger
+	// schemaLogger logs schema mismatches, also during replay
+	schemaLogger log.Logger
+	metrics      metrics.Client
 
 	clock clock.Clock
 	time  time.Time
 }
 
-func NewWorkflowState(instance *core.WorkflowInstance, logger log.Logger, clock clock.Clock) *WfState {
+func NewWorkflowState(instance *core.WorkflowInstance, logger log.Logger, metrics metrics.Client, clock clock.Clock) *WfState {
 	state := &WfState{
 		instance:        instance,
 		commands:        []command.Command{},
@@ -72,12 +111,25 @@ func NewWorkflowState(instance *core.WorkflowInstance, logger log.Logger, clock
 		pendingSignals: map[string][]payload.Payload{},
 		signalChannels: make(map[string]*signalChannel),
 
+		subWorkflowCounts: map[string]int{},
+
+		namedTimers: map[string]int64{},
+
+		configValues:         map[string]string{},
+		recordedConfigValues: map[int64]payload.Payload{},
+
+		versions:         map[string]int{},
+		recordedVersions: map[string]payload.Payload{},
+
 		clock: clock,
 	}
 
-	state.logger = NewReplayLogger(state, logger.With(
+	state.schemaLogger = logger.With(
 		"instance_id", instance.InstanceID,
-		"execution_id", instance.ExecutionID))
+		"execution_id", instance.ExecutionID)
+	state.logger = NewReplayLogger(state, state.schemaLogger)
+
+	state.metrics = NewReplayMetricsClient(state, metrics)
 
 	return state
 }
@@ -104,6 +156,20 @@ func (wf *WfState) HasPendingFutures() bool {
 	return len(wf.pendingFutures) > 0
 }
 
+func (wf *WfState) PendingFutures() int {
+	return len(wf.pendingFutures)
+}
+
+// SetNamedTimer associates the timer with the given schedule event id with a name.
+func (wf *WfState) SetNamedTimer(name string, scheduleEventID int64) {
+	wf.namedTimers[name] = scheduleEventID
+}
+
+func (wf *WfState) NamedTimer(name string) (int64, bool) {
+	id, ok := wf.namedTimers[name]
+	return id, ok
+}
+
 func (wf *WfState) FutureByScheduleEventID(scheduleEventID int64) (DecodingSettable, bool) {
 	f, ok := wf.pendingFutures[scheduleEventID]
 	return f, ok
@@ -139,6 +205,50 @@ func (wf *WfState) Replaying() bool {
 	return wf.replaying
 }
 
+// SetExecutionStarted records information from the event that started the workflow instance.
+func (wf *WfState) SetExecutionStarted(workflowName string, startedAt time.Time, attempt int) {
+	wf.workflowName = workflowName
+	wf.startedAt = startedAt
+	wf.attempt = attempt
+}
+
+func (wf *WfState) WorkflowName() string {
+	return wf.workflowName
+}
+
+func (wf *WfState) StartedAt() time.Time {
+	return wf.startedAt
+}
+
+func (wf *WfState) Attempt() int {
+	return wf.attempt
+}
+
+// Cancellation describes why a workflow instance has been canceled.
+type Cancellation struct {
+	// Cause is the cause the workflow context was canceled with, e.g., core.ErrCanceledByUser.
+	Cause error
+
+	// Message and Details are optionally attached by the client canceling the instance.
+	Message string
+	Details payload.Payload
+}
+
+// SetCancellation records that the workflow instance has been canceled.
+func (wf *WfState) SetCancellation(c *Cancellation) {
+	wf.cancellation = c
+}
+
+// Cancellation returns why the workflow instance has been canceled, or nil if it hasn't been canceled.
+func (wf *WfState) Cancellation() *Cancellation {
+	return wf.cancellation
+}
+
+// CancellationRequested returns true once the workflow instance has been canceled.
+func (wf *WfState) CancellationRequested() bool {
+	return wf.cancellation != nil
+}
+
 func (wf *WfState) SetTime(t time.Time) {
 	wf.time = t
 }
@@ -154,3 +264,23 @@ func (wf *WfState) Instance() *core.WorkflowInstance {
 func (wf *WfState) Logger() log.Logger {
 	return wf.logger
 }
+
+func (wf *WfState) Metrics() metrics.Client {
+	return wf.metrics
+}
+
+func (wf *WfState) SetSubWorkflowInstanceIDFunc(f SubWorkflowInstanceIDFunc) {
+	wf.subWorkflowInstanceID = f
+}
+
+// NextSubWorkflowInstanceID returns the instance ID for the next sub-workflow with the given name, or an empty
+// string if no SubWorkflowInstanceIDFunc is configured.
+func (wf *WfState) NextSubWorkflowInstanceID(name string) string {
+	if wf.subWorkflowInstanceID == nil {
+		return ""
+	}
+
+	wf.subWorkflowCounts[name]++
+
+	return wf.subWorkflowInstanceID(wf.instance.InstanceID, wf.instance.ExecutionID, name, wf.subWorkflowCounts[name])
+}
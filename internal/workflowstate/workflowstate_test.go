@@ -7,6 +7,7 @@ import (
 	"github.com/cschleiden/go-workflows/internal/converter"
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/logger"
+	"github.com/cschleiden/go-workflows/internal/metrics"
 	"github.com/cschleiden/go-workflows/internal/payload"
 	"github.com/cschleiden/go-workflows/internal/sync"
 	"github.com/google/uuid"
@@ -16,7 +17,7 @@ import (
 func Test_PendingFutures(t *testing.T) {
 	i := core.NewWorkflowInstance(uuid.NewString(), "")
 
-	wfState := NewWorkflowState(i, logger.NewDefaultLogger(), clock.New())
+	wfState := NewWorkflowState(i, logger.NewDefaultLogger(), metrics.NewNoopMetricsClient(), clock.New())
 
 	require.False(t, wfState.HasPendingFutures())
 
@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"expvar"
+	"strings"
+	"sync"
+	"time"
+)
+
+// expvarMu guards publishing expvar maps and variables in them, which might be shared by multiple clients
+// publishing under the same name.
+var expvarMu sync.Mutex
+
+type expvarClient struct {
+	vars *expvar.Map
+	next Client
+}
+
+// NewExpvarClient returns a client publishing metrics as the expvar map with the given name, served at
+// /debug/vars by the expvar package. Metrics are aggregated across tags: counters are summed, gauges hold the
+// last value, and distributions and timings are published as their count and sum. For every pair of
+// counters ending in .hit and .miss, e.g., for caches, the ratio of hits is published as .hit_rate.
+//
+// All metrics are also sent to next, if it's not nil, so expvar can be used next to another metrics client.
+func NewExpvarClient(name string, next Client) Client {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	vars, ok := expvar.Get(name).(*expvar.Map)
+	if !ok {
+		vars = expvar.NewMap(name)
+	}
+
+	return &expvarClient{
+		vars: vars,
+		next: next,
+	}
+}
+
+func (c *expvarClient) Counter(name string, tags Tags, value int64) {
+	c.vars.Add(name, value)
+
+	for _, suffix := range []string{".hit", ".miss"} {
+		if prefix := strings.TrimSuffix(name, suffix); prefix != name {
+			c.publishHitRate(prefix)
+		}
+	}
+
+	if c.next != nil {
+		c.next.Counter(name, tags, value)
+	}
+}
+
+func (c *expvarClient) Distribution(name string, tags Tags, value float64) {
+	c.vars.Add(name+".count", 1)
+	c.vars.AddFloat(name+".sum", value)
+
+	if c.next != nil {
+		c.next.Distribution(name, tags, value)
+	}
+}
+
+func (c *expvarClient) Gauge(name string, tags Tags, value int64) {
+	expvarMu.Lock()
+	v, ok := c.vars.Get(name).(*expvar.Int)
+	if !ok {
+		v = new(expvar.Int)
+		c.vars.Set(name, v)
+	}
+	expvarMu.Unlock()
+
+	v.Set(value)
+
+	if c.next != nil {
+		c.next.Gauge(name, tags, value)
+	}
+}
+
+func (c *expvarClient) Timing(name string, tags Tags, duration time.Duration) {
+	c.vars.Add(name+".count", 1)
+	c.vars.AddFloat(name+".sum_ms", float64(duration)/float64(time.Millisecond))
+
+	if c.next != nil {
+		c.next.Timing(name, tags, duration)
+	}
+}
+
+func (c *expvarClient) WithTags(tags Tags) Client {
+	var next Client
+	if c.next != nil {
+		next = c.next.WithTags(tags)
+	}
+
+	return &expvarClient{
+		vars: c.vars,
+		next: next,
+	}
+}
+
+func (c *expvarClient) publishHitRate(prefix string) {
+	name := prefix + ".hit_rate"
+
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	if c.vars.Get(name) != nil {
+		return
+	}
+
+	c.vars.Set(name, expvar.Func(func() interface{} {
+		hits := c.intValue(prefix + ".hit")
+		total := hits + c.intValue(prefix+".miss")
+		if total == 0 {
+			return 0.0
+		}
+
+		return float64(hits) / float64(total)
+	}))
+}
+
+func (c *expvarClient) intValue(name string) int64 {
+	if v, ok := c.vars.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+
+	return 0
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type countingClient struct {
+	counters map[string]int64
+	tags     Tags
+}
+
+func (c *countingClient) Counter(name string, tags Tags, value int64) {
+	c.counters[name] += value
+}
+
+func (c *countingClient) Distribution(name string, tags Tags, value float64) {}
+
+func (c *countingClient) Gauge(name string, tags Tags, value int64) {}
+
+func (c *countingClient) Timing(name string, tags Tags, duration time.Duration) {}
+
+func (c *countingClient) WithTags(tags Tags) Client {
+	return &countingClient{counters: c.counters, tags: tags}
+}
+
+func Test_ExpvarClient(t *testing.T) {
+	next := &countingClient{counters: map[string]int64{}}
+	c := NewExpvarClient("Test_ExpvarClient", next)
+
+	c.Counter("cache.hit", Tags{"a": "1"}, 3)
+	c.WithTags(Tags{"b": "2"}).Counter("cache.miss", Tags{}, 1)
+	c.Gauge("size", Tags{}, 5)
+	c.Gauge("size", Tags{}, 7)
+	c.Distribution("input", Tags{}, 2)
+	c.Distribution("input", Tags{}, 4)
+	c.Timing("task", Tags{}, 1500*time.Microsecond)
+
+	var vars map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get("Test_ExpvarClient").String()), &vars))
+
+	require.Equal(t, 3.0, vars["cache.hit"])
+	require.Equal(t, 1.0, vars["cache.miss"])
+	require.Equal(t, 0.75, vars["cache.hit_rate"])
+	require.Equal(t, 7.0, vars["size"])
+	require.Equal(t, 2.0, vars["input.count"])
+	require.Equal(t, 6.0, vars["input.sum"])
+	require.Equal(t, 1.0, vars["task.count"])
+	require.Equal(t, 1.5, vars["task.sum_ms"])
+
+	// Metrics are forwarded to the next client
+	require.Equal(t, map[string]int64{"cache.hit": 3, "cache.miss": 1}, next.counters)
+
+	// Clients with the same name share their variables
+	NewExpvarClient("Test_ExpvarClient", nil).Counter("cache.hit", Tags{}, 1)
+	require.Equal(t, "4", expvar.Get("Test_ExpvarClient").(*expvar.Map).Get("cache.hit").String())
+}
+
+func Test_ExpvarClient_Concurrent(t *testing.T) {
+	// Clients created concurrently with the same name don't publish the map twice, which panics
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			NewExpvarClient("Test_ExpvarClient_Concurrent", nil).Gauge("size", Tags{}, 1)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, "1", expvar.Get("Test_ExpvarClient_Concurrent").(*expvar.Map).Get("size").String())
+}
@@ -0,0 +1,69 @@
+package projection
+
+import (
+	"context"
+	"sync"
+)
+
+type memoryStore struct {
+	mu         sync.RWMutex
+	models     map[string]map[string][]byte
+	checkpoint []byte
+}
+
+// NewMemoryStore returns a store keeping read models in memory, useful for testing.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		models: map[string]map[string][]byte{},
+	}
+}
+
+func (s *memoryStore) Get(ctx context.Context, model, key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.models[model][key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return data, nil
+}
+
+func (s *memoryStore) List(ctx context.Context, model string) (map[string][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r := make(map[string][]byte, len(s.models[model]))
+	for key, data := range s.models[model] {
+		r[key] = data
+	}
+
+	return r, nil
+}
+
+func (s *memoryStore) Checkpoint(ctx context.Context) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.checkpoint, nil
+}
+
+func (s *memoryStore) Commit(ctx context.Context, entries []Entry, checkpoint []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range entries {
+		m, ok := s.models[e.Model]
+		if !ok {
+			m = map[string][]byte{}
+			s.models[e.Model] = m
+		}
+
+		m[e.Key] = e.Data
+	}
+
+	s.checkpoint = checkpoint
+
+	return nil
+}
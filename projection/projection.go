@@ -0,0 +1,330 @@
+// Package projection maintains materialized read models from the history events of workflow instances, e.g., to
+// back custom business dashboards without scanning raw history.
+//
+// Read models are defined by reducers that are registered with a Projector. The projector feeds history events to
+// the reducers incrementally in the order they were committed, including the events of running instances, and
+// commits the updated read model entries together with its checkpoint, so that every event is applied exactly once
+// if the Store commits atomically.
+//
+// Backends need to implement backend.CommittedEventLister, the SQLite and MySQL backends do. The Redis backend
+// doesn't, updating read models from it returns backend.ErrCommittedEventsNotSupported.
+package projection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/historyfeed"
+)
+
+var ErrNotFound = errors.New("read model entry not found")
+
+// Event is a history event of a workflow instance passed to reducers.
+type Event struct {
+	Instance *core.WorkflowInstance
+
+	*history.Event
+}
+
+// Entry is an entry of a read model.
+type Entry struct {
+	Model string
+	Key   string
+	Data  []byte
+}
+
+// Store holds read models and the checkpoint of a projector.
+type Store interface {
+	// Get returns the data of the given entry, or ErrNotFound.
+	Get(ctx context.Context, model, key string) ([]byte, error)
+
+	// List returns all entries of the given read model, keyed by their key.
+	List(ctx context.Context, model string) (map[string][]byte, error)
+
+	// Checkpoint returns the last committed checkpoint, or nil if nothing has been committed yet.
+	Checkpoint(ctx context.Context) ([]byte, error)
+
+	// Commit writes the given entries and checkpoint. Implementations should do this atomically, otherwise
+	// events might be applied more than once after a failure.
+	Commit(ctx context.Context, entries []Entry, checkpoint []byte) error
+}
+
+type projection struct {
+	key    func(e *Event) string
+	reduce func(data []byte, e *Event) ([]byte, error)
+}
+
+type options struct {
+	batchSize   int
+	settleDelay time.Duration
+}
+
+type Option func(*options)
+
+// WithBatchSize sets the number of events applied and committed together. Defaults to 1000.
+func WithBatchSize(n int) Option {
+	return func(o *options) {
+		o.batchSize = n
+	}
+}
+
+// WithSettleDelay sets how long ago events need to have been committed to be applied, so that events committed
+// concurrently are not skipped. Defaults to one minute.
+func WithSettleDelay(d time.Duration) Option {
+	return func(o *options) {
+		o.settleDelay = d
+	}
+}
+
+type Projector struct {
+	backend     backend.Backend
+	store       Store
+	options     *options
+	projections map[string]*projection
+}
+
+// NewProjector returns a projector maintaining read models in store from the history events of all instances of
+// the given backend.
+func NewProjector(backend backend.Backend, store Store, opts ...Option) *Projector {
+	o := &options{
+		batchSize:   1000,
+		settleDelay: historyfeed.DefaultSettleDelay,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &Projector{
+		backend:     backend,
+		store:       store,
+		options:     o,
+		projections: map[string]*projection{},
+	}
+}
+
+// Register adds the read model with the given name to the projector. For every history event, key returns the
+// key of the entry the event applies to, or an empty string if the event doesn't change the read model. reduce
+// then returns the new state of that entry, starting from the zero value of T. States are stored as JSON.
+//
+// Read models have to be registered before the projector is started. Events that were already processed are
+// not applied to read models registered later.
+func Register[T any](p *Projector, name string, key func(e *Event) string, reduce func(state T, e *Event) (T, error)) error {
+	if name == "" {
+		return errors.New("read model name must not be empty")
+	}
+
+	if _, ok := p.projections[name]; ok {
+		return fmt.Errorf("read model %s already registered", name)
+	}
+
+	p.projections[name] = &projection{
+		key: key,
+		reduce: func(data []byte, e *Event) ([]byte, error) {
+			var state T
+			if data != nil {
+				if err := json.Unmarshal(data, &state); err != nil {
+					return nil, fmt.Errorf("unmarshaling state: %w", err)
+				}
+			}
+
+			state, err := reduce(state, e)
+			if err != nil {
+				return nil, err
+			}
+
+			return json.Marshal(state)
+		},
+	}
+
+	return nil
+}
+
+// Get returns the entry with the given key of a read model, or ErrNotFound.
+func Get[T any](ctx context.Context, store Store, model, key string) (T, error) {
+	var state T
+
+	data, err := store.Get(ctx, model, key)
+	if err != nil {
+		return state, err
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("unmarshaling state: %w", err)
+	}
+
+	return state, nil
+}
+
+// List returns all entries of a read model, keyed by their key.
+func List[T any](ctx context.Context, store Store, model string) (map[string]T, error) {
+	entries, err := store.List(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+
+	r := make(map[string]T, len(entries))
+	for key, data := range entries {
+		var state T
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("unmarshaling state of %s: %w", key, err)
+		}
+
+		r[key] = state
+	}
+
+	return r, nil
+}
+
+type checkpoint struct {
+	// Cursor is the position after the last applied event, nil if nothing has been applied yet.
+	Cursor *backend.EventCursor `json:"cursor,omitempty"`
+}
+
+// Run applies new events every interval until the given context is canceled. Errors are logged, and
+// processing is retried from the last checkpoint in the next interval. It returns
+// backend.ErrCommittedEventsNotSupported right away if the backend can't list committed events.
+func (p *Projector) Run(ctx context.Context, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if _, err := p.Update(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			if errors.Is(err, backend.ErrCommittedEventsNotSupported) {
+				return err
+			}
+
+			p.backend.Logger().Error("updating read models", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// Update applies all events committed since the last checkpoint to the registered read models, and returns the
+// number of applied events. It returns backend.ErrCommittedEventsNotSupported if the backend can't list committed
+// events.
+func (p *Projector) Update(ctx context.Context) (int, error) {
+	cp, err := p.loadCheckpoint(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	f := &historyfeed.Feed{Backend: p.backend, BatchSize: p.options.batchSize, SettleDelay: p.options.settleDelay}
+
+	applied := 0
+	err = f.Read(ctx, cp.Cursor, func(events []*backend.CommittedEvent, next *backend.EventCursor) error {
+		b := &batch{store: p.store, entries: map[entryKey][]byte{}}
+
+		for _, e := range events {
+			if err := p.apply(ctx, b, &Event{Instance: e.Instance, Event: e.Event}); err != nil {
+				return err
+			}
+		}
+
+		data, err := json.Marshal(&checkpoint{Cursor: next})
+		if err != nil {
+			return fmt.Errorf("marshaling checkpoint: %w", err)
+		}
+
+		if err := p.store.Commit(ctx, b.changes(), data); err != nil {
+			return fmt.Errorf("committing read models: %w", err)
+		}
+
+		applied += len(events)
+
+		return nil
+	})
+
+	return applied, err
+}
+
+func (p *Projector) apply(ctx context.Context, b *batch, e *Event) error {
+	for name, pr := range p.projections {
+		key := pr.key(e)
+		if key == "" {
+			continue
+		}
+
+		data, err := b.get(ctx, name, key)
+		if err != nil {
+			return err
+		}
+
+		data, err = pr.reduce(data, e)
+		if err != nil {
+			return fmt.Errorf("reducing event %s into read model %s: %w", e.ID, name, err)
+		}
+
+		b.entries[entryKey{name, key}] = data
+	}
+
+	return nil
+}
+
+func (p *Projector) loadCheckpoint(ctx context.Context) (*checkpoint, error) {
+	cp := &checkpoint{}
+
+	data, err := p.store.Checkpoint(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading checkpoint: %w", err)
+	}
+
+	if data != nil {
+		if err := json.Unmarshal(data, cp); err != nil {
+			return nil, fmt.Errorf("unmarshaling checkpoint: %w", err)
+		}
+	}
+
+	return cp, nil
+}
+
+type entryKey struct {
+	model, key string
+}
+
+// batch holds the entries changed since the last commit.
+type batch struct {
+	store   Store
+	entries map[entryKey][]byte
+}
+
+func (b *batch) get(ctx context.Context, model, key string) ([]byte, error) {
+	if data, ok := b.entries[entryKey{model, key}]; ok {
+		return data, nil
+	}
+
+	data, err := b.store.Get(ctx, model, key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("getting read model entry: %w", err)
+	}
+
+	return data, nil
+}
+
+func (b *batch) changes() []Entry {
+	entries := make([]Entry, 0, len(b.entries))
+	for k, data := range b.entries {
+		entries = append(entries, Entry{Model: k.model, Key: k.key, Data: data})
+	}
+
+	return entries
+}
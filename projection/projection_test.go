@@ -0,0 +1,159 @@
+package projection
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/historyfeed/historyfeedtest"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+type instanceStatus struct {
+	Workflow string `json:"workflow"`
+	Finished bool   `json:"finished"`
+}
+
+func Test_Projector(t *testing.T) {
+	stores := []struct {
+		name  string
+		store func(t *testing.T) Store
+	}{
+		{
+			name: "Memory",
+			store: func(t *testing.T) Store {
+				return NewMemoryStore()
+			},
+		},
+		{
+			name: "SQL",
+			store: func(t *testing.T) Store {
+				db, err := sql.Open("sqlite3", ":memory:")
+				require.NoError(t, err)
+				db.SetMaxOpenConns(1)
+				t.Cleanup(func() { db.Close() })
+
+				require.NoError(t, CreateSQLTable(context.Background(), db, "read_models"))
+
+				return NewSQLStore(db, "read_models")
+			},
+		},
+	}
+
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := historyfeedtest.New(t)
+
+			store := s.store(t)
+
+			newProjector := func() *Projector {
+				p := NewProjector(f.Backend, store, WithBatchSize(1), WithSettleDelay(0))
+
+				require.NoError(t, Register(p, "started_by_workflow", func(e *Event) string {
+					if e.Type != history.EventType_WorkflowExecutionStarted {
+						return ""
+					}
+
+					a, _ := history.AttributesAs[*history.ExecutionStartedAttributes](e.Event)
+					return a.Name
+				}, func(count int, e *Event) (int, error) {
+					return count + 1, nil
+				}))
+
+				require.NoError(t, Register(p, "instance_status", func(e *Event) string {
+					return e.Instance.InstanceID
+				}, func(s instanceStatus, e *Event) (instanceStatus, error) {
+					switch e.Type {
+					case history.EventType_WorkflowExecutionStarted:
+						a, err := history.AttributesAs[*history.ExecutionStartedAttributes](e.Event)
+						if err != nil {
+							return s, err
+						}
+
+						s.Workflow = a.Name
+					case history.EventType_WorkflowExecutionFinished:
+						s.Finished = true
+					}
+
+					return s, nil
+				}))
+
+				return p
+			}
+
+			i1 := f.Run(t)
+			i2 := f.Run(t)
+
+			p := newProjector()
+			n, err := p.Update(ctx)
+			require.NoError(t, err)
+			require.Greater(t, n, 0)
+
+			counts, err := List[int](ctx, store, "started_by_workflow")
+			require.NoError(t, err)
+			require.Len(t, counts, 1)
+			for _, count := range counts {
+				require.Equal(t, 2, count)
+			}
+
+			status, err := Get[instanceStatus](ctx, store, "instance_status", i1.InstanceID)
+			require.NoError(t, err)
+			require.True(t, status.Finished)
+			require.NotEmpty(t, status.Workflow)
+
+			_, err = Get[instanceStatus](ctx, store, "instance_status", "unknown")
+			require.ErrorIs(t, err, ErrNotFound)
+
+			// Already applied events are not applied again, even by a new projector
+			n, err = newProjector().Update(ctx)
+			require.NoError(t, err)
+			require.Equal(t, 0, n)
+
+			f.Run(t)
+
+			_, err = newProjector().Update(ctx)
+			require.NoError(t, err)
+
+			counts, err = List[int](ctx, store, "started_by_workflow")
+			require.NoError(t, err)
+			for _, count := range counts {
+				require.Equal(t, 3, count)
+			}
+
+			status, err = Get[instanceStatus](ctx, store, "instance_status", i2.InstanceID)
+			require.NoError(t, err)
+			require.True(t, status.Finished)
+
+			// Events of running instances are applied without waiting for them to finish
+			running := f.Start(t)
+
+			_, err = newProjector().Update(ctx)
+			require.NoError(t, err)
+
+			status, err = Get[instanceStatus](ctx, store, "instance_status", running.InstanceID)
+			require.NoError(t, err)
+			require.False(t, status.Finished)
+			require.NotEmpty(t, status.Workflow)
+		})
+	}
+}
+
+func Test_Projector_NotSupported(t *testing.T) {
+	_, err := NewProjector(&backend.MockBackend{}, NewMemoryStore()).Update(context.Background())
+	require.ErrorIs(t, err, backend.ErrCommittedEventsNotSupported)
+}
+
+func Test_Register_RejectsDuplicateNames(t *testing.T) {
+	p := NewProjector(nil, NewMemoryStore())
+
+	key := func(e *Event) string { return "" }
+	reduce := func(s int, e *Event) (int, error) { return s, nil }
+
+	require.NoError(t, Register(p, "model", key, reduce))
+	require.Error(t, Register(p, "model", key, reduce))
+	require.Error(t, Register(p, "", key, reduce))
+}
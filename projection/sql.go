@@ -0,0 +1,115 @@
+package projection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// checkpointModel is the model of the row holding the checkpoint. Registered read models can't have an empty name.
+const checkpointModel = ""
+
+type sqlStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLStore returns a store keeping read models in the given table, which can be created with CreateSQLTable.
+// Queries use "?" placeholders, as supported by SQLite and MySQL. Read models are committed in a single
+// transaction together with the checkpoint.
+func NewSQLStore(db *sql.DB, table string) Store {
+	return &sqlStore{
+		db:    db,
+		table: table,
+	}
+}
+
+// CreateSQLTable creates the table for a store returned by NewSQLStore, if it doesn't exist yet.
+func CreateSQLTable(ctx context.Context, db *sql.DB, table string) error {
+	_, err := db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (model VARCHAR(191) NOT NULL, entry_key VARCHAR(191) NOT NULL, data BLOB NOT NULL, PRIMARY KEY (model, entry_key))",
+		table,
+	))
+
+	return err
+}
+
+func (s *sqlStore) Get(ctx context.Context, model, key string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRowContext(
+		ctx, fmt.Sprintf("SELECT data FROM %s WHERE model = ? AND entry_key = ?", s.table), model, key,
+	).Scan(&data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *sqlStore) List(ctx context.Context, model string) (map[string][]byte, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT entry_key, data FROM %s WHERE model = ?", s.table), model)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	r := map[string][]byte{}
+	for rows.Next() {
+		var key string
+		var data []byte
+		if err := rows.Scan(&key, &data); err != nil {
+			return nil, err
+		}
+
+		r[key] = data
+	}
+
+	return r, rows.Err()
+}
+
+func (s *sqlStore) Checkpoint(ctx context.Context) ([]byte, error) {
+	data, err := s.Get(ctx, checkpointModel, "")
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+
+	return data, err
+}
+
+func (s *sqlStore) Commit(ctx context.Context, entries []Entry, checkpoint []byte) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	put := func(model, key string, data []byte) error {
+		if _, err := tx.ExecContext(
+			ctx, fmt.Sprintf("DELETE FROM %s WHERE model = ? AND entry_key = ?", s.table), model, key,
+		); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(
+			ctx, fmt.Sprintf("INSERT INTO %s (model, entry_key, data) VALUES (?, ?, ?)", s.table), model, key, data,
+		)
+		return err
+	}
+
+	for _, e := range entries {
+		if err := put(e.Model, e.Key, e.Data); err != nil {
+			return fmt.Errorf("writing entry %s of read model %s: %w", e.Key, e.Model, err)
+		}
+	}
+
+	if err := put(checkpointModel, "", checkpoint); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/cschleiden/go-workflows/samples"
+	"github.com/cschleiden/go-workflows/simulator"
+)
+
+func main() {
+	options := simulator.DefaultOptions
+
+	flag.IntVar(&options.Workflows, "workflows", options.Workflows, "number of workflow instances to run")
+	flag.IntVar(&options.Concurrency, "concurrency", options.Concurrency, "number of concurrently running instances")
+	flag.IntVar(&options.ActivityFanOut, "fanout", options.ActivityFanOut, "activities per workflow")
+	flag.IntVar(&options.Timers, "timers", options.Timers, "timers per workflow")
+	flag.IntVar(&options.Signals, "signals", options.Signals, "signals per workflow")
+	flag.DurationVar(&options.SignalInterval, "signal-interval", options.SignalInterval, "time between signals to the same instance")
+
+	// Parses the flags
+	b := samples.GetBackend("simulation")
+
+	r, err := simulator.Run(context.Background(), b, &options)
+	if err != nil {
+		panic(err)
+	}
+
+	log.Println(r)
+}
@@ -0,0 +1,348 @@
+// Package simulator runs synthetic workflows against a backend and reports throughput and latency. It's
+// meant for sizing infrastructure before going to production, not for testing workflow logic.
+package simulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/client"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/worker"
+	"github.com/cschleiden/go-workflows/workflow"
+	"github.com/google/uuid"
+)
+
+// SignalName is the name of the signals sent to simulated workflow instances.
+const SignalName = "simulator-signal"
+
+type Options struct {
+	// Workflows is the total number of workflow instances to run. Defaults to 100.
+	Workflows int
+
+	// Concurrency is the maximum number of workflow instances running at the same time. Defaults to 10.
+	Concurrency int
+
+	// ActivityFanOut is the number of activities each workflow schedules in parallel.
+	ActivityFanOut int
+
+	// ActivityDuration is how long each simulated activity runs.
+	ActivityDuration time.Duration
+
+	// Timers is the number of timers each workflow waits on, one after the other.
+	Timers int
+
+	// TimerDuration is the duration of each timer.
+	TimerDuration time.Duration
+
+	// Signals is the number of signals sent to, and awaited by, each workflow.
+	Signals int
+
+	// SignalInterval is the time between two signals sent to the same workflow instance.
+	SignalInterval time.Duration
+
+	// Timeout is the maximum time to wait for a single workflow instance to finish. Defaults to one minute.
+	Timeout time.Duration
+
+	// WorkerOptions are used for the worker executing the simulated workflows. If nil, the default
+	// worker options are used.
+	WorkerOptions *worker.Options
+}
+
+var DefaultOptions = Options{
+	Workflows:      100,
+	Concurrency:    10,
+	ActivityFanOut: 5,
+	Timers:         1,
+	TimerDuration:  100 * time.Millisecond,
+	Signals:        1,
+	SignalInterval: 10 * time.Millisecond,
+	Timeout:        time.Minute,
+}
+
+// Spec describes the work done by a single simulated workflow instance.
+type Spec struct {
+	ActivityFanOut   int
+	ActivityDuration time.Duration
+	Timers           int
+	TimerDuration    time.Duration
+	Signals          int
+}
+
+type Latencies struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+type Report struct {
+	// Completed is the number of workflow instances that finished successfully.
+	Completed int
+
+	// Failed is the number of workflow instances that failed, or could not be started or awaited.
+	Failed int
+
+	// Elapsed is the wall clock time of the whole simulation.
+	Elapsed time.Duration
+
+	// Throughput is the number of completed workflow instances per second.
+	Throughput float64
+
+	// Latency of completed workflow instances, from creation until the instance finished.
+	Latency Latencies
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf(
+		"completed: %d, failed: %d, elapsed: %v, throughput: %.2f/s, latency p50: %v, p90: %v, p99: %v, max: %v",
+		r.Completed, r.Failed, r.Elapsed, r.Throughput, r.Latency.P50, r.Latency.P90, r.Latency.P99, r.Latency.Max,
+	)
+}
+
+// Run starts a worker for the given backend, executes the configured number of synthetic workflow instances,
+// and reports how the backend performed. The backend should not be shared with other workers while the
+// simulation is running.
+func Run(ctx context.Context, b backend.Backend, options *Options) (*Report, error) {
+	if options == nil {
+		options = &DefaultOptions
+	}
+
+	opts := *options
+	if opts.Workflows <= 0 {
+		opts.Workflows = DefaultOptions.Workflows
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultOptions.Concurrency
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultOptions.Timeout
+	}
+
+	w := worker.New(b, opts.WorkerOptions)
+
+	if err := w.RegisterWorkflow(SimulatedWorkflow); err != nil {
+		return nil, fmt.Errorf("registering workflow: %w", err)
+	}
+
+	if err := w.RegisterActivity(SimulatedActivity); err != nil {
+		return nil, fmt.Errorf("registering activity: %w", err)
+	}
+
+	workerCtx, cancelWorker := context.WithCancel(ctx)
+	if err := w.Start(workerCtx); err != nil {
+		cancelWorker()
+		return nil, fmt.Errorf("starting worker: %w", err)
+	}
+
+	s := &simulation{
+		b:       b,
+		c:       client.New(b),
+		options: opts,
+		spec: Spec{
+			ActivityFanOut:   opts.ActivityFanOut,
+			ActivityDuration: opts.ActivityDuration,
+			Timers:           opts.Timers,
+			TimerDuration:    opts.TimerDuration,
+			Signals:          opts.Signals,
+		},
+	}
+
+	r := s.run(ctx)
+
+	cancelWorker()
+	if err := w.WaitForCompletion(); err != nil {
+		return nil, fmt.Errorf("stopping worker: %w", err)
+	}
+
+	return r, nil
+}
+
+type simulation struct {
+	b       backend.Backend
+	c       client.Client
+	options Options
+	spec    Spec
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	failed    int
+}
+
+func (s *simulation) run(ctx context.Context) *Report {
+	start := time.Now()
+
+	sem := make(chan struct{}, s.options.Concurrency)
+	var wg sync.WaitGroup
+
+	for i := 0; i < s.options.Workflows; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			latency, err := s.runWorkflow(ctx)
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			if err != nil {
+				s.b.Logger().Warn("simulated workflow failed", "error", err)
+				s.failed++
+				return
+			}
+
+			s.latencies = append(s.latencies, latency)
+		}()
+	}
+
+	wg.Wait()
+
+	elapsed := time.Since(start)
+
+	r := &Report{
+		Completed: len(s.latencies),
+		Failed:    s.failed,
+		Elapsed:   elapsed,
+		Latency:   percentiles(s.latencies),
+	}
+
+	if elapsed > 0 {
+		r.Throughput = float64(r.Completed) / elapsed.Seconds()
+	}
+
+	return r
+}
+
+func (s *simulation) runWorkflow(ctx context.Context) (time.Duration, error) {
+	instance, err := s.c.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+		InstanceID: uuid.NewString(),
+	}, SimulatedWorkflow, s.spec)
+	if err != nil {
+		return 0, fmt.Errorf("creating workflow instance: %w", err)
+	}
+
+	for i := 0; i < s.spec.Signals; i++ {
+		if i > 0 && s.options.SignalInterval > 0 {
+			select {
+			case <-time.After(s.options.SignalInterval):
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		}
+
+		if err := s.c.SignalWorkflow(ctx, instance.InstanceID, SignalName, i); err != nil {
+			return 0, fmt.Errorf("signaling workflow instance: %w", err)
+		}
+	}
+
+	if _, err := client.GetWorkflowResult[int](ctx, s.c, instance, s.options.Timeout); err != nil {
+		return 0, fmt.Errorf("waiting for workflow instance: %w", err)
+	}
+
+	// Polling for the result adds delay, use the timestamps recorded in the history instead
+	h, err := s.b.GetWorkflowInstanceHistory(ctx, instance, nil)
+	if err != nil {
+		return 0, fmt.Errorf("getting workflow instance history: %w", err)
+	}
+
+	return historyLatency(h)
+}
+
+func historyLatency(h []history.Event) (time.Duration, error) {
+	var started, finished time.Time
+	for _, event := range h {
+		switch event.Type {
+		case history.EventType_WorkflowExecutionStarted:
+			started = event.Timestamp
+		case history.EventType_WorkflowExecutionFinished:
+			finished = event.Timestamp
+		}
+	}
+
+	if started.IsZero() || finished.IsZero() {
+		return 0, errors.New("history does not contain start and finish of workflow instance")
+	}
+
+	return finished.Sub(started), nil
+}
+
+// percentiles calculates latency percentiles using the nearest-rank method.
+func percentiles(latencies []time.Duration) Latencies {
+	if len(latencies) == 0 {
+		return Latencies{}
+	}
+
+	sorted := make([]time.Duration, len(latencies))
+	copy(sorted, latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := func(p int) time.Duration {
+		i := (p*len(sorted)+99)/100 - 1
+		if i < 0 {
+			i = 0
+		}
+
+		return sorted[i]
+	}
+
+	return Latencies{
+		P50: rank(50),
+		P90: rank(90),
+		P99: rank(99),
+		Max: sorted[len(sorted)-1],
+	}
+}
+
+// SimulatedWorkflow fans out to spec.ActivityFanOut activities, waits on spec.Timers timers, and then waits
+// for spec.Signals signals. It returns the number of signals received.
+func SimulatedWorkflow(ctx workflow.Context, spec Spec) (int, error) {
+	futures := make([]workflow.Future[int], 0, spec.ActivityFanOut)
+	for i := 0; i < spec.ActivityFanOut; i++ {
+		futures = append(futures, workflow.ExecuteActivity[int](ctx, workflow.DefaultActivityOptions, SimulatedActivity, i, spec.ActivityDuration))
+	}
+
+	for _, f := range futures {
+		if _, err := f.Get(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	for i := 0; i < spec.Timers; i++ {
+		if _, err := workflow.ScheduleTimer(ctx, spec.TimerDuration).Get(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	c := workflow.NewSignalChannel[int](ctx, SignalName)
+	for i := 0; i < spec.Signals; i++ {
+		c.Receive(ctx)
+	}
+
+	return spec.Signals, nil
+}
+
+// SimulatedActivity sleeps for the given duration to simulate work.
+func SimulatedActivity(ctx context.Context, i int, d time.Duration) (int, error) {
+	select {
+	case <-time.After(d):
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+
+	return i, nil
+}
@@ -0,0 +1,50 @@
+package simulator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend/sqlite"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Run(t *testing.T) {
+	b := sqlite.NewInMemoryBackend()
+
+	r, err := Run(context.Background(), b, &Options{
+		Workflows:      6,
+		Concurrency:    3,
+		ActivityFanOut: 3,
+		Timers:         1,
+		TimerDuration:  10 * time.Millisecond,
+		Signals:        2,
+		SignalInterval: time.Millisecond,
+		Timeout:        10 * time.Second,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, 6, r.Completed)
+	require.Equal(t, 0, r.Failed)
+	require.Greater(t, r.Throughput, 0.0)
+	require.GreaterOrEqual(t, r.Latency.P50, 10*time.Millisecond)
+	require.LessOrEqual(t, r.Latency.P50, r.Latency.P90)
+	require.LessOrEqual(t, r.Latency.P90, r.Latency.P99)
+	require.LessOrEqual(t, r.Latency.P99, r.Latency.Max)
+}
+
+func Test_Percentiles(t *testing.T) {
+	latencies := make([]time.Duration, 0, 100)
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	require.Equal(t, Latencies{
+		P50: 50 * time.Millisecond,
+		P90: 90 * time.Millisecond,
+		P99: 99 * time.Millisecond,
+		Max: 100 * time.Millisecond,
+	}, percentiles(latencies))
+
+	require.Equal(t, Latencies{}, percentiles(nil))
+}
@@ -18,10 +18,14 @@ import (
 	"github.com/cschleiden/go-workflows/internal/fn"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/logger"
+	mi "github.com/cschleiden/go-workflows/internal/metrics"
 	"github.com/cschleiden/go-workflows/internal/payload"
 	"github.com/cschleiden/go-workflows/internal/task"
 	"github.com/cschleiden/go-workflows/internal/workflow"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
 	"github.com/cschleiden/go-workflows/log"
+	"github.com/cschleiden/go-workflows/metrics"
+	"github.com/cschleiden/go-workflows/workflowerrors"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 	"go.opentelemetry.io/otel/trace"
@@ -84,6 +88,9 @@ type testWorkflow struct {
 type options struct {
 	TestTimeout time.Duration
 	Logger      log.Logger
+	Metrics     metrics.Client
+
+	SubWorkflowInstanceID workflowstate.SubWorkflowInstanceIDFunc
 }
 
 type workflowTester[TResult any] struct {
@@ -121,6 +128,8 @@ type workflowTester[TResult any] struct {
 	logger log.Logger
 
 	tracer trace.Tracer
+
+	metrics metrics.Client
 }
 
 type WorkflowTesterOption func(*options)
@@ -131,6 +140,18 @@ func WithLogger(logger log.Logger) WorkflowTesterOption {
 	}
 }
 
+func WithMetrics(client metrics.Client) WorkflowTesterOption {
+	return func(o *options) {
+		o.Metrics = client
+	}
+}
+
+func WithSubWorkflowInstanceID(f workflowstate.SubWorkflowInstanceIDFunc) WorkflowTesterOption {
+	return func(o *options) {
+		o.SubWorkflowInstanceID = f
+	}
+}
+
 func WithTestTimeout(timeout time.Duration) WorkflowTesterOption {
 	return func(o *options) {
 		o.TestTimeout = timeout
@@ -157,6 +178,10 @@ func NewWorkflowTester[TResult any](wf interface{}, opts ...WorkflowTesterOption
 		options.Logger = logger.NewDefaultLogger()
 	}
 
+	if options.Metrics == nil {
+		options.Metrics = mi.NewNoopMetricsClient()
+	}
+
 	tracer := trace.NewNoopTracerProvider().Tracer("workflow-tester")
 
 	wt := &workflowTester[TResult]{
@@ -180,8 +205,9 @@ func NewWorkflowTester[TResult any](wf interface{}, opts ...WorkflowTesterOption
 		timers:    make([]*testTimer, 0),
 		callbacks: make(chan func() *history.WorkflowEvent, 1024),
 
-		logger: options.Logger,
-		tracer: tracer,
+		logger:  options.Logger,
+		tracer:  tracer,
+		metrics: options.Metrics,
 	}
 
 	// Always register the workflow under test
@@ -252,7 +278,7 @@ func (wt *workflowTester[TResult]) Execute(args ...interface{}) {
 			tw.pendingEvents = tw.pendingEvents[:0]
 
 			// Execute task
-			e, err := workflow.NewExecutor(wt.logger, wt.tracer, wt.registry, &testHistoryProvider{tw.history}, tw.instance, wt.clock)
+			e, err := workflow.NewExecutor(wt.logger, wt.tracer, wt.metrics, wt.registry, &testHistoryProvider{tw.history}, tw.instance, wt.clock, wt.options.SubWorkflowInstanceID)
 			if err != nil {
 				panic("could not create workflow executor" + err.Error())
 			}
@@ -267,12 +293,21 @@ func (wt *workflowTester[TResult]) Execute(args ...interface{}) {
 			// Add all executed events to history
 			tw.history = append(tw.history, result.Executed...)
 
-			for _, event := range result.Executed {
+			for i := range result.Executed {
+				event := &result.Executed[i]
 				wt.logger.Debug("Event", "event_type", event.Type)
 
 				switch event.Type {
 				case history.EventType_WorkflowExecutionFinished:
-					a := event.Attributes.(*history.ExecutionCompletedAttributes)
+					a, err := history.AttributesAs[*history.ExecutionCompletedAttributes](event)
+					if err != nil {
+						panic("Could not read workflow result: " + err.Error())
+					}
+
+					if a.ContinuedAsNew != "" {
+						// The new execution is started below
+						continue
+					}
 
 					if !tw.instance.SubWorkflow() {
 						wt.workflowFinished = true
@@ -293,6 +328,14 @@ func (wt *workflowTester[TResult]) Execute(args ...interface{}) {
 
 				switch workflowEvent.HistoryEvent.Type {
 				case history.EventType_WorkflowExecutionStarted:
+					if workflowEvent.WorkflowInstance.InstanceID == tw.instance.InstanceID {
+						// The workflow continued as new, start the new execution with an empty history
+						tw.instance = workflowEvent.WorkflowInstance
+						tw.history = []history.Event{}
+						tw.pendingEvents = append(tw.pendingEvents, workflowEvent.HistoryEvent)
+						continue
+					}
+
 					wt.scheduleSubWorkflow(workflowEvent)
 
 				default:
@@ -301,6 +344,11 @@ func (wt *workflowTester[TResult]) Execute(args ...interface{}) {
 			}
 
 			for _, timerEvent := range result.TimerEvents {
+				if timerEvent.Type == history.EventType_ActivityFailed {
+					// The tester starts activities right away and doesn't report their start, don't time them out
+					continue
+				}
+
 				gotNewEvents = true
 				wt.logger.Debug("Timer event", "event_type", timerEvent.Type)
 
@@ -423,7 +471,10 @@ func (wt *workflowTester[TResult]) AssertExpectations(t *testing.T) {
 }
 
 func (wt *workflowTester[TResult]) scheduleActivity(wfi *core.WorkflowInstance, event history.Event) {
-	e := event.Attributes.(*history.ActivityScheduledAttributes)
+	e, err := history.AttributesAs[*history.ActivityScheduledAttributes](&event)
+	if err != nil {
+		panic("Could not read scheduled activity: " + err.Error())
+	}
 
 	go func() {
 		atomic.AddInt32(&wt.runningActivities, 1)
@@ -432,6 +483,8 @@ func (wt *workflowTester[TResult]) scheduleActivity(wfi *core.WorkflowInstance,
 		var activityErr error
 		var activityResult payload.Payload
 
+		hb := activity.NewHeartbeat(e.HeartbeatDetails)
+
 		// Execute mocked activity. If an activity is mocked once, we'll never fall back to the original implementation
 		if wt.mockedActivities[e.Name] {
 			afn, err := wt.registry.GetActivity(e.Name)
@@ -480,8 +533,29 @@ func (wt *workflowTester[TResult]) scheduleActivity(wfi *core.WorkflowInstance,
 			}
 
 		} else {
+			ctx := activity.WithHeartbeat(context.Background(), hb)
+			if e.Stream != "" {
+				ctx = activity.WithStreamer(ctx, func(ctx context.Context, chunk payload.Payload) error {
+					wt.callbacks <- func() *history.WorkflowEvent {
+						return &history.WorkflowEvent{
+							WorkflowInstance: wfi,
+							HistoryEvent: history.NewPendingEvent(
+								wt.clock.Now(),
+								history.EventType_SignalReceived,
+								&history.SignalReceivedAttributes{
+									Name: e.Stream,
+									Arg:  chunk,
+								},
+							),
+						}
+					}
+
+					return nil
+				})
+			}
+
 			executor := activity.NewExecutor(wt.logger, wt.tracer, wt.registry)
-			activityResult, activityErr = executor.ExecuteActivity(context.Background(), &task.Activity{
+			activityResult, activityErr = executor.ExecuteActivity(ctx, &task.Activity{
 				ID:               uuid.NewString(),
 				Metadata:         &core.WorkflowMetadata{},
 				WorkflowInstance: wfi,
@@ -497,7 +571,9 @@ func (wt *workflowTester[TResult]) scheduleActivity(wfi *core.WorkflowInstance,
 					wt.clock.Now(),
 					history.EventType_ActivityFailed,
 					&history.ActivityFailedAttributes{
-						Reason: activityErr.Error(),
+						Reason:           activityErr.Error(),
+						Type:             workflowerrors.Type(activityErr),
+						HeartbeatDetails: hb.Details(),
 					},
 					history.ScheduleEventID(event.ScheduleEventID),
 				)
@@ -521,10 +597,21 @@ func (wt *workflowTester[TResult]) scheduleActivity(wfi *core.WorkflowInstance,
 }
 
 func (wt *workflowTester[TResult]) scheduleTimer(instance *core.WorkflowInstance, event history.Event) {
-	e := event.Attributes.(*history.TimerFiredAttributes)
+	var at time.Time
+	if event.Type == history.EventType_TimerFired {
+		e, err := history.AttributesAs[*history.TimerFiredAttributes](&event)
+		if err != nil {
+			panic("Could not read timer: " + err.Error())
+		}
+
+		at = e.At
+	} else if event.VisibleAt != nil {
+		// Other future events, like the timeout of an execution, are delivered when they become visible
+		at = *event.VisibleAt
+	}
 
 	wt.timers = append(wt.timers, &testTimer{
-		At: e.At,
+		At: at,
 		Callback: func() {
 			wt.callbacks <- func() *history.WorkflowEvent {
 				return &history.WorkflowEvent{
@@ -537,7 +624,10 @@ func (wt *workflowTester[TResult]) scheduleTimer(instance *core.WorkflowInstance
 }
 
 func (wt *workflowTester[TResult]) scheduleSubWorkflow(event history.WorkflowEvent) {
-	a := event.HistoryEvent.Attributes.(*history.ExecutionStartedAttributes)
+	a, err := history.AttributesAs[*history.ExecutionStartedAttributes](&event.HistoryEvent)
+	if err != nil {
+		panic("Could not read sub workflow: " + err.Error())
+	}
 
 	// TODO: Right location to call handler?
 	if wt.subWorkflowListener != nil {
@@ -2,6 +2,7 @@ package tester
 
 import (
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -151,3 +152,114 @@ func Test_SubWorkflow_Signals(t *testing.T) {
 	require.Equal(t, "hello42", wfR)
 	tester.AssertExpectations(t)
 }
+
+func Test_SubWorkflowPool(t *testing.T) {
+	subWorkflow := func(ctx workflow.Context, input int) (int, error) {
+		return input * 2, nil
+	}
+
+	wf := func(ctx workflow.Context) ([]int, error) {
+		return workflow.SubWorkflowPool[int, int](ctx, workflow.SubWorkflowPoolOptions{
+			MaxConcurrency:     2,
+			SubWorkflowOptions: workflow.DefaultSubWorkflowOptions,
+		}, subWorkflow, []int{1, 2, 3, 4, 5})
+	}
+
+	tester := NewWorkflowTester[[]int](wf)
+	tester.Registry().RegisterWorkflow(subWorkflow)
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+
+	wfR, wfE := tester.WorkflowResult()
+	require.Empty(t, wfE)
+	require.Equal(t, []int{2, 4, 6, 8, 10}, wfR)
+	tester.AssertExpectations(t)
+}
+
+func Test_SubWorkflowPool_CancelOnError(t *testing.T) {
+	subWorkflow := func(ctx workflow.Context, input int) (int, error) {
+		if input == 2 {
+			return 0, errors.New("error")
+		}
+
+		return input, nil
+	}
+
+	wf := func(ctx workflow.Context) (map[int]string, error) {
+		_, err := workflow.SubWorkflowPool[int, int](ctx, workflow.SubWorkflowPoolOptions{
+			MaxConcurrency:     1,
+			CancelOnError:      true,
+			SubWorkflowOptions: workflow.DefaultSubWorkflowOptions,
+		}, subWorkflow, []int{1, 2, 3, 4})
+
+		var poolErr *workflow.SubWorkflowPoolError
+		if !errors.As(err, &poolErr) {
+			return nil, errors.New("expected pool error")
+		}
+
+		r := make(map[int]string)
+		for i, err := range poolErr.Errors {
+			r[i] = err.Error()
+		}
+
+		return r, nil
+	}
+
+	tester := NewWorkflowTester[map[int]string](wf)
+	tester.Registry().RegisterWorkflow(subWorkflow)
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+
+	wfR, wfE := tester.WorkflowResult()
+	require.Empty(t, wfE)
+	require.Equal(t, map[int]string{
+		1: "error",
+		2: workflow.Canceled.Error(),
+		3: workflow.Canceled.Error(),
+	}, wfR)
+	tester.AssertExpectations(t)
+}
+
+func Test_SubWorkflow_HierarchicalInstanceIDs(t *testing.T) {
+	subWorkflow := func(ctx workflow.Context) error {
+		return nil
+	}
+
+	wf := func(ctx workflow.Context) error {
+		for i := 0; i < 2; i++ {
+			if _, err := workflow.CreateSubWorkflowInstance[any](
+				ctx, workflow.DefaultSubWorkflowOptions, subWorkflow).Get(ctx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	tester := NewWorkflowTester[any](wf, WithSubWorkflowInstanceID(workflow.HierarchicalSubWorkflowInstanceID))
+	tester.Registry().RegisterWorkflow(subWorkflow)
+
+	var parentInstanceID string
+	var subWorkflowInstanceIDs []string
+
+	tester.ListenSubWorkflow(func(instance *core.WorkflowInstance, name string) {
+		parentInstanceID = instance.ParentInstanceID
+		subWorkflowInstanceIDs = append(subWorkflowInstanceIDs, instance.InstanceID)
+	})
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+	require.Len(t, subWorkflowInstanceIDs, 2)
+
+	// "<parent instance id>/<parent execution id>/"
+	prefix := strings.TrimSuffix(subWorkflowInstanceIDs[0], "func1-1")
+	require.True(t, strings.HasPrefix(prefix, parentInstanceID+"/"))
+	require.Greater(t, len(prefix), len(parentInstanceID)+2)
+	require.Equal(t, []string{prefix + "func1-1", prefix + "func1-2"}, subWorkflowInstanceIDs)
+	tester.AssertExpectations(t)
+}
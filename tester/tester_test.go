@@ -3,11 +3,15 @@ package tester
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/cschleiden/go-workflows/activity"
 	"github.com/cschleiden/go-workflows/internal/sync"
+	"github.com/cschleiden/go-workflows/metrics"
 	"github.com/cschleiden/go-workflows/workflow"
+	"github.com/cschleiden/go-workflows/workflowerrors"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
@@ -95,6 +99,114 @@ func Test_Activity_Retries(t *testing.T) {
 	require.Equal(t, 42, r)
 }
 
+func Test_Activity_NonRetryableErrorTypes(t *testing.T) {
+	tester := NewWorkflowTester[string](workflowWithNonRetryableActivity)
+
+	tester.OnActivity(activity1, mock.Anything).Return(0, errors.New("error")).Once()
+	tester.OnActivity(activity1, mock.Anything).Return(0, activity.NewError("InvalidInput", "invalid input")).Once()
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+	r, errStr := tester.WorkflowResult()
+	require.Zero(t, errStr)
+	require.Equal(t, "InvalidInput: invalid input", r)
+	tester.AssertExpectations(t)
+}
+
+func workflowWithNonRetryableActivity(ctx workflow.Context) (string, error) {
+	_, err := workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+		RetryOptions: workflow.RetryOptions{
+			MaxAttempts:            5,
+			FirstRetryInterval:     time.Second,
+			BackoffCoefficient:     2,
+			NonRetryableErrorTypes: []string{"InvalidInput"},
+		},
+	}, activity1).Get(ctx)
+
+	var activityErr *workflowerrors.ActivityError
+	if !errors.As(err, &activityErr) || activityErr.Activity != "activity1" {
+		return "", fmt.Errorf("unexpected error: %w", err)
+	}
+
+	return workflow.ErrorType(err) + ": " + err.Error(), nil
+}
+
+func Test_Activity_HeartbeatDetails(t *testing.T) {
+	tester := NewWorkflowTester[int](workflowWithHeartbeatingActivity)
+
+	tester.Registry().RegisterActivity(heartbeatingActivity)
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+	r, errStr := tester.WorkflowResult()
+	require.Zero(t, errStr)
+	require.Equal(t, 10, r)
+	tester.AssertExpectations(t)
+}
+
+func workflowWithHeartbeatingActivity(ctx workflow.Context) (int, error) {
+	return workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+		RetryOptions: workflow.RetryOptions{
+			MaxAttempts:        2,
+			FirstRetryInterval: time.Second,
+			BackoffCoefficient: 1,
+		},
+	}, heartbeatingActivity).Get(ctx)
+}
+
+// heartbeatingActivity processes ten items, but fails after the first five on the first attempt. The second
+// attempt resumes after the last processed item and returns the total number of processed items.
+func heartbeatingActivity(ctx context.Context) (int, error) {
+	var processed int
+	if _, err := activity.HeartbeatDetails(ctx, &processed); err != nil {
+		return 0, err
+	}
+
+	for i := processed; i < 10; i++ {
+		if i == 5 && processed == 0 {
+			return 0, errors.New("worker lost")
+		}
+
+		activity.RecordHeartbeat(ctx, i+1)
+	}
+
+	var total int
+	if _, err := activity.HeartbeatDetails(ctx, &total); err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+func Test_ContinueAsNew(t *testing.T) {
+	tester := NewWorkflowTester[int](workflowContinuingAsNew)
+
+	tester.OnActivity(activity1, mock.Anything).Return(1, nil).Times(3)
+
+	tester.Execute(0)
+
+	require.True(t, tester.WorkflowFinished())
+	r, errStr := tester.WorkflowResult()
+	require.Zero(t, errStr)
+	require.Equal(t, 3, r)
+	tester.AssertExpectations(t)
+}
+
+func workflowContinuingAsNew(ctx workflow.Context, n int) (int, error) {
+	r, err := workflow.ExecuteActivity[int](ctx, workflow.DefaultActivityOptions, activity1).Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if n+r < 3 {
+		return 0, workflow.ContinueAsNew(ctx, n+r)
+	}
+
+	return n + r, nil
+}
+
 func Test_Activity_WithoutMock(t *testing.T) {
 	tester := NewWorkflowTester[int](workflowWithActivity)
 
@@ -126,6 +238,162 @@ func activity1(ctx context.Context) (int, error) {
 	return 23, nil
 }
 
+func Test_Activity_ExecutionInfo(t *testing.T) {
+	tester := NewWorkflowTester[activity.ActivityExecutionInfo](workflowWithActivityExecutionInfo)
+	start := tester.Now()
+
+	tester.Registry().RegisterActivity(activityExecutionInfo)
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+	r, errStr := tester.WorkflowResult()
+	require.Zero(t, errStr)
+	require.Equal(t, "activityExecutionInfo", r.Name)
+	require.Equal(t, 1, r.Attempt)
+	require.Equal(t, 3, r.MaxAttempts)
+	require.True(t, start.Add(time.Hour).Equal(r.RetryDeadline))
+	require.False(t, r.CancellationRequested)
+}
+
+func workflowWithActivityExecutionInfo(ctx workflow.Context) (activity.ActivityExecutionInfo, error) {
+	return workflow.ExecuteActivity[activity.ActivityExecutionInfo](ctx, workflow.ActivityOptions{
+		RetryOptions: workflow.RetryOptions{
+			MaxAttempts:  3,
+			RetryTimeout: time.Hour,
+		},
+	}, activityExecutionInfo).Get(ctx)
+}
+
+func activityExecutionInfo(ctx context.Context) (activity.ActivityExecutionInfo, error) {
+	info := activity.ExecutionInfo(ctx)
+	if info.Attempt == 0 {
+		return info, errors.New("first attempt fails")
+	}
+
+	return info, nil
+}
+
+func Test_Activity_RetryAttempts(t *testing.T) {
+	tester := NewWorkflowTester[[]int](workflowWithFailingActivity)
+
+	tester.Registry().RegisterActivity(failingActivity)
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+	r, errStr := tester.WorkflowResult()
+	require.Zero(t, errStr)
+	require.Equal(t, []int{0, 1, 2}, r)
+}
+
+func workflowWithFailingActivity(ctx workflow.Context) ([]int, error) {
+	_, err := workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+		RetryOptions: workflow.RetryOptions{
+			MaxAttempts:        3,
+			FirstRetryInterval: time.Second,
+			BackoffCoefficient: 1,
+		},
+	}, failingActivity).Get(ctx)
+
+	var activityErr *workflowerrors.ActivityError
+	if !errors.As(err, &activityErr) {
+		return nil, fmt.Errorf("unexpected error: %w", err)
+	}
+
+	var attempts []int
+	if _, err := activityErr.DecodeHeartbeatDetails(&attempts); err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
+
+// failingActivity records the attempts made so far in its heartbeat details, and always fails.
+func failingActivity(ctx context.Context) (int, error) {
+	var attempts []int
+	if _, err := activity.HeartbeatDetails(ctx, &attempts); err != nil {
+		return 0, err
+	}
+
+	activity.RecordHeartbeat(ctx, append(attempts, activity.ExecutionInfo(ctx).Attempt))
+
+	return 0, errors.New("failed")
+}
+
+func Test_Activity_Streaming(t *testing.T) {
+	streamingActivity := func(ctx context.Context) (int, error) {
+		for i := 1; i <= 3; i++ {
+			if err := activity.Stream(ctx, i); err != nil {
+				return 0, err
+			}
+		}
+
+		return 42, nil
+	}
+
+	wf := func(ctx workflow.Context) ([]int, error) {
+		chunks, f := workflow.ExecuteStreamingActivity[int, int](ctx, workflow.DefaultActivityOptions, streamingActivity)
+
+		r := []int{}
+		for {
+			c, ok := chunks.Receive(ctx)
+			if !ok {
+				break
+			}
+
+			r = append(r, c)
+		}
+
+		result, err := f.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(r, result), nil
+	}
+
+	tester := NewWorkflowTester[[]int](wf)
+	tester.Registry().RegisterActivity(streamingActivity)
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+	r, errStr := tester.WorkflowResult()
+	require.Zero(t, errStr)
+	require.Equal(t, []int{1, 2, 3, 42}, r)
+	tester.AssertExpectations(t)
+}
+
+func Test_Activity_Streaming_BufferFull(t *testing.T) {
+	streamingActivity := func(ctx context.Context) (int, error) {
+		for i := 0; i < workflow.StreamBufferSize+1; i++ {
+			if err := activity.Stream(ctx, i); err != nil {
+				return 0, err
+			}
+		}
+
+		return 42, nil
+	}
+
+	wf := func(ctx workflow.Context) (int, error) {
+		// Wait for the result without receiving any chunks
+		_, f := workflow.ExecuteStreamingActivity[int, int](ctx, workflow.DefaultActivityOptions, streamingActivity)
+
+		return f.Get(ctx)
+	}
+
+	tester := NewWorkflowTester[int](wf)
+	tester.Registry().RegisterActivity(streamingActivity)
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+	_, errStr := tester.WorkflowResult()
+	require.Contains(t, errStr, "1 chunks dropped")
+	require.Contains(t, errStr, workflow.ErrStreamBufferFull.Error())
+}
+
 func Test_Activity_LongRunning(t *testing.T) {
 	tester := NewWorkflowTester[any](workflowLongRunningActivity)
 	tester.Registry().RegisterActivity(activityLongRunning)
@@ -203,6 +471,41 @@ func workflowTimerCancellation(ctx workflow.Context) (time.Time, error) {
 	return workflow.Now(ctx), nil
 }
 
+func Test_TimerReschedule(t *testing.T) {
+	tester := NewWorkflowTester[time.Duration](workflowTimerReschedule)
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+
+	wfR, wfErr := tester.WorkflowResult()
+	require.Empty(t, wfErr)
+	require.Equal(t, time.Hour, wfR)
+}
+
+func workflowTimerReschedule(ctx workflow.Context) (time.Duration, error) {
+	t := workflow.ScheduleNamedTimer(ctx, "deadline", 10*time.Minute)
+
+	workflow.ScheduleTimer(ctx, time.Minute).Get(ctx)
+
+	// Extend the deadline
+	if err := workflow.RescheduleTimer(ctx, "deadline", time.Hour); err != nil {
+		return 0, err
+	}
+	start := workflow.Now(ctx)
+
+	if _, err := t.Get(ctx); err != nil {
+		return 0, err
+	}
+
+	// Timer has fired, it can't be rescheduled anymore
+	if err := workflow.RescheduleTimer(ctx, "deadline", time.Hour); err != workflow.ErrTimerNotFound {
+		return 0, errors.New("expected ErrTimerNotFound")
+	}
+
+	return workflow.Now(ctx).Sub(start), nil
+}
+
 func Test_Signals(t *testing.T) {
 	tester := NewWorkflowTester[string](workflowSignal)
 	tester.ScheduleCallback(time.Duration(5*time.Second), func() {
@@ -234,3 +537,120 @@ func workflowSignal(ctx workflow.Context) (string, error) {
 
 	return val, nil
 }
+
+func Test_HandleSignal(t *testing.T) {
+	tester := NewWorkflowTester[[]string](workflowHandleSignal)
+	for i, delay := range []time.Duration{time.Second, 3 * time.Second, 4 * time.Second} {
+		s := fmt.Sprint("s", i)
+		tester.ScheduleCallback(delay, func() {
+			tester.SignalWorkflow("signal", s)
+		})
+	}
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+
+	wfR, wfErr := tester.WorkflowResult()
+	require.Empty(t, wfErr)
+	require.Equal(t, []string{"s0", "s1", "s2"}, wfR)
+}
+
+func workflowHandleSignal(ctx workflow.Context) ([]string, error) {
+	// The first signal arrives before the handler is registered
+	workflow.Sleep(ctx, 2*time.Second)
+
+	var received []string
+	workflow.HandleSignal(ctx, "signal", func(s string) {
+		received = append(received, s)
+	})
+
+	workflow.Sleep(ctx, 10*time.Second)
+
+	return received, nil
+}
+
+type counterMetricsClient struct {
+	counters map[string]int64
+}
+
+func (c *counterMetricsClient) Counter(name string, tags metrics.Tags, value int64) {
+	c.counters[name] += value
+}
+
+func (*counterMetricsClient) Distribution(name string, tags metrics.Tags, value float64) {}
+
+func (*counterMetricsClient) Gauge(name string, tags metrics.Tags, value int64) {}
+
+func (*counterMetricsClient) Timing(name string, tags metrics.Tags, duration time.Duration) {}
+
+func (c *counterMetricsClient) WithTags(tags metrics.Tags) metrics.Client {
+	return c
+}
+
+func Test_AwaitSignalWithTimeout(t *testing.T) {
+	tester := NewWorkflowTester[string](workflowAwaitSignalWithTimeout)
+	tester.ScheduleCallback(time.Duration(5*time.Second), func() {
+		tester.SignalWorkflow("signal", "s42")
+	})
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+
+	wfR, wfErr := tester.WorkflowResult()
+	require.Empty(t, wfErr)
+	require.Equal(t, "s42", wfR)
+}
+
+func Test_AwaitSignalWithTimeout_Timeout(t *testing.T) {
+	tester := NewWorkflowTester[string](workflowAwaitSignalWithTimeout)
+	start := tester.Now()
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+
+	wfR, wfErr := tester.WorkflowResult()
+	require.Empty(t, wfErr)
+	require.Equal(t, "timeout", wfR)
+	require.Equal(t, start.Add(time.Minute), tester.Now())
+}
+
+func workflowAwaitSignalWithTimeout(ctx workflow.Context) (string, error) {
+	v, ok := workflow.AwaitSignalWithTimeout[string](ctx, "signal", time.Minute)
+	if !ok {
+		return "timeout", nil
+	}
+
+	// Make sure the canceled timer doesn't interfere with the rest of the workflow
+	workflow.Sleep(ctx, time.Second)
+
+	return v, nil
+}
+
+func Test_RecordMetric_NotRecordedDuringReplay(t *testing.T) {
+	wf := func(ctx workflow.Context) (int, error) {
+		workflow.RecordMetric(ctx, "orders_processed", 1, metrics.Tags{})
+
+		r, err := workflow.ExecuteActivity[int](ctx, workflow.DefaultActivityOptions, activity1).Get(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		workflow.RecordMetric(ctx, "orders_processed", 2, metrics.Tags{})
+
+		return r, nil
+	}
+
+	mc := &counterMetricsClient{counters: map[string]int64{}}
+
+	tester := NewWorkflowTester[int](wf, WithMetrics(mc))
+	tester.Registry().RegisterActivity(activity1)
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+	require.Equal(t, int64(3), mc.counters["orders_processed"])
+	tester.AssertExpectations(t)
+}
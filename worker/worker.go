@@ -3,17 +3,24 @@ package worker
 import (
 	"context"
 	"fmt"
+	"net"
+	"net/http"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/benbjohnson/clock"
 	"github.com/cschleiden/go-workflows/backend"
+	syncinternal "github.com/cschleiden/go-workflows/internal/sync"
 	internal "github.com/cschleiden/go-workflows/internal/worker"
 	workflowinternal "github.com/cschleiden/go-workflows/internal/workflow"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
 	"github.com/cschleiden/go-workflows/workflow"
 )
 
 type WorkflowRegistry interface {
-	RegisterWorkflow(w workflow.Workflow) error
+	RegisterWorkflow(w workflow.Workflow, opts ...RegisterOption) error
 }
 
 type ActivityRegistry interface {
@@ -36,14 +43,48 @@ type Worker interface {
 
 	// WaitForCompletion
 	WaitForCompletion() error
+
+	// DrainActivities stops the worker from accepting new activity tasks. Running activities continue to
+	// heartbeat and are allowed to finish. Use ActivityDrainStatus to wait for them before shutting down.
+	DrainActivities()
+
+	// ActivityDrainStatus returns the progress of draining activities.
+	ActivityDrainStatus() DrainStatus
+
+	// RegisteredWorkflows returns information about all registered workflows.
+	RegisteredWorkflows() []FunctionInfo
+
+	// RegisteredActivities returns information about all registered activities.
+	RegisteredActivities() []FunctionInfo
+
+	// InFlightTasks returns the workflow and activity tasks currently being executed by the worker, longest
+	// running first. See HealthHandler to expose them via HTTP.
+	InFlightTasks() []InFlightTask
+
+	// HealthHandler returns the handler served by the health listener, see Options.HealthListenAddr. It can
+	// also be mounted on an existing HTTP server.
+	HealthHandler() http.Handler
+
+	// Prefetch replays the given workflow instances and caches their executors, so that their next tasks
+	// don't have to replay their histories, e.g., before a traffic spike. Instances that are already cached or
+	// have finished are skipped. At most WorkflowExecutorCacheSize instances stay cached.
+	Prefetch(ctx context.Context, instances ...*workflow.Instance) error
 }
 
 type worker struct {
 	backend backend.Backend
 
+	options *Options
+
 	done chan struct{}
 	wg   *sync.WaitGroup
 
+	// ready is 1 while the worker is started and not stopping or draining, see /readyz
+	ready int32
+
+	healthServer   *http.Server
+	stopHealthOnce sync.Once
+
 	registry *workflowinternal.Registry
 
 	workflowWorker *internal.WorkflowWorker
@@ -55,6 +96,107 @@ type worker struct {
 
 type Options = internal.Options
 
+type ActivityRateLimiter = internal.ActivityRateLimiter
+
+type ConfigurableActivityRateLimiter = internal.ConfigurableActivityRateLimiter
+
+type DynamicOptions = internal.DynamicOptions
+
+type WorkflowConcurrencyLimiter = internal.WorkflowConcurrencyLimiter
+
+// NewWorkflowConcurrencyLimiter returns a WorkflowConcurrencyLimiter enforcing the given limits, keyed by
+// workflow name, within a single worker. It must not be used if more than one worker executes the limited
+// workflows, since slots taken on one worker can't be released by another.
+func NewWorkflowConcurrencyLimiter(limits map[string]int) WorkflowConcurrencyLimiter {
+	return internal.NewWorkflowConcurrencyLimiter(limits)
+}
+
+type ActivityTask = internal.ActivityTask
+
+type ActivityTaskQueue = internal.ActivityTaskQueue
+
+var ErrActivityTaskQueueClosed = internal.ErrActivityTaskQueueClosed
+
+// NewPriorityActivityTaskQueue returns an ActivityTaskQueue holding up to size tasks, which are executed in the
+// order given by less. Tasks that are equal are executed in the order they were received.
+func NewPriorityActivityTaskQueue(size int, less func(a, b *ActivityTask) bool) ActivityTaskQueue {
+	return internal.NewPriorityActivityTaskQueue(size, less)
+}
+
+type ActivityLimits = internal.ActivityLimits
+
+type ActivityLimitError = internal.ActivityLimitError
+
+type ActivityCircuitBreaker = internal.ActivityCircuitBreaker
+
+type ActivityCircuitOpenError = internal.ActivityCircuitOpenError
+
+type DrainStatus = internal.DrainStatus
+
+type ConfigWatcher = internal.ConfigWatcher
+
+type ConfigSource = workflowstate.ConfigSource
+
+// NewFileConfigWatcher returns a ConfigWatcher reading DynamicOptions from the JSON file at the given path. The
+// file is checked for changes every interval. Only DynamicOptions are read from the file, see the README for the
+// supported keys.
+func NewFileConfigWatcher(path string, interval time.Duration) ConfigWatcher {
+	return internal.NewFileConfigWatcher(path, interval)
+}
+
+// SchedulerTracer receives the scheduling events of the coroutines of a workflow.
+type SchedulerTracer = syncinternal.SchedulerTracer
+
+type SchedulerEvent = syncinternal.SchedulerEvent
+
+type SchedulerTrace = syncinternal.SchedulerTrace
+
+// NewSchedulerTrace returns a SchedulerTracer recording the last maxEvents scheduling events of each workflow
+// task. If maxEvents is 0, all events are kept.
+func NewSchedulerTrace(maxEvents int) *SchedulerTrace {
+	return syncinternal.NewSchedulerTrace(maxEvents)
+}
+
+type Hooks = internal.Hooks
+
+type TaskInfo = internal.TaskInfo
+
+type TaskKind = internal.TaskKind
+
+type InFlightTask = internal.InFlightTask
+
+type WorkflowSLO = internal.WorkflowSLO
+
+type SLO = internal.SLO
+
+type SLOViolation = internal.SLOViolation
+
+const (
+	SLOMaxDuration    = internal.SLOMaxDuration
+	SLOMaxTaskLatency = internal.SLOMaxTaskLatency
+)
+
+const (
+	TaskKindWorkflow = internal.TaskKindWorkflow
+	TaskKindActivity = internal.TaskKindActivity
+)
+
+type FunctionInfo = workflowinternal.FunctionInfo
+
+// ErrInvalidRegistry is returned by Start when registered workflows or activities have signatures that can't
+// be executed, e.g., because a parameter type is not serializable. It lists all problems found.
+type ErrInvalidRegistry = workflowinternal.ErrInvalidRegistry
+
+type RegisterOption = workflowinternal.RegisterOption
+
+// WithVersion sets a version for a registered workflow definition. The version is part of the definition
+// checksum recorded when an instance is started. Workers log a warning when they replay an instance
+// started with a different checksum, so bump the version whenever a change is not safe for running
+// instances.
+func WithVersion(version string) RegisterOption {
+	return workflowinternal.WithVersion(version)
+}
+
 var DefaultWorkerOptions = internal.DefaultOptions
 
 func New(backend backend.Backend, options *Options) Worker {
@@ -62,6 +204,14 @@ func New(backend backend.Backend, options *Options) Worker {
 		options = &internal.DefaultOptions
 	}
 
+	if options.WorkflowPollers == 0 {
+		options.WorkflowPollers = internal.DefaultOptions.WorkflowPollers
+	}
+
+	if options.ActivityPollers == 0 {
+		options.ActivityPollers = internal.DefaultOptions.ActivityPollers
+	}
+
 	if options.WorkflowExecutorCacheSize == 0 {
 		options.WorkflowExecutorCacheSize = internal.DefaultOptions.WorkflowExecutorCacheSize
 	}
@@ -72,9 +222,16 @@ func New(backend backend.Backend, options *Options) Worker {
 
 	registry := workflowinternal.NewRegistry()
 
+	// Allow workflows to query other instances, see workflow.QueryExternalWorkflow
+	if err := registry.RegisterActivityWithName(workflowstate.QueryActivityName, internal.NewQueryActivity(backend, registry)); err != nil {
+		panic(err)
+	}
+
 	return &worker{
 		backend: backend,
 
+		options: options,
+
 		done: make(chan struct{}),
 		wg:   &sync.WaitGroup{},
 
@@ -86,18 +243,128 @@ func New(backend backend.Backend, options *Options) Worker {
 }
 
 func (w *worker) Start(ctx context.Context) error {
+	// Fail fast instead of when the first task for a broken workflow or activity arrives
+	if err := w.registry.Validate(); err != nil {
+		return err
+	}
+
+	if w.options.HealthListenAddr != "" {
+		if err := w.serveHealth(); err != nil {
+			return err
+		}
+	}
+
 	if err := w.workflowWorker.Start(ctx); err != nil {
+		w.stopHealth()
 		return fmt.Errorf("starting workflow worker: %w", err)
 	}
 
 	if err := w.activityWorker.Start(ctx); err != nil {
+		w.stopHealth()
 		return fmt.Errorf("starting activity worker: %w", err)
 	}
 
+	if w.options.ConfigWatcher != nil {
+		updates, err := w.options.ConfigWatcher.Watch(ctx)
+		if err != nil {
+			return fmt.Errorf("watching worker configuration: %w", err)
+		}
+
+		go w.watchConfig(ctx, updates)
+	}
+
+	if w.options.Hooks.OnWorkerStart != nil {
+		w.options.Hooks.OnWorkerStart(ctx)
+	}
+
+	atomic.StoreInt32(&w.ready, 1)
+	go func() {
+		<-ctx.Done()
+		atomic.StoreInt32(&w.ready, 0)
+	}()
+
+	return nil
+}
+
+// serveHealth starts the health listener. It keeps serving after the context passed to Start is canceled, so
+// probes and /inflight stay available while WaitForCompletion drains in-flight tasks.
+func (w *worker) serveHealth() error {
+	l, err := net.Listen("tcp", w.options.HealthListenAddr)
+	if err != nil {
+		return fmt.Errorf("starting health listener: %w", err)
+	}
+
+	w.healthServer = &http.Server{
+		Handler:           w.HealthHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		if err := w.healthServer.Serve(l); err != nil && err != http.ErrServerClosed {
+			w.backend.Logger().Error("Health listener failed", "error", err)
+		}
+	}()
+
+	w.backend.Logger().Debug("Started health listener", "addr", l.Addr().String())
+
 	return nil
 }
 
+func (w *worker) stopHealth() {
+	if w.healthServer == nil {
+		return
+	}
+
+	w.stopHealthOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := w.healthServer.Shutdown(ctx); err != nil {
+			w.backend.Logger().Error("Stopping health listener", "error", err)
+		}
+	})
+}
+
+func (w *worker) HealthHandler() http.Handler {
+	return internal.NewHealthHandler(
+		func() bool {
+			return atomic.LoadInt32(&w.ready) == 1
+		},
+		w.InFlightTasks,
+		w.options.MetricsHandler,
+	)
+}
+
+func (w *worker) watchConfig(ctx context.Context, updates <-chan DynamicOptions) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case o, ok := <-updates:
+			if !ok {
+				return
+			}
+
+			w.backend.Logger().Debug("Applying worker configuration update",
+				"workflow_pollers", o.WorkflowPollers,
+				"max_parallel_workflow_tasks", o.MaxParallelWorkflowTasks,
+				"activity_pollers", o.ActivityPollers,
+				"max_parallel_activity_tasks", o.MaxParallelActivityTasks,
+			)
+
+			w.workflowWorker.ApplyDynamicOptions(o)
+			w.activityWorker.ApplyDynamicOptions(o)
+		}
+	}
+}
+
 func (w *worker) WaitForCompletion() error {
+	atomic.StoreInt32(&w.ready, 0)
+
 	if err := w.workflowWorker.WaitForCompletion(); err != nil {
 		return err
 	}
@@ -106,13 +373,53 @@ func (w *worker) WaitForCompletion() error {
 		return err
 	}
 
+	w.stopHealth()
+	w.wg.Wait()
+
+	if w.options.Hooks.OnWorkerStop != nil {
+		w.options.Hooks.OnWorkerStop()
+	}
+
 	return nil
 }
 
-func (w *worker) RegisterWorkflow(wf workflow.Workflow) error {
-	return w.registry.RegisterWorkflow(wf)
+func (w *worker) DrainActivities() {
+	// Draining workers should not get new traffic routed to them
+	atomic.StoreInt32(&w.ready, 0)
+
+	w.activityWorker.Drain()
+}
+
+func (w *worker) ActivityDrainStatus() DrainStatus {
+	return w.activityWorker.DrainStatus()
+}
+
+func (w *worker) RegisterWorkflow(wf workflow.Workflow, opts ...RegisterOption) error {
+	return w.registry.RegisterWorkflow(wf, opts...)
 }
 
 func (w *worker) RegisterActivity(a interface{}) error {
 	return w.registry.RegisterActivity(a)
 }
+
+func (w *worker) RegisteredWorkflows() []FunctionInfo {
+	return w.registry.Workflows()
+}
+
+func (w *worker) RegisteredActivities() []FunctionInfo {
+	return w.registry.Activities()
+}
+
+func (w *worker) Prefetch(ctx context.Context, instances ...*workflow.Instance) error {
+	return w.workflowWorker.Prefetch(ctx, instances)
+}
+
+func (w *worker) InFlightTasks() []InFlightTask {
+	tasks := append(w.workflowWorker.InFlightTasks(), w.activityWorker.InFlightTasks()...)
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].StartedAt.Before(tasks[j].StartedAt)
+	})
+
+	return tasks
+}
@@ -1,56 +1,234 @@
 package workflow
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	a "github.com/cschleiden/go-workflows/internal/args"
+	"github.com/cschleiden/go-workflows/internal/activityqueue"
 	"github.com/cschleiden/go-workflows/internal/command"
 	"github.com/cschleiden/go-workflows/internal/converter"
 	"github.com/cschleiden/go-workflows/internal/fn"
+	"github.com/cschleiden/go-workflows/internal/payload"
 	"github.com/cschleiden/go-workflows/internal/sync"
 	"github.com/cschleiden/go-workflows/internal/tracing"
 	"github.com/cschleiden/go-workflows/internal/workflowstate"
 	"github.com/cschleiden/go-workflows/internal/workflowtracer"
+	"github.com/cschleiden/go-workflows/workflowerrors"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// ActivityInterceptor rewrites the inputs and results of activities scheduled by workflows. Interceptors are
+// configured on the worker and have to be deterministic, since they run as part of the workflow code.
+type ActivityInterceptor = workflowstate.ActivityInterceptor
+
 type ActivityOptions struct {
 	RetryOptions RetryOptions
+
+	// Queue is the queue the activity is dispatched to. Only workers whose backend serves the queue, see
+	// backend.WithActivityQueues, execute the activity. Defaults to the default queue.
+	Queue string
+
+	// Labels are required to execute the activity, e.g., "gpu" or "region=eu". Only workers whose backend
+	// advertises all of them, see backend.WithActivityLabels, execute the activity. Labels can't contain "#" or
+	// ",", and there can be at most backend.MaxActivityLabels of them.
+	Labels []string
+
+	// HeartbeatTimeout is the maximum time between heartbeats of the activity, see activity.RecordHeartbeat. If the
+	// activity doesn't record a heartbeat in time, e.g., because it's stuck or the worker executing it died, the
+	// attempt fails with a TimeoutError and is retried according to RetryOptions. Timeouts of activities whose worker
+	// died require a backend implementing backend.ActivityHeartbeatTimeoutRescheduler, otherwise such activities are
+	// delivered to another worker once their lock expires, see backend.Options.ActivityLockTimeout. Defaults to 0,
+	// which disables heartbeat timeouts.
+	HeartbeatTimeout time.Duration
+
+	// ScheduleToStartTimeout is the maximum time an attempt of the activity may wait to be started by a worker,
+	// e.g., because all workers are busy. Attempts that are started later fail with a TimeoutError without being
+	// executed, and are retried according to RetryOptions. Defaults to 0, which doesn't limit the time.
+	ScheduleToStartTimeout time.Duration
+
+	// StartToCloseTimeout is the maximum time a single attempt of the activity may run. When it expires, the
+	// activity's context is canceled and the attempt fails with a TimeoutError, which is retried according to
+	// RetryOptions. Defaults to 0, which doesn't limit the time.
+	StartToCloseTimeout time.Duration
 }
 
 var DefaultActivityOptions = ActivityOptions{
 	RetryOptions: DefaultRetryOptions,
 }
 
-// ExecuteActivity schedules the given activity to be executed
+// ExecuteActivity schedules the given activity to be executed. If the worker has default options for the activity,
+// see worker.Options.ActivityDefaults, they are used unless options specify other than the default retry options.
 func ExecuteActivity[TResult any](ctx Context, options ActivityOptions, activity interface{}, args ...interface{}) Future[TResult] {
+	name := fn.Name(activity)
+	options = activityOptions(ctx, name, options)
+
+	// Invalid options fail right away instead of every attempt
+	if err := activityqueue.Validate(options.Queue, options.Labels...); err != nil {
+		f := sync.NewFuture[TResult]()
+		f.Set(*new(TResult), err)
+		return f
+	}
+
+	var scheduleEventID int64
+	deadline := retryDeadline(ctx, options.RetryOptions)
+
+	var previous Future[TResult]
+
 	return withRetries(ctx, options.RetryOptions, func(ctx sync.Context, attempt int) Future[TResult] {
-		return executeActivity[TResult](ctx, options, attempt, activity, args...)
+		previous, scheduleEventID = executeActivity[TResult](
+			ctx, options, attempt, deadline, "", heartbeatDetails(ctx, previous), name, args...)
+		return previous
+	}, func() string {
+		return ActivityOperatorSignal(scheduleEventID)
+	})
+}
+
+// StreamBufferSize is the number of chunks of a streaming activity that are buffered until the workflow receives
+// them, see ExecuteStreamingActivity.
+const StreamBufferSize = 100
+
+// ErrStreamBufferFull is returned by the future of a streaming activity if chunks were dropped, because the
+// workflow didn't receive them fast enough.
+var ErrStreamBufferFull = errors.New("stream buffer full")
+
+// ExecuteStreamingActivity schedules the given activity to be executed. Intermediate results the activity sends
+// with activity.Stream are delivered on the returned channel, which is closed once the activity has finished.
+// If the activity is retried, chunks sent by failed attempts are delivered as well.
+//
+// Up to StreamBufferSize chunks that have not been received yet are buffered. If the workflow doesn't receive
+// chunks fast enough, chunks exceeding that are dropped, and the returned future fails with ErrStreamBufferFull
+// once the activity has finished.
+func ExecuteStreamingActivity[TChunk, TResult any](ctx Context, options ActivityOptions, activity interface{}, args ...interface{}) (Channel[TChunk], Future[TResult]) {
+	name := fn.Name(activity)
+	options = activityOptions(ctx, name, options)
+
+	if err := activityqueue.Validate(options.Queue, options.Labels...); err != nil {
+		chunks := sync.NewChannel[TChunk]()
+		chunks.Close()
+
+		f := sync.NewFuture[TResult]()
+		f.Set(*new(TResult), err)
+		return chunks, f
+	}
+
+	wfState := workflowstate.WorkflowState(ctx)
+	stream := fmt.Sprintf("activity-stream:%d", wfState.GetNextScheduleEventID())
+
+	chunks := sync.NewBufferedChannel[TChunk](StreamBufferSize)
+	dropped := 0
+	wfState.SetSignalHandler(stream, func(arg payload.Payload) {
+		if ci, ok := chunks.(sync.ChannelInternal[TChunk]); ok && ci.Closed() {
+			return
+		}
+
+		var chunk TChunk
+		if err := converter.DefaultConverter.From(arg, &chunk); err != nil {
+			panic(fmt.Errorf("converting chunk of streaming activity %s: %w", name, err))
+		}
+
+		if !chunks.SendNonblocking(chunk) {
+			if dropped == 0 {
+				wfState.Logger().Warn("Stream buffer full, dropping chunks of streaming activity", "activity", name)
+			}
+
+			dropped++
+		}
+	})
+
+	var scheduleEventID int64
+	deadline := retryDeadline(ctx, options.RetryOptions)
+
+	var previous Future[TResult]
+
+	f := withRetries(ctx, options.RetryOptions, func(ctx sync.Context, attempt int) Future[TResult] {
+		previous, scheduleEventID = executeActivity[TResult](
+			ctx, options, attempt, deadline, stream, heartbeatDetails(ctx, previous), name, args...)
+		return previous
+	}, func() string {
+		return ActivityOperatorSignal(scheduleEventID)
 	})
+
+	// Close the chunk channel once the activity is done. All chunks have been received by then, since they are
+	// delivered before the activity result.
+	result := sync.NewFuture[TResult]()
+	Go(ctx, func(ctx Context) {
+		r, err := f.Get(ctx)
+		chunks.Close()
+
+		if dropped > 0 && err == nil {
+			err = fmt.Errorf("streaming activity %s: %d chunks dropped: %w", name, dropped, ErrStreamBufferFull)
+		}
+
+		result.Set(r, err)
+	})
+
+	return chunks, result
 }
 
-func executeActivity[TResult any](ctx Context, options ActivityOptions, attempt int, activity interface{}, args ...interface{}) Future[TResult] {
+// heartbeatDetails returns the details of the last heartbeat recorded by the given failed attempt of an activity,
+// so they can be passed to the next attempt. previous is nil for the first attempt.
+func heartbeatDetails[TResult any](ctx Context, previous Future[TResult]) payload.Payload {
+	if previous == nil {
+		return nil
+	}
+
+	// The previous attempt has failed when the next one is scheduled, so this doesn't block
+	_, err := previous.Get(ctx)
+
+	var aerr *workflowerrors.ActivityError
+	if errors.As(err, &aerr) {
+		return aerr.HeartbeatDetails
+	}
+
+	return nil
+}
+
+// executeActivity schedules a single attempt of the activity with the given name. deadline is the time after
+// which the activity is not retried anymore, if any. heartbeatDetails are passed on from the previous attempt.
+// It also returns the schedule event ID of the attempt, 0 if it was not scheduled.
+func executeActivity[TResult any](
+	ctx Context, options ActivityOptions, attempt int, deadline time.Time, stream string, heartbeatDetails payload.Payload,
+	name string, args ...interface{},
+) (Future[TResult], int64) {
 	f := sync.NewFuture[TResult]()
 
 	if ctx.Err() != nil {
 		f.Set(*new(TResult), ctx.Err())
-		return f
+		return f, 0
 	}
 
 	inputs, err := a.ArgsToInputs(converter.DefaultConverter, args...)
 	if err != nil {
 		f.Set(*new(TResult), fmt.Errorf("converting activity input: %w", err))
-		return f
+		return f, 0
 	}
 
 	wfState := workflowstate.WorkflowState(ctx)
+
+	inputs, err = wfState.InterceptActivityInputs(name, inputs)
+	if err != nil {
+		f.Set(*new(TResult), fmt.Errorf("intercepting activity input: %w", err))
+		return f, 0
+	}
+
 	scheduleEventID := wfState.GetNextScheduleEventID()
 
-	name := fn.Name(activity)
-	cmd := command.NewScheduleActivityCommand(scheduleEventID, name, inputs)
+	cmd := command.NewScheduleActivityCommand(scheduleEventID, name, inputs, attempt)
+	cmd.Stream = stream
+	cmd.Queue = options.Queue
+	cmd.Labels = options.Labels
+	cmd.MaxAttempts = options.RetryOptions.MaxAttempts
+	cmd.RetryDeadline = deadline
+	cmd.CancellationRequested = wfState.CancellationRequested()
+	cmd.HeartbeatTimeout = options.HeartbeatTimeout
+	cmd.HeartbeatDetails = heartbeatDetails
+	cmd.ScheduleToStartTimeout = options.ScheduleToStartTimeout
+	cmd.StartToCloseTimeout = options.StartToCloseTimeout
 	wfState.AddCommand(cmd)
-	wfState.TrackFuture(scheduleEventID, workflowstate.AsDecodingSettable(f))
+	wfState.TrackFuture(scheduleEventID, wfState.InterceptActivityResult(name, workflowstate.AsDecodingSettable(wfState, f)))
 
 	ctx, span := workflowtracer.Tracer(ctx).Start(ctx,
 		fmt.Sprintf("ExecuteActivity: %s", name),
@@ -75,5 +253,5 @@ func executeActivity[TResult any](ctx Context, options ActivityOptions, attempt
 		}
 	}
 
-	return f
+	return f, scheduleEventID
 }
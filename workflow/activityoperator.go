@@ -0,0 +1,51 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ActivityOperatorAction is sent by operators to an activity that failed and is waiting for its next retry,
+// e.g., to unblock workflows waiting on long backoffs. See client.RetryActivityNow and client.FailActivityNow.
+type ActivityOperatorAction struct {
+	// Fail stops retrying the activity, it fails with Reason. Otherwise the activity is retried right away,
+	// even if it has exhausted its retry attempts.
+	Fail bool `json:"fail,omitempty"`
+
+	Reason string `json:"reason,omitempty"`
+}
+
+// ActivityOperatorSignal returns the name of the signal carrying ActivityOperatorAction for the failed activity
+// attempt with the given schedule event ID.
+func ActivityOperatorSignal(scheduleEventID int64) string {
+	return fmt.Sprintf("activity-operator:%d", scheduleEventID)
+}
+
+// waitForRetry waits for the given backoff before the next attempt of a failed activity. Operators can cut the
+// backoff short by signaling the failed attempt. retry is true if an operator requested an immediate retry.
+func waitForRetry(ctx Context, backoff time.Duration, signal string) (retry bool, err error) {
+	tctx, cancel := WithCancel(ctx)
+	defer cancel()
+
+	var action *ActivityOperatorAction
+
+	Select(ctx,
+		Receive(NewSignalChannel[ActivityOperatorAction](ctx, signal), func(ctx Context, v ActivityOperatorAction, ok bool) {
+			action = &v
+		}),
+		Await(ScheduleTimer(tctx, backoff), func(ctx Context, f Future[struct{}]) {
+			_, err = f.Get(ctx)
+		}),
+	)
+
+	if action == nil {
+		return false, err
+	}
+
+	if action.Fail {
+		return false, errors.New(action.Reason)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,50 @@
+package workflow
+
+import (
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+)
+
+// Cancellation describes why a workflow instance was canceled.
+type Cancellation struct {
+	// Cause is one of ErrCanceledByUser, ErrCanceledByParent, ErrTimedOut, or ErrTerminated, see Cause.
+	Cause error
+
+	// Reason is the reason passed to client.CancelWorkflowInstanceWithReason, empty otherwise.
+	Reason string
+
+	details payload.Payload
+}
+
+// HasDetails returns true if structured details were attached to the cancellation.
+func (c *Cancellation) HasDetails() bool {
+	return c.details != nil
+}
+
+// Details decodes the structured details attached to the cancellation into v. If no details were attached, v
+// is left unchanged.
+func (c *Cancellation) Details(v interface{}) error {
+	if c.details == nil {
+		return nil
+	}
+
+	return converter.DefaultConverter.From(c.details, v)
+}
+
+// CancellationDetails returns why the workflow instance was canceled, or nil if it hasn't been canceled. Cleanup
+// code can use it to branch on the reason and details passed when canceling the instance.
+func CancellationDetails(ctx Context) *Cancellation {
+	wfState := workflowstate.WorkflowState(ctx)
+
+	c := wfState.Cancellation()
+	if c == nil {
+		return nil
+	}
+
+	return &Cancellation{
+		Cause:   c.Cause,
+		Reason:  c.Message,
+		details: c.Details,
+	}
+}
@@ -0,0 +1,65 @@
+package workflow
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCancelScopeTimedOut is the cause when a CancelScope was canceled because the timeout set with
+// CancelAfter expired.
+var ErrCancelScopeTimedOut = errors.New("cancel scope timed out")
+
+// CancelScope groups the activities, timers, and sub-workflows started with its context, so that they can be
+// canceled together without canceling the whole workflow instance. Scopes can be nested: canceling a scope
+// cancels all scopes created from its context, but not the enclosing ones.
+//
+// Cancellation propagates in the order in which the scopes and the work within them were started, so it's
+// deterministic and replays the same way.
+type CancelScope struct {
+	ctx    Context
+	cancel CancelCauseFunc
+}
+
+// WithCancelScope returns a new CancelScope and its context, derived from ctx. Pass the returned context to
+// the work that should be canceled with the scope. The scope is canceled with ctx as well.
+func WithCancelScope(ctx Context) (Context, *CancelScope) {
+	ctx, cancel := WithCancelCause(ctx)
+
+	return ctx, &CancelScope{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Cancel cancels all work started with the scope's context. Canceling an already canceled scope has no effect.
+func (s *CancelScope) Cancel() {
+	s.cancel(nil)
+}
+
+// CancelWithCause cancels the scope like Cancel, recording the given cause. See Cause.
+func (s *CancelScope) CancelWithCause(cause error) {
+	s.cancel(cause)
+}
+
+// CancelAfter cancels the scope with ErrCancelScopeTimedOut when the given delay expires. It uses a timer
+// started with the scope's context, so the timer is canceled when the scope is canceled before. Call Cancel
+// once the work in the scope is done to clean up the timer.
+func (s *CancelScope) CancelAfter(delay time.Duration) {
+	t := ScheduleTimer(s.ctx, delay)
+
+	Go(s.ctx, func(ctx Context) {
+		if _, err := t.Get(ctx); err == nil {
+			s.CancelWithCause(ErrCancelScopeTimedOut)
+		}
+	})
+}
+
+// Canceled returns true if the scope, or one of its enclosing scopes, has been canceled.
+func (s *CancelScope) Canceled() bool {
+	return s.ctx.Err() != nil
+}
+
+// Cause returns why the scope was canceled, or nil if it was not canceled yet.
+func (s *CancelScope) Cause() error {
+	return Cause(s.ctx)
+}
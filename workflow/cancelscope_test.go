@@ -0,0 +1,68 @@
+package workflow
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cschleiden/go-workflows/internal/sync"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CancelScope_CancelsOnlyItsSubtree(t *testing.T) {
+	s := sync.NewScheduler()
+
+	errPayment := errors.New("payment branch abandoned")
+
+	var canceled []string
+
+	s.NewCoroutine(sync.Background(), func(ctx Context) error {
+		paymentCtx, payment := WithCancelScope(ctx)
+		chargeCtx, charge := WithCancelScope(paymentCtx)
+		_, shipping := WithCancelScope(ctx)
+
+		track := func(name string, ctx Context) {
+			ctx.Done().(sync.CancelChannel).AddReceiveCallback(func(struct{}, bool) {
+				canceled = append(canceled, name)
+			})
+		}
+		track("payment", paymentCtx)
+		track("charge", chargeCtx)
+
+		payment.CancelWithCause(errPayment)
+
+		require.True(t, payment.Canceled())
+		require.True(t, charge.Canceled())
+		require.False(t, shipping.Canceled())
+		require.NoError(t, ctx.Err())
+
+		require.Equal(t, errPayment, charge.Cause())
+		require.Nil(t, shipping.Cause())
+
+		return nil
+	})
+
+	require.NoError(t, s.Execute())
+	require.Equal(t, []string{"payment", "charge"}, canceled)
+}
+
+func Test_CancelScope_CanceledWithParent(t *testing.T) {
+	s := sync.NewScheduler()
+
+	ctx, cancel := sync.WithCancel(sync.Background())
+
+	var scope *CancelScope
+
+	s.NewCoroutine(ctx, func(ctx Context) error {
+		_, scope = WithCancelScope(ctx)
+
+		return nil
+	})
+
+	require.NoError(t, s.Execute())
+	require.False(t, scope.Canceled())
+
+	cancel()
+
+	require.True(t, scope.Canceled())
+	require.Equal(t, Canceled, scope.Cause())
+}
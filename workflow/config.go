@@ -0,0 +1,53 @@
+package workflow
+
+import (
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/internal/command"
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+)
+
+// GetConfigValue returns the value of the given configuration key, read from the worker's ConfigSource, or
+// from environment variables if none is configured. Reading configuration directly in workflow code is not
+// deterministic; GetConfigValue records the value in the history whenever it differs from the value last
+// seen by the instance, and returns the recorded value when the workflow is replayed.
+func GetConfigValue(ctx Context, key string) (string, error) {
+	wfState := workflowstate.WorkflowState(ctx)
+	scheduleEventID := wfState.GetNextScheduleEventID()
+	name := workflowstate.ConfigMarkerPrefix + key
+
+	if Replaying(ctx) {
+		p, recorded := wfState.RecordedConfigValue(scheduleEventID)
+		if !recorded {
+			// The value was unchanged when this was executed before
+			v, _ := wfState.ConfigValue(key)
+			return v, nil
+		}
+
+		var v string
+		if err := converter.DefaultConverter.From(p, &v); err != nil {
+			return "", fmt.Errorf("converting recorded config value: %w", err)
+		}
+
+		wfState.AddCommand(command.NewRecordMarkerCommand(scheduleEventID, name, p))
+		wfState.SetConfigValue(key, v)
+
+		return v, nil
+	}
+
+	v := wfState.ResolveConfigValue(key)
+	if previous, ok := wfState.ConfigValue(key); ok && previous == v {
+		return v, nil
+	}
+
+	p, err := converter.DefaultConverter.To(v)
+	if err != nil {
+		return "", fmt.Errorf("converting config value: %w", err)
+	}
+
+	wfState.AddCommand(command.NewRecordMarkerCommand(scheduleEventID, name, p))
+	wfState.SetConfigValue(key, v)
+
+	return v, nil
+}
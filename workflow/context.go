@@ -1,9 +1,29 @@
 package workflow
 
-import "github.com/cschleiden/go-workflows/internal/sync"
+import (
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/sync"
+)
 
 type CancelFunc = sync.CancelFunc
 
+type CancelCauseFunc = sync.CancelCauseFunc
+
+// Causes returned by Cause when a workflow instance was canceled. Err returns Canceled in all cases.
+var (
+	// ErrCanceledByUser is the cause when the instance was canceled using the client.
+	ErrCanceledByUser = core.ErrCanceledByUser
+
+	// ErrCanceledByParent is the cause when the parent canceled the context of this sub-workflow.
+	ErrCanceledByParent = core.ErrCanceledByParent
+
+	// ErrTimedOut is the cause when the instance exceeded its timeout.
+	ErrTimedOut = core.ErrTimedOut
+
+	// ErrTerminated is the cause when the instance was terminated.
+	ErrTerminated = core.ErrTerminated
+)
+
 // WithCancel returns a copy of parent with a new Done channel. The returned
 // context's Done channel is closed when the returned cancel function is called
 // or when the parent context's Done channel is closed, whichever happens first.
@@ -14,6 +34,19 @@ func WithCancel(parent Context) (ctx Context, cancel CancelFunc) {
 	return sync.WithCancel(parent)
 }
 
+// WithCancelCause behaves like WithCancel but the returned cancel function takes the cause of the
+// cancellation, which can be retrieved using Cause.
+func WithCancelCause(parent Context) (ctx Context, cancel CancelCauseFunc) {
+	return sync.WithCancelCause(parent)
+}
+
+// Cause returns why ctx was canceled, or nil if it was not canceled yet. For the context of a canceled
+// workflow instance, this is one of ErrCanceledByUser, ErrCanceledByParent, ErrTimedOut, or
+// ErrTerminated, so cleanup code can branch on the reason.
+func Cause(ctx Context) error {
+	return sync.Cause(ctx)
+}
+
 func NewDisconnectedContext(ctx Context) Context {
 	return sync.NewDisconnectedContext(ctx)
 }
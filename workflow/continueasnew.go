@@ -0,0 +1,31 @@
+package workflow
+
+import (
+	"fmt"
+
+	a "github.com/cschleiden/go-workflows/internal/args"
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+)
+
+// ContinueAsNew returns an error that, when returned by the workflow, completes the current execution of the
+// workflow instance and atomically starts a new execution of the same workflow with the given arguments. The
+// new execution keeps the instance ID, but gets a new execution ID and starts with an empty history. Use it for
+// workflows that loop forever, or for a long time, so that their history doesn't grow without bounds:
+//
+//	func Counter(ctx workflow.Context, n int) (int, error) {
+//		// ...
+//		return 0, workflow.ContinueAsNew(ctx, n+1)
+//	}
+//
+// Signals that have not been received by the current execution are delivered to the new one. Activities and
+// sub-workflows should be completed before continuing, results arriving after the current execution completed
+// are dropped.
+func ContinueAsNew(ctx Context, args ...interface{}) error {
+	inputs, err := a.ArgsToInputs(converter.DefaultConverter, args...)
+	if err != nil {
+		return fmt.Errorf("converting workflow inputs: %w", err)
+	}
+
+	return &workflowstate.ContinueAsNewError{Inputs: inputs}
+}
@@ -0,0 +1,11 @@
+package workflow
+
+import (
+	"github.com/cschleiden/go-workflows/workflowerrors"
+)
+
+// ErrorType returns the type of an error returned by an activity, see activity.NewError, or an empty string if
+// the error doesn't have a type.
+func ErrorType(err error) string {
+	return workflowerrors.Type(err)
+}
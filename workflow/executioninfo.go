@@ -0,0 +1,47 @@
+package workflow
+
+import (
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/sync"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+)
+
+// WorkflowExecutionInfo describes the workflow instance executing the current workflow code.
+type WorkflowExecutionInfo struct {
+	InstanceID  string
+	ExecutionID string
+
+	// WorkflowName is the name the workflow was registered with.
+	WorkflowName string
+
+	// ParentInstanceID is the instance ID of the parent workflow for sub-workflows, empty otherwise.
+	ParentInstanceID string
+
+	// Attempt is the retry attempt of a sub-workflow, starting at 0. Always 0 for other workflows.
+	Attempt int
+
+	// StartTime is the time the instance was started, as recorded in its history.
+	StartTime time.Time
+
+	// IsReplaying is true while the workflow code is replaying its history. Side effects outside of the
+	// workflow, like logging or emitting metrics, should usually be skipped while replaying.
+	IsReplaying bool
+}
+
+// ExecutionInfo returns information about the workflow instance executing the current workflow code. All
+// fields except IsReplaying are deterministic.
+func ExecutionInfo(ctx sync.Context) WorkflowExecutionInfo {
+	wfState := workflowstate.WorkflowState(ctx)
+	instance := wfState.Instance()
+
+	return WorkflowExecutionInfo{
+		InstanceID:       instance.InstanceID,
+		ExecutionID:      instance.ExecutionID,
+		WorkflowName:     wfState.WorkflowName(),
+		ParentInstanceID: instance.ParentInstanceID,
+		Attempt:          wfState.Attempt(),
+		StartTime:        wfState.StartedAt(),
+		IsReplaying:      wfState.Replaying(),
+	}
+}
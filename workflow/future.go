@@ -1,6 +1,33 @@
 package workflow
 
+import "github.com/cschleiden/go-workflows/internal/sync"
+
 type Future[T any] interface {
 	// Get returns the value if set, blocks otherwise
 	Get(ctx Context) (T, error)
 }
+
+// ErrNoFutures is returned by WaitAny when called without any futures.
+var ErrNoFutures = sync.ErrNoFutures
+
+// WaitAll waits until all given futures are resolved and returns their results in argument order. If any
+// future failed, the error of the first failed future in argument order is returned.
+func WaitAll[T any](ctx Context, futures ...Future[T]) ([]T, error) {
+	return sync.WaitAll(ctx, toSyncFutures(futures)...)
+}
+
+// WaitAny waits until at least one of the given futures is resolved and returns its index, value, and error.
+// When multiple futures are resolved, the one passed first is returned, independent of the order in which
+// they were resolved. This keeps workflows deterministic when they are replayed.
+func WaitAny[T any](ctx Context, futures ...Future[T]) (int, T, error) {
+	return sync.WaitAny(ctx, toSyncFutures(futures)...)
+}
+
+func toSyncFutures[T any](futures []Future[T]) []sync.Future[T] {
+	r := make([]sync.Future[T], len(futures))
+	for i, f := range futures {
+		r[i] = f
+	}
+
+	return r
+}
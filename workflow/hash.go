@@ -0,0 +1,47 @@
+package workflow
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"github.com/cschleiden/go-workflows/internal/command"
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+)
+
+// HashMarkerName is the name of the markers recording the hashes computed by Hash.
+const HashMarkerName = "workflow.Hash"
+
+// hashConverter encodes values to hash. It's not the pluggable default converter, since converters like the
+// encryption converter encode the same value differently every time.
+var hashConverter = converter.NewJSONConverter()
+
+// Hash returns a hash of the given value that's stable across processes, architectures, and Go versions, for
+// example to partition work or derive shard keys in workflow code. The value is encoded as canonical JSON,
+// independent of the configured converter, and hashed with 64-bit FNV-1a, so values with the same encoding
+// have the same hash.
+//
+// Every hash is recorded in the history as a marker. With strict replay enabled, replaying fails if the value
+// hashes differently.
+func Hash(ctx Context, value interface{}) (uint64, error) {
+	p, err := hashConverter.To(value)
+	if err != nil {
+		return 0, fmt.Errorf("converting value to hash: %w", err)
+	}
+
+	h := fnv.New64a()
+	h.Write(p)
+	sum := h.Sum64()
+
+	details, err := hashConverter.To(sum)
+	if err != nil {
+		return 0, fmt.Errorf("converting hash to payload: %w", err)
+	}
+
+	wfState := workflowstate.WorkflowState(ctx)
+	cmd := command.NewRecordMarkerCommand(wfState.GetNextScheduleEventID(), HashMarkerName, details)
+	cmd.Validate = true
+	wfState.AddCommand(cmd)
+
+	return sum, nil
+}
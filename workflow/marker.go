@@ -0,0 +1,28 @@
+package workflow
+
+import (
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/internal/command"
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+)
+
+// RecordMarker adds a marker event with the given name and details to the workflow history. Markers
+// have no effect on the execution of the workflow, but can be used to record business checkpoints that
+// show up when inspecting the history, for example in the diagnostics web UI. When the workflow is
+// replayed, the marker is not recorded again.
+func RecordMarker[T any](ctx Context, name string, details T) error {
+	wfState := workflowstate.WorkflowState(ctx)
+	scheduleEventID := wfState.GetNextScheduleEventID()
+
+	detailsPayload, err := converter.DefaultConverter.To(details)
+	if err != nil {
+		return fmt.Errorf("converting marker details to payload: %w", err)
+	}
+
+	cmd := command.NewRecordMarkerCommand(scheduleEventID, name, detailsPayload)
+	wfState.AddCommand(cmd)
+
+	return nil
+}
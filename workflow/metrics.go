@@ -0,0 +1,19 @@
+package workflow
+
+import (
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+	"github.com/cschleiden/go-workflows/metrics"
+)
+
+// Metrics returns the metrics client of the worker executing the workflow. Metrics are only emitted while the
+// workflow is not replaying, so every metric is recorded once.
+func Metrics(ctx Context) metrics.Client {
+	wfState := workflowstate.WorkflowState(ctx)
+	return wfState.Metrics()
+}
+
+// RecordMetric increments the counter with the given name by value. Like all metrics emitted from workflows,
+// it's only recorded while the workflow is not replaying.
+func RecordMetric(ctx Context, name string, value int64, tags metrics.Tags) {
+	Metrics(ctx).Counter(name, tags, value)
+}
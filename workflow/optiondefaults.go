@@ -0,0 +1,76 @@
+package workflow
+
+import (
+	"reflect"
+
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+)
+
+// activityOptions applies the defaults registered on the worker for the given activity. Retry options passed to
+// ExecuteActivity take precedence, unless they are the zero value or DefaultRetryOptions, as do a queue that's
+// not empty, labels, and timeouts.
+func activityOptions(ctx Context, name string, options ActivityOptions) ActivityOptions {
+	wfState := workflowstate.WorkflowState(ctx)
+
+	d, ok := wfState.ActivityOptionDefaults(name)
+	if !ok {
+		return options
+	}
+
+	defaults, ok := d.(ActivityOptions)
+	if !ok {
+		return options
+	}
+
+	if isDefaultRetryOptions(options.RetryOptions, DefaultRetryOptions) {
+		options.RetryOptions = defaults.RetryOptions
+	}
+
+	if options.Queue == "" {
+		options.Queue = defaults.Queue
+	}
+
+	if len(options.Labels) == 0 {
+		options.Labels = defaults.Labels
+	}
+
+	if options.HeartbeatTimeout == 0 {
+		options.HeartbeatTimeout = defaults.HeartbeatTimeout
+	}
+
+	if options.ScheduleToStartTimeout == 0 {
+		options.ScheduleToStartTimeout = defaults.ScheduleToStartTimeout
+	}
+
+	if options.StartToCloseTimeout == 0 {
+		options.StartToCloseTimeout = defaults.StartToCloseTimeout
+	}
+
+	return options
+}
+
+// subWorkflowOptions applies the defaults registered on the worker for the given workflow. Options passed to
+// CreateSubWorkflowInstance take precedence, unless they are the zero value or the package defaults.
+func subWorkflowOptions(ctx Context, name string, options SubWorkflowOptions) SubWorkflowOptions {
+	wfState := workflowstate.WorkflowState(ctx)
+
+	d, ok := wfState.SubWorkflowOptionDefaults(name)
+	if !ok {
+		return options
+	}
+
+	defaults, ok := d.(SubWorkflowOptions)
+	if !ok {
+		return options
+	}
+
+	if isDefaultRetryOptions(options.RetryOptions, DefaultSubWorkflowRetryOptions) {
+		options.RetryOptions = defaults.RetryOptions
+	}
+
+	return options
+}
+
+func isDefaultRetryOptions(o, defaults RetryOptions) bool {
+	return reflect.DeepEqual(o, RetryOptions{}) || reflect.DeepEqual(o, defaults)
+}
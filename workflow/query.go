@@ -0,0 +1,106 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	a "github.com/cschleiden/go-workflows/internal/args"
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/sync"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// SetQueryHandler exposes state of the workflow to other workflows, which can read it with
+// QueryExternalWorkflow, and to clients, see client.QueryWorkflow. handler has to be a function accepting any number of serializable arguments, and
+// returning a serializable result and an error. It's called after the history of the instance has been
+// replayed, outside of the workflow's goroutines, so it must not block or change workflow state.
+func SetQueryHandler(ctx Context, name string, handler interface{}) error {
+	hv := reflect.ValueOf(handler)
+	ht := hv.Type()
+	if ht.Kind() != reflect.Func || ht.NumOut() != 2 || ht.Out(1) != errorType {
+		return errors.New("query handler has to be a function returning (result, error)")
+	}
+
+	for i := 0; i < ht.NumIn(); i++ {
+		if a.IsOwnContext(ht.In(i)) || a.IsContext(ht.In(i)) {
+			return errors.New("query handler must not accept a context")
+		}
+	}
+
+	wfState := workflowstate.WorkflowState(ctx)
+	wfState.SetQueryHandler(name, func(inputs []payload.Payload) (result payload.Payload, err error) {
+		args, _, err := a.InputsToArgs(converter.DefaultConverter, hv, inputs)
+		if err != nil {
+			return nil, fmt.Errorf("converting query arguments: %w", err)
+		}
+
+		// Query handlers run in the worker or client process, don't let a panic take it down
+		defer func() {
+			if r := recover(); r != nil {
+				result, err = nil, fmt.Errorf("query handler %s panicked: %v", name, r)
+			}
+		}()
+
+		r := hv.Call(args)
+		if !r[1].IsNil() {
+			return nil, r[1].Interface().(error)
+		}
+
+		return converter.DefaultConverter.To(r[0].Interface())
+	})
+
+	return nil
+}
+
+// QueryExternalWorkflow calls the query handler with the given name of another workflow instance. The query
+// runs as an activity built into every worker, which replays the other instance and calls its handler, so the
+// result is recorded in the history like any activity result. The activity fails if the workflow of the other
+// instance is not registered with the worker executing it.
+func QueryExternalWorkflow[TResult any](ctx Context, options ActivityOptions, instanceID, name string, args ...interface{}) Future[TResult] {
+	result := sync.NewFuture[TResult]()
+
+	inputs, err := a.ArgsToInputs(converter.DefaultConverter, args...)
+	if err != nil {
+		result.Set(*new(TResult), fmt.Errorf("converting query arguments: %w", err))
+		return result
+	}
+
+	req := workflowstate.QueryRequest{
+		InstanceID: instanceID,
+		Name:       name,
+		Inputs:     inputs,
+	}
+
+	var scheduleEventID int64
+	deadline := retryDeadline(ctx, options.RetryOptions)
+
+	f := withRetries(ctx, options.RetryOptions, func(ctx sync.Context, attempt int) Future[payload.Payload] {
+		var f Future[payload.Payload]
+		f, scheduleEventID = executeActivity[payload.Payload](ctx, options, attempt, deadline, "", nil, workflowstate.QueryActivityName, req)
+		return f
+	}, func() string {
+		return ActivityOperatorSignal(scheduleEventID)
+	})
+
+	Go(ctx, func(ctx Context) {
+		p, err := f.Get(ctx)
+		if err != nil {
+			result.Set(*new(TResult), err)
+			return
+		}
+
+		var r TResult
+		if err := converter.DefaultConverter.From(p, &r); err != nil {
+			result.Set(*new(TResult), fmt.Errorf("converting query result: %w", err))
+			return
+		}
+
+		result.Set(r, nil)
+	})
+
+	return result
+}
@@ -0,0 +1,52 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/logger"
+	"github.com/cschleiden/go-workflows/internal/metrics"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/sync"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+	"github.com/stretchr/testify/require"
+)
+
+func newQueryContext() (Context, *workflowstate.WfState) {
+	wfState := workflowstate.NewWorkflowState(core.NewWorkflowInstance("a", "b"), logger.NewDefaultLogger(), metrics.NewNoopMetricsClient(), clock.New())
+	return workflowstate.WithWorkflowState(sync.Background(), wfState), wfState
+}
+
+func Test_SetQueryHandler_RejectsContext(t *testing.T) {
+	ctx, _ := newQueryContext()
+
+	require.Error(t, SetQueryHandler(ctx, "q", func(ctx Context) (int, error) { return 0, nil }))
+	require.Error(t, SetQueryHandler(ctx, "q", func(ctx context.Context) (int, error) { return 0, nil }))
+	require.Error(t, SetQueryHandler(ctx, "q", func(i int, ctx context.Context) (int, error) { return 0, nil }))
+	require.NoError(t, SetQueryHandler(ctx, "q", func(i int) (int, error) { return i, nil }))
+}
+
+func Test_SetQueryHandler_RecoversPanic(t *testing.T) {
+	ctx, wfState := newQueryContext()
+
+	require.NoError(t, SetQueryHandler(ctx, "q", func() (int, error) { panic("boom") }))
+
+	h, ok := wfState.QueryHandler("q")
+	require.True(t, ok)
+
+	r, err := h([]payload.Payload{})
+	require.Nil(t, r)
+	require.ErrorContains(t, err, "boom")
+
+	require.NoError(t, SetQueryHandler(ctx, "q", func() (int, error) { return 42, nil }))
+	h, _ = wfState.QueryHandler("q")
+	r, err = h([]payload.Payload{})
+	require.NoError(t, err)
+
+	var v int
+	require.NoError(t, converter.DefaultConverter.From(r, &v))
+	require.Equal(t, 42, v)
+}
@@ -0,0 +1,28 @@
+package workflow
+
+import (
+	"math/rand"
+
+	"github.com/cschleiden/go-workflows/internal/sync"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+	"github.com/google/uuid"
+)
+
+// Random returns a pseudo-random number generator for workflow code. It's seeded with a seed recorded in the
+// history when the execution was started, so replays, also on other machines or of exported histories, return
+// the same sequence of numbers. Like other workflow state, it must not be used outside of the workflow.
+func Random(ctx sync.Context) *rand.Rand {
+	wfState := workflowstate.WorkflowState(ctx)
+	return wfState.Random()
+}
+
+// NewUUID returns a random UUID, drawn from the generator returned by Random.
+func NewUUID(ctx sync.Context) string {
+	id, err := uuid.NewRandomFromReader(Random(ctx))
+	if err != nil {
+		// Reading from a *rand.Rand never fails
+		panic(err)
+	}
+
+	return id.String()
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/cschleiden/go-workflows/internal/sync"
+	"github.com/cschleiden/go-workflows/workflowerrors"
 )
 
 type RetryOptions struct {
@@ -22,6 +23,9 @@ type RetryOptions struct {
 
 	// Timeout after which retries are aborted
 	RetryTimeout time.Duration
+
+	// Errors with any of these types are not retried, see activity.NewError
+	NonRetryableErrorTypes []string
 }
 
 var DefaultRetryOptions = RetryOptions{
@@ -29,9 +33,23 @@ var DefaultRetryOptions = RetryOptions{
 	BackoffCoefficient: 1,
 }
 
-func withRetries[T any](ctx sync.Context, retryOptions RetryOptions, fn func(ctx sync.Context, attempt int) Future[T]) Future[T] {
+// retryDeadline returns the time after which retries that start now are aborted, or the zero time if they
+// are not limited by time.
+func retryDeadline(ctx sync.Context, retryOptions RetryOptions) time.Time {
+	if retryOptions.RetryTimeout == 0 {
+		return time.Time{}
+	}
+
+	return Now(ctx).Add(retryOptions.RetryTimeout)
+}
+
+// withRetries runs fn until it succeeds or retries are exhausted. If operatorSignal is given, it returns the
+// signal operators can use to cut the backoff after the latest failed attempt short, see waitForRetry.
+func withRetries[T any](
+	ctx sync.Context, retryOptions RetryOptions, fn func(ctx sync.Context, attempt int) Future[T], operatorSignal func() string,
+) Future[T] {
 	attempt := 0
-	firstAttempt := Now(ctx)
+	retryExpiration := retryDeadline(ctx, retryOptions)
 
 	f := fn(ctx, attempt)
 
@@ -47,11 +65,6 @@ func withRetries[T any](ctx sync.Context, retryOptions RetryOptions, fn func(ctx
 		var result T
 		var err error
 
-		var retryExpiration time.Time
-		if retryOptions.RetryTimeout != 0 {
-			retryExpiration = firstAttempt.Add(retryOptions.RetryTimeout)
-		}
-
 		for {
 			// Wait for active operation to finish
 			result, err = f.Get(ctx)
@@ -63,12 +76,29 @@ func withRetries[T any](ctx sync.Context, retryOptions RetryOptions, fn func(ctx
 				break
 			}
 
+			if isNonRetryable(err, retryOptions.NonRetryableErrorTypes) {
+				break
+			}
+
 			backoffDuration := time.Duration(float64(retryOptions.FirstRetryInterval) * math.Pow(retryOptions.BackoffCoefficient, float64(attempt)))
 			if retryOptions.MaxRetryInterval > 0 {
 				backoffDuration = time.Duration(math.Min(float64(backoffDuration), float64(retryOptions.MaxRetryInterval)))
 			}
 
-			if err := Sleep(ctx, backoffDuration); err != nil {
+			if operatorSignal != nil {
+				retryNow, err := waitForRetry(ctx, backoffDuration, operatorSignal())
+				if err != nil {
+					r.Set(*new(T), err)
+					return
+				}
+
+				if retryNow {
+					// Operator requested another attempt, regardless of the retry limits
+					attempt++
+					f = fn(ctx, attempt)
+					continue
+				}
+			} else if err := Sleep(ctx, backoffDuration); err != nil {
 				r.Set(*new(T), err)
 				return
 			}
@@ -83,7 +113,7 @@ func withRetries[T any](ctx sync.Context, retryOptions RetryOptions, fn func(ctx
 				break
 			}
 
-			f = fn(ctx, attempt+1)
+			f = fn(ctx, attempt)
 		}
 
 		r.Set(result, err)
@@ -91,3 +121,18 @@ func withRetries[T any](ctx sync.Context, retryOptions RetryOptions, fn func(ctx
 
 	return r
 }
+
+func isNonRetryable(err error, nonRetryableErrorTypes []string) bool {
+	errorType := workflowerrors.Type(err)
+	if errorType == "" {
+		return false
+	}
+
+	for _, t := range nonRetryableErrorTypes {
+		if t == errorType {
+			return true
+		}
+	}
+
+	return false
+}
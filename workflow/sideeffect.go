@@ -22,7 +22,7 @@ func SideEffect[TResult any](ctx Context, f func(ctx Context) TResult) Future[TR
 	wfState := workflowstate.WorkflowState(ctx)
 	scheduleEventID := wfState.GetNextScheduleEventID()
 
-	wfState.TrackFuture(scheduleEventID, workflowstate.AsDecodingSettable(future))
+	wfState.TrackFuture(scheduleEventID, workflowstate.AsDecodingSettable(wfState, future))
 
 	cmd := command.NewSideEffectCommand(scheduleEventID)
 	wfState.AddCommand(cmd)
@@ -2,9 +2,11 @@ package workflow
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/cschleiden/go-workflows/internal/command"
 	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/payload"
 	"github.com/cschleiden/go-workflows/internal/workflowstate"
 	"github.com/cschleiden/go-workflows/internal/workflowtracer"
 )
@@ -14,6 +16,41 @@ func NewSignalChannel[T any](ctx Context, name string) Channel[T] {
 	return workflowstate.GetSignalChannel[T](ctx, wfState, name)
 }
 
+// HandleSignal registers handler to be called with the argument of every signal with the given name, including
+// signals that arrived before it was registered. Handlers are called as signals arrive, between the awaits of
+// the workflow and outside of its goroutines, so they must not block. Signals with a handler are not delivered to
+// signal channels.
+func HandleSignal[T any](ctx Context, name string, handler func(T)) {
+	wfState := workflowstate.WorkflowState(ctx)
+	wfState.SetSignalHandler(name, func(arg payload.Payload) {
+		var v T
+		if err := converter.DefaultConverter.From(arg, &v); err != nil {
+			panic(fmt.Errorf("converting signal %s argument: %w", name, err))
+		}
+
+		handler(v)
+	})
+}
+
+// AwaitSignalWithTimeout waits for the next signal with the given name, but at most for the given duration. ok
+// is false if the timeout expired or the context was canceled before a signal was received. The timer is
+// canceled if the signal arrives first.
+func AwaitSignalWithTimeout[T any](ctx Context, name string, timeout time.Duration) (value T, ok bool) {
+	tctx, cancel := WithCancel(ctx)
+	defer cancel()
+
+	Select(ctx,
+		Receive(NewSignalChannel[T](ctx, name), func(ctx Context, v T, rok bool) {
+			value, ok = v, rok
+		}),
+		Await(ScheduleTimer(tctx, timeout), func(ctx Context, f Future[struct{}]) {
+			// Timeout expired
+		}),
+	)
+
+	return value, ok
+}
+
 func SignalWorkflow[T any](ctx Context, instanceID string, name string, arg T) error {
 	ctx, span := workflowtracer.Tracer(ctx).Start(ctx, "SignalWorkflow")
 	defer span.End()
@@ -16,10 +16,28 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+// SubWorkflowInstanceIDFunc derives the instance ID of a sub-workflow from its parent instance and execution ID.
+// n is the number of sub-workflows with the given name the parent execution has started so far, starting at 1.
+// It must be deterministic. Executions started by ContinueAsNew keep the instance ID but count from 1 again, so
+// derived IDs have to include the execution ID to be unique.
+type SubWorkflowInstanceIDFunc = workflowstate.SubWorkflowInstanceIDFunc
+
+// HierarchicalSubWorkflowInstanceID derives sub-workflow instance IDs as
+// "<parent instance id>/<parent execution id>/<name>-<n>". This makes hierarchies visible in instance listings and
+// allows finding all children of an instance by prefix.
+func HierarchicalSubWorkflowInstanceID(parentInstanceID, parentExecutionID, name string, n int) string {
+	return fmt.Sprintf("%s/%s/%s-%d", parentInstanceID, parentExecutionID, name, n)
+}
+
 type SubWorkflowOptions struct {
 	InstanceID string
 
 	RetryOptions RetryOptions
+
+	// Version pins the sub-workflow to workers that registered the workflow with the given version, see
+	// worker.WithVersion. This prevents a parent from starting a child on incompatible code during a
+	// deployment. Workers with a different version leave the sub-workflow's tasks to other workers.
+	Version string
 }
 
 var (
@@ -33,10 +51,15 @@ var (
 	}
 )
 
+// CreateSubWorkflowInstance starts the given workflow as a sub-workflow. If the worker has default options for the
+// workflow, see worker.Options.SubWorkflowDefaults, they are used unless options specify other than the default
+// retry options.
 func CreateSubWorkflowInstance[TResult any](ctx sync.Context, options SubWorkflowOptions, workflow interface{}, args ...interface{}) Future[TResult] {
+	options = subWorkflowOptions(ctx, fn.Name(workflow), options)
+
 	return withRetries(ctx, options.RetryOptions, func(ctx sync.Context, attempt int) Future[TResult] {
 		return createSubWorkflowInstance[TResult](ctx, options, attempt, workflow, args...)
-	})
+	}, nil)
 }
 
 func createSubWorkflowInstance[TResult any](ctx sync.Context, options SubWorkflowOptions, attempt int, wf interface{}, args ...interface{}) Future[TResult] {
@@ -71,13 +94,24 @@ func createSubWorkflowInstance[TResult any](ctx sync.Context, options SubWorkflo
 	metadata := &core.WorkflowMetadata{}
 	span.Marshal(metadata)
 
-	cmd := command.NewScheduleSubWorkflowCommand(scheduleEventID, wfState.Instance(), options.InstanceID, name, inputs, metadata)
+	instanceID := options.InstanceID
+	if instanceID == "" {
+		instanceID = wfState.NextSubWorkflowInstanceID(name)
+	}
+
+	cmd := command.NewScheduleSubWorkflowCommand(scheduleEventID, wfState.Instance(), instanceID, name, inputs, metadata, attempt, options.Version)
+	cmd.DerivedInstanceID = options.InstanceID == ""
 	wfState.AddCommand(cmd)
-	wfState.TrackFuture(scheduleEventID, workflowstate.AsDecodingSettable(f))
+	wfState.TrackFuture(scheduleEventID, workflowstate.AsDecodingSettable(wfState, f))
 
 	// Check if the channel is cancelable
 	if c, cancelable := ctx.Done().(sync.CancelChannel); cancelable {
 		c.AddReceiveCallback(func(v struct{}, ok bool) {
+			// Nothing to cancel if the sub-workflow has already completed
+			if cmd.State() == command.CommandState_Done {
+				return
+			}
+
 			cmd.Cancel()
 			if cmd.State() == command.CommandState_Canceled {
 				// Remove the sub-workflow future from the workflow state and mark it as canceled if it hasn't already fired
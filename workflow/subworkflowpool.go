@@ -0,0 +1,118 @@
+package workflow
+
+import (
+	"fmt"
+)
+
+type SubWorkflowPoolOptions struct {
+	// MaxConcurrency is the maximum number of sub-workflows running at the same time. If <= 0, all
+	// sub-workflows are started at once.
+	MaxConcurrency int
+
+	// CancelOnError cancels all running sub-workflows and stops starting new ones when the first
+	// sub-workflow fails.
+	CancelOnError bool
+
+	// SubWorkflowOptions are used for every started sub-workflow. If an InstanceID is given, the index
+	// of the input is appended to it to keep instance IDs unique.
+	SubWorkflowOptions SubWorkflowOptions
+}
+
+var DefaultSubWorkflowPoolOptions = SubWorkflowPoolOptions{
+	SubWorkflowOptions: DefaultSubWorkflowOptions,
+}
+
+// SubWorkflowPoolError is returned by SubWorkflowPool when one or more sub-workflows failed.
+type SubWorkflowPoolError struct {
+	// Errors maps the index of the input to the error of its sub-workflow. Inputs that were not
+	// started because of CancelOnError are reported as Canceled.
+	Errors map[int]error
+}
+
+func (e *SubWorkflowPoolError) Error() string {
+	return fmt.Sprintf("%d sub-workflow(s) failed", len(e.Errors))
+}
+
+// SubWorkflowPool starts a sub-workflow for every input, with at most options.MaxConcurrency running at
+// the same time. Whenever a sub-workflow completes, the next input is started. Results are returned in
+// the order of the inputs. If any sub-workflow failed, a *SubWorkflowPoolError is returned together with
+// the results of the successful ones.
+func SubWorkflowPool[TInput, TResult any](
+	ctx Context, options SubWorkflowPoolOptions, workflow interface{}, inputs []TInput,
+) ([]TResult, error) {
+	concurrency := options.MaxConcurrency
+	if concurrency <= 0 || concurrency > len(inputs) {
+		concurrency = len(inputs)
+	}
+
+	ctx, cancel := WithCancel(ctx)
+	defer cancel()
+
+	type pending struct {
+		idx int
+		f   Future[TResult]
+	}
+
+	results := make([]TResult, len(inputs))
+	errs := make(map[int]error)
+	running := make([]pending, 0, concurrency)
+	next := 0
+	stopped := false
+
+	for {
+		for !stopped && next < len(inputs) && len(running) < concurrency {
+			swo := options.SubWorkflowOptions
+			if swo.InstanceID != "" {
+				swo.InstanceID = fmt.Sprintf("%s-%d", swo.InstanceID, next)
+			}
+
+			running = append(running, pending{
+				idx: next,
+				f:   CreateSubWorkflowInstance[TResult](ctx, swo, workflow, inputs[next]),
+			})
+			next++
+		}
+
+		if len(running) == 0 {
+			break
+		}
+
+		completed := -1
+		cases := make([]SelectCase, len(running))
+		for i, p := range running {
+			i, p := i, p
+			cases[i] = Await(p.f, func(ctx Context, f Future[TResult]) {
+				completed = i
+
+				r, err := f.Get(ctx)
+				if err != nil {
+					errs[p.idx] = err
+
+					if options.CancelOnError && !stopped {
+						stopped = true
+						cancel()
+					}
+
+					return
+				}
+
+				results[p.idx] = r
+			})
+		}
+
+		Select(ctx, cases...)
+
+		running = append(running[:completed], running[completed+1:]...)
+	}
+
+	// Inputs that were never started
+	for i := next; i < len(inputs); i++ {
+		errs[i] = Canceled
+	}
+
+	if len(errs) > 0 {
+		return results, &SubWorkflowPoolError{Errors: errs}
+	}
+
+	return results, nil
+}
@@ -14,7 +14,15 @@ func NewWaitGroup() WaitGroup {
 	return sync.NewWaitGroup()
 }
 
-// Go spawns a workflow goroutine
+// SchedulerStats are statistics about the workflow goroutines of an instance, see worker.TaskInfo.
+type SchedulerStats = sync.SchedulerStats
+
+// BlockReason describes why a workflow goroutine is blocked.
+type BlockReason = sync.BlockReason
+
+// Go spawns a workflow goroutine. Workflow goroutines run one at a time in a deterministic order: the
+// workflow and its goroutines are resumed in the order they were started, and new goroutines run for
+// the first time after all goroutines started before them.
 func Go(ctx Context, f func(ctx Context)) {
 	sync.Go(ctx, f)
 }
@@ -48,3 +56,10 @@ func Send[T any](c Channel[T], value *T, handler func(ctx Context)) SelectCase {
 func Default(handler func(Context)) SelectCase {
 	return sync.Default(handler)
 }
+
+// Yield pauses the current workflow goroutine and lets other workflow goroutines run before it
+// continues. Call it periodically in long running loops to keep the time spent computing between
+// yields below the worker's WorkflowComputeWarningThreshold.
+func Yield(ctx Context) {
+	sync.Yield(ctx)
+}
@@ -0,0 +1,62 @@
+package workflow
+
+import (
+	"github.com/cschleiden/go-workflows/internal/sync"
+)
+
+// TaskGroup runs a group of workflow goroutines working on subtasks of a common task, like errgroup.Group
+// does for goroutines. The first error returned by a goroutine cancels the context of the group, so
+// siblings waiting on activities, timers, or sub-workflows are canceled as well.
+type TaskGroup struct {
+	ctx    Context
+	cancel CancelFunc
+
+	running int
+	done    sync.SettableFuture[struct{}]
+	err     error
+}
+
+// NewTaskGroup returns a new TaskGroup and a context derived from ctx. The derived context is canceled
+// when a goroutine of the group returns an error, or when Wait returns, whichever happens first.
+func NewTaskGroup(ctx Context) (*TaskGroup, Context) {
+	ctx, cancel := WithCancel(ctx)
+
+	return &TaskGroup{
+		ctx:    ctx,
+		cancel: cancel,
+	}, ctx
+}
+
+// Go runs the given function in a new workflow goroutine with the group's context. Goroutines are
+// started in order, see Go.
+func (g *TaskGroup) Go(f func(ctx Context) error) {
+	g.running++
+
+	Go(g.ctx, func(ctx Context) {
+		if err := f(ctx); err != nil && g.err == nil {
+			g.err = err
+			g.cancel()
+		}
+
+		g.running--
+		if g.running == 0 && g.done != nil {
+			g.done.Set(struct{}{}, nil)
+		}
+	})
+}
+
+// Wait blocks until all goroutines of the group have returned, and returns the first error returned
+// by any of them.
+func (g *TaskGroup) Wait(ctx Context) error {
+	if g.running > 0 {
+		g.done = sync.NewFuture[struct{}]()
+		if _, err := g.done.Get(ctx); err != nil {
+			return err
+		}
+		g.done = nil
+	}
+
+	g.cancel()
+
+	return g.err
+}
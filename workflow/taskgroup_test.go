@@ -0,0 +1,89 @@
+package workflow
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cschleiden/go-workflows/internal/sync"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TaskGroup_WaitsForAllGoroutines(t *testing.T) {
+	s := sync.NewScheduler()
+
+	var order []int
+	var err error
+
+	s.NewCoroutine(sync.Background(), func(ctx Context) error {
+		g, gctx := NewTaskGroup(ctx)
+
+		for i := 0; i < 3; i++ {
+			i := i
+			g.Go(func(ctx Context) error {
+				Yield(ctx)
+				order = append(order, i)
+				return nil
+			})
+		}
+
+		err = g.Wait(ctx)
+		require.Error(t, gctx.Err(), "context should be canceled after Wait")
+
+		return nil
+	})
+
+	require.NoError(t, s.Execute())
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 2}, order)
+}
+
+func Test_TaskGroup_FirstErrorCancelsSiblings(t *testing.T) {
+	s := sync.NewScheduler()
+
+	errFirst := errors.New("first")
+	var err error
+	siblingCanceled := false
+
+	s.NewCoroutine(sync.Background(), func(ctx Context) error {
+		g, _ := NewTaskGroup(ctx)
+
+		g.Go(func(ctx Context) error {
+			Select(ctx, sync.Receive(ctx.Done(), func(ctx Context, _ struct{}, _ bool) {
+				siblingCanceled = true
+			}))
+
+			return errors.New("second")
+		})
+
+		g.Go(func(ctx Context) error {
+			return errFirst
+		})
+
+		err = g.Wait(ctx)
+
+		return nil
+	})
+
+	require.NoError(t, s.Execute())
+	require.Equal(t, errFirst, err)
+	require.True(t, siblingCanceled)
+}
+
+func Test_TaskGroup_WaitWithoutGoroutines(t *testing.T) {
+	s := sync.NewScheduler()
+
+	var err error
+	waited := false
+
+	s.NewCoroutine(sync.Background(), func(ctx Context) error {
+		g, _ := NewTaskGroup(ctx)
+		err = g.Wait(ctx)
+		waited = true
+
+		return nil
+	})
+
+	require.NoError(t, s.Execute())
+	require.NoError(t, err)
+	require.True(t, waited)
+}
@@ -1,6 +1,7 @@
 package workflow
 
 import (
+	"errors"
 	"time"
 
 	"github.com/cschleiden/go-workflows/internal/command"
@@ -11,13 +12,60 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrTimerNotFound is returned when rescheduling a timer that doesn't exist, or has already fired or been
+// canceled.
+var ErrTimerNotFound = errors.New("no pending timer with the given name")
+
 func ScheduleTimer(ctx Context, delay time.Duration) Future[struct{}] {
+	f, _ := scheduleTimer(ctx, delay)
+	return f
+}
+
+// ScheduleNamedTimer schedules a timer like ScheduleTimer. The timer can be moved to a different fire time
+// later by passing the same name to RescheduleTimer.
+func ScheduleNamedTimer(ctx Context, name string, delay time.Duration) Future[struct{}] {
+	f, scheduleEventID := scheduleTimer(ctx, delay)
+	if scheduleEventID != 0 {
+		workflowstate.WorkflowState(ctx).SetNamedTimer(name, scheduleEventID)
+	}
+
+	return f
+}
+
+// RescheduleTimer moves the pending timer with the given name to fire after delay, measured from the
+// current workflow time. Futures returned when scheduling the timer resolve at the new time.
+func RescheduleTimer(ctx Context, name string, delay time.Duration) error {
+	wfState := workflowstate.WorkflowState(ctx)
+
+	scheduleEventID, ok := wfState.NamedTimer(name)
+	if !ok {
+		return ErrTimerNotFound
+	}
+
+	timerCmd, ok := wfState.CommandByScheduleEventID(scheduleEventID).(*command.ScheduleTimerCommand)
+	if !ok {
+		return ErrTimerNotFound
+	}
+
+	switch timerCmd.State() {
+	case command.CommandState_Pending, command.CommandState_Committed:
+	default:
+		// Timer has already fired or has been canceled
+		return ErrTimerNotFound
+	}
+
+	timerCmd.Reschedule(Now(ctx).Add(delay))
+
+	return nil
+}
+
+func scheduleTimer(ctx Context, delay time.Duration) (Future[struct{}], int64) {
 	f := sync.NewFuture[struct{}]()
 
 	// If the context is already canceled, return immediately.
 	if ctx.Err() != nil {
 		f.Set(struct{}{}, ctx.Err())
-		return f
+		return f, 0
 	}
 
 	wfState := workflowstate.WorkflowState(ctx)
@@ -27,7 +75,7 @@ func ScheduleTimer(ctx Context, delay time.Duration) Future[struct{}] {
 	timerCmd := command.NewScheduleTimerCommand(scheduleEventID, at)
 	wfState.AddCommand(timerCmd)
 
-	wfState.TrackFuture(scheduleEventID, workflowstate.AsDecodingSettable(f))
+	wfState.TrackFuture(scheduleEventID, workflowstate.AsDecodingSettable(wfState, f))
 
 	ctx, span := workflowtracer.Tracer(ctx).Start(ctx, "ScheduleTimer",
 		trace.WithAttributes(
@@ -50,6 +98,11 @@ func ScheduleTimer(ctx Context, delay time.Duration) Future[struct{}] {
 			}
 			canceled = true
 
+			// Timer has already fired, nothing to cancel
+			if timerCmd.State() == command.CommandState_Done {
+				return
+			}
+
 			timerCmd.Cancel()
 
 			// Remove the timer future from the workflow state and mark it as canceled if it hasn't already fired. This is different
@@ -64,5 +117,5 @@ func ScheduleTimer(ctx Context, delay time.Duration) Future[struct{}] {
 		})
 	}
 
-	return f
+	return f, scheduleEventID
 }
@@ -0,0 +1,87 @@
+package workflow
+
+import (
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/internal/command"
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+)
+
+// DefaultVersion is the version GetVersion returns for changes made after an instance executed the changed code.
+const DefaultVersion = -1
+
+// GetVersion returns the version of the change identified by changeID that the workflow uses, so that workflow code
+// can be changed without breaking the replay of instances that are already running:
+//
+//	v, err := workflow.GetVersion(ctx, "charge-before-ship", workflow.DefaultVersion, 1)
+//	if err != nil {
+//		return err
+//	}
+//
+//	if v == workflow.DefaultVersion {
+//		// Old code path
+//	} else {
+//		// New code path
+//	}
+//
+// The first time an instance executes GetVersion for a change, it records maxVersion in the history as a marker and
+// returns it. When the workflow is replayed, the recorded version is returned. Instances that executed the changed
+// code before GetVersion was added get DefaultVersion. Once determined, the version of a change stays the same for
+// the rest of the execution.
+//
+// If the version is lower than minVersion or higher than maxVersion, for example because support for old
+// versions has been removed while instances using them are still running, an error is returned.
+func GetVersion(ctx Context, changeID string, minVersion, maxVersion int) (int, error) {
+	wfState := workflowstate.WorkflowState(ctx)
+
+	version, ok := wfState.Version(changeID)
+	if !ok {
+		var err error
+		version, err = determineVersion(ctx, changeID, maxVersion)
+		if err != nil {
+			return DefaultVersion, err
+		}
+
+		wfState.SetVersion(changeID, version)
+	}
+
+	if version < minVersion || version > maxVersion {
+		return DefaultVersion, fmt.Errorf(
+			"version %d of change %q is not supported, supported versions are %d to %d", version, changeID, minVersion, maxVersion)
+	}
+
+	return version, nil
+}
+
+func determineVersion(ctx Context, changeID string, maxVersion int) (int, error) {
+	wfState := workflowstate.WorkflowState(ctx)
+	name := workflowstate.VersionMarkerPrefix + changeID
+
+	if Replaying(ctx) {
+		p, recorded := wfState.RecordedVersion(changeID)
+		if !recorded {
+			// The changed code was executed before GetVersion was added. This doesn't schedule an event, since the
+			// previous execution didn't either.
+			return DefaultVersion, nil
+		}
+
+		var version int
+		if err := converter.DefaultConverter.From(p, &version); err != nil {
+			return DefaultVersion, fmt.Errorf("converting recorded version: %w", err)
+		}
+
+		wfState.AddCommand(command.NewRecordMarkerCommand(wfState.GetNextScheduleEventID(), name, p))
+
+		return version, nil
+	}
+
+	p, err := converter.DefaultConverter.To(maxVersion)
+	if err != nil {
+		return DefaultVersion, fmt.Errorf("converting version: %w", err)
+	}
+
+	wfState.AddCommand(command.NewRecordMarkerCommand(wfState.GetNextScheduleEventID(), name, p))
+
+	return maxVersion, nil
+}
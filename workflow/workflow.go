@@ -2,6 +2,7 @@ package workflow
 
 import (
 	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
 )
 
 type (
@@ -9,3 +10,7 @@ type (
 	Metadata = core.WorkflowMetadata
 	Workflow = interface{}
 )
+
+// SchemaMismatchError fails a workflow when a recorded payload, e.g., an activity result, can't be decoded
+// into the type the workflow expects. It identifies the mismatching field and the history event.
+type SchemaMismatchError = workflowstate.SchemaMismatchError
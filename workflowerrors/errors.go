@@ -0,0 +1,253 @@
+// Package workflowerrors contains the errors returned by workflows, activities, workers, and the client. Check for
+// them with errors.As. Errors returned by activities keep their type when they are delivered to the workflow, and
+// when the workflow is replayed, since the type is recorded in the history.
+package workflowerrors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cschleiden/go-workflows/internal/converter"
+)
+
+// Types of errors recorded in the history, see Type.
+const (
+	TimeoutErrorType        = "Timeout"
+	CanceledErrorType       = "Canceled"
+	PanicErrorType          = "Panic"
+	QuotaExceededErrorType  = "QuotaExceeded"
+	NonDeterminismErrorType = "NonDeterminism"
+	TerminatedErrorType     = "Terminated"
+)
+
+type typedError interface {
+	ErrorType() string
+}
+
+// Type returns the type of the given error, or an empty string if it doesn't have one. Types of errors returned
+// by activities are recorded in the history, and can be used in workflow.RetryOptions.NonRetryableErrorTypes.
+func Type(err error) string {
+	var t typedError
+	if errors.As(err, &t) {
+		return t.ErrorType()
+	}
+
+	return ""
+}
+
+// typed returns the error with the given type and message, as recorded in the history.
+func typed(errorType, message string) error {
+	switch errorType {
+	case "":
+		return nil
+	case TimeoutErrorType:
+		return &TimeoutError{Message: message}
+	case CanceledErrorType:
+		return &CanceledError{Message: message}
+	case PanicErrorType:
+		return &PanicError{Value: strings.TrimPrefix(message, panicPrefix)}
+	case QuotaExceededErrorType:
+		return &QuotaExceededError{Message: message}
+	case NonDeterminismErrorType:
+		return &NonDeterminismError{Message: message}
+	case TerminatedErrorType:
+		return &TerminatedError{Message: message}
+	default:
+		return &ApplicationError{Type: errorType, Message: message}
+	}
+}
+
+// ApplicationError is an error with a type chosen by the application, e.g., returned by an activity with
+// activity.NewError.
+type ApplicationError struct {
+	Type    string
+	Message string
+}
+
+func NewApplicationError(errorType, message string) *ApplicationError {
+	return &ApplicationError{
+		Type:    errorType,
+		Message: message,
+	}
+}
+
+func (e *ApplicationError) Error() string {
+	return e.Message
+}
+
+func (e *ApplicationError) ErrorType() string {
+	return e.Type
+}
+
+// ActivityError is returned to workflows when an activity failed and is not retried anymore. If the activity
+// returned an error with a type, it's available with errors.As, e.g., as a *TimeoutError or an *ApplicationError.
+type ActivityError struct {
+	// Activity is the name of the activity
+	Activity string
+
+	// ScheduleEventID identifies the failed attempt in the history of the workflow instance
+	ScheduleEventID int64
+
+	// Type is the type of the error returned by the activity, if any
+	Type string
+
+	Message string
+
+	// HeartbeatDetails are the encoded details of the last heartbeat the activity recorded, if any. Use
+	// DecodeHeartbeatDetails to decode them.
+	HeartbeatDetails []byte
+}
+
+func (e *ActivityError) Error() string {
+	return e.Message
+}
+
+// DecodeHeartbeatDetails decodes the details of the last heartbeat the activity recorded into v. It returns false
+// if the activity didn't record any details.
+func (e *ActivityError) DecodeHeartbeatDetails(v interface{}) (bool, error) {
+	if len(e.HeartbeatDetails) == 0 {
+		return false, nil
+	}
+
+	if err := converter.DefaultConverter.From(e.HeartbeatDetails, v); err != nil {
+		return false, fmt.Errorf("converting heartbeat details: %w", err)
+	}
+
+	return true, nil
+}
+
+func (e *ActivityError) Unwrap() error {
+	return typed(e.Type, e.Message)
+}
+
+// ChildWorkflowError is returned to workflows when a sub-workflow failed. If the sub-workflow failed with an error
+// with a type, it's available with errors.As, e.g., as a *PanicError or an *ApplicationError.
+type ChildWorkflowError struct {
+	// Workflow is the name of the sub-workflow
+	Workflow string
+
+	InstanceID string
+
+	// Type is the type of the error the sub-workflow failed with, if any
+	Type string
+
+	Message string
+}
+
+func (e *ChildWorkflowError) Error() string {
+	return e.Message
+}
+
+func (e *ChildWorkflowError) Unwrap() error {
+	return typed(e.Type, e.Message)
+}
+
+// WorkflowError is returned by the client when a workflow instance failed. If the workflow failed with an error
+// with a type, it's available with errors.As, e.g., as a *PanicError or an *ApplicationError.
+type WorkflowError struct {
+	InstanceID string
+
+	// Type is the type of the error the workflow failed with, if any
+	Type string
+
+	Message string
+}
+
+func (e *WorkflowError) Error() string {
+	return e.Message
+}
+
+func (e *WorkflowError) Unwrap() error {
+	return typed(e.Type, e.Message)
+}
+
+// TerminatedError is returned by the client for a workflow instance that was terminated.
+type TerminatedError struct {
+	Message string
+}
+
+func (e *TerminatedError) Error() string {
+	return e.Message
+}
+
+func (e *TerminatedError) ErrorType() string {
+	return TerminatedErrorType
+}
+
+// TimeoutError is returned when an operation didn't finish in time, e.g., an activity that exceeded its
+// MaxRuntime, or a workflow instance that exceeded its timeout.
+type TimeoutError struct {
+	Message string
+}
+
+func (e *TimeoutError) Error() string {
+	return e.Message
+}
+
+func (e *TimeoutError) ErrorType() string {
+	return TimeoutErrorType
+}
+
+// CanceledError is returned when an operation was canceled, e.g., awaiting a future in a canceled workflow
+// context. workflow.Canceled is a CanceledError.
+type CanceledError struct {
+	Message string
+}
+
+func (e *CanceledError) Error() string {
+	return e.Message
+}
+
+func (e *CanceledError) ErrorType() string {
+	return CanceledErrorType
+}
+
+// PanicError is returned when workflow code panicked. The workflow instance fails with it.
+type PanicError struct {
+	// Value is the value passed to panic
+	Value interface{}
+
+	// Stack is the stack trace of the panicking goroutine
+	Stack string
+}
+
+// panicPrefix is the prefix of the messages of PanicErrors.
+const panicPrefix = "panic: "
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s%v", panicPrefix, e.Value)
+}
+
+func (e *PanicError) ErrorType() string {
+	return PanicErrorType
+}
+
+// NonDeterminismError is returned by the executor when replaying the history of a workflow instance doesn't
+// produce the same commands as the previous execution, e.g., because the workflow code changed in an incompatible
+// way. The workflow instance fails with it.
+type NonDeterminismError struct {
+	Message string
+}
+
+func (e *NonDeterminismError) Error() string {
+	return e.Message
+}
+
+func (e *NonDeterminismError) ErrorType() string {
+	return NonDeterminismErrorType
+}
+
+// QuotaExceededError is returned when an operation was rejected because a limit was reached, e.g., the memory or
+// concurrency limit of an activity.
+type QuotaExceededError struct {
+	Message string
+}
+
+func (e *QuotaExceededError) Error() string {
+	return e.Message
+}
+
+func (e *QuotaExceededError) ErrorType() string {
+	return QuotaExceededErrorType
+}
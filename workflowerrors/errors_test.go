@@ -0,0 +1,61 @@
+package workflowerrors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Type(t *testing.T) {
+	require.Equal(t, "", Type(errors.New("error")))
+	require.Equal(t, "InvalidInput", Type(NewApplicationError("InvalidInput", "invalid input")))
+	require.Equal(t, "InvalidInput", Type(fmt.Errorf("wrapped: %w", NewApplicationError("InvalidInput", "invalid input"))))
+	require.Equal(t, TimeoutErrorType, Type(&TimeoutError{Message: "timed out"}))
+	require.Equal(t, PanicErrorType, Type(&PanicError{Value: "boom"}))
+	require.Equal(t, NonDeterminismErrorType, Type(&NonDeterminismError{Message: "changed"}))
+	require.Equal(t, TerminatedErrorType, Type(&TerminatedError{Message: "terminated"}))
+}
+
+func Test_ActivityError_Unwrap(t *testing.T) {
+	err := error(&ActivityError{Activity: "a", ScheduleEventID: 1, Type: TimeoutErrorType, Message: "timed out"})
+
+	var timeoutErr *TimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.Equal(t, "timed out", timeoutErr.Message)
+	require.Equal(t, TimeoutErrorType, Type(err))
+
+	err = &ActivityError{Activity: "a", ScheduleEventID: 1, Type: "InvalidInput", Message: "invalid input"}
+
+	var appErr *ApplicationError
+	require.ErrorAs(t, err, &appErr)
+	require.Equal(t, "InvalidInput", appErr.Type)
+
+	err = &ActivityError{Activity: "a", ScheduleEventID: 1, Message: "failed"}
+	require.Nil(t, errors.Unwrap(err))
+	require.Equal(t, "", Type(err))
+	require.Equal(t, "failed", err.Error())
+}
+
+func Test_WorkflowError_Unwrap(t *testing.T) {
+	// Workflow failures are recorded with the message and type of the error, and decoded into the typed error
+	for _, original := range []error{
+		&PanicError{Value: "boom"},
+		&NonDeterminismError{Message: "changed"},
+		&TimeoutError{Message: "timed out"},
+		NewApplicationError("InvalidInput", "invalid input"),
+	} {
+		err := error(&WorkflowError{InstanceID: "i", Type: Type(original), Message: original.Error()})
+		require.Equal(t, original.Error(), err.Error())
+		require.Equal(t, original, errors.Unwrap(err))
+	}
+
+	err := &ChildWorkflowError{Workflow: "wf", InstanceID: "i", Type: PanicErrorType, Message: "panic: boom"}
+
+	var panicErr *PanicError
+	require.ErrorAs(t, err, &panicErr)
+	require.Equal(t, "boom", panicErr.Value)
+
+	require.Nil(t, errors.Unwrap(&WorkflowError{InstanceID: "i", Message: "failed"}))
+}